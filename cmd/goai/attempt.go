@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Zerofisher/goai/pkg/attempt"
+	"github.com/Zerofisher/goai/pkg/git"
+)
+
+// runAttemptCommand runs "goai attempt [-n N] [-verify CMD] task": N agents
+// solve the task in separate git worktrees, and the user picks the result
+// to apply. It returns the exit code.
+func runAttemptCommand(args []string) int {
+	flags := flag.NewFlagSet("attempt", flag.ContinueOnError)
+	count := flags.Int("n", 0, "number of attempts (default attempt.count)")
+	verify := flags.String("verify", "", "command run in every worktree (default attempt.verify)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	task := strings.TrimSpace(strings.Join(flags.Args(), " "))
+	if task == "" {
+		fmt.Println("Usage: goai attempt [-n N] [-verify CMD] \"task\"")
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading configuration: %v\n", err)
+		return 1
+	}
+	if *count <= 0 {
+		*count = cfg.Attempt.Count
+	}
+	if *verify == "" {
+		*verify = cfg.Attempt.Verify
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if !git.New(cfg.WorkDir).IsRepo(ctx) {
+		fmt.Println("Error: goai attempt needs a git repository")
+		return 1
+	}
+
+	// Every attempt gets its own agent, dispatcher and session
+	newAgent := func(dir string) (attempt.Agent, error) {
+		attemptCfg := *cfg
+		attemptCfg.WorkDir = dir
+		attemptCfg.Checkpoints.GitHistory = false
+		return createAgent(&attemptCfg)
+	}
+
+	fmt.Printf("Running %d attempts in separate worktrees", *count)
+	if *verify != "" {
+		fmt.Printf(", verifying with: %s", *verify)
+	}
+	fmt.Println()
+	batch, err := attempt.Run(ctx, cfg.WorkDir, task, newAgent, attempt.Options{
+		Count:         *count,
+		Verify:        *verify,
+		VerifyTimeout: time.Duration(cfg.Attempt.VerifyTimeoutMs) * time.Millisecond,
+		OnDone: func(r *attempt.Result) {
+			fmt.Printf("Finished attempt %s\n", r.Summary())
+		},
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	defer func() {
+		if err := batch.Cleanup(context.Background()); err != nil {
+			fmt.Printf("Failed to remove the worktrees: %v\n", err)
+		}
+	}()
+
+	fmt.Println()
+	fmt.Println(batch.Compare())
+	if ctx.Err() != nil {
+		return 1
+	}
+	return pickAttempt(ctx, batch)
+}
+
+// pickAttempt asks which attempt to apply until one is applied or the user
+// discards them all.
+func pickAttempt(ctx context.Context, batch *attempt.Batch) int {
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("\nApply which attempt? [1-%d, d<n> for details, Enter to discard]: ", len(batch.Results))
+		line, err := reader.ReadString('\n')
+		choice := strings.ToLower(strings.TrimSpace(line))
+		if choice == "" || choice == "q" {
+			fmt.Println("Discarded all attempts.")
+			return 0
+		}
+
+		detail := strings.HasPrefix(choice, "d")
+		n, convErr := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(choice, "d"), "#")))
+		if convErr != nil {
+			fmt.Printf("Invalid choice %q.\n", choice)
+		} else if detail {
+			if r, err := batch.Get(n); err != nil {
+				fmt.Println(err)
+			} else {
+				fmt.Println()
+				fmt.Println(r.Detail())
+			}
+		} else if r, err := batch.Apply(ctx, n); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		} else {
+			fmt.Printf("Applied attempt #%d to the work tree (%s); the changes are not staged.\n", r.N, r.Summary())
+			return 0
+		}
+		if err != nil {
+			// Input ended without an applied attempt
+			return 1
+		}
+	}
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/chzyer/readline"
 	"github.com/Zerofisher/goai/pkg/agent"
+	"github.com/Zerofisher/goai/pkg/backup"
+	"github.com/Zerofisher/goai/pkg/checkpoint"
 	"github.com/Zerofisher/goai/pkg/config"
 )
 
@@ -84,6 +86,7 @@ func (s *InteractiveSession) FormatResponse(response string) string {
 
 // HandleExit performs cleanup and exits the application.
 func (s *InteractiveSession) HandleExit() {
+	_ = s.agent.Close() // Stop language servers and other tool processes
 	fmt.Printf("%sGoodbye!%s\n", primaryColor, resetColor)
 	os.Exit(0)
 }
@@ -134,6 +137,7 @@ func runInteractiveLoop(ctx context.Context, a *agent.Agent) {
 
 			// Process regular query with timeout
 			session.StartSpinner("Thinking...")
+			lastCheckpoint := latestCheckpoint(a)
 
 			// Create a context with timeout for this query (120 seconds)
 			queryCtx, cancel := context.WithTimeout(ctx, 120*time.Second)
@@ -152,12 +156,25 @@ func runInteractiveLoop(ctx context.Context, a *agent.Agent) {
 			} else {
 				fmt.Println(session.FormatResponse(response))
 			}
+			if note := checkpointNote(a, lastCheckpoint); note != "" {
+				fmt.Printf("%s%s%s\n", dimColor, note, resetColor)
+			}
 		}
 	}
 }
 
 // handleSpecialCommand handles special commands and returns true if handled
 func handleSpecialCommand(input string, session *InteractiveSession, a *agent.Agent) bool {
+	// Checkpoint and backup commands take arguments and need the slash
+	if output, ok := checkpoint.RunCommand(a.GetCheckpoints(), input); ok {
+		session.PrintInfo(output)
+		return true
+	}
+	if output, ok := backup.RunCommand(a.GetBackups(), input); ok { // Paths keep their case
+		session.PrintInfo(output)
+		return true
+	}
+
 	lowered := strings.ToLower(strings.TrimSpace(input))
 
 	// Remove leading slash if present
@@ -190,6 +207,25 @@ func handleSpecialCommand(input string, session *InteractiveSession, a *agent.Ag
 	}
 }
 
+// latestCheckpoint returns the newest checkpoint, nil if there is none or
+// checkpoints are disabled.
+func latestCheckpoint(a *agent.Agent) *checkpoint.Checkpoint {
+	if a.GetCheckpoints() == nil {
+		return nil
+	}
+	return a.GetCheckpoints().Latest()
+}
+
+// checkpointNote summarizes the files changed by the last turn if it
+// recorded a checkpoint other than previous.
+func checkpointNote(a *agent.Agent, previous *checkpoint.Checkpoint) string {
+	cp := latestCheckpoint(a)
+	if cp == nil || cp == previous {
+		return ""
+	}
+	return fmt.Sprintf("Checkpoint #%d: %s (/undo to revert)", cp.ID, cp.Summary())
+}
+
 // printStats displays agent statistics
 func printStats(a *agent.Agent) {
 	stats := a.GetStats()
@@ -11,16 +11,32 @@ import (
 	"time"
 
 	"github.com/Zerofisher/goai/cmd/goai/tui"
+	"github.com/Zerofisher/goai/pkg/affected"
 	"github.com/Zerofisher/goai/pkg/agent"
+	"github.com/Zerofisher/goai/pkg/backup"
+	"github.com/Zerofisher/goai/pkg/checkpoint"
 	"github.com/Zerofisher/goai/pkg/config"
+	"github.com/Zerofisher/goai/pkg/diagnostics"
 	"github.com/Zerofisher/goai/pkg/dispatcher"
+	"github.com/Zerofisher/goai/pkg/filestate"
+	"github.com/Zerofisher/goai/pkg/git"
+	"github.com/Zerofisher/goai/pkg/llm"
+	"github.com/Zerofisher/goai/pkg/lsp"
 	"github.com/Zerofisher/goai/pkg/reminder"
+	"github.com/Zerofisher/goai/pkg/semantic"
+	"github.com/Zerofisher/goai/pkg/shadow"
 	"github.com/Zerofisher/goai/pkg/todo"
+	"github.com/Zerofisher/goai/pkg/tools"
 	"github.com/Zerofisher/goai/pkg/tools/bash"
+	"github.com/Zerofisher/goai/pkg/tools/codeintel"
 	"github.com/Zerofisher/goai/pkg/tools/edit"
 	"github.com/Zerofisher/goai/pkg/tools/file"
+	gittool "github.com/Zerofisher/goai/pkg/tools/git"
+	lsptool "github.com/Zerofisher/goai/pkg/tools/lsp"
 	"github.com/Zerofisher/goai/pkg/tools/search"
+	"github.com/Zerofisher/goai/pkg/tools/testrun"
 	todotool "github.com/Zerofisher/goai/pkg/tools/todo"
+	"github.com/Zerofisher/goai/pkg/tools/web"
 
 	tea "github.com/charmbracelet/bubbletea"
 
@@ -54,6 +70,11 @@ var (
 		{"/clear, /c", "Clear the conversation history"},
 		{"/stats, /s", "Display agent statistics"},
 		{"/reset, /r", "Reset the agent state"},
+		{"/checkpoints", "List file checkpoints with a diff summary per turn"},
+		{"/undo", "Restore the files changed by the last turn"},
+		{"/rewind <n>", "Restore the files to their state before checkpoint n"},
+		{"/backups [path]", "List the backups made by the edit tools"},
+		{"/restore <id> [target]", "Restore a backup to its file or to target"},
 		{"/exit, /quit", "Exit the application"},
 	}
 )
@@ -68,6 +89,12 @@ func main() {
 		case "--version", "-v", "version":
 			fmt.Printf("%s %s\n", AppName, Version)
 			os.Exit(0)
+		case "backups", "restore":
+			os.Exit(runBackupCommand(os.Args[1:]))
+		case "log", "diff", "checkout", "cherry-pick":
+			os.Exit(runHistoryCommand(os.Args[1:]))
+		case "attempt":
+			os.Exit(runAttemptCommand(os.Args[2:]))
 		}
 	}
 
@@ -75,15 +102,9 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Set up signal handling
+	// Set up signal handling; signals are buffered until the agent exists
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-sigChan
-		fmt.Println("\n\nGracefully shutting down...")
-		cancel()
-		os.Exit(0)
-	}()
 
 	// Load configuration
 	cfg, err := loadConfig()
@@ -99,6 +120,14 @@ func main() {
 		os.Exit(1)
 	}
 
+	go func() {
+		<-sigChan
+		fmt.Println("\n\nGracefully shutting down...")
+		cancel()
+		_ = agent.Close()
+		os.Exit(0)
+	}()
+
 	// Warn if API key is not set
 	if cfg.Model.APIKey == "" || cfg.Model.APIKey == "${OPENAI_API_KEY}" {
 		fmt.Println("\n⚠️  WARNING: OPENAI_API_KEY environment variable is not set!")
@@ -119,6 +148,9 @@ func main() {
 		// Use Bubble Tea TUI
 		runTUI(ctx, agent, cfg)
 	}
+
+	// Shut down language servers and other tool processes
+	_ = agent.Close()
 }
 
 // loadConfig loads the configuration from file or environment
@@ -181,6 +213,40 @@ func createAgent(cfg *config.Config) (*agent.Agent, error) {
 		return nil, fmt.Errorf("failed to register tools: %w", err)
 	}
 
+	// Refuse writes based on a missing or stale read of the file
+	if cfg.Tools.File.RequireRead {
+		tracker := filestate.NewTracker(cfg.WorkDir)
+		a.OnReset(tracker.Reset)
+		a.GetDispatcher().AddMiddleware(dispatcher.ReadGuardMiddleware(tracker, nil))
+	}
+
+	// Record the files each turn changes for /undo and /rewind
+	if cfg.Checkpoints.Enabled {
+		checkpoints := checkpoint.NewManager(cfg.WorkDir, checkpoint.Options{
+			MaxCheckpoints: cfg.Checkpoints.MaxTurns,
+			MaxFileSize:    int64(cfg.Checkpoints.MaxFileSize),
+			Excludes:       cfg.Tools.Search.ExcludePatterns,
+		})
+		a.SetCheckpoints(checkpoints)
+		a.GetDispatcher().AddMiddleware(dispatcher.CheckpointMiddleware(checkpoints, nil, cfg.Checkpoints.TrackCommands))
+
+		// Commit each turn to refs/goai/<session> for goai log and diff
+		if cfg.Checkpoints.GitHistory && git.New(cfg.WorkDir).IsRepo(context.Background()) {
+			recordTurns(a, shadow.NewRecorder(cfg.WorkDir, a.GetSessionID()))
+		}
+	}
+
+	// Check written files and report new problems with the tool result
+	if cfg.Tools.Diagnostics.Enabled {
+		pipeline, err := newDiagnosticsPipeline(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure diagnostics: %w", err)
+		}
+		if !pipeline.Empty() {
+			a.GetDispatcher().AddMiddleware(dispatcher.DiagnosticsMiddleware(pipeline, nil))
+		}
+	}
+
 	return a, nil
 }
 
@@ -189,18 +255,56 @@ func registerTools(a *agent.Agent, cfg *config.Config) error {
 	dispatcher := a.GetDispatcher()
 	enabledTools := []string{}
 
+	// Validate commands checked by the security validator with the bash
+	// tool's rules, so scripts nested in them are checked too
+	if security, ok := dispatcher.GetSecurity().(*tools.DefaultSecurityValidator); ok {
+		commands := bash.NewValidator()
+		commands.SetWorkDir(cfg.WorkDir)
+		commands.SetAllowDestructiveGit(cfg.Tools.Git.AllowDestructive)
+		security.SetCommandValidator(commands)
+	}
+
 	// Register bash tool
 	if isToolEnabled(cfg, "bash") {
 		bashTool := bash.NewBashTool(cfg.WorkDir, 30*time.Second)
+		bashTool.SetMaxOutputChars(cfg.Tools.Bash.MaxOutputChars)
+		bashTool.SetAllowDestructiveGit(cfg.Tools.Git.AllowDestructive)
 		if err := dispatcher.Register(bashTool); err != nil {
 			return fmt.Errorf("failed to register bash tool: %w", err)
 		}
 		enabledTools = append(enabledTools, "bash")
 	}
 
+	// Register the structured test runner - enabled with "bash" config
+	if isToolEnabled(cfg, "bash", "run_tests") {
+		runTestsTool := testrun.NewRunTestsTool(cfg.WorkDir)
+		runTestsTool.SetChangedFiles(func(ctx context.Context) ([]string, error) {
+			return changedFiles(ctx, a, cfg.WorkDir)
+		})
+		a.OnReset(runTestsTool.Reset)
+		if err := dispatcher.Register(runTestsTool); err != nil {
+			return fmt.Errorf("failed to register run_tests tool: %w", err)
+		}
+		enabledTools = append(enabledTools, "run_tests")
+	}
+
+	// Register the git tool - enabled with "bash" config; the security
+	// validator refuses operations that can lose work unless allowed
+	if isToolEnabled(cfg, "bash", "git") {
+		if security, ok := dispatcher.GetSecurity().(*tools.DefaultSecurityValidator); ok {
+			security.SetAllowDestructiveGit(cfg.Tools.Git.AllowDestructive)
+		}
+		if err := dispatcher.Register(gittool.NewGitTool(cfg.WorkDir)); err != nil {
+			return fmt.Errorf("failed to register git tool: %w", err)
+		}
+		enabledTools = append(enabledTools, "git")
+	}
+
 	// Register file tools (read, write, list) - enabled with "file" config
 	if isToolEnabled(cfg, "file") {
 		readTool := file.NewReadTool(cfg.WorkDir, 10*1024*1024) // 10MB max
+		a.OnReset(readTool.Reset)
+		a.OnToolResultDropped(readTool.Forget)
 		if err := dispatcher.Register(readTool); err != nil {
 			return fmt.Errorf("failed to register read_file tool: %w", err)
 		}
@@ -214,27 +318,137 @@ func registerTools(a *agent.Agent, cfg *config.Config) error {
 		if err := dispatcher.Register(listTool); err != nil {
 			return fmt.Errorf("failed to register list_files tool: %w", err)
 		}
-		enabledTools = append(enabledTools, "read_file", "write_file", "list_files")
+
+		outlineTool := file.NewOutlineTool(cfg.WorkDir, 10*1024*1024) // 10MB max
+		if err := dispatcher.Register(outlineTool); err != nil {
+			return fmt.Errorf("failed to register outline tool: %w", err)
+		}
+
+		globTool := file.NewGlobTool(cfg.WorkDir, cfg.Tools.File.MaxListFiles)
+		globTool.SetExcludePatterns(cfg.Tools.Search.ExcludePatterns)
+		globTool.SetIncludeHidden(cfg.Tools.Search.IncludeHidden)
+		if err := dispatcher.Register(globTool); err != nil {
+			return fmt.Errorf("failed to register glob tool: %w", err)
+		}
+
+		treeTool := file.NewTreeTool(cfg.WorkDir, cfg.Tools.File.MaxListFiles)
+		treeTool.SetExcludePatterns(cfg.Tools.Search.ExcludePatterns)
+		treeTool.SetIncludeHidden(cfg.Tools.Search.IncludeHidden)
+		if err := dispatcher.Register(treeTool); err != nil {
+			return fmt.Errorf("failed to register tree tool: %w", err)
+		}
+		enabledTools = append(enabledTools, "read_file", "write_file", "list_files", "outline", "glob", "tree")
 	}
 
 	// Register edit tool
 	if isToolEnabled(cfg, "edit", "edit_file") {
+		backups := newBackupStore(cfg)
+		a.SetBackups(backups)
 		editTool := edit.NewEditTool(cfg.WorkDir)
+		editTool.SetBackupStore(backups)
 		if err := dispatcher.Register(editTool); err != nil {
 			return fmt.Errorf("failed to register edit_file tool: %w", err)
 		}
-		enabledTools = append(enabledTools, "edit_file")
+		multiEditTool := edit.NewMultiEditTool(cfg.WorkDir)
+		multiEditTool.SetBackupStore(backups)
+		if err := dispatcher.Register(multiEditTool); err != nil {
+			return fmt.Errorf("failed to register multi_edit tool: %w", err)
+		}
+		applyPatchTool := edit.NewApplyPatchTool(cfg.WorkDir)
+		applyPatchTool.SetBackupStore(backups)
+		if err := dispatcher.Register(applyPatchTool); err != nil {
+			return fmt.Errorf("failed to register apply_patch tool: %w", err)
+		}
+		enabledTools = append(enabledTools, "edit_file", "multi_edit", "apply_patch")
 	}
 
 	// Register search tool
 	if isToolEnabled(cfg, "search") {
 		searchTool := search.NewSearchTool(cfg.WorkDir, nil)
+		searchTool.SetExcludePatterns(cfg.Tools.Search.ExcludePatterns)
+		searchTool.SetIncludeHidden(cfg.Tools.Search.IncludeHidden)
 		if err := dispatcher.Register(searchTool); err != nil {
 			return fmt.Errorf("failed to register search tool: %w", err)
 		}
 		enabledTools = append(enabledTools, "search")
 	}
 
+	// Register semantic search; chunks are embedded on first use
+	if isToolEnabled(cfg, "semantic_search") {
+		embedder, err := llm.CreateEmbedder(llm.EmbedderConfig{
+			Provider:   cfg.Tools.Semantic.Provider,
+			APIKey:     cfg.Tools.Semantic.APIKey,
+			BaseURL:    cfg.Tools.Semantic.BaseURL,
+			Model:      cfg.Tools.Semantic.Model,
+			Dimensions: cfg.Tools.Semantic.Dimensions,
+			Timeout:    time.Duration(cfg.Model.Timeout) * time.Second,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create embedder for semantic_search: %w", err)
+		}
+		index := semantic.New(cfg.WorkDir, filepath.Join(cfg.WorkDir, ".goai", "index", "semantic.gob"), embedder)
+		index.SetExcludePatterns(cfg.Tools.Search.ExcludePatterns)
+		index.SetIncludeHidden(cfg.Tools.Search.IncludeHidden)
+		semanticTool := search.NewSemanticSearchTool(index, cfg.Tools.Semantic.MaxResults)
+		if err := dispatcher.Register(semanticTool); err != nil {
+			return fmt.Errorf("failed to register semantic_search tool: %w", err)
+		}
+		enabledTools = append(enabledTools, "semantic_search")
+	}
+
+	// Register web fetch tool; fetched pages are cached until the session resets
+	if isToolEnabled(cfg, "web_fetch") {
+		webFetchTool := web.NewWebFetchTool(int64(cfg.Tools.Web.MaxBytes), time.Duration(cfg.Tools.Web.TimeoutMs)*time.Millisecond)
+		webFetchTool.SetMaxRedirects(cfg.Tools.Web.MaxRedirects)
+		webFetchTool.SetDomains(web.DomainFilter{
+			Allowed: cfg.Tools.Web.AllowedDomains,
+			Blocked: cfg.Tools.Web.BlockedDomains,
+		})
+		webFetchTool.SetAllowPrivateNetworks(cfg.Tools.Web.AllowPrivateNetworks)
+		if err := dispatcher.Register(webFetchTool); err != nil {
+			return fmt.Errorf("failed to register web_fetch tool: %w", err)
+		}
+		a.OnReset(webFetchTool.Reset)
+		enabledTools = append(enabledTools, "web_fetch")
+	}
+
+	// Register web search tool when a search backend is configured
+	if cfg.Tools.Web.Search.Backend != "" {
+		backend, err := searchBackend(cfg)
+		if err != nil {
+			return err
+		}
+		webSearchTool := web.NewWebSearchTool(backend, cfg.Tools.Web.Search.MaxResults)
+		webSearchTool.SetDomains(web.DomainFilter{
+			Allowed: cfg.Tools.Web.AllowedDomains,
+			Blocked: cfg.Tools.Web.BlockedDomains,
+		})
+		if err := dispatcher.Register(webSearchTool); err != nil {
+			return fmt.Errorf("failed to register web_search tool: %w", err)
+		}
+		enabledTools = append(enabledTools, "web_search")
+	}
+
+	// Register code intelligence tool
+	if isToolEnabled(cfg, "code_intel") {
+		codeIntelTool := codeintel.NewCodeIntelTool(cfg.WorkDir)
+		if err := dispatcher.Register(codeIntelTool); err != nil {
+			return fmt.Errorf("failed to register code_intel tool: %w", err)
+		}
+		enabledTools = append(enabledTools, "code_intel")
+	}
+
+	// Register language server tools; servers start on first use
+	if isToolEnabled(cfg, "lsp") {
+		manager := lsp.NewManager(cfg.WorkDir, lspServers(cfg))
+		for _, tool := range lsptool.NewTools(manager, cfg.WorkDir) {
+			if err := dispatcher.Register(tool); err != nil {
+				return fmt.Errorf("failed to register %s tool: %w", tool.Name(), err)
+			}
+			enabledTools = append(enabledTools, tool.Name())
+		}
+	}
+
 	// Register todo tool
 	if isToolEnabled(cfg, "todo") {
 		todoMgr := todo.NewManager()
@@ -254,6 +468,151 @@ func registerTools(a *agent.Agent, cfg *config.Config) error {
 	return nil
 }
 
+// newBackupStore opens the backup store in the work directory with the
+// configured retention.
+func newBackupStore(cfg *config.Config) *backup.Store {
+	return backup.NewStore(cfg.WorkDir, backup.Retention{
+		MaxPerFile: cfg.Backups.MaxPerFile,
+		MaxAge:     time.Duration(cfg.Backups.RetentionDays) * 24 * time.Hour,
+	})
+}
+
+// runBackupCommand runs "goai backups" or "goai restore" without starting
+// a session and returns the exit code.
+func runBackupCommand(args []string) int {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading configuration: %v\n", err)
+		return 1
+	}
+	output, err := backup.Run(newBackupStore(cfg), args)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	fmt.Println(output)
+	return 0
+}
+
+// runHistoryCommand runs "goai log", "diff", "checkout" or "cherry-pick"
+// on the turns recorded in git and returns the exit code.
+func runHistoryCommand(args []string) int {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("Error loading configuration: %v\n", err)
+		return 1
+	}
+	output, err := shadow.RunCommand(context.Background(), shadow.Open(cfg.WorkDir), args)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	fmt.Println(output)
+	return 0
+}
+
+// recordTurns commits the files of every turn that changed files to the
+// recorder's session ref, with the todo list in the message.
+func recordTurns(a *agent.Agent, recorder *shadow.Recorder) {
+	var todos *todo.Manager
+	if tool, err := a.GetDispatcher().GetRegistry().Get("todo_write"); err == nil {
+		if todoTool, ok := tool.(*todotool.TodoTool); ok {
+			todos = todoTool.GetManager()
+		}
+	}
+
+	a.OnTurnEnd(func(cp *checkpoint.Checkpoint) {
+		turn := shadow.Turn{Prompt: cp.Prompt}
+		if todos != nil {
+			turn.Todos = todos.GetAll()
+		}
+		for _, change := range cp.Changes {
+			turn.Files = append(turn.Files, change.Path)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		// Best effort: the checkpoint still allows /undo if git fails
+		_, _ = recorder.Record(ctx, turn)
+	})
+}
+
+// newDiagnosticsPipeline builds the post-write diagnostics pipeline from the
+// configuration.
+func newDiagnosticsPipeline(cfg *config.Config) (*diagnostics.Pipeline, error) {
+	diagCfg := cfg.Tools.Diagnostics
+	pipeline := diagnostics.NewPipeline(cfg.WorkDir, time.Duration(diagCfg.TimeoutMs)*time.Millisecond)
+
+	for _, name := range diagCfg.Go {
+		checker, err := diagnostics.Builtin(name)
+		if err != nil {
+			return nil, err
+		}
+		pipeline.Add(checker, ".go")
+	}
+
+	for _, linter := range diagCfg.Linters {
+		pipeline.Add(&diagnostics.CommandChecker{
+			Label:    linter.Name,
+			Command:  linter.Command,
+			Args:     linter.Args,
+			Severity: linter.Severity,
+		}, linter.Extensions...)
+	}
+
+	return pipeline, nil
+}
+
+// lspServers converts the configured language servers, skipping entries
+// without a command so a default server can be disabled.
+func lspServers(cfg *config.Config) []lsp.ServerConfig {
+	var servers []lsp.ServerConfig
+	for language, server := range cfg.Tools.LSP.Servers {
+		if server.Command == "" {
+			continue
+		}
+		servers = append(servers, lsp.ServerConfig{
+			Language:              language,
+			Command:               server.Command,
+			Args:                  server.Args,
+			Env:                   server.Env,
+			Extensions:            server.Extensions,
+			InitializationOptions: server.InitializationOptions,
+		})
+	}
+	return servers
+}
+
+// searchBackend creates the configured backend of the web_search tool.
+func searchBackend(cfg *config.Config) (web.SearchBackend, error) {
+	search := cfg.Tools.Web.Search
+	timeout := time.Duration(cfg.Tools.Web.TimeoutMs) * time.Millisecond
+	switch search.Backend {
+	case "searxng":
+		return web.NewSearXNGBackend(search.Endpoint, search.Params, search.Headers, timeout)
+	case "json":
+		return web.NewJSONBackend(search.Endpoint, search.QueryParam, search.Params, search.Headers, timeout)
+	case "fixture":
+		path := search.Fixture
+		if path != "" && !filepath.IsAbs(path) {
+			path = filepath.Join(cfg.WorkDir, path)
+		}
+		return web.LoadFixtureBackend(path)
+	default:
+		return nil, fmt.Errorf("unknown web search backend %q (use searxng, json or fixture)", search.Backend)
+	}
+}
+
+// changedFiles returns the files changed in the session: those recorded by
+// checkpoints, or the git working tree changes when no turn changed files.
+func changedFiles(ctx context.Context, a *agent.Agent, workDir string) ([]string, error) {
+	if checkpoints := a.GetCheckpoints(); checkpoints != nil {
+		if files := checkpoints.Changed(); len(files) > 0 {
+			return files, nil
+		}
+	}
+	return affected.GitChanged(ctx, workDir)
+}
+
 // isToolEnabled checks if a tool is enabled in the configuration
 func isToolEnabled(cfg *config.Config, names ...string) bool {
 	for _, enabledTool := range cfg.Tools.Enabled {
@@ -302,6 +661,14 @@ func printCLIHelp() {
 	fmt.Printf("%s %s - Your intelligent programming assistant\n\n", AppName, Version)
 	fmt.Println("Usage:")
 	fmt.Println("  goai [OPTIONS]")
+	fmt.Println("  goai backups [path]           List the backups made by the edit tools")
+	fmt.Println("  goai restore <id> [target]    Restore a backup")
+	fmt.Println("  goai log [session]            List the turns recorded in git")
+	fmt.Println("  goai diff <turn>              Show the changes of a turn (n or session:n)")
+	fmt.Println("  goai checkout <turn> [paths]  Put the files of a turn back in the work tree")
+	fmt.Println("  goai cherry-pick <turn>       Commit the changes of a turn on the current branch")
+	fmt.Println("  goai attempt [-n N] [-verify CMD] <task>")
+	fmt.Println("                                Solve a task N times in separate git worktrees and apply one")
 	fmt.Println()
 	fmt.Println("Options:")
 	fmt.Println("  --help, -h        Show this help message")
@@ -368,6 +735,7 @@ func runTUI(_ context.Context, a *agent.Agent, cfg *config.Config) {
 	// Start the program
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Error starting TUI: %v\n", err)
+		_ = a.Close()
 		os.Exit(1)
 	}
 }
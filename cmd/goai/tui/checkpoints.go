@@ -0,0 +1,44 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/Zerofisher/goai/pkg/backup"
+	"github.com/Zerofisher/goai/pkg/checkpoint"
+)
+
+// handleCheckpointCommand runs /checkpoints, /undo, /rewind, /backups and
+// /restore and shows the result in the chat. It reports false for other
+// input.
+func (m *Model) handleCheckpointCommand(text string) bool {
+	output, ok := checkpoint.RunCommand(m.agent.GetCheckpoints(), text)
+	if !ok {
+		output, ok = backup.RunCommand(m.agent.GetBackups(), text)
+	}
+	if !ok {
+		return false
+	}
+	m.chatContent = appendToContent(m.chatContent, fmt.Sprintf("\n👤 You: %s\n%s\n", text, output))
+	m.chat.SetContent(m.chatContent)
+	m.chat.GotoBottom()
+	return true
+}
+
+// latestCheckpoint returns the newest checkpoint, nil if there is none or
+// checkpoints are disabled.
+func (m *Model) latestCheckpoint() *checkpoint.Checkpoint {
+	if m.agent.GetCheckpoints() == nil {
+		return nil
+	}
+	return m.agent.GetCheckpoints().Latest()
+}
+
+// checkpointNote summarizes the files changed by the turn that just
+// finished, or returns "" if it changed none.
+func (m *Model) checkpointNote() string {
+	cp := m.latestCheckpoint()
+	if cp == nil || cp == m.turnStartCheckpoint {
+		return ""
+	}
+	return fmt.Sprintf("📝 Checkpoint #%d: %s (/undo to revert)", cp.ID, cp.Summary())
+}
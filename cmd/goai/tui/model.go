@@ -2,6 +2,7 @@ package tui
 
 import (
 	"github.com/Zerofisher/goai/pkg/agent"
+	"github.com/Zerofisher/goai/pkg/checkpoint"
 	"github.com/Zerofisher/goai/pkg/config"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
@@ -40,6 +41,9 @@ type Model struct {
 
 	// Spinner state
 	spinnerLabel string // Current spinner label text
+
+	// Newest checkpoint when the current query started
+	turnStartCheckpoint *checkpoint.Checkpoint
 }
 
 // New creates a new TUI model
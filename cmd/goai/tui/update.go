@@ -70,6 +70,9 @@ func (m *Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if !m.state.querying && m.input.Value() != "" {
 			query := m.input.Value()
 			m.input.SetValue("")
+			if m.handleCheckpointCommand(query) {
+				return m, nil
+			}
 			return m, func() tea.Msg {
 				return QueryMsg{Text: query}
 			}
@@ -131,7 +134,11 @@ func (m *Model) handleToolEventMsg(msg ToolEventMsg) (tea.Model, tea.Cmd) {
 		if len(output) > 100 {
 			output = output[:100] + "..."
 		}
-		eventText = formatToolEvent("succeeded", e.Name, fmt.Sprintf("succeeded%s\n  %s", duration, output))
+		if code, ok := e.Metadata["exit_code"].(int); ok && code != 0 {
+			eventText = formatToolEvent("failed", e.Name, fmt.Sprintf("exited with code %d%s\n  %s", code, duration, output))
+		} else {
+			eventText = formatToolEvent("succeeded", e.Name, fmt.Sprintf("succeeded%s\n  %s", duration, output))
+		}
 		m.spinnerLabel = "Thinking..."
 
 	case types.ToolEventFailed:
@@ -164,6 +171,9 @@ func (m *Model) handleLLMDoneMsg(_ LLMDoneMsg) (tea.Model, tea.Cmd) {
 
 	// Add newline after completion
 	m.chatContent = appendToContent(m.chatContent, "")
+	if note := m.checkpointNote(); note != "" {
+		m.chatContent = appendToContent(m.chatContent, note)
+	}
 	m.chat.SetContent(m.chatContent)
 
 	return m, nil
@@ -193,6 +203,7 @@ func (m *Model) handleQueryMsg(msg QueryMsg) (tea.Model, tea.Cmd) {
 	// Set querying state
 	m.state.querying = true
 	m.spinnerLabel = "Thinking..."
+	m.turnStartCheckpoint = m.latestCheckpoint()
 
 	// Start query in background
 	return m, func() tea.Msg {
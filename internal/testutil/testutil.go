@@ -0,0 +1,87 @@
+// Package testutil provides helpers shared by the tests of several
+// packages: writing files and creating git repositories.
+package testutil
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// WriteFile writes content to dir/name, creating parent directories, and
+// returns the path.
+func WriteFile(t testing.TB, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// WriteFiles writes name/content pairs below dir.
+func WriteFiles(t testing.TB, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		WriteFile(t, dir, name, content)
+	}
+}
+
+// ReadFile returns the content of dir/name.
+func ReadFile(t testing.TB, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// InitGitRepo creates an empty repository on branch main. It isolates git
+// from the user's configuration and sets a fixed author for the rest of
+// the test, and skips the test when git is not installed.
+func InitGitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	Git(t, dir, "init", "-q", "-b", "main")
+	return dir
+}
+
+// NewGitRepo creates a repository like InitGitRepo whose first commit,
+// "Initial commit", holds files.
+func NewGitRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := InitGitRepo(t)
+	WriteFiles(t, dir, files)
+	Git(t, dir, "add", ".")
+	Git(t, dir, "commit", "-q", "--allow-empty", "-m", "Initial commit")
+	return dir
+}
+
+// Git runs git in dir and returns its output without surrounding
+// whitespace, failing the test if it fails.
+func Git(t testing.TB, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,212 @@
+// Package affected works out which Go packages need testing after a set of
+// files changed. A package is affected when one of its files changed or
+// when it, or one of its tests, imports an affected package; the reverse
+// dependency graph comes from "go list -deps -test".
+package affected
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// moduleFiles are files whose change affects every package.
+var moduleFiles = map[string]bool{
+	"go.mod":  true,
+	"go.sum":  true,
+	"go.work": true,
+}
+
+// listedPackage is the part of "go list -json" output used here.
+type listedPackage struct {
+	ImportPath   string
+	Dir          string
+	ForTest      string
+	Standard     bool
+	DepOnly      bool
+	TestGoFiles  []string
+	XTestGoFiles []string
+	Imports      []string
+}
+
+// Packages returns the import paths of the packages below workDir whose
+// tests are affected by changes to files (relative to workDir), sorted.
+// Packages without test files are left out. A change to go.mod, go.sum or
+// go.work affects every package.
+func Packages(ctx context.Context, workDir string, files []string) ([]string, error) {
+	listed, err := list(ctx, workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Packages matched by ./..., by directory
+	byDir := make(map[string]string)
+	var all []string
+	for _, pkg := range listed {
+		if pkg.Standard || pkg.DepOnly || pkg.ForTest != "" || variant(pkg.ImportPath) {
+			continue
+		}
+		byDir[filepath.Clean(pkg.Dir)] = pkg.ImportPath
+		if len(pkg.TestGoFiles)+len(pkg.XTestGoFiles) > 0 {
+			all = append(all, pkg.ImportPath)
+		}
+	}
+	sort.Strings(all)
+
+	// Importers of each package, with test variants folded into the
+	// package they test
+	importers := make(map[string][]string)
+	for _, pkg := range listed {
+		if pkg.Standard {
+			continue
+		}
+		owner := pkg.ForTest
+		if owner == "" {
+			owner = baseName(pkg.ImportPath)
+		}
+		for _, imp := range pkg.Imports {
+			imp = baseName(imp)
+			if imp != owner {
+				importers[imp] = append(importers[imp], owner)
+			}
+		}
+	}
+
+	var queue []string
+	for _, file := range files {
+		if moduleFiles[filepath.Base(file)] {
+			return all, nil
+		}
+		if pkg, ok := owningPackage(byDir, filepath.Join(workDir, file), workDir); ok {
+			queue = append(queue, pkg)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		if seen[pkg] {
+			continue
+		}
+		seen[pkg] = true
+		queue = append(queue, importers[pkg]...)
+	}
+
+	var affected []string
+	for _, pkg := range all {
+		if seen[pkg] {
+			affected = append(affected, pkg)
+		}
+	}
+	return affected, nil
+}
+
+// list runs "go list -deps -test" over ./... in workDir. Broken packages
+// are still listed (-e) so edits that do not compile are handled.
+func list(ctx context.Context, workDir string) ([]listedPackage, error) {
+	cmd := exec.CommandContext(ctx, "go", "list", "-e", "-deps", "-test",
+		"-json=ImportPath,Dir,ForTest,Standard,DepOnly,TestGoFiles,XTestGoFiles,Imports", "./...")
+	cmd.Dir = workDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("go list failed: %s", msg)
+		}
+		return nil, fmt.Errorf("go list failed: %w", err)
+	}
+
+	var packages []listedPackage
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var pkg listedPackage
+		if err := dec.Decode(&pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse go list output: %w", err)
+		}
+		packages = append(packages, pkg)
+	}
+	return packages, nil
+}
+
+// owningPackage returns the package whose directory holds path, walking up
+// so that testdata and other nested non-Go files count for their package.
+func owningPackage(byDir map[string]string, path, workDir string) (string, bool) {
+	root := filepath.Clean(workDir)
+	for dir := filepath.Dir(filepath.Clean(path)); ; dir = filepath.Dir(dir) {
+		if pkg, ok := byDir[dir]; ok {
+			return pkg, true
+		}
+		if dir == root || dir == filepath.Dir(dir) {
+			return "", false
+		}
+	}
+}
+
+// baseName strips the " [pkg.test]" suffix of a test variant.
+func baseName(importPath string) string {
+	name, _, _ := strings.Cut(importPath, " ")
+	return name
+}
+
+// variant reports whether importPath names a test variant or test main.
+func variant(importPath string) bool {
+	return strings.Contains(importPath, " ") || strings.HasSuffix(importPath, ".test")
+}
+
+// GitChanged returns the files in workDir that differ from HEAD, including
+// untracked files that are not ignored, relative to workDir.
+func GitChanged(ctx context.Context, workDir string) ([]string, error) {
+	changed, err := git(ctx, workDir, "diff", "--name-only", "--relative", "-z", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git(ctx, workDir, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var files []string
+	for _, file := range append(changed, untracked...) {
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// git runs a git command with NUL-separated output in workDir and returns
+// the non-empty entries.
+func git(ctx context.Context, workDir string, args ...string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = workDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s failed: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+
+	var entries []string
+	for _, entry := range strings.Split(string(out), "\x00") {
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
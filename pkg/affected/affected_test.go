@@ -0,0 +1,77 @@
+package affected
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"github.com/Zerofisher/goai/internal/testutil"
+)
+
+// TestPackages tests reverse-dependency selection of tested packages
+func TestPackages(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	dir := t.TempDir()
+	testutil.WriteFile(t, dir, "go.mod", "module example.com/mod\n\ngo 1.21\n")
+	testutil.WriteFile(t, dir, "README.md", "# mod\n")
+	testutil.WriteFile(t, dir, "a/a.go", "package a\n\nfunc A() int { return 1 }\n")
+	testutil.WriteFile(t, dir, "a/a_test.go", "package a\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) {}\n")
+	// b imports a
+	testutil.WriteFile(t, dir, "b/b.go", "package b\n\nimport \"example.com/mod/a\"\n\nfunc B() int { return a.A() }\n")
+	testutil.WriteFile(t, dir, "b/b_test.go", "package b\n\nimport \"testing\"\n\nfunc TestB(t *testing.T) {}\n")
+	// c is independent and reads testdata
+	testutil.WriteFile(t, dir, "c/c.go", "package c\n")
+	testutil.WriteFile(t, dir, "c/c_test.go", "package c\n\nimport \"testing\"\n\nfunc TestC(t *testing.T) {}\n")
+	testutil.WriteFile(t, dir, "c/testdata/input.txt", "data\n")
+	// Only d's external test imports a
+	testutil.WriteFile(t, dir, "d/d.go", "package d\n")
+	testutil.WriteFile(t, dir, "d/d_test.go", "package d_test\n\nimport (\n\t\"testing\"\n\n\t\"example.com/mod/a\"\n)\n\nfunc TestD(t *testing.T) { a.A() }\n")
+	// e has no tests but f, which imports it, does
+	testutil.WriteFile(t, dir, "e/e.go", "package e\n\nimport \"example.com/mod/a\"\n\nvar E = a.A()\n")
+	testutil.WriteFile(t, dir, "f/f.go", "package f\n\nimport \"example.com/mod/e\"\n\nvar F = e.E\n")
+	testutil.WriteFile(t, dir, "f/f_test.go", "package f\n\nimport \"testing\"\n\nfunc TestF(t *testing.T) {}\n")
+
+	tests := []struct {
+		name  string
+		files []string
+		want  []string
+	}{
+		{"dependency", []string{"a/a.go"}, []string{"example.com/mod/a", "example.com/mod/b", "example.com/mod/d", "example.com/mod/f"}},
+		{"leaf", []string{"b/b_test.go"}, []string{"example.com/mod/b"}},
+		{"testdata", []string{"c/testdata/input.txt"}, []string{"example.com/mod/c"}},
+		{"untested package", []string{"e/e.go"}, []string{"example.com/mod/f"}},
+		{"outside packages", []string{"README.md", "docs/guide.md"}, nil},
+		{"module file", []string{"go.mod"}, []string{"example.com/mod/a", "example.com/mod/b", "example.com/mod/c", "example.com/mod/d", "example.com/mod/f"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Packages(context.Background(), dir, tt.files)
+			if err != nil {
+				t.Fatalf("Packages failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Packages(%v) = %v, want %v", tt.files, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestGitChanged tests listing modified and untracked files
+func TestGitChanged(t *testing.T) {
+	dir := testutil.NewGitRepo(t, map[string]string{"a.go": "package a\n", "b.go": "package a\n", ".gitignore": "*.log\n"})
+
+	testutil.WriteFile(t, dir, "a.go", "package a\n\nvar A = 1\n")
+	testutil.WriteFile(t, dir, "new dir/c.go", "package c\n")
+	testutil.WriteFile(t, dir, "debug.log", "ignored\n")
+
+	got, err := GitChanged(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("GitChanged failed: %v", err)
+	}
+	if want := []string{"a.go", "new dir/c.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GitChanged() = %q, want %q", got, want)
+	}
+}
@@ -6,11 +6,14 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Zerofisher/goai/pkg/backup"
+	"github.com/Zerofisher/goai/pkg/checkpoint"
 	"github.com/Zerofisher/goai/pkg/config"
 	"github.com/Zerofisher/goai/pkg/dispatcher"
 	"github.com/Zerofisher/goai/pkg/llm"
 	"github.com/Zerofisher/goai/pkg/message"
 	"github.com/Zerofisher/goai/pkg/prompt"
+	"github.com/Zerofisher/goai/pkg/repomap"
 	"github.com/Zerofisher/goai/pkg/types"
 )
 
@@ -23,6 +26,11 @@ type Agent struct {
 	state         *State
 	context       *Context
 	promptManager *prompt.Manager
+	checkpoints   *checkpoint.Manager
+	backups       *backup.Store
+	resetHooks    []func()
+	turnHooks     []func(cp *checkpoint.Checkpoint)
+	dropHooks     []func(toolUseID string)
 	mu            sync.RWMutex
 }
 
@@ -61,15 +69,18 @@ func NewAgent(cfg *config.Config) (*Agent, error) {
 	promptMgr := prompt.NewManager(cfg, agentContext)
 
 	// Create agent
+	state := NewState()
+	toolDispatcher.SetSessionID(state.GetSessionID())
 	agent := &Agent{
 		client:        client,
 		messages:      messageManager,
 		dispatcher:    toolDispatcher,
 		config:        cfg,
-		state:         NewState(),
+		state:         state,
 		context:       agentContext,
 		promptManager: promptMgr,
 	}
+	messageManager.OnDrop(agent.messageDropped)
 
 	// Set up dynamic tool list provider for prompt manager
 	promptMgr.SetToolListProvider(func() []string {
@@ -81,6 +92,19 @@ func NewAgent(cfg *config.Config) (*Agent, error) {
 		return toolNames
 	})
 
+	// Offer the ranked repository map to prompt templates
+	if cfg.RepoMap.Enabled {
+		repoMap := repomap.New(cfg.WorkDir, repomap.Options{
+			MaxTokens: cfg.RepoMap.MaxTokens,
+			MaxFiles:  cfg.RepoMap.MaxFiles,
+			Excludes:  cfg.Tools.Search.ExcludePatterns,
+		})
+		promptMgr.SetRepoMapProvider(func() string {
+			text, _ := repoMap.Render(context.Background())
+			return text
+		})
+	}
+
 	// Initialize system prompt
 	if err := agent.initializeSystemPrompt(); err != nil {
 		return nil, fmt.Errorf("failed to initialize system prompt: %w", err)
@@ -99,6 +123,12 @@ func (a *Agent) Query(ctx context.Context, input string) (string, error) {
 	a.state.SetProcessing(true)
 	defer a.state.SetProcessing(false)
 
+	// Record the files this turn changes
+	if a.checkpoints != nil {
+		a.checkpoints.Begin(input)
+		defer a.endTurn()
+	}
+
 	// Add user message
 	if err := a.messages.Add(types.NewTextMessage("user", input)); err != nil {
 		return "", fmt.Errorf("failed to add user message: %w", err)
@@ -192,6 +222,12 @@ func (a *Agent) StreamQuery(ctx context.Context, input string, outputChan chan<-
 	a.state.SetProcessing(true)
 	defer a.state.SetProcessing(false)
 
+	// Record the files this turn changes
+	if a.checkpoints != nil {
+		a.checkpoints.Begin(input)
+		defer a.endTurn()
+	}
+
 	// Add user message
 	if err := a.messages.Add(types.NewTextMessage("user", input)); err != nil {
 		return fmt.Errorf("failed to add user message: %w", err)
@@ -264,6 +300,38 @@ func (a *Agent) Reset() {
 
 	a.messages.ClearExceptSystem()
 	a.state.Reset()
+	for _, hook := range a.resetHooks {
+		hook()
+	}
+}
+
+// OnToolResultDropped registers fn to run with the tool use ID of every tool
+// result removed from the history to stay within the token limit.
+func (a *Agent) OnToolResultDropped(fn func(toolUseID string)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.dropHooks = append(a.dropHooks, fn)
+}
+
+// messageDropped runs the drop hooks for the tool results in msg. It is
+// called from the message manager while a.mu is held.
+func (a *Agent) messageDropped(msg types.Message) {
+	for _, c := range msg.Content {
+		if c.ToolResult == nil {
+			continue
+		}
+		for _, hook := range a.dropHooks {
+			hook(c.ToolResult.ToolUseID)
+		}
+	}
+}
+
+// OnReset registers fn to run when the conversation is reset, for state
+// that describes what the model has seen.
+func (a *Agent) OnReset(fn func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.resetHooks = append(a.resetHooks, fn)
 }
 
 // GetStats returns agent statistics
@@ -282,6 +350,65 @@ func (a *Agent) GetStats() Stats {
 	}
 }
 
+// Close shuts down resources owned by the session's tools. It should be
+// called once the agent is no longer used.
+func (a *Agent) Close() error {
+	return a.dispatcher.Close()
+}
+
+// SetCheckpoints enables per-turn checkpoints. Every query then records
+// the files it changes in m.
+func (a *Agent) SetCheckpoints(m *checkpoint.Manager) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.checkpoints = m
+}
+
+// OnTurnEnd registers fn to run after each turn that changed files, with
+// the turn's checkpoint. It runs while the agent is locked, so fn must not
+// call back into the agent.
+func (a *Agent) OnTurnEnd(fn func(cp *checkpoint.Checkpoint)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.turnHooks = append(a.turnHooks, fn)
+}
+
+// endTurn finishes the current checkpoint and runs the turn hooks if the
+// turn changed files. It is called with a.mu held.
+func (a *Agent) endTurn() {
+	cp := a.checkpoints.End()
+	if cp == nil {
+		return
+	}
+	for _, hook := range a.turnHooks {
+		hook(cp)
+	}
+}
+
+// GetCheckpoints returns the checkpoint manager, or nil if checkpoints are
+// disabled.
+func (a *Agent) GetCheckpoints() *checkpoint.Manager {
+	return a.checkpoints
+}
+
+// SetBackups sets the store the edit tools save backups to, so the
+// interactive /backups and /restore commands can reach it.
+func (a *Agent) SetBackups(s *backup.Store) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.backups = s
+}
+
+// GetBackups returns the backup store, or nil if none was set.
+func (a *Agent) GetBackups() *backup.Store {
+	return a.backups
+}
+
+// GetSessionID returns the ID of the agent's session.
+func (a *Agent) GetSessionID() string {
+	return a.state.GetSessionID()
+}
+
 // GetConfig returns the agent configuration
 func (a *Agent) GetConfig() *config.Config {
 	return a.config
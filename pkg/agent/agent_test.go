@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/Zerofisher/goai/internal/testutil"
 	"github.com/Zerofisher/goai/pkg/config"
 	"github.com/Zerofisher/goai/pkg/llm"
 	"github.com/Zerofisher/goai/pkg/types"
@@ -404,6 +406,34 @@ func TestContext_ProjectAnalysis(t *testing.T) {
 	}
 }
 
+// TestContext_GitBranch tests that the branch is read from the repository
+// once branchTTL has passed
+func TestContext_GitBranch(t *testing.T) {
+	tempDir := testutil.NewGitRepo(t, nil)
+
+	ctx := NewContext(tempDir)
+	if !ctx.ProjectHasGit() || ctx.GetProjectGitBranch() != "main" {
+		t.Fatalf("branch = %q (has git %v), want main", ctx.GetProjectGitBranch(), ctx.ProjectHasGit())
+	}
+
+	testutil.Git(t, tempDir, "switch", "-q", "-c", "feature")
+	if branch := ctx.GetProjectGitBranch(); branch != "main" {
+		t.Errorf("branch within the TTL = %q, want the cached main", branch)
+	}
+
+	ctx.branchTTL = 0
+	info := ctx.GetProjectInfo()
+	if info.GitBranch != "main" || info.GetGitBranch() != "feature" {
+		t.Errorf("GitBranch = %q, GetGitBranch() = %q, want main and feature", info.GitBranch, info.GetGitBranch())
+	}
+	if branch := ctx.GetProjectGitBranch(); branch != "feature" {
+		t.Errorf("branch after switch = %q, want feature", branch)
+	}
+	if !strings.Contains(ctx.GetSystemPrompt(), "Git Branch: feature") {
+		t.Error("system prompt should show the current branch")
+	}
+}
+
 func TestBuilder(t *testing.T) {
 	// Register mock client factory
 	llm.RegisterClientFactory("mock", func(config llm.ClientConfig) (llm.Client, error) {
@@ -1,20 +1,36 @@
 package agent
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/Zerofisher/goai/pkg/git"
 )
 
+// gitTimeout bounds the git commands that read the current branch.
+const gitTimeout = 2 * time.Second
+
+// branchTTL is how long the branch read from git is reused, so building
+// prompts does not start git every time.
+const branchTTL = 5 * time.Second
+
 // Context manages the agent's operating context
 type Context struct {
-	workDir      string
-	systemPrompt string
-	projectInfo  *ProjectInfo
-	startTime    time.Time
+	workDir     string
+	repo        *git.Repo
+	projectInfo *ProjectInfo
+	startTime   time.Time
+
+	branchMu   sync.Mutex
+	branch     string
+	branchRead time.Time
+	branchTTL  time.Duration
 }
 
 // ProjectInfo contains information about the current project
@@ -23,10 +39,15 @@ type ProjectInfo struct {
 	Path        string
 	Language    string
 	HasGit_     bool
+	// GitBranch is the branch when the project was analyzed.
+	//
+	// Deprecated: Use GetGitBranch, which follows branch switches.
 	GitBranch   string
 	FileCount   int
 	TotalSize   int64
 	LastModified time.Time
+
+	branch func() string // reads the current branch, nil without git
 }
 
 // GetName returns the project name.
@@ -44,8 +65,11 @@ func (p *ProjectInfo) HasGit() bool {
 	return p.HasGit_
 }
 
-// GetGitBranch returns the current git branch.
+// GetGitBranch returns the current git branch, or "detached at <commit>".
 func (p *ProjectInfo) GetGitBranch() string {
+	if p.branch != nil {
+		return p.branch()
+	}
 	return p.GitBranch
 }
 
@@ -63,21 +87,21 @@ func NewContext(workDir string) *Context {
 
 	ctx := &Context{
 		workDir:   workDir,
+		repo:      git.New(workDir),
 		startTime: time.Now(),
+		branchTTL: branchTTL,
 	}
 
 	// Initialize project info
 	ctx.projectInfo = ctx.analyzeProject()
 
-	// Generate system prompt
-	ctx.systemPrompt = ctx.generateSystemPrompt()
-
 	return ctx
 }
 
-// GetSystemPrompt returns the system prompt for the LLM
+// GetSystemPrompt returns the system prompt for the LLM. It is generated
+// on each call so the git branch is current within branchTTL.
 func (c *Context) GetSystemPrompt() string {
-	return c.systemPrompt
+	return c.generateSystemPrompt()
 }
 
 // GetWorkDir returns the working directory
@@ -109,12 +133,13 @@ func (c *Context) ProjectHasGit() bool {
 	return false
 }
 
-// GetProjectGitBranch returns the current git branch.
+// GetProjectGitBranch returns the branch checked out now, or
+// "detached at <commit>".
 func (c *Context) GetProjectGitBranch() string {
-	if c.projectInfo != nil {
-		return c.projectInfo.GitBranch
+	if !c.ProjectHasGit() {
+		return ""
 	}
-	return ""
+	return c.gitBranch()
 }
 
 // GetProjectInfo returns project information
@@ -148,7 +173,7 @@ func (c *Context) generateSystemPrompt() string {
 		parts = append(parts, fmt.Sprintf("- Project Name: %s", c.projectInfo.Name))
 		parts = append(parts, fmt.Sprintf("- Language: %s", c.projectInfo.Language))
 		if c.projectInfo.HasGit_ {
-			parts = append(parts, fmt.Sprintf("- Git Branch: %s", c.projectInfo.GitBranch))
+			parts = append(parts, fmt.Sprintf("- Git Branch: %s", c.gitBranch()))
 		}
 		parts = append(parts, fmt.Sprintf("- Files: %d", c.projectInfo.FileCount))
 	}
@@ -185,11 +210,13 @@ func (c *Context) analyzeProject() *ProjectInfo {
 		Path: c.workDir,
 	}
 
-	// Check for Git
-	gitDir := filepath.Join(c.workDir, ".git")
-	if stat, err := os.Stat(gitDir); err == nil && stat.IsDir() {
-		info.HasGit_ = true
-		info.GitBranch = c.getGitBranch()
+	// Check for Git; linked worktrees and submodules have a .git file
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+	info.HasGit_ = c.repo.IsRepo(ctx)
+	if info.HasGit_ {
+		info.branch = c.gitBranch
+		info.GitBranch = c.gitBranch()
 	}
 
 	// Detect primary language
@@ -201,20 +228,24 @@ func (c *Context) analyzeProject() *ProjectInfo {
 	return info
 }
 
-// getGitBranch gets the current git branch
-func (c *Context) getGitBranch() string {
-	headFile := filepath.Join(c.workDir, ".git", "HEAD")
-	data, err := os.ReadFile(headFile)
-	if err != nil {
-		return "unknown"
+// gitBranch reads the current branch from the repository, so branch
+// switches made during the session are reflected. A branch read less than
+// branchTTL ago is reused.
+func (c *Context) gitBranch() string {
+	c.branchMu.Lock()
+	defer c.branchMu.Unlock()
+	if c.branch != "" && time.Since(c.branchRead) < c.branchTTL {
+		return c.branch
 	}
 
-	content := strings.TrimSpace(string(data))
-	if strings.HasPrefix(content, "ref: refs/heads/") {
-		return strings.TrimPrefix(content, "ref: refs/heads/")
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+	c.branch = "unknown"
+	if head, err := c.repo.Head(ctx); err == nil {
+		c.branch = head.String()
 	}
-
-	return "detached"
+	c.branchRead = time.Now()
+	return c.branch
 }
 
 // detectLanguage detects the primary programming language
@@ -319,7 +350,7 @@ func (c *Context) GetContextSummary() string {
 	if c.projectInfo != nil {
 		parts = append(parts, fmt.Sprintf("Project: %s (%s)", c.projectInfo.Name, c.projectInfo.Language))
 		if c.projectInfo.HasGit_ {
-			parts = append(parts, fmt.Sprintf("Git Branch: %s", c.projectInfo.GitBranch))
+			parts = append(parts, fmt.Sprintf("Git Branch: %s", c.gitBranch()))
 		}
 		parts = append(parts, fmt.Sprintf("Files: %d (%.2f MB)",
 			c.projectInfo.FileCount,
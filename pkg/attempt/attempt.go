@@ -0,0 +1,279 @@
+// Package attempt runs several agents on the same task at once, each in its
+// own linked git worktree, verifies what every one of them produced and
+// applies the result the user picks to the main work tree.
+//
+// Every worktree starts from a snapshot of the tracked files with their
+// local changes, so the attempts see the same code the user does; untracked
+// files are not copied. An attempt's changes are its diff against that
+// snapshot, which applies cleanly to the main work tree as long as the user
+// has not changed the same files in the meantime.
+package attempt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zerofisher/goai/pkg/git"
+)
+
+const (
+	// maxPatchBytes bounds the patch kept per attempt; larger changes can
+	// be compared but not applied.
+	maxPatchBytes = 16 << 20
+
+	// maxOutputLines is the tail of the verification output kept.
+	maxOutputLines = 40
+)
+
+// Agent is the part of agent.Agent an attempt uses.
+type Agent interface {
+	Query(ctx context.Context, input string) (string, error)
+	Close() error
+}
+
+// NewAgentFunc creates the agent of one attempt, working in dir.
+type NewAgentFunc func(dir string) (Agent, error)
+
+// Options controls Run.
+type Options struct {
+	Count         int           // number of attempts
+	Verify        string        // shell command run in every worktree after its agent; none when empty
+	VerifyTimeout time.Duration // time limit of Verify; none when zero
+	OnDone        func(*Result) // called as each attempt finishes, from its goroutine
+}
+
+// Result is the outcome of one attempt.
+type Result struct {
+	N        int           // 1-based attempt number
+	Dir      string        // directory the agent worked in
+	Response string        // the agent's final answer
+	Err      error         // why the agent or collecting its changes failed
+	Duration time.Duration // time the agent took
+	Diff     *git.Diff     // changes against the snapshot
+	Verify   *Verification // nil when no command is configured or the agent failed
+}
+
+// Verification is the result of the verification command.
+type Verification struct {
+	Command  string
+	ExitCode int // -1 when the command could not run or timed out
+	TimedOut bool
+	Output   string // last lines of the combined output
+	Duration time.Duration
+}
+
+// Passed reports whether the command exited with status 0.
+func (v *Verification) Passed() bool {
+	return v.ExitCode == 0 && !v.TimedOut
+}
+
+// Changed reports whether the attempt left changes to apply.
+func (r *Result) Changed() bool {
+	return r.Diff != nil && len(r.Diff.Files) > 0
+}
+
+// Batch is a finished set of attempts whose worktrees still exist until
+// Cleanup.
+type Batch struct {
+	Task    string
+	Base    string // snapshot commit the worktrees started from
+	Results []*Result
+
+	repo      *git.Repo // main work tree, at the repository root
+	dir       string    // temporary directory holding the worktrees
+	worktrees []string
+}
+
+// Run creates opts.Count worktrees of the repository containing workDir,
+// runs a new agent on task in each at once, and collects and verifies
+// their changes. Call Cleanup on the returned batch to remove the
+// worktrees.
+func Run(ctx context.Context, workDir, task string, newAgent NewAgentFunc, opts Options) (*Batch, error) {
+	if opts.Count < 1 {
+		return nil, fmt.Errorf("the number of attempts must be at least 1")
+	}
+	repo := git.New(workDir)
+	root, err := repo.Root(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := relativeDir(root, workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Batch{Task: task, repo: git.New(root)}
+	if b.Base, err = b.repo.Snapshot(ctx); err != nil {
+		return nil, err
+	}
+	if b.Base == "" {
+		return nil, fmt.Errorf("the repository has no commits to start from")
+	}
+	if b.dir, err = os.MkdirTemp("", "goai-attempt-"); err != nil {
+		return nil, fmt.Errorf("failed to create the worktree directory: %w", err)
+	}
+
+	for n := 1; n <= opts.Count; n++ {
+		path := filepath.Join(b.dir, strconv.Itoa(n))
+		if err := b.repo.AddWorktree(ctx, path, b.Base); err != nil {
+			b.Cleanup(context.WithoutCancel(ctx))
+			return nil, fmt.Errorf("failed to create worktree %d: %w", n, err)
+		}
+		b.worktrees = append(b.worktrees, path)
+		b.Results = append(b.Results, &Result{N: n, Dir: filepath.Join(path, rel)})
+	}
+
+	var wg sync.WaitGroup
+	for i, result := range b.Results {
+		wg.Add(1)
+		go func(worktree string, result *Result) {
+			defer wg.Done()
+			b.run(ctx, worktree, result, newAgent, opts)
+			if opts.OnDone != nil {
+				opts.OnDone(result)
+			}
+		}(b.worktrees[i], result)
+	}
+	wg.Wait()
+	return b, nil
+}
+
+// run runs one attempt and fills in result.
+func (b *Batch) run(ctx context.Context, worktree string, result *Result, newAgent NewAgentFunc, opts Options) {
+	start := time.Now()
+	agent, err := newAgent(result.Dir)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to create agent: %w", err)
+		return
+	}
+	result.Response, result.Err = agent.Query(ctx, b.Task)
+	_ = agent.Close()
+	result.Duration = time.Since(start)
+
+	// Collect the changes before verification adds build output
+	wt := git.New(worktree)
+	if err := wt.Add(ctx, "."); err != nil {
+		result.Err = errors.Join(result.Err, fmt.Errorf("failed to collect changes: %w", err))
+		return
+	}
+	diff, err := wt.Diff(ctx, git.DiffOptions{Staged: true, Range: b.Base, Binary: true, MaxPatchBytes: maxPatchBytes})
+	if err != nil {
+		result.Err = errors.Join(result.Err, fmt.Errorf("failed to collect changes: %w", err))
+		return
+	}
+	result.Diff = diff
+
+	if result.Err == nil && opts.Verify != "" {
+		result.Verify = verify(ctx, result.Dir, opts.Verify, opts.VerifyTimeout)
+	}
+}
+
+// verify runs command with bash in dir.
+func verify(ctx context.Context, dir, command string, timeout time.Duration) *Verification {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	v := &Verification{Command: command}
+	start := time.Now()
+	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	cmd.Dir = dir
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	v.Duration = time.Since(start)
+	v.Output = tail(out.String(), maxOutputLines)
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		v.ExitCode, v.TimedOut = -1, true
+	case errors.As(err, &exitErr):
+		v.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		v.ExitCode = -1
+		v.Output = strings.TrimSpace(v.Output + "\n" + err.Error())
+	}
+	return v
+}
+
+// Get returns attempt n.
+func (b *Batch) Get(n int) (*Result, error) {
+	if n < 1 || n > len(b.Results) {
+		return nil, fmt.Errorf("no attempt #%d; choose 1 to %d", n, len(b.Results))
+	}
+	return b.Results[n-1], nil
+}
+
+// Apply applies the changes of attempt n to the main work tree without
+// staging them. Nothing is changed when they do not apply cleanly.
+func (b *Batch) Apply(ctx context.Context, n int) (*Result, error) {
+	result, err := b.Get(n)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Changed() {
+		return nil, fmt.Errorf("attempt #%d changed nothing", n)
+	}
+	if result.Diff.Truncated {
+		return nil, fmt.Errorf("the changes of attempt #%d are too large to apply", n)
+	}
+	if err := b.repo.Apply(ctx, result.Diff.Patch); err != nil {
+		return nil, fmt.Errorf("the changes of attempt #%d do not apply to the work tree: %w", n, err)
+	}
+	return result, nil
+}
+
+// Cleanup removes the worktrees and their directory. It keeps going after
+// an error and returns the first one.
+func (b *Batch) Cleanup(ctx context.Context) error {
+	var first error
+	for _, path := range b.worktrees {
+		if err := b.repo.RemoveWorktree(ctx, path); err != nil && first == nil {
+			first = err
+		}
+	}
+	b.worktrees = nil
+	if b.dir != "" {
+		if err := os.RemoveAll(b.dir); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// relativeDir returns dir relative to root, which contains it.
+func relativeDir(root, dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("%s is not inside the repository at %s", dir, root)
+	}
+	return rel, nil
+}
+
+// tail returns the last n lines of s.
+func tail(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = append([]string{fmt.Sprintf("... (%d lines omitted)", len(lines)-n)}, lines[len(lines)-n:]...)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,147 @@
+package attempt
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Zerofisher/goai/internal/testutil"
+	"github.com/Zerofisher/goai/pkg/git"
+)
+
+// newTestRepo creates a repository with one commit of a.txt and b.txt.
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	return testutil.NewGitRepo(t, map[string]string{"a.txt": "bug\n", "b.txt": "one\n"})
+}
+
+// fakeAgent runs query in its directory.
+type fakeAgent struct {
+	dir    string
+	query  func(dir string) (string, error)
+	closed bool
+}
+
+func (a *fakeAgent) Query(ctx context.Context, input string) (string, error) {
+	return a.query(a.dir)
+}
+
+func (a *fakeAgent) Close() error {
+	a.closed = true
+	return nil
+}
+
+// TestRun tests running, comparing, applying and cleaning up attempts
+func TestRun(t *testing.T) {
+	dir := newTestRepo(t)
+	ctx := context.Background()
+
+	// Uncommitted changes to tracked files are part of the starting point
+	testutil.WriteFile(t, dir, "b.txt", "two\n")
+
+	var mu sync.Mutex
+	var agents []*fakeAgent
+	newAgent := func(agentDir string) (Agent, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		a := &fakeAgent{dir: agentDir, query: func(dir string) (string, error) {
+			if got := testutil.ReadFile(t, dir, "b.txt"); got != "two\n" {
+				t.Errorf("b.txt in worktree = %q, want the uncommitted content", got)
+			}
+			switch filepath.Base(dir) {
+			case "1", "2":
+				testutil.WriteFile(t, dir, "a.txt", "fixed\n")
+				return "Fixed it", nil
+			}
+			testutil.WriteFile(t, dir, "a.txt", "still a bug\n")
+			testutil.WriteFile(t, dir, "c.txt", "new\n")
+			return "", errors.New("ran out of rounds")
+		}}
+		agents = append(agents, a)
+		return a, nil
+	}
+
+	var done int
+	b, err := Run(ctx, dir, "Fix the bug", newAgent, Options{
+		Count:  3,
+		Verify: "grep -q fixed a.txt",
+		OnDone: func(*Result) { mu.Lock(); done++; mu.Unlock() },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Cleanup(ctx)
+
+	if done != 3 || len(agents) != 3 {
+		t.Fatalf("done = %d, agents = %d, want 3", done, len(agents))
+	}
+	for _, a := range agents {
+		if !a.closed {
+			t.Errorf("agent in %s was not closed", a.dir)
+		}
+	}
+
+	r1, _ := b.Get(1)
+	if r1.Err != nil || r1.Response != "Fixed it" || r1.Verify == nil || !r1.Verify.Passed() {
+		t.Errorf("attempt 1 = %+v, verify %+v", r1, r1.Verify)
+	}
+	if !r1.Changed() || len(r1.Diff.Files) != 1 || r1.Diff.Files[0].Path != "a.txt" {
+		t.Errorf("attempt 1 diff = %+v", r1.Diff)
+	}
+	r3, _ := b.Get(3)
+	if r3.Err == nil || r3.Verify != nil || len(r3.Diff.Files) != 2 {
+		t.Errorf("attempt 3 = %+v", r3)
+	}
+	if _, err := b.Get(4); err == nil {
+		t.Error("expected an error for attempt 4")
+	}
+
+	report := b.Compare()
+	for _, want := range []string{"#1", "passed in", "error: ran out of rounds", "2 files, +2 -1", "c.txt", "Attempts #1, #2 made the same changes."} {
+		if !strings.Contains(report, want) {
+			t.Errorf("Compare() missing %q:\n%s", want, report)
+		}
+	}
+	if detail := r1.Detail(); !strings.Contains(detail, "+fixed") || !strings.Contains(detail, "Fixed it") {
+		t.Errorf("Detail() = %s", detail)
+	}
+
+	if _, err := b.Apply(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ReadFile(t, dir, "c.txt"); got != "new\n" {
+		t.Errorf("c.txt after apply = %q", got)
+	}
+	if got := testutil.ReadFile(t, dir, "b.txt"); got != "two\n" {
+		t.Errorf("b.txt after apply = %q, want the local change kept", got)
+	}
+	// Attempt 1 changed a.txt from the same starting point, which no longer holds
+	if _, err := b.Apply(ctx, 1); err == nil {
+		t.Error("expected a conflicting apply to fail")
+	}
+
+	if err := b.Cleanup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	worktrees, err := git.New(dir).Worktrees(ctx)
+	if err != nil || len(worktrees) != 1 {
+		t.Errorf("worktrees after cleanup = %v, %v", worktrees, err)
+	}
+	if _, err := os.Stat(r1.Dir); !os.IsNotExist(err) {
+		t.Errorf("worktree directory still exists: %v", err)
+	}
+}
+
+// TestRunWithoutCommits tests that attempts need a commit to start from
+func TestRunWithoutCommits(t *testing.T) {
+	dir := testutil.InitGitRepo(t)
+
+	newAgent := func(string) (Agent, error) { return nil, errors.New("unused") }
+	if _, err := Run(context.Background(), dir, "task", newAgent, Options{Count: 2}); err == nil {
+		t.Error("expected an error in a repository without commits")
+	}
+}
@@ -0,0 +1,191 @@
+package attempt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Summary returns a one-line description of the result.
+func (r *Result) Summary() string {
+	parts := []string{fmt.Sprintf("#%d", r.N)}
+	if r.Err != nil {
+		parts = append(parts, "error: "+firstLine(r.Err.Error()))
+	} else {
+		parts = append(parts, "done in "+round(r.Duration))
+	}
+	parts = append(parts, r.changes())
+	if r.Verify != nil {
+		parts = append(parts, "verify "+r.Verify.status())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Compare returns a table of the attempts, the files each one changed, and
+// which attempts made the same changes.
+func (b *Batch) Compare() string {
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Attempt\tAgent\tVerify\tChanges")
+	for _, r := range b.Results {
+		agent := "done in " + round(r.Duration)
+		if r.Err != nil {
+			agent = "error: " + truncate(firstLine(r.Err.Error()), 40)
+		}
+		verify := "-"
+		if r.Verify != nil {
+			verify = r.Verify.status()
+		}
+		fmt.Fprintf(tw, "#%d\t%s\t%s\t%s\n", r.N, agent, verify, r.changes())
+	}
+	tw.Flush()
+
+	// One row per file, one column per attempt
+	counts := map[string]map[int]string{}
+	for _, r := range b.Results {
+		if r.Diff == nil {
+			continue
+		}
+		for _, f := range r.Diff.Files {
+			if counts[f.Path] == nil {
+				counts[f.Path] = map[int]string{}
+			}
+			counts[f.Path][r.N] = fmt.Sprintf("+%d -%d", f.Added, f.Removed)
+			if f.Binary {
+				counts[f.Path][r.N] = "binary"
+			}
+		}
+	}
+	if len(counts) > 0 {
+		paths := make([]string, 0, len(counts))
+		for path := range counts {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		sb.WriteString("\nFiles changed:\n")
+		tw = tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+		header := "  File"
+		for _, r := range b.Results {
+			header += fmt.Sprintf("\t#%d", r.N)
+		}
+		fmt.Fprintln(tw, header)
+		for _, path := range paths {
+			row := "  " + path
+			for _, r := range b.Results {
+				cell := counts[path][r.N]
+				if cell == "" {
+					cell = "-"
+				}
+				row += "\t" + cell
+			}
+			fmt.Fprintln(tw, row)
+		}
+		tw.Flush()
+	}
+
+	for _, group := range b.identical() {
+		names := make([]string, len(group))
+		for i, n := range group {
+			names[i] = fmt.Sprintf("#%d", n)
+		}
+		fmt.Fprintf(&sb, "\nAttempts %s made the same changes.\n", strings.Join(names, ", "))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// Detail returns the agent's answer, the verification output and the patch
+// of a result.
+func (r *Result) Detail() string {
+	var sb strings.Builder
+	sb.WriteString("Attempt " + r.Summary() + "\n")
+	if r.Err != nil {
+		fmt.Fprintf(&sb, "\nError: %v\n", r.Err)
+	}
+	if response := strings.TrimSpace(r.Response); response != "" {
+		fmt.Fprintf(&sb, "\nAgent:\n%s\n", response)
+	}
+	if r.Verify != nil {
+		fmt.Fprintf(&sb, "\nVerification ($ %s): %s\n", r.Verify.Command, r.Verify.status())
+		if r.Verify.Output != "" {
+			sb.WriteString(r.Verify.Output + "\n")
+		}
+	}
+	if r.Changed() {
+		sb.WriteString("\nDiff:\n" + r.Diff.Patch)
+		if r.Diff.Truncated {
+			sb.WriteString("\n[diff truncated]")
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// identical groups the attempts with changes whose patches are equal.
+func (b *Batch) identical() [][]int {
+	var groups [][]int
+	seen := map[string]int{}
+	for _, r := range b.Results {
+		if !r.Changed() {
+			continue
+		}
+		if i, ok := seen[r.Diff.Patch]; ok {
+			groups[i] = append(groups[i], r.N)
+			continue
+		}
+		seen[r.Diff.Patch] = len(groups)
+		groups = append(groups, []int{r.N})
+	}
+	var same [][]int
+	for _, group := range groups {
+		if len(group) > 1 {
+			same = append(same, group)
+		}
+	}
+	return same
+}
+
+// changes describes the size of the result's diff.
+func (r *Result) changes() string {
+	if !r.Changed() {
+		return "no changes"
+	}
+	files := "files"
+	if len(r.Diff.Files) == 1 {
+		files = "file"
+	}
+	return fmt.Sprintf("%d %s, +%d -%d", len(r.Diff.Files), files, r.Diff.Added, r.Diff.Removed)
+}
+
+// status describes the outcome of the verification command.
+func (v *Verification) status() string {
+	switch {
+	case v.TimedOut:
+		return fmt.Sprintf("timed out after %s", round(v.Duration))
+	case v.Passed():
+		return fmt.Sprintf("passed in %s", round(v.Duration))
+	case v.ExitCode < 0:
+		return "could not run"
+	}
+	return fmt.Sprintf("failed with exit %d in %s", v.ExitCode, round(v.Duration))
+}
+
+func round(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+func truncate(s string, max int) string {
+	if runes := []rune(s); len(runes) > max {
+		return string(runes[:max-3]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RunCommand executes /backups or /restore typed at the interactive prompt
+// and returns the text to show. The input must start with the slash, so a
+// prompt such as "restore the old handler" goes to the model. It reports
+// false for other input. s may be nil when the edit tools are disabled.
+func RunCommand(s *Store, input string) (string, bool) {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, "/") {
+		return "", false
+	}
+	fields := strings.Fields(input[1:])
+	if len(fields) == 0 {
+		return "", false
+	}
+	fields[0] = strings.ToLower(fields[0])
+	if fields[0] != "backups" && fields[0] != "restore" {
+		return "", false
+	}
+	if s == nil {
+		return "Backups are not available (the edit tools are disabled).", true
+	}
+
+	output, err := Run(s, fields)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err), true
+	}
+	return output, true
+}
+
+// Run executes a backup command given as arguments, as used by the
+// interactive prompt and the command line, and returns the text to show:
+//
+//	backups [path]          list backups, newest first
+//	restore <id> [target]   restore a backup to its path or to target
+//
+// Before a restore overwrites a file, the file's current content is backed
+// up so the restore can be undone.
+func Run(s *Store, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("usage: backups [path] | restore <id> [target]")
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "backups":
+		if len(args) > 2 {
+			return "", fmt.Errorf("usage: backups [path]")
+		}
+		path := ""
+		if len(args) > 1 {
+			path = args[1]
+		}
+		return listBackups(s, path)
+
+	case "restore":
+		if len(args) < 2 || len(args) > 3 {
+			return "", fmt.Errorf("usage: restore <id> [target]; use backups to list IDs")
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(args[1], "#"))
+		if err != nil {
+			return "", fmt.Errorf("invalid backup ID %q", args[1])
+		}
+		target := ""
+		if len(args) > 2 {
+			target = args[2]
+		}
+		return restoreBackup(s, id, target)
+	}
+	return "", fmt.Errorf("unknown backup command %q", args[0])
+}
+
+// listBackups formats the backups of path, or of all files.
+func listBackups(s *Store, path string) (string, error) {
+	entries, err := s.List(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to list backups: %w", err)
+	}
+	if len(entries) == 0 {
+		if path != "" {
+			return fmt.Sprintf("No backups of %s.", path), nil
+		}
+		return "No backups yet.", nil
+	}
+
+	lines := []string{fmt.Sprintf("Backups in %s (newest first):", s.Dir())}
+	for _, e := range entries {
+		lines = append(lines, "  "+e.String())
+	}
+	lines = append(lines, "Use restore <id> to put a backup back.")
+	return strings.Join(lines, "\n"), nil
+}
+
+// restoreBackup restores a backup after saving what it overwrites.
+func restoreBackup(s *Store, id int, target string) (string, error) {
+	entry, err := s.Get(id)
+	if err != nil {
+		return "", fmt.Errorf("restore failed: %w", err)
+	}
+	if target == "" {
+		target = entry.Path
+	}
+
+	note := ""
+	if _, abs, err := s.resolve(target); err == nil {
+		if _, statErr := os.Stat(abs); statErr == nil {
+			saved, err := s.Save(target, Meta{Operation: "restore"})
+			if err != nil {
+				return "", fmt.Errorf("restore failed: could not back up the current %s: %w", target, err)
+			}
+			note = fmt.Sprintf(" (previous content saved as #%d)", saved.ID)
+		}
+	}
+
+	if _, err := s.Restore(id, target); err != nil {
+		return "", fmt.Errorf("restore failed: %w", err)
+	}
+	return fmt.Sprintf("Restored backup #%d to %s%s.", id, target, note), nil
+}
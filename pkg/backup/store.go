@@ -0,0 +1,594 @@
+// Package backup keeps copies of files before tools change them. Content is
+// stored once per distinct SHA-256 hash under .goai/backups/objects, and a
+// manifest maps numbered entries to the file's path relative to the work
+// directory together with what produced them.
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrNotFound is returned for unknown backup IDs.
+var ErrNotFound = errors.New("backup not found")
+
+const (
+	// lockTimeout is how long an operation waits for another process to
+	// release the manifest. It is longer than staleLockAge so a waiter
+	// outlives a lock whose owner cannot be checked.
+	lockTimeout = 45 * time.Second
+	// staleLockAge is the age after which a lock file is taken to be left
+	// behind even if its owner's PID is in use. No operation holds the
+	// lock for more than a moment.
+	staleLockAge = 30 * time.Second
+)
+
+// Retention limits how many backups are kept. Zero values disable a limit.
+type Retention struct {
+	MaxPerFile int           // Newest backups kept per path
+	MaxAge     time.Duration // Older backups are removed
+}
+
+// DefaultRetention keeps ten backups per file for a week.
+var DefaultRetention = Retention{MaxPerFile: 10, MaxAge: 7 * 24 * time.Hour}
+
+// Meta describes what created a backup.
+type Meta struct {
+	Operation string // Tool or command, e.g. "edit_file"
+	ToolUseID string
+	Session   string
+}
+
+// Entry is one backup in the manifest.
+type Entry struct {
+	ID        int         `json:"id"`
+	Path      string      `json:"path"` // Slash-separated, relative to the work directory
+	Hash      string      `json:"hash"`
+	Size      int64       `json:"size"`
+	Mode      os.FileMode `json:"mode"`
+	Time      time.Time   `json:"time"`
+	Operation string      `json:"operation,omitempty"`
+	ToolUseID string      `json:"tool_use_id,omitempty"`
+	Session   string      `json:"session,omitempty"`
+}
+
+// String returns a one-line description of the entry.
+func (e Entry) String() string {
+	s := fmt.Sprintf("#%d  %s  %s  %s", e.ID, e.Time.Format("2006-01-02 15:04:05"), e.Path, formatSize(e.Size))
+	if e.Operation != "" {
+		s += "  " + e.Operation
+	}
+	if e.ToolUseID != "" {
+		s += " (" + e.ToolUseID + ")"
+	}
+	return s
+}
+
+// manifest is the on-disk index of backups.
+type manifest struct {
+	NextID  int     `json:"next_id"`
+	Entries []Entry `json:"entries"`
+}
+
+// Store is a content-addressed backup store for one work directory. The
+// manifest is re-read for every operation and only changed while holding a
+// lock file, so several processes can share a store.
+type Store struct {
+	workDir   string
+	dir       string
+	retention Retention
+	now       func() time.Time
+	mu        sync.Mutex
+}
+
+// NewStore creates a store in workDir/.goai/backups.
+func NewStore(workDir string, retention Retention) *Store {
+	return &Store{
+		workDir:   workDir,
+		dir:       filepath.Join(workDir, ".goai", "backups"),
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// Dir returns the directory holding the store.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Save backs up the current content of path, which may be absolute or
+// relative to the work directory, and applies the retention policy.
+func (s *Store) Save(path string, meta Meta) (*Entry, error) {
+	rel, abs, err := s.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up %s: %w", rel, err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("cannot back up directory %s", rel)
+	}
+	content, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up %s: %w", rel, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	hash, err := s.writeObject(content)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := Entry{
+		ID:        m.NextID,
+		Path:      rel,
+		Hash:      hash,
+		Size:      int64(len(content)),
+		Mode:      info.Mode().Perm(),
+		Time:      s.now(),
+		Operation: meta.Operation,
+		ToolUseID: meta.ToolUseID,
+		Session:   meta.Session,
+	}
+	m.NextID++
+	m.Entries = append(m.Entries, entry)
+	s.prune(m)
+
+	if err := s.save(m); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// List returns the backups of path, or of all files if path is empty,
+// newest first.
+func (s *Store) List(path string) ([]Entry, error) {
+	var rel string
+	if path != "" {
+		var err error
+		if rel, _, err = s.resolve(path); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, e := range m.Entries {
+		if rel == "" || e.Path == rel {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID > entries[j].ID })
+	return entries, nil
+}
+
+// Get returns the entry with the given ID.
+func (s *Store) Get(id int) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range m.Entries {
+		if e.ID == id {
+			return &e, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: #%d", ErrNotFound, id)
+}
+
+// Read returns the content of a backup.
+func (s *Store) Read(id int) ([]byte, error) {
+	_, content, err := s.read(id)
+	return content, err
+}
+
+// read returns the entry and content of a backup. It holds the lock so
+// that pruning in another process cannot remove the content in between.
+func (s *Store) read(id int) (*Entry, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	m, err := s.load()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, e := range m.Entries {
+		if e.ID == id {
+			content, err := os.ReadFile(s.objectPath(e.Hash))
+			if err != nil {
+				return nil, nil, fmt.Errorf("backup #%d content is missing: %w", id, err)
+			}
+			return &e, content, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%w: #%d", ErrNotFound, id)
+}
+
+// Restore writes a backup back to its original path, or to target when
+// it is not empty, and returns the entry.
+func (s *Store) Restore(id int, target string) (*Entry, error) {
+	entry, content, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if target == "" {
+		target = entry.Path
+	}
+	_, abs, err := s.resolve(target)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+		return nil, fmt.Errorf("failed to restore backup #%d: %w", id, err)
+	}
+	if err := os.WriteFile(abs, content, entry.Mode); err != nil {
+		return nil, fmt.Errorf("failed to restore backup #%d: %w", id, err)
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(abs, entry.Mode); err != nil {
+		return nil, fmt.Errorf("failed to restore backup #%d: %w", id, err)
+	}
+	return entry, nil
+}
+
+// Delete removes a backup from the manifest, and its content if no other
+// backup shares it.
+func (s *Store) Delete(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	m, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i, e := range m.Entries {
+		if e.ID == id {
+			m.Entries = append(m.Entries[:i], m.Entries[i+1:]...)
+			s.removeUnreferenced(m, e.Hash)
+			return s.save(m)
+		}
+	}
+	return fmt.Errorf("%w: #%d", ErrNotFound, id)
+}
+
+// Prune applies the retention policy.
+func (s *Store) Prune() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	m, err := s.load()
+	if err != nil {
+		return err
+	}
+	if !s.prune(m) {
+		return nil
+	}
+	return s.save(m)
+}
+
+// prune drops entries outside the retention policy and their unshared
+// content. It reports whether anything was dropped.
+func (s *Store) prune(m *manifest) bool {
+	cutoff := time.Time{}
+	if s.retention.MaxAge > 0 {
+		cutoff = s.now().Add(-s.retention.MaxAge)
+	}
+
+	perFile := make(map[string]int)
+	var kept, dropped []Entry
+	// Walk newest first so the per-file limit keeps the latest backups
+	for i := len(m.Entries) - 1; i >= 0; i-- {
+		e := m.Entries[i]
+		perFile[e.Path]++
+		if (s.retention.MaxPerFile > 0 && perFile[e.Path] > s.retention.MaxPerFile) || e.Time.Before(cutoff) {
+			dropped = append(dropped, e)
+			continue
+		}
+		kept = append(kept, e)
+	}
+	if len(dropped) == 0 {
+		return false
+	}
+
+	for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+		kept[i], kept[j] = kept[j], kept[i]
+	}
+	m.Entries = kept
+	for _, e := range dropped {
+		s.removeUnreferenced(m, e.Hash)
+	}
+	return true
+}
+
+// removeUnreferenced deletes the content for hash if no entry uses it.
+func (s *Store) removeUnreferenced(m *manifest, hash string) {
+	for _, e := range m.Entries {
+		if e.Hash == hash {
+			return
+		}
+	}
+	_ = os.Remove(s.objectPath(hash))
+}
+
+// writeObject stores content under its hash unless it is already there.
+func (s *Store) writeObject(content []byte) (string, error) {
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	path := s.objectPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	if err := writeAtomic(path, content, 0644); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+	return hash, nil
+}
+
+// ContentPath returns the file holding the content of a backup. The file
+// is shared by backups with the same content and must not be changed.
+func (s *Store) ContentPath(e Entry) string {
+	return s.objectPath(e.Hash)
+}
+
+// objectPath returns where the content with the given hash is stored.
+func (s *Store) objectPath(hash string) string {
+	return filepath.Join(s.dir, "objects", hash[:2], hash[2:])
+}
+
+// manifestPath returns the path of the manifest file.
+func (s *Store) manifestPath() string {
+	return filepath.Join(s.dir, "manifest.json")
+}
+
+// lock creates the lock file that serializes access to the manifest
+// across processes, waiting while another process holds it, and returns
+// the function that removes it. The lock file holds the owner's PID, so a
+// lock left by a process that died is taken over at once.
+func (s *Store) lock() (func(), error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	path := filepath.Join(s.dir, "manifest.lock")
+	owner := strconv.Itoa(os.Getpid())
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		created, err := createExclusive(path, owner)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock backup manifest: %w", err)
+		}
+		if created {
+			return func() { _ = os.Remove(path) }, nil
+		}
+		if holder, stale := staleLock(path); stale {
+			if err := breakLock(path, holder); err != nil {
+				return nil, fmt.Errorf("failed to lock backup manifest: %w", err)
+			}
+		} else if time.Now().After(deadline) {
+			return nil, fmt.Errorf("backup manifest is locked by another process; remove %s if none is running", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// createExclusive creates path holding content unless it exists, and
+// reports whether it did.
+func createExclusive(path, content string) (bool, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	_, err = f.WriteString(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return false, err
+	}
+	return true, nil
+}
+
+// staleLock reports whether the lock file at path was left behind, either
+// because its owner is no longer running or because it is older than
+// staleLockAge, and returns its content.
+func staleLock(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false // Released meanwhile
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	holder := string(data)
+	if time.Since(info.ModTime()) > staleLockAge {
+		return holder, true
+	}
+	// An empty file is being written by its owner
+	pid, err := strconv.Atoi(holder)
+	return holder, err == nil && !processRunning(pid)
+}
+
+// breakLock removes the stale lock file at path if it still holds holder.
+// Breaking is itself serialized by a second lock file and the lock is
+// checked again while holding it, so two processes that both found the
+// lock stale cannot remove a lock one of them has just taken.
+func breakLock(path, holder string) error {
+	breaker := path + ".break"
+	created, err := createExclusive(breaker, strconv.Itoa(os.Getpid()))
+	if err != nil {
+		return err
+	}
+	if !created {
+		// A breaker that died leaves its file behind
+		if info, err := os.Stat(breaker); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			_ = os.Remove(breaker)
+		}
+		return nil
+	}
+	defer os.Remove(breaker)
+
+	if current, stale := staleLock(path); stale && current == holder {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// processRunning reports whether a process with the given PID exists.
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true // FindProcess fails for processes that are gone
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// load reads the manifest; a missing manifest is empty.
+func (s *Store) load() (*manifest, error) {
+	m := &manifest{NextID: 1}
+	data, err := os.ReadFile(s.manifestPath())
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid backup manifest: %w", err)
+	}
+	if m.NextID < 1 {
+		m.NextID = 1
+	}
+	return m, nil
+}
+
+// save writes the manifest atomically.
+func (s *Store) save(m *manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	if err := writeAtomic(s.manifestPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+	return nil
+}
+
+// resolve returns the slash-separated relative and the absolute form of
+// path, which must be inside the work directory.
+func (s *Store) resolve(path string) (string, string, error) {
+	abs := path
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(s.workDir, path)
+	}
+	abs = filepath.Clean(abs)
+	rel, err := filepath.Rel(s.workDir, abs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("path is outside the working directory: %s", path)
+	}
+	return filepath.ToSlash(rel), abs, nil
+}
+
+// writeAtomic writes data to a temporary file and renames it into place.
+func writeAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// formatSize formats a size in bytes in human-readable form.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,330 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Zerofisher/goai/internal/testutil"
+)
+
+// countObjects returns the number of content objects in the store.
+func countObjects(t *testing.T, s *Store) int {
+	t.Helper()
+	n := 0
+	err := filepath.Walk(filepath.Join(s.Dir(), "objects"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			n++
+		}
+		return err
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// TestSaveKeysByPath tests that files with the same name in different
+// directories are kept apart and identical content is stored once
+func TestSaveKeysByPath(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFile(t, dir, "a/config.go", "package a\n")
+	testutil.WriteFile(t, dir, "b/config.go", "package a\n")
+	s := NewStore(dir, DefaultRetention)
+
+	first, err := s.Save("a/config.go", Meta{Operation: "edit_file", ToolUseID: "toolu_1", Session: "s1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Save(filepath.Join(dir, "b/config.go"), Meta{Operation: "multi_edit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Path != "a/config.go" || second.Path != "b/config.go" {
+		t.Errorf("expected relative paths as keys, got %q and %q", first.Path, second.Path)
+	}
+	if first.Hash != second.Hash || countObjects(t, s) != 1 {
+		t.Errorf("expected identical content to share one object, got %d", countObjects(t, s))
+	}
+
+	entries, err := s.List("a/config.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ToolUseID != "toolu_1" || entries[0].Session != "s1" || entries[0].Operation != "edit_file" {
+		t.Errorf("unexpected manifest entries: %+v", entries)
+	}
+	if all, _ := s.List(""); len(all) != 2 || all[0].ID != second.ID {
+		t.Errorf("expected both backups newest first, got %+v", all)
+	}
+
+	if _, err := s.Save("../outside.txt", Meta{}); err == nil {
+		t.Error("expected error for a path outside the work directory")
+	}
+}
+
+// TestRestoreAndDelete tests restoring a backup and removing it
+func TestRestoreAndDelete(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFile(t, dir, "f.txt", "original\n")
+	s := NewStore(dir, DefaultRetention)
+
+	entry, err := s.Save("f.txt", Meta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.WriteFile(t, dir, "f.txt", "changed\n")
+
+	if _, err := s.Restore(entry.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "f.txt")); string(data) != "original\n" {
+		t.Errorf("f.txt = %q after restore", data)
+	}
+	if _, err := s.Restore(entry.ID, "copy/f.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "copy/f.txt")); string(data) != "original\n" {
+		t.Errorf("copy/f.txt = %q after restore", data)
+	}
+
+	if err := s.Delete(entry.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(entry.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if countObjects(t, s) != 0 {
+		t.Error("expected the unreferenced object to be removed")
+	}
+}
+
+// TestRetention tests the per-file limit and the age cutoff
+func TestRetention(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	s := NewStore(dir, Retention{MaxPerFile: 2, MaxAge: 24 * time.Hour})
+	s.now = func() time.Time { return now }
+
+	testutil.WriteFile(t, dir, "old.txt", "old\n")
+	if _, err := s.Save("old.txt", Meta{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"v1", "v2", "v3"} {
+		testutil.WriteFile(t, dir, "f.txt", v+"\n")
+		if _, err := s.Save("f.txt", Meta{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, _ := s.List("f.txt")
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 backups of f.txt, got %d", len(entries))
+	}
+	if data, _ := s.Read(entries[1].ID); string(data) != "v2\n" {
+		t.Errorf("expected the oldest kept backup to be v2, got %q", data)
+	}
+
+	now = now.Add(36 * time.Hour)
+	if err := s.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := s.List(""); len(all) != 0 {
+		t.Errorf("expected all backups to expire, got %d", len(all))
+	}
+	if countObjects(t, s) != 0 {
+		t.Errorf("expected expired objects to be removed, got %d", countObjects(t, s))
+	}
+}
+
+// TestRunCommand tests the backup commands
+func TestRunCommand(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFile(t, dir, "pkg/Main.go", "v1\n")
+	s := NewStore(dir, DefaultRetention)
+
+	tests := []struct {
+		input   string
+		handled bool
+		want    string
+	}{
+		{"/help", false, ""},
+		{"/backups", true, "No backups yet"},
+		{"/restore", true, "usage"},
+		{"/restore x", true, "invalid backup ID"},
+		{"/restore 9", true, "restore failed: backup not found"},
+		{"/restore 1 a b", true, "usage"},
+		{"backups", false, ""},
+		{"restore the old handler", false, ""},
+		{"restore 3 files", false, ""},
+		{"backups are broken, fix them", false, ""},
+	}
+	for _, tt := range tests {
+		out, ok := RunCommand(s, tt.input)
+		if ok != tt.handled || !strings.Contains(out, tt.want) {
+			t.Errorf("RunCommand(%q) = %q, %v", tt.input, out, ok)
+		}
+	}
+
+	entry, err := s.Save("pkg/Main.go", Meta{Operation: "edit_file"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.WriteFile(t, dir, "pkg/Main.go", "v2\n")
+
+	if out, _ := RunCommand(s, "/backups pkg/Main.go"); !strings.Contains(out, "pkg/Main.go") || !strings.Contains(out, "edit_file") {
+		t.Errorf("unexpected listing:\n%s", out)
+	}
+	out, _ := RunCommand(s, "/restore #1")
+	if !strings.Contains(out, "Restored backup #1 to pkg/Main.go (previous content saved as #2)") {
+		t.Errorf("unexpected restore output: %s", out)
+	}
+	if data, _ := s.Read(entry.ID + 1); string(data) != "v2\n" {
+		t.Errorf("expected the overwritten content to be backed up, got %q", data)
+	}
+	if out, _ := RunCommand(nil, "/backups"); !strings.Contains(out, "not available") {
+		t.Errorf("unexpected output without store: %s", out)
+	}
+}
+
+// TestRun tests the backup commands of the command line
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFile(t, dir, "main.go", "v1\n")
+	s := NewStore(dir, DefaultRetention)
+	if _, err := s.Save("main.go", Meta{Operation: "edit_file"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if out, err := Run(s, []string{"backups"}); err != nil || !strings.Contains(out, "main.go") {
+		t.Errorf("Run(backups) = %q, %v", out, err)
+	}
+	if out, err := Run(s, []string{"restore", "1", "copy.go"}); err != nil || !strings.Contains(out, "Restored backup #1 to copy.go") {
+		t.Errorf("Run(restore 1 copy.go) = %q, %v", out, err)
+	}
+	for _, args := range [][]string{{"restore"}, {"restore", "x"}, {"restore", "9"}, {"backups", "a", "b"}, {}} {
+		if out, err := Run(s, args); err == nil {
+			t.Errorf("Run(%q) = %q, want an error", args, out)
+		}
+	}
+}
+
+// TestConcurrentStores tests that stores of several processes sharing a
+// directory do not lose each other's backups
+func TestConcurrentStores(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFile(t, dir, "f.txt", "v1\n")
+
+	const stores, saves = 4, 10
+	var wg sync.WaitGroup
+	for i := 0; i < stores; i++ {
+		s := NewStore(dir, Retention{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < saves; j++ {
+				if _, err := s.Save("f.txt", Meta{Operation: "edit_file"}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, err := NewStore(dir, Retention{}).List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[int]bool)
+	for _, e := range entries {
+		ids[e.ID] = true
+	}
+	if len(entries) != stores*saves || len(ids) != stores*saves {
+		t.Errorf("got %d entries with %d distinct IDs, want %d", len(entries), len(ids), stores*saves)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".goai", "backups", "manifest.lock")); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+}
+
+// TestStaleLock tests that locks left by processes that are gone or that
+// are too old are taken over, and that readers wait for the lock
+func TestStaleLock(t *testing.T) {
+	if lockTimeout <= staleLockAge {
+		t.Fatalf("lockTimeout %v must exceed staleLockAge %v", lockTimeout, staleLockAge)
+	}
+
+	dir := t.TempDir()
+	testutil.WriteFile(t, dir, "f.txt", "v1\n")
+	s := NewStore(dir, Retention{})
+	entry, err := s.Save("f.txt", Meta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockPath := filepath.Join(s.Dir(), "manifest.lock")
+
+	// The PID of a process that has exited
+	cmd := exec.Command("go", "version")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("cannot run a child process: %v", err)
+	}
+	dead := strconv.Itoa(cmd.Process.Pid)
+
+	t.Run("DeadOwner", func(t *testing.T) {
+		if err := os.WriteFile(lockPath, []byte(dead), 0644); err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		if _, err := s.Read(entry.ID); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Errorf("took %v to take over the lock of a dead process", time.Since(start))
+		}
+	})
+
+	t.Run("OldLock", func(t *testing.T) {
+		if err := os.WriteFile(lockPath, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+			t.Fatal(err)
+		}
+		old := time.Now().Add(-2 * staleLockAge)
+		if err := os.Chtimes(lockPath, old, old); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Save("f.txt", Meta{}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	})
+
+	t.Run("LiveOwner", func(t *testing.T) {
+		if err := os.WriteFile(lockPath, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan error, 1)
+		go func() {
+			_, err := s.Restore(entry.ID, "restored.txt")
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			t.Fatalf("Restore did not wait for the lock: %v", err)
+		case <-time.After(200 * time.Millisecond):
+		}
+		if err := os.Remove(lockPath); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+		if got := testutil.ReadFile(t, dir, "restored.txt"); got != "v1\n" {
+			t.Errorf("restored %q", got)
+		}
+	})
+}
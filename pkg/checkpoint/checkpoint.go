@@ -0,0 +1,466 @@
+// Package checkpoint records the files each agent turn changes so a turn
+// can be undone. A turn's checkpoint holds the content every touched file
+// had before the turn started; restoring it puts those contents back.
+package checkpoint
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zerofisher/goai/pkg/tools/edit"
+)
+
+// File change statuses.
+const (
+	StatusCreated  = "created"
+	StatusModified = "modified"
+	StatusDeleted  = "deleted"
+)
+
+var (
+	// ErrTurnActive is returned when restoring while a turn is running.
+	ErrTurnActive = errors.New("cannot restore while a turn is in progress")
+
+	// ErrNoCheckpoint is returned when there is nothing to restore.
+	ErrNoCheckpoint = errors.New("no checkpoint to restore")
+)
+
+// Options configures a Manager.
+type Options struct {
+	// MaxCheckpoints is the number of turns kept; older checkpoints are
+	// dropped. Zero means 50.
+	MaxCheckpoints int
+
+	// MaxFileSize is the largest file whose content command scans keep in
+	// memory. Larger files changed by commands are reported but cannot be
+	// restored. Zero means 1MB.
+	MaxFileSize int64
+
+	// MaxScanBytes bounds the total content kept by command scans. Zero
+	// means 64MB.
+	MaxScanBytes int64
+
+	// Excludes are extra gitignore-style patterns skipped by command scans.
+	Excludes []string
+}
+
+// Change describes how one file differs after a turn.
+type Change struct {
+	Path    string `json:"path"`
+	Status  string `json:"status"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+}
+
+// Checkpoint is the state of the files one turn changed, taken before the
+// turn.
+type Checkpoint struct {
+	ID      int
+	Prompt  string
+	Time    time.Time
+	Changes []Change
+
+	files map[string]*fileState
+	diff  string
+}
+
+// fileState is a file's content at some point in time.
+type fileState struct {
+	existed    bool
+	content    []byte
+	mode       os.FileMode
+	restorable bool
+}
+
+// Restore reports the result of an undo or rewind.
+type Restore struct {
+	Checkpoints []*Checkpoint // Restored checkpoints, newest first
+	Files       []string      // Files put back
+	Skipped     []string      // Files that could not be restored
+}
+
+// Manager records a checkpoint per turn.
+type Manager struct {
+	workDir string
+	opts    Options
+	diff    *edit.DiffGenerator
+
+	mu          sync.Mutex
+	checkpoints []*Checkpoint
+	current     *Checkpoint
+	nextID      int
+	scan        *scanner
+}
+
+// NewManager creates a checkpoint manager for workDir.
+func NewManager(workDir string, opts Options) *Manager {
+	if opts.MaxCheckpoints <= 0 {
+		opts.MaxCheckpoints = 50
+	}
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = 1024 * 1024
+	}
+	if opts.MaxScanBytes <= 0 {
+		opts.MaxScanBytes = 64 * 1024 * 1024
+	}
+	return &Manager{
+		workDir: workDir,
+		opts:    opts,
+		diff:    edit.NewDiffGenerator(),
+		nextID:  1,
+		scan:    newScanner(workDir, opts),
+	}
+}
+
+// Begin starts recording a turn labeled with the user prompt. An
+// unfinished turn is ended first.
+func (m *Manager) Begin(prompt string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.current != nil {
+		m.endLocked()
+	}
+	m.current = &Checkpoint{
+		Prompt: prompt,
+		Time:   time.Now(),
+		files:  make(map[string]*fileState),
+	}
+}
+
+// Track records the current state of paths before they are modified.
+// Only the first call per file and turn counts; outside a turn, and for
+// paths outside the work directory, it does nothing.
+func (m *Manager) Track(paths ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.current == nil {
+		return
+	}
+	for _, path := range paths {
+		rel, ok := m.rel(path)
+		if !ok {
+			continue
+		}
+		if _, tracked := m.current.files[rel]; tracked {
+			continue
+		}
+		m.current.files[rel] = m.readState(rel)
+	}
+}
+
+// End finishes the current turn. It returns the turn's checkpoint, or nil
+// if the turn left every file as it was.
+func (m *Manager) End() *Checkpoint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.endLocked()
+}
+
+// endLocked finishes the current turn with m.mu held.
+func (m *Manager) endLocked() *Checkpoint {
+	cp := m.current
+	m.current = nil
+	if cp == nil {
+		return nil
+	}
+
+	var diffs []string
+	for _, rel := range sortedPaths(cp.files) {
+		before := cp.files[rel]
+		after := m.readState(rel)
+		change, diff, changed := m.compare(rel, before, after)
+		if !changed {
+			delete(cp.files, rel)
+			continue
+		}
+		cp.Changes = append(cp.Changes, change)
+		if diff != "" {
+			diffs = append(diffs, diff)
+		}
+	}
+	if len(cp.Changes) == 0 {
+		return nil
+	}
+
+	cp.ID = m.nextID
+	m.nextID++
+	cp.diff = strings.Join(diffs, "\n")
+	m.checkpoints = append(m.checkpoints, cp)
+	if len(m.checkpoints) > m.opts.MaxCheckpoints {
+		m.checkpoints = m.checkpoints[len(m.checkpoints)-m.opts.MaxCheckpoints:]
+	}
+	return cp
+}
+
+// List returns the recorded checkpoints, oldest first.
+func (m *Manager) List() []*Checkpoint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Checkpoint(nil), m.checkpoints...)
+}
+
+// Latest returns the newest checkpoint, or nil if there is none.
+func (m *Manager) Latest() *Checkpoint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.checkpoints) == 0 {
+		return nil
+	}
+	return m.checkpoints[len(m.checkpoints)-1]
+}
+
+// Changed returns the files changed by the recorded turns, together with
+// the files the current turn has touched so far, sorted and relative to the
+// work directory.
+func (m *Manager) Changed() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[string]*fileState)
+	for _, cp := range m.checkpoints {
+		for _, change := range cp.Changes {
+			seen[change.Path] = nil
+		}
+	}
+	if m.current != nil {
+		for rel := range m.current.files {
+			seen[rel] = nil
+		}
+	}
+	return sortedPaths(seen)
+}
+
+// Undo restores the files changed by the last turn.
+func (m *Manager) Undo() (*Restore, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.checkpoints) == 0 {
+		return nil, ErrNoCheckpoint
+	}
+	return m.restoreFrom(len(m.checkpoints) - 1)
+}
+
+// Rewind restores the files to their state before checkpoint id, undoing
+// that turn and every later one.
+func (m *Manager) Rewind(id int) (*Restore, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, cp := range m.checkpoints {
+		if cp.ID == id {
+			return m.restoreFrom(i)
+		}
+	}
+	return nil, fmt.Errorf("checkpoint %d not found", id)
+}
+
+// restoreFrom restores checkpoints[idx:] newest first and drops them.
+func (m *Manager) restoreFrom(idx int) (*Restore, error) {
+	if m.current != nil {
+		return nil, ErrTurnActive
+	}
+
+	result := &Restore{}
+	seen := make(map[string]bool)
+	var errs []error
+	for i := len(m.checkpoints) - 1; i >= idx; i-- {
+		cp := m.checkpoints[i]
+		result.Checkpoints = append(result.Checkpoints, cp)
+		for _, rel := range sortedPaths(cp.files) {
+			state := cp.files[rel]
+			if !state.restorable {
+				result.Skipped = append(result.Skipped, rel)
+				continue
+			}
+			if err := m.writeState(rel, state); err != nil {
+				errs = append(errs, err)
+				result.Skipped = append(result.Skipped, rel)
+				continue
+			}
+			// Older checkpoints overwrite newer ones; report each file once
+			if !seen[rel] {
+				seen[rel] = true
+				result.Files = append(result.Files, rel)
+			}
+		}
+	}
+	m.checkpoints = m.checkpoints[:idx]
+	// Files changed behind the scanner's back must be re-read
+	m.scan.reset()
+	return result, errors.Join(errs...)
+}
+
+// Diff returns the unified diff of the turn's changes.
+func (c *Checkpoint) Diff() string {
+	return c.diff
+}
+
+// Stats returns the total lines added and removed by the turn.
+func (c *Checkpoint) Stats() (added, removed int) {
+	for _, ch := range c.Changes {
+		added += ch.Added
+		removed += ch.Removed
+	}
+	return added, removed
+}
+
+// Summary describes the turn's changes in one line.
+func (c *Checkpoint) Summary() string {
+	added, removed := c.Stats()
+	return fmt.Sprintf("%d file(s) changed, +%d -%d", len(c.Changes), added, removed)
+}
+
+// Report describes the checkpoint with one line per changed file.
+func (c *Checkpoint) Report() string {
+	prompt := strings.Join(strings.Fields(c.Prompt), " ")
+	if len(prompt) > 60 {
+		prompt = prompt[:57] + "..."
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "#%d  %s  %q  (%s)", c.ID, c.Time.Format("15:04:05"), prompt, c.Summary())
+	for _, ch := range c.Changes {
+		fmt.Fprintf(&b, "\n  %s %s  +%d -%d", strings.ToUpper(ch.Status[:1]), ch.Path, ch.Added, ch.Removed)
+	}
+	return b.String()
+}
+
+// Describe summarizes the restore for display.
+func (r *Restore) Describe() string {
+	ids := make([]string, len(r.Checkpoints))
+	for i, cp := range r.Checkpoints {
+		ids[i] = fmt.Sprintf("#%d", cp.ID)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Restored %d file(s) from checkpoint %s", len(r.Files), strings.Join(ids, ", "))
+	for _, path := range r.Files {
+		fmt.Fprintf(&b, "\n  %s", path)
+	}
+	if len(r.Skipped) > 0 {
+		fmt.Fprintf(&b, "\nCould not restore: %s", strings.Join(r.Skipped, ", "))
+	}
+	return b.String()
+}
+
+// rel converts path to a slash-separated path relative to the work
+// directory. It reports false for paths outside it.
+func (m *Manager) rel(path string) (string, bool) {
+	if path == "" {
+		return "", false
+	}
+	abs := path
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(m.workDir, path)
+	}
+	rel, err := filepath.Rel(m.workDir, filepath.Clean(abs))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// readState reads the current state of a file.
+func (m *Manager) readState(rel string) *fileState {
+	path := filepath.Join(m.workDir, filepath.FromSlash(rel))
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return &fileState{restorable: os.IsNotExist(err)}
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return &fileState{existed: true, mode: info.Mode().Perm()}
+	}
+	return &fileState{existed: true, content: content, mode: info.Mode().Perm(), restorable: true}
+}
+
+// writeState puts a file back into the recorded state.
+func (m *Manager) writeState(rel string, state *fileState) error {
+	path := filepath.Join(m.workDir, filepath.FromSlash(rel))
+	if !state.existed {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", rel, err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", rel, err)
+	}
+	if err := os.WriteFile(path, state.content, state.mode); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", rel, err)
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(path, state.mode); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", rel, err)
+	}
+	return nil
+}
+
+// compare describes how a file changed between two states.
+func (m *Manager) compare(rel string, before, after *fileState) (Change, string, bool) {
+	change := Change{Path: rel}
+	switch {
+	case !before.existed && !after.existed:
+		return change, "", false
+	case !before.existed:
+		change.Status = StatusCreated
+	case !after.existed:
+		change.Status = StatusDeleted
+	default:
+		if before.restorable && after.restorable && bytes.Equal(before.content, after.content) {
+			if before.mode == after.mode {
+				return change, "", false
+			}
+		}
+		change.Status = StatusModified
+	}
+
+	// Unreadable and binary content only gets a status
+	if (before.existed && !before.restorable) || (after.existed && !after.restorable) ||
+		isBinary(before.content) || isBinary(after.content) {
+		return change, "", true
+	}
+
+	diff := m.diff.GenerateDiff(string(before.content), string(after.content), rel)
+	if diff == "No changes detected" {
+		return change, "", true
+	}
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			change.Added++
+		case strings.HasPrefix(line, "-"):
+			change.Removed++
+		}
+	}
+	return change, diff, true
+}
+
+// isBinary reports whether content looks binary.
+func isBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// sortedPaths returns the keys of files in sorted order.
+func sortedPaths(files map[string]*fileState) []string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
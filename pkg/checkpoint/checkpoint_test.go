@@ -0,0 +1,258 @@
+package checkpoint
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Zerofisher/goai/internal/testutil"
+)
+
+// readFile returns the content of dir/name, or "<missing>".
+func readFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return "<missing>"
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// TestTurnAndUndo tests recording a turn and undoing it
+func TestTurnAndUndo(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFile(t, dir, "a.txt", "one\ntwo\n")
+	testutil.WriteFile(t, dir, "same.txt", "same\n")
+	m := NewManager(dir, Options{})
+
+	m.Begin("change a, add b")
+	m.Track("a.txt", filepath.Join(dir, "b.txt"), "same.txt", "../outside.txt")
+	m.Track("a.txt") // Only the first snapshot counts
+	testutil.WriteFile(t, dir, "a.txt", "one\n2\n")
+	m.Track("a.txt")
+	testutil.WriteFile(t, dir, "b.txt", "new\n")
+	cp := m.End()
+
+	if cp == nil || cp.ID != 1 || cp.Prompt != "change a, add b" {
+		t.Fatalf("unexpected checkpoint: %+v", cp)
+	}
+	if len(cp.Changes) != 2 {
+		t.Fatalf("expected 2 changes (unchanged files dropped), got %+v", cp.Changes)
+	}
+	if cp.Changes[0] != (Change{Path: "a.txt", Status: StatusModified, Added: 1, Removed: 1}) {
+		t.Errorf("unexpected change for a.txt: %+v", cp.Changes[0])
+	}
+	if cp.Changes[1] != (Change{Path: "b.txt", Status: StatusCreated, Added: 1}) {
+		t.Errorf("unexpected change for b.txt: %+v", cp.Changes[1])
+	}
+	if !strings.Contains(cp.Diff(), "+2") || !strings.Contains(cp.Report(), "M a.txt  +1 -1") {
+		t.Errorf("unexpected diff or report:\n%s\n%s", cp.Diff(), cp.Report())
+	}
+
+	restore, err := m.Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restore.Files) != 2 || len(restore.Skipped) != 0 {
+		t.Errorf("unexpected restore: %+v", restore)
+	}
+	if got := readFile(t, dir, "a.txt"); got != "one\ntwo\n" {
+		t.Errorf("a.txt = %q", got)
+	}
+	if got := readFile(t, dir, "b.txt"); got != "<missing>" {
+		t.Errorf("b.txt should be removed, got %q", got)
+	}
+	if _, err := m.Undo(); !errors.Is(err, ErrNoCheckpoint) {
+		t.Errorf("expected ErrNoCheckpoint, got %v", err)
+	}
+}
+
+// TestChanged tests listing the files changed in the session
+func TestChanged(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFile(t, dir, "a.txt", "one\n")
+	m := NewManager(dir, Options{})
+
+	m.Begin("first")
+	m.Track("a.txt", "untouched.txt")
+	testutil.WriteFile(t, dir, "a.txt", "two\n")
+	m.End()
+
+	m.Begin("second")
+	m.Track("pkg/b.go")
+	got := strings.Join(m.Changed(), ",")
+	if got != "a.txt,pkg/b.go" {
+		t.Errorf("Changed() = %s, want recorded and in-progress files", got)
+	}
+
+	m.End()
+	if _, err := m.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Undo(); !errors.Is(err, ErrNoCheckpoint) {
+		t.Fatalf("expected nothing left to undo, got %v", err)
+	}
+	if got := m.Changed(); len(got) != 0 {
+		t.Errorf("Changed() = %v after undo, want none", got)
+	}
+}
+
+// TestEmptyTurn tests that turns without changes leave no checkpoint
+func TestEmptyTurn(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(dir, Options{})
+
+	m.Begin("just asking")
+	if cp := m.End(); cp != nil {
+		t.Errorf("expected no checkpoint, got %+v", cp)
+	}
+	m.Track("ignored.txt") // Outside a turn
+	if len(m.List()) != 0 {
+		t.Errorf("expected no checkpoints, got %d", len(m.List()))
+	}
+}
+
+// TestRewind tests restoring an earlier checkpoint
+func TestRewind(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFile(t, dir, "f.txt", "v0\n")
+	m := NewManager(dir, Options{})
+
+	for _, v := range []string{"v1", "v2", "v3"} {
+		m.Begin("write " + v)
+		m.Track("f.txt")
+		testutil.WriteFile(t, dir, "f.txt", v+"\n")
+		m.End()
+	}
+
+	m.Begin("in progress")
+	if _, err := m.Rewind(2); !errors.Is(err, ErrTurnActive) {
+		t.Errorf("expected ErrTurnActive, got %v", err)
+	}
+	m.End()
+
+	restore, err := m.Rewind(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restore.Checkpoints) != 2 || restore.Checkpoints[0].ID != 3 {
+		t.Errorf("expected checkpoints 3 and 2 restored, got %+v", restore.Checkpoints)
+	}
+	if got := readFile(t, dir, "f.txt"); got != "v1\n" {
+		t.Errorf("f.txt = %q, want the state before turn 2", got)
+	}
+	if list := m.List(); len(list) != 1 || list[0].ID != 1 {
+		t.Errorf("expected only checkpoint 1 left, got %d", len(list))
+	}
+	if _, err := m.Rewind(7); err == nil {
+		t.Error("expected error for unknown checkpoint")
+	}
+}
+
+// TestCommandChanges tests detecting files changed by a shell command
+func TestCommandChanges(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFile(t, dir, "keep.txt", "keep\n")
+	testutil.WriteFile(t, dir, "edit.txt", "before\n")
+	testutil.WriteFile(t, dir, "gone.txt", "gone\n")
+	testutil.WriteFile(t, dir, "big.bin", strings.Repeat("x", 64))
+	testutil.WriteFile(t, dir, ".gitignore", "build/\n")
+	m := NewManager(dir, Options{MaxFileSize: 32})
+	ctx := context.Background()
+
+	m.Begin("run the generator")
+	if err := m.BeforeCommand(ctx); err != nil {
+		t.Fatal(err)
+	}
+	testutil.WriteFile(t, dir, "edit.txt", "after the command\n")
+	testutil.WriteFile(t, dir, "new/made.txt", "made\n")
+	testutil.WriteFile(t, dir, "build/out.o", "ignored\n")
+	testutil.WriteFile(t, dir, "big.bin", strings.Repeat("y", 65))
+	testutil.WriteFile(t, dir, ".env", "TOKEN=x\n")
+	testutil.WriteFile(t, dir, ".goai/backups/manifest.json", "{}\n")
+	if err := os.Remove(filepath.Join(dir, "gone.txt")); err != nil {
+		t.Fatal(err)
+	}
+	paths, err := m.AfterCommand(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(paths, ",") != ".env,big.bin,edit.txt,gone.txt,new/made.txt" {
+		t.Errorf("unexpected changed paths: %v", paths)
+	}
+	cp := m.End()
+	if cp == nil || len(cp.Changes) != 5 {
+		t.Fatalf("unexpected checkpoint: %+v", cp)
+	}
+
+	restore, err := m.Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restore.Skipped) != 1 || restore.Skipped[0] != "big.bin" {
+		t.Errorf("large files cannot be restored, got skipped %v", restore.Skipped)
+	}
+	for name, want := range map[string]string{
+		"edit.txt":     "before\n",
+		"gone.txt":     "gone\n",
+		"new/made.txt": "<missing>",
+		"keep.txt":     "keep\n",
+		".env":         "<missing>",
+	} {
+		if got := readFile(t, dir, name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// TestRunCommand tests the interactive checkpoint commands
+func TestRunCommand(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFile(t, dir, "f.txt", "v0\n")
+	m := NewManager(dir, Options{})
+
+	tests := []struct {
+		input   string
+		handled bool
+		want    string
+	}{
+		{"/help", false, ""},
+		{"/undo", true, "Nothing to undo"},
+		{"/checkpoints", true, "No checkpoints yet"},
+		{"/rewind x", true, "Invalid checkpoint"},
+		{"/undo everything", true, "takes no arguments"},
+		{"/rewind 1 2", true, "Usage"},
+		{"undo", false, ""},
+		{"Undo the rename you did in main.go", false, ""},
+		{"rewind the parser to use tokens", false, ""},
+		{"checkpoints should be listed in the README", false, ""},
+		{"/", false, ""},
+	}
+	for _, tt := range tests {
+		out, ok := RunCommand(m, tt.input)
+		if ok != tt.handled || !strings.Contains(out, tt.want) {
+			t.Errorf("RunCommand(%q) = %q, %v", tt.input, out, ok)
+		}
+	}
+
+	m.Begin("update f")
+	m.Track("f.txt")
+	testutil.WriteFile(t, dir, "f.txt", "v1\n")
+	m.End()
+
+	if out, _ := RunCommand(m, "/rewind"); !strings.Contains(out, `#1`) || !strings.Contains(out, "M f.txt  +1 -1") {
+		t.Errorf("unexpected listing:\n%s", out)
+	}
+	if out, _ := RunCommand(m, "/rewind #1"); !strings.Contains(out, "Restored 1 file(s) from checkpoint #1") {
+		t.Errorf("unexpected rewind output:\n%s", out)
+	}
+	if out, _ := RunCommand(nil, "/undo"); !strings.Contains(out, "disabled") {
+		t.Errorf("unexpected output without manager: %s", out)
+	}
+}
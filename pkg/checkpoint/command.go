@@ -0,0 +1,84 @@
+package checkpoint
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RunCommand executes one of the interactive checkpoint commands and
+// returns the text to show:
+//
+//	/checkpoints   list the checkpoints with their changes
+//	/undo          restore the files changed by the last turn
+//	/rewind [n]    restore the state before checkpoint n, or list them
+//
+// The input must start with the slash, so a prompt such as "undo the
+// rename" goes to the model. It reports false for other input. m may be
+// nil when checkpoints are disabled.
+func RunCommand(m *Manager, input string) (string, bool) {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, "/") {
+		return "", false
+	}
+	fields := strings.Fields(input[1:])
+	if len(fields) == 0 {
+		return "", false
+	}
+	name := strings.ToLower(fields[0])
+	switch {
+	case name == "checkpoints" || name == "undo":
+		if len(fields) > 1 {
+			return fmt.Sprintf("/%s takes no arguments.", name), true
+		}
+	case name == "rewind":
+		if len(fields) > 2 {
+			return "Usage: /rewind [n]", true
+		}
+	default:
+		return "", false
+	}
+
+	if m == nil {
+		return "Checkpoints are disabled (checkpoints.enabled in the configuration).", true
+	}
+
+	switch {
+	case name == "undo":
+		return describeRestore(m.Undo())
+
+	case name == "rewind" && len(fields) > 1:
+		id, err := strconv.Atoi(strings.TrimPrefix(fields[1], "#"))
+		if err != nil {
+			return fmt.Sprintf("Invalid checkpoint %q; use /checkpoints to list them.", fields[1]), true
+		}
+		return describeRestore(m.Rewind(id))
+	}
+
+	list := m.List()
+	if len(list) == 0 {
+		return "No checkpoints yet. Turns that change files are recorded automatically.", true
+	}
+	lines := []string{"Checkpoints (oldest first):"}
+	for _, cp := range list {
+		lines = append(lines, cp.Report())
+	}
+	if name == "rewind" {
+		lines = append(lines, "Use /rewind <n> to restore the files to their state before checkpoint n.")
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+// describeRestore formats the result of Undo or Rewind.
+func describeRestore(r *Restore, err error) (string, bool) {
+	switch {
+	case errors.Is(err, ErrNoCheckpoint):
+		return "Nothing to undo.", true
+	case r == nil:
+		return fmt.Sprintf("Restore failed: %v", err), true
+	case err != nil:
+		return r.Describe() + fmt.Sprintf("\nErrors: %v", err), true
+	}
+	return r.Describe(), true
+}
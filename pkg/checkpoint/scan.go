@@ -0,0 +1,169 @@
+package checkpoint
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Zerofisher/goai/pkg/ignore"
+)
+
+// scanner finds files changed by shell commands. It keeps the metadata of
+// every non-ignored file, plus the content of small files, so the state a
+// file had before a command is still known after the command changed it.
+type scanner struct {
+	workDir string
+	opts    Options
+	files   map[string]*scanEntry
+	cached  int64 // Bytes of content held
+}
+
+// scanEntry is what the scanner knows about one file.
+type scanEntry struct {
+	size    int64
+	modTime time.Time
+	mode    os.FileMode
+	content []byte // nil when the file is too large to keep
+}
+
+// newScanner creates a scanner for workDir.
+func newScanner(workDir string, opts Options) *scanner {
+	return &scanner{workDir: workDir, opts: opts, files: make(map[string]*scanEntry)}
+}
+
+// stat lists the non-ignored files with their metadata. Dot files are
+// included, since commands change files like .env as well; .git and goai's
+// own .goai directory are not.
+func (s *scanner) stat(ctx context.Context) (map[string]fs.FileInfo, error) {
+	infos := make(map[string]fs.FileInfo)
+	opts := ignore.WalkOptions{
+		Excludes:      append([]string{"/.goai/"}, s.opts.Excludes...),
+		IncludeHidden: true,
+	}
+	err := ignore.Walk(ctx, s.workDir, opts, func(rel string, d fs.DirEntry) error {
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			infos[rel] = info
+		}
+		return nil
+	})
+	return infos, err
+}
+
+// refresh brings the scanner up to date, reading only files that changed
+// since the last refresh.
+func (s *scanner) refresh(ctx context.Context) error {
+	infos, err := s.stat(ctx)
+	if err != nil {
+		return err
+	}
+
+	for rel, entry := range s.files {
+		if _, ok := infos[rel]; !ok {
+			s.drop(rel, entry)
+		}
+	}
+	for rel, info := range infos {
+		if entry, ok := s.files[rel]; ok && entry.matches(info) {
+			continue
+		}
+		if entry, ok := s.files[rel]; ok {
+			s.drop(rel, entry)
+		}
+
+		entry := &scanEntry{size: info.Size(), modTime: info.ModTime(), mode: info.Mode().Perm()}
+		if info.Size() <= s.opts.MaxFileSize && s.cached+info.Size() <= s.opts.MaxScanBytes {
+			if content, err := os.ReadFile(filepath.Join(s.workDir, filepath.FromSlash(rel))); err == nil {
+				entry.content = content
+				s.cached += int64(len(content))
+			}
+		}
+		s.files[rel] = entry
+	}
+	return nil
+}
+
+// changes returns the previous state of every file created, modified or
+// deleted since the last refresh.
+func (s *scanner) changes(ctx context.Context) (map[string]*fileState, error) {
+	infos, err := s.stat(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]*fileState)
+	for rel, entry := range s.files {
+		if info, ok := infos[rel]; ok && entry.matches(info) {
+			continue
+		}
+		changed[rel] = &fileState{
+			existed:    true,
+			content:    entry.content,
+			mode:       entry.mode,
+			restorable: entry.content != nil,
+		}
+	}
+	for rel := range infos {
+		if _, ok := s.files[rel]; !ok {
+			changed[rel] = &fileState{restorable: true}
+		}
+	}
+	return changed, nil
+}
+
+// reset forgets everything, so the next refresh reads all files again.
+func (s *scanner) reset() {
+	s.files = make(map[string]*scanEntry)
+	s.cached = 0
+}
+
+// drop forgets one file.
+func (s *scanner) drop(rel string, entry *scanEntry) {
+	s.cached -= int64(len(entry.content))
+	delete(s.files, rel)
+}
+
+// matches reports whether the file still has the recorded metadata.
+func (e *scanEntry) matches(info fs.FileInfo) bool {
+	return e.size == info.Size() && e.modTime.Equal(info.ModTime()) && e.mode == info.Mode().Perm()
+}
+
+// BeforeCommand records the work directory so AfterCommand can find the
+// files a shell command changes. It does nothing outside a turn.
+func (m *Manager) BeforeCommand(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.current == nil {
+		return nil
+	}
+	return m.scan.refresh(ctx)
+}
+
+// AfterCommand tracks the files created, modified or deleted since
+// BeforeCommand and returns their paths. Files too large for the scanner
+// are tracked but cannot be restored.
+func (m *Manager) AfterCommand(ctx context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.current == nil {
+		return nil, nil
+	}
+	changed, err := m.scan.changes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := sortedPaths(changed)
+	for _, rel := range paths {
+		if _, tracked := m.current.files[rel]; !tracked {
+			m.current.files[rel] = changed[rel]
+		}
+	}
+	return paths, nil
+}
@@ -19,6 +19,11 @@ type Config struct {
 	Output  OutputConfig `yaml:"output" json:"output"`
 	WorkDir string       `yaml:"work_dir" json:"work_dir"`
 	Debug   bool         `yaml:"debug" json:"debug"`
+
+	Checkpoints CheckpointsConfig `yaml:"checkpoints" json:"checkpoints"`
+	Backups     BackupsConfig     `yaml:"backups" json:"backups"`
+	RepoMap     RepoMapConfig     `yaml:"repo_map" json:"repo_map"`
+	Attempt     AttemptConfig     `yaml:"attempt" json:"attempt"`
 }
 
 // ModelConfig contains LLM model configuration.
@@ -39,6 +44,12 @@ type ToolsConfig struct {
 	File    FileConfig   `yaml:"file" json:"file"`
 	Edit    EditConfig   `yaml:"edit" json:"edit"`
 	Search  SearchConfig `yaml:"search" json:"search"`
+	LSP     LSPConfig    `yaml:"lsp" json:"lsp"`
+	Git     GitConfig    `yaml:"git" json:"git"`
+	Web     WebConfig    `yaml:"web" json:"web"`
+
+	Semantic    SemanticConfig    `yaml:"semantic" json:"semantic"`
+	Diagnostics DiagnosticsConfig `yaml:"diagnostics" json:"diagnostics"`
 }
 
 // BashConfig contains bash tool configuration.
@@ -56,6 +67,7 @@ type FileConfig struct {
 	MaxListFiles      int      `yaml:"max_list_files" json:"max_list_files"`         // Maximum files to list
 	AllowedExtensions []string `yaml:"allowed_extensions" json:"allowed_extensions"` // If set, only these extensions allowed
 	BlockedPaths      []string `yaml:"blocked_paths" json:"blocked_paths"`           // Paths to block access to
+	RequireRead       bool     `yaml:"require_read" json:"require_read"`             // Refuse writes to files not read, or changed since
 }
 
 // EditConfig contains edit tool configuration.
@@ -75,6 +87,110 @@ type SearchConfig struct {
 	CaseSensitive   bool     `yaml:"case_sensitive" json:"case_sensitive"`     // Case sensitive search
 }
 
+// SemanticConfig contains the embedding backend of the semantic_search
+// tool.
+type SemanticConfig struct {
+	Provider   string `yaml:"provider" json:"provider"`       // "openai" (any OpenAI-compatible endpoint) or "local"
+	Model      string `yaml:"model" json:"model"`             // Embedding model
+	APIKey     string `yaml:"api_key" json:"api_key"`         // API key (can use ${ENV_VAR} syntax)
+	BaseURL    string `yaml:"base_url" json:"base_url"`       // Optional custom base URL
+	Dimensions int    `yaml:"dimensions" json:"dimensions"`   // Vector size; 0 uses the model's default
+	MaxResults int    `yaml:"max_results" json:"max_results"` // Chunks returned when the model does not ask for a number
+}
+
+// LSPConfig contains language server configuration for the lsp tools.
+type LSPConfig struct {
+	Servers map[string]LSPServerConfig `yaml:"servers" json:"servers"` // Language servers keyed by language
+}
+
+// LSPServerConfig describes how to start one language server.
+type LSPServerConfig struct {
+	Command               string                 `yaml:"command" json:"command"`                                         // Executable to run
+	Args                  []string               `yaml:"args" json:"args"`                                               // Command arguments
+	Env                   []string               `yaml:"env" json:"env"`                                                 // Extra environment, KEY=VALUE
+	Extensions            []string               `yaml:"extensions" json:"extensions"`                                   // File extensions handled
+	InitializationOptions map[string]interface{} `yaml:"initialization_options" json:"initialization_options,omitempty"` // Server-specific options
+}
+
+// GitConfig contains git tool configuration.
+type GitConfig struct {
+	AllowDestructive bool `yaml:"allow_destructive" json:"allow_destructive"` // Allow git operations that can lose work (force delete/reset, amend, stash drop), from the git tool and bash
+}
+
+// WebConfig contains the configuration of the web tools.
+type WebConfig struct {
+	MaxBytes             int      `yaml:"max_bytes" json:"max_bytes"`                           // Largest response body read
+	TimeoutMs            int      `yaml:"timeout_ms" json:"timeout_ms"`                         // Time limit of one request
+	MaxRedirects         int      `yaml:"max_redirects" json:"max_redirects"`                   // Redirects followed
+	AllowedDomains       []string `yaml:"allowed_domains" json:"allowed_domains"`               // Only these domains and their subdomains; all when empty
+	BlockedDomains       []string `yaml:"blocked_domains" json:"blocked_domains"`               // Never contacted, even if allowed
+	AllowPrivateNetworks bool     `yaml:"allow_private_networks" json:"allow_private_networks"` // Let web_fetch reach loopback, link-local and private addresses
+
+	Search WebSearchConfig `yaml:"search" json:"search"`
+}
+
+// WebSearchConfig contains the search backend of the web_search tool.
+type WebSearchConfig struct {
+	Backend    string            `yaml:"backend" json:"backend"`         // "searxng", "json" or "fixture"; web_search is registered only when set
+	Endpoint   string            `yaml:"endpoint" json:"endpoint"`       // URL of the search API (can use ${ENV_VAR} syntax)
+	QueryParam string            `yaml:"query_param" json:"query_param"` // Name of the query parameter
+	Params     map[string]string `yaml:"params" json:"params"`           // Extra query parameters sent with every search
+	Headers    map[string]string `yaml:"headers" json:"headers"`         // Request headers, e.g. an API key (can use ${ENV_VAR} syntax)
+	Fixture    string            `yaml:"fixture" json:"fixture"`         // JSON file of results for the fixture backend
+	MaxResults int               `yaml:"max_results" json:"max_results"` // Results returned when the model does not ask for a number
+}
+
+// DiagnosticsConfig contains the post-write diagnostics configuration.
+type DiagnosticsConfig struct {
+	Enabled   bool           `yaml:"enabled" json:"enabled"`       // Check files after each successful write
+	TimeoutMs int            `yaml:"timeout_ms" json:"timeout_ms"` // Time limit for one round of checks
+	Go        []string       `yaml:"go" json:"go"`                 // Built-in checks for .go files: "gofmt", "vet"
+	Linters   []LinterConfig `yaml:"linters" json:"linters"`       // Additional linters per file extension
+}
+
+// LinterConfig describes a user-configured linter. "{file}" in Args is
+// replaced by the written file's path; output lines must look like
+// "file:line[:col]: message".
+type LinterConfig struct {
+	Name       string   `yaml:"name" json:"name"`             // Label shown with diagnostics
+	Command    string   `yaml:"command" json:"command"`       // Executable to run
+	Args       []string `yaml:"args" json:"args"`             // Command arguments
+	Extensions []string `yaml:"extensions" json:"extensions"` // File extensions checked, e.g. ".py"
+	Severity   string   `yaml:"severity" json:"severity"`     // "error" (default) or "warning"
+}
+
+// CheckpointsConfig contains the per-turn checkpoint configuration.
+type CheckpointsConfig struct {
+	Enabled       bool `yaml:"enabled" json:"enabled"`               // Record the files each turn changes for /undo and /rewind
+	MaxTurns      int  `yaml:"max_turns" json:"max_turns"`           // Number of checkpoints kept
+	TrackCommands bool `yaml:"track_commands" json:"track_commands"` // Scan the work directory around bash calls
+	MaxFileSize   int  `yaml:"max_file_size" json:"max_file_size"`   // Largest file restorable after a bash change
+	GitHistory    bool `yaml:"git_history" json:"git_history"`       // Also commit each turn to refs/goai/<session> in git repositories
+}
+
+// BackupsConfig contains the retention policy of the backup store in
+// .goai/backups.
+type BackupsConfig struct {
+	MaxPerFile    int `yaml:"max_per_file" json:"max_per_file"`     // Backups kept per file
+	RetentionDays int `yaml:"retention_days" json:"retention_days"` // Backups older than this are removed
+}
+
+// RepoMapConfig contains the configuration of the ranked repository map
+// offered to prompt templates as {{ .RepoMap }}.
+type RepoMapConfig struct {
+	Enabled   bool `yaml:"enabled" json:"enabled"`       // Build the map when a template uses it
+	MaxTokens int  `yaml:"max_tokens" json:"max_tokens"` // Token budget of the map
+	MaxFiles  int  `yaml:"max_files" json:"max_files"`   // Source files considered, most recently modified first
+}
+
+// AttemptConfig contains the configuration of goai attempt, which runs
+// several agents on one task in separate git worktrees.
+type AttemptConfig struct {
+	Count           int    `yaml:"count" json:"count"`                         // Attempts run when -n is not given
+	Verify          string `yaml:"verify" json:"verify"`                       // Shell command run in every worktree, e.g. "go test ./..."
+	VerifyTimeoutMs int    `yaml:"verify_timeout_ms" json:"verify_timeout_ms"` // Time limit of the verification command
+}
+
 // TodoConfig contains todo management configuration.
 type TodoConfig struct {
 	MaxItems           int  `yaml:"max_items" json:"max_items"`                       // Maximum todo items
@@ -110,7 +226,7 @@ func DefaultConfig() *Config {
 			Timeout:   60,
 		},
 		Tools: ToolsConfig{
-			Enabled: []string{"bash", "file", "edit", "todo", "search"},
+			Enabled: []string{"bash", "file", "edit", "todo", "search", "code_intel"},
 			Bash: BashConfig{
 				TimeoutMs: 30000,
 				ForbiddenCommands: []string{
@@ -127,6 +243,7 @@ func DefaultConfig() *Config {
 			File: FileConfig{
 				MaxFileSize:  10 * 1024 * 1024, // 10MB
 				MaxListFiles: 1000,
+				RequireRead:  true,
 			},
 			Edit: EditConfig{
 				CreateBackup:    true,
@@ -148,6 +265,44 @@ func DefaultConfig() *Config {
 				SearchTypes:   []string{"code", "text"},
 				CaseSensitive: false,
 			},
+			Semantic: SemanticConfig{
+				Provider:   "openai",
+				Model:      "text-embedding-3-small",
+				APIKey:     "${OPENAI_API_KEY}",
+				MaxResults: 8,
+			},
+			Web: WebConfig{
+				MaxBytes:     5 * 1024 * 1024, // 5MB
+				TimeoutMs:    30000,
+				MaxRedirects: 5,
+				Search: WebSearchConfig{
+					QueryParam: "q",
+					MaxResults: 5,
+				},
+			},
+			Diagnostics: DiagnosticsConfig{
+				Enabled:   true,
+				TimeoutMs: 20000,
+				Go:        []string{"gofmt", "vet"},
+			},
+			LSP: LSPConfig{
+				Servers: map[string]LSPServerConfig{
+					"go": {
+						Command:    "gopls",
+						Extensions: []string{".go"},
+					},
+					"python": {
+						Command:    "pyright-langserver",
+						Args:       []string{"--stdio"},
+						Extensions: []string{".py", ".pyi"},
+					},
+					"typescript": {
+						Command:    "typescript-language-server",
+						Args:       []string{"--stdio"},
+						Extensions: []string{".ts", ".tsx", ".mts", ".cts", ".js", ".jsx", ".mjs", ".cjs"},
+					},
+				},
+			},
 		},
 		Todo: TodoConfig{
 			MaxItems:           20,
@@ -155,6 +310,26 @@ func DefaultConfig() *Config {
 			AutoCleanCompleted: false,
 			ShowProgress:       true,
 		},
+		Checkpoints: CheckpointsConfig{
+			Enabled:       true,
+			MaxTurns:      50,
+			TrackCommands: true,
+			MaxFileSize:   1024 * 1024, // 1MB
+			GitHistory:    true,
+		},
+		Backups: BackupsConfig{
+			MaxPerFile:    10,
+			RetentionDays: 7,
+		},
+		RepoMap: RepoMapConfig{
+			Enabled:   true,
+			MaxTokens: 1500,
+			MaxFiles:  5000,
+		},
+		Attempt: AttemptConfig{
+			Count:           3,
+			VerifyTimeoutMs: 600000, // 10 minutes
+		},
 		Output: OutputConfig{
 			MaxChars:           100000,
 			Format:             "markdown",
@@ -256,6 +431,12 @@ func (c *Config) expandEnvVars() {
 	// Expand API key
 	c.Model.APIKey = expandEnvVar(c.Model.APIKey)
 	c.Model.BaseURL = expandEnvVar(c.Model.BaseURL)
+	c.Tools.Semantic.APIKey = expandEnvVar(c.Tools.Semantic.APIKey)
+	c.Tools.Semantic.BaseURL = expandEnvVar(c.Tools.Semantic.BaseURL)
+	c.Tools.Web.Search.Endpoint = expandEnvVar(c.Tools.Web.Search.Endpoint)
+	for name, value := range c.Tools.Web.Search.Headers {
+		c.Tools.Web.Search.Headers[name] = expandEnvVar(value)
+	}
 
 	// Expand work directory
 	c.WorkDir = expandEnvVar(c.WorkDir)
@@ -331,6 +512,89 @@ func (c *Config) Validate() error {
 		c.Tools.File.MaxListFiles = 1000
 	}
 
+	// Validate semantic search configuration
+	if c.Tools.Semantic.Provider == "" {
+		c.Tools.Semantic.Provider = "openai"
+	}
+
+	if c.Tools.Semantic.MaxResults <= 0 {
+		c.Tools.Semantic.MaxResults = 8
+	}
+
+	// Validate web tool configuration
+	if c.Tools.Web.MaxBytes <= 0 {
+		c.Tools.Web.MaxBytes = 5 * 1024 * 1024
+	}
+
+	if c.Tools.Web.TimeoutMs <= 0 {
+		c.Tools.Web.TimeoutMs = 30000
+	}
+
+	if c.Tools.Web.MaxRedirects < 0 {
+		c.Tools.Web.MaxRedirects = 5
+	}
+
+	if c.Tools.Web.Search.QueryParam == "" {
+		c.Tools.Web.Search.QueryParam = "q"
+	}
+
+	if c.Tools.Web.Search.MaxResults <= 0 {
+		c.Tools.Web.Search.MaxResults = 5
+	}
+
+	// Validate diagnostics configuration
+	if c.Tools.Diagnostics.TimeoutMs <= 0 {
+		c.Tools.Diagnostics.TimeoutMs = 20000
+	}
+
+	for _, check := range c.Tools.Diagnostics.Go {
+		if check != "gofmt" && check != "vet" {
+			return fmt.Errorf("unknown diagnostics check for Go: %s", check)
+		}
+	}
+
+	for _, linter := range c.Tools.Diagnostics.Linters {
+		if linter.Command == "" || len(linter.Extensions) == 0 {
+			return fmt.Errorf("diagnostics linter %q needs a command and extensions", linter.Name)
+		}
+	}
+
+	// Validate checkpoint configuration
+	if c.Checkpoints.MaxTurns <= 0 {
+		c.Checkpoints.MaxTurns = 50
+	}
+
+	if c.Checkpoints.MaxFileSize <= 0 {
+		c.Checkpoints.MaxFileSize = 1024 * 1024
+	}
+
+	// Validate backup retention
+	if c.Backups.MaxPerFile <= 0 {
+		c.Backups.MaxPerFile = 10
+	}
+
+	if c.Backups.RetentionDays <= 0 {
+		c.Backups.RetentionDays = 7
+	}
+
+	// Validate repository map budget
+	if c.RepoMap.MaxTokens <= 0 {
+		c.RepoMap.MaxTokens = 1500
+	}
+
+	if c.RepoMap.MaxFiles <= 0 {
+		c.RepoMap.MaxFiles = 5000
+	}
+
+	// Validate attempt configuration
+	if c.Attempt.Count <= 0 {
+		c.Attempt.Count = 3
+	}
+
+	if c.Attempt.VerifyTimeoutMs <= 0 {
+		c.Attempt.VerifyTimeoutMs = 600000
+	}
+
 	// Validate todo configuration
 	if c.Todo.MaxItems <= 0 {
 		c.Todo.MaxItems = 20
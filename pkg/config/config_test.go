@@ -24,14 +24,53 @@ func TestDefaultConfig(t *testing.T) {
 	}
 
 	// Test tools defaults
-	if len(cfg.Tools.Enabled) != 5 {
-		t.Errorf("Default enabled tools count = %d, want 5", len(cfg.Tools.Enabled))
+	if len(cfg.Tools.Enabled) != 6 {
+		t.Errorf("Default enabled tools count = %d, want 6", len(cfg.Tools.Enabled))
 	}
 
 	if cfg.Tools.Bash.TimeoutMs != 30000 {
 		t.Errorf("Default bash timeout = %d, want 30000", cfg.Tools.Bash.TimeoutMs)
 	}
 
+	if !cfg.Tools.File.RequireRead {
+		t.Error("Default require_read = false, want true")
+	}
+
+	if cfg.Tools.Git.AllowDestructive {
+		t.Error("Default git allow_destructive = true, want false")
+	}
+
+	if cfg.Tools.Web.MaxBytes != 5*1024*1024 || cfg.Tools.Web.MaxRedirects != 5 || len(cfg.Tools.Web.AllowedDomains) != 0 {
+		t.Errorf("Default web = %+v, want 5MB, 5 redirects and no domain restriction", cfg.Tools.Web)
+	}
+
+	if cfg.Tools.Web.Search.Backend != "" || cfg.Tools.Web.Search.MaxResults != 5 {
+		t.Errorf("Default web search = %+v, want no backend and 5 results", cfg.Tools.Web.Search)
+	}
+
+	if !cfg.Tools.Diagnostics.Enabled || len(cfg.Tools.Diagnostics.Go) != 2 {
+		t.Errorf("Default diagnostics = %+v, want enabled with gofmt and vet", cfg.Tools.Diagnostics)
+	}
+
+	if cfg.Tools.Semantic.Provider != "openai" || cfg.Tools.Semantic.Model != "text-embedding-3-small" || cfg.Tools.Semantic.MaxResults != 8 {
+		t.Errorf("Default semantic search = %+v, want openai text-embedding-3-small with 8 results", cfg.Tools.Semantic)
+	}
+
+	if !cfg.Checkpoints.Enabled || !cfg.Checkpoints.TrackCommands || cfg.Checkpoints.MaxTurns != 50 || !cfg.Checkpoints.GitHistory {
+		t.Errorf("Default checkpoints = %+v, want enabled with command tracking, git history and 50 turns", cfg.Checkpoints)
+	}
+	if cfg.Backups.MaxPerFile != 10 || cfg.Backups.RetentionDays != 7 {
+		t.Errorf("Default backups = %+v, want 10 per file for 7 days", cfg.Backups)
+	}
+
+	if !cfg.RepoMap.Enabled || cfg.RepoMap.MaxTokens != 1500 {
+		t.Errorf("Default repo map = %+v, want enabled with 1500 tokens", cfg.RepoMap)
+	}
+
+	if cfg.Attempt.Count != 3 || cfg.Attempt.Verify != "" {
+		t.Errorf("Default attempt = %+v, want 3 attempts without verification", cfg.Attempt)
+	}
+
 	// Test todo defaults
 	if cfg.Todo.MaxItems != 20 {
 		t.Errorf("Default max todo items = %d, want 20", cfg.Todo.MaxItems)
@@ -89,6 +128,23 @@ func TestConfig_Validate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "model name is required",
 		},
+		{
+			name: "unknown go diagnostics check",
+			config: &Config{
+				Model: ModelConfig{
+					Provider: "openai",
+					Name:     "gpt-4",
+					APIKey:   "test-key",
+				},
+				Tools: ToolsConfig{
+					Enabled:     []string{"bash"},
+					Diagnostics: DiagnosticsConfig{Go: []string{"gofmt", "staticcheck"}},
+				},
+				WorkDir: ".",
+			},
+			wantErr: true,
+			errMsg:  "unknown diagnostics check for Go: staticcheck",
+		},
 		{
 			name: "no tools enabled",
 			config: &Config{
@@ -0,0 +1,235 @@
+package diagnostics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GofmtChecker reports Go syntax errors and files that are not
+// gofmt-formatted.
+type GofmtChecker struct{}
+
+// Name returns the name of the checker.
+func (GofmtChecker) Name() string {
+	return "gofmt"
+}
+
+// Check parses and formats each file in memory.
+func (GofmtChecker) Check(ctx context.Context, workDir string, files []string) ([]Diagnostic, error) {
+	var diags []Diagnostic
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		fset := token.NewFileSet()
+		_, err = parser.ParseFile(fset, file, src, 0)
+		var list scanner.ErrorList
+		if errors.As(err, &list) {
+			for _, e := range list {
+				diags = append(diags, Diagnostic{
+					File:     file,
+					Line:     e.Pos.Line,
+					Column:   e.Pos.Column,
+					Severity: SeverityError,
+					Source:   "gofmt",
+					Message:  e.Msg,
+				})
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if formatted, err := format.Source(src); err == nil && !bytes.Equal(formatted, src) {
+			diags = append(diags, Diagnostic{
+				File:     file,
+				Line:     1,
+				Column:   1,
+				Severity: SeverityWarning,
+				Source:   "gofmt",
+				Message:  "file is not gofmt-formatted",
+			})
+		}
+	}
+	return diags, nil
+}
+
+// VetChecker runs "go vet" on the packages containing the files. Type
+// errors that stop vet are reported too.
+type VetChecker struct{}
+
+// Name returns the name of the checker.
+func (VetChecker) Name() string {
+	return "vet"
+}
+
+// Check runs go vet once per package directory.
+func (VetChecker) Check(ctx context.Context, workDir string, files []string) ([]Diagnostic, error) {
+	dirs := make(map[string]bool)
+	for _, file := range files {
+		dirs[filepath.Dir(file)] = true
+	}
+
+	var diags []Diagnostic
+	for _, dir := range sortedKeys(dirs) {
+		cmd := exec.CommandContext(ctx, "go", "vet", ".")
+		cmd.Dir = dir
+		// Never touch the network, like the code_intel loader
+		cmd.Env = append(os.Environ(), "GOPROXY=off")
+		out, err := cmd.CombinedOutput()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		found := parseOutput(string(out), dir, "vet", SeverityError)
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("go vet failed: %w", err)
+		}
+		if err != nil && len(found) == 0 {
+			return nil, fmt.Errorf("go vet failed: %s", firstLine(string(out)))
+		}
+		diags = append(diags, found...)
+	}
+	return diags, nil
+}
+
+// CommandChecker runs a user-configured linter. "{file}" in the arguments
+// is replaced by the file path relative to the work directory; without a
+// placeholder the paths are appended. Output lines of the form
+// "file:line[:col]: message" become diagnostics.
+type CommandChecker struct {
+	Label    string
+	Command  string
+	Args     []string
+	Severity string
+}
+
+// Name returns the name of the checker.
+func (c *CommandChecker) Name() string {
+	if c.Label != "" {
+		return c.Label
+	}
+	return filepath.Base(c.Command)
+}
+
+// Check runs the linter once per file.
+func (c *CommandChecker) Check(ctx context.Context, workDir string, files []string) ([]Diagnostic, error) {
+	severity := c.Severity
+	if severity == "" {
+		severity = SeverityError
+	}
+
+	var diags []Diagnostic
+	for _, file := range files {
+		rel, err := filepath.Rel(workDir, file)
+		if err != nil {
+			rel = file
+		}
+		args := make([]string, 0, len(c.Args)+1)
+		replaced := false
+		for _, arg := range c.Args {
+			if strings.Contains(arg, "{file}") {
+				arg = strings.ReplaceAll(arg, "{file}", rel)
+				replaced = true
+			}
+			args = append(args, arg)
+		}
+		if !replaced {
+			args = append(args, rel)
+		}
+
+		cmd := exec.CommandContext(ctx, c.Command, args...)
+		cmd.Dir = workDir
+		out, err := cmd.CombinedOutput()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// Linters exit non-zero when they find problems
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("%s failed: %w", c.Name(), err)
+		}
+		diags = append(diags, parseOutput(string(out), workDir, c.Name(), severity)...)
+	}
+	return diags, nil
+}
+
+// outputLine matches "file:line:col: message" and "file:line: message",
+// optionally prefixed by "vet: ".
+var outputLine = regexp.MustCompile(`^(?:vet: )?([^\s:][^:]*):(\d+)(?::(\d+))?:\s*(.+)$`)
+
+// parseOutput extracts diagnostics from compiler-style output. Relative
+// paths are resolved against dir.
+func parseOutput(out, dir, source, severity string) []Diagnostic {
+	var diags []Diagnostic
+	for _, line := range strings.Split(out, "\n") {
+		m := outputLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		file := m[1]
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		lineNo, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3])
+		diags = append(diags, Diagnostic{
+			File:     filepath.Clean(file),
+			Line:     lineNo,
+			Column:   col,
+			Severity: severity,
+			Source:   source,
+			Message:  m[4],
+		})
+	}
+	return diags
+}
+
+// firstLine returns the first non-empty line of s.
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return "no output"
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Builtin returns the built-in checker with the given name: "gofmt" or
+// "vet".
+func Builtin(name string) (Checker, error) {
+	switch name {
+	case "gofmt":
+		return GofmtChecker{}, nil
+	case "vet":
+		return VetChecker{}, nil
+	}
+	return nil, fmt.Errorf("unknown diagnostics check: %s", name)
+}
@@ -0,0 +1,285 @@
+// Package diagnostics checks files after the agent writes them.
+//
+// A Pipeline maps file extensions to checkers (gofmt, go vet, or any
+// user-configured linter). Results for a file's content before a write are
+// kept as a baseline, so only problems the write introduced are reported.
+package diagnostics
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Severity levels.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Diagnostic is a problem found in a file.
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"`
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+// String formats the diagnostic as "file:line:col: severity: message
+// (source)".
+func (d Diagnostic) String() string {
+	pos := fmt.Sprintf("%s:%d", d.File, d.Line)
+	if d.Column > 0 {
+		pos = fmt.Sprintf("%s:%d", pos, d.Column)
+	}
+	return fmt.Sprintf("%s: %s: %s (%s)", pos, d.Severity, d.Message, d.Source)
+}
+
+// Checker checks a batch of files. Files are absolute paths; the returned
+// diagnostics may cover other files, which the pipeline ignores.
+type Checker interface {
+	Name() string
+	Check(ctx context.Context, workDir string, files []string) ([]Diagnostic, error)
+}
+
+// Report is the outcome of checking the files touched by a write. Paths
+// are relative to the work directory.
+type Report struct {
+	New    []Diagnostic `json:"new"`              // Problems introduced by the write
+	Errors []string     `json:"errors,omitempty"` // Checkers that could not run
+}
+
+// snapshot is the diagnostics for one version of a file.
+type snapshot struct {
+	hash  [sha256.Size]byte
+	diags []Diagnostic
+}
+
+// Pipeline runs the configured checkers for written files.
+type Pipeline struct {
+	workDir string
+	timeout time.Duration
+	checks  map[string][]Checker // by lower-case extension
+
+	mu        sync.Mutex
+	snapshots map[string]snapshot
+}
+
+// NewPipeline creates an empty pipeline for workDir. A zero timeout means
+// 30 seconds.
+func NewPipeline(workDir string, timeout time.Duration) *Pipeline {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &Pipeline{
+		workDir:   workDir,
+		timeout:   timeout,
+		checks:    make(map[string][]Checker),
+		snapshots: make(map[string]snapshot),
+	}
+}
+
+// Add registers checker for files with the given extensions (e.g. ".go").
+func (p *Pipeline) Add(checker Checker, extensions ...string) {
+	for _, ext := range extensions {
+		ext = strings.ToLower(ext)
+		p.checks[ext] = append(p.checks[ext], checker)
+	}
+}
+
+// Empty reports whether no checker is configured.
+func (p *Pipeline) Empty() bool {
+	return len(p.checks) == 0
+}
+
+// Handles reports whether any checker is configured for path.
+func (p *Pipeline) Handles(path string) bool {
+	return len(p.checks[strings.ToLower(filepath.Ext(path))]) > 0
+}
+
+// Baseline records the current diagnostics of the files that are about to
+// be written. Files whose content has not changed since the last check
+// reuse the recorded result.
+func (p *Pipeline) Baseline(ctx context.Context, paths []string) {
+	var stale []string
+	for _, path := range p.filter(paths) {
+		hash, ok := fileHash(path)
+		if !ok {
+			continue // New file: everything found later is new
+		}
+		p.mu.Lock()
+		snap, cached := p.snapshots[path]
+		p.mu.Unlock()
+		if !cached || snap.hash != hash {
+			stale = append(stale, path)
+		}
+	}
+	if len(stale) > 0 {
+		// Errors only weaken the baseline; Check reports them
+		p.run(ctx, stale)
+	}
+}
+
+// Check runs the checkers on the written files and returns the problems
+// that were not present in the baseline.
+func (p *Pipeline) Check(ctx context.Context, paths []string) Report {
+	var existing []string
+	for _, path := range p.filter(paths) {
+		if _, ok := fileHash(path); ok {
+			existing = append(existing, path) // Deleted files have nothing to check
+		}
+	}
+	paths = existing
+	report := Report{New: []Diagnostic{}}
+	if len(paths) == 0 {
+		return report
+	}
+
+	p.mu.Lock()
+	before := make(map[string][]Diagnostic)
+	for _, path := range paths {
+		before[path] = p.snapshots[path].diags
+	}
+	p.mu.Unlock()
+
+	current, errs := p.run(ctx, paths)
+	report.Errors = errs
+	for _, path := range paths {
+		for _, d := range newDiagnostics(before[path], current[path]) {
+			if rel, err := filepath.Rel(p.workDir, d.File); err == nil && !strings.HasPrefix(rel, "..") {
+				d.File = filepath.ToSlash(rel)
+			}
+			report.New = append(report.New, d)
+		}
+	}
+	return report
+}
+
+// run checks paths, records snapshots and returns the diagnostics per
+// file.
+func (p *Pipeline) run(ctx context.Context, paths []string) (map[string][]Diagnostic, []string) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	// Group files by checker so package-level checkers run once
+	groups := make(map[Checker][]string)
+	var order []Checker
+	for _, path := range paths {
+		for _, c := range p.checks[strings.ToLower(filepath.Ext(path))] {
+			if _, seen := groups[c]; !seen {
+				order = append(order, c)
+			}
+			groups[c] = append(groups[c], path)
+		}
+	}
+
+	hashes := make(map[string][sha256.Size]byte)
+	for _, path := range paths {
+		hashes[path], _ = fileHash(path)
+	}
+
+	result := make(map[string][]Diagnostic)
+	failed := make(map[string]bool)
+	var errs []string
+	for _, c := range order {
+		files := groups[c]
+		diags, err := c.Check(ctx, p.workDir, files)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", c.Name(), err))
+			for _, f := range files {
+				failed[f] = true
+			}
+			continue
+		}
+		wanted := make(map[string]bool, len(files))
+		for _, f := range files {
+			wanted[f] = true
+		}
+		for _, d := range diags {
+			if wanted[d.File] {
+				result[d.File] = append(result[d.File], d)
+			}
+		}
+	}
+
+	p.mu.Lock()
+	for _, path := range paths {
+		if failed[path] {
+			// An incomplete result must not become the next baseline
+			delete(p.snapshots, path)
+			continue
+		}
+		sortDiagnostics(result[path])
+		p.snapshots[path] = snapshot{hash: hashes[path], diags: result[path]}
+	}
+	p.mu.Unlock()
+
+	return result, errs
+}
+
+// filter returns the absolute, de-duplicated paths with checkers.
+func (p *Pipeline) filter(paths []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(p.workDir, path)
+		}
+		path = filepath.Clean(path)
+		if !seen[path] && p.Handles(path) {
+			seen[path] = true
+			out = append(out, path)
+		}
+	}
+	return out
+}
+
+// newDiagnostics returns the diagnostics in after that are not in before.
+// Positions are ignored when comparing, since edits shift lines.
+func newDiagnostics(before, after []Diagnostic) []Diagnostic {
+	counts := make(map[string]int)
+	for _, d := range before {
+		counts[d.Source+"\x00"+d.Message]++
+	}
+	var out []Diagnostic
+	for _, d := range after {
+		key := d.Source + "\x00" + d.Message
+		if counts[key] > 0 {
+			counts[key]--
+			continue
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// sortDiagnostics orders diagnostics by position.
+func sortDiagnostics(diags []Diagnostic) {
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i], diags[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// fileHash returns the content hash of path.
+func fileHash(path string) ([sha256.Size]byte, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}, false
+	}
+	return sha256.Sum256(data), true
+}
@@ -0,0 +1,137 @@
+package diagnostics
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Zerofisher/goai/internal/testutil"
+)
+
+// TestGofmtChecker tests syntax and formatting checks
+func TestGofmtChecker(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		content  string
+		want     int
+		severity string
+	}{
+		{"formatted", "package a\n\nfunc F() {}\n", 0, ""},
+		{"unformatted", "package a\nfunc F()  {}\n", 1, SeverityWarning},
+		{"syntax error", "package a\n\nfunc F() {\n", 1, SeverityError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := testutil.WriteFile(t, dir, "a.go", tt.content)
+			diags, err := GofmtChecker{}.Check(context.Background(), dir, []string{path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(diags) != tt.want {
+				t.Fatalf("got %d diagnostics, want %d: %+v", len(diags), tt.want, diags)
+			}
+			if tt.want > 0 && diags[0].Severity != tt.severity {
+				t.Errorf("severity = %s, want %s", diags[0].Severity, tt.severity)
+			}
+		})
+	}
+}
+
+// TestVetChecker tests go vet and type errors on a temporary module
+func TestVetChecker(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFile(t, dir, "go.mod", "module example.com/vet\n\ngo 1.21\n")
+	main := testutil.WriteFile(t, dir, "main.go", "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Printf(\"%d\\n\", \"x\")\n}\n")
+
+	diags, err := VetChecker{}.Check(context.Background(), dir, []string{main})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 || diags[0].File != main || diags[0].Line != 6 || !strings.Contains(diags[0].Message, "Printf") {
+		t.Fatalf("unexpected vet diagnostics: %+v", diags)
+	}
+
+	testutil.WriteFile(t, dir, "main.go", "package main\n\nfunc main() {\n\tx := 1\n}\n")
+	diags, err = VetChecker{}.Check(context.Background(), dir, []string{main})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 || diags[0].Line != 4 || !strings.Contains(diags[0].Message, "declared and not used") {
+		t.Fatalf("expected type error, got %+v", diags)
+	}
+}
+
+// TestCommandChecker tests running a user-configured linter
+func TestCommandChecker(t *testing.T) {
+	dir := t.TempDir()
+	path := testutil.WriteFile(t, dir, "src/app.py", "print(1)\n")
+
+	checker := &CommandChecker{
+		Label:    "fake",
+		Command:  "sh",
+		Args:     []string{"-c", `echo "{file}:1:7: E999 fake problem"; echo "unrelated output"; exit 1`},
+		Severity: SeverityWarning,
+	}
+	diags, err := checker.Check(context.Background(), dir, []string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %+v", diags)
+	}
+	d := diags[0]
+	if d.File != path || d.Line != 1 || d.Column != 7 || d.Source != "fake" || d.Severity != SeverityWarning {
+		t.Errorf("unexpected diagnostic: %+v", d)
+	}
+}
+
+// TestPipelineReportsOnlyNewProblems tests baseline comparison
+func TestPipelineReportsOnlyNewProblems(t *testing.T) {
+	dir := t.TempDir()
+	path := testutil.WriteFile(t, dir, "a.go", "package a\nfunc F()  {}\n") // unformatted
+
+	p := NewPipeline(dir, 0)
+	p.Add(GofmtChecker{}, ".go")
+	ctx := context.Background()
+
+	// Editing an already unformatted file reports nothing new
+	p.Baseline(ctx, []string{"a.go"})
+	testutil.WriteFile(t, dir, "a.go", "package a\nfunc G()  {}\n")
+	if report := p.Check(ctx, []string{"a.go"}); len(report.New) != 0 {
+		t.Errorf("expected no new problems, got %+v", report.New)
+	}
+
+	// A syntax error is new
+	p.Baseline(ctx, []string{path})
+	testutil.WriteFile(t, dir, "a.go", "package a\nfunc G( {}\n")
+	report := p.Check(ctx, []string{path})
+	if len(report.New) == 0 || report.New[0].File != "a.go" || report.New[0].Severity != SeverityError {
+		t.Errorf("expected a new syntax error, got %+v", report.New)
+	}
+
+	// New files have no baseline; unhandled extensions are ignored
+	testutil.WriteFile(t, dir, "b.go", "package a\nvar  x = 1\n")
+	testutil.WriteFile(t, dir, "notes.txt", "hello")
+	report = p.Check(ctx, []string{"b.go", "notes.txt"})
+	if len(report.New) != 1 || report.New[0].File != "b.go" {
+		t.Errorf("unexpected report for new file: %+v", report.New)
+	}
+}
+
+// TestPipelineCheckerErrors tests that failing checkers are reported
+func TestPipelineCheckerErrors(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFile(t, dir, "a.py", "x = 1\n")
+
+	p := NewPipeline(dir, 0)
+	p.Add(&CommandChecker{Command: filepath.Join(dir, "missing-linter")}, ".py")
+
+	report := p.Check(context.Background(), []string{"a.py"})
+	if len(report.Errors) != 1 || !strings.Contains(report.Errors[0], "missing-linter") {
+		t.Errorf("expected checker error, got %+v", report)
+	}
+}
@@ -0,0 +1,42 @@
+package dispatcher
+
+import (
+	"context"
+
+	"github.com/Zerofisher/goai/pkg/checkpoint"
+	"github.com/Zerofisher/goai/pkg/types"
+)
+
+// CheckpointMiddleware creates a middleware that records the files a tool
+// use is about to change in the current turn's checkpoint. Writes are
+// found with targets; when scanCommands is set, the work directory is
+// scanned around bash calls and git checkouts to find the files a command
+// changed.
+func CheckpointMiddleware(manager *checkpoint.Manager, targets WriteTargets, scanCommands bool) Middleware {
+	if targets == nil {
+		targets = DefaultWriteTargets
+	}
+
+	return func(ctx context.Context, tu types.ToolUse, next ExecuteFunc) types.ToolResult {
+		if manager == nil {
+			return next(ctx, tu)
+		}
+
+		if paths := targets(tu); len(paths) > 0 {
+			manager.Track(paths...)
+			return next(ctx, tu)
+		}
+
+		if scanCommands && runsCommand(tu) {
+			// A failed scan only costs the ability to undo this command
+			if err := manager.BeforeCommand(ctx); err != nil {
+				return next(ctx, tu)
+			}
+			result := next(ctx, tu)
+			_, _ = manager.AfterCommand(ctx)
+			return result
+		}
+
+		return next(ctx, tu)
+	}
+}
@@ -0,0 +1,105 @@
+package dispatcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Zerofisher/goai/pkg/checkpoint"
+	"github.com/Zerofisher/goai/pkg/tools/bash"
+	"github.com/Zerofisher/goai/pkg/types"
+)
+
+// TestCheckpointMiddleware tests that writes and commands are recorded in the turn
+func TestCheckpointMiddleware(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("main.go", "package main\n")
+
+	manager := checkpoint.NewManager(dir, checkpoint.Options{})
+	middleware := CheckpointMiddleware(manager, nil, true)
+	run := func(tu types.ToolUse, effect func()) {
+		middleware(context.Background(), tu, func(_ context.Context, tu types.ToolUse) types.ToolResult {
+			effect()
+			return types.ToolResult{ToolUseID: tu.ID, Content: `{"ok":true}`}
+		})
+	}
+
+	manager.Begin("edit and generate")
+	run(types.ToolUse{ID: "w1", Name: "write_file", Input: map[string]interface{}{"path": "main.go"}}, func() {
+		write("main.go", "package main\n\nfunc main() {}\n")
+	})
+	run(types.ToolUse{ID: "b1", Name: "bash", Input: map[string]interface{}{"command": "go generate"}}, func() {
+		write("gen.go", "package main\n")
+	})
+	run(types.ToolUse{ID: "g1", Name: "git", Input: map[string]interface{}{"action": "stash", "operation": "pop"}}, func() {
+		write("popped.go", "package main\n")
+	})
+	run(types.ToolUse{ID: "r1", Name: "read_file", Input: map[string]interface{}{"path": "main.go"}}, func() {})
+	cp := manager.End()
+
+	if cp == nil || len(cp.Changes) != 3 {
+		t.Fatalf("expected 3 changes, got %+v", cp)
+	}
+	if cp.Changes[0].Path != "gen.go" || cp.Changes[0].Status != checkpoint.StatusCreated {
+		t.Errorf("unexpected change: %+v", cp.Changes[0])
+	}
+	if cp.Changes[1].Path != "main.go" || cp.Changes[1].Status != checkpoint.StatusModified {
+		t.Errorf("unexpected change: %+v", cp.Changes[1])
+	}
+	if cp.Changes[2].Path != "popped.go" || cp.Changes[2].Status != checkpoint.StatusCreated {
+		t.Errorf("unexpected change: %+v", cp.Changes[2])
+	}
+
+	if _, err := manager.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "main.go")); string(data) != "package main\n" {
+		t.Errorf("main.go not restored: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gen.go")); !os.IsNotExist(err) {
+		t.Error("gen.go should have been removed")
+	}
+}
+
+// TestCheckpointMiddlewareDotFiles tests that dot files written by a bash
+// command are undone
+func TestCheckpointMiddlewareDotFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".envrc"), []byte("export A=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manager := checkpoint.NewManager(dir, checkpoint.Options{})
+	middleware := CheckpointMiddleware(manager, nil, true)
+	tool := bash.NewBashTool(dir, 10*time.Second)
+	ctx := context.Background()
+
+	manager.Begin("write env files")
+	tu := types.ToolUse{ID: "b1", Name: "bash", Input: map[string]interface{}{"command": "echo x > .env && echo 'export A=2' > .envrc"}}
+	result := middleware(ctx, tu, func(ctx context.Context, tu types.ToolUse) types.ToolResult {
+		out, err := tool.Execute(ctx, tu.Input)
+		return types.ToolResult{ToolUseID: tu.ID, Content: out, IsError: err != nil}
+	})
+	if result.IsError {
+		t.Fatalf("bash failed: %s", result.Content)
+	}
+	if cp := manager.End(); cp == nil || len(cp.Changes) != 2 {
+		t.Fatalf("expected .env and .envrc in the checkpoint, got %+v", cp)
+	}
+
+	if _, err := manager.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".env")); !os.IsNotExist(err) {
+		t.Error(".env should have been removed")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, ".envrc")); string(data) != "export A=1\n" {
+		t.Errorf(".envrc not restored: %q", data)
+	}
+}
@@ -0,0 +1,156 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Zerofisher/goai/pkg/diagnostics"
+	"github.com/Zerofisher/goai/pkg/patch"
+	"github.com/Zerofisher/goai/pkg/types"
+)
+
+// WriteTargets returns the files a tool use writes, or nil if it writes
+// none.
+type WriteTargets func(tu types.ToolUse) []string
+
+// DefaultWriteTargets knows the built-in file-writing tools.
+func DefaultWriteTargets(tu types.ToolUse) []string {
+	switch tu.Name {
+	case "write_file", "edit_file":
+		if path, ok := tu.Input["path"].(string); ok && path != "" {
+			return []string{path}
+		}
+	case "multi_edit":
+		edits, _ := tu.Input["edits"].([]interface{})
+		var paths []string
+		seen := make(map[string]bool)
+		for _, e := range edits {
+			edit, _ := e.(map[string]interface{})
+			if path, ok := edit["path"].(string); ok && path != "" && !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+		return paths
+	case "apply_patch":
+		text, _ := tu.Input["patch"].(string)
+		return patch.Paths(text)
+	}
+	return nil
+}
+
+// runsCommand reports whether tu may change files in the work tree without
+// naming them: bash commands, and git operations that check out or stash
+// files.
+func runsCommand(tu types.ToolUse) bool {
+	switch tu.Name {
+	case "bash":
+		return true
+	case "git":
+		action, _ := tu.Input["action"].(string)
+		operation, _ := tu.Input["operation"].(string)
+		switch action {
+		case "branch":
+			return operation == "switch"
+		case "stash":
+			return operation == "push" || operation == "pop" || operation == "apply"
+		}
+	}
+	return false
+}
+
+// DiagnosticsMiddleware creates a middleware that checks files after a
+// successful write. Problems the write introduced are attached to the tool
+// response as a "diagnostics" field and mentioned in its summary, so the
+// model can fix them in the same turn.
+func DiagnosticsMiddleware(pipeline *diagnostics.Pipeline, targets WriteTargets) Middleware {
+	if targets == nil {
+		targets = DefaultWriteTargets
+	}
+
+	return func(ctx context.Context, tu types.ToolUse, next ExecuteFunc) types.ToolResult {
+		paths := targets(tu)
+		if pipeline == nil || len(paths) == 0 {
+			return next(ctx, tu)
+		}
+
+		pipeline.Baseline(ctx, paths)
+		result := next(ctx, tu)
+		if result.IsError || !responseOk(result.Content) {
+			return result
+		}
+
+		report := pipeline.Check(ctx, paths)
+		if len(report.New) == 0 && len(report.Errors) == 0 {
+			return result
+		}
+		result.Content = attachDiagnostics(result.Content, report)
+		return result
+	}
+}
+
+// responseOk reports whether content is a standardized response with
+// "ok": true. Plain-text results count as successful.
+func responseOk(content string) bool {
+	if !strings.HasPrefix(strings.TrimSpace(content), "{") {
+		return true
+	}
+	var resp struct {
+		Ok *bool `json:"ok"`
+	}
+	if err := json.Unmarshal([]byte(content), &resp); err != nil || resp.Ok == nil {
+		return true
+	}
+	return *resp.Ok
+}
+
+// attachDiagnostics adds the report to a tool response.
+func attachDiagnostics(content string, report diagnostics.Report) string {
+	note := diagnosticsNote(report)
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &resp); err != nil {
+		lines := []string{content, "", note}
+		for _, d := range report.New {
+			lines = append(lines, "  "+d.String())
+		}
+		for _, e := range report.Errors {
+			lines = append(lines, "  check failed: "+e)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	if summary, ok := resp["summary"].(string); ok && summary != "" {
+		resp["summary"] = summary + "; " + note
+	} else {
+		resp["summary"] = note
+	}
+	resp["diagnostics"] = report
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return content
+	}
+	return string(data)
+}
+
+// diagnosticsNote summarizes a report in one sentence.
+func diagnosticsNote(report diagnostics.Report) string {
+	errorCount := 0
+	for _, d := range report.New {
+		if d.Severity == diagnostics.SeverityError {
+			errorCount++
+		}
+	}
+
+	var parts []string
+	if len(report.New) > 0 {
+		parts = append(parts, fmt.Sprintf("%d new problem(s) in written files (%d error(s)), see diagnostics", len(report.New), errorCount))
+	}
+	if len(report.Errors) > 0 {
+		parts = append(parts, fmt.Sprintf("%d check(s) could not run", len(report.Errors)))
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,92 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Zerofisher/goai/pkg/diagnostics"
+	"github.com/Zerofisher/goai/pkg/types"
+)
+
+// TestDiagnosticsMiddleware tests that new problems are attached to write results
+func TestDiagnosticsMiddleware(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pipeline := diagnostics.NewPipeline(dir, 0)
+	pipeline.Add(diagnostics.GofmtChecker{}, ".go")
+	middleware := DiagnosticsMiddleware(pipeline, nil)
+
+	// write simulates write_file replacing the file content
+	write := func(content, response string) func(context.Context, types.ToolUse) types.ToolResult {
+		return func(_ context.Context, tu types.ToolUse) types.ToolResult {
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			return types.ToolResult{ToolUseID: tu.ID, Content: response}
+		}
+	}
+	tu := types.ToolUse{ID: "w1", Name: "write_file", Input: map[string]interface{}{"path": "main.go"}}
+	okResponse := `{"ok":true,"summary":"Wrote main.go"}`
+
+	t.Run("CleanWrite", func(t *testing.T) {
+		result := middleware(context.Background(), tu, write("package main\n\nfunc main() {}\n", okResponse))
+		if result.Content != okResponse {
+			t.Errorf("clean write should not change the result, got %s", result.Content)
+		}
+	})
+
+	t.Run("BrokenWrite", func(t *testing.T) {
+		result := middleware(context.Background(), tu, write("package main\n\nfunc main() {\n", okResponse))
+
+		var resp struct {
+			Ok          bool               `json:"ok"`
+			Summary     string             `json:"summary"`
+			Diagnostics diagnostics.Report `json:"diagnostics"`
+		}
+		if err := json.Unmarshal([]byte(result.Content), &resp); err != nil {
+			t.Fatalf("invalid JSON: %v\n%s", err, result.Content)
+		}
+		if !resp.Ok || !strings.Contains(resp.Summary, "1 new problem(s)") {
+			t.Errorf("unexpected summary: %s", resp.Summary)
+		}
+		if len(resp.Diagnostics.New) != 1 || resp.Diagnostics.New[0].File != "main.go" || resp.Diagnostics.New[0].Line != 3 {
+			t.Errorf("unexpected diagnostics: %+v", resp.Diagnostics.New)
+		}
+	})
+
+	t.Run("ExistingProblemNotRepeated", func(t *testing.T) {
+		// The file is still broken, but the write added nothing new
+		result := middleware(context.Background(), tu, write("package main\n\n// comment\nfunc main() {\n", okResponse))
+		if result.Content != okResponse {
+			t.Errorf("expected no new diagnostics, got %s", result.Content)
+		}
+	})
+
+	t.Run("FailedWriteSkipped", func(t *testing.T) {
+		failed := `{"ok":false,"summary":"Write failed","error":"denied"}`
+		result := middleware(context.Background(), tu, write("package main\nfunc (\n", failed))
+		if result.Content != failed {
+			t.Errorf("failed writes must not be checked, got %s", result.Content)
+		}
+	})
+
+	t.Run("OtherToolsSkipped", func(t *testing.T) {
+		read := types.ToolUse{ID: "r1", Name: "read_file", Input: map[string]interface{}{"path": "main.go"}}
+		called := false
+		result := middleware(context.Background(), read, func(_ context.Context, tu types.ToolUse) types.ToolResult {
+			called = true
+			return types.ToolResult{ToolUseID: tu.ID, Content: okResponse}
+		})
+		if !called || result.Content != okResponse {
+			t.Errorf("unexpected result for read_file: %s", result.Content)
+		}
+	})
+}
@@ -2,7 +2,9 @@ package dispatcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
@@ -18,6 +20,7 @@ type Dispatcher struct {
 	maxParallel  int
 	timeout      time.Duration
 	middlewares  []Middleware
+	sessionID    string
 	mu           sync.RWMutex
 }
 
@@ -90,6 +93,7 @@ func (d *Dispatcher) executeCore(ctx context.Context, toolUse types.ToolUse) typ
 	// Get the tool
 	d.mu.RLock()
 	tool, err := d.registry.Get(toolUse.Name)
+	sessionID := d.sessionID
 	d.mu.RUnlock()
 
 	if err != nil {
@@ -107,6 +111,7 @@ func (d *Dispatcher) executeCore(ctx context.Context, toolUse types.ToolUse) typ
 	}
 
 	// Execute the tool
+	ctx = tools.WithCallInfo(ctx, tools.CallInfo{ToolUseID: toolUse.ID, SessionID: sessionID})
 	result, err := tool.Execute(ctx, toolUse.Input)
 	if err != nil {
 		return *toolUse.Error(err)
@@ -186,6 +191,13 @@ func (d *Dispatcher) SetTimeout(timeout time.Duration) {
 	d.mu.Unlock()
 }
 
+// SetSessionID sets the session ID passed to tools with each call.
+func (d *Dispatcher) SetSessionID(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sessionID = id
+}
+
 // AddMiddleware adds a middleware to the dispatcher
 func (d *Dispatcher) AddMiddleware(middleware Middleware) {
 	d.mu.Lock()
@@ -224,6 +236,24 @@ func (d *Dispatcher) Clear() {
 	d.registry.Clear()
 }
 
+// Close releases resources held by registered tools, such as language
+// server processes. Tools opt in by implementing io.Closer.
+func (d *Dispatcher) Close() error {
+	d.mu.RLock()
+	registered := d.registry.List()
+	d.mu.RUnlock()
+
+	var errs []error
+	for _, tool := range registered {
+		if closer, ok := tool.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close tool %s: %w", tool.Name(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // Stats returns execution statistics
 type Stats struct {
 	RegisteredTools int
@@ -2,6 +2,7 @@ package dispatcher
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -110,6 +111,7 @@ func EventsMiddleware(obs ToolObserver, opts EventsOptions) Middleware {
 			// Succeeded event
 			event.Type = types.ToolEventSucceeded
 			event.Output, event.Metadata = truncateOutput(result.Content, opts.MaxOutputChars)
+			responseMetadata(result.Content, event.Metadata)
 		}
 
 		// Emit completed event
@@ -199,6 +201,33 @@ func truncateOutput(output string, maxChars int) (string, map[string]interface{}
 	return truncated + "\n... [output truncated]", metadata
 }
 
+// responseMetadata lifts well-known fields out of a standardized tool response
+// ({"ok":...,"data":{...}}) into the event metadata, so observers can render
+// them (e.g. a bash exit code) without parsing the output themselves.
+func responseMetadata(content string, metadata map[string]interface{}) {
+	if !strings.HasPrefix(strings.TrimSpace(content), "{") {
+		return
+	}
+
+	var resp struct {
+		Ok   *bool                  `json:"ok"`
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(content), &resp); err != nil {
+		return
+	}
+
+	if resp.Ok != nil {
+		metadata["ok"] = *resp.Ok
+	}
+	if code, ok := resp.Data["exit_code"].(float64); ok {
+		metadata["exit_code"] = int(code)
+	}
+	if timedOut, ok := resp.Data["timed_out"].(bool); ok && timedOut {
+		metadata["timed_out"] = true
+	}
+}
+
 // attemptFromContext extracts the retry attempt number from context
 // Returns 1 if not present (first attempt)
 func attemptFromContext(ctx context.Context) int {
@@ -184,6 +184,40 @@ func TestEventsMiddleware_OutputTruncation(t *testing.T) {
 	}
 }
 
+// TestEventsMiddleware_ExitCodeMetadata tests that exit codes from structured responses reach metadata
+func TestEventsMiddleware_ExitCodeMetadata(t *testing.T) {
+	obs := &mockObserver{}
+	middleware := EventsMiddleware(obs, DefaultEventsOptions())
+
+	tu := types.ToolUse{
+		ID:    "test-exit",
+		Name:  "bash",
+		Input: map[string]interface{}{"command": "exit 3"},
+	}
+
+	next := func(_ context.Context, _ types.ToolUse) types.ToolResult {
+		return types.ToolResult{
+			ToolUseID: tu.ID,
+			Content:   `{"ok":false,"summary":"Command exited with code 3","data":{"command":"exit 3","exit_code":3,"stdout":"","stderr":"boom"}}`,
+		}
+	}
+
+	middleware(context.Background(), tu, next)
+
+	events := obs.getEvents()
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+
+	meta := events[1].Metadata
+	if code, ok := meta["exit_code"].(int); !ok || code != 3 {
+		t.Errorf("Expected exit_code 3 in metadata, got %v", meta["exit_code"])
+	}
+	if okVal, ok := meta["ok"].(bool); !ok || okVal {
+		t.Errorf("Expected ok=false in metadata, got %v", meta["ok"])
+	}
+}
+
 // TestSanitizeInput tests sensitive data masking
 func TestSanitizeInput(t *testing.T) {
 	maskKeys := []string{"api_key", "password", "secret", "token"}
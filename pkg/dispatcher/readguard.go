@@ -0,0 +1,99 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Zerofisher/goai/pkg/filestate"
+	"github.com/Zerofisher/goai/pkg/types"
+)
+
+// ReadGuardMiddleware creates a middleware that refuses writes to files
+// the model has not read in this session, or that changed on disk since
+// it last read or wrote them. Successful reads and writes record the
+// version the model has seen; after bash calls and git checkouts, changed
+// files are re-recorded since the model made those changes itself. Reads
+// of a line range or symbol are enough for edits, but write_file, which
+// replaces the whole file, needs a read of all of it.
+func ReadGuardMiddleware(tracker *filestate.Tracker, targets WriteTargets) Middleware {
+	if targets == nil {
+		targets = DefaultWriteTargets
+	}
+
+	return func(ctx context.Context, tu types.ToolUse, next ExecuteFunc) types.ToolResult {
+		if tracker == nil {
+			return next(ctx, tu)
+		}
+
+		if tu.Name == "read_file" {
+			result := next(ctx, tu)
+			if path, ok := tu.Input["path"].(string); ok && !result.IsError && responseOk(result.Content) {
+				if partialRead(tu) {
+					_ = tracker.RecordPartial(path, tu.ID)
+				} else {
+					_ = tracker.Record(path, tu.ID)
+				}
+			}
+			return result
+		}
+
+		if paths := targets(tu); len(paths) > 0 {
+			check := tracker.Check
+			if tu.Name == "write_file" {
+				check = tracker.CheckFull
+			}
+			var problems []string
+			for _, path := range paths {
+				if err := check(path); err != nil {
+					problems = append(problems, err.Error())
+				}
+			}
+			if len(problems) > 0 {
+				return *tu.Error(errors.New("write refused: " + strings.Join(problems, "; ")))
+			}
+
+			result := next(ctx, tu)
+			if !result.IsError && responseOk(result.Content) {
+				for _, path := range paths {
+					// An edit shows the model no more of the file than it had seen
+					if state, ok := tracker.Get(path); ok && state.Partial && tu.Name != "write_file" {
+						_ = tracker.RecordPartial(path, tu.ID)
+					} else {
+						_ = tracker.Record(path, tu.ID)
+					}
+				}
+			}
+			return result
+		}
+
+		if runsCommand(tu) {
+			result := next(ctx, tu)
+			tracker.Refresh(tu.ID)
+			return result
+		}
+
+		return next(ctx, tu)
+	}
+}
+
+// partialRead reports whether a read_file call reads only part of the file.
+func partialRead(tu types.ToolUse) bool {
+	for _, key := range []string{"start_line", "end_line", "symbol"} {
+		switch v := tu.Input[key].(type) {
+		case string:
+			if v != "" {
+				return true
+			}
+		case float64:
+			if v != 0 {
+				return true
+			}
+		case int:
+			if v != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
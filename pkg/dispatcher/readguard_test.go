@@ -0,0 +1,102 @@
+package dispatcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Zerofisher/goai/pkg/filestate"
+	"github.com/Zerofisher/goai/pkg/types"
+)
+
+// TestReadGuardMiddleware tests that writes need a current read of the file
+func TestReadGuardMiddleware(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("package main\n")
+
+	middleware := ReadGuardMiddleware(filestate.NewTracker(dir), nil)
+	calls := 0
+	run := func(tu types.ToolUse, effect func()) types.ToolResult {
+		return middleware(context.Background(), tu, func(_ context.Context, tu types.ToolUse) types.ToolResult {
+			calls++
+			effect()
+			return types.ToolResult{ToolUseID: tu.ID, Content: `{"ok":true}`}
+		})
+	}
+	edit := types.ToolUse{ID: "e1", Name: "edit_file", Input: map[string]interface{}{"path": "main.go"}}
+
+	result := run(edit, func() {})
+	if !result.IsError || !strings.Contains(result.Content, "has not been read") || calls != 0 {
+		t.Fatalf("expected the unread write to be refused, got %+v", result)
+	}
+
+	run(types.ToolUse{ID: "r1", Name: "read_file", Input: map[string]interface{}{"path": "main.go"}}, func() {})
+	if result := run(edit, func() { write("package main\n\nfunc main() {}\n") }); result.IsError {
+		t.Fatalf("expected the write to pass, got %+v", result)
+	}
+	// The tool's own write is the version the model has seen
+	if result := run(edit, func() {}); result.IsError {
+		t.Fatalf("expected a second write to pass, got %+v", result)
+	}
+
+	// So are changes from the model's own commands
+	run(types.ToolUse{ID: "b1", Name: "bash", Input: map[string]interface{}{"command": "gofmt -w ."}}, func() { write("package main\n") })
+	if result := run(edit, func() {}); result.IsError {
+		t.Fatalf("expected a write after bash to pass, got %+v", result)
+	}
+
+	write("package external\n")
+	result = run(edit, func() {})
+	if !result.IsError || !strings.Contains(result.Content, "changed since it was last read") {
+		t.Errorf("expected the stale write to be refused, got %+v", result)
+	}
+}
+
+// TestReadGuardPartialReads tests that reads of part of a file allow edits
+// but not whole-file writes
+func TestReadGuardPartialReads(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	middleware := ReadGuardMiddleware(filestate.NewTracker(dir), nil)
+	run := func(tu types.ToolUse) types.ToolResult {
+		return middleware(context.Background(), tu, func(_ context.Context, tu types.ToolUse) types.ToolResult {
+			return types.ToolResult{ToolUseID: tu.ID, Content: `{"ok":true}`}
+		})
+	}
+	write := types.ToolUse{ID: "w1", Name: "write_file", Input: map[string]interface{}{"path": "main.go"}}
+	edit := types.ToolUse{ID: "e1", Name: "edit_file", Input: map[string]interface{}{"path": "main.go"}}
+
+	for _, input := range []map[string]interface{}{
+		{"path": "main.go", "start_line": float64(1), "end_line": float64(2)},
+		{"path": "main.go", "symbol": "main"},
+	} {
+		run(types.ToolUse{ID: "r1", Name: "read_file", Input: input})
+		if result := run(edit); result.IsError {
+			t.Fatalf("expected an edit after %v to pass, got %+v", input, result)
+		}
+		result := run(write)
+		if !result.IsError || !strings.Contains(result.Content, "only part of the file has been read") {
+			t.Fatalf("expected write_file after %v to be refused, got %+v", input, result)
+		}
+	}
+
+	run(types.ToolUse{ID: "r2", Name: "read_file", Input: map[string]interface{}{"path": "main.go"}})
+	if result := run(write); result.IsError {
+		t.Fatalf("expected write_file after a full read to pass, got %+v", result)
+	}
+	// A later partial read of the same version keeps the full read
+	run(types.ToolUse{ID: "r3", Name: "read_file", Input: map[string]interface{}{"path": "main.go", "start_line": float64(1)}})
+	if result := run(write); result.IsError {
+		t.Errorf("expected write_file to pass, got %+v", result)
+	}
+}
@@ -0,0 +1,195 @@
+// Package filestate tracks which version of each file the model has seen
+// in a session, so writes based on a missing or stale view of a file can
+// be refused.
+package filestate
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotRead is returned for files that were never read in the session.
+	ErrNotRead = errors.New("file has not been read")
+	// ErrModified is returned for files that changed since they were last
+	// read or written.
+	ErrModified = errors.New("file changed since it was last read")
+	// ErrPartialRead is returned by CheckFull for files of which only some
+	// lines were read.
+	ErrPartialRead = errors.New("only part of the file has been read")
+)
+
+// State is the version of a file as last read or written.
+type State struct {
+	Hash      [sha256.Size]byte
+	ModTime   time.Time
+	Size      int64
+	ToolUseID string // Tool use that read or wrote this version
+	Partial   bool   // Only some lines of the file were read
+}
+
+// Tracker records file states for one session. It is safe for concurrent
+// use.
+type Tracker struct {
+	workDir string
+	mu      sync.Mutex
+	files   map[string]State
+}
+
+// NewTracker creates a tracker for files in workDir.
+func NewTracker(workDir string) *Tracker {
+	return &Tracker{
+		workDir: workDir,
+		files:   make(map[string]State),
+	}
+}
+
+// Record stores the current state of path as seen in full by toolUseID.
+// A path that no longer exists is forgotten.
+func (t *Tracker) Record(path, toolUseID string) error {
+	return t.record(path, toolUseID, false)
+}
+
+// RecordPartial stores the current state of path as seen by toolUseID,
+// which read only some of its lines. A full read of the same version is
+// kept.
+func (t *Tracker) RecordPartial(path, toolUseID string) error {
+	return t.record(path, toolUseID, true)
+}
+
+// record stores the current state of path.
+func (t *Tracker) record(path, toolUseID string, partial bool) error {
+	abs := t.abs(path)
+	state, err := stat(abs)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if os.IsNotExist(err) {
+		delete(t.files, abs)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if known, ok := t.files[abs]; partial && ok && !known.Partial && known.Hash == state.Hash {
+		return nil
+	}
+	state.ToolUseID = toolUseID
+	state.Partial = partial
+	t.files[abs] = state
+	return nil
+}
+
+// Check reports whether path may be edited: it must not exist yet, or
+// match the version last recorded, which may have been read in part. The
+// error wraps ErrNotRead or ErrModified and tells the model what to do.
+func (t *Tracker) Check(path string) error {
+	return t.check(path, false)
+}
+
+// CheckFull is Check for writes that replace the whole file, which also
+// need the whole file to have been read. The error may wrap
+// ErrPartialRead as well.
+func (t *Tracker) CheckFull(path string) error {
+	return t.check(path, true)
+}
+
+// check reports whether path may be written.
+func (t *Tracker) check(path string, full bool) error {
+	abs := t.abs(path)
+	info, err := os.Stat(abs)
+	if os.IsNotExist(err) {
+		return nil // Creating a file needs no prior read
+	}
+	if err != nil || info.IsDir() {
+		return nil // Left to the tool to report
+	}
+
+	t.mu.Lock()
+	known, ok := t.files[abs]
+	t.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%s: %w in this session; read it with read_file before changing it", path, ErrNotRead)
+	}
+	if full && known.Partial {
+		return fmt.Errorf("%s: %w (tool_use %s); read it without start_line, end_line or symbol before replacing it, or change it with edit_file",
+			path, ErrPartialRead, known.ToolUseID)
+	}
+	if info.ModTime().Equal(known.ModTime) && info.Size() == known.Size {
+		return nil
+	}
+
+	// The timestamp moved; only a different content counts as a change
+	current, err := stat(abs)
+	if err != nil {
+		return nil
+	}
+	if current.Hash == known.Hash {
+		current.ToolUseID = known.ToolUseID
+		current.Partial = known.Partial
+		t.mu.Lock()
+		t.files[abs] = current
+		t.mu.Unlock()
+		return nil
+	}
+	return fmt.Errorf("%s: %w (tool_use %s); it was modified outside this session, read it again with read_file before changing it",
+		path, ErrModified, known.ToolUseID)
+}
+
+// Refresh records the current state of every tracked file that changed.
+// It is used after commands the model ran itself, such as formatters, so
+// their changes do not count as external. Files read in part stay so.
+func (t *Tracker) Refresh(toolUseID string) {
+	t.mu.Lock()
+	partial := make(map[string]bool, len(t.files))
+	for path, state := range t.files {
+		partial[path] = state.Partial
+	}
+	t.mu.Unlock()
+
+	for path, part := range partial {
+		if _, err := os.Stat(path); os.IsNotExist(err) || errors.Is(t.Check(path), ErrModified) {
+			_ = t.record(path, toolUseID, part)
+		}
+	}
+}
+
+// Get returns the recorded state of path.
+func (t *Tracker) Get(path string) (State, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state, ok := t.files[t.abs(path)]
+	return state, ok
+}
+
+// Reset forgets every file.
+func (t *Tracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.files = make(map[string]State)
+}
+
+// abs resolves path against the work directory.
+func (t *Tracker) abs(path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(t.workDir, path)
+	}
+	return filepath.Clean(path)
+}
+
+// stat reads the state of the file at path.
+func stat(path string) (State, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return State{}, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return State{}, err
+	}
+	return State{Hash: sha256.Sum256(content), ModTime: info.ModTime(), Size: info.Size()}, nil
+}
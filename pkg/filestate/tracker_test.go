@@ -0,0 +1,125 @@
+package filestate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestCheck tests which writes the tracker allows
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("package main\n")
+	tracker := NewTracker(dir)
+
+	if err := tracker.Check("new.go"); err != nil {
+		t.Errorf("expected creating a file to be allowed, got %v", err)
+	}
+	if err := tracker.Check("main.go"); !errors.Is(err, ErrNotRead) {
+		t.Errorf("expected ErrNotRead, got %v", err)
+	}
+
+	if err := tracker.Record("main.go", "r1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.Check(path); err != nil {
+		t.Errorf("expected a read file to be writable, got %v", err)
+	}
+
+	// Touching the file without changing it is not a change
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.Check("main.go"); err != nil {
+		t.Errorf("expected a touched file to be writable, got %v", err)
+	}
+
+	write("package other\n")
+	if err := tracker.Check("main.go"); !errors.Is(err, ErrModified) {
+		t.Errorf("expected ErrModified, got %v", err)
+	}
+
+	tracker.Reset()
+	if _, ok := tracker.Get("main.go"); ok {
+		t.Error("expected Reset to forget the file")
+	}
+}
+
+// TestRefresh tests that changes made by the model's own commands are recorded
+func TestRefresh(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tracker := NewTracker(dir)
+	if err := tracker.Record("a.txt", "r1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tracker.Refresh("b1")
+	if err := tracker.Check("a.txt"); err != nil {
+		t.Errorf("expected the refreshed file to be writable, got %v", err)
+	}
+	if state, _ := tracker.Get("a.txt"); state.ToolUseID != "b1" {
+		t.Errorf("expected the state to come from b1, got %q", state.ToolUseID)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	tracker.Refresh("b2")
+	if _, ok := tracker.Get("a.txt"); ok {
+		t.Error("expected a deleted file to be forgotten")
+	}
+}
+
+// TestPartialRead tests that partial reads allow edits but not full writes
+func TestPartialRead(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tracker := NewTracker(dir)
+	if err := tracker.RecordPartial("main.go", "r1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.Check("main.go"); err != nil {
+		t.Errorf("expected a partly read file to be editable, got %v", err)
+	}
+	if err := tracker.CheckFull("main.go"); !errors.Is(err, ErrPartialRead) {
+		t.Errorf("expected ErrPartialRead, got %v", err)
+	}
+
+	// Commands keep the file partly read
+	if err := os.WriteFile(path, []byte("package main // formatted\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tracker.Refresh("b1")
+	if err := tracker.CheckFull("main.go"); !errors.Is(err, ErrPartialRead) {
+		t.Errorf("expected ErrPartialRead after a command, got %v", err)
+	}
+
+	if err := tracker.Record("main.go", "r2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.RecordPartial("main.go", "r3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.CheckFull("main.go"); err != nil {
+		t.Errorf("expected a full read to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Branch is a local branch.
+type Branch struct {
+	Name     string `json:"name"`
+	Commit   string `json:"commit"`
+	Upstream string `json:"upstream,omitempty"`
+	Track    string `json:"track,omitempty"` // e.g. "ahead 1, behind 2"
+	Current  bool   `json:"current,omitempty"`
+	Subject  string `json:"subject"`
+}
+
+// Branches returns the local branches.
+func (r *Repo) Branches(ctx context.Context) ([]Branch, error) {
+	out, err := r.run(ctx, "for-each-ref",
+		"--format=%(refname:short)%1f%(objectname:short)%1f%(upstream:short)%1f%(upstream:track,nobracket)%1f%(HEAD)%1f%(subject)",
+		"refs/heads")
+	if err != nil {
+		return nil, err
+	}
+
+	var branches []Branch
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 6 {
+			continue
+		}
+		branches = append(branches, Branch{
+			Name:     fields[0],
+			Commit:   fields[1],
+			Upstream: fields[2],
+			Track:    fields[3],
+			Current:  fields[4] == "*",
+			Subject:  fields[5],
+		})
+	}
+	return branches, nil
+}
+
+// CreateBranch creates branch name at startPoint (HEAD when empty). With
+// force, an existing branch is reset to startPoint.
+func (r *Repo) CreateBranch(ctx context.Context, name, startPoint string, force bool) error {
+	args := []string{"branch"}
+	if force {
+		args = append(args, "--force")
+	}
+	args = append(args, "--", name)
+	if startPoint != "" {
+		args = append(args, startPoint)
+	}
+	_, err := r.run(ctx, args...)
+	return err
+}
+
+// SwitchBranch checks out branch name. With force, local changes that
+// would be overwritten are discarded.
+func (r *Repo) SwitchBranch(ctx context.Context, name string, force bool) error {
+	args := []string{"switch"}
+	if force {
+		args = append(args, "--discard-changes")
+	}
+	_, err := r.run(ctx, append(args, name)...)
+	return err
+}
+
+// DeleteBranch deletes branch name. Without force git refuses to delete a
+// branch that is not merged.
+func (r *Repo) DeleteBranch(ctx context.Context, name string, force bool) error {
+	flag := "--delete"
+	if force {
+		flag = "-D"
+	}
+	_, err := r.run(ctx, "branch", flag, "--", name)
+	return err
+}
+
+// Add stages paths. Deleted paths are staged as deletions.
+func (r *Repo) Add(ctx context.Context, paths ...string) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("no paths to add")
+	}
+	_, err := r.run(ctx, append([]string{"add", "--all", "--"}, paths...)...)
+	return err
+}
+
+// CommitOptions controls Commit.
+type CommitOptions struct {
+	All   bool // stage modified and deleted tracked files first
+	Amend bool // replace the last commit
+}
+
+// Commit records the staged changes with message and returns the new
+// commit. Hooks run as usual.
+func (r *Repo) Commit(ctx context.Context, message string, opts CommitOptions) (*Commit, error) {
+	if strings.TrimSpace(message) == "" {
+		return nil, fmt.Errorf("commit message is required")
+	}
+	args := []string{"commit", "--file=-"}
+	if opts.All {
+		args = append(args, "--all")
+	}
+	if opts.Amend {
+		args = append(args, "--amend")
+	}
+	if _, err := r.runInput(ctx, message, args...); err != nil {
+		return nil, err
+	}
+	commits, err := r.Log(ctx, LogOptions{MaxCount: 1})
+	if err != nil || len(commits) == 0 {
+		return nil, err
+	}
+	return &commits[0], nil
+}
+
+// Stash is one stash entry.
+type Stash struct {
+	Ref     string `json:"ref"` // stash@{n}
+	Message string `json:"message"`
+}
+
+// Stashes returns the stash entries, newest first.
+func (r *Repo) Stashes(ctx context.Context) ([]Stash, error) {
+	out, err := r.run(ctx, "stash", "list", "--format=%gd%x1f%gs")
+	if err != nil {
+		return nil, err
+	}
+	var stashes []Stash
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		if ref, message, ok := strings.Cut(line, "\x1f"); ok {
+			stashes = append(stashes, Stash{Ref: ref, Message: message})
+		}
+	}
+	return stashes, nil
+}
+
+// StashPush saves local changes (of paths, or all) to a new stash entry
+// and reverts them in the work tree. It reports false when there was
+// nothing to stash.
+func (r *Repo) StashPush(ctx context.Context, message string, includeUntracked bool, paths ...string) (bool, error) {
+	args := []string{"stash", "push"}
+	if includeUntracked {
+		args = append(args, "--include-untracked")
+	}
+	if message != "" {
+		args = append(args, "--message="+message)
+	}
+	out, err := r.run(ctx, append(append(args, "--"), paths...)...)
+	if err != nil {
+		return false, err
+	}
+	return !strings.Contains(out, "No local changes to save"), nil
+}
+
+// StashApply applies stash entry index to the work tree; with drop the
+// entry is removed afterwards (git stash pop).
+func (r *Repo) StashApply(ctx context.Context, index int, drop bool) error {
+	op := "apply"
+	if drop {
+		op = "pop"
+	}
+	_, err := r.run(ctx, "stash", op, StashRef(index))
+	return err
+}
+
+// StashDrop deletes stash entry index.
+func (r *Repo) StashDrop(ctx context.Context, index int) error {
+	_, err := r.run(ctx, "stash", "drop", StashRef(index))
+	return err
+}
+
+// StashRef returns the reference of stash entry index.
+func StashRef(index int) string {
+	return fmt.Sprintf("stash@{%d}", index)
+}
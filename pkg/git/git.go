@@ -0,0 +1,153 @@
+// Package git runs git commands against a repository and parses their
+// output into typed results: status, diffs, history, blame, branches and
+// stashes.
+//
+// Every operation shells out to the git binary with machine-readable
+// output formats (porcelain v2, -z, numstat, custom log formats), so the
+// results match what git itself reports.
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ErrNotRepository is returned when the directory is not inside a git
+// work tree.
+var ErrNotRepository = errors.New("not a git repository")
+
+// Repo is a git work tree.
+type Repo struct {
+	dir string
+	env []string // extra environment for every command
+}
+
+// New returns the repository containing dir. It does not check that dir is
+// inside a work tree; see IsRepo.
+func New(dir string) *Repo {
+	return &Repo{dir: dir}
+}
+
+// WithEnv returns a copy of r whose commands run with env (KEY=VALUE)
+// added to the environment.
+func (r *Repo) WithEnv(env ...string) *Repo {
+	return &Repo{dir: r.dir, env: append(append([]string(nil), r.env...), env...)}
+}
+
+// WithIndex returns a copy of r that uses indexFile instead of the
+// repository's index, so the user's staging area is left alone.
+func (r *Repo) WithIndex(indexFile string) *Repo {
+	return r.WithEnv("GIT_INDEX_FILE=" + indexFile)
+}
+
+// Dir returns the directory commands run in.
+func (r *Repo) Dir() string {
+	return r.dir
+}
+
+// IsRepo reports whether the directory is inside a git work tree. Linked
+// worktrees and submodules, whose .git is a file, count too.
+func (r *Repo) IsRepo(ctx context.Context) bool {
+	out, err := r.run(ctx, "rev-parse", "--is-inside-work-tree")
+	return err == nil && strings.TrimSpace(out) == "true"
+}
+
+// Root returns the top-level directory of the work tree.
+func (r *Repo) Root(ctx context.Context) (string, error) {
+	out, err := r.run(ctx, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// Head describes the checked-out commit.
+type Head struct {
+	Branch   string `json:"branch,omitempty"` // empty when detached
+	Commit   string `json:"commit,omitempty"` // short hash; empty before the first commit
+	Detached bool   `json:"detached,omitempty"`
+}
+
+// String returns the branch name, or "detached at <commit>".
+func (h Head) String() string {
+	if h.Detached {
+		return "detached at " + h.Commit
+	}
+	return h.Branch
+}
+
+// Head returns the current branch and commit.
+func (r *Repo) Head(ctx context.Context) (Head, error) {
+	var head Head
+	if out, err := r.run(ctx, "symbolic-ref", "--quiet", "--short", "HEAD"); err == nil {
+		head.Branch = strings.TrimSpace(out)
+	} else {
+		head.Detached = true
+	}
+	if out, err := r.run(ctx, "rev-parse", "--short", "HEAD"); err == nil {
+		head.Commit = strings.TrimSpace(out)
+	} else if head.Detached {
+		return Head{}, err
+	}
+	return head, nil
+}
+
+// CommandError is returned when git exits with an error.
+type CommandError struct {
+	Args   []string
+	Stderr string
+	Err    error
+}
+
+func (e *CommandError) Error() string {
+	if e.Stderr != "" {
+		return fmt.Sprintf("git %s: %s", e.Args[0], e.Stderr)
+	}
+	return fmt.Sprintf("git %s: %v", e.Args[0], e.Err)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// run runs git with args in the repository directory and returns stdout.
+func (r *Repo) run(ctx context.Context, args ...string) (string, error) {
+	return r.runInput(ctx, "", args...)
+}
+
+// runInput runs git with stdin set to input.
+func (r *Repo) runInput(ctx context.Context, input string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.dir
+	// Never stop for a pager, editor or credential prompt
+	cmd.Env = append(cmd.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_EDITOR=true", "GIT_PAGER=cat", "LC_ALL=C")
+	cmd.Env = append(cmd.Env, r.env...)
+	if input != "" {
+		cmd.Stdin = strings.NewReader(input)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if strings.Contains(msg, "not a git repository") {
+			return "", ErrNotRepository
+		}
+		return "", &CommandError{Args: args, Stderr: msg, Err: err}
+	}
+	return stdout.String(), nil
+}
+
+// splitNul splits NUL-terminated output into its entries.
+func splitNul(out string) []string {
+	out = strings.TrimSuffix(out, "\x00")
+	if out == "" {
+		return nil
+	}
+	return strings.Split(out, "\x00")
+}
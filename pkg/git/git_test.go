@@ -0,0 +1,330 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Zerofisher/goai/internal/testutil"
+)
+
+// newTestRepo creates a repository with one commit of a.txt and b.txt.
+func newTestRepo(t *testing.T) (*Repo, string) {
+	t.Helper()
+	dir := testutil.NewGitRepo(t, map[string]string{"a.txt": "one\ntwo\nthree\n", "b.txt": "bee\n"})
+	return New(dir), dir
+}
+
+// TestStatusAndDiff tests parsing status and diffs of staged, unstaged,
+// renamed and untracked files
+func TestStatusAndDiff(t *testing.T) {
+	repo, dir := newTestRepo(t)
+	ctx := context.Background()
+
+	if !repo.IsRepo(ctx) {
+		t.Fatal("expected a repository")
+	}
+	if New(t.TempDir()).IsRepo(ctx) {
+		t.Error("expected an empty directory not to be a repository")
+	}
+
+	testutil.WriteFile(t, dir, "a.txt", "one\n2\nthree\nfour\n")
+	testutil.Git(t, dir, "mv", "b.txt", "bee.txt")
+	testutil.WriteFile(t, dir, "new file.txt", "new\n")
+	testutil.WriteFile(t, dir, "c.txt", "staged\n")
+	testutil.Git(t, dir, "add", "c.txt")
+
+	status, err := repo.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.Head.Branch != "main" || status.Head.Detached || len(status.Head.Commit) != 7 {
+		t.Errorf("unexpected head: %+v", status.Head)
+	}
+	wantStaged := []FileStatus{
+		{Path: "bee.txt", OrigPath: "b.txt", State: StateRenamed},
+		{Path: "c.txt", State: StateAdded},
+	}
+	if !reflect.DeepEqual(status.Staged, wantStaged) {
+		t.Errorf("staged = %+v, want %+v", status.Staged, wantStaged)
+	}
+	if !reflect.DeepEqual(status.Unstaged, []FileStatus{{Path: "a.txt", State: StateModified}}) {
+		t.Errorf("unstaged = %+v", status.Unstaged)
+	}
+	if !reflect.DeepEqual(status.Untracked, []string{"new file.txt"}) || status.Clean() {
+		t.Errorf("untracked = %v", status.Untracked)
+	}
+
+	diff, err := repo.Diff(ctx, DiffOptions{})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(diff.Files) != 1 || diff.Files[0] != (DiffFile{Path: "a.txt", Added: 2, Removed: 1}) {
+		t.Errorf("unstaged diff files = %+v", diff.Files)
+	}
+	if !strings.Contains(diff.Patch, "+four") || diff.Truncated {
+		t.Errorf("unexpected patch:\n%s", diff.Patch)
+	}
+
+	staged, err := repo.Diff(ctx, DiffOptions{Staged: true, StatOnly: true})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	wantFiles := []DiffFile{{Path: "bee.txt", OldPath: "b.txt"}, {Path: "c.txt", Added: 1}}
+	if !reflect.DeepEqual(staged.Files, wantFiles) || staged.Patch != "" {
+		t.Errorf("staged diff = %+v, want files %+v and no patch", staged, wantFiles)
+	}
+
+	small, err := repo.Diff(ctx, DiffOptions{Range: "HEAD", MaxPatchBytes: 40})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if !small.Truncated || len(small.Patch) > 40 || len(small.Files) != 3 {
+		t.Errorf("expected a truncated patch of 3 files, got %+v", small)
+	}
+}
+
+// TestHistory tests log, show and blame
+func TestHistory(t *testing.T) {
+	repo, dir := newTestRepo(t)
+	ctx := context.Background()
+
+	testutil.WriteFile(t, dir, "a.txt", "one\n2\nthree\n")
+	commit, err := repo.Commit(ctx, "Change two\n\nUse a digit.", CommitOptions{All: true})
+	if err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if commit.Subject != "Change two" || commit.Body != "Use a digit." || commit.Author != "Test" {
+		t.Errorf("unexpected commit: %+v", commit)
+	}
+
+	commits, err := repo.Log(ctx, LogOptions{})
+	if err != nil {
+		t.Fatalf("Log failed: %v", err)
+	}
+	if len(commits) != 2 || commits[0].Hash != commit.Hash || commits[1].Subject != "Initial commit" {
+		t.Fatalf("unexpected log: %+v", commits)
+	}
+	if len(commits[0].Parents) != 1 || commits[0].Parents[0] != commits[1].Hash || commits[0].Date.IsZero() {
+		t.Errorf("unexpected parents or date: %+v", commits[0])
+	}
+
+	filtered, err := repo.Log(ctx, LogOptions{Paths: []string{"b.txt"}})
+	if err != nil || len(filtered) != 1 || filtered[0].Subject != "Initial commit" {
+		t.Errorf("path-filtered log = %+v, %v", filtered, err)
+	}
+	grepped, err := repo.Log(ctx, LogOptions{Grep: "change", MaxCount: 5})
+	if err != nil || len(grepped) != 1 {
+		t.Errorf("grep log = %+v, %v", grepped, err)
+	}
+
+	shown, err := repo.Show(ctx, "", DiffOptions{})
+	if err != nil {
+		t.Fatalf("Show failed: %v", err)
+	}
+	if shown.Hash != commit.Hash || shown.Diff == nil || len(shown.Diff.Files) != 1 || !strings.Contains(shown.Diff.Patch, "+2") {
+		t.Errorf("unexpected show: %+v", shown)
+	}
+
+	blame, err := repo.Blame(ctx, "a.txt", 2, 3, "")
+	if err != nil {
+		t.Fatalf("Blame failed: %v", err)
+	}
+	if len(blame) != 2 {
+		t.Fatalf("expected 2 lines, got %+v", blame)
+	}
+	if blame[0].Line != 2 || blame[0].Text != "2" || blame[0].Summary != "Change two" || blame[0].Commit != commit.Hash[:8] {
+		t.Errorf("unexpected blame for line 2: %+v", blame[0])
+	}
+	if blame[1].Line != 3 || blame[1].Summary != "Initial commit" || blame[1].Author != "Test" || len(blame[1].Date) != 10 {
+		t.Errorf("unexpected blame for line 3: %+v", blame[1])
+	}
+}
+
+// TestBranchesAndStash tests branch and stash operations
+func TestBranchesAndStash(t *testing.T) {
+	repo, dir := newTestRepo(t)
+	ctx := context.Background()
+
+	if err := repo.CreateBranch(ctx, "feature", "", false); err != nil {
+		t.Fatalf("CreateBranch failed: %v", err)
+	}
+	if err := repo.SwitchBranch(ctx, "feature", false); err != nil {
+		t.Fatalf("SwitchBranch failed: %v", err)
+	}
+	head, err := repo.Head(ctx)
+	if err != nil || head.Branch != "feature" || head.String() != "feature" {
+		t.Errorf("Head() = %+v, %v", head, err)
+	}
+
+	branches, err := repo.Branches(ctx)
+	if err != nil {
+		t.Fatalf("Branches failed: %v", err)
+	}
+	if len(branches) != 2 || branches[0].Name != "feature" || !branches[0].Current || branches[1].Current {
+		t.Errorf("unexpected branches: %+v", branches)
+	}
+
+	testutil.WriteFile(t, dir, "a.txt", "changed\n")
+	if err := repo.Add(ctx, "a.txt"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, err := repo.Commit(ctx, "Feature work", CommitOptions{}); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if err := repo.SwitchBranch(ctx, "main", false); err != nil {
+		t.Fatalf("SwitchBranch failed: %v", err)
+	}
+	var cmdErr *CommandError
+	if err := repo.DeleteBranch(ctx, "feature", false); !errors.As(err, &cmdErr) || !strings.Contains(err.Error(), "not fully merged") {
+		t.Errorf("expected an unmerged branch to be kept, got %v", err)
+	}
+
+	testutil.WriteFile(t, dir, "b.txt", "stashed\n")
+	saved, err := repo.StashPush(ctx, "wip", false)
+	if err != nil || !saved {
+		t.Fatalf("StashPush = %v, %v", saved, err)
+	}
+	if saved, _ := repo.StashPush(ctx, "", false); saved {
+		t.Error("expected nothing to stash")
+	}
+	stashes, err := repo.Stashes(ctx)
+	if err != nil || len(stashes) != 1 || stashes[0].Ref != "stash@{0}" || !strings.Contains(stashes[0].Message, "wip") {
+		t.Fatalf("Stashes() = %+v, %v", stashes, err)
+	}
+	if err := repo.StashApply(ctx, 0, true); err != nil {
+		t.Fatalf("StashApply failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "b.txt")); string(data) != "stashed\n" {
+		t.Errorf("b.txt = %q after pop", data)
+	}
+
+	testutil.Git(t, dir, "checkout", "-q", "--detach", "HEAD")
+	head, err = repo.Head(ctx)
+	if err != nil || !head.Detached || !strings.HasPrefix(head.String(), "detached at ") {
+		t.Errorf("Head() = %+v, %v", head, err)
+	}
+}
+
+// TestDestructiveCommand tests classifying git command lines
+func TestDestructiveCommand(t *testing.T) {
+	tests := []struct {
+		args        string
+		destructive bool
+	}{
+		{"status", false},
+		{"push origin main", false},
+		{"push -u origin feature", false},
+		{"push --force origin main", true},
+		{"push -fu origin main", true},
+		{"push --force-with-lease", true},
+		{"push origin +main", true},
+		{"push origin :old", true},
+		{"-C sub reset --hard HEAD~1", true},
+		{"reset --soft HEAD~1", false},
+		{"clean -fdx", true},
+		{"clean -n -f", false},
+		{"checkout main", false},
+		{"checkout -b topic", false},
+		{"checkout -- a.txt", true},
+		{"checkout main --", false},
+		{"checkout -b topic origin/main", false},
+		{"checkout -", false},
+		{"checkout .", true},
+		{"checkout ./pkg", true},
+		{"checkout *.go", true},
+		{"checkout HEAD~1 a.txt", true},
+		{"checkout a.txt b.txt", true},
+		{"restore a.txt", true},
+		{"restore --staged a.txt", false},
+		{"branch -d topic", false},
+		{"branch -D topic", true},
+		{"stash", false},
+		{"stash pop", false},
+		{"stash drop", true},
+		{"commit -m fix", false},
+		{"commit --amend --no-edit", true},
+		{"switch --discard-changes main", true},
+		{"log --format=%H -f", false},
+		{"-c alias.x=reset x", true},
+		{"-c Alias.x=reset x", true},
+		{"--config-env=alias.x=CMD x", true},
+		{"-c core.pager=cat log", false},
+		{"rebase main", true},
+		{"rebase -i HEAD~3", true},
+		{"rebase --onto main topic", true},
+		{"rebase --abort", false},
+		{"rebase --continue", false},
+		{"pull --rebase", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			reason := DestructiveCommand(strings.Fields(tt.args))
+			if (reason != "") != tt.destructive {
+				t.Errorf("DestructiveCommand(%q) = %q, want destructive %v", tt.args, reason, tt.destructive)
+			}
+		})
+	}
+}
+
+// TestDestructiveCommandIn tests recognizing checkouts of existing files
+func TestDestructiveCommandIn(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFile(t, dir, "main.go", "package main\n")
+	testutil.WriteFile(t, dir, "sub/util.go", "package sub\n")
+
+	tests := []struct {
+		args        string
+		destructive bool
+	}{
+		{"checkout main.go", true},
+		{"checkout sub", true},
+		{"checkout main", false},
+		{"checkout feature/x", false},
+		{"-C sub checkout util.go", true},
+		{"checkout --quiet main.go", true},
+	}
+	for _, tt := range tests {
+		if reason := DestructiveCommandIn(dir, strings.Fields(tt.args)); (reason != "") != tt.destructive {
+			t.Errorf("DestructiveCommandIn(%q) = %q, want destructive %v", tt.args, reason, tt.destructive)
+		}
+	}
+	if reason := DestructiveCommand([]string{"checkout", "main.go"}); reason != "" {
+		t.Errorf("DestructiveCommand without a work tree = %q", reason)
+	}
+}
+
+// TestDestructiveCommandAliases tests that configured aliases are expanded
+// before classifying a command
+func TestDestructiveCommandAliases(t *testing.T) {
+	dir := testutil.InitGitRepo(t)
+	testutil.Git(t, dir, "config", "alias.nuke", "reset --hard")
+	testutil.Git(t, dir, "config", "alias.wipe", "nuke HEAD")
+	testutil.Git(t, dir, "config", "alias.sh", "!git reset --hard")
+	testutil.Git(t, dir, "config", "alias.st", "status --short")
+	testutil.Git(t, dir, "config", "alias.status", "reset --hard")
+
+	tests := []struct {
+		args        string
+		destructive bool
+	}{
+		{"nuke", true},
+		{"wipe", true},
+		{"sh", true},
+		{"st", false},
+		{"status", false}, // aliases cannot replace builtin commands
+		{"unknown", false},
+	}
+	for _, tt := range tests {
+		if reason := DestructiveCommandIn(dir, strings.Fields(tt.args)); (reason != "") != tt.destructive {
+			t.Errorf("DestructiveCommandIn(%q) = %q, want destructive %v", tt.args, reason, tt.destructive)
+		}
+	}
+	if reason := DestructiveCommand([]string{"nuke"}); reason != "" {
+		t.Errorf("DestructiveCommand without a repository = %q", reason)
+	}
+}
@@ -0,0 +1,336 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMaxPatchBytes bounds the patch text returned with a diff.
+const defaultMaxPatchBytes = 64 * 1024
+
+// DiffOptions selects what Diff compares.
+type DiffOptions struct {
+	Staged        bool     // index against HEAD instead of work tree against index
+	Range         string   // "a..b", "a...b", or one revision compared with the work tree
+	Paths         []string // limit to these paths
+	Context       int      // lines of context; git's default when zero
+	StatOnly      bool     // per-file counts only, no patch
+	MaxPatchBytes int      // patch size limit; 64KB when zero
+	Binary        bool     // include binary changes so git apply accepts the patch
+}
+
+// Diff is a set of file changes with the patch text.
+type Diff struct {
+	Files     []DiffFile `json:"files"`
+	Added     int        `json:"added"`
+	Removed   int        `json:"removed"`
+	Patch     string     `json:"patch,omitempty"`
+	Truncated bool       `json:"truncated,omitempty"` // Patch was cut at the size limit
+}
+
+// DiffFile is the line counts of one changed file. OldPath is set for
+// renames.
+type DiffFile struct {
+	Path    string `json:"path"`
+	OldPath string `json:"old_path,omitempty"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	Binary  bool   `json:"binary,omitempty"`
+}
+
+// Diff returns the changes selected by opts.
+func (r *Repo) Diff(ctx context.Context, opts DiffOptions) (*Diff, error) {
+	args := []string{"diff", "--no-ext-diff", "--no-color"}
+	if opts.Staged {
+		args = append(args, "--cached")
+	}
+	if opts.Range != "" {
+		args = append(args, opts.Range)
+	}
+	return r.diff(ctx, args, opts)
+}
+
+// diff runs args (a "diff" or "show" command line without paths) once for
+// the counts and once for the patch.
+func (r *Repo) diff(ctx context.Context, args []string, opts DiffOptions) (*Diff, error) {
+	paths := append([]string{"--"}, opts.Paths...)
+
+	out, err := r.run(ctx, append(append(append([]string{}, args...), "--numstat", "-z", "-M"), paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	diff := &Diff{Files: parseNumstat(out)}
+	for _, file := range diff.Files {
+		diff.Added += file.Added
+		diff.Removed += file.Removed
+	}
+	if opts.StatOnly || len(diff.Files) == 0 {
+		return diff, nil
+	}
+
+	patchArgs := append(append([]string{}, args...), "-M")
+	if opts.Binary {
+		patchArgs = append(patchArgs, "--binary")
+	}
+	if opts.Context > 0 {
+		patchArgs = append(patchArgs, fmt.Sprintf("-U%d", opts.Context))
+	}
+	patch, err := r.run(ctx, append(patchArgs, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	maxBytes := opts.MaxPatchBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxPatchBytes
+	}
+	diff.Patch, diff.Truncated = truncate(patch, maxBytes)
+	return diff, nil
+}
+
+// parseNumstat parses "--numstat -z" output. Renames are reported as
+// "added\tremoved\t" followed by the old and new paths as separate entries.
+func parseNumstat(out string) []DiffFile {
+	var files []DiffFile
+	entries := splitNul(out)
+	for i := 0; i < len(entries); i++ {
+		fields := strings.SplitN(entries[i], "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		file := DiffFile{Path: fields[2]}
+		if fields[0] == "-" {
+			file.Binary = true
+		} else {
+			file.Added, _ = strconv.Atoi(fields[0])
+			file.Removed, _ = strconv.Atoi(fields[1])
+		}
+		if file.Path == "" && i+2 < len(entries) {
+			file.OldPath, file.Path = entries[i+1], entries[i+2]
+			i += 2
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// LogOptions selects the commits Log returns.
+type LogOptions struct {
+	Range    string   // revision or range; HEAD when empty
+	Paths    []string // only commits touching these paths
+	MaxCount int      // 20 when zero
+	Author   string   // author name or email pattern
+	Grep     string   // commit message pattern
+	Since    string   // date, e.g. "2 weeks ago" or "2024-01-31"
+}
+
+// Commit is one commit. Diff is only set by Show.
+type Commit struct {
+	Hash      string    `json:"hash"`
+	ShortHash string    `json:"short_hash"`
+	Author    string    `json:"author"`
+	Email     string    `json:"email"`
+	Date      time.Time `json:"date"`
+	Parents   []string  `json:"parents,omitempty"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body,omitempty"`
+	Diff      *Diff     `json:"diff,omitempty"`
+}
+
+// logFormat separates fields with US and records with RS.
+const logFormat = "%H%x1f%h%x1f%an%x1f%ae%x1f%aI%x1f%P%x1f%s%x1f%b%x1e"
+
+// Log returns commits, newest first.
+func (r *Repo) Log(ctx context.Context, opts LogOptions) ([]Commit, error) {
+	maxCount := opts.MaxCount
+	if maxCount <= 0 {
+		maxCount = 20
+	}
+	args := []string{"log", "--format=" + logFormat, fmt.Sprintf("--max-count=%d", maxCount)}
+	if opts.Author != "" {
+		args = append(args, "--author="+opts.Author)
+	}
+	if opts.Grep != "" {
+		args = append(args, "--grep="+opts.Grep, "--regexp-ignore-case")
+	}
+	if opts.Since != "" {
+		args = append(args, "--since="+opts.Since)
+	}
+	if opts.Range != "" {
+		args = append(args, opts.Range)
+	}
+	args = append(append(args, "--"), opts.Paths...)
+
+	out, err := r.run(ctx, args...)
+	if err != nil {
+		if isEmptyHistory(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parseLog(out), nil
+}
+
+// Show returns a commit with its changes.
+func (r *Repo) Show(ctx context.Context, rev string, opts DiffOptions) (*Commit, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	out, err := r.run(ctx, "show", "--no-patch", "--format="+logFormat, rev, "--")
+	if err != nil {
+		return nil, err
+	}
+	commits := parseLog(out)
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("no commit %s", rev)
+	}
+	commit := commits[0]
+
+	// First-parent changes, so merges show what they brought in
+	diff, err := r.diff(ctx, []string{"show", "--no-ext-diff", "--no-color", "--format=", "--first-parent", rev}, opts)
+	if err != nil {
+		return nil, err
+	}
+	commit.Diff = diff
+	return &commit, nil
+}
+
+// parseLog parses output produced with logFormat.
+func parseLog(out string) []Commit {
+	var commits []Commit
+	for _, record := range strings.Split(out, "\x1e") {
+		record = strings.TrimLeft(record, "\n")
+		fields := strings.Split(record, "\x1f")
+		if len(fields) != 8 {
+			continue
+		}
+		commit := Commit{
+			Hash:      fields[0],
+			ShortHash: fields[1],
+			Author:    fields[2],
+			Email:     fields[3],
+			Parents:   strings.Fields(fields[5]),
+			Subject:   fields[6],
+			Body:      strings.TrimSpace(fields[7]),
+		}
+		commit.Date, _ = time.Parse(time.RFC3339, fields[4])
+		commits = append(commits, commit)
+	}
+	return commits
+}
+
+// BlameLine is the commit that last changed one line.
+type BlameLine struct {
+	Line    int    `json:"line"`
+	Commit  string `json:"commit"` // short hash; zeros for uncommitted lines
+	Author  string `json:"author"`
+	Date    string `json:"date"` // YYYY-MM-DD
+	Summary string `json:"summary"`
+	Text    string `json:"text"`
+}
+
+// Blame returns who last changed lines start..end of path (the whole file
+// when both are zero) as of rev, or the work tree when rev is empty.
+func (r *Repo) Blame(ctx context.Context, path string, start, end int, rev string) ([]BlameLine, error) {
+	args := []string{"blame", "--porcelain"}
+	if start > 0 || end > 0 {
+		if start <= 0 {
+			start = 1
+		}
+		lineRange := strconv.Itoa(start) + ","
+		if end > 0 {
+			lineRange += strconv.Itoa(end)
+		}
+		args = append(args, "-L", lineRange)
+	}
+	if rev != "" {
+		args = append(args, rev)
+	}
+	out, err := r.run(ctx, append(args, "--", path)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseBlame(out), nil
+}
+
+// parseBlame parses "git blame --porcelain" output. Commit details are
+// only given the first time a commit appears.
+func parseBlame(out string) []BlameLine {
+	type info struct {
+		author, date, summary string
+	}
+	commits := make(map[string]*info)
+
+	var lines []BlameLine
+	var current *BlameLine
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "\t") {
+			if current != nil {
+				c := commits[current.Commit]
+				current.Author, current.Date, current.Summary = c.author, c.date, c.summary
+				current.Text = line[1:]
+				if len(current.Commit) > 8 {
+					current.Commit = current.Commit[:8]
+				}
+				lines = append(lines, *current)
+				current = nil
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && len(fields[0]) == 40 && isHex(fields[0]) {
+			final, _ := strconv.Atoi(fields[2])
+			current = &BlameLine{Line: final, Commit: fields[0]}
+			if commits[fields[0]] == nil {
+				commits[fields[0]] = &info{}
+			}
+			continue
+		}
+		if current == nil || len(fields) < 2 {
+			continue
+		}
+		c := commits[current.Commit]
+		value := strings.TrimSpace(line[len(fields[0]):])
+		switch fields[0] {
+		case "author":
+			c.author = value
+		case "author-time":
+			if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+				c.date = time.Unix(sec, 0).UTC().Format("2006-01-02")
+			}
+		case "summary":
+			c.summary = value
+		}
+	}
+	return lines
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// isEmptyHistory reports whether err is git complaining that the current
+// branch has no commits yet.
+func isEmptyHistory(err error) bool {
+	return strings.Contains(err.Error(), "does not have any commits yet")
+}
+
+// truncate cuts s to at most max bytes at a line boundary.
+func truncate(s string, max int) (string, bool) {
+	if len(s) <= max {
+		return s, false
+	}
+	cut := s[:max]
+	if i := strings.LastIndexByte(cut, '\n'); i > 0 {
+		cut = cut[:i+1]
+	}
+	return cut, true
+}
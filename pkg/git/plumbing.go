@@ -0,0 +1,169 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// GitDir returns the absolute path of the repository's git directory. For
+// a linked worktree this is the worktree's own directory under
+// .git/worktrees.
+func (r *Repo) GitDir(ctx context.Context) (string, error) {
+	out, err := r.run(ctx, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// ResolveCommit returns the full hash of the commit rev names, or "" when
+// there is no such commit.
+func (r *Repo) ResolveCommit(ctx context.Context, rev string) (string, error) {
+	out, err := r.run(ctx, "rev-parse", "--verify", "--quiet", "--end-of-options", rev+"^{commit}")
+	if err != nil {
+		var cmdErr *CommandError
+		if errors.As(err, &cmdErr) && cmdErr.Stderr == "" {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// Tree returns the hash of the tree of commit rev.
+func (r *Repo) Tree(ctx context.Context, rev string) (string, error) {
+	out, err := r.run(ctx, "rev-parse", "--verify", "--end-of-options", rev+"^{tree}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// ReadTree replaces the index with the tree of treeish, or empties it when
+// treeish is "".
+func (r *Repo) ReadTree(ctx context.Context, treeish string) error {
+	args := []string{"read-tree", "--empty"}
+	if treeish != "" {
+		args = []string{"read-tree", treeish}
+	}
+	_, err := r.run(ctx, args...)
+	return err
+}
+
+// UpdateIndex records the work tree content of paths in the index, adding
+// new files and removing the ones that no longer exist. Unlike Add it
+// takes paths literally and ignores .gitignore.
+func (r *Repo) UpdateIndex(ctx context.Context, paths ...string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	_, err := r.run(ctx, append([]string{"update-index", "--add", "--remove", "--"}, paths...)...)
+	return err
+}
+
+// WriteTree writes the index as a tree and returns its hash.
+func (r *Repo) WriteTree(ctx context.Context) (string, error) {
+	out, err := r.run(ctx, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// CommitTree creates a commit of tree with message and parents without
+// moving any branch, and returns its hash.
+func (r *Repo) CommitTree(ctx context.Context, tree, message string, parents ...string) (string, error) {
+	args := []string{"commit-tree", tree}
+	for _, parent := range parents {
+		args = append(args, "-p", parent)
+	}
+	out, err := r.runInput(ctx, message, append(args, "-F", "-")...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// UpdateRef points ref at commit. When oldCommit is set the update only
+// happens if ref still points there; "" requires that ref does not exist.
+func (r *Repo) UpdateRef(ctx context.Context, ref, commit, oldCommit string) error {
+	_, err := r.run(ctx, "update-ref", "-m", "goai", ref, commit, oldCommit)
+	return err
+}
+
+// Ref is a reference and the commit it points at.
+type Ref struct {
+	Name   string    `json:"name"`
+	Commit string    `json:"commit"`
+	Date   time.Time `json:"date"` // committer date
+}
+
+// Refs returns the references under prefix (e.g. "refs/heads/"), most
+// recently committed first.
+func (r *Repo) Refs(ctx context.Context, prefix string) ([]Ref, error) {
+	out, err := r.run(ctx, "for-each-ref", "--sort=-committerdate",
+		"--format=%(refname)%1f%(objectname)%1f%(committerdate:iso-strict)", prefix)
+	if err != nil {
+		return nil, err
+	}
+	var refs []Ref
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 3 {
+			continue
+		}
+		ref := Ref{Name: fields[0], Commit: fields[1]}
+		ref.Date, _ = time.Parse(time.RFC3339, fields[2])
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// RestoreFiles sets paths in the work tree to their content in rev and
+// deletes the ones rev does not have. The index is not changed.
+func (r *Repo) RestoreFiles(ctx context.Context, rev string, paths ...string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	out, err := r.run(ctx, append([]string{"ls-tree", "-r", "-z", "--name-only", rev, "--"}, paths...)...)
+	if err != nil {
+		return err
+	}
+	present := splitNul(out)
+	if len(present) > 0 {
+		if _, err := r.run(ctx, append([]string{"restore", "--source=" + rev, "--worktree", "--"}, present...)...); err != nil {
+			return err
+		}
+	}
+
+	inRev := make(map[string]bool, len(present))
+	for _, path := range present {
+		inRev[path] = true
+	}
+	for _, path := range paths {
+		if inRev[path] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(r.dir, path)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// CherryPick applies the changes of commit to the work tree and index.
+// Unless noCommit is set they are committed on the current branch with the
+// original message; git stops and leaves the conflict to resolve when they
+// do not apply cleanly.
+func (r *Repo) CherryPick(ctx context.Context, commit string, noCommit bool) error {
+	args := []string{"cherry-pick"}
+	if noCommit {
+		args = append(args, "--no-commit")
+	}
+	_, err := r.run(ctx, append(args, commit)...)
+	return err
+}
@@ -0,0 +1,278 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// globalOptionsWithValue are git options before the subcommand that take
+// the next argument as their value.
+var globalOptionsWithValue = map[string]bool{
+	"-C": true, "-c": true, "--git-dir": true, "--work-tree": true,
+	"--namespace": true, "--super-prefix": true, "--config-env": true,
+}
+
+// builtinCommands are git commands that aliases cannot replace, so they
+// are never looked up as aliases.
+var builtinCommands = map[string]bool{
+	"add": true, "am": true, "apply": true, "archive": true, "bisect": true, "blame": true,
+	"branch": true, "bundle": true, "cat-file": true, "checkout": true, "cherry-pick": true,
+	"clean": true, "clone": true, "commit": true, "config": true, "describe": true, "diff": true,
+	"fetch": true, "filter-branch": true, "for-each-ref": true, "format-patch": true, "gc": true,
+	"grep": true, "help": true, "init": true, "log": true, "ls-files": true, "ls-remote": true,
+	"ls-tree": true, "merge": true, "merge-base": true, "mv": true, "notes": true, "pull": true,
+	"push": true, "range-diff": true, "rebase": true, "reflog": true, "remote": true, "reset": true,
+	"restore": true, "rev-list": true, "rev-parse": true, "revert": true, "rm": true,
+	"shortlog": true, "show": true, "show-ref": true, "stash": true, "status": true,
+	"submodule": true, "switch": true, "symbolic-ref": true, "tag": true, "update-ref": true,
+	"version": true, "worktree": true,
+}
+
+// maxAliasDepth bounds the expansion of aliases defined through other
+// aliases.
+const maxAliasDepth = 5
+
+// DestructiveCommand reports why running git with args (without the "git"
+// itself) would discard uncommitted work, delete unmerged commits or
+// rewrite history. It returns "" for commands that do none of these.
+// Rebasing is destructive; "pull --rebase", which only replays local
+// commits onto the upstream, is not. Aliases defined with -c or
+// --config-env are refused. Only the command line is inspected;
+// DestructiveCommandIn also recognizes checkouts of files that exist in the
+// work tree and aliases from the repository's configuration.
+func DestructiveCommand(args []string) string {
+	return DestructiveCommandIn("", args)
+}
+
+// DestructiveCommandIn is like DestructiveCommand for git run in dir: an
+// operand of checkout naming a file or directory in dir counts as a path,
+// so "git checkout main.go" is destructive, and an alias configured for
+// dir is expanded and checked in turn. Aliases that run a shell command
+// ("!...") cannot be checked and are refused. An empty dir skips both.
+func DestructiveCommandIn(dir string, args []string) string {
+	return destructiveCommand(dir, args, 0)
+}
+
+func destructiveCommand(dir string, args []string, depth int) string {
+	i := 0
+	for i < len(args) && strings.HasPrefix(args[i], "-") {
+		switch {
+		case args[i] == "-C" && i+1 < len(args) && dir != "":
+			if filepath.IsAbs(args[i+1]) {
+				dir = args[i+1]
+			} else {
+				dir = filepath.Join(dir, args[i+1])
+			}
+		case (args[i] == "-c" || args[i] == "--config-env") && i+1 < len(args) && isAliasKey(args[i+1]):
+			return "alias defined with " + args[i]
+		case strings.HasPrefix(args[i], "--config-env=") && isAliasKey(strings.TrimPrefix(args[i], "--config-env=")):
+			return "alias defined with --config-env"
+		}
+		if globalOptionsWithValue[args[i]] {
+			i++
+		}
+		i++
+	}
+	if i >= len(args) {
+		return ""
+	}
+	sub, rest := args[i], args[i+1:]
+
+	if alias := lookupAlias(dir, sub); alias != "" {
+		if strings.HasPrefix(alias, "!") {
+			return "shell alias " + sub
+		}
+		if depth >= maxAliasDepth {
+			return "alias " + sub + " nested too deeply"
+		}
+		expanded := append(strings.Fields(alias), rest...)
+		if reason := destructiveCommand(dir, expanded, depth+1); reason != "" {
+			return reason + " (through alias " + sub + ")"
+		}
+		return ""
+	}
+
+	flags, operands := splitArgs(rest)
+	has := func(short byte, long ...string) bool {
+		return hasFlag(flags, short, long...)
+	}
+
+	switch sub {
+	case "push":
+		if has('f', "--force", "--force-with-lease", "--force-if-includes", "--mirror") {
+			return "push --force"
+		}
+		if has('d', "--delete", "--prune") {
+			return "push --delete"
+		}
+		for _, refspec := range operands {
+			if strings.HasPrefix(refspec, "+") {
+				return "forced push refspec " + refspec
+			}
+			if strings.HasPrefix(refspec, ":") {
+				return "push deleting " + strings.TrimPrefix(refspec, ":")
+			}
+		}
+	case "reset":
+		if has(0, "--hard", "--merge", "--keep") {
+			return "reset --hard"
+		}
+	case "clean":
+		if has('f', "--force") && !has('n', "--dry-run") {
+			return "clean --force"
+		}
+	case "checkout":
+		if has('f', "--force") {
+			return "checkout --force"
+		}
+		if checkoutPaths(dir, rest) {
+			return "checkout discarding changes to paths"
+		}
+	case "restore":
+		if !has('S', "--staged") || has('W', "--worktree") {
+			return "restore of work tree files"
+		}
+	case "switch":
+		if has('f', "--force", "--discard-changes") {
+			return "switch --discard-changes"
+		}
+		if has('C', "--force-create") {
+			return "switch --force-create"
+		}
+	case "branch":
+		if has('D') || has('M') || has('C') {
+			return "branch with forced delete, move or copy"
+		}
+		if has('f', "--force") {
+			return "branch --force"
+		}
+	case "stash":
+		if len(operands) > 0 && (operands[0] == "drop" || operands[0] == "clear") {
+			return "stash " + operands[0]
+		}
+	case "commit":
+		if has(0, "--amend") {
+			return "commit --amend"
+		}
+	case "update-ref":
+		if has('d') {
+			return "update-ref -d"
+		}
+	case "reflog":
+		if len(operands) > 0 && (operands[0] == "expire" || operands[0] == "delete") {
+			return "reflog " + operands[0]
+		}
+	case "rebase":
+		if !has(0, "--abort", "--quit", "--continue", "--edit-todo", "--show-current-patch") {
+			return "rebase rewriting history"
+		}
+	case "filter-branch", "filter-repo":
+		return sub
+	}
+	return ""
+}
+
+// isAliasKey reports whether a -c or --config-env value sets an alias.
+func isAliasKey(value string) bool {
+	return strings.HasPrefix(strings.ToLower(value), "alias.")
+}
+
+// lookupAlias returns the alias configured for sub in the repository at
+// dir, or "" if there is none or dir is empty.
+func lookupAlias(dir, sub string) string {
+	if dir == "" || builtinCommands[sub] {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	out, err := New(dir).run(ctx, "config", "--get", "alias."+sub)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// checkoutPaths reports whether checkout with args overwrites work tree
+// files rather than only switching branches. Operands after "--", a commit
+// followed by paths, ".", pathspecs and operands that exist in dir are
+// paths.
+func checkoutPaths(dir string, args []string) bool {
+	var operands []string
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			return len(args) > i+1
+		case arg == "-b" || arg == "-B" || arg == "--orphan":
+			// Creates a branch: the operands are its name and start point
+			return false
+		case strings.HasPrefix(arg, "-") && arg != "-":
+		default:
+			operands = append(operands, arg)
+		}
+	}
+	switch len(operands) {
+	case 0:
+		return false
+	case 1:
+		return isPathOperand(dir, operands[0])
+	default:
+		// "git checkout <commit> <path>..." or several paths
+		return true
+	}
+}
+
+// isPathOperand reports whether a checkout operand names files rather
+// than a branch or commit.
+func isPathOperand(dir, operand string) bool {
+	if operand == "." || operand == ".." || strings.HasPrefix(operand, "./") || strings.HasPrefix(operand, "../") {
+		return true
+	}
+	// Ref names cannot contain glob characters, and ":(...)" and ":/" are
+	// pathspec magic
+	if strings.ContainsAny(operand, "*?[") || strings.HasPrefix(operand, ":(") || operand == ":/" {
+		return true
+	}
+	if dir == "" {
+		return false
+	}
+	_, err := os.Lstat(filepath.Join(dir, operand))
+	return err == nil
+}
+
+// splitArgs separates option arguments from operands. Everything after
+// "--" is an operand.
+func splitArgs(args []string) (flags, operands []string) {
+	for i, arg := range args {
+		if arg == "--" {
+			return flags, append(operands, args[i+1:]...)
+		}
+		if strings.HasPrefix(arg, "-") && arg != "-" {
+			flags = append(flags, arg)
+		} else {
+			operands = append(operands, arg)
+		}
+	}
+	return flags, operands
+}
+
+// hasFlag reports whether flags contain short (also combined, as in
+// "-fdx") or one of the long options (also as "--opt=value").
+func hasFlag(flags []string, short byte, long ...string) bool {
+	for _, flag := range flags {
+		if strings.HasPrefix(flag, "--") {
+			for _, l := range long {
+				if flag == l || strings.HasPrefix(flag, l+"=") {
+					return true
+				}
+			}
+			continue
+		}
+		if short != 0 && strings.IndexByte(flag[1:], short) >= 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package git
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// File states reported by Status.
+const (
+	StateModified   = "modified"
+	StateAdded      = "added"
+	StateDeleted    = "deleted"
+	StateRenamed    = "renamed"
+	StateCopied     = "copied"
+	StateTypeChange = "type_changed"
+	StateUntracked  = "untracked"
+	StateConflict   = "conflict"
+)
+
+// Status is the state of the work tree and index.
+type Status struct {
+	Head      Head         `json:"head"`
+	Upstream  string       `json:"upstream,omitempty"`
+	Ahead     int          `json:"ahead,omitempty"`
+	Behind    int          `json:"behind,omitempty"`
+	Staged    []FileStatus `json:"staged,omitempty"`
+	Unstaged  []FileStatus `json:"unstaged,omitempty"`
+	Untracked []string     `json:"untracked,omitempty"`
+	Conflicts []string     `json:"conflicts,omitempty"`
+}
+
+// FileStatus is a changed path. OrigPath is set for renames and copies.
+type FileStatus struct {
+	Path     string `json:"path"`
+	OrigPath string `json:"orig_path,omitempty"`
+	State    string `json:"state"`
+}
+
+// Clean reports whether there is nothing to commit and no untracked file.
+func (s *Status) Clean() bool {
+	return len(s.Staged)+len(s.Unstaged)+len(s.Untracked)+len(s.Conflicts) == 0
+}
+
+// Status returns the branch, upstream tracking and changed files.
+func (r *Repo) Status(ctx context.Context) (*Status, error) {
+	out, err := r.run(ctx, "status", "--porcelain=v2", "--branch", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+	return parseStatus(out), nil
+}
+
+// parseStatus parses "git status --porcelain=v2 --branch -z" output.
+func parseStatus(out string) *Status {
+	status := &Status{}
+	entries := splitNul(out)
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if entry == "" {
+			continue
+		}
+		switch entry[0] {
+		case '#':
+			parseBranchHeader(status, entry)
+		case '1', '2':
+			// "1 XY sub mH mI mW hH hI path"
+			// "2 XY sub mH mI mW hH hI Xscore path" followed by the original path
+			fieldCount := 9
+			if entry[0] == '2' {
+				fieldCount = 10
+			}
+			fields := strings.SplitN(entry, " ", fieldCount)
+			if len(fields) < fieldCount {
+				continue
+			}
+			file := FileStatus{Path: fields[fieldCount-1]}
+			if entry[0] == '2' && i+1 < len(entries) {
+				i++
+				file.OrigPath = entries[i]
+			}
+			xy := fields[1]
+			if xy[0] != '.' {
+				staged := file
+				staged.State = stateName(xy[0])
+				status.Staged = append(status.Staged, staged)
+			}
+			if xy[1] != '.' {
+				unstaged := file
+				unstaged.State = stateName(xy[1])
+				status.Unstaged = append(status.Unstaged, unstaged)
+			}
+		case 'u':
+			// "u XY sub m1 m2 m3 mW h1 h2 h3 path"
+			fields := strings.SplitN(entry, " ", 11)
+			if len(fields) == 11 {
+				status.Conflicts = append(status.Conflicts, fields[10])
+			}
+		case '?':
+			status.Untracked = append(status.Untracked, strings.TrimPrefix(entry, "? "))
+		}
+	}
+	return status
+}
+
+// parseBranchHeader parses a "# branch.*" header line.
+func parseBranchHeader(status *Status, line string) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return
+	}
+	switch fields[1] {
+	case "branch.oid":
+		if fields[2] != "(initial)" && len(fields[2]) >= 7 {
+			status.Head.Commit = fields[2][:7]
+		}
+	case "branch.head":
+		if fields[2] == "(detached)" {
+			status.Head.Detached = true
+		} else {
+			status.Head.Branch = fields[2]
+		}
+	case "branch.upstream":
+		status.Upstream = fields[2]
+	case "branch.ab":
+		if len(fields) >= 4 {
+			status.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "+"))
+			status.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[3], "-"))
+		}
+	}
+}
+
+// stateName maps a porcelain status letter to a state.
+func stateName(c byte) string {
+	switch c {
+	case 'A':
+		return StateAdded
+	case 'D':
+		return StateDeleted
+	case 'R':
+		return StateRenamed
+	case 'C':
+		return StateCopied
+	case 'T':
+		return StateTypeChange
+	}
+	return StateModified
+}
@@ -0,0 +1,60 @@
+package git
+
+import (
+	"context"
+	"strings"
+)
+
+// AddWorktree checks commit out into a new linked worktree at path with a
+// detached HEAD, so no branch is created or moved.
+func (r *Repo) AddWorktree(ctx context.Context, path, commit string) error {
+	_, err := r.run(ctx, "worktree", "add", "--detach", "--quiet", path, commit)
+	return err
+}
+
+// RemoveWorktree deletes the linked worktree at path, with any changes in
+// it, and forgets it.
+func (r *Repo) RemoveWorktree(ctx context.Context, path string) error {
+	_, err := r.run(ctx, "worktree", "remove", "--force", path)
+	return err
+}
+
+// Worktrees returns the paths of the repository's worktrees, the main one
+// first.
+func (r *Repo) Worktrees(ctx context.Context) ([]string, error) {
+	out, err := r.run(ctx, "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, line := range strings.Split(out, "\n") {
+		if path, ok := strings.CutPrefix(line, "worktree "); ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// Snapshot returns a commit of the work tree's tracked files with their
+// local changes, staged or not, without touching the index, the work tree
+// or the stash list. It is HEAD when there are no local changes.
+func (r *Repo) Snapshot(ctx context.Context) (string, error) {
+	out, err := r.run(ctx, "stash", "create")
+	if err != nil {
+		return "", err
+	}
+	if hash := strings.TrimSpace(out); hash != "" {
+		return hash, nil
+	}
+	return r.ResolveCommit(ctx, "HEAD")
+}
+
+// Apply applies patch, as produced by a diff with Binary set, to the work
+// tree. Nothing is changed when any part of it does not apply.
+func (r *Repo) Apply(ctx context.Context, patch string) error {
+	if patch == "" {
+		return nil
+	}
+	_, err := r.runInput(ctx, patch, "apply", "--whitespace=nowarn", "-")
+	return err
+}
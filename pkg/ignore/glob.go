@@ -0,0 +1,75 @@
+package ignore
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Glob is a compiled file pattern. "*" and "?" match within one path
+// segment and "**" matches any number of segments. A pattern without a
+// slash matches the base name at any depth; otherwise it matches the whole
+// slash-separated path relative to the walk root.
+type Glob struct {
+	pattern string
+	re      *regexp.Regexp
+	prefix  string
+}
+
+// CompileGlob compiles pattern.
+func CompileGlob(pattern string) (*Glob, error) {
+	pattern = strings.TrimPrefix(path.Clean(strings.TrimSpace(pattern)), "./")
+	if pattern == "" || pattern == "." {
+		return nil, fmt.Errorf("pattern cannot be empty")
+	}
+	if strings.HasPrefix(pattern, "/") || pattern == ".." || strings.HasPrefix(pattern, "../") {
+		return nil, fmt.Errorf("pattern must be relative to the work directory: %s", pattern)
+	}
+
+	expr := globToRegexp(pattern)
+	if !strings.Contains(pattern, "/") {
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	// The leading segments without wildcards name the only directory that
+	// can hold matches
+	var static []string
+	segments := strings.Split(pattern, "/")
+	for _, seg := range segments[:len(segments)-1] {
+		if strings.ContainsAny(seg, `*?[\`) {
+			break
+		}
+		static = append(static, seg)
+	}
+	return &Glob{pattern: pattern, re: re, prefix: strings.Join(static, "/")}, nil
+}
+
+// String returns the pattern.
+func (g *Glob) String() string {
+	return g.pattern
+}
+
+// Match reports whether rel matches the pattern.
+func (g *Glob) Match(rel string) bool {
+	return g.re.MatchString(rel)
+}
+
+// Prefix returns the directory every match lies under, "" if matches can
+// be anywhere.
+func (g *Glob) Prefix() string {
+	return g.prefix
+}
+
+// CanContain reports whether the directory rel can hold matches, so walks
+// can skip the directories that cannot.
+func (g *Glob) CanContain(dir string) bool {
+	if g.prefix == "" || dir == g.prefix {
+		return true
+	}
+	return strings.HasPrefix(g.prefix, dir+"/") || strings.HasPrefix(dir, g.prefix+"/")
+}
@@ -0,0 +1,208 @@
+// Package ignore implements gitignore-style path filtering and a directory
+// walker that honors it.
+//
+// Rules are loaded per directory from .gitignore, .ignore and .goaiignore
+// files and apply to paths below the directory that defined them. Deeper
+// files take precedence over shallower ones and, within one file, the last
+// matching pattern wins, exactly as in git. Negated patterns ("!keep.log")
+// re-include paths, and a trailing slash restricts a pattern to directories.
+package ignore
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultFiles are the per-directory ignore files read by the walker.
+// .goaiignore holds patterns only goai should skip.
+var DefaultFiles = []string{".gitignore", ".ignore", ".goaiignore"}
+
+// rule is a single compiled ignore pattern.
+type rule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Matcher decides whether a path is ignored. Matchers form a chain from the
+// innermost directory to the root; each link holds the rules of one
+// directory and matches paths relative to it.
+type Matcher struct {
+	parent *Matcher
+	base   string // slash-separated directory relative to the walk root, "" for the root
+	rules  []rule
+}
+
+// New creates a root matcher from patterns written in gitignore syntax.
+func New(patterns ...string) *Matcher {
+	m := &Matcher{}
+	m.AddPatterns(patterns...)
+	return m
+}
+
+// AddPatterns compiles and appends gitignore-style patterns to the matcher.
+// Blank lines and comments are skipped.
+func (m *Matcher) AddPatterns(patterns ...string) {
+	for _, p := range patterns {
+		if r, ok := compile(p); ok {
+			m.rules = append(m.rules, r)
+		}
+	}
+}
+
+// AddFile appends the patterns from an ignore file. A missing file is not
+// an error.
+func (m *Matcher) AddFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer func() {
+		_ = f.Close() // Read-only, close error is not actionable
+	}()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m.AddPatterns(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// Child returns the matcher for the directory rel (slash-separated, relative
+// to the walk root) after loading the given ignore files from dir. When the
+// directory defines no rules the receiver itself is returned.
+func (m *Matcher) Child(dir, rel string, files []string) *Matcher {
+	child := &Matcher{parent: m, base: rel}
+	for _, name := range files {
+		_ = child.AddFile(filepath.Join(dir, name)) // Unreadable ignore files are skipped
+	}
+	if len(child.rules) == 0 {
+		return m
+	}
+	return child
+}
+
+// Match reports whether rel (slash-separated, relative to the walk root) is
+// ignored. isDir must be true when rel names a directory so that patterns
+// with a trailing slash apply.
+func (m *Matcher) Match(rel string, isDir bool) bool {
+	for cur := m; cur != nil; cur = cur.parent {
+		local := rel
+		if cur.base != "" {
+			if !strings.HasPrefix(rel, cur.base+"/") {
+				continue
+			}
+			local = rel[len(cur.base)+1:]
+		}
+		if ignored, decided := cur.match(local, isDir); decided {
+			return ignored
+		}
+	}
+	return false
+}
+
+// match applies the rules of a single directory. The last matching rule
+// decides; decided is false when no rule matched.
+func (m *Matcher) match(local string, isDir bool) (ignored, decided bool) {
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		r := m.rules[i]
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.re.MatchString(local) {
+			return !r.negate, true
+		}
+	}
+	return false, false
+}
+
+// compile converts one line of an ignore file into a rule.
+func compile(line string) (rule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+
+	var r rule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule{}, false
+	}
+
+	// A slash anywhere but the end anchors the pattern to the directory
+	// holding the ignore file; otherwise it matches at any depth.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return rule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+// globToRegexp translates gitignore glob syntax into a regular expression.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				switch {
+				case i+2 < len(glob) && glob[i+2] == '/':
+					b.WriteString("(?:.*/)?")
+					i += 2
+				default:
+					b.WriteString(".*")
+					i++
+				}
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,186 @@
+package ignore
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Zerofisher/goai/internal/testutil"
+)
+
+// TestMatcher tests gitignore pattern semantics
+func TestMatcher(t *testing.T) {
+	m := New(
+		"# comment",
+		"*.log",
+		"!keep.log",
+		"build/",
+		"/root-only.txt",
+		"docs/*.tmp",
+		"**/generated/**",
+		"secret?.env",
+		`\#hash`,
+	)
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"nested/dir/app.log", false, true},
+		{"keep.log", false, false},
+		{"nested/keep.log", false, false},
+		{"build", true, true},
+		{"src/build", true, true},
+		{"build", false, false},
+		{"root-only.txt", false, true},
+		{"sub/root-only.txt", false, false},
+		{"docs/a.tmp", false, true},
+		{"docs/sub/a.tmp", false, false},
+		{"a/generated/b/c.go", false, true},
+		{"secret1.env", false, true},
+		{"secret12.env", false, false},
+		{"#hash", false, true},
+		{"main.go", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := m.Match(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("Match(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMatcherChild tests that nested ignore files apply relative to their
+// directory and override parent rules
+func TestMatcherChild(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFile(t, dir, "sub/.gitignore", "!important.log\nlocal.txt\n")
+
+	root := New("*.log")
+	child := root.Child(filepath.Join(dir, "sub"), "sub", DefaultFiles)
+	if child == root {
+		t.Fatal("Expected a new matcher for a directory with rules")
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"sub/important.log", false},
+		{"sub/other.log", true},
+		{"sub/local.txt", true},
+		{"local.txt", false},
+		{"important.log", true},
+	}
+	for _, tt := range tests {
+		if got := child.Match(tt.path, false); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	if same := root.Child(dir, "", DefaultFiles); same != root {
+		t.Error("Expected the parent matcher for a directory without rules")
+	}
+}
+
+// TestWalk tests that Walk honors ignore files, excludes and hidden files
+func TestWalk(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFile(t, dir, ".gitignore", "dist/\n*.out\n")
+	testutil.WriteFile(t, dir, "main.go", "package main")
+	testutil.WriteFile(t, dir, "run.out", "binary")
+	testutil.WriteFile(t, dir, "dist/bundle.js", "x")
+	testutil.WriteFile(t, dir, "pkg/.ignore", "fixtures\n")
+	testutil.WriteFile(t, dir, "pkg/lib.go", "package pkg")
+	testutil.WriteFile(t, dir, "pkg/fixtures/data.txt", "x")
+	testutil.WriteFile(t, dir, "node_modules/mod.js", "x")
+	testutil.WriteFile(t, dir, ".hidden/file.txt", "x")
+	testutil.WriteFile(t, dir, ".git/HEAD", "ref")
+
+	collect := func(opts WalkOptions) []string {
+		var files []string
+		err := Walk(context.Background(), dir, opts, func(rel string, d fs.DirEntry) error {
+			if !d.IsDir() {
+				files = append(files, rel)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Walk failed: %v", err)
+		}
+		return files
+	}
+
+	got := collect(WalkOptions{Excludes: []string{"node_modules"}})
+	want := []string{"main.go", "pkg/lib.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk() = %v, want %v", got, want)
+	}
+
+	got = collect(WalkOptions{IncludeHidden: true, Files: []string{}})
+	want = []string{
+		".gitignore", ".hidden/file.txt", "dist/bundle.js", "main.go",
+		"node_modules/mod.js", "pkg/.ignore", "pkg/fixtures/data.txt", "pkg/lib.go", "run.out",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk() without ignore files = %v, want %v", got, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Walk(ctx, dir, WalkOptions{}, func(string, fs.DirEntry) error { return nil }); err == nil {
+		t.Error("Expected error for canceled context")
+	}
+}
+
+// TestGlob tests glob matching and directory pruning
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "pkg/a/b.go", true},
+		{"*.go", "pkg/a/b.gox", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "pkg/a/b.go", true},
+		{"pkg/**/*_test.go", "pkg/a/b_test.go", true},
+		{"pkg/**/*_test.go", "pkg/b_test.go", true},
+		{"pkg/**/*_test.go", "cmd/b_test.go", false},
+		{"pkg/*.go", "pkg/a/b.go", false},
+		{"./docs/**", "docs/a/b.md", true},
+		{"src/[ab].ts", "src/b.ts", true},
+	}
+	for _, tt := range tests {
+		g, err := CompileGlob(tt.pattern)
+		if err != nil {
+			t.Fatalf("CompileGlob(%q): %v", tt.pattern, err)
+		}
+		if got := g.Match(tt.path); got != tt.want {
+			t.Errorf("%q.Match(%q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+
+	g, _ := CompileGlob("pkg/tools/**/*.go")
+	if g.Prefix() != "pkg/tools" {
+		t.Errorf("Prefix() = %q", g.Prefix())
+	}
+	for dir, want := range map[string]bool{"pkg": true, "pkg/tools": true, "pkg/tools/file": true, "cmd": false, "pkg/agent": false} {
+		if got := g.CanContain(dir); got != want {
+			t.Errorf("CanContain(%q) = %v, want %v", dir, got, want)
+		}
+	}
+
+	for _, bad := range []string{"", "/etc/*", "../*.go"} {
+		if _, err := CompileGlob(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
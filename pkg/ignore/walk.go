@@ -0,0 +1,99 @@
+package ignore
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WalkOptions configures Walk.
+type WalkOptions struct {
+	// IncludeHidden walks dot files and dot directories. The .git directory
+	// is always skipped.
+	IncludeHidden bool
+
+	// Excludes are extra gitignore-style patterns applied from the root,
+	// typically taken from configuration.
+	Excludes []string
+
+	// Files names the per-directory ignore files to honor. Nil means
+	// DefaultFiles; an empty non-nil slice disables ignore files.
+	Files []string
+}
+
+// WalkFunc is called for every file and directory that is not ignored. rel
+// is the slash-separated path relative to the root. Returning
+// filepath.SkipDir from a directory skips it.
+type WalkFunc func(rel string, d fs.DirEntry) error
+
+// Walk walks root in lexical order, skipping everything matched by the
+// configured excludes, .git/info/exclude and the ignore files found along
+// the way. It stops early when ctx is done.
+func Walk(ctx context.Context, root string, opts WalkOptions, fn WalkFunc) error {
+	files := opts.Files
+	if files == nil {
+		files = DefaultFiles
+	}
+
+	rootMatcher := New(opts.Excludes...)
+	_ = rootMatcher.AddFile(filepath.Join(root, ".git", "info", "exclude")) // Optional
+	matchers := map[string]*Matcher{"": rootMatcher.Child(root, "", files)}
+
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			if p == root {
+				return err
+			}
+			return nil // Skip unreadable entries
+		}
+		if p == root {
+			return nil
+		}
+
+		rel, relErr := filepath.Rel(root, p)
+		if relErr != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		parent := parentDir(rel)
+		m := matchers[parent]
+
+		name := d.Name()
+		if d.IsDir() && name == ".git" {
+			return filepath.SkipDir
+		}
+		if !opts.IncludeHidden && strings.HasPrefix(name, ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if m.Match(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			matchers[rel] = m.Child(p, rel, files)
+		} else if d.Type()&os.ModeSymlink != 0 {
+			return nil // Symlinks are not followed
+		}
+		return fn(rel, d)
+	})
+}
+
+// parentDir returns the slash-separated parent of rel, "" for top-level
+// entries.
+func parentDir(rel string) string {
+	if i := strings.LastIndexByte(rel, '/'); i >= 0 {
+		return rel[:i]
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Embedder turns text into vectors whose cosine similarity reflects how
+// close the texts are in meaning.
+type Embedder interface {
+	// Embed returns one vector per text, in order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+
+	// Model identifies the vector space; vectors from different models
+	// cannot be compared
+	Model() string
+}
+
+// EmbedderConfig represents configuration for an embedder
+type EmbedderConfig struct {
+	Provider   string        `json:"provider"`
+	APIKey     string        `json:"api_key"`
+	BaseURL    string        `json:"base_url,omitempty"`
+	Model      string        `json:"model"`
+	Dimensions int           `json:"dimensions,omitempty"` // 0 means the model's default
+	Timeout    time.Duration `json:"timeout"`
+}
+
+// EmbedderFactory is a function that creates a new embedder
+type EmbedderFactory func(config EmbedderConfig) (Embedder, error)
+
+var (
+	embedderMu        sync.RWMutex
+	embedderFactories = map[string]EmbedderFactory{
+		"local": func(config EmbedderConfig) (Embedder, error) {
+			return NewHashEmbedder(config.Dimensions), nil
+		},
+	}
+)
+
+// RegisterEmbedderFactory registers an embedder factory for a provider
+func RegisterEmbedderFactory(provider string, factory EmbedderFactory) {
+	embedderMu.Lock()
+	defer embedderMu.Unlock()
+	embedderFactories[provider] = factory
+}
+
+// CreateEmbedder creates a new embedder based on the provider
+func CreateEmbedder(config EmbedderConfig) (Embedder, error) {
+	embedderMu.RLock()
+	factory, exists := embedderFactories[config.Provider]
+	embedderMu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unknown embedding provider: %s", config.Provider)
+	}
+
+	return factory(config)
+}
@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// TestHashEmbedder tests that the local embedder is deterministic and
+// places texts with shared words closer together
+func TestHashEmbedder(t *testing.T) {
+	embedder, err := CreateEmbedder(EmbedderConfig{Provider: "local", Dimensions: 128})
+	if err != nil {
+		t.Fatalf("CreateEmbedder failed: %v", err)
+	}
+	if embedder.Model() != "local-hash-128" {
+		t.Errorf("Model() = %q, want local-hash-128", embedder.Model())
+	}
+
+	texts := []string{
+		"func RefreshToken renews the auth token",
+		"refresh expired auth tokens",
+		"render the markdown table",
+		"",
+	}
+	vectors, err := embedder.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(vectors) != len(texts) {
+		t.Fatalf("got %d vectors, want %d", len(vectors), len(texts))
+	}
+
+	again, _ := embedder.Embed(context.Background(), texts[:1])
+	if dot(vectors[0], again[0]) < 0.9999 {
+		t.Error("expected the same text to give the same vector")
+	}
+	if n := dot(vectors[0], vectors[0]); math.Abs(n-1) > 1e-5 {
+		t.Errorf("expected a unit vector, got squared norm %f", n)
+	}
+	if dot(vectors[3], vectors[3]) != 0 {
+		t.Error("expected a zero vector for empty text")
+	}
+
+	related, unrelated := dot(vectors[0], vectors[1]), dot(vectors[0], vectors[2])
+	if related <= unrelated {
+		t.Errorf("similarity of related texts %f not above unrelated %f", related, unrelated)
+	}
+
+	if _, err := CreateEmbedder(EmbedderConfig{Provider: "unknown"}); err == nil {
+		t.Error("expected an error for an unknown provider")
+	}
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
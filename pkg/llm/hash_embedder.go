@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// DefaultHashDimensions is the vector size of a HashEmbedder created
+// without one.
+const DefaultHashDimensions = 256
+
+// HashEmbedder is a local, deterministic embedder that needs no model or
+// network. Each word and identifier part is hashed into one of a fixed
+// number of dimensions, so texts sharing vocabulary get similar vectors.
+// It captures no meaning beyond shared words and is meant for tests and
+// offline use.
+type HashEmbedder struct {
+	dims int
+}
+
+// NewHashEmbedder creates a hash embedder with the given vector size;
+// dims <= 0 uses DefaultHashDimensions.
+func NewHashEmbedder(dims int) *HashEmbedder {
+	if dims <= 0 {
+		dims = DefaultHashDimensions
+	}
+	return &HashEmbedder{dims: dims}
+}
+
+// Model returns the name of the vector space.
+func (e *HashEmbedder) Model() string {
+	return fmt.Sprintf("local-hash-%d", e.dims)
+}
+
+// Embed returns a unit-length vector per text. Empty texts and texts
+// without words yield zero vectors.
+func (e *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+// embed hashes the words of text into a normalized vector.
+func (e *HashEmbedder) embed(text string) []float32 {
+	vec := make([]float32, e.dims)
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		for _, part := range splitWord(word) {
+			if len(part) < 2 {
+				continue
+			}
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(strings.ToLower(part)))
+			sum := h.Sum32()
+			// The top bit picks the sign so collisions tend to cancel out
+			if sum&(1<<31) != 0 {
+				vec[sum%uint32(e.dims)]--
+			} else {
+				vec[sum%uint32(e.dims)]++
+			}
+		}
+	}
+
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v) * float64(v)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vec {
+			vec[i] *= scale
+		}
+	}
+	return vec
+}
+
+// splitWord splits a camelCase word into its parts, keeping a trailing
+// plural "s" off so "tokens" and "Token" share a dimension.
+func splitWord(word string) []string {
+	var parts []string
+	runes := []rune(word)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		if unicode.IsUpper(runes[i]) && !unicode.IsUpper(runes[i-1]) {
+			parts = append(parts, string(runes[start:i]))
+			start = i
+		}
+	}
+	parts = append(parts, string(runes[start:]))
+	for i, part := range parts {
+		if len(part) > 3 && strings.HasSuffix(part, "s") && !strings.HasSuffix(part, "ss") {
+			parts[i] = part[:len(part)-1]
+		}
+	}
+	return parts
+}
@@ -0,0 +1,99 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Zerofisher/goai/pkg/llm"
+	openaisdk "github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+)
+
+// embedBatchSize is the number of texts sent per embeddings request.
+const embedBatchSize = 96
+
+// Embedder implements llm.Embedder with the OpenAI embeddings API or any
+// endpoint compatible with it (for example Ollama or LM Studio).
+type Embedder struct {
+	client     *openaisdk.Client
+	model      string
+	dimensions int
+}
+
+// NewEmbedder creates an embedder. An API key is required unless a custom
+// base URL is given, since local servers usually do not check it.
+func NewEmbedder(config llm.EmbedderConfig) (llm.Embedder, error) {
+	if config.APIKey == "" && config.BaseURL == "" {
+		return nil, fmt.Errorf("openai api key is required for embeddings")
+	}
+
+	opts := []option.RequestOption{
+		option.WithAPIKey(config.APIKey),
+	}
+
+	if config.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(config.BaseURL))
+	}
+
+	if config.Timeout > 0 {
+		opts = append(opts, option.WithRequestTimeout(config.Timeout))
+	}
+
+	sdkClient := openaisdk.NewClient(opts...)
+
+	model := config.Model
+	if model == "" {
+		model = openaisdk.EmbeddingModelTextEmbedding3Small
+	}
+
+	return &Embedder{
+		client:     &sdkClient,
+		model:      model,
+		dimensions: config.Dimensions,
+	}, nil
+}
+
+// Model returns the embedding model, with the dimensions when set.
+func (e *Embedder) Model() string {
+	if e.dimensions > 0 {
+		return fmt.Sprintf("%s@%d", e.model, e.dimensions)
+	}
+	return e.model
+}
+
+// Embed returns one vector per text, sending them in batches.
+func (e *Embedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += embedBatchSize {
+		end := min(start+embedBatchSize, len(texts))
+		params := openaisdk.EmbeddingNewParams{
+			Input: openaisdk.EmbeddingNewParamsInputUnion{OfArrayOfStrings: texts[start:end]},
+			Model: e.model,
+		}
+		if e.dimensions > 0 {
+			params.Dimensions = openaisdk.Int(int64(e.dimensions))
+		}
+
+		resp, err := e.client.Embeddings.New(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("openai embeddings error: %w", err)
+		}
+		if len(resp.Data) != end-start {
+			return nil, fmt.Errorf("openai embeddings returned %d vectors for %d texts", len(resp.Data), end-start)
+		}
+
+		batch := make([][]float32, end-start)
+		for _, d := range resp.Data {
+			if d.Index < 0 || int(d.Index) >= len(batch) {
+				return nil, fmt.Errorf("openai embeddings returned index %d out of range", d.Index)
+			}
+			vec := make([]float32, len(d.Embedding))
+			for i, v := range d.Embedding {
+				vec[i] = float32(v)
+			}
+			batch[d.Index] = vec
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
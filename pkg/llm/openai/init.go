@@ -9,4 +9,7 @@ func init() {
 	llm.RegisterClientFactory("openai", func(config llm.ClientConfig) (llm.Client, error) {
 		return NewClient(config)
 	})
+	llm.RegisterEmbedderFactory("openai", func(config llm.EmbedderConfig) (llm.Embedder, error) {
+		return NewEmbedder(config)
+	})
 }
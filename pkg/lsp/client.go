@@ -0,0 +1,603 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default timeouts for server lifecycle operations.
+const (
+	defaultDiagnosticsWait = 5 * time.Second
+	shutdownTimeout        = 3 * time.Second
+	stderrTailSize         = 4096
+)
+
+// Location is a source range with 1-based lines and byte columns.
+type Location struct {
+	Path      string `json:"path"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"end_line"`
+	EndColumn int    `json:"end_column"`
+}
+
+// Diagnostic is a problem reported by a language server.
+type Diagnostic struct {
+	Location
+	Severity string `json:"severity"`
+	Source   string `json:"source,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Symbol is a workspace symbol.
+type Symbol struct {
+	Name      string   `json:"name"`
+	Kind      string   `json:"kind"`
+	Container string   `json:"container,omitempty"`
+	Location  Location `json:"location"`
+}
+
+// TextEdit replaces a range of a file with new text.
+type TextEdit struct {
+	Location
+	NewText string `json:"new_text"`
+}
+
+// FileEdit groups the edits a rename would make to one file.
+type FileEdit struct {
+	Path  string     `json:"path"`
+	Edits []TextEdit `json:"edits"`
+}
+
+// document is the client's view of an open text document.
+type document struct {
+	version int
+	content []byte
+}
+
+// diagnosticSet is the latest diagnostics published for a document.
+type diagnosticSet struct {
+	seq   int
+	items []protoDiagnostic
+}
+
+// Client is a connection to one running language server.
+type Client struct {
+	config  ServerConfig
+	rootDir string
+	cmd     *exec.Cmd
+	conn    *Conn
+	stderr  *tailBuffer
+	exited  chan struct{}
+
+	// DiagnosticsWait bounds how long Diagnostics waits for the server to
+	// publish results for a freshly synced document.
+	DiagnosticsWait time.Duration
+
+	mu       sync.Mutex
+	docs     map[string]*document
+	diags    map[string]*diagnosticSet
+	seq      int
+	diagWake chan struct{}
+}
+
+// Start launches the server described by cfg and performs the initialize
+// handshake for rootDir.
+func Start(ctx context.Context, cfg ServerConfig, rootDir string) (*Client, error) {
+	if cfg.Command == "" {
+		return nil, fmt.Errorf("no command configured for %s language server", cfg.Language)
+	}
+	rootDir, err := filepath.Abs(rootDir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid root directory: %w", err)
+	}
+
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Dir = rootDir
+	cmd.Env = append(os.Environ(), cfg.Env...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := &tailBuffer{max: stderrTailSize}
+	cmd.Stderr = stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s language server %q: %w", cfg.Language, cfg.Command, err)
+	}
+
+	c := &Client{
+		config:          cfg,
+		rootDir:         rootDir,
+		cmd:             cmd,
+		stderr:          stderr,
+		exited:          make(chan struct{}),
+		DiagnosticsWait: defaultDiagnosticsWait,
+		docs:            make(map[string]*document),
+		diags:           make(map[string]*diagnosticSet),
+		diagWake:        make(chan struct{}),
+	}
+	c.conn = NewConn(stdout, stdin, c.handle)
+	go func() {
+		_ = cmd.Wait()
+		close(c.exited)
+	}()
+
+	if err := c.initialize(ctx); err != nil {
+		c.kill()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Language returns the language the server was configured for.
+func (c *Client) Language() string {
+	return c.config.Language
+}
+
+// Alive reports whether the server process is still running.
+func (c *Client) Alive() bool {
+	select {
+	case <-c.exited:
+		return false
+	case <-c.conn.Done():
+		return false
+	default:
+		return true
+	}
+}
+
+// initialize performs the initialize/initialized handshake.
+func (c *Client) initialize(ctx context.Context) error {
+	rootURI := pathToURI(c.rootDir)
+	params := map[string]interface{}{
+		"processId":  os.Getpid(),
+		"clientInfo": map[string]string{"name": "goai"},
+		"rootUri":    rootURI,
+		"rootPath":   c.rootDir,
+		"workspaceFolders": []map[string]string{
+			{"uri": rootURI, "name": filepath.Base(c.rootDir)},
+		},
+		"capabilities": map[string]interface{}{
+			"general": map[string]interface{}{
+				"positionEncodings": []string{"utf-16"},
+			},
+			"textDocument": map[string]interface{}{
+				"synchronization":    map[string]interface{}{"dynamicRegistration": false},
+				"hover":              map[string]interface{}{"contentFormat": []string{"markdown", "plaintext"}},
+				"definition":         map[string]interface{}{"linkSupport": true},
+				"references":         map[string]interface{}{},
+				"rename":             map[string]interface{}{"prepareSupport": false},
+				"publishDiagnostics": map[string]interface{}{"versionSupport": true},
+			},
+			"workspace": map[string]interface{}{
+				"workspaceFolders": true,
+				"configuration":    true,
+				"symbol":           map[string]interface{}{},
+			},
+		},
+	}
+	if c.config.InitializationOptions != nil {
+		params["initializationOptions"] = c.config.InitializationOptions
+	}
+
+	if err := c.conn.Call(ctx, "initialize", params, nil); err != nil {
+		return c.wrapErr("initialize failed", err)
+	}
+	if err := c.conn.Notify("initialized", map[string]interface{}{}); err != nil {
+		return c.wrapErr("initialized failed", err)
+	}
+	return nil
+}
+
+// Shutdown asks the server to exit and kills it if it does not comply in
+// time.
+func (c *Client) Shutdown(ctx context.Context) error {
+	if !c.Alive() {
+		c.kill()
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+
+	err := c.conn.Call(ctx, "shutdown", nil, nil)
+	if err == nil {
+		_ = c.conn.Notify("exit", nil)
+	}
+	_ = c.conn.Close()
+
+	select {
+	case <-c.exited:
+	case <-ctx.Done():
+		c.kill()
+	}
+	if err != nil && !errors.Is(err, ErrClosed) {
+		return c.wrapErr("shutdown failed", err)
+	}
+	return nil
+}
+
+// kill terminates the server process and waits for it.
+func (c *Client) kill() {
+	_ = c.conn.Close()
+	if c.cmd.Process != nil {
+		_ = c.cmd.Process.Kill()
+	}
+	<-c.exited
+}
+
+// handle answers server-to-client requests and records notifications.
+func (c *Client) handle(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "textDocument/publishDiagnostics":
+		var p publishDiagnosticsParams
+		if err := json.Unmarshal(params, &p); err == nil {
+			c.storeDiagnostics(p)
+		}
+		return nil, nil
+	case "workspace/configuration":
+		var p struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		_ = json.Unmarshal(params, &p)
+		return make([]interface{}, len(p.Items)), nil
+	case "workspace/workspaceFolders":
+		return []map[string]string{{"uri": pathToURI(c.rootDir), "name": filepath.Base(c.rootDir)}}, nil
+	case "window/workDoneProgress/create", "client/registerCapability", "client/unregisterCapability",
+		"window/showMessageRequest", "window/logMessage", "window/showMessage", "$/progress", "telemetry/event":
+		return nil, nil
+	}
+	return nil, &RPCError{Code: codeMethodNotFound, Message: "method not supported: " + method}
+}
+
+// storeDiagnostics records a publishDiagnostics notification and wakes
+// waiters.
+func (c *Client) storeDiagnostics(p publishDiagnosticsParams) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	c.diags[p.URI] = &diagnosticSet{seq: c.seq, items: p.Diagnostics}
+	close(c.diagWake)
+	c.diagWake = make(chan struct{})
+}
+
+// sync opens or updates the document at path so the server sees the
+// current disk content. It returns the URI and content.
+func (c *Client) sync(path string) (string, []byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	uri := pathToURI(path)
+
+	c.mu.Lock()
+	doc, open := c.docs[uri]
+	switch {
+	case !open:
+		c.docs[uri] = &document{version: 1, content: content}
+		c.mu.Unlock()
+		err = c.conn.Notify("textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"uri":        uri,
+				"languageId": languageID(path, c.config.Language),
+				"version":    1,
+				"text":       string(content),
+			},
+		})
+	case string(doc.content) != string(content):
+		doc.version++
+		doc.content = content
+		version := doc.version
+		// Diagnostics for the old content are stale now
+		delete(c.diags, uri)
+		c.mu.Unlock()
+		err = c.conn.Notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": version},
+			"contentChanges": []map[string]string{{"text": string(content)}},
+		})
+	default:
+		c.mu.Unlock()
+	}
+	if err != nil {
+		return "", nil, c.wrapErr("document sync failed", err)
+	}
+	return uri, content, nil
+}
+
+// positionParams syncs path and builds the params for a position request.
+func (c *Client) positionParams(path string, line, column int) (textDocumentPositionParams, error) {
+	uri, content, err := c.sync(path)
+	if err != nil {
+		return textDocumentPositionParams{}, err
+	}
+	if line < 1 || column < 1 {
+		return textDocumentPositionParams{}, fmt.Errorf("line and column must be >= 1")
+	}
+	pos := protoPosition{Line: line - 1, Character: utf16Offset(lineAt(content, line-1), column)}
+	return textDocumentPositionParams{TextDocument: textDocumentIdentifier{URI: uri}, Position: pos}, nil
+}
+
+// Hover returns the hover text for the symbol at path:line:column.
+func (c *Client) Hover(ctx context.Context, path string, line, column int) (string, error) {
+	params, err := c.positionParams(path, line, column)
+	if err != nil {
+		return "", err
+	}
+	var raw json.RawMessage
+	if err := c.conn.Call(ctx, "textDocument/hover", params, &raw); err != nil {
+		return "", c.wrapErr("hover failed", err)
+	}
+	return decodeHover(raw)
+}
+
+// Definition returns the definition locations of the symbol at
+// path:line:column.
+func (c *Client) Definition(ctx context.Context, path string, line, column int) ([]Location, error) {
+	params, err := c.positionParams(path, line, column)
+	if err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	if err := c.conn.Call(ctx, "textDocument/definition", params, &raw); err != nil {
+		return nil, c.wrapErr("definition failed", err)
+	}
+	locs, err := decodeLocations(raw)
+	if err != nil {
+		return nil, err
+	}
+	return c.convertLocations(locs), nil
+}
+
+// References returns the references to the symbol at path:line:column.
+func (c *Client) References(ctx context.Context, path string, line, column int, includeDeclaration bool) ([]Location, error) {
+	params, err := c.positionParams(path, line, column)
+	if err != nil {
+		return nil, err
+	}
+	req := map[string]interface{}{
+		"textDocument": params.TextDocument,
+		"position":     params.Position,
+		"context":      map[string]bool{"includeDeclaration": includeDeclaration},
+	}
+	var raw json.RawMessage
+	if err := c.conn.Call(ctx, "textDocument/references", req, &raw); err != nil {
+		return nil, c.wrapErr("references failed", err)
+	}
+	locs, err := decodeLocations(raw)
+	if err != nil {
+		return nil, err
+	}
+	return c.convertLocations(locs), nil
+}
+
+// Rename computes the edits that renaming the symbol at path:line:column to
+// newName would make. The edits are returned for preview and never applied.
+func (c *Client) Rename(ctx context.Context, path string, line, column int, newName string) ([]FileEdit, error) {
+	params, err := c.positionParams(path, line, column)
+	if err != nil {
+		return nil, err
+	}
+	req := map[string]interface{}{
+		"textDocument": params.TextDocument,
+		"position":     params.Position,
+		"newName":      newName,
+	}
+	var edit protoWorkspaceEdit
+	if err := c.conn.Call(ctx, "textDocument/rename", req, &edit); err != nil {
+		return nil, c.wrapErr("rename failed", err)
+	}
+
+	byURI := make(map[string][]protoTextEdit)
+	for uri, edits := range edit.Changes {
+		byURI[uri] = append(byURI[uri], edits...)
+	}
+	for _, raw := range edit.DocumentChanges {
+		var docEdit protoTextDocumentEdit
+		// Create/rename/delete file operations have no textDocument and are
+		// skipped; the preview only covers text edits.
+		if err := json.Unmarshal(raw, &docEdit); err != nil || docEdit.TextDocument.URI == "" {
+			continue
+		}
+		byURI[docEdit.TextDocument.URI] = append(byURI[docEdit.TextDocument.URI], docEdit.Edits...)
+	}
+
+	lines := newLineCache()
+	var result []FileEdit
+	for uri, edits := range byURI {
+		path, err := uriToPath(uri)
+		if err != nil {
+			continue
+		}
+		fe := FileEdit{Path: path}
+		for _, e := range edits {
+			fe.Edits = append(fe.Edits, TextEdit{Location: lines.location(path, e.Range), NewText: e.NewText})
+		}
+		sort.Slice(fe.Edits, func(i, j int) bool {
+			a, b := fe.Edits[i], fe.Edits[j]
+			return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+		})
+		result = append(result, fe)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result, nil
+}
+
+// WorkspaceSymbols searches the workspace for symbols matching query.
+func (c *Client) WorkspaceSymbols(ctx context.Context, query string) ([]Symbol, error) {
+	var raw []protoSymbol
+	if err := c.conn.Call(ctx, "workspace/symbol", map[string]string{"query": query}, &raw); err != nil {
+		return nil, c.wrapErr("workspace symbol search failed", err)
+	}
+
+	lines := newLineCache()
+	symbols := make([]Symbol, 0, len(raw))
+	for _, s := range raw {
+		var loc protoLocation
+		if err := json.Unmarshal(s.Location, &loc); err != nil {
+			continue
+		}
+		path, err := uriToPath(loc.URI)
+		if err != nil {
+			continue
+		}
+		kind := symbolKinds[s.Kind]
+		if kind == "" {
+			kind = "unknown"
+		}
+		symbols = append(symbols, Symbol{
+			Name:      s.Name,
+			Kind:      kind,
+			Container: s.ContainerName,
+			Location:  lines.location(path, loc.Range),
+		})
+	}
+	return symbols, nil
+}
+
+// Diagnostics syncs path and returns the diagnostics the server publishes
+// for it, waiting up to DiagnosticsWait for a fresh report.
+func (c *Client) Diagnostics(ctx context.Context, path string) ([]Diagnostic, error) {
+	uri, _, err := c.sync(path)
+	if err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(c.DiagnosticsWait)
+	defer timer.Stop()
+	for {
+		c.mu.Lock()
+		set := c.diags[uri]
+		wake := c.diagWake
+		c.mu.Unlock()
+
+		if set != nil {
+			return c.convertDiagnostics(path, set.items), nil
+		}
+
+		select {
+		case <-wake:
+		case <-timer.C:
+			// Servers publish nothing for files without problems
+			return []Diagnostic{}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.conn.Done():
+			return nil, c.wrapErr("diagnostics failed", c.conn.closeErr())
+		}
+	}
+}
+
+// convertLocations converts wire locations to 1-based byte positions.
+func (c *Client) convertLocations(locs []protoLocation) []Location {
+	lines := newLineCache()
+	result := make([]Location, 0, len(locs))
+	for _, loc := range locs {
+		path, err := uriToPath(loc.URI)
+		if err != nil {
+			continue
+		}
+		result = append(result, lines.location(path, loc.Range))
+	}
+	return result
+}
+
+// convertDiagnostics converts wire diagnostics for path.
+func (c *Client) convertDiagnostics(path string, items []protoDiagnostic) []Diagnostic {
+	lines := newLineCache()
+	result := make([]Diagnostic, 0, len(items))
+	for _, d := range items {
+		severity := severities[d.Severity]
+		if severity == "" {
+			severity = "error"
+		}
+		code := strings.Trim(string(d.Code), `"`)
+		result = append(result, Diagnostic{
+			Location: lines.location(path, d.Range),
+			Severity: severity,
+			Source:   d.Source,
+			Code:     code,
+			Message:  d.Message,
+		})
+	}
+	return result
+}
+
+// wrapErr adds context and the server's recent stderr output to err.
+func (c *Client) wrapErr(msg string, err error) error {
+	if tail := strings.TrimSpace(c.stderr.String()); tail != "" && !c.Alive() {
+		return fmt.Errorf("%s language server: %s: %w (stderr: %s)", c.config.Language, msg, err, tail)
+	}
+	return fmt.Errorf("%s language server: %s: %w", c.config.Language, msg, err)
+}
+
+// lineCache reads files at most once while converting a batch of ranges.
+type lineCache struct {
+	files map[string][]byte
+}
+
+func newLineCache() *lineCache {
+	return &lineCache{files: make(map[string][]byte)}
+}
+
+// location converts a wire range in path to a Location. Files that cannot
+// be read keep UTF-16 offsets as columns.
+func (l *lineCache) location(path string, r protoRange) Location {
+	content, ok := l.files[path]
+	if !ok {
+		content, _ = os.ReadFile(path)
+		l.files[path] = content
+	}
+	col := func(p protoPosition) int {
+		if content == nil {
+			return p.Character + 1
+		}
+		return byteColumn(lineAt(content, p.Line), p.Character)
+	}
+	return Location{
+		Path:      path,
+		Line:      r.Start.Line + 1,
+		Column:    col(r.Start),
+		EndLine:   r.End.Line + 1,
+		EndColumn: col(r.End),
+	}
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+// Write implements io.Writer.
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = t.buf[len(t.buf)-t.max:]
+	}
+	return len(p), nil
+}
+
+// String returns the buffered output.
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
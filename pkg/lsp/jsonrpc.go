@@ -0,0 +1,267 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JSON-RPC error codes used by the client.
+const (
+	codeMethodNotFound = -32601
+	codeInternalError  = -32603
+)
+
+// ErrClosed is returned for calls on a closed connection.
+var ErrClosed = errors.New("lsp: connection closed")
+
+// RPCError is an error returned by the server for a request.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Error implements the error interface.
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("lsp error %d: %s", e.Code, e.Message)
+}
+
+// message is a JSON-RPC 2.0 request, notification or response.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// Handler handles requests and notifications sent by the server. For
+// notifications the returned result is ignored.
+type Handler func(ctx context.Context, method string, params json.RawMessage) (interface{}, error)
+
+// Conn is a JSON-RPC 2.0 connection using the LSP base protocol framing
+// (Content-Length headers) over a byte stream.
+type Conn struct {
+	w       io.WriteCloser
+	r       *bufio.Reader
+	handler Handler
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[string]chan *message
+	closed  bool
+	err     error
+	done    chan struct{}
+}
+
+// NewConn starts reading messages from r and returns a connection that
+// writes to w. Incoming requests and notifications are passed to handler.
+func NewConn(r io.Reader, w io.WriteCloser, handler Handler) *Conn {
+	c := &Conn{
+		w:       w,
+		r:       bufio.NewReader(r),
+		handler: handler,
+		pending: make(map[string]chan *message),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Call sends a request and decodes the response into result (which may be
+// nil).
+func (c *Conn) Call(ctx context.Context, method string, params, result interface{}) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	c.nextID++
+	id := c.nextID
+	key := strconv.FormatInt(id, 10)
+	ch := make(chan *message, 1)
+	c.pending[key] = ch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, key)
+		c.mu.Unlock()
+	}()
+
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s params: %w", method, err)
+	}
+	if err := c.write(&message{JSONRPC: "2.0", ID: json.RawMessage(key), Method: method, Params: raw}); err != nil {
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil || len(resp.Result) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("failed to decode %s result: %w", method, err)
+		}
+		return nil
+	case <-ctx.Done():
+		// Tell the server we gave up; failures are irrelevant here.
+		_ = c.Notify("$/cancelRequest", map[string]interface{}{"id": id})
+		return ctx.Err()
+	case <-c.done:
+		return c.closeErr()
+	}
+}
+
+// Notify sends a notification.
+func (c *Conn) Notify(method string, params interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s params: %w", method, err)
+	}
+	return c.write(&message{JSONRPC: "2.0", Method: method, Params: raw})
+}
+
+// Close closes the writer and fails pending calls.
+func (c *Conn) Close() error {
+	c.shutdown(ErrClosed)
+	return c.w.Close()
+}
+
+// Done is closed when the connection stops reading.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// write frames and sends one message.
+func (c *Conn) write(msg *message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if _, err := c.w.Write(data); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return nil
+}
+
+// readLoop dispatches incoming messages until the stream ends.
+func (c *Conn) readLoop() {
+	for {
+		msg, err := c.read()
+		if err != nil {
+			c.shutdown(err)
+			return
+		}
+
+		switch {
+		case msg.Method != "" && len(msg.ID) > 0:
+			go c.handleRequest(msg)
+		case msg.Method != "":
+			if c.handler != nil {
+				_, _ = c.handler(context.Background(), msg.Method, msg.Params)
+			}
+		default:
+			c.mu.Lock()
+			ch := c.pending[string(msg.ID)]
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+		}
+	}
+}
+
+// handleRequest answers a server-to-client request.
+func (c *Conn) handleRequest(msg *message) {
+	resp := &message{JSONRPC: "2.0", ID: msg.ID}
+	if c.handler == nil {
+		resp.Error = &RPCError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+	} else {
+		result, err := c.handler(context.Background(), msg.Method, msg.Params)
+		var rpcErr *RPCError
+		switch {
+		case errors.As(err, &rpcErr):
+			resp.Error = rpcErr
+		case err != nil:
+			resp.Error = &RPCError{Code: codeInternalError, Message: err.Error()}
+		default:
+			raw, encErr := json.Marshal(result)
+			if encErr != nil {
+				resp.Error = &RPCError{Code: codeInternalError, Message: encErr.Error()}
+			} else {
+				resp.Result = raw
+			}
+		}
+	}
+	_ = c.write(resp) // Nothing useful to do if the server went away
+}
+
+// read reads one framed message.
+func (c *Conn) read() (*message, error) {
+	tp := textproto.NewReader(c.r)
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length header: %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return nil, err
+	}
+
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+	return &msg, nil
+}
+
+// shutdown marks the connection closed once.
+func (c *Conn) shutdown(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.err = err
+	close(c.done)
+}
+
+// closeErr returns the reason the connection closed.
+func (c *Conn) closeErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil || c.err == io.EOF {
+		return ErrClosed
+	}
+	return fmt.Errorf("%w: %v", ErrClosed, c.err)
+}
@@ -0,0 +1,340 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubEnv makes the test binary act as a language server.
+const stubEnv = "GOAI_LSP_STUB"
+
+// TestMain runs the stub language server when the test binary is started
+// by a client under test.
+func TestMain(m *testing.M) {
+	if os.Getenv(stubEnv) == "1" {
+		runStubServer()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// stubServer is a tiny language server. Every document's first line
+// "// sym NAME" declares NAME at 1:8; any occurrence of NAME elsewhere is a
+// reference. Lines containing ERROR produce a diagnostic.
+type stubServer struct {
+	conn *Conn
+	docs map[string]string
+}
+
+func runStubServer() {
+	s := &stubServer{docs: make(map[string]string)}
+	s.conn = NewConn(os.Stdin, os.Stdout, s.handle)
+	<-s.conn.Done()
+}
+
+func (s *stubServer) handle(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+	var p struct {
+		TextDocument struct {
+			URI  string `json:"uri"`
+			Text string `json:"text"`
+		} `json:"textDocument"`
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+		Position protoPosition `json:"position"`
+		NewName  string        `json:"newName"`
+		Query    string        `json:"query"`
+	}
+	_ = json.Unmarshal(params, &p)
+	uri := p.TextDocument.URI
+
+	switch method {
+	case "initialize":
+		// Exercise a server-to-client request during startup
+		var cfg []interface{}
+		if err := s.conn.Call(ctx, "workspace/configuration", map[string]interface{}{"items": []interface{}{map[string]string{"section": "stub"}}}, &cfg); err != nil || len(cfg) != 1 {
+			return nil, errors.New("workspace/configuration failed")
+		}
+		return map[string]interface{}{"capabilities": map[string]interface{}{}}, nil
+	case "textDocument/didOpen":
+		s.docs[uri] = p.TextDocument.Text
+		s.publish(uri)
+	case "textDocument/didChange":
+		s.docs[uri] = p.ContentChanges[len(p.ContentChanges)-1].Text
+		s.publish(uri)
+	case "textDocument/hover":
+		return map[string]interface{}{"contents": map[string]string{"kind": "markdown", "value": "**" + s.word(uri, p.Position) + "**"}}, nil
+	case "textDocument/definition":
+		return []protoLocationLink{{TargetURI: uri, TargetSelectionRange: s.declRange(uri)}}, nil
+	case "textDocument/references":
+		return s.occurrences(uri), nil
+	case "textDocument/rename":
+		var edits []protoTextEdit
+		for _, loc := range s.occurrences(uri) {
+			edits = append(edits, protoTextEdit{Range: loc.Range, NewText: p.NewName})
+		}
+		return map[string]interface{}{"changes": map[string][]protoTextEdit{uri: edits}}, nil
+	case "workspace/symbol":
+		var out []map[string]interface{}
+		for u := range s.docs {
+			if name := s.symbol(u); strings.Contains(name, p.Query) {
+				out = append(out, map[string]interface{}{"name": name, "kind": 12, "location": protoLocation{URI: u, Range: s.declRange(u)}})
+			}
+		}
+		return out, nil
+	case "shutdown":
+		return nil, nil
+	case "exit":
+		os.Exit(0)
+	}
+	return nil, nil
+}
+
+func (s *stubServer) publish(uri string) {
+	diags := []protoDiagnostic{}
+	for i, line := range strings.Split(s.docs[uri], "\n") {
+		if col := strings.Index(line, "ERROR"); col >= 0 {
+			diags = append(diags, protoDiagnostic{
+				Range:    protoRange{Start: protoPosition{i, col}, End: protoPosition{i, col + 5}},
+				Severity: 1,
+				Source:   "stub",
+				Message:  "found ERROR",
+			})
+		}
+	}
+	_ = s.conn.Notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diags})
+}
+
+func (s *stubServer) symbol(uri string) string {
+	first := strings.SplitN(s.docs[uri], "\n", 2)[0]
+	return strings.TrimPrefix(first, "// sym ")
+}
+
+func (s *stubServer) declRange(uri string) protoRange {
+	return protoRange{Start: protoPosition{0, 7}, End: protoPosition{0, 7 + len(s.symbol(uri))}}
+}
+
+// occurrences returns all occurrences of the symbol, in UTF-16 offsets.
+func (s *stubServer) occurrences(uri string) []protoLocation {
+	name := s.symbol(uri)
+	var locs []protoLocation
+	for i, line := range strings.Split(s.docs[uri], "\n") {
+		for off := 0; ; {
+			idx := strings.Index(line[off:], name)
+			if idx < 0 {
+				break
+			}
+			start := utf16Offset(line, off+idx+1)
+			locs = append(locs, protoLocation{URI: uri, Range: protoRange{
+				Start: protoPosition{i, start},
+				End:   protoPosition{i, start + len(name)},
+			}})
+			off += idx + len(name)
+		}
+	}
+	return locs
+}
+
+func (s *stubServer) word(uri string, pos protoPosition) string {
+	line := strings.Split(s.docs[uri], "\n")[pos.Line]
+	start := byteColumn(line, pos.Character) - 1
+	end := start
+	for end < len(line) && line[end] != ' ' && line[end] != '(' && line[end] != ')' {
+		end++
+	}
+	return line[start:end]
+}
+
+// stubConfig returns a server config that re-executes the test binary as
+// the stub server.
+func stubConfig(t *testing.T) ServerConfig {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ServerConfig{
+		Language:   "stub",
+		Command:    exe,
+		Env:        []string{stubEnv + "=1"},
+		Extensions: []string{".stub"},
+	}
+}
+
+// TestClient tests the client requests against the stub server
+func TestClient(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.stub")
+	// "é" is two bytes but one UTF-16 unit, so byte columns and wire
+	// offsets differ after it.
+	content := "// sym greet\nsay(\"é\") greet()\ngreet()\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(dir, []ServerConfig{stubConfig(t)})
+	defer m.Close()
+	ctx := context.Background()
+
+	c, err := m.ClientFor(ctx, path)
+	if err != nil {
+		t.Fatalf("ClientFor failed: %v", err)
+	}
+
+	t.Run("Hover", func(t *testing.T) {
+		text, err := c.Hover(ctx, path, 2, 11)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text != "**greet**" {
+			t.Errorf("hover = %q, want **greet**", text)
+		}
+	})
+
+	t.Run("Definition", func(t *testing.T) {
+		locs, err := c.Definition(ctx, path, 3, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Location{Path: path, Line: 1, Column: 8, EndLine: 1, EndColumn: 13}
+		if len(locs) != 1 || locs[0] != want {
+			t.Errorf("definition = %+v, want %+v", locs, want)
+		}
+	})
+
+	t.Run("References", func(t *testing.T) {
+		locs, err := c.References(ctx, path, 1, 8, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, l := range locs {
+			got = append(got, fmt.Sprintf("%d:%d", l.Line, l.Column))
+		}
+		if strings.Join(got, ",") != "1:8,2:11,3:1" {
+			t.Errorf("references = %v", got)
+		}
+	})
+
+	t.Run("RenamePreview", func(t *testing.T) {
+		edits, err := c.Rename(ctx, path, 1, 8, "hello")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(edits) != 1 || len(edits[0].Edits) != 3 || edits[0].Edits[1].Column != 11 {
+			t.Fatalf("unexpected rename edits: %+v", edits)
+		}
+		data, _ := os.ReadFile(path)
+		if string(data) != content {
+			t.Error("rename preview modified the file")
+		}
+	})
+
+	t.Run("WorkspaceSymbols", func(t *testing.T) {
+		symbols, err := c.WorkspaceSymbols(ctx, "gre")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(symbols) != 1 || symbols[0].Name != "greet" || symbols[0].Kind != "function" {
+			t.Errorf("unexpected symbols: %+v", symbols)
+		}
+	})
+
+	t.Run("Diagnostics", func(t *testing.T) {
+		diags, err := c.Diagnostics(ctx, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(diags) != 0 {
+			t.Fatalf("expected no diagnostics, got %+v", diags)
+		}
+
+		if err := os.WriteFile(path, []byte(content+"x := ERROR\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		diags, err = c.Diagnostics(ctx, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(diags) != 1 || diags[0].Line != 4 || diags[0].Column != 6 || diags[0].Severity != "error" {
+			t.Errorf("unexpected diagnostics: %+v", diags)
+		}
+	})
+}
+
+// TestManagerLifecycle tests lazy start, routing, restart and shutdown
+func TestManagerLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(dir, []ServerConfig{stubConfig(t)})
+
+	if len(m.clients) != 0 {
+		t.Fatal("servers must not start before first use")
+	}
+	if _, err := m.ClientFor(context.Background(), filepath.Join(dir, "a.py")); !errors.Is(err, ErrNoServer) {
+		t.Errorf("expected ErrNoServer for unconfigured extension, got %v", err)
+	}
+
+	c, err := m.Client(context.Background(), "stub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := m.Client(context.Background(), "stub")
+	if again != c {
+		t.Error("expected the running client to be reused")
+	}
+
+	// A crashed server is restarted on next use
+	c.kill()
+	restarted, err := m.Client(context.Background(), "stub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restarted == c || !restarted.Alive() {
+		t.Error("expected a fresh server after the old one exited")
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	select {
+	case <-restarted.exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not exit on Close")
+	}
+	if err := m.Close(); err != nil {
+		t.Errorf("second Close failed: %v", err)
+	}
+	if _, err := m.Client(context.Background(), "stub"); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+}
+
+// TestPositionConversion tests UTF-16 offset conversion
+func TestPositionConversion(t *testing.T) {
+	line := "a😀é=x"
+	tests := []struct {
+		column int
+		offset int
+	}{
+		{1, 0},
+		{2, 1},
+		{6, 3}, // after the emoji (4 bytes, 2 units)
+		{8, 4}, // after é (2 bytes, 1 unit)
+		{10, 6},
+	}
+	for _, tt := range tests {
+		if got := utf16Offset(line, tt.column); got != tt.offset {
+			t.Errorf("utf16Offset(%d) = %d, want %d", tt.column, got, tt.offset)
+		}
+		if got := byteColumn(line, tt.offset); got != tt.column {
+			t.Errorf("byteColumn(%d) = %d, want %d", tt.offset, got, tt.column)
+		}
+	}
+}
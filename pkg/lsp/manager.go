@@ -0,0 +1,158 @@
+// Package lsp implements a minimal Language Server Protocol client.
+//
+// A Manager starts the configured language servers (gopls, pyright,
+// typescript-language-server, ...) over stdio on first use and routes each
+// file to the server for its extension. Positions in the public API are
+// 1-based lines and byte columns, matching the rest of the tools; the
+// conversion to LSP's UTF-16 offsets happens inside the client.
+package lsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNoServer is returned when no language server is configured for a
+// file or language.
+var ErrNoServer = errors.New("no language server configured")
+
+// ServerConfig describes how to start a language server.
+type ServerConfig struct {
+	Language              string                 // Language name, e.g. "go"
+	Command               string                 // Executable to run
+	Args                  []string               // Command arguments
+	Env                   []string               // Extra environment, KEY=VALUE
+	Extensions            []string               // File extensions handled, e.g. ".go"
+	InitializationOptions map[string]interface{} // Sent with initialize
+}
+
+// languageIDs maps file extensions to LSP language identifiers.
+var languageIDs = map[string]string{
+	".go":  "go",
+	".py":  "python",
+	".pyi": "python",
+	".ts":  "typescript",
+	".mts": "typescript",
+	".cts": "typescript",
+	".tsx": "typescriptreact",
+	".js":  "javascript",
+	".mjs": "javascript",
+	".cjs": "javascript",
+	".jsx": "javascriptreact",
+}
+
+// languageID returns the LSP language identifier for path.
+func languageID(path, fallback string) string {
+	if id, ok := languageIDs[strings.ToLower(filepath.Ext(path))]; ok {
+		return id
+	}
+	return fallback
+}
+
+// Manager owns the language server clients of a session.
+type Manager struct {
+	rootDir string
+	servers map[string]ServerConfig
+
+	mu      sync.Mutex
+	clients map[string]*Client
+	closed  bool
+}
+
+// NewManager creates a manager for the workspace at rootDir. No server is
+// started until a request needs it.
+func NewManager(rootDir string, servers []ServerConfig) *Manager {
+	m := &Manager{
+		rootDir: rootDir,
+		servers: make(map[string]ServerConfig),
+		clients: make(map[string]*Client),
+	}
+	for _, s := range servers {
+		m.servers[s.Language] = s
+	}
+	return m
+}
+
+// Languages returns the configured languages in sorted order.
+func (m *Manager) Languages() []string {
+	langs := make([]string, 0, len(m.servers))
+	for lang := range m.servers {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// LanguageFor returns the configured language handling path.
+func (m *Manager) LanguageFor(path string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, lang := range m.Languages() {
+		for _, e := range m.servers[lang].Extensions {
+			if strings.ToLower(e) == ext {
+				return lang, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w for %s files", ErrNoServer, ext)
+}
+
+// ClientFor returns the running client for path's language, starting the
+// server if needed.
+func (m *Manager) ClientFor(ctx context.Context, path string) (*Client, error) {
+	lang, err := m.LanguageFor(path)
+	if err != nil {
+		return nil, err
+	}
+	return m.Client(ctx, lang)
+}
+
+// Client returns the running client for language, starting the server if
+// needed. A server that has exited is restarted.
+func (m *Manager) Client(ctx context.Context, language string) (*Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+	cfg, ok := m.servers[language]
+	if !ok {
+		return nil, fmt.Errorf("%w for language %q", ErrNoServer, language)
+	}
+	if c := m.clients[language]; c != nil {
+		if c.Alive() {
+			return c, nil
+		}
+		c.kill()
+		delete(m.clients, language)
+	}
+
+	c, err := Start(ctx, cfg, m.rootDir)
+	if err != nil {
+		return nil, err
+	}
+	m.clients[language] = c
+	return c, nil
+}
+
+// Close shuts down all running servers. It is safe to call more than once.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	clients := m.clients
+	m.clients = make(map[string]*Client)
+	m.closed = true
+	m.mu.Unlock()
+
+	var errs []error
+	for _, c := range clients {
+		if err := c.Shutdown(context.Background()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
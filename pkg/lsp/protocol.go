@@ -0,0 +1,254 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// The subset of the Language Server Protocol used by the client. Positions
+// on the wire are zero-based lines and UTF-16 code unit offsets.
+
+type protoPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type protoRange struct {
+	Start protoPosition `json:"start"`
+	End   protoPosition `json:"end"`
+}
+
+type protoLocation struct {
+	URI   string     `json:"uri"`
+	Range protoRange `json:"range"`
+}
+
+type protoLocationLink struct {
+	TargetURI            string     `json:"targetUri"`
+	TargetRange          protoRange `json:"targetRange"`
+	TargetSelectionRange protoRange `json:"targetSelectionRange"`
+}
+
+type textDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type textDocumentPositionParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Position     protoPosition          `json:"position"`
+}
+
+type protoDiagnostic struct {
+	Range    protoRange      `json:"range"`
+	Severity int             `json:"severity,omitempty"`
+	Code     json.RawMessage `json:"code,omitempty"`
+	Source   string          `json:"source,omitempty"`
+	Message  string          `json:"message"`
+}
+
+type publishDiagnosticsParams struct {
+	URI         string            `json:"uri"`
+	Version     *int              `json:"version,omitempty"`
+	Diagnostics []protoDiagnostic `json:"diagnostics"`
+}
+
+type protoTextEdit struct {
+	Range   protoRange `json:"range"`
+	NewText string     `json:"newText"`
+}
+
+type protoWorkspaceEdit struct {
+	Changes         map[string][]protoTextEdit `json:"changes,omitempty"`
+	DocumentChanges []json.RawMessage          `json:"documentChanges,omitempty"`
+}
+
+type protoTextDocumentEdit struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Edits        []protoTextEdit        `json:"edits"`
+}
+
+type protoSymbol struct {
+	Name          string          `json:"name"`
+	Kind          int             `json:"kind"`
+	ContainerName string          `json:"containerName,omitempty"`
+	Location      json.RawMessage `json:"location"`
+}
+
+// decodeLocations decodes a definition/references result, which may be
+// null, a Location, a Location array or a LocationLink array.
+func decodeLocations(raw json.RawMessage) ([]protoLocation, error) {
+	raw = json.RawMessage(strings.TrimSpace(string(raw)))
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	if raw[0] == '{' {
+		var loc protoLocation
+		if err := json.Unmarshal(raw, &loc); err != nil {
+			return nil, err
+		}
+		return []protoLocation{loc}, nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+	locs := make([]protoLocation, 0, len(items))
+	for _, item := range items {
+		var link protoLocationLink
+		if err := json.Unmarshal(item, &link); err == nil && link.TargetURI != "" {
+			locs = append(locs, protoLocation{URI: link.TargetURI, Range: link.TargetSelectionRange})
+			continue
+		}
+		var loc protoLocation
+		if err := json.Unmarshal(item, &loc); err != nil {
+			return nil, err
+		}
+		locs = append(locs, loc)
+	}
+	return locs, nil
+}
+
+// decodeHover extracts the text of a hover result. Contents may be
+// MarkupContent, a MarkedString or an array of MarkedStrings.
+func decodeHover(raw json.RawMessage) (string, error) {
+	var hover struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	if err := json.Unmarshal(raw, &hover); err != nil {
+		return "", err
+	}
+	return markedText(hover.Contents), nil
+}
+
+// markedText renders hover contents as plain markdown.
+func markedText(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+
+	var markup struct {
+		Kind     string `json:"kind"`
+		Language string `json:"language"`
+		Value    string `json:"value"`
+	}
+	if err := json.Unmarshal(raw, &markup); err == nil && markup.Value != "" {
+		if markup.Language != "" {
+			return fmt.Sprintf("```%s\n%s\n```", markup.Language, markup.Value)
+		}
+		return markup.Value
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err == nil {
+		parts := make([]string, 0, len(items))
+		for _, item := range items {
+			if text := markedText(item); text != "" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, "\n\n")
+	}
+	return ""
+}
+
+// symbolKinds names LSP SymbolKind values.
+var symbolKinds = map[int]string{
+	1: "file", 2: "module", 3: "namespace", 4: "package", 5: "class",
+	6: "method", 7: "property", 8: "field", 9: "constructor", 10: "enum",
+	11: "interface", 12: "function", 13: "variable", 14: "constant", 15: "string",
+	16: "number", 17: "boolean", 18: "array", 19: "object", 20: "key",
+	21: "null", 22: "enum_member", 23: "struct", 24: "event", 25: "operator",
+	26: "type_parameter",
+}
+
+// severities names LSP DiagnosticSeverity values.
+var severities = map[int]string{1: "error", 2: "warning", 3: "information", 4: "hint"}
+
+// pathToURI converts an absolute file path to a file:// URI.
+func pathToURI(path string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	return u.String()
+}
+
+// uriToPath converts a file:// URI to a file path.
+func uriToPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", fmt.Errorf("invalid URI %q: %w", uri, err)
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported URI scheme: %s", uri)
+	}
+	return filepath.FromSlash(u.Path), nil
+}
+
+// utf16Offset converts a 1-based byte column in line to a zero-based UTF-16
+// offset.
+func utf16Offset(line string, column int) int {
+	byteOffset := column - 1
+	if byteOffset > len(line) {
+		byteOffset = len(line)
+	}
+	n := 0
+	for _, r := range line[:max(byteOffset, 0)] {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// byteColumn converts a zero-based UTF-16 offset in line to a 1-based byte
+// column.
+func byteColumn(line string, offset int) int {
+	units := 0
+	for i, r := range line {
+		if units >= offset {
+			return i + 1
+		}
+		n := utf16.RuneLen(r)
+		if n < 0 {
+			n = 1 // Invalid UTF-8 counts as one unit
+		}
+		units += n
+	}
+	return len(line) + 1
+}
+
+// lineAt returns the zero-based line of content, without its line ending.
+func lineAt(content []byte, line int) string {
+	for i := 0; i < line; i++ {
+		next := indexByte(content, '\n')
+		if next < 0 {
+			return ""
+		}
+		content = content[next+1:]
+	}
+	if end := indexByte(content, '\n'); end >= 0 {
+		content = content[:end]
+	}
+	s := strings.TrimSuffix(string(content), "\r")
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "�")
+	}
+	return s
+}
+
+// indexByte is bytes.IndexByte without the import.
+func indexByte(b []byte, c byte) int {
+	for i, x := range b {
+		if x == c {
+			return i
+		}
+	}
+	return -1
+}
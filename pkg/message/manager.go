@@ -12,6 +12,7 @@ type Manager struct {
 	messages   []types.Message
 	maxTokens  int
 	tokenCount int
+	onDrop     func(types.Message)
 }
 
 // NewManager creates a new message manager
@@ -82,6 +83,9 @@ func (m *Manager) Truncate() {
 
 			// Remove the message
 			m.messages = append(m.messages[:startIdx], m.messages[startIdx+1:]...)
+			if m.onDrop != nil {
+				m.onDrop(removed)
+			}
 		} else {
 			break
 		}
@@ -90,6 +94,11 @@ func (m *Manager) Truncate() {
 	m.tokenCount = totalTokens
 }
 
+// OnDrop sets a function called with each message Truncate removes
+func (m *Manager) OnDrop(fn func(types.Message)) {
+	m.onDrop = fn
+}
+
 // GetHistory returns the message history
 func (m *Manager) GetHistory() []types.Message {
 	return append([]types.Message{}, m.messages...)
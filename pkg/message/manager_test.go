@@ -76,6 +76,20 @@ func TestManager_Truncate(t *testing.T) {
 	}
 }
 
+func TestManager_OnDrop(t *testing.T) {
+	m := NewManager(100)
+	dropped := 0
+	m.OnDrop(func(types.Message) { dropped++ })
+
+	for i := 0; i < 20; i++ {
+		m.AddUserMessage("This is a long message that will consume many tokens")
+	}
+
+	if dropped == 0 || dropped+m.Count() != 20 {
+		t.Errorf("Expected every removed message to be reported, got %d dropped and %d kept", dropped, m.Count())
+	}
+}
+
 func TestManager_GetHistory(t *testing.T) {
 	m := NewManager(1000)
 
@@ -0,0 +1,153 @@
+package outline
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// parseGo outlines a Go file from its syntax tree. Files with syntax
+// errors are outlined as far as the parser got.
+func parseGo(path string, content []byte) ([]Symbol, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, content, parser.ParseComments|parser.SkipObjectResolution)
+	if file == nil {
+		return nil, err
+	}
+
+	line := func(pos token.Pos) int { return fset.Position(pos).Line }
+	text := func(from, to token.Pos) string {
+		start, end := fset.Position(from).Offset, fset.Position(to).Offset
+		if start < 0 || end > len(content) || start >= end {
+			return ""
+		}
+		return strings.Join(strings.Fields(string(bytes.TrimSpace(content[start:end]))), " ")
+	}
+	symbol := func(name, kind string, node ast.Node, doc *ast.CommentGroup, signature string) Symbol {
+		s := Symbol{Name: name, Kind: kind, Line: line(node.Pos()), EndLine: line(node.End()), Signature: signature}
+		s.DocLine = s.Line
+		if doc != nil {
+			s.DocLine = line(doc.Pos())
+		}
+		return s
+	}
+
+	var symbols []Symbol
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			name, kind := d.Name.Name, KindFunc
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				name, kind = receiverName(d.Recv.List[0].Type)+"."+name, KindMethod
+			}
+			end := d.End()
+			if d.Body != nil {
+				end = d.Body.Lbrace
+			}
+			symbols = append(symbols, symbol(name, kind, d, d.Doc, text(d.Pos(), end)))
+
+		case *ast.GenDecl:
+			grouped := d.Lparen.IsValid()
+			for _, spec := range d.Specs {
+				// A lone spec spans the whole declaration, keyword included
+				var node ast.Node = spec
+				doc := d.Doc
+				if grouped {
+					doc = specDoc(spec)
+				} else {
+					node = d
+				}
+
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					kind := KindType
+					header := "type " + s.Name.Name
+					switch s.Type.(type) {
+					case *ast.StructType:
+						kind, header = KindStruct, header+" struct"
+					case *ast.InterfaceType:
+						kind, header = KindInterface, header+" interface"
+					default:
+						header = "type " + text(s.Pos(), s.End())
+					}
+					symbols = append(symbols, symbol(s.Name.Name, kind, node, doc, header))
+					symbols = append(symbols, members(s, line, text)...)
+				case *ast.ValueSpec:
+					kind := KindVar
+					if d.Tok == token.CONST {
+						kind = KindConst
+					}
+					for _, n := range s.Names {
+						if n.Name == "_" {
+							continue
+						}
+						symbols = append(symbols, symbol(n.Name, kind, node, doc, kind+" "+n.Name))
+					}
+				}
+			}
+		}
+	}
+	return symbols, nil
+}
+
+// members returns the methods of an interface type, which have no
+// declarations of their own.
+func members(spec *ast.TypeSpec, line func(token.Pos) int, text func(from, to token.Pos) string) []Symbol {
+	iface, ok := spec.Type.(*ast.InterfaceType)
+	if !ok {
+		return nil
+	}
+	var symbols []Symbol
+	for _, field := range iface.Methods.List {
+		if _, ok := field.Type.(*ast.FuncType); !ok || len(field.Names) == 0 {
+			continue // Embedded interface
+		}
+		s := Symbol{
+			Name:      spec.Name.Name + "." + field.Names[0].Name,
+			Kind:      KindMethod,
+			Line:      line(field.Pos()),
+			EndLine:   line(field.End()),
+			Signature: text(field.Pos(), field.End()),
+		}
+		s.DocLine = s.Line
+		if field.Doc != nil {
+			s.DocLine = line(field.Doc.Pos())
+		}
+		symbols = append(symbols, s)
+	}
+	return symbols
+}
+
+// receiverName returns the type name of a method receiver, without pointer
+// or type parameters.
+func receiverName(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return "?"
+		}
+	}
+}
+
+// specDoc returns the doc comment of a spec inside a grouped declaration.
+func specDoc(spec ast.Spec) *ast.CommentGroup {
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		return s.Doc
+	case *ast.ValueSpec:
+		return s.Doc
+	}
+	return nil
+}
@@ -0,0 +1,357 @@
+package outline
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Ways a declaration's extent is found outside Go.
+const (
+	blockBraces  = iota // From the declaration to its matching closing brace
+	blockIndent         // Until the next line indented no deeper than the declaration
+	blockHeading        // Until the next heading of the same or a higher level
+)
+
+// pattern recognizes one kind of declaration. The name is the first
+// submatch.
+type pattern struct {
+	re     *regexp.Regexp
+	kind   string
+	member bool // Only inside a class, struct, interface or impl block
+}
+
+// language describes how to outline one language.
+type language struct {
+	name     string
+	block    int
+	patterns []pattern
+}
+
+// p compiles a pattern.
+func p(expr, kind string) pattern {
+	return pattern{re: regexp.MustCompile(expr), kind: kind}
+}
+
+// member compiles a pattern that only applies inside a container.
+func member(expr, kind string) pattern {
+	return pattern{re: regexp.MustCompile(expr), kind: kind, member: true}
+}
+
+var (
+	jsLanguage = language{name: "javascript", block: blockBraces, patterns: []pattern{
+		p(`^\s*(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:abstract\s+)?class\s+([\w$]+)`, KindClass),
+		p(`^\s*(?:export\s+)?(?:declare\s+)?interface\s+([\w$]+)`, KindInterface),
+		p(`^\s*(?:export\s+)?(?:declare\s+)?(?:const\s+)?enum\s+([\w$]+)`, KindType),
+		p(`^\s*(?:export\s+)?(?:declare\s+)?type\s+([\w$]+)\s*(?:<[^=]*>)?\s*=`, KindType),
+		p(`^\s*(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:async\s+)?function\s*\*?\s*([\w$]+)`, KindFunc),
+		p(`^\s*(?:export\s+)?(?:const|let|var)\s+([\w$]+)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|(?:\([^)]*\)|[\w$]+)\s*(?::[^=]+)?=>)`, KindFunc),
+		member(`^\s+(?:(?:public|private|protected|static|async|readonly|override|abstract|get|set)\s+)*\*?([\w$]+)\s*(?:<[^>]*>)?\s*\([^;]*$`, KindMethod),
+	}}
+	pythonLanguage = language{name: "python", block: blockIndent, patterns: []pattern{
+		p(`^\s*class\s+(\w+)`, KindClass),
+		p(`^\s*(?:async\s+)?def\s+(\w+)`, KindFunc),
+	}}
+	rubyLanguage = language{name: "ruby", block: blockIndent, patterns: []pattern{
+		p(`^\s*(?:class|module)\s+([\w:]+)`, KindClass),
+		p(`^\s*def\s+(?:self\.)?([\w?!=]+)`, KindFunc),
+	}}
+	rustLanguage = language{name: "rust", block: blockBraces, patterns: []pattern{
+		p(`^\s*(?:pub(?:\([^)]*\))?\s+)?struct\s+(\w+)`, KindStruct),
+		p(`^\s*(?:pub(?:\([^)]*\))?\s+)?enum\s+(\w+)`, KindType),
+		p(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:unsafe\s+)?trait\s+(\w+)`, KindInterface),
+		p(`^\s*(?:unsafe\s+)?impl(?:<[^>]*>)?\s+(?:[\w:<>, ]+\s+for\s+)?(\w+)`, KindType),
+		p(`^\s*(?:pub(?:\([^)]*\))?\s+)?mod\s+(\w+)\s*\{`, KindType),
+		p(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?(?:extern\s+"[^"]*"\s+)?fn\s+(\w+)`, KindFunc),
+	}}
+	javaLanguage = language{name: "java", block: blockBraces, patterns: []pattern{
+		p(`^\s*(?:(?:public|private|protected|static|final|abstract|sealed|partial|internal|data|open)\s+)*(?:class|record|object)\s+(\w+)`, KindClass),
+		p(`^\s*(?:(?:public|private|protected|static|internal)\s+)*(?:interface|@interface)\s+(\w+)`, KindInterface),
+		p(`^\s*(?:(?:public|private|protected|static|internal)\s+)*enum\s+(?:class\s+)?(\w+)`, KindType),
+		p(`^\s*(?:(?:public|private|protected|internal|override|suspend|inline|open)\s+)*fun\s+(?:<[^>]*>\s*)?(?:[\w.]+\.)?(\w+)\s*\(`, KindFunc),
+		member(`^\s+(?:(?:public|private|protected|static|final|abstract|synchronized|native|override|async|virtual|internal|default)\s+)*(?:<[^>]*>\s+)?[\w<>\[\],.? ]+\s+(\w+)\s*\([^;]*$`, KindMethod),
+	}}
+	cLanguage = language{name: "c", block: blockBraces, patterns: []pattern{
+		p(`^\s*(?:typedef\s+)?(?:struct|union)\s+(\w+)\s*\{?\s*$`, KindStruct),
+		p(`^\s*(?:template\s*<[^>]*>\s*)?class\s+(\w+)(?:\s*:[^;{]*)?\s*\{?\s*$`, KindClass),
+		p(`^\s*(?:typedef\s+)?enum\s+(?:class\s+)?(\w+)\s*\{?\s*$`, KindType),
+		p(`^\s*namespace\s+(\w+)\s*\{?\s*$`, KindType),
+		p(`^(?:[\w*&:<>,]+\s+)+\**&?([\w:~]+)\s*\([^;]*$`, KindFunc),
+		member(`^\s+(?:(?:virtual|static|inline|explicit|constexpr)\s+)*(?:[\w*&:<>,]+\s+)*\**&?(~?\w+)\s*\([^;]*$`, KindMethod),
+	}}
+	markdownLanguage = language{name: "markdown", block: blockHeading}
+)
+
+// languages maps file extensions to languages.
+var languages = map[string]language{
+	".js": jsLanguage, ".jsx": jsLanguage, ".mjs": jsLanguage, ".cjs": jsLanguage,
+	".ts": withName(jsLanguage, "typescript"), ".tsx": withName(jsLanguage, "typescript"),
+	".mts": withName(jsLanguage, "typescript"), ".cts": withName(jsLanguage, "typescript"),
+	".py": pythonLanguage, ".pyi": pythonLanguage,
+	".rb":   rubyLanguage,
+	".rs":   rustLanguage,
+	".java": javaLanguage, ".kt": withName(javaLanguage, "kotlin"), ".kts": withName(javaLanguage, "kotlin"),
+	".cs": withName(javaLanguage, "csharp"), ".scala": withName(javaLanguage, "scala"),
+	".c": cLanguage, ".h": cLanguage, ".cc": withName(cLanguage, "cpp"), ".cpp": withName(cLanguage, "cpp"),
+	".cxx": withName(cLanguage, "cpp"), ".hpp": withName(cLanguage, "cpp"), ".hh": withName(cLanguage, "cpp"),
+	".md": markdownLanguage, ".markdown": markdownLanguage,
+}
+
+// notNames are keywords the method patterns would otherwise take for names.
+var notNames = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true, "return": true,
+	"new": true, "else": true, "function": true, "do": true, "try": true, "sizeof": true,
+	"throw": true, "typeof": true, "await": true, "yield": true, "super": true, "delete": true,
+}
+
+// withName returns lang under another name.
+func withName(lang language, name string) language {
+	lang.name = name
+	return lang
+}
+
+// languageFor returns the language for a file extension.
+func languageFor(ext string) (language, bool) {
+	lang, ok := languages[ext]
+	return lang, ok
+}
+
+// isContainer reports whether symbols of kind hold members.
+func isContainer(kind string) bool {
+	switch kind {
+	case KindClass, KindStruct, KindInterface, KindType:
+		return true
+	}
+	return false
+}
+
+// parseHeuristic outlines content with the patterns of lang.
+func parseHeuristic(lang language, content string) []Symbol {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	if lang.block == blockHeading {
+		return parseHeadings(lines)
+	}
+
+	var symbols []Symbol
+	var open []Symbol // Enclosing declarations, innermost last
+	for i, line := range lines {
+		for len(open) > 0 && open[len(open)-1].EndLine < i+1 {
+			open = open[:len(open)-1]
+		}
+		var parent *Symbol
+		if len(open) > 0 {
+			parent = &open[len(open)-1]
+		}
+
+		for _, pat := range lang.patterns {
+			m := pat.re.FindStringSubmatch(line)
+			if m == nil || notNames[m[1]] {
+				continue
+			}
+			if pat.member && (parent == nil || !isContainer(parent.Kind)) {
+				continue
+			}
+			if parent != nil && !isContainer(parent.Kind) {
+				break // Declarations local to a function are not part of the outline
+			}
+
+			s := Symbol{Name: m[1], Kind: pat.kind, Line: i + 1, Signature: strings.TrimSpace(line)}
+			if parent != nil {
+				s.Name = parent.Name + "." + s.Name
+				if s.Kind == KindFunc {
+					s.Kind = KindMethod
+				}
+			}
+			s.Signature = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s.Signature), "{"))
+			if lang.block == blockIndent {
+				s.EndLine = indentEnd(lines, i)
+			} else {
+				s.EndLine = braceEnd(lines, i)
+			}
+			s.DocLine = docStart(lines, i)
+			symbols = append(symbols, s)
+			open = append(open, s)
+			break
+		}
+	}
+	return symbols
+}
+
+// braceEnd returns the 1-based line where the declaration starting at
+// line start ends: the line closing its first brace, or the line ending it
+// with a semicolon or without a body.
+func braceEnd(lines []string, start int) int {
+	depth, opened := 0, false
+	for i := start; i < len(lines); i++ {
+		line := stripStrings(lines[i])
+		for _, c := range line {
+			switch c {
+			case '{':
+				depth++
+				opened = true
+			case '}':
+				depth--
+				if opened && depth <= 0 {
+					return i + 1
+				}
+			case ';':
+				if !opened && depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		if !opened && !continues(lines, i) {
+			return i + 1
+		}
+	}
+	return len(lines)
+}
+
+// continues reports whether the declaration header on line i goes on to
+// the next line.
+func continues(lines []string, i int) bool {
+	line := strings.TrimSpace(stripStrings(lines[i]))
+	for _, suffix := range []string{"(", ",", "=>", "=", ":", "|", "&", "<", "->"} {
+		if strings.HasSuffix(line, suffix) {
+			return true
+		}
+	}
+	if strings.Count(line, "(") > strings.Count(line, ")") {
+		return true
+	}
+	for j := i + 1; j < len(lines); j++ {
+		next := strings.TrimSpace(lines[j])
+		if next == "" {
+			continue
+		}
+		return strings.HasPrefix(next, "{") || strings.HasPrefix(next, ")") ||
+			strings.HasPrefix(next, "where") || strings.HasPrefix(next, ":") || strings.HasPrefix(next, "throws")
+	}
+	return false
+}
+
+// stripStrings removes string literals and line comments from a line so
+// their braces are not counted.
+func stripStrings(line string) string {
+	var b strings.Builder
+	var quote rune
+	escaped := false
+	prev := rune(0)
+	for _, c := range line {
+		switch {
+		case quote != 0:
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '/' && prev == '/':
+			s := b.String()
+			return s[:len(s)-1]
+		default:
+			b.WriteRune(c)
+		}
+		prev = c
+	}
+	return b.String()
+}
+
+// indentEnd returns the 1-based last line of the indented block starting
+// at line start. A closing "end" at the declaration's indentation belongs
+// to it.
+func indentEnd(lines []string, start int) int {
+	base := indentOf(lines[start])
+	end := start
+	parens := strings.Count(lines[start], "(") - strings.Count(lines[start], ")")
+	for i := start + 1; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if parens <= 0 && indentOf(line) <= base {
+			if trimmed == "end" || strings.HasPrefix(trimmed, "end ") || strings.HasPrefix(trimmed, ")") {
+				return i + 1
+			}
+			break
+		}
+		parens += strings.Count(line, "(") - strings.Count(line, ")")
+		end = i
+	}
+	return end + 1
+}
+
+// indentOf returns the width of the leading whitespace of line.
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// docStart returns the 1-based first line of the comments and decorators
+// directly above line i.
+func docStart(lines []string, i int) int {
+	start := i
+	for j := i - 1; j >= 0; j-- {
+		trimmed := strings.TrimSpace(lines[j])
+		if trimmed == "" {
+			break
+		}
+		isDoc := false
+		for _, prefix := range []string{"//", "#", "/*", "*", "@", "///", "#["} {
+			if strings.HasPrefix(trimmed, prefix) {
+				isDoc = true
+				break
+			}
+		}
+		if !isDoc || strings.HasPrefix(trimmed, "#include") || strings.HasPrefix(trimmed, "#define") {
+			break
+		}
+		start = j
+	}
+	return start + 1
+}
+
+// parseHeadings outlines Markdown by its headings, skipping fenced code.
+func parseHeadings(lines []string) []Symbol {
+	type heading struct {
+		Symbol
+		level int
+	}
+	var headings []heading
+	fenced := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+			continue
+		}
+		if fenced || !strings.HasPrefix(line, "#") {
+			continue
+		}
+		level := len(line) - len(strings.TrimLeft(line, "#"))
+		title := strings.TrimSpace(line[level:])
+		if level > 6 || title == "" || !strings.HasPrefix(line[level:], " ") {
+			continue
+		}
+		headings = append(headings, heading{Symbol{Name: title, Kind: KindHeading, Line: i + 1, DocLine: i + 1, Signature: trimmed}, level})
+	}
+
+	last := len(lines)
+	if last > 0 && lines[last-1] == "" {
+		last--
+	}
+	symbols := make([]Symbol, len(headings))
+	for i, h := range headings {
+		h.EndLine = last
+		for _, next := range headings[i+1:] {
+			if next.level <= h.level {
+				h.EndLine = next.Line - 1
+				break
+			}
+		}
+		symbols[i] = h.Symbol
+	}
+	return symbols
+}
@@ -0,0 +1,131 @@
+// Package outline extracts the structure of a source file: its types,
+// functions, methods and other declarations with their line ranges. Go
+// files are parsed with go/ast; other languages use per-language regular
+// expressions with brace or indentation matching to find where a
+// declaration ends.
+package outline
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Symbol kinds.
+const (
+	KindFunc      = "func"
+	KindMethod    = "method"
+	KindType      = "type"
+	KindStruct    = "struct"
+	KindInterface = "interface"
+	KindClass     = "class"
+	KindConst     = "const"
+	KindVar       = "var"
+	KindHeading   = "heading"
+)
+
+// Symbol is one declaration in a file.
+type Symbol struct {
+	Name      string // Qualified with its container, e.g. "Agent.Query"
+	Kind      string
+	Line      int    // First line of the declaration (1-based)
+	EndLine   int    // Last line of the declaration
+	DocLine   int    // First line of the doc comment, or Line when there is none
+	Signature string // Declaration header, e.g. "func (a *Agent) Query(...) error"
+}
+
+// Outline is the structure of one file.
+type Outline struct {
+	Language string
+	Lines    int
+	Symbols  []Symbol // In source order
+}
+
+// Parse returns the outline of the file at path with the given content.
+// The language is chosen by the file extension.
+func Parse(path string, content []byte) (*Outline, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	lines := strings.Count(string(content), "\n")
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		lines++
+	}
+
+	var (
+		o   = &Outline{Lines: lines}
+		err error
+	)
+	if ext == ".go" {
+		o.Language = "go"
+		o.Symbols, err = parseGo(path, content)
+	} else if lang, ok := languageFor(ext); ok {
+		o.Language = lang.name
+		o.Symbols = parseHeuristic(lang, string(content))
+	} else {
+		return nil, fmt.Errorf("outline is not supported for %s files", displayExt(ext))
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(o.Symbols, func(i, j int) bool { return o.Symbols[i].Line < o.Symbols[j].Line })
+	return o, nil
+}
+
+// Supported reports whether files named like path can be outlined.
+func Supported(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	_, ok := languageFor(ext)
+	return ext == ".go" || ok
+}
+
+// Find returns the symbol called name. An exact match of the qualified
+// name wins; otherwise name may be the unqualified last part ("Query" for
+// "Agent.Query") if only one symbol has it.
+func (o *Outline) Find(name string) (Symbol, error) {
+	var suffix []Symbol
+	for _, s := range o.Symbols {
+		if s.Name == name {
+			return s, nil
+		}
+		if strings.HasSuffix(s.Name, "."+name) {
+			suffix = append(suffix, s)
+		}
+	}
+	switch len(suffix) {
+	case 1:
+		return suffix[0], nil
+	case 0:
+		return Symbol{}, fmt.Errorf("symbol %s not found", name)
+	}
+	names := make([]string, len(suffix))
+	for i, s := range suffix {
+		names[i] = s.Name
+	}
+	return Symbol{}, fmt.Errorf("symbol %s is ambiguous: %s", name, strings.Join(names, ", "))
+}
+
+// String renders the outline one symbol per line with its line range,
+// nesting members under their container.
+func (o *Outline) String() string {
+	var b strings.Builder
+	for _, s := range o.Symbols {
+		depth := strings.Count(s.Name, ".")
+		if s.Kind == KindHeading {
+			depth = len(s.Signature) - len(strings.TrimLeft(s.Signature, "#")) - 1
+		}
+		header := s.Signature
+		if header == "" {
+			header = s.Kind + " " + s.Name
+		}
+		fmt.Fprintf(&b, "%5d-%-5d %s%s\n", s.Line, s.EndLine, strings.Repeat("  ", depth), header)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// displayExt names an extension for error messages.
+func displayExt(ext string) string {
+	if ext == "" {
+		return "extensionless"
+	}
+	return ext
+}
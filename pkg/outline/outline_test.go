@@ -0,0 +1,185 @@
+package outline
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// rangeOf describes the kind, name, doc line, line and end line of the
+// symbol called name.
+func rangeOf(t *testing.T, o *Outline, name string) string {
+	t.Helper()
+	s, err := o.Find(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("%s %s %02d %02d %02d", s.Kind, s.Name, s.DocLine, s.Line, s.EndLine)
+}
+
+// TestParseGo tests outlining Go declarations
+func TestParseGo(t *testing.T) {
+	src := `package agent
+
+// Agent runs queries.
+type Agent struct {
+	name string
+}
+
+// Runner runs.
+type Runner interface {
+	// Run runs.
+	Run() error
+}
+
+const (
+	// A is a.
+	A = 1
+	B = 2
+)
+
+var x = 3
+
+// Query answers.
+func (a *Agent) Query(input string) (string, error) {
+	return input, nil
+}
+
+func New[T any]() *Agent {
+	return &Agent{}
+}
+
+func (l *List[T]) Len() int { return 0 }
+`
+	o, err := Parse("agent.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Language != "go" || o.Lines != 31 {
+		t.Errorf("unexpected outline: %s, %d lines", o.Language, o.Lines)
+	}
+
+	tests := map[string]string{
+		"Agent":       "struct Agent 03 04 06",
+		"Runner.Run":  "method Runner.Run 10 11 11",
+		"A":           "const A 15 16 16",
+		"x":           "var x 20 20 20",
+		"Agent.Query": "method Agent.Query 22 23 25",
+		"Query":       "method Agent.Query 22 23 25",
+		"New":         "func New 27 27 29",
+		"List.Len":    "method List.Len 31 31 31",
+	}
+	for name, want := range tests {
+		if got := rangeOf(t, o, name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	if !strings.Contains(o.String(), "  func (a *Agent) Query(input string) (string, error)") {
+		t.Errorf("unexpected rendering:\n%s", o)
+	}
+	if _, err := o.Find("Missing"); err == nil {
+		t.Error("expected an error for a missing symbol")
+	}
+}
+
+// TestParseHeuristic tests the fallback outlines of other languages
+func TestParseHeuristic(t *testing.T) {
+	tests := []struct {
+		file string
+		src  string
+		want map[string]string
+	}{
+		{
+			file: "app.py",
+			src: `import os
+
+class Service:
+    """A service."""
+
+    @property
+    def name(self):
+        return "x"
+
+    def run(self, a,
+            b):
+        def helper():
+            pass
+        return helper()
+
+async def main():
+    await Service().run(1, 2)
+`,
+			want: map[string]string{
+				"Service":      "class Service 03 03 14",
+				"Service.name": "method Service.name 06 07 08",
+				"Service.run":  "method Service.run 10 10 14",
+				"main":         "func main 16 16 17",
+			},
+		},
+		{
+			file: "app.ts",
+			src: `// Options configure the app.
+export interface Options {
+  port: number;
+}
+
+export class App {
+  private readonly s = "}";
+
+  constructor(private opts: Options) {}
+
+  async start(): Promise<void> {
+    if (this.opts.port) {
+      listen();
+    }
+  }
+}
+
+export const handler = async (req: Request) => {
+  return respond(req);
+};
+
+export type ID = string;
+`,
+			want: map[string]string{
+				"Options":     "interface Options 01 02 04",
+				"App":         "class App 06 06 16",
+				"constructor": "method App.constructor 09 09 09",
+				"App.start":   "method App.start 11 11 15",
+				"handler":     "func handler 18 18 20",
+				"ID":          "type ID 22 22 22",
+			},
+		},
+		{
+			file: "README.md",
+			src:  "# Title\n\n## Install\n\n```sh\n# not a heading\n```\n\n## Usage\n\ntext\n",
+			want: map[string]string{
+				"Title":   "heading Title 01 01 11",
+				"Install": "heading Install 03 03 08",
+				"Usage":   "heading Usage 09 09 11",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			o, err := Parse(tt.file, []byte(tt.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(o.Symbols) != len(tt.want) {
+				t.Errorf("expected %d symbols, got:\n%s", len(tt.want), o)
+			}
+			for name, want := range tt.want {
+				if got := rangeOf(t, o, name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+
+	if _, err := Parse("data.bin", []byte("x")); err == nil {
+		t.Error("expected an error for an unsupported extension")
+	}
+}
@@ -0,0 +1,269 @@
+package patch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultMaxFuzz is the number of context lines that may be ignored at
+// each end of a hunk when it does not apply as written.
+const DefaultMaxFuzz = 2
+
+// maxOffset is how many lines away from the line in its header, or from
+// its anchor line, a hunk may apply.
+const maxOffset = 100
+
+// HunkResult describes where a hunk was applied.
+type HunkResult struct {
+	Header     string `json:"header"`
+	Line       int    `json:"line"`                 // First line of the hunk in the original file
+	Offset     int    `json:"offset,omitempty"`     // Lines away from the position in the header
+	Fuzz       int    `json:"fuzz,omitempty"`       // Context lines ignored at each end
+	Whitespace bool   `json:"whitespace,omitempty"` // Context matched ignoring whitespace
+}
+
+// String describes a hunk that did not apply exactly as written.
+func (r HunkResult) String() string {
+	var notes []string
+	if r.Offset != 0 {
+		notes = append(notes, fmt.Sprintf("offset %+d", r.Offset))
+	}
+	if r.Fuzz > 0 {
+		notes = append(notes, fmt.Sprintf("fuzz %d", r.Fuzz))
+	}
+	if r.Whitespace {
+		notes = append(notes, "ignoring whitespace")
+	}
+	return fmt.Sprintf("%s applied at line %d (%s)", r.Header, r.Line, strings.Join(notes, ", "))
+}
+
+// Exact reports whether the hunk applied where and as written.
+func (r HunkResult) Exact() bool {
+	return r.Offset == 0 && r.Fuzz == 0 && !r.Whitespace
+}
+
+// Apply applies hunks to content. Each hunk is searched for nearest to
+// the line in its header, adjusted by the drift of the hunks before it,
+// or to its anchor line, and must apply within maxOffset lines of it.
+// Hunks with neither must match only one place after the previous hunk.
+// Hunks must not overlap and apply in order. If the context is not found,
+// up to maxFuzz context lines are dropped from each end, and lines are
+// compared ignoring whitespace. CRLF line endings are preserved.
+func Apply(content string, hunks []*Hunk, maxFuzz int) (string, []HunkResult, error) {
+	crlf := strings.Count(content, "\r\n")*2 > strings.Count(content, "\n")
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	eol := content == "" || strings.HasSuffix(content, "\n")
+	var lines []string
+	if content != "" {
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+
+	var out []string
+	var results []HunkResult
+	pos, drift := 0, 0
+	for n, h := range hunks {
+		expected, window := pos, maxOffset
+		if h.OldStart > 0 {
+			expected = h.OldStart - 1 + drift
+		} else if h.Anchor != "" {
+			anchor := findAnchor(lines, pos, h.Anchor)
+			if anchor < 0 {
+				return "", nil, fmt.Errorf("hunk %d (%s): anchor line not found", n+1, h.Header())
+			}
+			expected = anchor + 1
+		} else {
+			window = -1
+		}
+
+		found, ok := locate(lines, pos, expected, window, h, maxFuzz)
+		if !ok {
+			if far, ok := locate(lines, pos, expected, -1, h, maxFuzz); ok {
+				return "", nil, fmt.Errorf("hunk %d (%s) does not apply within %d lines of line %d; its context is at line %d, check the hunk's position",
+					n+1, h.Header(), maxOffset, expected+1, far.start-far.lead+1)
+			}
+			return "", nil, fmt.Errorf("hunk %d (%s) does not apply: %s", n+1, h.Header(), mismatch(lines, clamp(expected, pos, len(lines)), h.old()))
+		}
+		if window < 0 {
+			if other, ok := search(lines, found.start+1, found.start+1, -1, found.old(), found.loose); ok {
+				return "", nil, fmt.Errorf("hunk %d (%s) matches at lines %d and %d; add an @@ line naming the enclosing function or more context",
+					n+1, h.Header(), found.start+1, other+1)
+			}
+		}
+
+		out = append(out, lines[pos:found.start]...)
+		out = append(out, found.replace(lines)...)
+		pos = found.start + found.length
+
+		result := HunkResult{Header: h.Header(), Line: found.start - found.lead + 1, Fuzz: found.fuzz, Whitespace: found.loose}
+		if h.OldStart > 0 {
+			result.Offset = found.start - found.lead - (h.OldStart - 1)
+			drift = result.Offset
+		}
+		results = append(results, result)
+
+		if pos == len(lines) && (h.OldNoEOL || h.NewNoEOL) {
+			eol = !h.NewNoEOL
+		}
+	}
+	out = append(out, lines[pos:]...)
+
+	result := strings.Join(out, "\n")
+	if eol && len(out) > 0 {
+		result += "\n"
+	}
+	if crlf {
+		result = strings.ReplaceAll(result, "\n", "\r\n")
+	}
+	return result, results, nil
+}
+
+// location is where a hunk matched.
+type location struct {
+	start, length int
+	lead, trail   int // Context lines dropped from each end
+	fuzz          int
+	loose         bool
+	hunk          *Hunk
+}
+
+// old returns the matched lines as the hunk has them.
+func (l location) old() []string {
+	var old []string
+	for _, line := range l.hunk.Lines[l.lead : len(l.hunk.Lines)-l.trail] {
+		if line.Kind != '+' {
+			old = append(old, line.Text)
+		}
+	}
+	return old
+}
+
+// replace returns the lines that take the place of the matched ones.
+// Context lines keep the file's text, so whitespace-insensitive matches
+// do not rewrite them.
+func (l location) replace(lines []string) []string {
+	body := l.hunk.Lines[l.lead : len(l.hunk.Lines)-l.trail]
+	var out []string
+	i := l.start
+	for _, line := range body {
+		switch line.Kind {
+		case ' ':
+			out = append(out, lines[i])
+			i++
+		case '-':
+			i++
+		case '+':
+			out = append(out, line.Text)
+		}
+	}
+	return out
+}
+
+// locate finds the position of a hunk at or after pos, nearest to
+// expected and at most window lines from it, with the least fuzz. A
+// negative window does not limit the search.
+func locate(lines []string, pos, expected, window int, h *Hunk, maxFuzz int) (location, bool) {
+	leading, trailing := 0, 0
+	for _, l := range h.Lines {
+		if l.Kind != ' ' {
+			break
+		}
+		leading++
+	}
+	for i := len(h.Lines) - 1; i >= 0 && h.Lines[i].Kind == ' '; i-- {
+		trailing++
+	}
+	if leading == len(h.Lines) {
+		trailing = 0 // A hunk of only context has nothing to anchor on
+	}
+
+	for fuzz := 0; fuzz <= maxFuzz; fuzz++ {
+		lead, trail := min(fuzz, leading), min(fuzz, trailing)
+		if fuzz > 0 && lead < fuzz && trail < fuzz {
+			break // Nothing more to drop
+		}
+		var old []string
+		for _, l := range h.Lines[lead : len(h.Lines)-trail] {
+			if l.Kind != '+' {
+				old = append(old, l.Text)
+			}
+		}
+		for _, loose := range []bool{false, true} {
+			if start, ok := search(lines, pos, expected+lead, window, old, loose); ok {
+				return location{start: start, length: len(old), lead: lead, trail: trail, fuzz: fuzz, loose: loose, hunk: h}, true
+			}
+		}
+	}
+	return location{}, false
+}
+
+// search finds old in lines at or after pos, trying positions nearest to
+// expected first and at most window lines from it if window is not
+// negative.
+func search(lines []string, pos, expected, window int, old []string, loose bool) (int, bool) {
+	last := len(lines) - len(old)
+	if last < pos {
+		return 0, false
+	}
+	expected = clamp(expected, pos, last)
+	for d := 0; (expected-d >= pos || expected+d <= last) && (window < 0 || d <= window); d++ {
+		for _, start := range []int{expected + d, expected - d} {
+			if start >= pos && start <= last && matchAt(lines, start, old, loose) {
+				return start, true
+			}
+			if d == 0 {
+				break
+			}
+		}
+	}
+	return 0, false
+}
+
+// matchAt reports whether old matches lines starting at start.
+func matchAt(lines []string, start int, old []string, loose bool) bool {
+	for i, want := range old {
+		got := lines[start+i]
+		if got == want {
+			continue
+		}
+		if !loose || strings.Join(strings.Fields(got), " ") != strings.Join(strings.Fields(want), " ") {
+			return false
+		}
+	}
+	return true
+}
+
+// findAnchor returns the first line at or after pos containing anchor.
+func findAnchor(lines []string, pos int, anchor string) int {
+	anchor = strings.TrimSpace(anchor)
+	for i := pos; i < len(lines); i++ {
+		if strings.Contains(lines[i], anchor) {
+			return i
+		}
+	}
+	return -1
+}
+
+// mismatch explains why a hunk's context did not match at line at.
+func mismatch(lines []string, at int, old []string) string {
+	for i, want := range old {
+		if at+i >= len(lines) {
+			return fmt.Sprintf("the file ends at line %d, before the hunk's context", len(lines))
+		}
+		if got := lines[at+i]; got != want {
+			return fmt.Sprintf("line %d is %q, the patch expects %q", at+i+1, truncate(got), truncate(want))
+		}
+	}
+	return "its context was not found after the previous hunk"
+}
+
+// clamp limits v to [lo, hi].
+func clamp(v, lo, hi int) int {
+	if v > hi {
+		v = hi
+	}
+	if v < lo {
+		v = lo
+	}
+	return v
+}
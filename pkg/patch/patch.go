@@ -0,0 +1,478 @@
+// Package patch parses multi-file patches and applies their hunks.
+//
+// Two formats are accepted: unified diffs as produced by git diff (with
+// extended headers for new, deleted and renamed files and mode changes)
+// or plain diff -u, and a simplified envelope that is easier to write by
+// hand:
+//
+//	*** Begin Patch
+//	*** Add File: path/new.go
+//	+package main
+//	*** Update File: path/old.go
+//	*** Move to: path/renamed.go
+//	@@ func main() {
+//	-	println("old")
+//	+	println("new")
+//	*** Delete File: path/gone.go
+//	*** End Patch
+//
+// Envelope hunks carry no line numbers; they are located by their context
+// and the optional text after @@.
+package patch
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Operations performed on a file.
+const (
+	OpCreate = "create"
+	OpDelete = "delete"
+	OpModify = "modify"
+	OpRename = "rename"
+)
+
+// FilePatch is the change to one file.
+type FilePatch struct {
+	OldPath string      // Empty for created files
+	NewPath string      // Empty for deleted files
+	OldMode os.FileMode // Permission bits from the header, 0 if not given
+	NewMode os.FileMode
+	Hunks   []*Hunk
+}
+
+// Op returns the operation the patch performs.
+func (fp *FilePatch) Op() string {
+	switch {
+	case fp.OldPath == "":
+		return OpCreate
+	case fp.NewPath == "":
+		return OpDelete
+	case fp.OldPath != fp.NewPath:
+		return OpRename
+	}
+	return OpModify
+}
+
+// Path returns the path the file ends up at, or the deleted path.
+func (fp *FilePatch) Path() string {
+	if fp.NewPath != "" {
+		return fp.NewPath
+	}
+	return fp.OldPath
+}
+
+// Line is one line of a hunk.
+type Line struct {
+	Kind byte // ' ' for context, '-' for removed, '+' for added
+	Text string
+}
+
+// Hunk is a block of changes. OldStart is 0 when the position is unknown.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Anchor             string // Text after @@ in envelope hunks
+	Lines              []Line
+	OldNoEOL, NewNoEOL bool // "\ No newline at end of file" markers
+}
+
+// Header formats the hunk header for error messages.
+func (h *Hunk) Header() string {
+	if h.OldStart == 0 {
+		return strings.TrimSpace("@@ " + h.Anchor)
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// old returns the lines the hunk expects in the file.
+func (h *Hunk) old() []string {
+	var lines []string
+	for _, l := range h.Lines {
+		if l.Kind != '+' {
+			lines = append(lines, l.Text)
+		}
+	}
+	return lines
+}
+
+// Stats returns the number of added and removed lines.
+func (fp *FilePatch) Stats() (added, removed int) {
+	for _, h := range fp.Hunks {
+		for _, l := range h.Lines {
+			switch l.Kind {
+			case '+':
+				added++
+			case '-':
+				removed++
+			}
+		}
+	}
+	return added, removed
+}
+
+// Parse parses a patch in git diff, unified diff or envelope format.
+func Parse(text string) ([]*FilePatch, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "*** Begin Patch" {
+			return parseEnvelope(lines)
+		}
+	}
+	return parseUnified(lines)
+}
+
+// Paths returns every path a patch reads or writes, without duplicates. It
+// returns nil if the patch cannot be parsed.
+func Paths(text string) []string {
+	files, err := Parse(text)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	seen := make(map[string]bool)
+	for _, fp := range files {
+		for _, p := range []string{fp.OldPath, fp.NewPath} {
+			if p != "" && !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+	return paths
+}
+
+// parseUnified parses git and plain unified diffs.
+func parseUnified(lines []string) ([]*FilePatch, error) {
+	var files []*FilePatch
+	var cur *FilePatch
+	gitHeader := false // Inside the extended header of a diff --git section
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			oldPath, newPath := splitGitPaths(strings.TrimPrefix(line, "diff --git "))
+			cur = &FilePatch{OldPath: oldPath, NewPath: newPath}
+			files = append(files, cur)
+			gitHeader = true
+
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if cur == nil || !gitHeader {
+				cur = &FilePatch{}
+				files = append(files, cur)
+			}
+			cur.OldPath = headerPath(strings.TrimPrefix(line, "--- "))
+			cur.NewPath = headerPath(strings.TrimPrefix(lines[i+1], "+++ "))
+			gitHeader = false
+			i++
+
+		case strings.HasPrefix(line, "@@"):
+			if cur == nil {
+				// A bare hunk applies to the file given elsewhere
+				cur = &FilePatch{}
+				files = append(files, cur)
+			}
+			gitHeader = false
+			hunk, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			cur.Hunks = append(cur.Hunks, hunk)
+			i = next - 1
+
+		case gitHeader:
+			if err := parseGitHeader(cur, line); err != nil {
+				return nil, err
+			}
+
+		case strings.TrimSpace(line) == "" || cur == nil:
+			// Blank lines and commentary before the first file
+
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %q outside a hunk", i+1, truncate(line))
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file changes found in patch")
+	}
+	for _, fp := range files {
+		if fp.OldPath == "" && fp.NewPath == "" && len(files) > 1 {
+			return nil, fmt.Errorf("a file in the patch has no path")
+		}
+	}
+	return files, nil
+}
+
+// parseGitHeader applies one extended header line of a git diff.
+func parseGitHeader(fp *FilePatch, line string) error {
+	field := func(prefix string) (string, bool) {
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimSpace(strings.TrimPrefix(line, prefix)), true
+		}
+		return "", false
+	}
+
+	if v, ok := field("new file mode "); ok {
+		fp.OldPath, fp.NewMode = "", parseMode(v)
+	} else if v, ok := field("deleted file mode "); ok {
+		fp.NewPath, fp.OldMode = "", parseMode(v)
+	} else if v, ok := field("old mode "); ok {
+		fp.OldMode = parseMode(v)
+	} else if v, ok := field("new mode "); ok {
+		fp.NewMode = parseMode(v)
+	} else if v, ok := field("rename from "); ok {
+		fp.OldPath = unquote(v)
+	} else if v, ok := field("rename to "); ok {
+		fp.NewPath = unquote(v)
+	} else if strings.HasPrefix(line, "copy from ") || strings.HasPrefix(line, "copy to ") {
+		return fmt.Errorf("copies are not supported (%s)", fp.Path())
+	} else if strings.HasPrefix(line, "Binary files ") || strings.HasPrefix(line, "GIT binary patch") {
+		return fmt.Errorf("binary patches are not supported (%s)", fp.Path())
+	}
+	// index, similarity and dissimilarity lines carry nothing we need
+	return nil
+}
+
+// parseHunk parses the hunk starting at lines[start] and returns the index
+// of the first line after it.
+func parseHunk(lines []string, start int) (*Hunk, int, error) {
+	hunk := &Hunk{}
+	header := lines[start]
+	if _, err := fmt.Sscanf(rangeField(header, '-'), "%d,%d", &hunk.OldStart, &hunk.OldLines); err != nil {
+		if _, err := fmt.Sscanf(rangeField(header, '-'), "%d", &hunk.OldStart); err != nil {
+			return nil, 0, fmt.Errorf("line %d: invalid hunk header %q", start+1, truncate(header))
+		}
+		hunk.OldLines = 1
+	}
+	if _, err := fmt.Sscanf(rangeField(header, '+'), "%d,%d", &hunk.NewStart, &hunk.NewLines); err != nil {
+		if _, err := fmt.Sscanf(rangeField(header, '+'), "%d", &hunk.NewStart); err != nil {
+			return nil, 0, fmt.Errorf("line %d: invalid hunk header %q", start+1, truncate(header))
+		}
+		hunk.NewLines = 1
+	}
+	if hunk.OldStart == 0 && hunk.OldLines == 0 {
+		hunk.OldStart = 1 // New files start before line 1
+	}
+
+	oldSeen, newSeen := 0, 0
+	i := start + 1
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		complete := oldSeen >= hunk.OldLines && newSeen >= hunk.NewLines
+		if strings.HasPrefix(line, "@@") || strings.HasPrefix(line, "diff --git ") ||
+			(complete && strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")) {
+			break
+		}
+		if strings.HasPrefix(line, `\`) {
+			markNoEOL(hunk)
+			continue
+		}
+		if line == "" {
+			// Editors often strip the space of empty context lines
+			if complete {
+				break
+			}
+			line = " "
+		}
+		kind := line[0]
+		if kind != ' ' && kind != '-' && kind != '+' {
+			break
+		}
+		hunk.Lines = append(hunk.Lines, Line{Kind: kind, Text: line[1:]})
+		if kind != '+' {
+			oldSeen++
+		}
+		if kind != '-' {
+			newSeen++
+		}
+	}
+	if len(hunk.Lines) == 0 {
+		return nil, 0, fmt.Errorf("line %d: empty hunk", start+1)
+	}
+	return hunk, i, nil
+}
+
+// markNoEOL records a "\ No newline at end of file" marker for the line
+// before it.
+func markNoEOL(h *Hunk) {
+	if len(h.Lines) == 0 {
+		return
+	}
+	switch h.Lines[len(h.Lines)-1].Kind {
+	case '-':
+		h.OldNoEOL = true
+	case '+':
+		h.NewNoEOL = true
+	default:
+		h.OldNoEOL, h.NewNoEOL = true, true
+	}
+}
+
+// parseEnvelope parses the simplified envelope format.
+func parseEnvelope(lines []string) ([]*FilePatch, error) {
+	var files []*FilePatch
+	var cur *FilePatch
+	var hunk *Hunk
+	inside := false
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		directive := func(prefix string) (string, bool) {
+			if strings.HasPrefix(trimmed, prefix) {
+				return strings.TrimSpace(strings.TrimPrefix(trimmed, prefix)), true
+			}
+			return "", false
+		}
+
+		if !inside {
+			inside = trimmed == "*** Begin Patch"
+			continue
+		}
+		if trimmed == "*** End Patch" {
+			break
+		}
+
+		if path, ok := directive("*** Add File:"); ok {
+			cur = &FilePatch{NewPath: path}
+			hunk = &Hunk{OldStart: 1, NewStart: 1}
+			cur.Hunks = append(cur.Hunks, hunk)
+			files = append(files, cur)
+			continue
+		}
+		if path, ok := directive("*** Delete File:"); ok {
+			cur, hunk = &FilePatch{OldPath: path}, nil
+			files = append(files, cur)
+			continue
+		}
+		if path, ok := directive("*** Update File:"); ok {
+			cur, hunk = &FilePatch{OldPath: path, NewPath: path}, nil
+			files = append(files, cur)
+			continue
+		}
+		if path, ok := directive("*** Move to:"); ok {
+			if cur == nil || cur.Op() == OpCreate || cur.Op() == OpDelete || len(cur.Hunks) > 0 {
+				return nil, fmt.Errorf("line %d: *** Move to must follow *** Update File", i+1)
+			}
+			cur.NewPath = path
+			continue
+		}
+		if trimmed == "*** End of File" {
+			continue
+		}
+		if cur == nil {
+			return nil, fmt.Errorf("line %d: expected a file directive, got %q", i+1, truncate(line))
+		}
+
+		switch cur.Op() {
+		case OpDelete:
+			if trimmed != "" {
+				return nil, fmt.Errorf("line %d: unexpected content after *** Delete File", i+1)
+			}
+		case OpCreate:
+			if !strings.HasPrefix(line, "+") {
+				return nil, fmt.Errorf("line %d: lines of an added file must start with +", i+1)
+			}
+			hunk.Lines = append(hunk.Lines, Line{Kind: '+', Text: line[1:]})
+			hunk.NewLines++
+		default:
+			if strings.HasPrefix(line, "@@") {
+				hunk = &Hunk{Anchor: strings.TrimSpace(strings.TrimPrefix(line, "@@"))}
+				cur.Hunks = append(cur.Hunks, hunk)
+				continue
+			}
+			if hunk == nil {
+				hunk = &Hunk{}
+				cur.Hunks = append(cur.Hunks, hunk)
+			}
+			if line == "" {
+				line = " "
+			}
+			if kind := line[0]; kind == ' ' || kind == '-' || kind == '+' {
+				hunk.Lines = append(hunk.Lines, Line{Kind: kind, Text: line[1:]})
+				continue
+			}
+			return nil, fmt.Errorf("line %d: hunk lines must start with ' ', '-' or '+', got %q", i+1, truncate(line))
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file changes found in patch")
+	}
+	for _, fp := range files {
+		if fp.Op() != OpDelete && fp.Op() != OpCreate && fp.Op() != OpRename && len(fp.Hunks) == 0 {
+			return nil, fmt.Errorf("*** Update File: %s has no changes", fp.OldPath)
+		}
+	}
+	return files, nil
+}
+
+// splitGitPaths splits the "a/x b/y" part of a diff --git line.
+func splitGitPaths(s string) (string, string) {
+	if idx := strings.LastIndex(s, " b/"); idx >= 0 {
+		return headerPath(s[:idx]), headerPath(s[idx+1:])
+	}
+	fields := strings.Fields(s)
+	if len(fields) == 2 {
+		return headerPath(fields[0]), headerPath(fields[1])
+	}
+	return "", ""
+}
+
+// headerPath cleans a path from a ---/+++ or diff --git line.
+func headerPath(s string) string {
+	if idx := strings.IndexByte(s, '\t'); idx >= 0 {
+		s = s[:idx] // diff -u appends a timestamp
+	}
+	s = unquote(strings.TrimSpace(s))
+	if s == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/") {
+		return s[2:]
+	}
+	return s
+}
+
+// unquote removes the C-style quoting git uses for unusual paths.
+func unquote(s string) string {
+	if strings.HasPrefix(s, `"`) {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+	}
+	return s
+}
+
+// parseMode returns the permission bits of an octal git mode.
+func parseMode(s string) os.FileMode {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0
+	}
+	return os.FileMode(mode) & os.ModePerm
+}
+
+// rangeField returns the "-a,b" or "+c,d" range of a hunk header without
+// its sign.
+func rangeField(header string, sign byte) string {
+	for _, f := range strings.Fields(header) {
+		if len(f) > 1 && f[0] == sign {
+			return f[1:]
+		}
+	}
+	return ""
+}
+
+// truncate shortens a line for error messages.
+func truncate(s string) string {
+	if len(s) > 60 {
+		return s[:60] + "..."
+	}
+	return s
+}
@@ -0,0 +1,255 @@
+package patch
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestParseGitDiff tests parsing the extended headers of a git diff
+func TestParseGitDiff(t *testing.T) {
+	text := `diff --git a/main.go b/main.go
+index 83db48f..bf269f4 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ package main
+-var x = 1
++var x = 2
+
+diff --git a/new.txt b/new.txt
+new file mode 100644
+index 0000000..3b18e51
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++hello
+\ No newline at end of file
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/run.sh b/scripts/run.sh
+old mode 100644
+new mode 100755
+similarity index 100%
+rename from run.sh
+rename to scripts/run.sh
+`
+	files, err := Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("expected 4 files, got %d", len(files))
+	}
+
+	tests := []struct {
+		op, path string
+		hunks    int
+	}{
+		{OpModify, "main.go", 1},
+		{OpCreate, "new.txt", 1},
+		{OpDelete, "old.txt", 1},
+		{OpRename, "scripts/run.sh", 0},
+	}
+	for i, tt := range tests {
+		if files[i].Op() != tt.op || files[i].Path() != tt.path || len(files[i].Hunks) != tt.hunks {
+			t.Errorf("file %d = %s %s with %d hunks, want %s %s with %d", i, files[i].Op(), files[i].Path(), len(files[i].Hunks), tt.op, tt.path, tt.hunks)
+		}
+	}
+	if len(files[0].Hunks[0].Lines) != 4 {
+		t.Errorf("expected the empty context line to be kept, got %+v", files[0].Hunks[0].Lines)
+	}
+	if !files[1].Hunks[0].NewNoEOL {
+		t.Error("expected the no-newline marker on new.txt")
+	}
+	if files[3].OldMode != 0644 || files[3].NewMode != 0755 || files[3].OldPath != "run.sh" {
+		t.Errorf("unexpected rename: %+v", files[3])
+	}
+	if paths := Paths(text); strings.Join(paths, ",") != "main.go,new.txt,old.txt,run.sh,scripts/run.sh" {
+		t.Errorf("unexpected paths: %v", paths)
+	}
+}
+
+// TestParseEnvelope tests parsing the envelope format
+func TestParseEnvelope(t *testing.T) {
+	text := `*** Begin Patch
+*** Add File: docs/new.md
++# Title
++
+*** Update File: a.go
+*** Move to: b.go
+@@ func main() {
+-	old()
++	new()
+*** Delete File: c.go
+*** End Patch`
+	files, err := Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("expected 3 files, got %d", len(files))
+	}
+	if files[0].Op() != OpCreate || len(files[0].Hunks[0].Lines) != 2 {
+		t.Errorf("unexpected added file: %+v", files[0])
+	}
+	if files[1].Op() != OpRename || files[1].Hunks[0].Anchor != "func main() {" {
+		t.Errorf("unexpected update: %+v", files[1])
+	}
+	if files[2].Op() != OpDelete {
+		t.Errorf("unexpected delete: %+v", files[2])
+	}
+
+	for _, bad := range []string{
+		"*** Begin Patch\n*** Update File: a.go\n*** End Patch",
+		"*** Begin Patch\n*** Add File: a.go\nno plus\n*** End Patch",
+		"*** Begin Patch\n*** End Patch",
+	} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+// TestApply tests locating hunks with offsets, fuzz and whitespace drift
+func TestApply(t *testing.T) {
+	base := "one\ntwo\nthree\nfour\nfive\nsix\nseven\n"
+	farBase := base + strings.Repeat("filler\n", 150) + "eight\nnine\nten\n"
+
+	tests := []struct {
+		name    string
+		content string
+		patch   string
+		want    string
+		note    string
+		wantErr string
+	}{
+		{
+			name:    "exact",
+			content: base,
+			patch:   "@@ -2,3 +2,3 @@\n two\n-three\n+THREE\n four\n",
+			want:    "one\ntwo\nTHREE\nfour\nfive\nsix\nseven\n",
+		},
+		{
+			name:    "shifted line numbers",
+			content: "zero\n" + base,
+			patch:   "@@ -2,3 +2,3 @@\n two\n-three\n+THREE\n four\n",
+			want:    "zero\none\ntwo\nTHREE\nfour\nfive\nsix\nseven\n",
+			note:    "offset +1",
+		},
+		{
+			name:    "drifted context",
+			content: base,
+			patch:   "@@ -2,3 +2,3 @@\n TWO\n-three\n+THREE\n four\n",
+			want:    "one\ntwo\nTHREE\nfour\nfive\nsix\nseven\n",
+			note:    "fuzz 1",
+		},
+		{
+			name:    "whitespace",
+			content: "if x {\n\treturn\n}\n",
+			patch:   "@@ -1,3 +1,3 @@\n if x {\n-    return\n+\treturn nil\n }\n",
+			want:    "if x {\n\treturn nil\n}\n",
+			note:    "ignoring whitespace",
+		},
+		{
+			name:    "crlf",
+			content: "a\r\nb\r\n",
+			patch:   "@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+			want:    "a\r\nc\r\n",
+		},
+		{
+			name:    "no newline at end",
+			content: "a\nb\n",
+			patch:   "@@ -1,2 +1,2 @@\n a\n-b\n+c\n\\ No newline at end of file\n",
+			want:    "a\nc",
+		},
+		{
+			name:    "context missing",
+			content: base,
+			patch:   "@@ -2,3 +2,3 @@\n deux\n-trois\n+THREE\n quatre\n",
+			wantErr: `line 2 is "two", the patch expects "deux"`,
+		},
+		{
+			name:    "context far from header",
+			content: farBase,
+			patch:   "@@ -2,3 +2,3 @@\n eight\n-nine\n+NINE\n ten\n",
+			wantErr: "does not apply within 100 lines of line 2; its context is at line 158",
+		},
+		{
+			name:    "context near header",
+			content: farBase,
+			patch:   "@@ -100,3 +100,3 @@\n eight\n-nine\n+NINE\n ten\n",
+			want:    strings.Replace(farBase, "nine", "NINE", 1),
+			note:    "offset +58",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := Parse(tt.patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, results, err := Apply(tt.content, files[0].Hunks, DefaultMaxFuzz)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Apply = %q, want %q", got, tt.want)
+			}
+			if tt.note != "" && !strings.Contains(results[0].String(), tt.note) {
+				t.Errorf("expected %q in %q", tt.note, results[0].String())
+			}
+			if tt.note == "" && !results[0].Exact() {
+				t.Errorf("expected an exact application, got %s", results[0])
+			}
+		})
+	}
+}
+
+// TestApplyEnvelopeAnchor tests locating envelope hunks by their anchor
+func TestApplyEnvelopeAnchor(t *testing.T) {
+	content := "func a() {\n\treturn\n}\n\nfunc b() {\n\treturn\n}\n"
+	files, err := Parse("*** Begin Patch\n*** Update File: x.go\n@@ func b() {\n-\treturn\n+\treturn // b\n*** End Patch\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := Apply(content, files[0].Hunks, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "func a() {\n\treturn\n}\n\nfunc b() {\n\treturn // b\n}\n"; got != want {
+		t.Errorf("Apply = %q, want %q", got, want)
+	}
+}
+
+// TestApplyEnvelopeAmbiguous tests that hunks without a position must
+// match one place only
+func TestApplyEnvelopeAmbiguous(t *testing.T) {
+	content := "func a() {\n\treturn\n}\n\nfunc b() {\n\treturn\n}\n"
+	files, err := Parse("*** Begin Patch\n*** Update File: x.go\n@@\n-\treturn\n+\treturn // b\n*** End Patch\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = Apply(content, files[0].Hunks, 0)
+	if err == nil || !strings.Contains(err.Error(), "matches at lines 2 and 6") {
+		t.Errorf("expected an ambiguous hunk error, got %v", err)
+	}
+}
+
+// TestParseMode tests reading permission bits from git modes
+func TestParseMode(t *testing.T) {
+	if parseMode("100755") != 0755 || parseMode("100644") != os.FileMode(0644) || parseMode("x") != 0 {
+		t.Error("unexpected modes")
+	}
+}
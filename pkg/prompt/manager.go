@@ -29,6 +29,9 @@ type Context interface {
 // This allows the prompt manager to get dynamic tool lists without circular dependencies.
 type ToolListProvider func() []string
 
+// RepoMapProvider is a function that returns the ranked repository map.
+type RepoMapProvider func() string
+
 // Manager manages system prompts with support for:
 // - Multiple sources (file, env, config, default)
 // - Template variable interpolation
@@ -52,6 +55,9 @@ type Manager struct {
 
 	// Tool list provider (optional, provides dynamic tool list)
 	toolListProvider ToolListProvider
+
+	// Repository map provider (optional, backs {{ .RepoMap }})
+	repoMapProvider RepoMapProvider
 }
 
 // PromptConfig represents the YAML configuration for system prompts.
@@ -211,6 +217,11 @@ func (m *Manager) Compose() (string, error) {
 	// Build template variables
 	templateVars := m.buildTemplateVars()
 
+	// The repository map walks the tree, so only build it when used
+	if m.repoMapProvider != nil && strings.Contains(content, ".RepoMap") {
+		templateVars["RepoMap"] = m.repoMapProvider()
+	}
+
 	// Parse and execute template
 	tmpl, err := template.New("system").Parse(content)
 	if err != nil {
@@ -259,6 +270,9 @@ func (m *Manager) buildTemplateVars() map[string]any {
 		"Branch":   m.context.GetProjectGitBranch(),
 	}
 
+	// Repository map, filled in by Compose when the template uses it
+	vars["RepoMap"] = ""
+
 	// Tools information - use dynamic tool list if provider is set
 	var toolList []string
 	if m.toolListProvider != nil {
@@ -307,6 +321,11 @@ func (m *Manager) SetToolListProvider(provider ToolListProvider) {
 	m.toolListProvider = provider
 }
 
+// SetRepoMapProvider sets the function that provides {{ .RepoMap }}.
+func (m *Manager) SetRepoMapProvider(provider RepoMapProvider) {
+	m.repoMapProvider = provider
+}
+
 // UseProfile switches to a different profile (if YAML config supports multiple profiles).
 // For now, this is a placeholder for future multi-profile support.
 func (m *Manager) UseProfile(name string) error {
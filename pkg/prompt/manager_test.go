@@ -289,6 +289,36 @@ func TestCompose(t *testing.T) {
 	}
 }
 
+// TestCompose_WithRepoMap tests that the repository map is built only when used.
+func TestCompose_WithRepoMap(t *testing.T) {
+	cfg := &config.Config{WorkDir: "/test"}
+	ctx := newMockContext("/test")
+
+	calls := 0
+	manager := NewManager(cfg, ctx)
+	manager.SetRepoMapProvider(func() string {
+		calls++
+		return "pkg/agent/agent.go\n  type Agent struct"
+	})
+
+	manager.base = "Project: {{ .Project.Name }}"
+	if _, err := manager.Compose(); err != nil {
+		t.Fatalf("Compose() failed: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("expected the map not to be built for a template without it, got %d calls", calls)
+	}
+
+	manager.base = "{{ if .RepoMap }}## Map\n{{ .RepoMap }}{{ end }}"
+	result, err := manager.Compose()
+	if err != nil {
+		t.Fatalf("Compose() failed: %v", err)
+	}
+	if calls != 1 || !strings.Contains(result, "## Map\npkg/agent/agent.go") {
+		t.Errorf("unexpected result after %d calls: %s", calls, result)
+	}
+}
+
 // TestCompose_WithPartials tests composition with partials.
 func TestCompose_WithPartials(t *testing.T) {
 	cfg := &config.Config{WorkDir: "/test"}
@@ -0,0 +1,371 @@
+// Package repomap builds a ranked map of a repository for the system
+// prompt: its key files with their top-level declarations, fitted to a
+// token budget.
+//
+// Files are ranked by how many other files reference the names they
+// declare and by how recently they changed. Parsing results are cached
+// per file in .goai/cache/repomap.json, so after the first build only
+// files whose size or modification time changed are parsed again.
+package repomap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zerofisher/goai/pkg/ignore"
+	"github.com/Zerofisher/goai/pkg/outline"
+)
+
+// cacheVersion changes whenever the cached file format does.
+const cacheVersion = 1
+
+// Defaults for Options.
+const (
+	DefaultMaxTokens   = 1500
+	DefaultMaxFiles    = 5000
+	DefaultMaxFileSize = 256 * 1024
+)
+
+// Options configures a Map.
+type Options struct {
+	MaxTokens   int      // Budget for the rendered map, estimated at 4 characters per token
+	MaxFiles    int      // Source files considered; the most recently modified are kept
+	MaxFileSize int64    // Larger files are listed without being parsed
+	Excludes    []string // Extra gitignore-style patterns to skip
+	CachePath   string   // Defaults to .goai/cache/repomap.json in the work directory
+}
+
+// keyFiles are always worth listing, even without symbols.
+var keyFiles = map[string]bool{
+	"go.mod": true, "package.json": true, "pyproject.toml": true, "Cargo.toml": true,
+	"main.go": true, "Makefile": true, "README.md": true, "Dockerfile": true,
+}
+
+// identRe finds identifiers for reference counting.
+var identRe = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// symbol is one top-level declaration of a file.
+type symbol struct {
+	Name      string `json:"name"`
+	Signature string `json:"sig"`
+}
+
+// fileEntry is the cached parse of one file.
+type fileEntry struct {
+	ModTime time.Time `json:"mtime"`
+	Size    int64     `json:"size"`
+	Symbols []symbol  `json:"symbols,omitempty"`
+	Idents  []string  `json:"idents,omitempty"` // Distinct identifiers used in the file
+}
+
+// cacheFile is the on-disk cache.
+type cacheFile struct {
+	Version int                   `json:"version"`
+	Files   map[string]*fileEntry `json:"files"`
+}
+
+// Map builds repository maps for one work directory. It is safe for
+// concurrent use.
+type Map struct {
+	workDir string
+	opts    Options
+
+	mu    sync.Mutex
+	files map[string]*fileEntry
+	dirty bool
+	now   func() time.Time
+}
+
+// New creates a map for workDir and loads its cache.
+func New(workDir string, opts Options) *Map {
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = DefaultMaxTokens
+	}
+	if opts.MaxFiles <= 0 {
+		opts.MaxFiles = DefaultMaxFiles
+	}
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = DefaultMaxFileSize
+	}
+	if opts.CachePath == "" {
+		opts.CachePath = filepath.Join(workDir, ".goai", "cache", "repomap.json")
+	}
+	m := &Map{workDir: workDir, opts: opts, files: make(map[string]*fileEntry), now: time.Now}
+	m.load()
+	return m
+}
+
+// Render updates the parsed files and returns the map fitted to the token
+// budget. The cache is saved when anything changed.
+func (m *Map) Render(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.update(ctx); err != nil {
+		return "", err
+	}
+	if m.dirty {
+		if err := m.save(); err == nil {
+			m.dirty = false
+		}
+	}
+	return m.render(), nil
+}
+
+// candidate is a file found by the walk.
+type candidate struct {
+	rel  string
+	info fs.FileInfo
+}
+
+// update walks the work directory and parses new and changed files.
+func (m *Map) update(ctx context.Context) error {
+	var found []candidate
+	err := ignore.Walk(ctx, m.workDir, ignore.WalkOptions{Excludes: m.opts.Excludes}, func(rel string, d fs.DirEntry) error {
+		if !d.Type().IsRegular() || !mappable(rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		found = append(found, candidate{rel: rel, info: info})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Keep the most recently modified files on very large trees
+	if len(found) > m.opts.MaxFiles {
+		sort.Slice(found, func(i, j int) bool { return found[i].info.ModTime().After(found[j].info.ModTime()) })
+		found = found[:m.opts.MaxFiles]
+	}
+
+	seen := make(map[string]bool, len(found))
+	for _, c := range found {
+		seen[c.rel] = true
+		if e, ok := m.files[c.rel]; ok && e.Size == c.info.Size() && e.ModTime.Equal(c.info.ModTime()) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		m.files[c.rel] = m.parse(c)
+		m.dirty = true
+	}
+	for rel := range m.files {
+		if !seen[rel] {
+			delete(m.files, rel)
+			m.dirty = true
+		}
+	}
+	return nil
+}
+
+// mappable reports whether rel can appear in the map.
+func mappable(rel string) bool {
+	if keyFiles[path.Base(rel)] {
+		return true
+	}
+	ext := path.Ext(rel)
+	return outline.Supported(rel) && ext != ".md" && ext != ".markdown"
+}
+
+// parse reads the symbols and identifiers of a file.
+func (m *Map) parse(c candidate) *fileEntry {
+	e := &fileEntry{ModTime: c.info.ModTime(), Size: c.info.Size()}
+	if c.info.Size() > m.opts.MaxFileSize {
+		return e
+	}
+	content, err := os.ReadFile(filepath.Join(m.workDir, filepath.FromSlash(c.rel)))
+	if err != nil {
+		return e
+	}
+
+	if o, err := outline.Parse(c.rel, content); err == nil {
+		for _, s := range o.Symbols {
+			if strings.Contains(s.Name, ".") || s.Name == "_" || s.Name == "init" || s.Name == "main" {
+				continue // Members are listed with their container only
+			}
+			e.Symbols = append(e.Symbols, symbol{Name: s.Name, Signature: shorten(s.Signature, 100)})
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, ident := range identRe.FindAllString(string(content), -1) {
+		if len(ident) > 2 && !seen[ident] {
+			seen[ident] = true
+			e.Idents = append(e.Idents, ident)
+		}
+	}
+	sort.Strings(e.Idents)
+	return e
+}
+
+// ranked is a file with its score and symbols in order of importance.
+type ranked struct {
+	rel     string
+	score   float64
+	symbols []symbol
+}
+
+// rank scores every file. A declared name is worth more the more other
+// files use it, shared between the files declaring it; test files count
+// less and recently modified files more.
+func (m *Map) rank() []ranked {
+	defined := make(map[string]int)
+	for _, e := range m.files {
+		for _, s := range e.Symbols {
+			defined[s.Name]++
+		}
+	}
+	users := make(map[string]int)
+	for _, e := range m.files {
+		for _, ident := range e.Idents {
+			if defined[ident] > 0 {
+				users[ident]++
+			}
+		}
+	}
+
+	now := m.now()
+	files := make([]ranked, 0, len(m.files))
+	for rel, e := range m.files {
+		weights := make(map[string]float64, len(e.Symbols))
+		score := 0.1 // Files nobody uses still beat no files at all
+		for _, s := range e.Symbols {
+			// The declaring file uses the name too
+			w := math.Log2(1+float64(max(users[s.Name]-1, 0))) / float64(defined[s.Name])
+			if path.Ext(rel) == ".go" && !ast.IsExported(s.Name) {
+				w /= 2 // Only usable within the package
+			}
+			weights[s.Name] = w
+			score += w
+		}
+		if keyFiles[path.Base(rel)] {
+			score += 2
+		}
+		if isTest(rel) {
+			score *= 0.2
+		}
+		age := now.Sub(e.ModTime).Hours() / 24
+		score *= 1 + math.Exp(-age/7)
+
+		symbols := append([]symbol(nil), e.Symbols...)
+		sort.SliceStable(symbols, func(i, j int) bool { return weights[symbols[i].Name] > weights[symbols[j].Name] })
+		files = append(files, ranked{rel: rel, score: score, symbols: symbols})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].score != files[j].score {
+			return files[i].score > files[j].score
+		}
+		return files[i].rel < files[j].rel
+	})
+	return files
+}
+
+// render lists the best-ranked files with their most used symbols until
+// the token budget is spent, then orders them by path.
+func (m *Map) render() string {
+	const maxSymbols = 8
+	budget := m.opts.MaxTokens * 4
+
+	files := m.rank()
+	type block struct {
+		rel  string
+		text string
+	}
+	var blocks []block
+	used := 0
+	for _, f := range files {
+		if len(f.symbols) == 0 && !keyFiles[path.Base(f.rel)] {
+			continue
+		}
+		var b strings.Builder
+		b.WriteString(f.rel + "\n")
+		for i, s := range f.symbols {
+			if i == maxSymbols {
+				fmt.Fprintf(&b, "  ... %d more\n", len(f.symbols)-maxSymbols)
+				break
+			}
+			b.WriteString("  " + s.Signature + "\n")
+		}
+		if used+b.Len() > budget {
+			if used+len(f.rel)+1 > budget {
+				break
+			}
+			b.Reset()
+			b.WriteString(f.rel + "\n") // The file name alone still fits
+		}
+		used += b.Len()
+		blocks = append(blocks, block{rel: f.rel, text: b.String()})
+	}
+
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].rel < blocks[j].rel })
+	var out strings.Builder
+	for _, b := range blocks {
+		out.WriteString(b.text)
+	}
+	if rest := len(m.files) - len(blocks); rest > 0 && len(blocks) > 0 {
+		fmt.Fprintf(&out, "(%d more files not shown)\n", rest)
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// load reads the cache; a missing or outdated cache is ignored.
+func (m *Map) load() {
+	data, err := os.ReadFile(m.opts.CachePath)
+	if err != nil {
+		return
+	}
+	var c cacheFile
+	if json.Unmarshal(data, &c) != nil || c.Version != cacheVersion || c.Files == nil {
+		return
+	}
+	m.files = c.Files
+}
+
+// save writes the cache.
+func (m *Map) save() error {
+	data, err := json.Marshal(cacheFile{Version: cacheVersion, Files: m.files})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.opts.CachePath), 0755); err != nil {
+		return err
+	}
+	tmp := m.opts.CachePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.opts.CachePath)
+}
+
+// isTest reports whether rel looks like a test file.
+func isTest(rel string) bool {
+	base := path.Base(rel)
+	return strings.HasSuffix(base, "_test.go") || strings.HasPrefix(base, "test_") ||
+		strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") ||
+		strings.HasPrefix(rel, "test/") || strings.HasPrefix(rel, "tests/") || strings.Contains(rel, "/tests/")
+}
+
+// shorten truncates s to n bytes.
+func shorten(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
@@ -0,0 +1,92 @@
+package repomap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zerofisher/goai/internal/testutil"
+)
+
+// TestRender tests ranking by references and fitting the budget
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFiles(t, dir, map[string]string{
+		".gitignore":       "vendor/\n",
+		"go.mod":           "module example.com/x\n",
+		"widget/widget.go": "package widget\n\n// Widget is used everywhere.\ntype Widget struct{}\n\nfunc NewWidget() *Widget { return nil }\n",
+		"a/a.go":           "package a\n\nvar w = widget.NewWidget()\nvar v widget.Widget\n",
+		"b/b.go":           "package b\n\nfunc Use() widget.Widget { return widget.Widget{} }\n",
+		"lonely/lonely.go": "package lonely\n\nfunc Unused() {}\n",
+		"vendor/dep.go":    "package dep\n\nfunc Widget() {}\n",
+		"notes.txt":        "Widget\n",
+	})
+
+	m := New(dir, Options{})
+	old := time.Now().Add(365 * 24 * time.Hour)
+	m.now = func() time.Time { return old } // No recency boost
+	out, err := m.Render(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "widget/widget.go\n  type Widget struct\n  func NewWidget() *Widget") {
+		t.Errorf("expected widget.go with its symbols, got:\n%s", out)
+	}
+	if strings.Contains(out, "vendor") || strings.Contains(out, "notes.txt") {
+		t.Errorf("expected ignored and unsupported files to be left out, got:\n%s", out)
+	}
+
+	ranks := m.rank()
+	if ranks[0].rel != "widget/widget.go" {
+		t.Errorf("expected widget.go to rank first, got %s", ranks[0].rel)
+	}
+
+	// A tiny budget keeps only the best-ranked file
+	small := New(dir, Options{MaxTokens: 12, CachePath: filepath.Join(t.TempDir(), "c.json")})
+	small.now = m.now
+	out, _ = small.Render(context.Background())
+	if !strings.Contains(out, "widget/widget.go\n") || strings.Contains(out, "lonely") || !strings.Contains(out, "more files not shown") {
+		t.Errorf("unexpected map for a small budget:\n%s", out)
+	}
+}
+
+// TestCache tests that unchanged files are not parsed again
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFiles(t, dir, map[string]string{
+		"a.go": "package a\n\nfunc Alpha() {}\n",
+		"b.go": "package a\n\nfunc Beta() { Alpha() }\n",
+	})
+
+	if _, err := New(dir, Options{}).Render(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	cache := filepath.Join(dir, ".goai", "cache", "repomap.json")
+	if _, err := os.Stat(cache); err != nil {
+		t.Fatalf("expected the cache to be written: %v", err)
+	}
+
+	// A fresh map starts from the cache; mark an entry to see whether it is reused
+	m := New(dir, Options{})
+	m.files["a.go"].Symbols[0].Signature = "func Alpha() // cached"
+	out, _ := m.Render(context.Background())
+	if !strings.Contains(out, "// cached") {
+		t.Errorf("expected the cached entry to be reused, got:\n%s", out)
+	}
+
+	later := time.Now().Add(time.Minute)
+	testutil.WriteFiles(t, dir, map[string]string{"a.go": "package a\n\nfunc Gamma() {}\n\nvar _ = Gamma\n"})
+	if err := os.Chtimes(filepath.Join(dir, "a.go"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "b.go")); err != nil {
+		t.Fatal(err)
+	}
+	out, _ = m.Render(context.Background())
+	if !strings.Contains(out, "func Gamma()") || strings.Contains(out, "b.go") {
+		t.Errorf("expected changed and deleted files to be updated, got:\n%s", out)
+	}
+}
@@ -0,0 +1,188 @@
+package semantic
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// Chunking limits, in lines unless noted.
+const (
+	// WindowLines is the size of the fixed windows used for files that are
+	// not Go and for Go declarations longer than MaxDeclLines.
+	WindowLines = 60
+	// WindowOverlap is how many lines consecutive windows share, so code
+	// at a window boundary appears whole in one of them.
+	WindowOverlap = 10
+	// MaxDeclLines is the longest Go declaration embedded as one chunk.
+	MaxDeclLines = 150
+	// MaxChunkChars is the most text in a chunk, in bytes, so chunks fit
+	// the input limit of embedding models. Windows end early to stay
+	// below it and longer lines are cut to it, so a minified file does
+	// not become one huge chunk.
+	MaxChunkChars = 6000
+)
+
+// Chunk is a piece of a source file embedded as one vector.
+type Chunk struct {
+	StartLine int    // First line, 1-based
+	EndLine   int    // Last line, inclusive
+	Symbol    string // Declaration name for Go chunks, e.g. "Agent.Query"
+	Text      string
+}
+
+// ChunkFile splits a file into chunks. Go files are split by top-level
+// declaration, including doc comments; package clauses and imports are
+// left out. Other files, and Go files that do not parse, are split into
+// overlapping windows of at most WindowLines lines and MaxChunkChars bytes.
+// Chunks without any non-blank text are dropped.
+func ChunkFile(rel string, content []byte) []Chunk {
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for i, line := range lines {
+		lines[i] = clip(line)
+	}
+
+	if path.Ext(rel) == ".go" {
+		if chunks, ok := chunkGo(rel, content, lines); ok {
+			return chunks
+		}
+	}
+	return windows(lines, 1, len(lines), "")
+}
+
+// chunkGo chunks a Go file by declaration.
+func chunkGo(rel string, content []byte, lines []string) ([]Chunk, bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, rel, content, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, false
+	}
+
+	var chunks []Chunk
+	for _, decl := range file.Decls {
+		name, doc := declInfo(decl)
+		if name == "" {
+			continue // Imports
+		}
+		start := fset.Position(decl.Pos()).Line
+		if doc != nil {
+			start = fset.Position(doc.Pos()).Line
+		}
+		end := fset.Position(decl.End()).Line
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		if end-start+1 > MaxDeclLines || textSize(lines, start, end) > MaxChunkChars {
+			chunks = append(chunks, windows(lines, start, end, name)...)
+			continue
+		}
+		if chunk, ok := makeChunk(lines, start, end, name); ok {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks, true
+}
+
+// declInfo returns the name and doc comment of a declaration. Methods are
+// named Type.Method; grouped declarations are named after their first spec.
+// Imports yield no name.
+func declInfo(decl ast.Decl) (string, *ast.CommentGroup) {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		name := d.Name.Name
+		if d.Recv != nil && len(d.Recv.List) > 0 {
+			if recv := receiverName(d.Recv.List[0].Type); recv != "" {
+				name = recv + "." + name
+			}
+		}
+		return name, d.Doc
+	case *ast.GenDecl:
+		if d.Tok == token.IMPORT || len(d.Specs) == 0 {
+			return "", nil
+		}
+		switch s := d.Specs[0].(type) {
+		case *ast.TypeSpec:
+			return s.Name.Name, d.Doc
+		case *ast.ValueSpec:
+			if len(s.Names) > 0 {
+				return s.Names[0].Name, d.Doc
+			}
+		}
+	}
+	return "", nil
+}
+
+// receiverName returns the type name of a method receiver.
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// windows splits lines start..end (1-based, inclusive) into overlapping
+// windows of at most WindowLines lines and MaxChunkChars bytes. Windows cut
+// short by the size limit overlap proportionally less.
+func windows(lines []string, start, end int, symbol string) []Chunk {
+	var chunks []Chunk
+	for from := start; from <= end; {
+		to, size := from, len(lines[from-1])
+		for to < end && to-from+1 < WindowLines && size+1+len(lines[to]) <= MaxChunkChars {
+			size += 1 + len(lines[to])
+			to++
+		}
+		if chunk, ok := makeChunk(lines, from, to, symbol); ok {
+			chunks = append(chunks, chunk)
+		}
+		if to == end {
+			break
+		}
+		overlap := min(WindowOverlap, (to-from+1)/6)
+		from = to + 1 - overlap
+	}
+	return chunks
+}
+
+// textSize returns the length of lines start..end joined by newlines.
+func textSize(lines []string, start, end int) int {
+	size := end - start
+	for _, line := range lines[start-1 : end] {
+		size += len(line)
+	}
+	return size
+}
+
+// clip cuts a line to MaxChunkChars bytes without splitting a character.
+func clip(line string) string {
+	if len(line) <= MaxChunkChars {
+		return line
+	}
+	cut := MaxChunkChars
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return line[:cut]
+}
+
+// makeChunk builds a chunk of lines start..end unless they are all blank.
+func makeChunk(lines []string, start, end int, symbol string) (Chunk, bool) {
+	text := strings.Join(lines[start-1:end], "\n")
+	if strings.TrimSpace(text) == "" {
+		return Chunk{}, false
+	}
+	return Chunk{StartLine: start, EndLine: end, Symbol: symbol, Text: text}, true
+}
@@ -0,0 +1,517 @@
+// Package semantic implements semantic code search: files are split into
+// chunks (Go declarations or fixed line windows), each chunk is embedded
+// with an llm.Embedder, and queries are answered by cosine similarity
+// against a local vector store.
+//
+// The store is a single file, normally .goai/index/semantic.gob. It is
+// brought up to date incrementally: files whose size and modification time
+// are unchanged are skipped, and within a changed file only chunks whose
+// text changed are embedded again.
+package semantic
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zerofisher/goai/pkg/ignore"
+	"github.com/Zerofisher/goai/pkg/llm"
+)
+
+const (
+	// storeVersion changes whenever the store format or chunking does.
+	storeVersion = 2
+	// maxFileSize is the largest file indexed.
+	maxFileSize = 512 * 1024
+	// embedBatch is the number of chunks embedded per call, which bounds
+	// the work lost when an update is interrupted.
+	embedBatch = 256
+)
+
+// sourceExts are the extensions of the files indexed.
+var sourceExts = map[string]bool{
+	".go": true, ".py": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true,
+	".java": true, ".kt": true, ".cs": true, ".c": true, ".h": true, ".cpp": true,
+	".cc": true, ".hpp": true, ".rs": true, ".rb": true, ".php": true, ".swift": true,
+	".scala": true, ".sh": true, ".sql": true, ".proto": true, ".md": true,
+}
+
+// storedChunk is one embedded chunk in the store.
+type storedChunk struct {
+	StartLine int
+	EndLine   int
+	Symbol    string
+	Hash      string // Hash of the embedded text, to reuse the vector
+	Vector    []float32
+}
+
+// fileRecord is the stored state of one file.
+type fileRecord struct {
+	ModTime time.Time
+	Size    int64
+	Hash    string
+	Chunks  []storedChunk
+	Skipped bool // The embedder failed on the file; retried once it changes
+}
+
+// storeFile is the on-disk vector store.
+type storeFile struct {
+	Version int
+	Model   string
+	Files   map[string]*fileRecord
+}
+
+// Match is a chunk found by a search.
+type Match struct {
+	Path      string
+	StartLine int
+	EndLine   int
+	Symbol    string
+	Score     float64 // Cosine similarity to the query
+	Text      string
+}
+
+// Stats describes what an index update did.
+type Stats struct {
+	Files    int // Files in the index
+	Chunks   int // Chunks in the index
+	Embedded int // Chunks embedded by this update
+	Removed  int // Files dropped because they are gone or now ignored
+	Skipped  int // Files in the index without chunks because embedding failed
+}
+
+// Index is a semantic index of one work directory. It is safe for
+// concurrent use.
+type Index struct {
+	root     string
+	path     string
+	embedder llm.Embedder
+	opts     ignore.WalkOptions
+
+	mu     sync.Mutex
+	loaded bool
+	files  map[string]*fileRecord
+}
+
+// New creates an index of root stored at storePath, embedding with
+// embedder. Nothing is read until the first update.
+func New(root, storePath string, embedder llm.Embedder) *Index {
+	return &Index{
+		root:     root,
+		path:     storePath,
+		embedder: embedder,
+		files:    make(map[string]*fileRecord),
+	}
+}
+
+// SetExcludePatterns sets extra gitignore-style patterns to exclude.
+func (x *Index) SetExcludePatterns(patterns []string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.opts.Excludes = patterns
+}
+
+// SetIncludeHidden controls whether dot files and directories are indexed.
+func (x *Index) SetIncludeHidden(include bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.opts.IncludeHidden = include
+}
+
+// Update brings the index up to date with the work directory and saves it
+// when anything changed.
+func (x *Index) Update(ctx context.Context) (Stats, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.update(ctx)
+}
+
+// Search updates the index and returns the k chunks most similar to query,
+// best first. A non-empty dir restricts the search to files below it.
+func (x *Index) Search(ctx context.Context, query string, k int, dir string) ([]Match, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("query must not be empty")
+	}
+	dir = strings.Trim(filepath.ToSlash(filepath.Clean(dir)), "/")
+	if dir == "." {
+		dir = ""
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if _, err := x.update(ctx); err != nil {
+		return nil, err
+	}
+
+	vectors, err := x.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("embedder returned %d vectors for the query", len(vectors))
+	}
+	q := vectors[0]
+
+	var matches []Match
+	for rel, file := range x.files {
+		if dir != "" && rel != dir && !strings.HasPrefix(rel, dir+"/") {
+			continue
+		}
+		for _, c := range file.Chunks {
+			matches = append(matches, Match{
+				Path:      rel,
+				StartLine: c.StartLine,
+				EndLine:   c.EndLine,
+				Symbol:    c.Symbol,
+				Score:     cosine(q, c.Vector),
+			})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if matches[i].Path != matches[j].Path {
+			return matches[i].Path < matches[j].Path
+		}
+		return matches[i].StartLine < matches[j].StartLine
+	})
+	if k > 0 && len(matches) > k {
+		matches = matches[:k]
+	}
+
+	for i := range matches {
+		matches[i].Text = x.readLines(matches[i].Path, matches[i].StartLine, matches[i].EndLine)
+	}
+	return matches, nil
+}
+
+// readLines returns lines start..end of a file.
+func (x *Index) readLines(rel string, start, end int) string {
+	data, err := os.ReadFile(filepath.Join(x.root, filepath.FromSlash(rel)))
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if start < 1 || start > len(lines) {
+		return ""
+	}
+	return strings.Join(lines[start-1:min(end, len(lines))], "\n")
+}
+
+// pending is a changed file waiting for its chunks to be embedded.
+type pending struct {
+	rel    string
+	record *fileRecord
+	texts  []string // Texts to embed, indexed like record.Chunks; "" when reused
+}
+
+// update walks the work directory, re-chunks changed files, embeds the
+// chunks whose text is new and drops files that are gone.
+func (x *Index) update(ctx context.Context) (Stats, error) {
+	if !x.loaded {
+		x.load()
+		x.loaded = true
+	}
+
+	type candidate struct {
+		rel  string
+		info fs.FileInfo
+	}
+	var changed []candidate
+	seen := make(map[string]bool)
+	err := ignore.Walk(ctx, x.root, x.opts, func(rel string, d fs.DirEntry) error {
+		if !d.Type().IsRegular() || !sourceExts[strings.ToLower(path.Ext(rel))] {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > maxFileSize {
+			return nil
+		}
+		seen[rel] = true
+		if f, ok := x.files[rel]; ok && f.Size == info.Size() && f.ModTime.Equal(info.ModTime()) {
+			return nil
+		}
+		changed = append(changed, candidate{rel: rel, info: info})
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return Stats{}, fmt.Errorf("indexing canceled: %w", ctx.Err())
+		}
+		return Stats{}, fmt.Errorf("failed to walk %s: %w", x.root, err)
+	}
+
+	var stats Stats
+	dirty := false
+	for rel := range x.files {
+		if !seen[rel] {
+			delete(x.files, rel)
+			stats.Removed++
+			dirty = true
+		}
+	}
+
+	var work []pending
+	for _, c := range changed {
+		data, err := os.ReadFile(filepath.Join(x.root, filepath.FromSlash(c.rel)))
+		if err != nil || bytes.IndexByte(data, 0) >= 0 {
+			if _, ok := x.files[c.rel]; ok {
+				delete(x.files, c.rel)
+				stats.Removed++
+				dirty = true
+			}
+			continue
+		}
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+
+		old := x.files[c.rel]
+		if old != nil && old.Hash == hash {
+			old.ModTime, old.Size = c.info.ModTime(), c.info.Size()
+			dirty = true
+			continue
+		}
+		work = append(work, x.prepare(c.rel, data, hash, c.info, old))
+	}
+
+	embedded, err := x.embed(ctx, work)
+	stats.Embedded = embedded
+	if embedded > 0 || len(work) > 0 {
+		dirty = true
+	}
+	if dirty {
+		if saveErr := x.save(); saveErr != nil && err == nil {
+			err = fmt.Errorf("failed to save semantic index: %w", saveErr)
+		}
+	}
+	if err != nil {
+		return stats, err
+	}
+
+	for _, f := range x.files {
+		if f.Skipped {
+			stats.Skipped++
+			continue
+		}
+		stats.Files++
+		stats.Chunks += len(f.Chunks)
+	}
+	return stats, nil
+}
+
+// prepare chunks a changed file, reusing the vectors of chunks whose text
+// is unchanged.
+func (x *Index) prepare(rel string, data []byte, hash string, info fs.FileInfo, old *fileRecord) pending {
+	reuse := make(map[string][]float32)
+	if old != nil {
+		for _, c := range old.Chunks {
+			reuse[c.Hash] = c.Vector
+		}
+	}
+
+	p := pending{rel: rel, record: &fileRecord{ModTime: info.ModTime(), Size: info.Size(), Hash: hash}}
+	for _, chunk := range ChunkFile(rel, data) {
+		text := embedText(rel, chunk)
+		sum := sha256.Sum256([]byte(text))
+		c := storedChunk{
+			StartLine: chunk.StartLine,
+			EndLine:   chunk.EndLine,
+			Symbol:    chunk.Symbol,
+			Hash:      hex.EncodeToString(sum[:]),
+		}
+		if vec, ok := reuse[c.Hash]; ok {
+			c.Vector = vec
+			text = ""
+		}
+		p.record.Chunks = append(p.record.Chunks, c)
+		p.texts = append(p.texts, text)
+	}
+	return p
+}
+
+// embed embeds the new chunks of the pending files in batches. A file is
+// stored once all its chunks have vectors, so an error leaves the files
+// not yet complete at their previous state.
+//
+// When a batch fails, its files are embedded one by one, and those that
+// still fail are stored as skipped so one file the embedder rejects does
+// not stop every update. If every file of the batch fails, the embedder
+// itself is failing and its error is returned.
+func (x *Index) embed(ctx context.Context, work []pending) (int, error) {
+	var refs []chunkRef
+	var texts []string
+	remaining := make([]int, len(work))
+	skipped := make([]bool, len(work))
+	for i, p := range work {
+		for j, text := range p.texts {
+			if text != "" {
+				refs = append(refs, chunkRef{i, j})
+				texts = append(texts, text)
+				remaining[i]++
+			}
+		}
+		if remaining[i] == 0 {
+			x.files[p.rel] = p.record
+		}
+	}
+
+	embedded := 0
+	for start := 0; start < len(texts); start += embedBatch {
+		if err := ctx.Err(); err != nil {
+			return embedded, fmt.Errorf("indexing canceled: %w", err)
+		}
+		end := min(start+embedBatch, len(texts))
+		vectors, err := x.embedVectors(ctx, texts[start:end])
+		if err != nil {
+			if ctx.Err() != nil {
+				return embedded, fmt.Errorf("indexing canceled: %w", ctx.Err())
+			}
+			if vectors, err = x.embedEach(ctx, refs[start:end], texts[start:end]); err != nil {
+				return embedded, err
+			}
+		}
+		for i, vec := range vectors {
+			r := refs[start+i]
+			if skipped[r.file] {
+				continue
+			}
+			if vec == nil {
+				skipped[r.file] = true
+				rec := work[r.file].record
+				x.files[work[r.file].rel] = &fileRecord{ModTime: rec.ModTime, Size: rec.Size, Hash: rec.Hash, Skipped: true}
+				continue
+			}
+			work[r.file].record.Chunks[r.chunk].Vector = vec
+			remaining[r.file]--
+			if remaining[r.file] == 0 {
+				x.files[work[r.file].rel] = work[r.file].record
+			}
+			embedded++
+		}
+	}
+	return embedded, nil
+}
+
+// embedVectors embeds texts and checks that there is a vector for each.
+func (x *Index) embedVectors(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors, err := x.embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed chunks: %w", err)
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d chunks", len(vectors), len(texts))
+	}
+	return vectors, nil
+}
+
+// chunkRef locates a chunk to embed within the pending files.
+type chunkRef struct{ file, chunk int }
+
+// embedEach embeds the texts of a failed batch file by file. The vectors
+// of files that fail again are left nil. It returns an error when no file
+// could be embedded.
+func (x *Index) embedEach(ctx context.Context, refs []chunkRef, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	var firstErr error
+	ok := 0
+	for from := 0; from < len(refs); {
+		to := from
+		for to < len(refs) && refs[to].file == refs[from].file {
+			to++
+		}
+		fileVectors, err := x.embedVectors(ctx, texts[from:to])
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("indexing canceled: %w", ctx.Err())
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else {
+			copy(vectors[from:to], fileVectors)
+			ok++
+		}
+		from = to
+	}
+	if ok == 0 {
+		return nil, firstErr
+	}
+	return vectors, nil
+}
+
+// embedText is the text embedded for a chunk. The path and symbol give the
+// embedder context the code alone may lack.
+func embedText(rel string, chunk Chunk) string {
+	header := rel
+	if chunk.Symbol != "" {
+		header += " " + chunk.Symbol
+	}
+	return header + "\n" + chunk.Text
+}
+
+// cosine returns the cosine similarity of two vectors, or 0 when their
+// sizes differ or either is zero.
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// load reads the store. A missing store, an outdated format or vectors of
+// a different model are ignored, so everything is embedded again.
+func (x *Index) load() {
+	f, err := os.Open(x.path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = f.Close() // Read-only, close errors are irrelevant
+	}()
+
+	var stored storeFile
+	if gob.NewDecoder(f).Decode(&stored) != nil || stored.Version != storeVersion ||
+		stored.Model != x.embedder.Model() || stored.Files == nil {
+		return
+	}
+	x.files = stored.Files
+}
+
+// save writes the store atomically.
+func (x *Index) save() error {
+	if err := os.MkdirAll(filepath.Dir(x.path), 0755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(storeFile{Version: storeVersion, Model: x.embedder.Model(), Files: x.files})
+	if err != nil {
+		return err
+	}
+	tmp := x.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, x.path)
+}
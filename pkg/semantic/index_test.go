@@ -0,0 +1,268 @@
+package semantic
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Zerofisher/goai/pkg/llm"
+)
+
+// countingEmbedder wraps the deterministic hash embedder and counts the
+// texts it embeds.
+type countingEmbedder struct {
+	*llm.HashEmbedder
+	texts int
+}
+
+func (e *countingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.texts += len(texts)
+	return e.HashEmbedder.Embed(ctx, texts)
+}
+
+// limitedEmbedder fails like an embedding API on requests with a text
+// over its input limit or containing a rejected word.
+type limitedEmbedder struct {
+	*llm.HashEmbedder
+	maxChars int
+	reject   string
+}
+
+func (e *limitedEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	for _, text := range texts {
+		if len(text) > e.maxChars || (e.reject != "" && strings.Contains(text, e.reject)) {
+			return nil, errors.New("input rejected")
+		}
+	}
+	return e.HashEmbedder.Embed(ctx, texts)
+}
+
+// TestChunkFile tests chunking by Go declaration and by window
+func TestChunkFile(t *testing.T) {
+	goSrc := `package auth
+
+import "time"
+
+// Token is an access token.
+type Token struct {
+	Expires time.Time
+}
+
+// Refresh renews the token.
+func (t *Token) Refresh() error {
+	return nil
+}
+
+const (
+	DefaultTTL = time.Hour
+	MaxTTL     = 24 * time.Hour
+)
+`
+	type span struct {
+		Start, End int
+		Symbol     string
+	}
+	spans := func(chunks []Chunk) []span {
+		var out []span
+		for _, c := range chunks {
+			out = append(out, span{c.StartLine, c.EndLine, c.Symbol})
+		}
+		return out
+	}
+
+	got := spans(ChunkFile("auth/token.go", []byte(goSrc)))
+	want := []span{{5, 8, "Token"}, {10, 13, "Token.Refresh"}, {15, 18, "DefaultTTL"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Go chunks = %v, want %v", got, want)
+	}
+
+	var text strings.Builder
+	for i := 0; i < 130; i++ {
+		text.WriteString("line\n")
+	}
+	got = spans(ChunkFile("notes.md", []byte(text.String())))
+	want = []span{{1, 60, ""}, {51, 110, ""}, {101, 130, ""}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("window chunks = %v, want %v", got, want)
+	}
+
+	// Go that does not parse falls back to windows
+	got = spans(ChunkFile("broken.go", []byte("package x\nfunc {\n")))
+	if !reflect.DeepEqual(got, []span{{1, 2, ""}}) {
+		t.Errorf("fallback chunks = %v", got)
+	}
+
+	// A single 200 KB line is cut to the chunk size limit
+	chunks := ChunkFile("bundle.min.js", []byte(strings.Repeat("é", 100*1024)+"\n"))
+	if len(chunks) != 1 || len(chunks[0].Text) > MaxChunkChars || !utf8.ValidString(chunks[0].Text) {
+		t.Errorf("expected one valid chunk of at most %d bytes, got %d chunks", MaxChunkChars, len(chunks))
+	}
+
+	// Long lines end windows early, and no chunk exceeds the limit
+	text.Reset()
+	for i := 0; i < 20; i++ {
+		text.WriteString(strings.Repeat("x", 1000) + "\n")
+	}
+	chunks = ChunkFile("data.csv", []byte(text.String()))
+	if len(chunks) < 4 || chunks[len(chunks)-1].EndLine != 20 {
+		t.Errorf("expected size-limited windows covering all 20 lines, got %v", spans(chunks))
+	}
+	for _, c := range chunks {
+		if len(c.Text) > MaxChunkChars {
+			t.Errorf("chunk %d-%d has %d bytes", c.StartLine, c.EndLine, len(c.Text))
+		}
+	}
+}
+
+// TestIndex tests search, incremental updates and persistence
+func TestIndex(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("auth/token.go", "package auth\n\n// RefreshToken renews an expired auth token.\nfunc RefreshToken() {}\n\n// Logout ends the session.\nfunc Logout() {}\n")
+	write("render/table.go", "package render\n\n// Table renders rows as a markdown table.\nfunc Table() {}\n")
+	write("data.bin", "token\x00refresh")
+	write(".gitignore", "vendor/\n")
+	write("vendor/lib.go", "package lib\n\n// RefreshToken is vendored.\nfunc RefreshToken() {}\n")
+
+	storePath := filepath.Join(dir, ".goai", "index", "semantic.gob")
+	embedder := &countingEmbedder{HashEmbedder: llm.NewHashEmbedder(256)}
+	index := New(dir, storePath, embedder)
+	ctx := context.Background()
+
+	stats, err := index.Update(ctx)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if want := (Stats{Files: 2, Chunks: 3, Embedded: 3}); stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	matches, err := index.Search(ctx, "where do we refresh expired auth tokens", 2, "")
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(matches) != 2 || matches[0].Symbol != "RefreshToken" || matches[0].Path != "auth/token.go" {
+		t.Fatalf("expected RefreshToken first, got %+v", matches)
+	}
+	if matches[0].StartLine != 3 || !strings.Contains(matches[0].Text, "func RefreshToken() {}") {
+		t.Errorf("unexpected match: %+v", matches[0])
+	}
+
+	matches, err = index.Search(ctx, "refresh token", 5, "render")
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Path != "render/table.go" {
+		t.Errorf("expected only render/table.go below render, got %+v", matches)
+	}
+
+	t.Run("Incremental", func(t *testing.T) {
+		// One declaration changes, one is added; the rest reuse vectors
+		write("auth/token.go", "package auth\n\n// RefreshToken renews an expired auth token.\nfunc RefreshToken() {}\n\n// Logout ends the session and clears cookies.\nfunc Logout() {}\n\n// Login starts a session.\nfunc Login() {}\n")
+		embedder.texts = 0
+		stats, err := index.Update(ctx)
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if stats.Embedded != 2 || embedder.texts != 2 || stats.Chunks != 4 {
+			t.Errorf("stats = %+v after embedding %d texts, want 2 embedded of 4 chunks", stats, embedder.texts)
+		}
+
+		if err := os.Remove(filepath.Join(dir, "render", "table.go")); err != nil {
+			t.Fatal(err)
+		}
+		stats, err = index.Update(ctx)
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if want := (Stats{Files: 1, Chunks: 3, Removed: 1}); stats != want {
+			t.Errorf("stats = %+v, want %+v", stats, want)
+		}
+	})
+
+	t.Run("Persistence", func(t *testing.T) {
+		reloaded := New(dir, storePath, &countingEmbedder{HashEmbedder: llm.NewHashEmbedder(256)})
+		stats, err := reloaded.Update(ctx)
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if want := (Stats{Files: 1, Chunks: 3}); stats != want {
+			t.Errorf("stats = %+v, want the stored index unchanged", stats)
+		}
+
+		// Vectors of another model are not reused
+		other := New(dir, storePath, &countingEmbedder{HashEmbedder: llm.NewHashEmbedder(64)})
+		stats, err = other.Update(ctx)
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if stats.Embedded != 3 {
+			t.Errorf("expected a different model to re-embed everything, got %+v", stats)
+		}
+	})
+}
+
+// TestIndexEmbedFailures tests that files the embedder rejects are skipped
+func TestIndexEmbedFailures(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"auth/token.go":    "package auth\n\n// RefreshToken renews an expired auth token.\nfunc RefreshToken() {}\n",
+		"secret/poison.go": "package secret\n\n// Poison is rejected by the embedder.\nfunc Poison() {}\n",
+		"web/bundle.js":    strings.Repeat("a", 200*1024) + "\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	storePath := filepath.Join(dir, ".goai", "index", "semantic.gob")
+	embedder := &limitedEmbedder{HashEmbedder: llm.NewHashEmbedder(256), maxChars: 8000, reject: "Poison"}
+	index := New(dir, storePath, embedder)
+	stats, err := index.Update(ctx)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if want := (Stats{Files: 2, Chunks: 2, Embedded: 2, Skipped: 1}); stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	matches, err := index.Search(ctx, "refresh expired auth token", 1, "")
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Path != "auth/token.go" {
+		t.Errorf("expected auth/token.go, got %+v", matches)
+	}
+
+	// The skipped file is not retried until it changes
+	stats, err = index.Update(ctx)
+	if err != nil || stats.Embedded != 0 || stats.Skipped != 1 {
+		t.Errorf("stats = %+v, err = %v, want the skipped file left alone", stats, err)
+	}
+
+	// An embedder that fails on everything fails the update
+	broken := New(dir, filepath.Join(t.TempDir(), "semantic.gob"), &limitedEmbedder{HashEmbedder: llm.NewHashEmbedder(256)})
+	if _, err := broken.Update(ctx); err == nil {
+		t.Error("expected an error when no file can be embedded")
+	}
+}
@@ -0,0 +1,158 @@
+package shadow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Commands are the subcommands RunCommand handles.
+var Commands = []string{"log", "diff", "checkout", "cherry-pick"}
+
+// maxDiffBytes bounds the patch printed by the diff command.
+const maxDiffBytes = 1 << 20
+
+// IsCommand reports whether name is one of Commands.
+func IsCommand(name string) bool {
+	for _, c := range Commands {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RunCommand runs one of the history commands and returns its output:
+//
+//	log [session]                     list the turns of a session (the latest by default)
+//	diff <turn>                       show the changes of a turn
+//	checkout <turn> [paths...]        put the files of a turn back in the work tree
+//	cherry-pick <turn>                commit the changes of a turn on the current branch
+//
+// A turn is "n" for turn n of the latest session or "session:n".
+func RunCommand(ctx context.Context, h *History, args []string) (string, error) {
+	if len(args) == 0 || !IsCommand(args[0]) {
+		return "", fmt.Errorf("unknown command; use one of %s", strings.Join(Commands, ", "))
+	}
+	name, args := args[0], args[1:]
+
+	if name == "log" {
+		session := ""
+		if len(args) > 0 {
+			session = args[0]
+		}
+		return listTurns(ctx, h, session)
+	}
+
+	if len(args) == 0 {
+		return "", fmt.Errorf("usage: goai %s <turn>; use goai log to list turns", name)
+	}
+	entry, err := findTurn(ctx, h, args[0])
+	if err != nil {
+		return "", err
+	}
+
+	switch name {
+	case "diff":
+		diff, err := h.Show(ctx, entry, maxDiffBytes)
+		if err != nil {
+			return "", err
+		}
+		out := fmt.Sprintf("%s:%d %s %s (%s)\n\n%s", entry.Session, entry.Turn, entry.ShortHash(),
+			entry.Subject, fileCounts(len(diff.Files), diff.Added, diff.Removed), diff.Patch)
+		if diff.Truncated {
+			out += "\n[diff truncated]"
+		}
+		return strings.TrimRight(out, "\n"), nil
+
+	case "checkout":
+		paths, err := h.Checkout(ctx, entry, args[1:]...)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Restored %d %s to turn %s:%d: %s", len(paths), plural(len(paths), "file", "files"),
+			entry.Session, entry.Turn, strings.Join(paths, ", ")), nil
+
+	case "cherry-pick":
+		commit, err := h.CherryPick(ctx, entry)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Committed turn %s:%d as %s: %s", entry.Session, entry.Turn, commit.ShortHash, commit.Subject), nil
+	}
+	return "", nil
+}
+
+// listTurns formats the turns of session, or of the latest session.
+func listTurns(ctx context.Context, h *History, session string) (string, error) {
+	sessions, err := h.Sessions(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(sessions) == 0 {
+		return "No recorded turns yet.", nil
+	}
+	if session == "" {
+		session = sessions[0].ID
+	}
+	turns, err := h.Turns(ctx, session)
+	if err != nil {
+		return "", err
+	}
+
+	lines := []string{fmt.Sprintf("Session %s (%s), newest first:", session, Ref(session))}
+	for _, e := range turns {
+		line := fmt.Sprintf("  #%d %s %s %s", e.Turn, e.ShortHash(), e.Date.Local().Format("2006-01-02 15:04"), e.Subject)
+		if e.Diff != nil {
+			line += fmt.Sprintf(" (%s)", fileCounts(len(e.Diff.Files), e.Diff.Added, e.Diff.Removed))
+		}
+		lines = append(lines, line)
+	}
+
+	var others []string
+	for _, s := range sessions {
+		if s.ID != session {
+			others = append(others, s.ID)
+		}
+	}
+	if len(others) > 0 {
+		lines = append(lines, "Other sessions: "+strings.Join(others, ", "))
+	}
+	lines = append(lines, "Use goai diff, checkout or cherry-pick with a turn: n in the latest session, or session:n.")
+	return strings.Join(lines, "\n"), nil
+}
+
+// findTurn resolves "n" or "session:n".
+func findTurn(ctx context.Context, h *History, ref string) (*Entry, error) {
+	session, number, found := strings.Cut(strings.TrimPrefix(ref, "#"), ":")
+	if !found {
+		number, session = session, ""
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(number, "#"))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("invalid turn %q; use n or session:n", ref)
+	}
+	if session == "" {
+		if session, err = h.Latest(ctx); err != nil {
+			if errors.Is(err, ErrNoHistory) {
+				return nil, fmt.Errorf("no recorded turns yet")
+			}
+			return nil, err
+		}
+	}
+	return h.Find(ctx, session, n)
+}
+
+// fileCounts formats a file count with line counts.
+func fileCounts(files, added, removed int) string {
+	return fmt.Sprintf("%d %s, +%d -%d", files, plural(files, "file", "files"), added, removed)
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
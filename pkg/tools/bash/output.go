@@ -4,6 +4,7 @@ import (
 	"regexp"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // OutputProcessor processes command output for better readability.
@@ -55,6 +56,41 @@ func (p *OutputProcessor) ProcessOutput(output string, maxLines int) string {
 	return strings.Join(processedLines, "\n")
 }
 
+// ProcessStream processes a single output stream (stdout or stderr) and
+// reports whether any part of it was dropped by the line, line-length or
+// character limits.
+func (p *OutputProcessor) ProcessStream(output string, maxLines, maxChars int) (string, bool) {
+	if maxLines <= 0 {
+		maxLines = p.maxLines
+	}
+
+	truncated := false
+	lines := strings.Split(output, "\n")
+	if len(lines) > maxLines {
+		truncated = true
+	} else {
+		for _, line := range lines {
+			if len(line) > p.maxLineLength {
+				truncated = true
+				break
+			}
+		}
+	}
+
+	processed := p.ProcessOutput(output, maxLines)
+
+	if maxChars > 0 && len(processed) > maxChars {
+		cut := maxChars
+		for cut > 0 && !utf8.RuneStart(processed[cut]) {
+			cut--
+		}
+		processed = processed[:cut] + "\n... [output truncated]"
+		truncated = true
+	}
+
+	return processed, truncated
+}
+
 // RemoveANSICodes removes ANSI escape codes from the output.
 func (p *OutputProcessor) RemoveANSICodes(output string) string {
 	return p.ansiRegex.ReplaceAllString(output, "")
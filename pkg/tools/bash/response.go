@@ -0,0 +1,63 @@
+package bash
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ToolResponse represents the standardized JSON response format for the bash tool.
+// It mirrors the file and edit tools: {"ok":true,"summary":"...","data":{...}}
+type ToolResponse struct {
+	Ok      bool         `json:"ok"`
+	Summary string       `json:"summary"`
+	Data    *CommandData `json:"data,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// CommandData contains the structured result of a command execution.
+// Stdout and stderr are kept separate so a failing command's output reaches
+// the model intact together with its exit code.
+type CommandData struct {
+	Command         string `json:"command"`
+	ExitCode        int    `json:"exit_code"`
+	Stdout          string `json:"stdout"`
+	Stderr          string `json:"stderr"`
+	DurationMs      int64  `json:"duration_ms"`
+	TimedOut        bool   `json:"timed_out,omitempty"`
+	StdoutTruncated bool   `json:"stdout_truncated,omitempty"`
+	StderrTruncated bool   `json:"stderr_truncated,omitempty"`
+}
+
+// Success creates a successful response with data.
+func Success(summary string, data *CommandData) string {
+	resp := ToolResponse{
+		Ok:      true,
+		Summary: summary,
+		Data:    data,
+	}
+	return marshalResponse(resp)
+}
+
+// Failure creates a response for a command that ran but did not succeed
+// (non-zero exit or timeout). The command data is still included.
+func Failure(summary string, data *CommandData, err error) string {
+	resp := ToolResponse{
+		Ok:      false,
+		Summary: summary,
+		Data:    data,
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return marshalResponse(resp)
+}
+
+// marshalResponse converts the response to JSON string.
+func marshalResponse(resp ToolResponse) string {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		// Fallback to plain text error if JSON marshaling fails
+		return fmt.Sprintf(`{"ok":false,"summary":"JSON marshaling error","error":"%s"}`, err.Error())
+	}
+	return string(data)
+}
@@ -3,36 +3,49 @@ package bash
 import (
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
+
+	"github.com/Zerofisher/goai/pkg/git"
+	"github.com/Zerofisher/goai/pkg/shell"
 )
 
 // Validator validates bash commands for security.
+//
+// Commands are parsed into a shell syntax tree (see pkg/shell) and every
+// simple command, wherever it appears (pipelines, subshells, substitutions,
+// here-documents, nested "bash -c" scripts, find -exec), is checked against
+// the policy by its resolved name and arguments. The forbidden patterns are
+// matched against the raw text of every script as a fallback.
 type Validator struct {
-	forbiddenCommands  []string
-	forbiddenPatterns  []*regexp.Regexp
-	dangerousFlags     map[string][]string
-	allowedCommands    map[string]bool
-	maxCommandLength   int
+	forbiddenCommands []string
+	forbiddenPatterns []*regexp.Regexp
+	maxCommandLength  int
+	maxCommands       int
+
+	allowDestructiveGit bool   // permit git commands that can lose work
+	workDir             string // where commands run, for git checkout paths
+
+	checks []func(shell.Command) error // extra rules for every command
 }
 
 // NewValidator creates a new command validator.
 func NewValidator() *Validator {
 	v := &Validator{
 		maxCommandLength: 10000, // Default max command length
-		allowedCommands:  make(map[string]bool),
-		dangerousFlags:   make(map[string][]string),
+		maxCommands:      64,    // Default max simple commands per script
 	}
 
-	// Set default forbidden commands
+	// Set default forbidden commands. Each entry is matched against every
+	// simple command: the first word is the command name, the remaining
+	// words must appear in order among its arguments.
 	v.forbiddenCommands = []string{
 		"rm -rf /",
-		"rm -rf /*",
 		"dd if=/dev/zero",
 		"mkfs",
 		"format",
-		":(){:|:&};:",     // Fork bomb
-		"chmod -R 777 /",
-		"chown -R",
+		"fdisk",
+		"wipefs",
 		"shutdown",
 		"reboot",
 		"halt",
@@ -41,40 +54,55 @@ func NewValidator() *Validator {
 		"init 6",
 		"systemctl poweroff",
 		"systemctl reboot",
+		"systemctl halt",
+		"eval",
 	}
 
-	// Set dangerous command patterns
+	// Dangerous command patterns, kept as a safety net for whatever the
+	// per-command rules miss
 	v.forbiddenPatterns = []*regexp.Regexp{
-		regexp.MustCompile(`rm\s+(-rf|-fr)\s+/`),              // Dangerous rm
-		regexp.MustCompile(`>\s*/dev/sd[a-z]`),                // Direct disk write
-		regexp.MustCompile(`dd\s+.*of=/dev/[^/]+$`),           // dd to device
-		regexp.MustCompile(`/etc/passwd`),                     // Password file access
-		regexp.MustCompile(`/etc/shadow`),                     // Shadow file access
-		regexp.MustCompile(`curl.*\|\s*sh`),                   // Curl pipe to shell
-		regexp.MustCompile(`wget.*\|\s*sh`),                   // Wget pipe to shell
-		regexp.MustCompile(`\$\(.*\)`),                        // Command substitution
-		regexp.MustCompile("`.+`"),                            // Backtick command substitution
-		regexp.MustCompile(`nc\s+-l`),                         // Netcat listener
-		regexp.MustCompile(`/dev/tcp/`),                       // Network redirection
-		regexp.MustCompile(`iptables\s+-F`),                   // Firewall flush
-		regexp.MustCompile(`base64\s+-d.*\|\s*sh`),            // Base64 decode to shell
-		regexp.MustCompile(`eval\s+`),                         // Eval command
-		regexp.MustCompile(`exec\s+[^>]+$`),                   // Exec replacement
-	}
-
-	// Set dangerous flags for common commands
-	v.dangerousFlags = map[string][]string{
-		"rm":     {"-rf", "-fr", "--no-preserve-root"},
-		"chmod":  {"-R 777", "777 -R", "a+rwx"},
-		"chown":  {"-R root", "root:root -R"},
-		"kill":   {"-9 1", "-KILL 1", "-TERM 1"},
-		"pkill":  {"-9", "-KILL"},
-		"killall": {"-9", "-KILL"},
+		regexp.MustCompile(`rm\s+(-rf|-fr)\s+/(\s|$|\*)`), // Dangerous rm
+		regexp.MustCompile(`>\s*/dev/sd[a-z]`),            // Direct disk write
+		regexp.MustCompile(`dd\s+.*of=/dev/[^/]+$`),       // dd to device
+		regexp.MustCompile(`/etc/passwd`),                 // Password file access
+		regexp.MustCompile(`/etc/shadow`),                 // Shadow file access
+		regexp.MustCompile(`curl.*\|\s*(ba)?sh`),          // Curl pipe to shell
+		regexp.MustCompile(`wget.*\|\s*(ba)?sh`),          // Wget pipe to shell
+		regexp.MustCompile(`nc\s+-l`),                     // Netcat listener
+		regexp.MustCompile(`/dev/tcp/`),                   // Network redirection
+		regexp.MustCompile(`iptables\s+-F`),               // Firewall flush
+		regexp.MustCompile(`base64\s+-d.*\|\s*(ba)?sh`),   // Base64 decode to shell
+		regexp.MustCompile(`(^|[;&|(\s])eval\s+`),         // Eval command
+		regexp.MustCompile(`\$'[^']*\\(x|[0-7])`),         // Hex or octal escapes in $'...'
 	}
 
 	return v
 }
 
+// interpreters are programs that execute code read from stdin when they are
+// not given a script argument.
+var interpreters = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "fish": true,
+	"python": true, "python2": true, "python3": true, "perl": true, "ruby": true,
+	"php": true, "node": true,
+}
+
+// shells are interpreters whose inline scripts (-c, here-documents) can be
+// parsed and validated recursively.
+var shells = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true,
+}
+
+// sensitiveFiles may not be referenced by any command or redirection.
+var sensitiveFiles = []string{"/etc/passwd", "/etc/shadow", "/etc/sudoers", "/dev/tcp/", "/dev/udp/"}
+
+// protectedPaths may not be written to, removed recursively or have their
+// permissions changed.
+var protectedPaths = []string{
+	"/", "/bin", "/boot", "/dev", "/etc", "/lib", "/lib64", "/proc", "/root",
+	"/sbin", "/sys", "/usr", "/var", "/home", "~", "$HOME", "${HOME}",
+}
+
 // ValidateCommand validates a command for safety.
 func (v *Validator) ValidateCommand(command string) error {
 	// Check command length
@@ -93,114 +121,368 @@ func (v *Validator) ValidateCommand(command string) error {
 		return fmt.Errorf("command contains null bytes")
 	}
 
-	// Convert to lowercase for checking
-	lowerCmd := strings.ToLower(command)
+	// Carriage returns can make a command display differently than it runs
+	if strings.Contains(command, "\r") {
+		return fmt.Errorf("command contains a carriage return")
+	}
 
-	// Check for exact forbidden commands
-	for _, forbidden := range v.forbiddenCommands {
-		if strings.Contains(lowerCmd, strings.ToLower(forbidden)) {
-			return fmt.Errorf("forbidden command detected: %s", forbidden)
+	return v.validateScript(command, 0)
+}
+
+// validateScript parses a script and validates every command in it.
+// depth tracks nesting through "bash -c" and shell here-documents.
+func (v *Validator) validateScript(script string, depth int) error {
+	if depth > 3 {
+		return fmt.Errorf("nested shell scripts are too deep")
+	}
+
+	parsed, err := shell.Parse(script)
+	if err != nil {
+		return err
+	}
+
+	if len(parsed.Commands) > v.maxCommands {
+		return fmt.Errorf("too many commands in one invocation (%d > %d)", len(parsed.Commands), v.maxCommands)
+	}
+
+	for _, fn := range parsed.Functions {
+		if fn.Recursive {
+			return fmt.Errorf("recursive function %q detected (possible fork bomb) at %d:%d", fn.Name, fn.Line, fn.Col)
+		}
+	}
+
+	for _, param := range parsed.Params {
+		if param == "IFS" {
+			return fmt.Errorf("suspicious use of $IFS detected")
+		}
+	}
+
+	for _, cmd := range parsed.Commands {
+		if err := v.validateSimpleCommand(cmd, depth); err != nil {
+			return err
 		}
 	}
 
-	// Check against forbidden patterns
 	for _, pattern := range v.forbiddenPatterns {
-		if pattern.MatchString(command) {
-			return fmt.Errorf("dangerous command pattern detected")
+		if pattern.MatchString(script) {
+			return fmt.Errorf("dangerous command pattern detected: %s", pattern)
 		}
 	}
 
-	// Check for dangerous command and flag combinations
-	for cmd, flags := range v.dangerousFlags {
-		if strings.Contains(lowerCmd, cmd) {
-			for _, flag := range flags {
-				if strings.Contains(lowerCmd, strings.ToLower(flag)) {
-					return fmt.Errorf("dangerous %s with flag %s detected", cmd, flag)
-				}
+	return nil
+}
+
+// validateSimpleCommand checks a single command against the policy.
+func (v *Validator) validateSimpleCommand(cmd shell.Command, depth int) error {
+	if cmd.Dynamic {
+		return shell.NewCommandError(cmd, "command name is computed at runtime and cannot be verified")
+	}
+
+	for _, name := range cmd.Assigns {
+		if !v.IsSafeEnvVar(name) {
+			return shell.NewCommandError(cmd, "assignment to unsafe variable %s", name)
+		}
+	}
+
+	for _, forbidden := range v.forbiddenCommands {
+		if cmd.Matches(forbidden) {
+			return shell.NewCommandError(cmd, "forbidden command %q", forbidden)
+		}
+	}
+
+	for _, check := range v.checks {
+		if err := check(cmd); err != nil {
+			return err
+		}
+	}
+
+	for _, word := range append(cmd.Words, redirectTargets(cmd)...) {
+		for _, sensitive := range sensitiveFiles {
+			if strings.Contains(word, sensitive) {
+				return shell.NewCommandError(cmd, "access to %s is not allowed", sensitive)
 			}
 		}
 	}
 
-	// Check for multiple command chaining with dangerous operators
-	if err := v.validateCommandChaining(command); err != nil {
+	for _, r := range cmd.Redirects {
+		if r.IsWrite() && isDeviceOrSystemPath(r.Target) {
+			return shell.NewCommandError(cmd, "write to %s is not allowed", r.Target)
+		}
+	}
+
+	if err := v.validateArguments(cmd); err != nil {
 		return err
 	}
 
-	// Check for shell injection attempts
-	if err := v.validateShellInjection(command); err != nil {
+	if err := v.validateFind(cmd, depth); err != nil {
 		return err
 	}
 
-	return nil
+	return v.validateInterpreter(cmd, depth)
 }
 
-// validateCommandChaining checks for dangerous command chaining.
-func (v *Validator) validateCommandChaining(command string) error {
-	// Check for dangerous pipe combinations
-	dangerousPipes := []string{
-		"| sh",
-		"| bash",
-		"| zsh",
-		"| python",
-		"| perl",
-		"| ruby",
-		"| php",
+// validateArguments applies the per-program argument rules.
+func (v *Validator) validateArguments(cmd shell.Command) error {
+	// xargs appends paths read from stdin, which cannot be checked
+	if slices.Contains(cmd.Wrappers, "xargs") && recursiveChange(cmd) {
+		return shell.NewCommandError(cmd, "recursive %s of paths read by xargs", cmd.Name)
 	}
 
-	lowerCmd := strings.ToLower(command)
-	for _, pipe := range dangerousPipes {
-		if strings.Contains(lowerCmd, pipe) {
-			return fmt.Errorf("dangerous pipe to interpreter detected: %s", pipe)
+	switch cmd.Name {
+	case "rm":
+		if cmd.HasFlag(0, "--no-preserve-root") {
+			return shell.NewCommandError(cmd, "rm with --no-preserve-root")
+		}
+		if cmd.HasFlag('r', "--recursive") || cmd.HasFlag('R') {
+			for _, target := range cmd.Operands() {
+				if isProtectedTarget(target) {
+					return shell.NewCommandError(cmd, "recursive removal of %s", target)
+				}
+			}
+		}
+
+	case "chmod", "chown", "chgrp":
+		if cmd.HasFlag('R', "--recursive") {
+			if cmd.Name == "chown" || cmd.Name == "chgrp" {
+				return shell.NewCommandError(cmd, "recursive ownership change")
+			}
+			for _, operand := range cmd.Operands() {
+				if operand == "777" || strings.Contains(operand, "a+rwx") || isProtectedTarget(operand) {
+					return shell.NewCommandError(cmd, "recursive permission change %s", operand)
+				}
+			}
+		}
+
+	case "dd":
+		for _, arg := range cmd.Args {
+			if strings.HasPrefix(arg, "of=") && isDeviceOrSystemPath(strings.TrimPrefix(arg, "of=")) {
+				return shell.NewCommandError(cmd, "dd writing to %s", strings.TrimPrefix(arg, "of="))
+			}
+		}
+
+	case "kill", "pkill", "killall":
+		for _, operand := range cmd.Operands() {
+			if operand == "1" {
+				return shell.NewCommandError(cmd, "signalling the init process")
+			}
+		}
+		for _, arg := range cmd.Args {
+			if arg == "-1" && cmd.Name == "kill" && len(cmd.Operands()) == 0 {
+				return shell.NewCommandError(cmd, "signalling every process")
+			}
+		}
+
+	case "nc", "ncat", "netcat":
+		if cmd.HasFlag('l', "--listen") || cmd.HasFlag('e', "--exec") {
+			return shell.NewCommandError(cmd, "network listener or remote exec")
+		}
+
+	case "git":
+		if !v.allowDestructiveGit {
+			// Configuration from the environment can define aliases
+			for _, name := range cmd.Assigns {
+				if strings.HasPrefix(strings.ToUpper(name), "GIT_CONFIG") {
+					return shell.NewCommandError(cmd, "git configured through %s (set tools.git.allow_destructive to permit it)", name)
+				}
+			}
+			if reason := git.DestructiveCommandIn(v.workDir, cmd.Args); reason != "" {
+				return shell.NewCommandError(cmd, "git %s (set tools.git.allow_destructive to permit it)", reason)
+			}
+		}
+
+	case "iptables", "ip6tables":
+		if cmd.HasFlag('F', "--flush") {
+			return shell.NewCommandError(cmd, "firewall flush")
 		}
 	}
 
-	// Check for excessive command chaining
-	separators := []string{"&&", "||", ";", "|"}
-	chainCount := 0
-	for _, sep := range separators {
-		chainCount += strings.Count(command, sep)
+	return nil
+}
+
+// findActions are find expressions that run a command given as the
+// following arguments, up to ";" or "+".
+var findActions = map[string]bool{"-exec": true, "-execdir": true, "-ok": true, "-okdir": true}
+
+// validateFind checks what find does with the files it finds: commands run
+// through -exec and friends are validated like any other command, and
+// deleting or changing files is refused when a starting point is a
+// protected path.
+func (v *Validator) validateFind(cmd shell.Command, depth int) error {
+	if cmd.Name != "find" {
+		return nil
+	}
+
+	var starts []string
+	for _, arg := range cmd.Args {
+		if strings.HasPrefix(arg, "-") || arg == "(" || arg == "!" {
+			break
+		}
+		starts = append(starts, arg)
 	}
 
-	if chainCount > 10 {
-		return fmt.Errorf("excessive command chaining detected (%d chains)", chainCount)
+	destructive := false
+	for i := 0; i < len(cmd.Args); i++ {
+		arg := cmd.Args[i]
+		if arg == "-delete" {
+			destructive = true
+			continue
+		}
+		if !findActions[arg] {
+			continue
+		}
+		end := i + 1
+		for end < len(cmd.Args) && cmd.Args[end] != ";" && cmd.Args[end] != "+" {
+			end++
+		}
+		words := cmd.Args[i+1 : end]
+		i = end
+		if len(words) == 0 {
+			continue
+		}
+		if err := v.validateScript(shell.Join(words), depth+1); err != nil {
+			return fmt.Errorf("in find %s command: %w", arg, err)
+		}
+		if exec, err := shell.Parse(shell.Join(words)); err == nil && len(exec.Commands) > 0 {
+			destructive = destructive || destructiveCommands[exec.Commands[0].Name]
+		}
 	}
 
+	if destructive {
+		for _, start := range starts {
+			if strings.TrimRight(start, "/") != "." && isProtectedTarget(start) {
+				return shell.NewCommandError(cmd, "find changing files under %s", start)
+			}
+		}
+	}
 	return nil
 }
 
-// validateShellInjection checks for shell injection attempts.
-func (v *Validator) validateShellInjection(command string) error {
-	// Check for suspicious character sequences
-	suspiciousPatterns := []string{
-		"${IFS}",     // Internal Field Separator abuse
-		"$'\\x",      // Hex escape sequences
-		"$'\\0",      // Octal escape sequences
-		"${PATH",     // Path manipulation
-		"${LD_",      // Library path manipulation
-		"${BASH_ENV", // Bash environment manipulation
+// destructiveCommands remove or change the files they are given.
+var destructiveCommands = map[string]bool{
+	"rm": true, "rmdir": true, "unlink": true, "shred": true, "truncate": true,
+	"mv": true, "chmod": true, "chown": true, "chgrp": true, "dd": true,
+}
+
+// recursiveChange reports whether the command removes or changes
+// permissions of whole directory trees.
+func recursiveChange(cmd shell.Command) bool {
+	switch cmd.Name {
+	case "rm":
+		return cmd.HasFlag('r', "--recursive") || cmd.HasFlag('R')
+	case "chmod", "chown", "chgrp":
+		return cmd.HasFlag('R', "--recursive")
+	}
+	return false
+}
+
+// validateInterpreter checks code handed to an interpreter: inline shell
+// scripts are validated recursively, code piped in from another command is
+// rejected because it cannot be inspected.
+func (v *Validator) validateInterpreter(cmd shell.Command, depth int) error {
+	if !interpreters[cmd.Name] && cmd.Name != "source" && cmd.Name != "." {
+		return nil
+	}
+
+	// Code read from a process substitution, as in "sh <(curl ...)", cannot
+	// be inspected
+	for _, word := range append(cmd.Args, redirectTargets(cmd)...) {
+		if strings.HasPrefix(word, "<(") {
+			return shell.NewCommandError(cmd, "running code from a process substitution")
+		}
+	}
+	if !interpreters[cmd.Name] {
+		return nil
+	}
+
+	if shells[cmd.Name] {
+		for i, arg := range cmd.Args {
+			if strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.Contains(arg, "c") {
+				if i+1 >= len(cmd.Args) {
+					return shell.NewCommandError(cmd, "%s -c without a script", cmd.Name)
+				}
+				if err := v.validateScript(cmd.Args[i+1], depth+1); err != nil {
+					return fmt.Errorf("in %s -c script: %w", cmd.Name, err)
+				}
+				return nil
+			}
+		}
 	}
 
-	for _, pattern := range suspiciousPatterns {
-		if strings.Contains(command, pattern) {
-			return fmt.Errorf("suspicious pattern detected: %s", pattern)
+	// The interpreter runs a script file.
+	operands := cmd.Operands()
+	if len(operands) > 0 && operands[0] != "-" {
+		return nil
+	}
+	for _, arg := range cmd.Args {
+		if arg == "-e" || arg == "-c" || arg == "-m" || arg == "-E" || arg == "-r" {
+			return nil // inline code for python/perl/ruby/node/php
 		}
 	}
 
-	// Check for excessive special characters
-	specialChars := []string{"$", "`", "\\", ";", "&", "|", "(", ")", "{", "}", "[", "]", "<", ">"}
-	specialCount := 0
-	for _, char := range specialChars {
-		specialCount += strings.Count(command, char)
+	for _, r := range cmd.Redirects {
+		if r.IsHeredoc() {
+			if shells[cmd.Name] {
+				if err := v.validateScript(r.Body, depth+1); err != nil {
+					return fmt.Errorf("in %s here-document: %w", cmd.Name, err)
+				}
+			}
+			return nil
+		}
 	}
 
-	// If special characters make up more than 30% of the command, it's suspicious
-	if len(command) > 0 && float64(specialCount)/float64(len(command)) > 0.3 {
-		return fmt.Errorf("excessive special characters detected")
+	if cmd.Piped {
+		return shell.NewCommandError(cmd, "piping into interpreter %s", cmd.Name)
 	}
 
 	return nil
 }
 
+// redirectTargets returns the targets of all redirections of a command.
+func redirectTargets(cmd shell.Command) []string {
+	targets := make([]string, 0, len(cmd.Redirects))
+	for _, r := range cmd.Redirects {
+		if !r.IsHeredoc() {
+			targets = append(targets, r.Target)
+		}
+	}
+	return targets
+}
+
+// isProtectedTarget reports whether a path operand refers to the filesystem
+// root, a system directory, the home directory, a parent directory or a bare
+// glob.
+func isProtectedTarget(target string) bool {
+	cleaned := strings.TrimRight(target, "/")
+	if cleaned == "" || cleaned == "/*" || cleaned == "*" || cleaned == "." || cleaned == ".." {
+		return true
+	}
+	if strings.HasPrefix(cleaned, "../") || strings.Contains(cleaned, "/../") {
+		return true
+	}
+	cleaned = strings.TrimSuffix(cleaned, "/*")
+	for _, protected := range protectedPaths {
+		if cleaned == protected {
+			return true
+		}
+	}
+	return strings.HasPrefix(cleaned, "/") && strings.Count(cleaned, "/") == 1
+}
+
+// isDeviceOrSystemPath reports whether writing to the path would touch a
+// block device or system configuration.
+func isDeviceOrSystemPath(target string) bool {
+	switch target {
+	case "/dev/null", "/dev/stdout", "/dev/stderr", "/dev/tty":
+		return false
+	}
+	for _, prefix := range []string{"/dev/", "/etc/", "/boot/", "/sys/", "/proc/"} {
+		if strings.HasPrefix(target, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // IsSafeEnvVar checks if an environment variable name is safe to set.
 func (v *Validator) IsSafeEnvVar(name string) bool {
 	// Dangerous environment variables
@@ -250,6 +532,24 @@ func (v *Validator) AddForbiddenPattern(pattern string) error {
 	return nil
 }
 
+// AddCheck adds a rule applied to every simple command, including the
+// commands of nested "bash -c" scripts and shell here-documents.
+func (v *Validator) AddCheck(check func(shell.Command) error) {
+	v.checks = append(v.checks, check)
+}
+
+// SetAllowDestructiveGit sets whether git commands that discard work or
+// rewrite history are allowed.
+func (v *Validator) SetAllowDestructiveGit(allow bool) {
+	v.allowDestructiveGit = allow
+}
+
+// SetWorkDir sets the directory commands run in, so a git checkout of a
+// file there is recognized as discarding its changes.
+func (v *Validator) SetWorkDir(dir string) {
+	v.workDir = dir
+}
+
 // SetMaxCommandLength sets the maximum allowed command length.
 func (v *Validator) SetMaxCommandLength(length int) {
 	if length > 0 {
@@ -286,4 +586,4 @@ func (v *Validator) ValidatePath(path string) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,23 @@
+package tools
+
+import "context"
+
+// CallInfo identifies the tool call a tool is executing, so tools can
+// label what they record (for example backups) with its origin.
+type CallInfo struct {
+	ToolUseID string
+	SessionID string
+}
+
+type callInfoKey struct{}
+
+// WithCallInfo returns a context carrying info.
+func WithCallInfo(ctx context.Context, info CallInfo) context.Context {
+	return context.WithValue(ctx, callInfoKey{}, info)
+}
+
+// CallInfoFrom returns the call info carried by ctx, or the zero value.
+func CallInfoFrom(ctx context.Context) CallInfo {
+	info, _ := ctx.Value(callInfoKey{}).(CallInfo)
+	return info
+}
@@ -0,0 +1,272 @@
+package codeintel
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testModule is a small workspace exercising grouped declarations,
+// generics, interfaces and cross-package calls.
+var testModule = map[string]string{
+	"go.mod": "module example.com/demo\n\ngo 1.21\n",
+	"shapes/shapes.go": `package shapes
+
+// Shape has an area.
+type Shape interface {
+	Area() float64
+}
+
+type (
+	Square struct{ Side float64 }
+	Circle struct{ R float64 }
+)
+
+var (
+	Unit  = Square{Side: 1}
+	count int
+)
+
+func (s Square) Area() float64 { return s.Side * s.Side }
+
+func (c *Circle) Area() float64 { return 3 * c.R * c.R }
+
+// Sum adds up the areas of shapes.
+func Sum[T Shape](items []T) float64 {
+	total := 0.0
+	for _, it := range items {
+		total += it.Area()
+	}
+	count++
+	return total
+}
+
+func unexported() {}
+`,
+	"main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/demo/shapes"
+)
+
+func main() {
+	total := shapes.Sum([]shapes.Square{shapes.Unit})
+	fmt.Println(total, report())
+}
+
+func report() float64 {
+	return shapes.Sum[shapes.Shape]([]shapes.Shape{&shapes.Circle{R: 1}})
+}
+`,
+	"shapes/shapes_test.go": `package shapes
+
+import "testing"
+
+func TestSum(t *testing.T) {
+	if Sum([]Square{Unit}) != 1 {
+		t.Fatal("bad sum")
+	}
+}
+`,
+}
+
+// setupModule writes testModule into a temporary directory.
+func setupModule(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range testModule {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// runQuery executes the tool and decodes a successful response.
+func runQuery(t *testing.T, tool *CodeIntelTool, input map[string]interface{}) QueryData {
+	t.Helper()
+	out, err := tool.Execute(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+
+	var resp struct {
+		Ok    bool      `json:"ok"`
+		Error string    `json:"error"`
+		Data  QueryData `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v\n%s", err, out)
+	}
+	if !resp.Ok {
+		t.Fatalf("query %v failed: %s", input, resp.Error)
+	}
+	return resp.Data
+}
+
+// positions returns the position strings of the results.
+func positions(data QueryData) []string {
+	var out []string
+	for _, r := range data.Results {
+		out = append(out, r.Position)
+	}
+	return out
+}
+
+// TestCodeIntelTool tests the code_intel queries against a real module
+func TestCodeIntelTool(t *testing.T) {
+	dir := setupModule(t)
+	tool := NewCodeIntelTool(dir)
+
+	t.Run("DefinitionGroupedType", func(t *testing.T) {
+		data := runQuery(t, tool, map[string]interface{}{"action": "definition", "symbol": "shapes.Circle"})
+		if data.Count != 1 || data.Results[0].Position != "shapes/shapes.go:10:2" {
+			t.Fatalf("unexpected definition: %+v", data.Results)
+		}
+		if data.Results[0].Kind != "struct" {
+			t.Errorf("expected kind struct, got %s", data.Results[0].Kind)
+		}
+	})
+
+	t.Run("DefinitionGroupedVar", func(t *testing.T) {
+		data := runQuery(t, tool, map[string]interface{}{"action": "definition", "symbol": "Unit"})
+		if data.Count != 1 || data.Results[0].Position != "shapes/shapes.go:14:2" {
+			t.Fatalf("unexpected definition: %+v", data.Results)
+		}
+	})
+
+	t.Run("DefinitionByPosition", func(t *testing.T) {
+		// "Sum" in main.go line 10: `total := shapes.Sum(...)`
+		data := runQuery(t, tool, map[string]interface{}{"action": "definition", "position": "main.go:10:18"})
+		if data.Count != 1 || data.Results[0].Position != "shapes/shapes.go:23:6" {
+			t.Fatalf("unexpected definition: %+v", data.Results)
+		}
+		if data.Results[0].Kind != "function" {
+			t.Errorf("expected kind function, got %s", data.Results[0].Kind)
+		}
+	})
+
+	t.Run("ReferencesIncludeGenericsAndTests", func(t *testing.T) {
+		data := runQuery(t, tool, map[string]interface{}{"action": "references", "symbol": "example.com/demo/shapes.Sum"})
+		want := []string{"main.go:10:18", "main.go:15:16", "shapes/shapes_test.go:6:5"}
+		if got := positions(data); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("references = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Callers", func(t *testing.T) {
+		data := runQuery(t, tool, map[string]interface{}{"action": "callers", "symbol": "Square.Area"})
+		if data.Count != 0 {
+			// Area is only called through the interface, never on Square directly.
+			t.Errorf("expected no direct callers, got %+v", data.Results)
+		}
+
+		data = runQuery(t, tool, map[string]interface{}{"action": "callers", "symbol": "report"})
+		if data.Count != 1 || data.Results[0].Caller != "main" {
+			t.Errorf("unexpected callers: %+v", data.Results)
+		}
+	})
+
+	t.Run("Implementations", func(t *testing.T) {
+		data := runQuery(t, tool, map[string]interface{}{"action": "implementations", "symbol": "Shape"})
+		var names []string
+		for _, r := range data.Results {
+			names = append(names, r.Name)
+		}
+		if strings.Join(names, ",") != "Square,Circle" {
+			t.Errorf("implementations = %v, want [Square Circle]", names)
+		}
+
+		data = runQuery(t, tool, map[string]interface{}{"action": "implementations", "symbol": "Circle"})
+		if data.Count != 1 || data.Results[0].Name != "Shape" {
+			t.Errorf("interfaces of Circle = %+v", data.Results)
+		}
+
+		data = runQuery(t, tool, map[string]interface{}{"action": "implementations", "symbol": "Shape.Area"})
+		if data.Count != 2 {
+			t.Errorf("expected 2 Area implementations, got %+v", data.Results)
+		}
+	})
+
+	t.Run("API", func(t *testing.T) {
+		data := runQuery(t, tool, map[string]interface{}{"action": "api", "package": "./shapes"})
+		var names []string
+		for _, r := range data.Results {
+			names = append(names, r.Name)
+		}
+		got := strings.Join(names, ",")
+		want := "Circle,Circle.Area,Shape,Square,Square.Area,Sum,Unit"
+		if got != want {
+			t.Errorf("api = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		out, err := tool.Execute(context.Background(), map[string]interface{}{"action": "definition", "symbol": "Missing"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, `"ok":false`) || !strings.Contains(out, "symbol not found") {
+			t.Errorf("expected symbol not found error, got %s", out)
+		}
+	})
+}
+
+// TestCodeIntelCacheInvalidation tests that edits to Go files are picked up
+func TestCodeIntelCacheInvalidation(t *testing.T) {
+	dir := setupModule(t)
+	tool := NewCodeIntelTool(dir)
+
+	data := runQuery(t, tool, map[string]interface{}{"action": "callers", "symbol": "report"})
+	if data.Count != 1 {
+		t.Fatalf("expected 1 caller, got %d", data.Count)
+	}
+
+	extra := "package main\n\nfunc again() float64 { return report() }\n"
+	if err := os.WriteFile(filepath.Join(dir, "extra.go"), []byte(extra), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data = runQuery(t, tool, map[string]interface{}{"action": "callers", "symbol": "report"})
+	if data.Count != 2 {
+		t.Errorf("expected 2 callers after edit, got %d", data.Count)
+	}
+}
+
+// TestCodeIntelValidate tests input validation
+func TestCodeIntelValidate(t *testing.T) {
+	tool := NewCodeIntelTool(t.TempDir())
+
+	tests := []struct {
+		name    string
+		input   map[string]interface{}
+		wantErr bool
+	}{
+		{"definition by symbol", map[string]interface{}{"action": "definition", "symbol": "Foo"}, false},
+		{"references by position", map[string]interface{}{"action": "references", "position": "a.go:1:2"}, false},
+		{"api", map[string]interface{}{"action": "api", "package": "./pkg"}, false},
+		{"missing action", map[string]interface{}{"symbol": "Foo"}, true},
+		{"invalid action", map[string]interface{}{"action": "rename", "symbol": "Foo"}, true},
+		{"missing symbol", map[string]interface{}{"action": "callers"}, true},
+		{"bad position", map[string]interface{}{"action": "definition", "position": "a.go:x"}, true},
+		{"api without package", map[string]interface{}{"action": "api"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tool.Validate(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
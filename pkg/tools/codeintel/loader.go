@@ -0,0 +1,138 @@
+package codeintel
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/Zerofisher/goai/pkg/ignore"
+)
+
+// loadMode is what every query needs: syntax and full type information.
+// Dependencies are type-checked from source too (NeedDeps) rather than
+// imported from export data, whose format changes between Go releases and
+// would tie the tool to the toolchain it was built with.
+const loadMode = packages.NeedName |
+	packages.NeedFiles |
+	packages.NeedCompiledGoFiles |
+	packages.NeedImports |
+	packages.NeedDeps |
+	packages.NeedTypes |
+	packages.NeedTypesInfo |
+	packages.NeedSyntax |
+	packages.NeedModule
+
+// program is one type-checked snapshot of the workspace.
+type program struct {
+	pkgs        []*packages.Package // Workspace packages (load roots)
+	fingerprint string
+}
+
+// loader loads workspace packages and caches the result until a Go file
+// changes.
+type loader struct {
+	workDir string
+	tests   bool
+
+	mu     sync.Mutex
+	cached *program
+}
+
+// newLoader creates a loader rooted at workDir.
+func newLoader(workDir string, tests bool) *loader {
+	return &loader{workDir: workDir, tests: tests}
+}
+
+// load returns the type-checked workspace, reusing the cached snapshot when
+// no Go file has changed since it was taken.
+func (l *loader) load(ctx context.Context) (*program, error) {
+	fingerprint, err := l.fingerprint(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cached != nil && l.cached.fingerprint == fingerprint {
+		return l.cached, nil
+	}
+
+	cfg := &packages.Config{
+		Context: ctx,
+		Mode:    loadMode,
+		Dir:     l.workDir,
+		Tests:   l.tests,
+		// Never reach out to the network: queries must work offline and
+		// must not hang on module downloads.
+		Env:       append(os.Environ(), "GOPROXY=off"),
+		ParseFile: l.parseFile,
+	}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packages: %w", err)
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no Go packages found in %s", l.workDir)
+	}
+
+	l.cached = &program{pkgs: pkgs, fingerprint: fingerprint}
+	return l.cached, nil
+}
+
+// parseFile parses a Go file for the loader. Function bodies outside the
+// work directory are dropped: dependencies only need their declarations,
+// and skipping their bodies makes type-checking them from source cheap.
+func (l *loader) parseFile(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+	mode := parser.AllErrors | parser.ParseComments
+	if !l.inWorkDir(filename) {
+		mode = parser.SkipObjectResolution
+	}
+	f, err := parser.ParseFile(fset, filename, src, mode)
+	if f != nil && !l.inWorkDir(filename) {
+		for _, decl := range f.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok {
+				fn.Body = nil
+			}
+		}
+	}
+	return f, err
+}
+
+// inWorkDir reports whether filename lies under the work directory.
+func (l *loader) inWorkDir(filename string) bool {
+	rel, err := filepath.Rel(l.workDir, filename)
+	return err == nil && !strings.HasPrefix(rel, "..")
+}
+
+// fingerprint summarizes the Go sources of the workspace (names, sizes and
+// modification times) so a changed tree invalidates the cache.
+func (l *loader) fingerprint(ctx context.Context) (string, error) {
+	h := fnv.New64a()
+	err := ignore.Walk(ctx, l.workDir, ignore.WalkOptions{}, func(rel string, d fs.DirEntry) error {
+		if d.IsDir() || !(strings.HasSuffix(rel, ".go") || path.Base(rel) == "go.mod") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		fmt.Fprintf(h, "%s:%d:%d\n", rel, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to scan %s: %w", l.workDir, err)
+	}
+	return fmt.Sprintf("%x", h.Sum64()), nil
+}
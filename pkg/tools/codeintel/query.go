@@ -0,0 +1,629 @@
+package codeintel
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// index answers queries over one loaded program.
+//
+// With tests enabled a package is type-checked more than once (the plain
+// package and its test variant), so the same declaration can be represented
+// by several types.Object values. Objects are therefore compared by the
+// position of their declaration rather than by identity, and results are
+// de-duplicated by position.
+type index struct {
+	workDir string
+	pkgs    []*packages.Package
+	fset    *token.FileSet
+	lines   map[string][][]byte // file contents cache for snippets
+}
+
+// newIndex creates an index over the loaded program.
+func newIndex(workDir string, prog *program) *index {
+	idx := &index{
+		workDir: workDir,
+		lines:   make(map[string][][]byte),
+	}
+	for _, pkg := range prog.pkgs {
+		if pkg.Types == nil || pkg.TypesInfo == nil {
+			continue
+		}
+		idx.pkgs = append(idx.pkgs, pkg)
+		if idx.fset == nil {
+			idx.fset = pkg.Fset
+		}
+	}
+	return idx
+}
+
+// loadErrors returns up to max package load errors, so callers can tell the
+// model when results may be incomplete.
+func (idx *index) loadErrors(max int) []string {
+	var errs []string
+	seen := make(map[string]bool)
+	for _, pkg := range idx.pkgs {
+		for _, err := range pkg.Errors {
+			msg := err.Error()
+			if seen[msg] {
+				continue
+			}
+			seen[msg] = true
+			if len(errs) < max {
+				errs = append(errs, msg)
+			}
+		}
+	}
+	return errs
+}
+
+// objectKey identifies a declaration independently of the package variant
+// that produced the object.
+func (idx *index) objectKey(obj types.Object) string {
+	if obj == nil || !obj.Pos().IsValid() {
+		return ""
+	}
+	return obj.Name() + "@" + idx.fset.Position(obj.Pos()).String()
+}
+
+// resolve finds the objects a query refers to. The query is either a
+// position ("file.go:12:7") or a symbol name: "Name", "pkg.Name",
+// "Type.Member", "pkg.Type.Member", or any of these prefixed with a full
+// import path ("example.com/mod/pkg.Name").
+func (idx *index) resolve(query string) ([]types.Object, error) {
+	if file, line, col, ok := parsePosition(query); ok {
+		return idx.resolvePosition(file, line, col)
+	}
+	return idx.resolveSymbol(query)
+}
+
+// resolvePosition returns the objects defined or used by the identifier at
+// file:line:col.
+func (idx *index) resolvePosition(file string, line, col int) ([]types.Object, error) {
+	abs := file
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(idx.workDir, file)
+	}
+	abs = filepath.Clean(abs)
+
+	var objs []types.Object
+	found := false
+	for _, pkg := range idx.pkgs {
+		for _, f := range pkg.Syntax {
+			tf := pkg.Fset.File(f.Pos())
+			if tf == nil || filepath.Clean(tf.Name()) != abs {
+				continue
+			}
+			found = true
+			if line < 1 || line > tf.LineCount() {
+				return nil, fmt.Errorf("line %d out of range (file has %d lines)", line, tf.LineCount())
+			}
+			start := tf.LineStart(line)
+			if col < 1 || tf.Offset(start)+col-1 > tf.Size() {
+				return nil, fmt.Errorf("column %d out of range", col)
+			}
+			pos := start + token.Pos(col-1)
+
+			id := identAt(f, pos)
+			if id == nil {
+				continue
+			}
+			if obj := pkg.TypesInfo.Uses[id]; obj != nil {
+				objs = append(objs, obj)
+			} else if obj := pkg.TypesInfo.Defs[id]; obj != nil {
+				objs = append(objs, obj)
+			}
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("file is not part of any loaded package: %s", file)
+	}
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("no identifier at %s:%d:%d", file, line, col)
+	}
+	return objs, nil
+}
+
+// resolveSymbol returns the objects named by a dotted symbol.
+func (idx *index) resolveSymbol(symbol string) ([]types.Object, error) {
+	pkgPath := ""
+	rest := symbol
+	if i := strings.LastIndex(symbol, "/"); i >= 0 {
+		dot := strings.Index(symbol[i:], ".")
+		if dot < 0 {
+			return nil, fmt.Errorf("invalid symbol %q: expected import/path.Name", symbol)
+		}
+		pkgPath = symbol[:i+dot]
+		rest = symbol[i+dot+1:]
+	}
+
+	parts := strings.Split(rest, ".")
+	for _, p := range parts {
+		if p == "" {
+			return nil, fmt.Errorf("invalid symbol %q", symbol)
+		}
+	}
+
+	var objs []types.Object
+	for _, pkg := range idx.pkgs {
+		scope := pkg.Types.Scope()
+		inPkg := pkgPath == "" || pkg.PkgPath == pkgPath
+
+		switch len(parts) {
+		case 1:
+			if inPkg {
+				if obj := scope.Lookup(parts[0]); obj != nil {
+					objs = append(objs, obj)
+				}
+			}
+		case 2:
+			// pkg.Name
+			if pkgPath == "" && pkg.Name == parts[0] {
+				if obj := scope.Lookup(parts[1]); obj != nil {
+					objs = append(objs, obj)
+				}
+			}
+			// Type.Member
+			if inPkg {
+				if obj := lookupMember(pkg.Types, parts[0], parts[1]); obj != nil {
+					objs = append(objs, obj)
+				}
+			}
+		case 3:
+			if pkgPath == "" && pkg.Name == parts[0] {
+				if obj := lookupMember(pkg.Types, parts[1], parts[2]); obj != nil {
+					objs = append(objs, obj)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("invalid symbol %q: too many components", symbol)
+		}
+	}
+
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("symbol not found: %s", symbol)
+	}
+	return objs, nil
+}
+
+// lookupMember finds a method or field of the named type typeName declared
+// in pkg.
+func lookupMember(pkg *types.Package, typeName, member string) types.Object {
+	tn, ok := pkg.Scope().Lookup(typeName).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	obj, _, _ := types.LookupFieldOrMethod(tn.Type(), true, pkg, member)
+	return obj
+}
+
+// definitions returns the declaration of each target.
+func (idx *index) definitions(targets []types.Object) []Location {
+	var locs []Location
+	for _, obj := range targets {
+		locs = append(locs, idx.describe(obj))
+	}
+	return dedupe(locs)
+}
+
+// references returns every use of the targets across the workspace.
+func (idx *index) references(targets []types.Object) []Location {
+	keys := idx.keySet(targets)
+
+	var locs []Location
+	for _, pkg := range idx.pkgs {
+		for id, obj := range pkg.TypesInfo.Uses {
+			if !keys[idx.objectKey(obj)] {
+				continue
+			}
+			loc := idx.location(id.Pos())
+			loc.Name = id.Name
+			loc.Snippet = idx.snippet(loc)
+			locs = append(locs, loc)
+		}
+	}
+	return dedupe(locs)
+}
+
+// callers returns the call sites of the target functions, with the function
+// that contains each call.
+func (idx *index) callers(targets []types.Object) ([]Location, error) {
+	for _, obj := range targets {
+		if _, ok := obj.(*types.Func); !ok {
+			return nil, fmt.Errorf("%s is a %s, not a function or method", obj.Name(), kindOf(obj))
+		}
+	}
+	keys := idx.keySet(targets)
+
+	var locs []Location
+	for _, pkg := range idx.pkgs {
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				caller := "package initialization"
+				if fn, ok := decl.(*ast.FuncDecl); ok {
+					caller = funcDeclName(fn)
+				}
+				ast.Inspect(decl, func(n ast.Node) bool {
+					call, ok := n.(*ast.CallExpr)
+					if !ok {
+						return true
+					}
+					id := calleeIdent(call.Fun)
+					if id == nil || !keys[idx.objectKey(pkg.TypesInfo.Uses[id])] {
+						return true
+					}
+					loc := idx.location(call.Pos())
+					loc.Name = id.Name
+					loc.Caller = caller
+					loc.Snippet = idx.snippet(loc)
+					locs = append(locs, loc)
+					return true
+				})
+			}
+		}
+	}
+	return dedupe(locs), nil
+}
+
+// implementations relates interfaces and concrete types. For an interface
+// it returns the types implementing it; for a concrete type, the interfaces
+// it implements; for a method, the corresponding methods on the other side.
+func (idx *index) implementations(targets []types.Object) ([]Location, error) {
+	var locs []Location
+	for _, obj := range targets {
+		switch obj := obj.(type) {
+		case *types.TypeName:
+			for _, match := range idx.implementing(obj.Type(), "") {
+				locs = append(locs, idx.describe(match))
+			}
+		case *types.Func:
+			recv := obj.Type().(*types.Signature).Recv()
+			if recv == nil {
+				return nil, fmt.Errorf("%s is a function; implementations needs a type or method", obj.Name())
+			}
+			for _, match := range idx.implementing(recv.Type(), obj.Name()) {
+				locs = append(locs, idx.describe(match))
+			}
+		default:
+			return nil, fmt.Errorf("%s is a %s; implementations needs a type or method", obj.Name(), kindOf(obj))
+		}
+	}
+	return dedupe(locs), nil
+}
+
+// implementing returns the package-level types on the other side of an
+// implements relation with t. When method is set, the method of that name
+// is returned instead of the type.
+func (idx *index) implementing(t types.Type, method string) []types.Object {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	iface, isIface := t.Underlying().(*types.Interface)
+
+	var matches []types.Object
+	for _, pkg := range idx.pkgs {
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			named, ok := tn.Type().(*types.Named)
+			if !ok || named.TypeParams().Len() > 0 || types.Identical(named, t) {
+				continue
+			}
+			candIface, candIsIface := named.Underlying().(*types.Interface)
+
+			var related bool
+			switch {
+			case isIface && !candIsIface:
+				related = types.Implements(named, iface) || types.Implements(types.NewPointer(named), iface)
+			case !isIface && candIsIface && candIface.NumMethods() > 0:
+				related = types.Implements(t, candIface) || types.Implements(types.NewPointer(t), candIface)
+			}
+			if !related {
+				continue
+			}
+
+			if method == "" {
+				matches = append(matches, tn)
+				continue
+			}
+			if m, _, _ := types.LookupFieldOrMethod(named, true, pkg.Types, method); m != nil {
+				matches = append(matches, m)
+			}
+		}
+	}
+	return matches
+}
+
+// api returns the exported declarations of a package, including the
+// exported methods of its types.
+func (idx *index) api(pkg *packages.Package) []Location {
+	var locs []Location
+	scope := pkg.Types.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		locs = append(locs, idx.describe(obj))
+
+		tn, ok := obj.(*types.TypeName)
+		if !ok || tn.IsAlias() {
+			continue
+		}
+		named, ok := tn.Type().(*types.Named)
+		if !ok || types.IsInterface(named) {
+			continue
+		}
+		for i := 0; i < named.NumMethods(); i++ {
+			if m := named.Method(i); m.Exported() {
+				loc := idx.describe(m)
+				loc.Name = name + "." + m.Name()
+				locs = append(locs, loc)
+			}
+		}
+	}
+	return locs
+}
+
+// findPackage resolves an import path, a directory relative to the work
+// directory or a package name to a loaded (non-test) package.
+func (idx *index) findPackage(query string) (*packages.Package, error) {
+	dir := filepath.Clean(filepath.Join(idx.workDir, query))
+	if filepath.IsAbs(query) {
+		dir = filepath.Clean(query)
+	}
+
+	var byName []*packages.Package
+	for _, pkg := range idx.pkgs {
+		if pkg.ID != pkg.PkgPath {
+			continue // Test variant
+		}
+		if pkg.PkgPath == query {
+			return pkg, nil
+		}
+		if len(pkg.GoFiles) > 0 && filepath.Dir(pkg.GoFiles[0]) == dir {
+			return pkg, nil
+		}
+		if pkg.Name == query {
+			byName = append(byName, pkg)
+		}
+	}
+
+	switch len(byName) {
+	case 0:
+		return nil, fmt.Errorf("package not found: %s", query)
+	case 1:
+		return byName[0], nil
+	default:
+		var paths []string
+		for _, pkg := range byName {
+			paths = append(paths, pkg.PkgPath)
+		}
+		return nil, fmt.Errorf("package name %q is ambiguous: %s", query, strings.Join(paths, ", "))
+	}
+}
+
+// describe returns the location of an object's declaration with its kind
+// and signature.
+func (idx *index) describe(obj types.Object) Location {
+	loc := idx.location(obj.Pos())
+	loc.Name = obj.Name()
+	if f, ok := obj.(*types.Func); ok {
+		if recv := f.Type().(*types.Signature).Recv(); recv != nil {
+			loc.Name = receiverName(recv.Type()) + "." + obj.Name()
+		}
+	}
+	loc.Kind = kindOf(obj)
+	loc.Signature = types.ObjectString(obj, types.RelativeTo(obj.Pkg()))
+	return loc
+}
+
+// location converts a token position into a workspace-relative location.
+func (idx *index) location(pos token.Pos) Location {
+	p := idx.fset.Position(pos)
+	file := p.Filename
+	if rel, err := filepath.Rel(idx.workDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+		file = filepath.ToSlash(rel)
+	}
+	return Location{
+		Position: fmt.Sprintf("%s:%d:%d", file, p.Line, p.Column),
+		File:     file,
+		Line:     p.Line,
+		Column:   p.Column,
+	}
+}
+
+// snippet returns the trimmed source line of a location.
+func (idx *index) snippet(loc Location) string {
+	name := loc.File
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(idx.workDir, name)
+	}
+	lines, ok := idx.lines[name]
+	if !ok {
+		data, err := os.ReadFile(name)
+		if err == nil {
+			lines = bytes.Split(data, []byte("\n"))
+		}
+		idx.lines[name] = lines
+	}
+	if loc.Line < 1 || loc.Line > len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(string(lines[loc.Line-1]))
+}
+
+// keySet returns the declaration keys of the targets.
+func (idx *index) keySet(targets []types.Object) map[string]bool {
+	keys := make(map[string]bool)
+	for _, obj := range targets {
+		if key := idx.objectKey(obj); key != "" {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// dedupe removes locations reported more than once (for example by a
+// package and its test variant) and sorts the rest by position.
+func dedupe(locs []Location) []Location {
+	seen := make(map[string]bool)
+	unique := locs[:0]
+	for _, loc := range locs {
+		key := loc.Position + "|" + loc.Name
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, loc)
+	}
+	sort.SliceStable(unique, func(i, j int) bool {
+		a, b := unique[i], unique[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return unique
+}
+
+// identAt returns the identifier of f that covers pos.
+func identAt(f *ast.File, pos token.Pos) *ast.Ident {
+	var found *ast.Ident
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil || found != nil || pos < n.Pos() || pos > n.End() {
+			return false
+		}
+		if id, ok := n.(*ast.Ident); ok && pos < id.End() {
+			found = id
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// calleeIdent returns the identifier naming the function called by fun.
+func calleeIdent(fun ast.Expr) *ast.Ident {
+	for {
+		switch e := fun.(type) {
+		case *ast.ParenExpr:
+			fun = e.X
+		case *ast.IndexExpr: // Explicit instantiation: F[int](x)
+			fun = e.X
+		case *ast.IndexListExpr:
+			fun = e.X
+		case *ast.Ident:
+			return e
+		case *ast.SelectorExpr:
+			return e.Sel
+		default:
+			return nil
+		}
+	}
+}
+
+// funcDeclName renders a function declaration as Name or Recv.Name.
+func funcDeclName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	recv := fn.Recv.List[0].Type
+	for {
+		switch e := recv.(type) {
+		case *ast.StarExpr:
+			recv = e.X
+			continue
+		case *ast.IndexExpr:
+			recv = e.X
+			continue
+		case *ast.IndexListExpr:
+			recv = e.X
+			continue
+		case *ast.Ident:
+			return e.Name + "." + fn.Name.Name
+		}
+		return fn.Name.Name
+	}
+}
+
+// receiverName returns the bare type name of a method receiver.
+func receiverName(t types.Type) string {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	switch t := t.(type) {
+	case *types.Named:
+		return t.Obj().Name()
+	case *types.Interface:
+		return "interface"
+	}
+	return t.String()
+}
+
+// kindOf names the kind of declaration an object represents.
+func kindOf(obj types.Object) string {
+	switch obj := obj.(type) {
+	case *types.Func:
+		if obj.Type().(*types.Signature).Recv() != nil {
+			return "method"
+		}
+		return "function"
+	case *types.TypeName:
+		if obj.IsAlias() {
+			return "alias"
+		}
+		switch obj.Type().Underlying().(type) {
+		case *types.Interface:
+			return "interface"
+		case *types.Struct:
+			return "struct"
+		}
+		return "type"
+	case *types.Var:
+		if obj.IsField() {
+			return "field"
+		}
+		return "variable"
+	case *types.Const:
+		return "constant"
+	case *types.PkgName:
+		return "package"
+	}
+	return "object"
+}
+
+// parsePosition splits "file:line:col" into its parts.
+func parsePosition(s string) (file string, line, col int, ok bool) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return "", 0, 0, false
+	}
+	j := strings.LastIndex(s[:i], ":")
+	if j <= 0 {
+		return "", 0, 0, false
+	}
+	line, err1 := strconv.Atoi(s[j+1 : i])
+	col, err2 := strconv.Atoi(s[i+1:])
+	if err1 != nil || err2 != nil {
+		return "", 0, 0, false
+	}
+	return s[:j], line, col, true
+}
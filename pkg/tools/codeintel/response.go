@@ -0,0 +1,69 @@
+package codeintel
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ToolResponse represents the standardized JSON response format for the
+// code_intel tool: {"ok":true,"summary":"...","data":{...}}
+type ToolResponse struct {
+	Ok      bool        `json:"ok"`
+	Summary string      `json:"summary"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// QueryData contains the data returned by a code_intel query.
+type QueryData struct {
+	Action     string     `json:"action"`
+	Query      string     `json:"query"`
+	Results    []Location `json:"results"`
+	Count      int        `json:"count"`
+	Truncated  bool       `json:"truncated,omitempty"`
+	LoadErrors []string   `json:"load_errors,omitempty"`
+}
+
+// Location is a precise source position with optional details about the
+// declaration or use found there.
+type Location struct {
+	Position  string `json:"position"` // file:line:col
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	Name      string `json:"name,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Caller    string `json:"caller,omitempty"`
+	Snippet   string `json:"snippet,omitempty"`
+}
+
+// Success creates a successful response with data.
+func Success(summary string, data interface{}) string {
+	resp := ToolResponse{
+		Ok:      true,
+		Summary: summary,
+		Data:    data,
+	}
+	return marshalResponse(resp)
+}
+
+// Error creates an error response.
+func Error(summary string, err error) string {
+	resp := ToolResponse{
+		Ok:      false,
+		Summary: summary,
+		Error:   err.Error(),
+	}
+	return marshalResponse(resp)
+}
+
+// marshalResponse converts the response to JSON string.
+func marshalResponse(resp ToolResponse) string {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		// Fallback to plain text error if JSON marshaling fails
+		return fmt.Sprintf(`{"ok":false,"summary":"JSON marshaling error","error":"%s"}`, err.Error())
+	}
+	return string(data)
+}
@@ -0,0 +1,208 @@
+// Package codeintel provides type-aware Go code navigation for the agent.
+//
+// Workspace packages are loaded with golang.org/x/tools/go/packages and
+// type-checked with go/types, so queries see grouped declarations, generics
+// and method sets exactly as the compiler does. Loading runs "go list" with
+// GOPROXY=off and never touches the network.
+package codeintel
+
+import (
+	"context"
+	"fmt"
+)
+
+// Supported actions.
+const (
+	ActionDefinition      = "definition"
+	ActionReferences      = "references"
+	ActionImplementations = "implementations"
+	ActionCallers         = "callers"
+	ActionAPI             = "api"
+)
+
+// CodeIntelTool answers definition, reference, implementation, caller and
+// package API queries for Go code.
+type CodeIntelTool struct {
+	workDir    string
+	loader     *loader
+	maxResults int
+}
+
+// NewCodeIntelTool creates a new code intelligence tool. Test files are
+// loaded too, so references and callers in tests are found.
+func NewCodeIntelTool(workDir string) *CodeIntelTool {
+	return &CodeIntelTool{
+		workDir:    workDir,
+		loader:     newLoader(workDir, true),
+		maxResults: 200,
+	}
+}
+
+// Name returns the name of the tool.
+func (t *CodeIntelTool) Name() string {
+	return "code_intel"
+}
+
+// Description returns the description of the tool.
+func (t *CodeIntelTool) Description() string {
+	return "Type-aware Go code navigation: find the definition, references, implementations or callers of a symbol, or list a package's exported API. Results are precise file:line:col positions"
+}
+
+// InputSchema returns the JSON schema for the input.
+func (t *CodeIntelTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{ActionDefinition, ActionReferences, ActionImplementations, ActionCallers, ActionAPI},
+				"description": "Query to run",
+			},
+			"symbol": map[string]interface{}{
+				"type":        "string",
+				"description": "Symbol to query: 'Name', 'pkg.Name', 'Type.Method', 'pkg.Type.Method' or 'import/path.Name'. Required unless position is given (not used by 'api')",
+			},
+			"position": map[string]interface{}{
+				"type":        "string",
+				"description": "Identifier position as 'file:line:col' (relative to work directory), instead of symbol",
+			},
+			"package": map[string]interface{}{
+				"type":        "string",
+				"description": "Package for 'api': import path, directory relative to work directory, or package name",
+			},
+			"max_results": map[string]interface{}{
+				"type":        "integer",
+				"description": "Maximum number of results to return",
+				"default":     200,
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+// Validate checks if the input is valid.
+func (t *CodeIntelTool) Validate(input map[string]interface{}) error {
+	action, ok := input["action"].(string)
+	if !ok {
+		return fmt.Errorf("missing required field: action")
+	}
+
+	switch action {
+	case ActionAPI:
+		if pkg, _ := input["package"].(string); pkg == "" {
+			return fmt.Errorf("package is required for action 'api'")
+		}
+	case ActionDefinition, ActionReferences, ActionImplementations, ActionCallers:
+		symbol, _ := input["symbol"].(string)
+		position, _ := input["position"].(string)
+		if symbol == "" && position == "" {
+			return fmt.Errorf("symbol or position is required for action '%s'", action)
+		}
+		if position != "" {
+			if _, _, _, ok := parsePosition(position); !ok {
+				return fmt.Errorf("position must be 'file:line:col', got %q", position)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid action: %s", action)
+	}
+
+	if maxResults, ok := input["max_results"].(float64); ok && (maxResults < 1 || maxResults > 1000) {
+		return fmt.Errorf("max_results must be between 1 and 1000")
+	}
+
+	return nil
+}
+
+// Execute runs the requested query.
+func (t *CodeIntelTool) Execute(ctx context.Context, input map[string]interface{}) (string, error) {
+	if err := t.Validate(input); err != nil {
+		return Error("Invalid input", err), nil
+	}
+
+	action := input["action"].(string)
+	query, _ := input["position"].(string)
+	if query == "" {
+		query, _ = input["symbol"].(string)
+	}
+	if action == ActionAPI {
+		query, _ = input["package"].(string)
+	}
+
+	maxResults := t.maxResults
+	if val, ok := input["max_results"].(float64); ok {
+		maxResults = int(val)
+	}
+
+	prog, err := t.loader.load(ctx)
+	if err != nil {
+		return Error("Failed to load Go packages", err), nil
+	}
+	idx := newIndex(t.workDir, prog)
+
+	results, err := t.run(idx, action, query)
+	if err != nil {
+		return Error(fmt.Sprintf("%s query failed", action), err), nil
+	}
+
+	data := QueryData{
+		Action:     action,
+		Query:      query,
+		Count:      len(results),
+		LoadErrors: idx.loadErrors(5),
+	}
+	if len(results) > maxResults {
+		results = results[:maxResults]
+		data.Truncated = true
+	}
+	data.Results = results
+	if data.Results == nil {
+		data.Results = []Location{}
+	}
+
+	return Success(summarize(action, query, data.Count), data), nil
+}
+
+// run dispatches a query to the index.
+func (t *CodeIntelTool) run(idx *index, action, query string) ([]Location, error) {
+	if action == ActionAPI {
+		pkg, err := idx.findPackage(query)
+		if err != nil {
+			return nil, err
+		}
+		return idx.api(pkg), nil
+	}
+
+	targets, err := idx.resolve(query)
+	if err != nil {
+		return nil, err
+	}
+
+	switch action {
+	case ActionDefinition:
+		return idx.definitions(targets), nil
+	case ActionReferences:
+		return idx.references(targets), nil
+	case ActionImplementations:
+		return idx.implementations(targets)
+	case ActionCallers:
+		return idx.callers(targets)
+	}
+	return nil, fmt.Errorf("invalid action: %s", action)
+}
+
+// summarize builds the human-readable summary of a query result.
+func summarize(action, query string, count int) string {
+	switch action {
+	case ActionDefinition:
+		return fmt.Sprintf("Found %d definition(s) of %s", count, query)
+	case ActionReferences:
+		return fmt.Sprintf("Found %d reference(s) to %s", count, query)
+	case ActionImplementations:
+		return fmt.Sprintf("Found %d implementation(s) related to %s", count, query)
+	case ActionCallers:
+		return fmt.Sprintf("Found %d call site(s) of %s", count, query)
+	default:
+		return fmt.Sprintf("Found %d exported declaration(s) in %s", count, query)
+	}
+}
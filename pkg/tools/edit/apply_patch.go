@@ -0,0 +1,398 @@
+package edit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Zerofisher/goai/pkg/backup"
+	"github.com/Zerofisher/goai/pkg/patch"
+)
+
+// ApplyPatchTool applies a multi-file patch as one transaction: every file
+// is patched in memory first, and the work directory is only changed when
+// all of them apply.
+type ApplyPatchTool struct {
+	workDir string
+	backups *backup.Store
+}
+
+// NewApplyPatchTool creates a new patch tool.
+func NewApplyPatchTool(workDir string) *ApplyPatchTool {
+	return &ApplyPatchTool{
+		workDir: workDir,
+		backups: backup.NewStore(workDir, backup.DefaultRetention),
+	}
+}
+
+// SetBackupStore sets the store backups are saved to.
+func (t *ApplyPatchTool) SetBackupStore(store *backup.Store) {
+	t.backups = store
+}
+
+// Name returns the name of the tool.
+func (t *ApplyPatchTool) Name() string {
+	return "apply_patch"
+}
+
+// Description returns the description of the tool.
+func (t *ApplyPatchTool) Description() string {
+	return "Apply a multi-file patch atomically. Accepts git diff / unified diff format (including new, deleted and renamed files and mode changes) " +
+		"or the envelope format: '*** Begin Patch', then '*** Add File: <path>' (lines prefixed with +), '*** Delete File: <path>' or " +
+		"'*** Update File: <path>' (optionally followed by '*** Move to: <path>') with hunks of ' ', '-' and '+' lines introduced by '@@ <nearby line>', then '*** End Patch'. " +
+		"Hunks tolerate shifted line numbers and small context drift. Either every file is changed or none"
+}
+
+// InputSchema returns the JSON schema for the input.
+func (t *ApplyPatchTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"patch": map[string]interface{}{
+				"type":        "string",
+				"description": "The patch text in git diff or envelope format",
+			},
+			"max_fuzz": map[string]interface{}{
+				"type":        "integer",
+				"description": "Context lines that may be ignored at each end of a hunk that does not apply as written (default: 2)",
+				"minimum":     0,
+				"default":     patch.DefaultMaxFuzz,
+			},
+			"create_backup": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Whether to back up each changed or deleted file (default: true)",
+				"default":     true,
+			},
+		},
+		"required": []string{"patch"},
+	}
+}
+
+// Validate parses the patch and checks its paths without touching any file.
+func (t *ApplyPatchTool) Validate(input map[string]interface{}) error {
+	_, err := t.parse(input)
+	return err
+}
+
+// parse parses the patch and checks that every path stays in the work
+// directory.
+func (t *ApplyPatchTool) parse(input map[string]interface{}) ([]*patch.FilePatch, error) {
+	text, ok := input["patch"].(string)
+	if !ok || strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("patch is required")
+	}
+	if fuzz, ok := input["max_fuzz"]; ok {
+		if f, ok := fuzz.(float64); !ok || f < 0 {
+			return nil, fmt.Errorf("max_fuzz must be a non-negative number")
+		}
+	}
+
+	files, err := patch.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	guard := &EditTool{workDir: t.workDir}
+	seen := make(map[string]bool)
+	for _, fp := range files {
+		if fp.Path() == "" {
+			return nil, fmt.Errorf("patch has a hunk without a file header; add ---/+++ lines or use edit_file with the apply_patch strategy")
+		}
+		paths := []string{fp.OldPath}
+		if fp.NewPath != fp.OldPath {
+			paths = append(paths, fp.NewPath)
+		}
+		for _, p := range paths {
+			if p == "" {
+				continue
+			}
+			if err := guard.validatePath(p); err != nil {
+				return nil, fmt.Errorf("%s: %w", p, err)
+			}
+			if seen[p] {
+				return nil, fmt.Errorf("%s is changed more than once in the patch", p)
+			}
+			seen[p] = true
+		}
+	}
+	return files, nil
+}
+
+// plannedFile is the new state of one file of a patch.
+type plannedFile struct {
+	fp      *patch.FilePatch
+	oldAbs  string
+	newAbs  string
+	content []byte
+	mode    os.FileMode
+	report  PatchFile
+}
+
+// Execute patches every file in memory and then writes them all.
+func (t *ApplyPatchTool) Execute(ctx context.Context, input map[string]interface{}) (string, error) {
+	files, err := t.parse(input)
+	if err != nil {
+		return Error("Invalid patch", err), nil
+	}
+	maxFuzz := patch.DefaultMaxFuzz
+	if f, ok := input["max_fuzz"].(float64); ok {
+		maxFuzz = int(f)
+	}
+	createBackup := true
+	if backupBool, ok := input["create_backup"].(bool); ok {
+		createBackup = backupBool
+	}
+
+	// Work out the result for every file before changing any
+	var plans []*plannedFile
+	var problems []string
+	for _, fp := range files {
+		plan, err := t.plan(fp, maxFuzz)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", fp.Path(), err))
+			continue
+		}
+		plans = append(plans, plan)
+	}
+	if len(problems) > 0 {
+		return Error("Patch does not apply; no files were changed", errors.New(strings.Join(problems, "\n"))), nil
+	}
+	if err := ctx.Err(); err != nil {
+		return Error("Patch canceled; no files were changed", err), nil
+	}
+
+	if createBackup {
+		for _, p := range plans {
+			if p.oldAbs == "" {
+				continue
+			}
+			saved, err := t.backups.Save(p.oldAbs, backupMeta(ctx, t.Name()))
+			if err != nil {
+				return Error("Failed to create backup; no files were changed", err), nil
+			}
+			p.report.BackupID = saved.ID
+		}
+	}
+	if err := commitPlans(plans); err != nil {
+		return Error("Failed to apply patch; changes were rolled back", err), nil
+	}
+
+	result := &PatchResult{Files: make([]PatchFile, 0, len(plans))}
+	counts := make(map[string]int)
+	for _, p := range plans {
+		result.Files = append(result.Files, p.report)
+		result.Added += p.report.Added
+		result.Removed += p.report.Removed
+		counts[p.report.Operation]++
+	}
+	var parts []string
+	for _, op := range []string{patch.OpCreate, patch.OpModify, patch.OpRename, patch.OpDelete} {
+		if counts[op] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[op], pastTense(op)))
+		}
+	}
+	summary := fmt.Sprintf("Applied patch to %d file(s): %s (+%d -%d)", len(plans), strings.Join(parts, ", "), result.Added, result.Removed)
+	return Success(summary, result), nil
+}
+
+// plan computes the new content and mode of the file changed by fp.
+func (t *ApplyPatchTool) plan(fp *patch.FilePatch, maxFuzz int) (*plannedFile, error) {
+	p := &plannedFile{fp: fp}
+	p.report = PatchFile{Path: fp.Path(), Operation: fp.Op(), Hunks: len(fp.Hunks)}
+	p.report.Added, p.report.Removed = fp.Stats()
+
+	var original string
+	if fp.OldPath != "" {
+		p.oldAbs = t.resolvePath(fp.OldPath)
+		info, err := os.Stat(p.oldAbs)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("file does not exist")
+			}
+			return nil, err
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("is a directory")
+		}
+		data, err := os.ReadFile(p.oldAbs)
+		if err != nil {
+			return nil, err
+		}
+		original = string(data)
+		p.mode = info.Mode().Perm()
+	}
+	if fp.NewPath != "" {
+		p.newAbs = t.resolvePath(fp.NewPath)
+		if fp.Op() == patch.OpCreate || fp.Op() == patch.OpRename {
+			if _, err := os.Stat(p.newAbs); err == nil {
+				return nil, fmt.Errorf("%s already exists", fp.NewPath)
+			}
+		}
+	}
+	if fp.Op() == patch.OpRename {
+		p.report.OldPath = fp.OldPath
+	}
+	if fp.Op() == patch.OpDelete {
+		return p, nil
+	}
+
+	switch {
+	case fp.NewMode != 0:
+		p.mode = fp.NewMode
+	case fp.Op() == patch.OpCreate:
+		p.mode = 0644
+	}
+	if fp.NewMode != 0 && (fp.OldMode == 0 || fp.NewMode != fp.OldMode) {
+		p.report.Mode = fmt.Sprintf("%04o", fp.NewMode)
+	}
+
+	content, hunks, err := patch.Apply(original, fp.Hunks, maxFuzz)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range hunks {
+		if !h.Exact() {
+			p.report.Fuzzy = append(p.report.Fuzzy, h.String())
+		}
+	}
+	p.content = []byte(content)
+	return p, nil
+}
+
+// resolvePath converts a relative path to an absolute path within the work directory.
+func (t *ApplyPatchTool) resolvePath(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(t.workDir, path)
+}
+
+// priorState is a file's state before the patch, for rollback.
+type priorState struct {
+	path    string
+	existed bool
+	content []byte
+	mode    os.FileMode
+}
+
+// commitPlans writes every planned file. If a step fails, every path
+// already touched is put back as it was and the directories created for
+// new files are removed.
+func commitPlans(plans []*plannedFile) error {
+	var touched []priorState
+	var created []string // Directories, outermost first
+	remember := func(path string) error {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			touched = append(touched, priorState{path: path})
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		touched = append(touched, priorState{path: path, existed: true, content: content, mode: info.Mode().Perm()})
+		return nil
+	}
+	rollback := func() {
+		for i := len(touched) - 1; i >= 0; i-- {
+			s := touched[i]
+			if !s.existed {
+				_ = os.Remove(s.path)
+				continue
+			}
+			_ = writeFileAtomic(s.path, s.content, s.mode)
+		}
+		for i := len(created) - 1; i >= 0; i-- {
+			_ = os.Remove(created[i]) // Fails, as it should, if something else was put there
+		}
+	}
+
+	for _, p := range plans {
+		for _, path := range []string{p.oldAbs, p.newAbs} {
+			if path == "" {
+				continue
+			}
+			if err := remember(path); err != nil {
+				rollback()
+				return err
+			}
+		}
+
+		var err error
+		if p.newAbs != "" {
+			var dirs []string
+			dirs, err = mkdirAll(filepath.Dir(p.newAbs))
+			created = append(created, dirs...)
+			if err == nil {
+				err = writeFileAtomic(p.newAbs, p.content, p.mode)
+			}
+		}
+		if err == nil && p.oldAbs != "" && p.oldAbs != p.newAbs {
+			err = os.Remove(p.oldAbs)
+		}
+		if err != nil {
+			rollback()
+			return fmt.Errorf("%s: %w", p.fp.Path(), err)
+		}
+	}
+	return nil
+}
+
+// mkdirAll creates dir and its missing parents and returns the directories
+// it had to create, outermost first. They are returned on failure too, as
+// some of them may exist.
+func mkdirAll(dir string) ([]string, error) {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); !os.IsNotExist(err) {
+			break
+		}
+		missing = append([]string{d}, missing...)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	return missing, os.MkdirAll(dir, 0755)
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the
+// same directory.
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".apply_patch-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), mode)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// pastTense names an operation for the summary.
+func pastTense(op string) string {
+	switch op {
+	case patch.OpCreate:
+		return "created"
+	case patch.OpDelete:
+		return "deleted"
+	case patch.OpRename:
+		return "renamed"
+	}
+	return "modified"
+}
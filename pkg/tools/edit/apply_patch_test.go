@@ -0,0 +1,184 @@
+package edit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Zerofisher/goai/pkg/patch"
+)
+
+// runPatch executes apply_patch and decodes the response.
+func runPatch(t *testing.T, dir, text string) ToolResponse {
+	t.Helper()
+	result, err := NewApplyPatchTool(dir).Execute(context.Background(), map[string]interface{}{"patch": text})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp ToolResponse
+	if err := json.Unmarshal([]byte(result), &resp); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	return resp
+}
+
+// TestApplyPatchMultiFile tests creating, changing, renaming and deleting files in one patch.
+func TestApplyPatchMultiFile(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "main.go", "package main\n\nvar x = 1\n")
+	writeTestFile(t, dir, "old.txt", "bye\n")
+	writeTestFile(t, dir, "run.sh", "echo hi\n")
+
+	resp := runPatch(t, dir, `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ package main
+
+-var x = 1
++var x = 2
+diff --git a/docs/new.md b/docs/new.md
+new file mode 100644
+--- /dev/null
++++ b/docs/new.md
+@@ -0,0 +1 @@
++# New
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/run.sh b/scripts/run.sh
+old mode 100644
+new mode 100755
+similarity index 100%
+rename from run.sh
+rename to scripts/run.sh
+`)
+	if !resp.Ok {
+		t.Fatalf("apply_patch failed: %s", resp.Error)
+	}
+	if !strings.Contains(resp.Summary, "1 created, 1 modified, 1 renamed, 1 deleted") {
+		t.Errorf("unexpected summary: %s", resp.Summary)
+	}
+
+	for name, want := range map[string]string{
+		"main.go":        "package main\n\nvar x = 2\n",
+		"docs/new.md":    "# New\n",
+		"scripts/run.sh": "echo hi\n",
+		"old.txt":        "<missing>",
+		"run.sh":         "<missing>",
+	} {
+		if got := readTestFile(t, dir, name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dir, "scripts/run.sh")); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("expected scripts/run.sh to be executable, got %v", info.Mode())
+	}
+
+	data := resp.Data.(map[string]interface{})
+	files := data["files"].([]interface{})
+	renamed := files[3].(map[string]interface{})
+	if renamed["old_path"] != "run.sh" || renamed["mode"] != "0755" || renamed["backup_id"] == nil {
+		t.Errorf("unexpected report for the rename: %+v", renamed)
+	}
+}
+
+// TestApplyPatchAtomic tests that nothing changes when one file does not apply.
+func TestApplyPatchAtomic(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "a.txt", "one\ntwo\n")
+	writeTestFile(t, dir, "b.txt", "three\n")
+
+	resp := runPatch(t, dir, `*** Begin Patch
+*** Update File: a.txt
+ one
+-two
++TWO
+*** Add File: c.txt
++new
+*** Update File: b.txt
+-four
++FOUR
+*** End Patch`)
+	if resp.Ok {
+		t.Fatal("expected the patch to fail")
+	}
+	if !strings.Contains(resp.Error, "b.txt:") || !strings.Contains(resp.Summary, "no files were changed") {
+		t.Errorf("unexpected error: %s / %s", resp.Summary, resp.Error)
+	}
+	if got := readTestFile(t, dir, "a.txt"); got != "one\ntwo\n" {
+		t.Errorf("a.txt changed: %q", got)
+	}
+	if got := readTestFile(t, dir, "c.txt"); got != "<missing>" {
+		t.Errorf("c.txt created: %q", got)
+	}
+}
+
+// TestCommitPlansRollback tests that a failed write undoes earlier writes
+// and removes the directories created for them.
+func TestCommitPlansRollback(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "a.txt", "one\n")
+	plans := []*plannedFile{
+		{fp: &patch.FilePatch{OldPath: "a.txt", NewPath: "a.txt"}, oldAbs: filepath.Join(dir, "a.txt"), newAbs: filepath.Join(dir, "a.txt"), content: []byte("ONE\n"), mode: 0644},
+		{fp: &patch.FilePatch{NewPath: "new/deep/b.txt"}, newAbs: filepath.Join(dir, "new", "deep", "b.txt"), content: []byte("b\n"), mode: 0644},
+		// A file cannot be created below a regular file
+		{fp: &patch.FilePatch{NewPath: "a.txt/c.txt"}, newAbs: filepath.Join(dir, "a.txt", "c.txt"), content: []byte("c\n"), mode: 0644},
+	}
+	err := commitPlans(plans)
+	if err == nil || !strings.Contains(err.Error(), "a.txt/c.txt") {
+		t.Fatalf("expected the last write to fail, got %v", err)
+	}
+	if got := readTestFile(t, dir, "a.txt"); got != "one\n" {
+		t.Errorf("a.txt not restored: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new")); !os.IsNotExist(err) {
+		t.Errorf("expected the created directories to be removed, got %v", err)
+	}
+}
+
+// TestApplyPatchValidate tests rejecting unsafe or inconsistent patches.
+func TestApplyPatchValidate(t *testing.T) {
+	tool := NewApplyPatchTool(t.TempDir())
+	for _, text := range []string{
+		"",
+		"*** Begin Patch\n*** Add File: ../escape.txt\n+x\n*** End Patch",
+		"*** Begin Patch\n*** Delete File: a.txt\n*** Delete File: a.txt\n*** End Patch",
+		"@@ -1 +1 @@\n-a\n+b\n",
+	} {
+		if err := tool.Validate(map[string]interface{}{"patch": text}); err == nil {
+			t.Errorf("expected validation error for %q", text)
+		}
+	}
+}
+
+// writeTestFile writes content to dir/name.
+func writeTestFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readTestFile returns the content of dir/name, or "<missing>".
+func readTestFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return "<missing>"
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
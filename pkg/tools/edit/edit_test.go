@@ -33,8 +33,8 @@ func TestReplaceStrategy(t *testing.T) {
 	input := map[string]interface{}{
 		"path":     "test.txt",
 		"strategy": "replace",
-		"old_text": "World",
-		"new_text": "Earth",
+		"old_text": "Hello World",
+		"new_text": "Hello Earth",
 	}
 
 	result, err := tool.Execute(ctx, input)
@@ -375,6 +375,50 @@ func TestBackupAndRestore(t *testing.T) {
 	}
 }
 
+// TestBackupManager tests the deprecated wrapper over the backup store.
+func TestBackupManager(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "sub", "test.txt")
+	if err := os.MkdirAll(filepath.Dir(testFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(testFile, []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewBackupManager(tempDir)
+	backupPath, err := m.AutoBackup(testFile, "edit_file")
+	if err != nil {
+		t.Fatalf("AutoBackup failed: %v", err)
+	}
+
+	latest, err := m.GetLatestBackup("test.txt")
+	if err != nil || latest.Path != backupPath || latest.OriginalFile != "sub/test.txt" {
+		t.Fatalf("GetLatestBackup = %+v, %v", latest, err)
+	}
+	meta, err := m.GetBackupMetadata(backupPath)
+	if err != nil || meta["Operation"] != "edit_file" {
+		t.Errorf("GetBackupMetadata = %v, %v", meta, err)
+	}
+
+	if err := os.WriteFile(testFile, []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RestoreBackup(backupPath, testFile); err != nil {
+		t.Fatalf("RestoreBackup failed: %v", err)
+	}
+	if content, _ := os.ReadFile(testFile); string(content) != "v1" {
+		t.Errorf("restored %q, want v1", content)
+	}
+
+	if err := m.DeleteBackup(backupPath); err != nil {
+		t.Fatalf("DeleteBackup failed: %v", err)
+	}
+	if backups, err := m.ListBackups("test.txt"); err != nil || len(backups) != 0 {
+		t.Errorf("ListBackups after delete = %v, %v", backups, err)
+	}
+}
+
 // TestConflictDetection tests conflict detection.
 func TestConflictDetection(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "edit_test_*")
@@ -386,7 +430,7 @@ func TestConflictDetection(t *testing.T) {
 	}()
 
 	testFile := filepath.Join(tempDir, "test.txt")
-	content := "foo bar foo baz foo"
+	content := "# BEGIN\nfoo\n# END\n# BEGIN\nbar\n# END"
 	if err := os.WriteFile(testFile, []byte(content), 0644); err != nil {
 		t.Fatal(err)
 	}
@@ -394,12 +438,14 @@ func TestConflictDetection(t *testing.T) {
 	tool := NewEditTool(tempDir)
 	ctx := context.Background()
 
-	// Replace without replace_all should detect conflict
+	// An anchor that is not unique should be reported as a conflict
 	input := map[string]interface{}{
 		"path":             "test.txt",
-		"strategy":         "replace",
+		"strategy":         "anchored",
 		"old_text":         "foo",
 		"new_text":         "FOO",
+		"before_anchor":    "# BEGIN",
+		"after_anchor":     "# END",
 		"detect_conflicts": true,
 	}
 
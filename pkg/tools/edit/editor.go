@@ -6,30 +6,41 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/Zerofisher/goai/pkg/backup"
+	"github.com/Zerofisher/goai/pkg/tools"
 )
 
 // EditTool implements text file editing functionality with multiple strategies.
 type EditTool struct {
 	workDir    string
-	backup     *BackupManager
+	backups    *backup.Store
 	strategies map[string]Strategy
 }
 
 // NewEditTool creates a new text editing tool.
 func NewEditTool(workDir string) *EditTool {
-	tool := &EditTool{
+	return &EditTool{
 		workDir:    workDir,
-		backup:     NewBackupManager(workDir),
-		strategies: make(map[string]Strategy),
+		backups:    backup.NewStore(workDir, backup.DefaultRetention),
+		strategies: newStrategies(),
 	}
+}
 
-	// Register available strategies
-	tool.strategies["replace"] = NewReplaceStrategy()
-	tool.strategies["insert"] = NewInsertStrategy()
-	tool.strategies["anchored"] = NewAnchoredStrategy()
-	tool.strategies["apply_patch"] = NewApplyPatchStrategy()
+// SetBackupStore sets the store backups are saved to, so tools can share
+// one store and its retention policy.
+func (t *EditTool) SetBackupStore(store *backup.Store) {
+	t.backups = store
+}
 
-	return tool
+// newStrategies returns the available edit strategies by name.
+func newStrategies() map[string]Strategy {
+	return map[string]Strategy{
+		"replace":     NewReplaceStrategy(),
+		"insert":      NewInsertStrategy(),
+		"anchored":    NewAnchoredStrategy(),
+		"apply_patch": NewApplyPatchStrategy(),
+	}
 }
 
 // Name returns the name of the tool.
@@ -60,7 +71,7 @@ func (t *EditTool) InputSchema() map[string]interface{} {
 			// Replace strategy parameters
 			"old_text": map[string]interface{}{
 				"type":        "string",
-				"description": "Text to replace (for replace and anchored strategies)",
+				"description": "Text to replace (for replace and anchored strategies). For replace, whole lines also match when line endings, whitespace or the indentation of the block differ; new_text is then re-indented to fit",
 			},
 			"new_text": map[string]interface{}{
 				"type":        "string",
@@ -178,15 +189,13 @@ func (t *EditTool) Execute(ctx context.Context, input map[string]interface{}) (s
 	}
 
 	// Create backup if requested
-	var backupPath string
-	var backupCreated bool
+	var saved *backup.Entry
 	if createBackup {
 		var err error
-		backupPath, err = t.backup.CreateBackup(absPath)
+		saved, err = t.backups.Save(absPath, backupMeta(ctx, t.Name()))
 		if err != nil {
 			return Error("Failed to create backup", err), nil
 		}
-		backupCreated = true
 	}
 
 	// Detect conflicts if requested
@@ -211,26 +220,32 @@ func (t *EditTool) Execute(ctx context.Context, input map[string]interface{}) (s
 	result, err := strategyImpl.Execute(absPath, input)
 	if err != nil {
 		// Restore from backup if operation failed and backup was created
-		if backupPath != "" {
-			if restoreErr := t.backup.RestoreBackup(backupPath, absPath); restoreErr != nil {
+		if saved != nil {
+			if _, restoreErr := t.backups.Restore(saved.ID, absPath); restoreErr != nil {
 				return Error("Operation failed and backup restore failed",
 					fmt.Errorf("edit error: %v, restore error: %w", err, restoreErr)), nil
 			}
 			// Clean up failed backup
-			_ = os.Remove(backupPath)
+			_ = t.backups.Delete(saved.ID)
 		}
 		return Error("Edit operation failed", err), nil
 	}
 
 	// Update result with backup info
-	result.BackupCreated = backupCreated
-	result.BackupPath = backupPath
+	if saved != nil {
+		result.BackupCreated = true
+		result.BackupID = saved.ID
+	}
 	result.Conflicts = conflicts
 
 	// Generate summary
 	summary := fmt.Sprintf("Successfully edited %s using %s strategy (%d lines modified)",
 		path, strategy, result.LinesModified)
 
+	if note := describeTier(result.Match); note != "" {
+		summary += " [" + note + "]"
+	}
+
 	if len(conflicts) > 0 {
 		summary += fmt.Sprintf(" [%d potential conflicts detected]", len(conflicts))
 	}
@@ -318,29 +333,8 @@ func (t *EditTool) detectConflicts(path string, input map[string]interface{}, st
 
 	contentStr := string(content)
 
+	// The replace strategy refuses old_text found more than once itself
 	switch strategy {
-	case "replace":
-		oldText, hasOldText := input["old_text"].(string)
-		if !hasOldText {
-			return conflicts
-		}
-
-		// Check for multiple occurrences if not replace_all
-		replaceAll := false
-		if replaceAllRaw, ok := input["replace_all"]; ok {
-			if replaceAllBool, ok := replaceAllRaw.(bool); ok {
-				replaceAll = replaceAllBool
-			}
-		}
-
-		if !replaceAll {
-			count := strings.Count(contentStr, oldText)
-			if count > 1 {
-				conflicts = append(conflicts,
-					fmt.Sprintf("Multiple occurrences found (%d), but replace_all=false. Only first will be replaced.", count))
-			}
-		}
-
 	case "anchored":
 		// Check if anchors are unique
 		if beforeAnchor, ok := input["before_anchor"].(string); ok {
@@ -366,3 +360,9 @@ func (t *EditTool) detectConflicts(path string, input map[string]interface{}, st
 
 	return conflicts
 }
+
+// backupMeta labels a backup with the tool call that made it.
+func backupMeta(ctx context.Context, operation string) backup.Meta {
+	info := tools.CallInfoFrom(ctx)
+	return backup.Meta{Operation: operation, ToolUseID: info.ToolUseID, Session: info.SessionID}
+}
@@ -0,0 +1,321 @@
+package edit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Match tiers, from strictest to most tolerant. ReplaceStrategy tries them
+// in this order and reports the first that finds old_text.
+const (
+	MatchExact       = "exact"        // Byte-for-byte
+	MatchLineEndings = "line_endings" // CRLF and LF treated alike
+	MatchWhitespace  = "whitespace"   // Runs of spaces and tabs, and trailing whitespace, ignored
+	MatchIndentation = "indentation"  // Whole block indented by a different amount
+)
+
+// tabWidth is the width of a tab when indentation is compared.
+const tabWidth = 4
+
+// region is a byte range of the file matched by old_text.
+type region struct {
+	start, end int
+	delta      int // Indentation added to new_text, in columns
+}
+
+// textMatch is the result of locating old_text in a file.
+type textMatch struct {
+	tier    string
+	regions []region
+}
+
+// fileLine is one line of a file; end excludes the line terminator.
+type fileLine struct {
+	start, end, next int
+	text             string
+}
+
+// findText locates oldText in content using the first tier that matches.
+// The whitespace and indentation tiers compare whole lines. It returns nil
+// if no tier matches.
+func findText(content, oldText string) *textMatch {
+	if oldText == "" {
+		return nil
+	}
+	if n := strings.Count(content, oldText); n > 0 {
+		m := &textMatch{tier: MatchExact}
+		offset := 0
+		for i := 0; i < n; i++ {
+			idx := strings.Index(content[offset:], oldText)
+			m.regions = append(m.regions, region{start: offset + idx, end: offset + idx + len(oldText)})
+			offset += idx + len(oldText)
+		}
+		return m
+	}
+	if regions := findNormalized(content, oldText); len(regions) > 0 {
+		return &textMatch{tier: MatchLineEndings, regions: regions}
+	}
+
+	lines := splitLines(content)
+	want := strings.Split(strings.ReplaceAll(oldText, "\r\n", "\n"), "\n")
+	trailingNewline := len(want) > 1 && want[len(want)-1] == ""
+	if trailingNewline {
+		want = want[:len(want)-1]
+	}
+	if strings.TrimSpace(strings.Join(want, "")) == "" {
+		return nil
+	}
+
+	for _, tier := range []string{MatchWhitespace, MatchIndentation} {
+		var regions []region
+		for i := 0; i+len(want) <= len(lines); {
+			delta, ok := matchLines(lines[i:i+len(want)], want, tier)
+			if !ok {
+				i++
+				continue
+			}
+			last := lines[i+len(want)-1]
+			r := region{start: lines[i].start, end: last.end, delta: delta}
+			if trailingNewline {
+				r.end = last.next
+			}
+			regions = append(regions, r)
+			i += len(want)
+		}
+		if len(regions) > 0 {
+			return &textMatch{tier: tier, regions: regions}
+		}
+	}
+	return nil
+}
+
+// findNormalized finds oldText in content with all line endings treated as
+// LF, returning regions of the original content.
+func findNormalized(content, oldText string) []region {
+	if !strings.Contains(content, "\r\n") && !strings.Contains(oldText, "\r\n") {
+		return nil
+	}
+
+	// startOf and endOf map each byte of the normalized content back to
+	// the original; a CRLF newline starts at its \r and ends after its \n
+	var normalized strings.Builder
+	var startOf, endOf []int
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\r' && i+1 < len(content) && content[i+1] == '\n' {
+			normalized.WriteByte('\n')
+			startOf = append(startOf, i)
+			endOf = append(endOf, i+2)
+			i++
+			continue
+		}
+		normalized.WriteByte(content[i])
+		startOf = append(startOf, i)
+		endOf = append(endOf, i+1)
+	}
+
+	haystack := normalized.String()
+	needle := strings.ReplaceAll(oldText, "\r\n", "\n")
+	var regions []region
+	for offset := 0; ; {
+		idx := strings.Index(haystack[offset:], needle)
+		if idx < 0 {
+			break
+		}
+		s := offset + idx
+		e := s + len(needle)
+		regions = append(regions, region{start: startOf[s], end: endOf[e-1]})
+		offset = e
+	}
+	return regions
+}
+
+// matchLines compares a window of file lines with the lines of old_text.
+// For the indentation tier it returns the indentation the file adds to
+// every non-blank line.
+func matchLines(lines []fileLine, want []string, tier string) (int, bool) {
+	delta, seen := 0, false
+	for i, line := range lines {
+		if collapse(line.text) != collapse(want[i]) {
+			return 0, false
+		}
+		if collapse(want[i]) == "" {
+			continue
+		}
+		d := indentWidth(line.text) - indentWidth(want[i])
+		switch {
+		case tier == MatchWhitespace && d != 0:
+			return 0, false
+		case !seen:
+			delta, seen = d, true
+		case d != delta:
+			return 0, false
+		}
+	}
+	return delta, true
+}
+
+// adaptText rewrites newText for a tolerant match: line endings follow the
+// file, and after whitespace or indentation matches, leading indentation
+// is shifted by delta and written in the file's indentation style.
+func adaptText(newText, content, matched, tier string, delta int) string {
+	text := strings.ReplaceAll(newText, "\r\n", "\n")
+	if tier == MatchWhitespace || tier == MatchIndentation {
+		useTabs := usesTabs(matched)
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			width := indentWidth(line) + delta
+			if width < 0 {
+				width = 0
+			}
+			lines[i] = renderIndent(width, useTabs) + strings.TrimLeft(line, " \t")
+		}
+		text = strings.Join(lines, "\n")
+	}
+	if strings.Count(content, "\r\n")*2 > strings.Count(content, "\n") {
+		text = strings.ReplaceAll(text, "\n", "\r\n")
+	}
+	return text
+}
+
+// describeTier explains a tolerant match for the edit summary.
+func describeTier(tier string) string {
+	switch tier {
+	case MatchLineEndings:
+		return "matched ignoring line endings"
+	case MatchWhitespace:
+		return "matched ignoring whitespace"
+	case MatchIndentation:
+		return "matched ignoring indentation"
+	}
+	return ""
+}
+
+// closestRegion finds the block of lines most similar to oldText and
+// describes it with its similarity for a "text not found" error.
+func closestRegion(content, oldText string) string {
+	lines := splitLines(content)
+	want := strings.Split(strings.TrimRight(strings.ReplaceAll(oldText, "\r\n", "\n"), "\n"), "\n")
+	if len(lines) == 0 || len(want) > len(lines) {
+		return ""
+	}
+
+	target := bigrams(collapse(strings.Join(want, "\n")))
+	best, bestScore := -1, 0.0
+	for i := 0; i+len(want) <= len(lines); i++ {
+		var window []string
+		for _, line := range lines[i : i+len(want)] {
+			window = append(window, line.text)
+		}
+		if score := dice(target, bigrams(collapse(strings.Join(window, "\n")))); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 || bestScore < 0.3 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "closest match at lines %d-%d (similarity %.2f):", best+1, best+len(want), bestScore)
+	for i, line := range lines[best : best+len(want)] {
+		fmt.Fprintf(&b, "\n%6d\t%s", best+i+1, line.text)
+	}
+	return b.String()
+}
+
+// splitLines splits content into lines, recording their offsets.
+func splitLines(content string) []fileLine {
+	var lines []fileLine
+	for start := 0; start < len(content); {
+		end := strings.IndexByte(content[start:], '\n')
+		next := len(content)
+		if end < 0 {
+			end = len(content)
+		} else {
+			end += start
+			next = end + 1
+		}
+		textEnd := end
+		if textEnd > start && content[textEnd-1] == '\r' {
+			textEnd--
+		}
+		lines = append(lines, fileLine{start: start, end: textEnd, next: next, text: content[start:textEnd]})
+		start = next
+	}
+	return lines
+}
+
+// collapse removes leading and trailing whitespace and turns inner runs of
+// whitespace into single spaces.
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// indentWidth returns the width of the leading whitespace of line.
+func indentWidth(line string) int {
+	width := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			width++
+		case '\t':
+			width += tabWidth
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// usesTabs reports whether the indented lines of text use tabs.
+func usesTabs(text string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "\t") {
+			return true
+		}
+		if strings.HasPrefix(line, " ") {
+			return false
+		}
+	}
+	return false
+}
+
+// renderIndent writes width columns of indentation.
+func renderIndent(width int, useTabs bool) string {
+	if !useTabs {
+		return strings.Repeat(" ", width)
+	}
+	return strings.Repeat("\t", width/tabWidth) + strings.Repeat(" ", width%tabWidth)
+}
+
+// bigrams counts the character pairs of s.
+func bigrams(s string) map[string]int {
+	counts := make(map[string]int)
+	runes := []rune(s)
+	for i := 0; i+1 < len(runes); i++ {
+		counts[string(runes[i:i+2])]++
+	}
+	return counts
+}
+
+// dice returns the Sørensen–Dice coefficient of two bigram sets.
+func dice(a, b map[string]int) float64 {
+	total, shared := 0, 0
+	for k, n := range a {
+		total += n
+		if m := b[k]; m < n {
+			shared += m
+		} else {
+			shared += n
+		}
+	}
+	for _, n := range b {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	return 2 * float64(shared) / float64(total)
+}
@@ -0,0 +1,153 @@
+package edit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestReplaceMatchTiers tests that replace falls back through the match tiers.
+func TestReplaceMatchTiers(t *testing.T) {
+	goFile := "func f() {\n\tif x {\n\t\treturn 1\n\t}\n\treturn 0\n}\n"
+
+	tests := []struct {
+		name       string
+		content    string
+		oldText    string
+		newText    string
+		replaceAll bool
+		wantTier   string
+		want       string
+		wantErr    string
+	}{
+		{
+			name:     "exact",
+			content:  goFile,
+			oldText:  "return 1",
+			newText:  "return 2",
+			wantTier: MatchExact,
+			want:     strings.Replace(goFile, "return 1", "return 2", 1),
+		},
+		{
+			name:     "crlf file with lf old_text",
+			content:  "a\r\nb\r\nc\r\n",
+			oldText:  "a\nb\n",
+			newText:  "x\ny\n",
+			wantTier: MatchLineEndings,
+			want:     "x\r\ny\r\nc\r\n",
+		},
+		{
+			name:     "trailing spaces and spaces for tabs",
+			content:  goFile,
+			oldText:  "    if x {  \n        return 1\n    }",
+			newText:  "    if y {\n        return 3\n    }",
+			wantTier: MatchWhitespace,
+			want:     "func f() {\n\tif y {\n\t\treturn 3\n\t}\n\treturn 0\n}\n",
+		},
+		{
+			name:     "dedented block",
+			content:  goFile,
+			oldText:  "if x {\n\treturn 1\n}\n",
+			newText:  "if x {\n\tlog()\n\treturn 1\n}\n",
+			wantTier: MatchIndentation,
+			want:     "func f() {\n\tif x {\n\t\tlog()\n\t\treturn 1\n\t}\n\treturn 0\n}\n",
+		},
+		{
+			name:    "ambiguous exact match",
+			content: "a:\n  x = 1\nb:\n  x = 1\n",
+			oldText: "x = 1",
+			newText: "x = 2",
+			wantErr: "old_text matches 2 locations;",
+		},
+		{
+			name:       "ambiguous exact match with replace_all",
+			content:    "a:\n  x = 1\nb:\n  x = 1\n",
+			oldText:    "x = 1",
+			newText:    "x = 2",
+			replaceAll: true,
+			wantTier:   MatchExact,
+			want:       "a:\n  x = 2\nb:\n  x = 2\n",
+		},
+		{
+			name:    "ambiguous tolerant match",
+			content: "a:\n  x = 1\nb:\n  x = 1\n",
+			oldText: "x  =  1",
+			newText: "x = 2",
+			wantErr: "matches 2 locations",
+		},
+		{
+			name:       "ambiguous tolerant match with replace_all",
+			content:    "a:\n  x = 1\nb:\n  x = 1\n",
+			oldText:    "x  =  1",
+			newText:    "x = 2",
+			replaceAll: true,
+			wantTier:   MatchIndentation,
+			want:       "a:\n  x = 2\nb:\n  x = 2\n",
+		},
+		{
+			name:    "closest candidate",
+			content: goFile,
+			oldText: "if x {\n\t\treturn 10\n\t}",
+			newText: "",
+			wantErr: "closest match at lines 2-4 (similarity",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "f.go")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := NewReplaceStrategy().Execute(path, map[string]interface{}{
+				"old_text":    tt.oldText,
+				"new_text":    tt.newText,
+				"replace_all": tt.replaceAll,
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Match != tt.wantTier {
+				t.Errorf("Match = %q, want %q", result.Match, tt.wantTier)
+			}
+			if got, _ := os.ReadFile(path); string(got) != tt.want {
+				t.Errorf("Content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEditToolReportsTier tests that edit_file reports tolerant matches.
+func TestEditToolReportsTier(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello   world\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, _ := NewEditTool(dir).Execute(context.Background(), map[string]interface{}{
+		"path":     "a.txt",
+		"strategy": "replace",
+		"old_text": "hello world",
+		"new_text": "hello there",
+	})
+	var resp ToolResponse
+	if err := json.Unmarshal([]byte(result), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Ok || !strings.Contains(resp.Summary, "matched ignoring whitespace") {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if data, _ := resp.Data.(map[string]interface{}); data["match"] != MatchWhitespace {
+		t.Errorf("match = %v, want %q", data["match"], MatchWhitespace)
+	}
+}
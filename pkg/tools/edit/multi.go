@@ -0,0 +1,299 @@
+package edit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Zerofisher/goai/pkg/backup"
+)
+
+// MultiEditTool applies a list of edits across files as one transaction:
+// every edit is validated first, all of them are applied to staged copies,
+// and the originals are only replaced when every edit succeeded.
+type MultiEditTool struct {
+	workDir    string
+	backups    *backup.Store
+	strategies map[string]Strategy
+	diff       *DiffGenerator
+}
+
+// NewMultiEditTool creates a new multi-file edit tool.
+func NewMultiEditTool(workDir string) *MultiEditTool {
+	return &MultiEditTool{
+		workDir:    workDir,
+		backups:    backup.NewStore(workDir, backup.DefaultRetention),
+		strategies: newStrategies(),
+		diff:       NewDiffGenerator(),
+	}
+}
+
+// SetBackupStore sets the store backups are saved to.
+func (t *MultiEditTool) SetBackupStore(store *backup.Store) {
+	t.backups = store
+}
+
+// Name returns the name of the tool.
+func (t *MultiEditTool) Name() string {
+	return "multi_edit"
+}
+
+// Description returns the description of the tool.
+func (t *MultiEditTool) Description() string {
+	return "Apply several edits across one or more files atomically: all edits are validated first and either all are applied or none. Each edit takes the same parameters as edit_file. Returns one combined diff"
+}
+
+// InputSchema returns the JSON schema for the input.
+func (t *MultiEditTool) InputSchema() map[string]interface{} {
+	// Each edit accepts the edit_file parameters except the common options
+	editProps := map[string]interface{}{}
+	for name, prop := range NewEditTool(t.workDir).InputSchema()["properties"].(map[string]interface{}) {
+		if name != "create_backup" && name != "detect_conflicts" {
+			editProps[name] = prop
+		}
+	}
+
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"edits": map[string]interface{}{
+				"type":        "array",
+				"description": "Edits to apply in order; later edits to the same file see the result of earlier ones",
+				"minItems":    1,
+				"items": map[string]interface{}{
+					"type":       "object",
+					"properties": editProps,
+					"required":   []string{"path", "strategy"},
+				},
+			},
+			"create_backup": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Whether to back up each file before editing (default: true)",
+				"default":     true,
+			},
+		},
+		"required": []string{"edits"},
+	}
+}
+
+// Validate checks every edit without touching any file.
+func (t *MultiEditTool) Validate(input map[string]interface{}) error {
+	_, err := t.parseEdits(input)
+	return err
+}
+
+// multiEdit is one validated edit of a transaction.
+type multiEdit struct {
+	path     string // absolute
+	rel      string
+	strategy Strategy
+	params   map[string]interface{}
+}
+
+// stagedFile is a file being edited in a transaction.
+type stagedFile struct {
+	path     string
+	rel      string
+	original []byte
+	mode     os.FileMode
+	temp     string
+	edits    int
+	lines    int
+	backup   int
+}
+
+// parseEdits validates the edits and resolves their paths.
+func (t *MultiEditTool) parseEdits(input map[string]interface{}) ([]multiEdit, error) {
+	raw, ok := input["edits"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("edits must be an array of edit objects")
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("edits cannot be empty")
+	}
+
+	edits := make([]multiEdit, 0, len(raw))
+	for i, item := range raw {
+		params, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("edit %d: must be an object", i+1)
+		}
+		path, _ := params["path"].(string)
+		if err := (&EditTool{workDir: t.workDir}).validatePath(path); err != nil {
+			return nil, fmt.Errorf("edit %d: %w", i+1, err)
+		}
+		name, _ := params["strategy"].(string)
+		if name == "" {
+			name = "replace"
+		}
+		strategy, ok := t.strategies[name]
+		if !ok {
+			return nil, fmt.Errorf("edit %d: invalid strategy: %s", i+1, name)
+		}
+		if err := strategy.Validate(params); err != nil {
+			return nil, fmt.Errorf("edit %d (%s): %w", i+1, path, err)
+		}
+
+		abs := t.resolvePath(path)
+		info, err := os.Stat(abs)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("edit %d: file does not exist: %s", i+1, path)
+			}
+			return nil, fmt.Errorf("edit %d: %w", i+1, err)
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("edit %d: %s is a directory", i+1, path)
+		}
+
+		rel, err := filepath.Rel(t.workDir, abs)
+		if err != nil {
+			rel = path
+		}
+		edits = append(edits, multiEdit{path: abs, rel: filepath.ToSlash(rel), strategy: strategy, params: params})
+	}
+	return edits, nil
+}
+
+// Execute validates, stages and commits all edits.
+func (t *MultiEditTool) Execute(ctx context.Context, input map[string]interface{}) (string, error) {
+	edits, err := t.parseEdits(input)
+	if err != nil {
+		return Error("Invalid edits", err), nil
+	}
+
+	createBackup := true
+	if backupBool, ok := input["create_backup"].(bool); ok {
+		createBackup = backupBool
+	}
+
+	// Stage a private copy of every file involved
+	files := make(map[string]*stagedFile)
+	var order []*stagedFile
+	defer func() {
+		for _, f := range order {
+			if f.temp != "" {
+				_ = os.Remove(f.temp)
+			}
+		}
+	}()
+	for _, e := range edits {
+		if _, ok := files[e.path]; ok {
+			continue
+		}
+		f, err := stageFile(e.path, e.rel)
+		if err != nil {
+			return Error("Failed to stage edits", err), nil
+		}
+		files[e.path] = f
+		order = append(order, f)
+	}
+
+	// Apply the edits to the staged copies, in order
+	for i, e := range edits {
+		if err := ctx.Err(); err != nil {
+			return Error("Edits canceled; no files were changed", err), nil
+		}
+		f := files[e.path]
+		result, err := e.strategy.Execute(f.temp, e.params)
+		if err != nil {
+			return Error("Edit failed; no files were changed", fmt.Errorf("edit %d (%s, %s): %w", i+1, e.rel, e.strategy.Name(), err)), nil
+		}
+		f.edits++
+		f.lines += result.LinesModified
+	}
+
+	// Back up the originals, then move every staged copy into place
+	if createBackup {
+		for _, f := range order {
+			saved, err := t.backups.Save(f.path, backupMeta(ctx, t.Name()))
+			if err != nil {
+				return Error("Failed to create backup; no files were changed", err), nil
+			}
+			f.backup = saved.ID
+		}
+	}
+	if err := commitStaged(order); err != nil {
+		return Error("Failed to apply edits; changes were rolled back", err), nil
+	}
+
+	result := &MultiEditResult{Files: make([]FileChange, 0, len(order))}
+	var diffs []string
+	for _, f := range order {
+		updated, err := os.ReadFile(f.path)
+		if err != nil {
+			return Error("Edits applied but the result could not be read", err), nil
+		}
+		if diff := t.diff.GenerateDiff(string(f.original), string(updated), f.rel); diff != "No changes detected" {
+			diffs = append(diffs, diff)
+		}
+		result.Files = append(result.Files, FileChange{
+			Path:          f.rel,
+			Edits:         f.edits,
+			LinesModified: f.lines,
+			BackupID:      f.backup,
+		})
+		result.EditsApplied += f.edits
+		result.LinesModified += f.lines
+	}
+	result.Diff = strings.Join(diffs, "\n")
+
+	summary := fmt.Sprintf("Applied %d edit(s) to %d file(s) (%d lines modified)", result.EditsApplied, len(order), result.LinesModified)
+	return Success(summary, result), nil
+}
+
+// resolvePath converts a relative path to an absolute path within the work directory.
+func (t *MultiEditTool) resolvePath(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(t.workDir, path)
+}
+
+// stageFile copies path to a temporary file in the same directory, so the
+// final rename stays on one filesystem.
+func stageFile(path, rel string) (*stagedFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", rel, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".multi_edit-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to stage %s: %w", rel, err)
+	}
+	f := &stagedFile{path: path, rel: rel, original: original, mode: info.Mode().Perm(), temp: tmp.Name()}
+	_, err = tmp.Write(original)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.temp, f.mode)
+	}
+	if err != nil {
+		_ = os.Remove(f.temp)
+		return nil, fmt.Errorf("failed to stage %s: %w", rel, err)
+	}
+	return f, nil
+}
+
+// commitStaged renames every staged copy over its original. If a rename
+// fails, the files already replaced are restored from memory.
+func commitStaged(files []*stagedFile) error {
+	for i, f := range files {
+		if err := os.Rename(f.temp, f.path); err != nil {
+			for _, done := range files[:i] {
+				_ = os.WriteFile(done.path, done.original, done.mode)
+			}
+			return fmt.Errorf("failed to replace %s: %w", f.rel, err)
+		}
+		f.temp = ""
+	}
+	return nil
+}
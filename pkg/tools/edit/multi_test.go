@@ -0,0 +1,165 @@
+package edit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// multiEditResponse mirrors the multi_edit JSON response.
+type multiEditResponse struct {
+	Ok      bool            `json:"ok"`
+	Summary string          `json:"summary"`
+	Data    MultiEditResult `json:"data"`
+	Error   string          `json:"error"`
+}
+
+// setupMultiEdit creates a work directory with two files.
+func setupMultiEdit(t *testing.T) (string, map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.go":     "package a\n\nfunc Old() {}\n",
+		"sub/b.go": "package sub\n\nvar x = a.Old()\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir, files
+}
+
+// runMultiEdit executes multi_edit and decodes the response.
+func runMultiEdit(t *testing.T, tool *MultiEditTool, edits ...map[string]interface{}) multiEditResponse {
+	t.Helper()
+	list := make([]interface{}, len(edits))
+	for i, e := range edits {
+		list[i] = e
+	}
+	result, err := tool.Execute(context.Background(), map[string]interface{}{
+		"edits":         list,
+		"create_backup": false,
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	var resp multiEditResponse
+	if err := json.Unmarshal([]byte(result), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, result)
+	}
+	return resp
+}
+
+// TestMultiEditAppliesAll tests edits across files with a combined diff.
+func TestMultiEditAppliesAll(t *testing.T) {
+	dir, _ := setupMultiEdit(t)
+	tool := NewMultiEditTool(dir)
+
+	resp := runMultiEdit(t, tool,
+		map[string]interface{}{"path": "a.go", "strategy": "replace", "old_text": "Old", "new_text": "New"},
+		map[string]interface{}{"path": "sub/b.go", "strategy": "replace", "old_text": "a.Old", "new_text": "a.New"},
+		// A second edit to the same file sees the first one
+		map[string]interface{}{"path": "a.go", "strategy": "replace", "old_text": "func New() {}", "new_text": "func New() int { return 1 }"},
+	)
+	if !resp.Ok {
+		t.Fatalf("expected success, got %s: %s", resp.Summary, resp.Error)
+	}
+	if resp.Data.EditsApplied != 3 || len(resp.Data.Files) != 2 {
+		t.Errorf("unexpected result: %+v", resp.Data)
+	}
+	if resp.Data.Files[0].Path != "a.go" || resp.Data.Files[0].Edits != 2 {
+		t.Errorf("unexpected file change: %+v", resp.Data.Files[0])
+	}
+	for _, want := range []string{"a.go", "sub/b.go", "+func New() int { return 1 }", "+var x = a.New()"} {
+		if !strings.Contains(resp.Data.Diff, want) {
+			t.Errorf("combined diff missing %q:\n%s", want, resp.Data.Diff)
+		}
+	}
+
+	a, _ := os.ReadFile(filepath.Join(dir, "a.go"))
+	if string(a) != "package a\n\nfunc New() int { return 1 }\n" {
+		t.Errorf("a.go = %q", a)
+	}
+	assertNoStagedFiles(t, dir)
+}
+
+// TestMultiEditRollsBack tests that a failing edit leaves every file untouched.
+func TestMultiEditRollsBack(t *testing.T) {
+	dir, files := setupMultiEdit(t)
+	tool := NewMultiEditTool(dir)
+
+	resp := runMultiEdit(t, tool,
+		map[string]interface{}{"path": "a.go", "strategy": "replace", "old_text": "Old", "new_text": "New"},
+		map[string]interface{}{"path": "sub/b.go", "strategy": "replace", "old_text": "missing text", "new_text": "x"},
+	)
+	if resp.Ok {
+		t.Fatal("expected failure")
+	}
+	if !strings.Contains(resp.Error, "edit 2") {
+		t.Errorf("error should name the failing edit: %s", resp.Error)
+	}
+
+	for name, want := range files {
+		got, _ := os.ReadFile(filepath.Join(dir, name))
+		if string(got) != want {
+			t.Errorf("%s was modified: %q", name, got)
+		}
+	}
+	assertNoStagedFiles(t, dir)
+}
+
+// TestMultiEditValidate tests that invalid transactions are rejected up front.
+func TestMultiEditValidate(t *testing.T) {
+	dir, _ := setupMultiEdit(t)
+	tool := NewMultiEditTool(dir)
+
+	tests := []struct {
+		name  string
+		input map[string]interface{}
+		want  string
+	}{
+		{"missing edits", map[string]interface{}{}, "array"},
+		{"empty edits", map[string]interface{}{"edits": []interface{}{}}, "empty"},
+		{"path traversal", map[string]interface{}{"edits": []interface{}{
+			map[string]interface{}{"path": "../x.go", "strategy": "replace", "old_text": "a", "new_text": "b"},
+		}}, "edit 1"},
+		{"missing file", map[string]interface{}{"edits": []interface{}{
+			map[string]interface{}{"path": "a.go", "strategy": "replace", "old_text": "a", "new_text": "b"},
+			map[string]interface{}{"path": "nope.go", "strategy": "replace", "old_text": "a", "new_text": "b"},
+		}}, "edit 2: file does not exist"},
+		{"unknown strategy", map[string]interface{}{"edits": []interface{}{
+			map[string]interface{}{"path": "a.go", "strategy": "rewrite"},
+		}}, "invalid strategy"},
+		{"invalid strategy input", map[string]interface{}{"edits": []interface{}{
+			map[string]interface{}{"path": "a.go", "strategy": "insert"},
+		}}, "edit 1 (a.go)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tool.Validate(tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() error = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// assertNoStagedFiles fails if temporary copies were left behind.
+func assertNoStagedFiles(t *testing.T, dir string) {
+	t.Helper()
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && strings.Contains(info.Name(), ".multi_edit-") {
+			t.Errorf("staged file left behind: %s", path)
+		}
+		return nil
+	})
+}
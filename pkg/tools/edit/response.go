@@ -17,15 +17,52 @@ type ToolResponse struct {
 type EditResult struct {
 	Path          string   `json:"path"`
 	Strategy      string   `json:"strategy"`
+	Match         string   `json:"match,omitempty"` // Tier that located old_text for replace
 	LinesModified int      `json:"lines_modified"`
 	BackupCreated bool     `json:"backup_created,omitempty"`
-	BackupPath    string   `json:"backup_path,omitempty"`
+	BackupID      int      `json:"backup_id,omitempty"`
 	Diff          string   `json:"diff,omitempty"`
 	Conflicts     []string `json:"conflicts,omitempty"`
 }
 
+// MultiEditResult contains the data returned by multi_edit.
+type MultiEditResult struct {
+	Files         []FileChange `json:"files"`
+	EditsApplied  int          `json:"edits_applied"`
+	LinesModified int          `json:"lines_modified"`
+	Diff          string       `json:"diff"`
+}
+
+// FileChange summarizes the edits applied to one file by multi_edit.
+type FileChange struct {
+	Path          string `json:"path"`
+	Edits         int    `json:"edits"`
+	LinesModified int    `json:"lines_modified"`
+	BackupID      int    `json:"backup_id,omitempty"`
+}
+
+// PatchResult contains the data returned by apply_patch.
+type PatchResult struct {
+	Files   []PatchFile `json:"files"`
+	Added   int         `json:"added"`
+	Removed int         `json:"removed"`
+}
+
+// PatchFile reports the change apply_patch made to one file.
+type PatchFile struct {
+	Path      string   `json:"path"`
+	OldPath   string   `json:"old_path,omitempty"` // Source of a rename
+	Operation string   `json:"operation"`          // create, delete, modify or rename
+	Mode      string   `json:"mode,omitempty"`     // New permission bits, e.g. "0755"
+	Hunks     int      `json:"hunks"`
+	Added     int      `json:"added"`
+	Removed   int      `json:"removed"`
+	Fuzzy     []string `json:"fuzzy,omitempty"` // Hunks applied with an offset, fuzz or whitespace tolerance
+	BackupID  int      `json:"backup_id,omitempty"`
+}
+
 // Success creates a successful response with data.
-func Success(summary string, data *EditResult) string {
+func Success(summary string, data interface{}) string {
 	resp := ToolResponse{
 		Ok:      true,
 		Summary: summary,
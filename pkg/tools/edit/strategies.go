@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"strings"
+
+	"github.com/Zerofisher/goai/pkg/patch"
 )
 
 // Strategy defines the interface for edit strategies.
@@ -84,6 +86,7 @@ func (s *ReplaceStrategy) Execute(path string, input map[string]interface{}) (*E
 	var newContent string
 	var lineStart, lineEnd int
 	var replaceAll bool
+	tier := MatchExact
 
 	// Check for line range constraint
 	if startRaw, ok := input["line_start"]; ok {
@@ -107,16 +110,33 @@ func (s *ReplaceStrategy) Execute(path string, input map[string]interface{}) (*E
 			}
 		}
 
-		// Replace in entire file
-		if replaceAll {
+		// Find old_text, tolerating line ending and whitespace differences
+		match := findText(originalStr, oldText)
+		if match == nil {
+			if closest := closestRegion(originalStr, oldText); closest != "" {
+				return nil, fmt.Errorf("text not found: %s\n%s", oldText, closest)
+			}
+			return nil, fmt.Errorf("text not found: %s", oldText)
+		}
+		tier = match.tier
+
+		switch {
+		case tier == MatchExact && replaceAll:
 			newContent = strings.ReplaceAll(originalStr, oldText, newText)
-		} else {
+		case tier == MatchExact:
+			if n := strings.Count(originalStr, oldText); n > 1 {
+				return nil, ambiguousMatch(n, tier)
+			}
 			newContent = strings.Replace(originalStr, oldText, newText, 1)
+		case len(match.regions) > 1 && !replaceAll:
+			return nil, ambiguousMatch(len(match.regions), tier)
+		default:
+			newContent = replaceRegions(originalStr, newText, match)
 		}
 
 		// Check if any changes were made
 		if originalStr == newContent {
-			return nil, fmt.Errorf("text not found: %s", oldText)
+			return nil, fmt.Errorf("new_text is identical to the matched text")
 		}
 	}
 
@@ -134,11 +154,36 @@ func (s *ReplaceStrategy) Execute(path string, input map[string]interface{}) (*E
 	return &EditResult{
 		Path:          path,
 		Strategy:      "replace",
+		Match:         tier,
 		LinesModified: linesModified,
 		Diff:          diff,
 	}, nil
 }
 
+// ambiguousMatch is the error for old_text found at n locations without
+// replace_all.
+func ambiguousMatch(n int, tier string) error {
+	where := fmt.Sprintf("%d locations", n)
+	if desc := describeTier(tier); desc != "" {
+		where += " (" + desc + ")"
+	}
+	return fmt.Errorf("old_text matches %s; include more surrounding lines to make it unique, or set replace_all", where)
+}
+
+// replaceRegions replaces every region of a tolerant match with newText
+// adapted to the file's line endings and indentation.
+func replaceRegions(content, newText string, match *textMatch) string {
+	var b strings.Builder
+	last := 0
+	for _, r := range match.regions {
+		b.WriteString(content[last:r.start])
+		b.WriteString(adaptText(newText, content, content[r.start:r.end], match.tier, r.delta))
+		last = r.end
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
 // replaceInLineRange replaces text only within the specified line range.
 func (s *ReplaceStrategy) replaceInLineRange(content, oldText, newText string, lineStart, lineEnd int) (string, error) {
 	lines := strings.Split(content, "\n")
@@ -432,93 +477,51 @@ func (s *ApplyPatchStrategy) Validate(input map[string]interface{}) error {
 	return nil
 }
 
-// Execute applies a unified diff patch.
+// Execute applies a unified diff patch to the file. Hunks may be off by a
+// few lines; the file keeps its permissions.
 func (s *ApplyPatchStrategy) Execute(path string, input map[string]interface{}) (*EditResult, error) {
-	patch, _ := input["patch"].(string)
+	text, _ := input["patch"].(string)
 
 	// Read original file
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
 	originalContent, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
+	files, err := patch.Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse patch: %w", err)
+	}
+	if len(files) != 1 {
+		return nil, fmt.Errorf("patch changes %d files; use the apply_patch tool for multi-file patches", len(files))
+	}
+	if op := files[0].Op(); op != patch.OpModify && files[0].OldPath != "" {
+		return nil, fmt.Errorf("patch would %s the file; use the apply_patch tool", op)
+	}
+
 	// Apply the patch
-	newContent, linesModified, err := s.applyUnifiedDiff(string(originalContent), patch)
+	newContent, _, err := patch.Apply(string(originalContent), files[0].Hunks, patch.DefaultMaxFuzz)
 	if err != nil {
 		return nil, fmt.Errorf("failed to apply patch: %w", err)
 	}
 
 	// Write back
-	if err := os.WriteFile(path, []byte(newContent), 0644); err != nil {
+	if err := os.WriteFile(path, []byte(newContent), info.Mode().Perm()); err != nil {
 		return nil, fmt.Errorf("failed to write file: %w", err)
 	}
 
+	added, removed := files[0].Stats()
 	return &EditResult{
 		Path:          path,
 		Strategy:      "apply_patch",
-		LinesModified: linesModified,
+		LinesModified: added + removed,
 	}, nil
 }
 
-// applyUnifiedDiff applies a unified diff patch to content.
-func (s *ApplyPatchStrategy) applyUnifiedDiff(content, patch string) (string, int, error) {
-	lines := strings.Split(content, "\n")
-	patchLines := strings.Split(patch, "\n")
-
-	// Parse the patch
-	linesModified := 0
-
-	// Skip header lines until we find @@ line
-	i := 0
-	for i < len(patchLines) {
-		if strings.HasPrefix(patchLines[i], "@@") {
-			i++
-			break
-		}
-		i++
-	}
-
-	// Apply hunks
-	newLines := make([]string, 0, len(lines))
-	lineIdx := 0
-
-	for i < len(patchLines) {
-		line := patchLines[i]
-
-		if strings.HasPrefix(line, "@@") {
-			// New hunk
-			i++
-			continue
-		}
-
-		if strings.HasPrefix(line, "+") {
-			// Add line
-			newLines = append(newLines, strings.TrimPrefix(line, "+"))
-			linesModified++
-		} else if strings.HasPrefix(line, "-") {
-			// Remove line (skip)
-			lineIdx++
-			linesModified++
-		} else if strings.HasPrefix(line, " ") {
-			// Context line
-			if lineIdx < len(lines) {
-				newLines = append(newLines, lines[lineIdx])
-				lineIdx++
-			}
-		}
-
-		i++
-	}
-
-	// Add remaining lines
-	for lineIdx < len(lines) {
-		newLines = append(newLines, lines[lineIdx])
-		lineIdx++
-	}
-
-	return strings.Join(newLines, "\n"), linesModified, nil
-}
-
 // countLinesModified counts how many lines were changed between two strings.
 func countLinesModified(original, new string) int {
 	originalLines := strings.Split(original, "\n")
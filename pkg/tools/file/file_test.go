@@ -7,6 +7,9 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/Zerofisher/goai/pkg/tools"
 )
 
 // TestReadTool tests the file reading functionality with JSON output.
@@ -545,3 +548,224 @@ func TestPathSecurity(t *testing.T) {
 		})
 	}
 }
+
+// TestReadToolDedup tests that repeated reads refer back to the earlier result.
+func TestReadToolDedup(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "main.go")
+	lines := make([]string, 40)
+	for i := range lines {
+		lines[i] = "// line"
+	}
+	original := strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tool := NewReadTool(tempDir, 1024*1024)
+	read := func(id string, input map[string]interface{}) map[string]interface{} {
+		t.Helper()
+		ctx := tools.WithCallInfo(context.Background(), tools.CallInfo{ToolUseID: id})
+		result, _ := tool.Execute(ctx, input)
+		var resp ToolResponse
+		if err := json.Unmarshal([]byte(result), &resp); err != nil || !resp.Ok {
+			t.Fatalf("read %s failed: %s", id, result)
+		}
+		return resp.Data.(map[string]interface{})
+	}
+	input := map[string]interface{}{"path": "main.go"}
+
+	if data := read("t1", input); data["content"] != original {
+		t.Fatalf("expected the full content on the first read, got %v", data)
+	}
+	if data := read("t2", input); data["unchanged"] != true || data["since"] != "t1" || data["content"] != "" {
+		t.Errorf("expected an unchanged stub, got %v", data)
+	}
+	// A different range is a different read
+	if data := read("t3", map[string]interface{}{"path": "main.go", "start_line": float64(1), "end_line": float64(2)}); data["content"] == "" {
+		t.Errorf("expected the range in full, got %v", data)
+	}
+
+	changed := strings.Replace(original, "// line", "// changed", 1)
+	if err := os.WriteFile(path, []byte(changed), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data := read("t4", input)
+	diff, _ := data["diff"].(string)
+	if data["since"] != "t2" || !strings.Contains(diff, "-// line") || !strings.Contains(diff, "+// changed") {
+		t.Errorf("expected a diff against t2, got %v", data)
+	}
+
+	if data := read("t5", map[string]interface{}{"path": "main.go", "force": true}); data["content"] != changed {
+		t.Errorf("expected force to return the full content, got %v", data)
+	}
+
+	// Once a result the view depends on is gone, the file is read in full
+	tool.Forget("t5")
+	if data := read("t6", input); data["content"] != changed {
+		t.Errorf("expected the full content after Forget, got %v", data)
+	}
+	tool.Reset()
+	if data := read("t7", input); data["content"] != changed {
+		t.Errorf("expected the full content after Reset, got %v", data)
+	}
+
+	// Reads outside a conversation are never deduplicated
+	if result, _ := tool.Execute(context.Background(), input); !strings.Contains(result, "// changed") {
+		t.Errorf("expected the full content without a tool use ID, got %s", result)
+	}
+}
+
+// TestGlobTool tests gitignore-aware matching ordered by modification time.
+func TestGlobTool(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		".gitignore":                "build/\n",
+		".goaiignore":               "*.gen.go\n",
+		"main.go":                   "package main\n",
+		"pkg/a/a.go":                "package a\n",
+		"pkg/a/a_test.go":           "package a\n",
+		"pkg/a/zz.gen.go":           "package a\n",
+		"build/out.go":              "package build\n",
+		"node_modules/lib/index.go": "package lib\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// main.go is the most recently modified
+	old := time.Now().Add(-time.Hour)
+	for _, name := range []string{"pkg/a/a.go", "pkg/a/a_test.go"} {
+		if err := os.Chtimes(filepath.Join(tempDir, name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tool := NewGlobTool(tempDir, 1000)
+	tool.SetExcludePatterns([]string{"node_modules"})
+	run := func(input map[string]interface{}) (ToolResponse, []string) {
+		t.Helper()
+		result, _ := tool.Execute(context.Background(), input)
+		var resp ToolResponse
+		if err := json.Unmarshal([]byte(result), &resp); err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		if data, ok := resp.Data.(map[string]interface{}); ok {
+			for _, f := range data["files"].([]interface{}) {
+				paths = append(paths, f.(map[string]interface{})["path"].(string))
+			}
+		}
+		return resp, paths
+	}
+
+	resp, paths := run(map[string]interface{}{"pattern": "**/*.go"})
+	if !resp.Ok || strings.Join(paths, ",") != "main.go,pkg/a/a.go,pkg/a/a_test.go" {
+		t.Errorf("unexpected matches: %v (%s)", paths, resp.Error)
+	}
+
+	_, paths = run(map[string]interface{}{"pattern": "*_test.go", "path": "pkg"})
+	if strings.Join(paths, ",") != "pkg/a/a_test.go" {
+		t.Errorf("unexpected matches under pkg: %v", paths)
+	}
+
+	resp, paths = run(map[string]interface{}{"pattern": "**/*.go", "limit": float64(1)})
+	if len(paths) != 1 || paths[0] != "main.go" || resp.Data.(map[string]interface{})["total"] != float64(3) {
+		t.Errorf("expected the newest of 3 matches, got %v", resp.Data)
+	}
+
+	if err := tool.Validate(map[string]interface{}{"pattern": "../*.go"}); err == nil {
+		t.Error("expected an error for a pattern outside the work directory")
+	}
+	if resp, _ := run(map[string]interface{}{"pattern": "*.go", "path": "../"}); resp.Ok {
+		t.Error("expected an error for a path outside the work directory")
+	}
+}
+
+// TestTreeTool tests depth limits and per-directory summaries.
+func TestTreeTool(t *testing.T) {
+	tempDir := t.TempDir()
+	for name, content := range map[string]string{
+		".gitignore":     "dist/\n",
+		"go.mod":         "module x\n",
+		"pkg/a/a.go":     "package a\n",
+		"pkg/a/b/b.go":   "package b\n",
+		"pkg/c.go":       "package pkg\n",
+		"dist/bundle.js": "x",
+	} {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tool := NewTreeTool(tempDir, 1000)
+	result, _ := tool.Execute(context.Background(), map[string]interface{}{"depth": float64(2)})
+	var resp ToolResponse
+	if err := json.Unmarshal([]byte(result), &resp); err != nil || !resp.Ok {
+		t.Fatalf("tree failed: %s", result)
+	}
+	data := resp.Data.(map[string]interface{})
+	want := `./ (4 files, 3 dirs, 41 B)
+├── go.mod (9 B)
+└── pkg/ (3 files, 32 B)
+    ├── a/ (2 files, 20 B)
+    └── c.go (12 B)`
+	if data["tree"] != want {
+		t.Errorf("tree =\n%s\nwant\n%s", data["tree"], want)
+	}
+
+	result, _ = tool.Execute(context.Background(), map[string]interface{}{"path": "pkg", "limit": float64(2)})
+	if err := json.Unmarshal([]byte(result), &resp); err != nil || !resp.Ok {
+		t.Fatalf("tree failed: %s", result)
+	}
+	data = resp.Data.(map[string]interface{})
+	if data["truncated"] != true || !strings.Contains(data["tree"].(string), "... 1 more") {
+		t.Errorf("expected a truncated tree, got\n%s", data["tree"])
+	}
+}
+
+// TestOutlineTool tests outlining a file and reading one symbol from it.
+func TestOutlineTool(t *testing.T) {
+	tempDir := t.TempDir()
+	src := "package agent\n\ntype Agent struct{}\n\n// Query answers.\nfunc (a *Agent) Query() string {\n\treturn \"\"\n}\n\nfunc Close() {}\n"
+	if err := os.WriteFile(filepath.Join(tempDir, "agent.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, _ := NewOutlineTool(tempDir, 1024*1024).Execute(context.Background(), map[string]interface{}{"path": "agent.go"})
+	var resp ToolResponse
+	if err := json.Unmarshal([]byte(result), &resp); err != nil || !resp.Ok {
+		t.Fatalf("outline failed: %s", result)
+	}
+	data := resp.Data.(map[string]interface{})
+	if data["count"] != float64(3) || !strings.Contains(data["outline"].(string), "6-8       func (a *Agent) Query() string") {
+		t.Errorf("unexpected outline:\n%s", data["outline"])
+	}
+
+	read := NewReadTool(tempDir, 1024*1024)
+	result, _ = read.Execute(context.Background(), map[string]interface{}{"path": "agent.go", "symbol": "Agent.Query"})
+	if err := json.Unmarshal([]byte(result), &resp); err != nil || !resp.Ok {
+		t.Fatalf("read failed: %s", result)
+	}
+	data = resp.Data.(map[string]interface{})
+	if want := "// Query answers.\nfunc (a *Agent) Query() string {\n\treturn \"\"\n}"; data["content"] != want {
+		t.Errorf("content = %q, want %q", data["content"], want)
+	}
+
+	result, _ = read.Execute(context.Background(), map[string]interface{}{"path": "agent.go", "symbol": "Missing"})
+	if err := json.Unmarshal([]byte(result), &resp); err != nil || resp.Ok || !strings.Contains(resp.Error, "outline") {
+		t.Errorf("expected a not-found error pointing at outline, got %s", result)
+	}
+	if err := read.Validate(map[string]interface{}{"path": "agent.go", "symbol": "Close", "start_line": float64(1)}); err == nil {
+		t.Error("expected symbol with start_line to be rejected")
+	}
+}
@@ -0,0 +1,273 @@
+package file
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Zerofisher/goai/pkg/ignore"
+)
+
+// GlobTool finds files by name pattern. It walks the tree with the ignore
+// package, so .gitignore, .ignore and .goaiignore files and the configured
+// exclude patterns are honored, and returns the most recently modified
+// matches first.
+type GlobTool struct {
+	workDir  string
+	maxItems int
+	opts     ignore.WalkOptions
+}
+
+// NewGlobTool creates a new glob tool.
+func NewGlobTool(workDir string, maxItems int) *GlobTool {
+	if maxItems <= 0 {
+		maxItems = 1000 // Default max items
+	}
+	return &GlobTool{
+		workDir:  workDir,
+		maxItems: maxItems,
+	}
+}
+
+// SetExcludePatterns sets extra gitignore-style patterns to exclude.
+func (t *GlobTool) SetExcludePatterns(patterns []string) {
+	t.opts.Excludes = patterns
+}
+
+// SetIncludeHidden controls whether dot files and directories are matched.
+func (t *GlobTool) SetIncludeHidden(include bool) {
+	t.opts.IncludeHidden = include
+}
+
+// Name returns the name of the tool.
+func (t *GlobTool) Name() string {
+	return "glob"
+}
+
+// Description returns the description of the tool.
+func (t *GlobTool) Description() string {
+	return "Find files by glob pattern (e.g. '**/*.go', 'pkg/**/*_test.go', '*.md'), most recently modified first. " +
+		"'**' matches any number of directories; a pattern without '/' matches file names at any depth. Ignored files (.gitignore, .goaiignore) are skipped"
+}
+
+// InputSchema returns the JSON schema for the input.
+func (t *GlobTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"pattern": map[string]interface{}{
+				"type":        "string",
+				"description": "Glob pattern relative to path",
+			},
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Directory to search in (default: '.' - work directory)",
+				"default":     ".",
+			},
+			"limit": map[string]interface{}{
+				"type":        "integer",
+				"description": "Maximum number of files to return (default: 100)",
+				"default":     100,
+				"minimum":     1,
+			},
+		},
+		"required": []string{"pattern"},
+	}
+}
+
+// Validate validates the input parameters.
+func (t *GlobTool) Validate(input map[string]interface{}) error {
+	pattern, ok := input["pattern"].(string)
+	if !ok {
+		return fmt.Errorf("pattern is required and must be a string")
+	}
+	if _, err := ignore.CompileGlob(pattern); err != nil {
+		return err
+	}
+	return validateWalkInput(input)
+}
+
+// Execute finds the matching files and returns JSON formatted result.
+func (t *GlobTool) Execute(ctx context.Context, input map[string]interface{}) (string, error) {
+	pattern, _ := input["pattern"].(string)
+	glob, err := ignore.CompileGlob(pattern)
+	if err != nil {
+		return Error("Invalid pattern", err), nil
+	}
+	base, err := walkBase(t.workDir, input)
+	if err != nil {
+		return Error("Invalid path", err), nil
+	}
+	limit := 100
+	if limitFloat, ok := input["limit"].(float64); ok && limitFloat > 0 {
+		limit = int(limitFloat)
+	}
+	limit = min(limit, t.maxItems)
+
+	// Keep only the newest matches so memory stays bounded on large trees
+	newest := &matchHeap{}
+	total := 0
+	err = walkUnder(ctx, t.workDir, base, t.opts, func(rel, local string, d fs.DirEntry) error {
+		if d.IsDir() {
+			if local != "" && !glob.CanContain(local) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !glob.Match(local) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		total++
+		heap.Push(newest, GlobMatch{Path: rel, Size: info.Size(), modTime: info.ModTime()})
+		if newest.Len() > limit {
+			heap.Pop(newest)
+		}
+		return nil
+	})
+	if err != nil {
+		return Error("Failed to search files", err), nil
+	}
+
+	matches := []GlobMatch(*newest)
+	sort.Slice(matches, func(i, j int) bool { return newer(matches[i], matches[j]) })
+	for i := range matches {
+		matches[i].Modified = matches[i].modTime.Format(time.RFC3339)
+	}
+
+	data := GlobData{
+		Pattern:   glob.String(),
+		Path:      base,
+		Files:     matches,
+		Count:     len(matches),
+		Total:     total,
+		Truncated: total > len(matches),
+	}
+	summary := fmt.Sprintf("Found %d files matching %s", total, glob)
+	if data.Truncated {
+		summary += fmt.Sprintf(" (showing the %d most recently modified)", len(matches))
+	}
+	return Success(summary, data), nil
+}
+
+// newer orders matches by modification time, newest first, then by path.
+func newer(a, b GlobMatch) bool {
+	if !a.modTime.Equal(b.modTime) {
+		return a.modTime.After(b.modTime)
+	}
+	return a.Path < b.Path
+}
+
+// matchHeap is a min-heap of matches with the oldest on top.
+type matchHeap []GlobMatch
+
+func (h matchHeap) Len() int           { return len(h) }
+func (h matchHeap) Less(i, j int) bool { return newer(h[j], h[i]) }
+func (h matchHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *matchHeap) Push(x any)        { *h = append(*h, x.(GlobMatch)) }
+func (h *matchHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// validateWalkInput validates the path and limit inputs shared by glob and
+// tree.
+func validateWalkInput(input map[string]interface{}) error {
+	if pathRaw, ok := input["path"]; ok {
+		p, ok := pathRaw.(string)
+		if !ok || p == "" {
+			return fmt.Errorf("path must be a non-empty string")
+		}
+	}
+	if limitRaw, ok := input["limit"]; ok {
+		if limit, ok := limitRaw.(float64); !ok || limit < 1 {
+			return fmt.Errorf("limit must be a positive number")
+		}
+	}
+	return nil
+}
+
+// walkBase returns the slash-separated directory named by the path input,
+// relative to workDir and "" for workDir itself.
+func walkBase(workDir string, input map[string]interface{}) (string, error) {
+	dir := "."
+	if p, ok := input["path"].(string); ok && p != "" {
+		dir = p
+	}
+
+	abs := dir
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(workDir, dir)
+	}
+	rel, err := filepath.Rel(workDir, filepath.Clean(abs))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path escapes work directory")
+	}
+
+	info, err := os.Stat(abs)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("path does not exist: %s", dir)
+		}
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("path is not a directory: %s", dir)
+	}
+	if rel == "." {
+		return "", nil
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// walkUnder walks the entries below base. The walk starts at workDir so
+// ignore files above base still apply. fn receives the path relative to
+// workDir and the path relative to base.
+func walkUnder(ctx context.Context, workDir, base string, opts ignore.WalkOptions, fn func(rel, local string, d fs.DirEntry) error) error {
+	return ignore.Walk(ctx, workDir, opts, func(rel string, d fs.DirEntry) error {
+		if base == "" {
+			return fn(rel, rel, d)
+		}
+		if rel == base {
+			return fn(rel, "", d)
+		}
+		if strings.HasPrefix(rel, base+"/") {
+			return fn(rel, rel[len(base)+1:], d)
+		}
+		if d.IsDir() && strings.HasPrefix(base, rel+"/") {
+			return nil // On the way to base
+		}
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+// depthOf returns the number of segments in the slash-separated path local.
+func depthOf(local string) int {
+	if local == "" {
+		return 0
+	}
+	return strings.Count(local, "/") + 1
+}
+
+// parentOf returns the slash-separated parent of local, "" at the top.
+func parentOf(local string) string {
+	if dir := path.Dir(local); dir != "." {
+		return dir
+	}
+	return ""
+}
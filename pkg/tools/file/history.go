@@ -0,0 +1,94 @@
+package file
+
+import (
+	"strings"
+	"sync"
+)
+
+// readView is the content of a file (or line range) as the model last saw
+// it, and the tool uses whose results it needs to reconstruct that view: the
+// last full read and every diff returned since.
+type readView struct {
+	content    string
+	toolUseIDs []string
+}
+
+// last returns the most recent tool use the view was returned by.
+func (v *readView) last() string {
+	return v.toolUseIDs[len(v.toolUseIDs)-1]
+}
+
+// readHistory remembers what read_file returned in the conversation, keyed
+// by path and line range. It is safe for concurrent use.
+type readHistory struct {
+	mu    sync.Mutex
+	views map[string]*readView
+}
+
+// newReadHistory creates an empty history.
+func newReadHistory() *readHistory {
+	return &readHistory{views: make(map[string]*readView)}
+}
+
+// get returns the view stored under key.
+func (h *readHistory) get(key string) (readView, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	v, ok := h.views[key]
+	if !ok {
+		return readView{}, false
+	}
+	return readView{content: v.content, toolUseIDs: append([]string(nil), v.toolUseIDs...)}, true
+}
+
+// full stores content returned in full by toolUseID.
+func (h *readHistory) full(key, content, toolUseID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.views[key] = &readView{content: content, toolUseIDs: []string{toolUseID}}
+}
+
+// diff stores content returned by toolUseID as a diff against the view.
+func (h *readHistory) diff(key, content, toolUseID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if v, ok := h.views[key]; ok {
+		v.content = content
+		v.toolUseIDs = append(v.toolUseIDs, toolUseID)
+	}
+}
+
+// forget drops every view that depends on the result of toolUseID.
+func (h *readHistory) forget(toolUseID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for key, v := range h.views {
+		for _, id := range v.toolUseIDs {
+			if id == toolUseID {
+				delete(h.views, key)
+				break
+			}
+		}
+	}
+}
+
+// reset drops every view.
+func (h *readHistory) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.views = make(map[string]*readView)
+}
+
+// diffStats counts the lines a unified diff adds and removes.
+func diffStats(diff string) (added, removed int) {
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,124 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Zerofisher/goai/pkg/outline"
+)
+
+// OutlineTool returns the structure of a file with the line range of each
+// declaration, so large files can be read one symbol at a time.
+type OutlineTool struct {
+	workDir string
+	maxSize int64
+}
+
+// NewOutlineTool creates a new outline tool.
+func NewOutlineTool(workDir string, maxSize int64) *OutlineTool {
+	if maxSize <= 0 {
+		maxSize = 10 * 1024 * 1024 // Default 10MB
+	}
+	return &OutlineTool{
+		workDir: workDir,
+		maxSize: maxSize,
+	}
+}
+
+// Name returns the name of the tool.
+func (t *OutlineTool) Name() string {
+	return "outline"
+}
+
+// Description returns the description of the tool.
+func (t *OutlineTool) Description() string {
+	return "Show the structure of a file: types, functions, methods, constants (or Markdown headings) with their line ranges. " +
+		"Go is parsed exactly; other languages use heuristics. Use it before reading a large file, then read one declaration " +
+		"with read_file's symbol parameter (e.g. 'Agent.Query') or a line range"
+}
+
+// InputSchema returns the JSON schema for the input.
+func (t *OutlineTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Path to the file to outline (relative to work directory)",
+			},
+		},
+		"required": []string{"path"},
+	}
+}
+
+// Validate validates the input parameters.
+func (t *OutlineTool) Validate(input map[string]interface{}) error {
+	path, ok := input["path"].(string)
+	if !ok || path == "" {
+		return fmt.Errorf("path is required and must be a non-empty string")
+	}
+	return nil
+}
+
+// Execute outlines the file and returns JSON formatted result.
+func (t *OutlineTool) Execute(ctx context.Context, input map[string]interface{}) (string, error) {
+	path, _ := input["path"].(string)
+	reader := &ReadTool{workDir: t.workDir}
+	if err := reader.validatePath(path); err != nil {
+		return Error("Invalid path", err), nil
+	}
+
+	o, err := parseOutline(reader.resolvePath(path), t.maxSize)
+	if err != nil {
+		return Error("Failed to outline file", err), nil
+	}
+
+	data := OutlineData{
+		Path:     path,
+		Language: o.Language,
+		Lines:    o.Lines,
+		Count:    len(o.Symbols),
+		Outline:  o.String(),
+	}
+	summary := fmt.Sprintf("Outlined %s: %d symbols in %d lines (%s)", path, len(o.Symbols), o.Lines, o.Language)
+	return Success(summary, data), nil
+}
+
+// parseOutline reads and outlines the file at absPath.
+func parseOutline(absPath string, maxSize int64) (*outline.Outline, error) {
+	info, err := os.Stat(absPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("file does not exist: %s", filepath.Base(absPath))
+		}
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("path is a directory, not a file")
+	}
+	if info.Size() > maxSize {
+		return nil, fmt.Errorf("file size %d bytes exceeds the limit of %d", info.Size(), maxSize)
+	}
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, err
+	}
+	return outline.Parse(absPath, content)
+}
+
+// symbolRange returns the lines of the declaration called symbol in the
+// file at absPath, including its doc comment.
+func symbolRange(absPath, symbol string, maxSize int64) (int, int, error) {
+	o, err := parseOutline(absPath, maxSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	s, err := o.Find(strings.TrimSpace(symbol))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w; use the outline tool to list the symbols of the file", err)
+	}
+	return s.DocLine, s.EndLine, nil
+}
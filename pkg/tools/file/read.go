@@ -8,12 +8,16 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/Zerofisher/goai/pkg/tools"
+	"github.com/Zerofisher/goai/pkg/tools/edit"
 )
 
 // ReadTool implements file reading functionality with security checks.
 type ReadTool struct {
 	workDir string
 	maxSize int64 // max file size in bytes
+	history *readHistory
 }
 
 // NewReadTool creates a new file reading tool.
@@ -24,9 +28,21 @@ func NewReadTool(workDir string, maxSize int64) *ReadTool {
 	return &ReadTool{
 		workDir: workDir,
 		maxSize: maxSize,
+		history: newReadHistory(),
 	}
 }
 
+// Reset forgets every read, for when the conversation is cleared.
+func (t *ReadTool) Reset() {
+	t.history.reset()
+}
+
+// Forget forgets the reads that depend on the result of toolUseID, for when
+// that result is dropped from the conversation.
+func (t *ReadTool) Forget(toolUseID string) {
+	t.history.forget(toolUseID)
+}
+
 // Name returns the name of the tool.
 func (t *ReadTool) Name() string {
 	return "read_file"
@@ -34,7 +50,8 @@ func (t *ReadTool) Name() string {
 
 // Description returns the description of the tool.
 func (t *ReadTool) Description() string {
-	return "Read contents of a file within the work directory"
+	return "Read contents of a file within the work directory. Reading the same file or line range again returns a short " +
+		"'unchanged' note, or a diff when it changed, that refers to the earlier tool_use; set force to get the full content"
 }
 
 // InputSchema returns the JSON schema for the input.
@@ -61,6 +78,15 @@ func (t *ReadTool) InputSchema() map[string]interface{} {
 				"description": "Maximum bytes to read (optional, default 200KB)",
 				"default":     200 * 1024,
 			},
+			"symbol": map[string]interface{}{
+				"type":        "string",
+				"description": "Read only this declaration, e.g. 'Agent.Query' or 'Query' (see the outline tool; not combined with start_line/end_line)",
+			},
+			"force": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Return the full content even if it was read before in this conversation (default: false)",
+				"default":     false,
+			},
 		},
 		"required": []string{"path"},
 	}
@@ -130,6 +156,15 @@ func (t *ReadTool) Execute(ctx context.Context, input map[string]interface{}) (s
 		}
 	}
 
+	// A symbol selects the lines of its declaration
+	symbol, _ := input["symbol"].(string)
+	if symbol != "" {
+		startLine, endLine, err = symbolRange(absPath, symbol, t.maxSize)
+		if err != nil {
+			return Error("Symbol not found", err), nil
+		}
+	}
+
 	// Read the file
 	content, readBytes, lineRange, err := t.readFile(absPath, startLine, endLine, maxBytes)
 	if err != nil {
@@ -146,12 +181,22 @@ func (t *ReadTool) Execute(ctx context.Context, input map[string]interface{}) (s
 	if lineRange != nil {
 		data.Range = lineRange
 	}
+	data.Symbol = symbol
+
+	// Refer back to an earlier read of the same content in the conversation
+	force, _ := input["force"].(bool)
+	if summary, ok := t.dedupe(ctx, &data, absPath, startLine, endLine, force); ok {
+		return Success(summary, data), nil
+	}
 
 	// Format summary
 	summary := fmt.Sprintf("Read %d bytes from %s", readBytes, path)
 	if lineRange != nil {
 		summary = fmt.Sprintf("Read lines %d-%d from %s (%d bytes)", lineRange.Start, lineRange.End, path, readBytes)
 	}
+	if symbol != "" {
+		summary = fmt.Sprintf("Read %s (lines %d-%d) from %s", symbol, lineRange.Start, lineRange.End, path)
+	}
 
 	return Success(summary, data), nil
 }
@@ -204,6 +249,22 @@ func (t *ReadTool) Validate(input map[string]interface{}) error {
 		return fmt.Errorf("start_line cannot be greater than end_line")
 	}
 
+	if symbolRaw, ok := input["symbol"]; ok {
+		symbol, ok := symbolRaw.(string)
+		if !ok || strings.TrimSpace(symbol) == "" {
+			return fmt.Errorf("symbol must be a non-empty string")
+		}
+		if startLine > 0 || endLine > 0 {
+			return fmt.Errorf("symbol cannot be combined with start_line or end_line")
+		}
+	}
+
+	if forceRaw, ok := input["force"]; ok {
+		if _, ok := forceRaw.(bool); !ok {
+			return fmt.Errorf("force must be a boolean")
+		}
+	}
+
 	// Validate max_bytes if provided
 	if maxBytesRaw, ok := input["max_bytes"]; ok {
 		if maxBytesFloat, ok := maxBytesRaw.(float64); ok {
@@ -218,6 +279,49 @@ func (t *ReadTool) Validate(input map[string]interface{}) error {
 	return nil
 }
 
+// dedupe records the content returned by this read and, if the same file
+// and range was returned earlier in the conversation, replaces the content
+// with a note that it is unchanged or a diff against the earlier content.
+// It reports whether data was replaced, along with the summary to use.
+// Reads without a tool use ID are not part of a conversation and are
+// always returned in full.
+func (t *ReadTool) dedupe(ctx context.Context, data *ReadFileData, absPath string, startLine, endLine int, force bool) (string, bool) {
+	info := tools.CallInfoFrom(ctx)
+	if info.ToolUseID == "" {
+		return "", false
+	}
+	key := fmt.Sprintf("%s:%d-%d", absPath, startLine, endLine)
+	content := data.Content
+
+	prev, seen := t.history.get(key)
+	if !seen || force {
+		t.history.full(key, content, info.ToolUseID)
+		return "", false
+	}
+
+	if content == prev.content {
+		t.history.diff(key, content, info.ToolUseID)
+		data.Content = ""
+		data.Unchanged = true
+		data.Since = prev.last()
+		return fmt.Sprintf("%s is unchanged since tool_use %s (%d bytes); set force to read it in full", data.Path, prev.last(), data.Bytes), true
+	}
+
+	diff := edit.NewDiffGenerator().GenerateDiff(prev.content, content, data.Path)
+	if len(diff) >= len(content) {
+		// The diff would not save anything
+		t.history.full(key, content, info.ToolUseID)
+		return "", false
+	}
+	t.history.diff(key, content, info.ToolUseID)
+	data.Content = ""
+	data.Diff = diff
+	data.Since = prev.last()
+	added, removed := diffStats(diff)
+	return fmt.Sprintf("%s changed since tool_use %s (+%d -%d); returning a diff against that content, set force to read it in full",
+		data.Path, prev.last(), added, removed), true
+}
+
 // validatePath checks if the path is safe to access.
 func (t *ReadTool) validatePath(path string) error {
 	// Prevent empty paths
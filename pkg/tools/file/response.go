@@ -3,6 +3,7 @@ package file
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 )
 
 // ToolResponse represents the standardized JSON response format for file tools.
@@ -20,6 +21,10 @@ type ReadFileData struct {
 	Content string    `json:"content"`
 	Range   *LineRange `json:"range,omitempty"`
 	Bytes   int       `json:"bytes"`
+	Symbol  string    `json:"symbol,omitempty"`
+	Unchanged bool      `json:"unchanged,omitempty"` // Content is the same as returned by Since
+	Diff      string    `json:"diff,omitempty"`      // Unified diff against the content returned by Since
+	Since     string    `json:"since,omitempty"`     // Earlier tool use that returned this file
 }
 
 // LineRange represents the line range that was read.
@@ -53,6 +58,43 @@ type FileEntry struct {
 	Mode     string `json:"mode,omitempty"`
 }
 
+// OutlineData contains the data returned by outline tool.
+type OutlineData struct {
+	Path     string `json:"path"`
+	Language string `json:"language"`
+	Lines    int    `json:"lines"`
+	Count    int    `json:"count"`
+	Outline  string `json:"outline"` // One symbol per line: "start-end  declaration"
+}
+
+// GlobData contains the data returned by glob tool.
+type GlobData struct {
+	Pattern   string      `json:"pattern"`
+	Path      string      `json:"path,omitempty"`
+	Files     []GlobMatch `json:"files"`
+	Count     int         `json:"count"`
+	Total     int         `json:"total"` // Matches before the limit was applied
+	Truncated bool        `json:"truncated,omitempty"`
+}
+
+// GlobMatch is a file matched by glob.
+type GlobMatch struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	Modified string    `json:"modified"`
+	modTime  time.Time // For ordering
+}
+
+// TreeData contains the data returned by tree tool.
+type TreeData struct {
+	Path      string `json:"path"`
+	Tree      string `json:"tree"`
+	Files     int    `json:"files"`
+	Dirs      int    `json:"dirs"`
+	Size      int64  `json:"size"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
 // Success creates a successful response with data.
 func Success(summary string, data interface{}) string {
 	resp := ToolResponse{
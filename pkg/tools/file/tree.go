@@ -0,0 +1,232 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/Zerofisher/goai/pkg/ignore"
+)
+
+// TreeTool shows the directory structure up to a depth, summarizing the
+// files and size below every directory. Like GlobTool it honors ignore
+// files and the configured exclude patterns.
+type TreeTool struct {
+	workDir  string
+	maxItems int
+	opts     ignore.WalkOptions
+}
+
+// NewTreeTool creates a new tree tool.
+func NewTreeTool(workDir string, maxItems int) *TreeTool {
+	if maxItems <= 0 {
+		maxItems = 1000 // Default max items
+	}
+	return &TreeTool{
+		workDir:  workDir,
+		maxItems: maxItems,
+	}
+}
+
+// SetExcludePatterns sets extra gitignore-style patterns to exclude.
+func (t *TreeTool) SetExcludePatterns(patterns []string) {
+	t.opts.Excludes = patterns
+}
+
+// SetIncludeHidden controls whether dot files and directories are shown.
+func (t *TreeTool) SetIncludeHidden(include bool) {
+	t.opts.IncludeHidden = include
+}
+
+// Name returns the name of the tool.
+func (t *TreeTool) Name() string {
+	return "tree"
+}
+
+// Description returns the description of the tool.
+func (t *TreeTool) Description() string {
+	return "Show the directory tree up to a depth, with the file count and total size of every directory. " +
+		"Directories at the depth limit are collapsed into their summary. Ignored files (.gitignore, .goaiignore) are skipped"
+}
+
+// InputSchema returns the JSON schema for the input.
+func (t *TreeTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Directory to show (default: '.' - work directory)",
+				"default":     ".",
+			},
+			"depth": map[string]interface{}{
+				"type":        "integer",
+				"description": "Levels of directories to expand (default: 3)",
+				"default":     3,
+				"minimum":     1,
+			},
+			"limit": map[string]interface{}{
+				"type":        "integer",
+				"description": "Maximum number of entries to show (default: 500)",
+				"default":     500,
+				"minimum":     1,
+			},
+		},
+	}
+}
+
+// Validate validates the input parameters.
+func (t *TreeTool) Validate(input map[string]interface{}) error {
+	if depthRaw, ok := input["depth"]; ok {
+		if depth, ok := depthRaw.(float64); !ok || depth < 1 {
+			return fmt.Errorf("depth must be a positive number")
+		}
+	}
+	return validateWalkInput(input)
+}
+
+// treeNode is a directory or file in the tree.
+type treeNode struct {
+	name     string
+	isDir    bool
+	size     int64 // Total size of the files below a directory
+	files    int   // Files below a directory, at any depth
+	children []*treeNode
+}
+
+// Execute walks the directory and returns the rendered tree.
+func (t *TreeTool) Execute(ctx context.Context, input map[string]interface{}) (string, error) {
+	base, err := walkBase(t.workDir, input)
+	if err != nil {
+		return Error("Invalid path", err), nil
+	}
+	depth := 3
+	if depthFloat, ok := input["depth"].(float64); ok && depthFloat >= 1 {
+		depth = int(depthFloat)
+	}
+	limit := 500
+	if limitFloat, ok := input["limit"].(float64); ok && limitFloat > 0 {
+		limit = int(limitFloat)
+	}
+	limit = min(limit, t.maxItems)
+
+	// Every directory is counted, but only entries within depth are kept
+	root := &treeNode{name: displayBase(base), isDir: true}
+	dirs := map[string]*treeNode{"": root}
+	dirCount := 0
+	err = walkUnder(ctx, t.workDir, base, t.opts, func(rel, local string, d fs.DirEntry) error {
+		if local == "" {
+			return nil
+		}
+		parent := parentOf(local)
+		if d.IsDir() {
+			dirCount++
+			node := &treeNode{name: d.Name(), isDir: true}
+			dirs[local] = node
+			if depthOf(local) <= depth {
+				dirs[parent].children = append(dirs[parent].children, node)
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if depthOf(local) <= depth {
+			dirs[parent].children = append(dirs[parent].children, &treeNode{name: d.Name(), size: info.Size()})
+		}
+		for dir := parent; ; dir = parentOf(dir) {
+			dirs[dir].files++
+			dirs[dir].size += info.Size()
+			if dir == "" {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Error("Failed to walk directory", err), nil
+	}
+
+	r := &treeRenderer{depth: depth, limit: limit}
+	fmt.Fprintf(&r.b, "%s/ (%d files, %d dirs, %s)\n", root.name, root.files, dirCount, formatSize(root.size))
+	r.render(root.children, "", 1)
+
+	data := TreeData{
+		Path:      root.name,
+		Tree:      strings.TrimSuffix(r.b.String(), "\n"),
+		Files:     root.files,
+		Dirs:      dirCount,
+		Size:      root.size,
+		Truncated: r.truncated,
+	}
+	summary := fmt.Sprintf("Tree of %s: %d files in %d directories (%s)", root.name, root.files, dirCount, formatSize(root.size))
+	if r.truncated {
+		summary += fmt.Sprintf("; output truncated at %d entries, narrow the path or lower the depth", limit)
+	}
+	return Success(summary, data), nil
+}
+
+// treeRenderer draws nodes with box-drawing connectors up to a line limit.
+type treeRenderer struct {
+	b         strings.Builder
+	depth     int
+	limit     int
+	lines     int
+	truncated bool
+}
+
+// render writes nodes at level with the given line prefix.
+func (r *treeRenderer) render(nodes []*treeNode, prefix string, level int) {
+	for i, node := range nodes {
+		if r.lines >= r.limit {
+			r.truncated = true
+			fmt.Fprintf(&r.b, "%s└── ... %d more\n", prefix, len(nodes)-i)
+			return
+		}
+		r.lines++
+
+		connector, indent := "├── ", "│   "
+		if i == len(nodes)-1 {
+			connector, indent = "└── ", "    "
+		}
+		if !node.isDir {
+			fmt.Fprintf(&r.b, "%s%s%s (%s)\n", prefix, connector, node.name, formatSize(node.size))
+			continue
+		}
+		fmt.Fprintf(&r.b, "%s%s%s/ (%d files, %s)\n", prefix, connector, node.name, node.files, formatSize(node.size))
+		if level < r.depth {
+			r.render(node.children, prefix+indent, level+1)
+		}
+		if r.truncated {
+			return
+		}
+	}
+}
+
+// displayBase returns the name to show for the walked directory.
+func displayBase(base string) string {
+	if base == "" {
+		return "."
+	}
+	return path.Clean(base)
+}
+
+// formatSize formats a size in bytes in human-readable form.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,166 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/Zerofisher/goai/internal/testutil"
+)
+
+// newTestRepo creates a repository with one commit of a.txt.
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	return testutil.NewGitRepo(t, map[string]string{"a.txt": "one\ntwo\n"})
+}
+
+// execute runs the tool and decodes its response.
+func execute(t *testing.T, tool *GitTool, input map[string]interface{}) ToolResponse {
+	t.Helper()
+	out, err := tool.Execute(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	var resp ToolResponse
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatalf("invalid JSON response %q: %v", out, err)
+	}
+	return resp
+}
+
+// TestGitToolValidate tests input validation
+func TestGitToolValidate(t *testing.T) {
+	tool := NewGitTool(t.TempDir())
+
+	tests := []struct {
+		name    string
+		input   map[string]interface{}
+		wantErr string
+	}{
+		{"status", map[string]interface{}{"action": "status"}, ""},
+		{"missing action", map[string]interface{}{}, "action is required"},
+		{"unknown action", map[string]interface{}{"action": "push"}, "unknown action"},
+		{"option as range", map[string]interface{}{"action": "diff", "range": "--output=x"}, "must not start with '-'"},
+		{"blame without path", map[string]interface{}{"action": "blame"}, "path is required"},
+		{"bad line range", map[string]interface{}{"action": "blame", "path": "a.txt", "start_line": 5.0, "end_line": 2.0}, "end_line"},
+		{"add without paths", map[string]interface{}{"action": "add"}, "paths is required"},
+		{"commit without message", map[string]interface{}{"action": "commit", "message": " "}, "message is required"},
+		{"branch without name", map[string]interface{}{"action": "branch", "operation": "create"}, "name is required"},
+		{"stash operation on branch", map[string]interface{}{"action": "branch", "operation": "pop"}, "unknown branch operation"},
+		{"operation on status", map[string]interface{}{"action": "status", "operation": "list"}, "only applies"},
+		{"large max_count", map[string]interface{}{"action": "log", "max_count": 500.0}, "at most"},
+		{"fractional index", map[string]interface{}{"action": "stash", "operation": "drop", "index": 1.5}, "non-negative integer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tool.Validate(tt.input)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestGitTool tests running operations and their structured results
+func TestGitTool(t *testing.T) {
+	dir := newTestRepo(t)
+	tool := NewGitTool(dir)
+
+	resp := execute(t, tool, map[string]interface{}{"action": "status"})
+	if !resp.Ok || resp.Summary != "On main: working tree clean" {
+		t.Errorf("unexpected clean status: %+v", resp)
+	}
+
+	testutil.WriteFile(t, dir, "a.txt", "one\n2\n")
+	testutil.WriteFile(t, dir, "b.txt", "bee\n")
+	resp = execute(t, tool, map[string]interface{}{"action": "status"})
+	if resp.Summary != "On main: 1 unstaged, 1 untracked" {
+		t.Errorf("status summary = %q", resp.Summary)
+	}
+
+	resp = execute(t, tool, map[string]interface{}{"action": "diff"})
+	data := resp.Data.(map[string]interface{})
+	if resp.Summary != "1 file changed, +1 -1 (unstaged)" || !strings.Contains(data["patch"].(string), "+2") {
+		t.Errorf("unexpected diff: %+v", resp)
+	}
+
+	resp = execute(t, tool, map[string]interface{}{"action": "add", "paths": []interface{}{"a.txt", "b.txt"}})
+	if !resp.Ok || resp.Summary != "Staged 2 paths; On main: 2 staged" {
+		t.Errorf("unexpected add: %+v", resp)
+	}
+	resp = execute(t, tool, map[string]interface{}{"action": "diff", "staged": true, "stat_only": true})
+	if resp.Summary != "2 files changed, +2 -1 (staged)" {
+		t.Errorf("staged diff summary = %q", resp.Summary)
+	}
+
+	resp = execute(t, tool, map[string]interface{}{"action": "commit", "message": "Add b"})
+	if !resp.Ok || !strings.HasSuffix(resp.Summary, ": Add b") {
+		t.Errorf("unexpected commit: %+v", resp)
+	}
+
+	resp = execute(t, tool, map[string]interface{}{"action": "log", "max_count": 1.0})
+	commits := resp.Data.(map[string]interface{})["commits"].([]interface{})
+	if resp.Summary != "1 commit" || commits[0].(map[string]interface{})["subject"] != "Add b" {
+		t.Errorf("unexpected log: %+v", resp)
+	}
+
+	resp = execute(t, tool, map[string]interface{}{"action": "show", "rev": "HEAD~1", "stat_only": true})
+	if !resp.Ok || !strings.HasSuffix(resp.Summary, "Initial commit: 1 file changed, +2 -0") {
+		t.Errorf("unexpected show: %+v", resp)
+	}
+
+	resp = execute(t, tool, map[string]interface{}{"action": "blame", "path": "a.txt"})
+	if resp.Summary != "Blame of a.txt: 2 lines from 2 commits" {
+		t.Errorf("blame summary = %q", resp.Summary)
+	}
+
+	resp = execute(t, tool, map[string]interface{}{"action": "branch", "operation": "create", "name": "topic"})
+	if !resp.Ok || resp.Summary != "Created branch topic" {
+		t.Errorf("unexpected branch create: %+v", resp)
+	}
+	resp = execute(t, tool, map[string]interface{}{"action": "branch", "operation": "switch", "name": "topic"})
+	if !resp.Ok || resp.Data.(map[string]interface{})["head"].(map[string]interface{})["branch"] != "topic" {
+		t.Errorf("unexpected branch switch: %+v", resp)
+	}
+	resp = execute(t, tool, map[string]interface{}{"action": "branch"})
+	if resp.Summary != "2 branches, on topic" {
+		t.Errorf("branch list summary = %q", resp.Summary)
+	}
+
+	testutil.WriteFile(t, dir, "a.txt", "wip\n")
+	resp = execute(t, tool, map[string]interface{}{"action": "stash", "operation": "push", "message": "wip"})
+	if !resp.Ok || resp.Summary != "Stashed local changes as stash@{0}" {
+		t.Errorf("unexpected stash push: %+v", resp)
+	}
+	resp = execute(t, tool, map[string]interface{}{"action": "stash", "operation": "pop"})
+	if !resp.Ok || len(resp.Data.(map[string]interface{})["stashes"].([]interface{})) != 0 {
+		t.Errorf("unexpected stash pop: %+v", resp)
+	}
+
+	resp = execute(t, tool, map[string]interface{}{"action": "branch", "operation": "switch", "name": "missing"})
+	if resp.Ok || resp.Error == "" {
+		t.Errorf("expected switching to a missing branch to fail: %+v", resp)
+	}
+}
+
+// TestGitToolNotRepository tests the error outside a repository
+func TestGitToolNotRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	t.Setenv("GIT_CEILING_DIRECTORIES", os.TempDir())
+
+	resp := execute(t, NewGitTool(t.TempDir()), map[string]interface{}{"action": "status"})
+	if resp.Ok || resp.Summary != "Not a git repository" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
@@ -0,0 +1,47 @@
+package git
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ToolResponse represents the standardized JSON response format for the git
+// tool: {"ok":true,"summary":"...","data":{...}}
+type ToolResponse struct {
+	Ok      bool        `json:"ok"`
+	Summary string      `json:"summary"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// Success creates a success response.
+func Success(summary string, data interface{}) string {
+	resp := ToolResponse{
+		Ok:      true,
+		Summary: summary,
+		Data:    data,
+	}
+	return marshalResponse(resp)
+}
+
+// Error creates an error response.
+func Error(summary string, err error) string {
+	resp := ToolResponse{
+		Ok:      false,
+		Summary: summary,
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return marshalResponse(resp)
+}
+
+// marshalResponse converts the response to JSON string.
+func marshalResponse(resp ToolResponse) string {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		// Fallback to plain text error if JSON marshaling fails
+		return fmt.Sprintf(`{"ok":false,"summary":"JSON marshaling error","error":"%s"}`, err.Error())
+	}
+	return string(data)
+}
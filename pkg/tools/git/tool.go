@@ -0,0 +1,558 @@
+// Package git provides the git tool, which runs typed git operations in the
+// work directory and returns structured results instead of raw output.
+//
+// Operations that can lose work (forced branch deletion or reset, switching
+// branches over local changes, amending, dropping stashes) are refused by the
+// security validator unless tools.git.allow_destructive is set.
+package git
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Zerofisher/goai/pkg/git"
+)
+
+const (
+	// maxLogCount is the largest accepted max_count for log.
+	maxLogCount = 200
+)
+
+// Actions supported by the tool.
+var actions = []string{"status", "diff", "log", "show", "blame", "branch", "add", "commit", "stash"}
+
+// Operations of the branch and stash actions; the first is the default.
+var (
+	branchOperations = []string{"list", "create", "switch", "delete"}
+	stashOperations  = []string{"list", "push", "pop", "apply", "drop"}
+)
+
+// GitTool runs git operations in the work directory.
+type GitTool struct {
+	workDir string
+	repo    *git.Repo
+}
+
+// NewGitTool creates a git tool for the repository containing workDir.
+func NewGitTool(workDir string) *GitTool {
+	return &GitTool{
+		workDir: workDir,
+		repo:    git.New(workDir),
+	}
+}
+
+// Name returns the name of the tool.
+func (t *GitTool) Name() string {
+	return "git"
+}
+
+// Description returns the description of the tool.
+func (t *GitTool) Description() string {
+	return "Run git operations with structured results: status, diff (unstaged, staged or a revision range), log, show, blame, branch (list/create/switch/delete), add, commit and stash (list/push/pop/apply/drop). Operations that can lose work, such as force-deleting a branch, switching with force, amending or dropping a stash, require permission. Prefer this over running git through bash"
+}
+
+// InputSchema returns the JSON schema for the input.
+func (t *GitTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"enum":        actions,
+				"description": "The git operation to run",
+			},
+			"operation": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{"list", "create", "switch", "delete", "push", "pop", "apply", "drop"},
+				"description": "For branch: list, create, switch or delete. For stash: list, push, pop, apply or drop (default: list)",
+			},
+			"staged": map[string]interface{}{
+				"type":        "boolean",
+				"description": "For diff: compare the index with HEAD instead of the work tree with the index",
+			},
+			"range": map[string]interface{}{
+				"type":        "string",
+				"description": "For diff and log: a revision range such as 'main..HEAD', or one revision (diff compares it with the work tree)",
+			},
+			"rev": map[string]interface{}{
+				"type":        "string",
+				"description": "For show and blame: the revision (default: HEAD for show, the work tree for blame)",
+			},
+			"paths": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "For diff, log, show, add and stash push: limit to these paths",
+			},
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "For blame: the file",
+			},
+			"start_line": map[string]interface{}{
+				"type":        "integer",
+				"description": "For blame: first line (1-based)",
+			},
+			"end_line": map[string]interface{}{
+				"type":        "integer",
+				"description": "For blame: last line (inclusive)",
+			},
+			"stat_only": map[string]interface{}{
+				"type":        "boolean",
+				"description": "For diff and show: only per-file line counts, no patch",
+			},
+			"max_count": map[string]interface{}{
+				"type":        "integer",
+				"description": fmt.Sprintf("For log: number of commits (default: 20, max: %d)", maxLogCount),
+			},
+			"author": map[string]interface{}{
+				"type":        "string",
+				"description": "For log: only commits by this author name or email pattern",
+			},
+			"grep": map[string]interface{}{
+				"type":        "string",
+				"description": "For log: only commits whose message matches this pattern (case-insensitive)",
+			},
+			"since": map[string]interface{}{
+				"type":        "string",
+				"description": "For log: only commits after this date, e.g. '2 weeks ago' or '2024-01-31'",
+			},
+			"name": map[string]interface{}{
+				"type":        "string",
+				"description": "For branch create, switch and delete: the branch name",
+			},
+			"start_point": map[string]interface{}{
+				"type":        "string",
+				"description": "For branch create: the revision the branch starts at (default: HEAD)",
+			},
+			"force": map[string]interface{}{
+				"type":        "boolean",
+				"description": "For branch: reset an existing branch (create), discard local changes (switch) or delete an unmerged branch (delete)",
+			},
+			"message": map[string]interface{}{
+				"type":        "string",
+				"description": "For commit: the commit message. For stash push: the stash description",
+			},
+			"all": map[string]interface{}{
+				"type":        "boolean",
+				"description": "For commit: stage modified and deleted tracked files first",
+			},
+			"amend": map[string]interface{}{
+				"type":        "boolean",
+				"description": "For commit: replace the last commit",
+			},
+			"include_untracked": map[string]interface{}{
+				"type":        "boolean",
+				"description": "For stash push: also stash untracked files",
+			},
+			"index": map[string]interface{}{
+				"type":        "integer",
+				"description": "For stash pop, apply and drop: the stash entry (default: 0, the newest)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+// Validate checks if the input is valid.
+func (t *GitTool) Validate(input map[string]interface{}) error {
+	action, ok := input["action"].(string)
+	if !ok || action == "" {
+		return fmt.Errorf("action is required")
+	}
+	if !contains(actions, action) {
+		return fmt.Errorf("unknown action %q (available: %s)", action, strings.Join(actions, ", "))
+	}
+
+	for _, key := range []string{"range", "rev", "path", "author", "grep", "since", "name", "start_point", "message", "operation"} {
+		if raw, ok := input[key]; ok {
+			if _, ok := raw.(string); !ok {
+				return fmt.Errorf("%s must be a string", key)
+			}
+		}
+	}
+	// Revisions and names are passed as arguments, so they must not be
+	// mistaken for options
+	for _, key := range []string{"range", "rev", "name", "start_point"} {
+		if value := stringParam(input, key); strings.HasPrefix(value, "-") {
+			return fmt.Errorf("invalid %s %q: must not start with '-'", key, value)
+		}
+	}
+
+	for _, key := range []string{"staged", "stat_only", "force", "all", "amend", "include_untracked"} {
+		if raw, ok := input[key]; ok {
+			if _, ok := raw.(bool); !ok {
+				return fmt.Errorf("%s must be a boolean", key)
+			}
+		}
+	}
+
+	if raw, ok := input["paths"]; ok {
+		list, ok := raw.([]interface{})
+		if !ok {
+			return fmt.Errorf("paths must be an array of strings")
+		}
+		for _, item := range list {
+			if path, ok := item.(string); !ok || strings.TrimSpace(path) == "" {
+				return fmt.Errorf("paths must be non-empty strings")
+			}
+		}
+	}
+
+	for _, key := range []string{"start_line", "end_line", "max_count", "index"} {
+		if raw, ok := input[key]; ok {
+			value, ok := raw.(float64)
+			if !ok || value != float64(int(value)) || value < 0 {
+				return fmt.Errorf("%s must be a non-negative integer", key)
+			}
+		}
+	}
+	if count := intParam(input, "max_count"); count > maxLogCount {
+		return fmt.Errorf("max_count must be at most %d", maxLogCount)
+	}
+	if start, end := intParam(input, "start_line"), intParam(input, "end_line"); start > 0 && end > 0 && end < start {
+		return fmt.Errorf("end_line must not be before start_line")
+	}
+
+	operation := stringParam(input, "operation")
+	switch action {
+	case "blame":
+		if stringParam(input, "path") == "" {
+			return fmt.Errorf("path is required for blame")
+		}
+	case "add":
+		if len(stringsParam(input, "paths")) == 0 {
+			return fmt.Errorf("paths is required for add")
+		}
+	case "commit":
+		if strings.TrimSpace(stringParam(input, "message")) == "" {
+			return fmt.Errorf("message is required for commit")
+		}
+	case "branch":
+		if operation != "" && !contains(branchOperations, operation) {
+			return fmt.Errorf("unknown branch operation %q (available: %s)", operation, strings.Join(branchOperations, ", "))
+		}
+		if operation != "" && operation != "list" && stringParam(input, "name") == "" {
+			return fmt.Errorf("name is required for branch %s", operation)
+		}
+	case "stash":
+		if operation != "" && !contains(stashOperations, operation) {
+			return fmt.Errorf("unknown stash operation %q (available: %s)", operation, strings.Join(stashOperations, ", "))
+		}
+	default:
+		if operation != "" {
+			return fmt.Errorf("operation only applies to branch and stash")
+		}
+	}
+
+	return nil
+}
+
+// Execute runs the requested git operation.
+func (t *GitTool) Execute(ctx context.Context, input map[string]interface{}) (string, error) {
+	if err := t.Validate(input); err != nil {
+		return Error("Invalid input", err), nil
+	}
+	if !t.repo.IsRepo(ctx) {
+		return Error("Not a git repository", fmt.Errorf("%s: %w", t.workDir, git.ErrNotRepository)), nil
+	}
+
+	switch stringParam(input, "action") {
+	case "status":
+		return t.status(ctx)
+	case "diff":
+		return t.diff(ctx, input)
+	case "log":
+		return t.log(ctx, input)
+	case "show":
+		return t.show(ctx, input)
+	case "blame":
+		return t.blame(ctx, input)
+	case "branch":
+		return t.branch(ctx, input)
+	case "add":
+		return t.add(ctx, input)
+	case "commit":
+		return t.commit(ctx, input)
+	default:
+		return t.stash(ctx, input)
+	}
+}
+
+func (t *GitTool) status(ctx context.Context) (string, error) {
+	status, err := t.repo.Status(ctx)
+	if err != nil {
+		return Error("Failed to get status", err), nil
+	}
+	return Success(statusSummary(status), status), nil
+}
+
+// statusSummary describes a status, e.g. "On main: 2 staged, 1 unstaged".
+func statusSummary(status *git.Status) string {
+	head := "On " + status.Head.String()
+	if status.Head.Branch == "" && !status.Head.Detached {
+		head = "No branch"
+	}
+	if status.Ahead > 0 || status.Behind > 0 {
+		head += fmt.Sprintf(" (ahead %d, behind %d of %s)", status.Ahead, status.Behind, status.Upstream)
+	}
+	if status.Clean() {
+		return head + ": working tree clean"
+	}
+
+	var parts []string
+	for _, part := range []struct {
+		count int
+		label string
+	}{
+		{len(status.Conflicts), "conflicted"},
+		{len(status.Staged), "staged"},
+		{len(status.Unstaged), "unstaged"},
+		{len(status.Untracked), "untracked"},
+	} {
+		if part.count > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", part.count, part.label))
+		}
+	}
+	return head + ": " + strings.Join(parts, ", ")
+}
+
+func (t *GitTool) diff(ctx context.Context, input map[string]interface{}) (string, error) {
+	staged, _ := input["staged"].(bool)
+	diff, err := t.repo.Diff(ctx, t.diffOptions(input, staged))
+	if err != nil {
+		return Error("Failed to get diff", err), nil
+	}
+
+	what := "unstaged"
+	switch {
+	case stringParam(input, "range") != "":
+		what = stringParam(input, "range")
+	case staged:
+		what = "staged"
+	}
+	if len(diff.Files) == 0 {
+		return Success(fmt.Sprintf("No %s changes", what), diff), nil
+	}
+	return Success(fmt.Sprintf("%s (%s)", diffSummary(diff), what), diff), nil
+}
+
+func (t *GitTool) diffOptions(input map[string]interface{}, staged bool) git.DiffOptions {
+	statOnly, _ := input["stat_only"].(bool)
+	return git.DiffOptions{
+		Staged:   staged,
+		Range:    stringParam(input, "range"),
+		Paths:    stringsParam(input, "paths"),
+		StatOnly: statOnly,
+	}
+}
+
+// diffSummary describes a diff, e.g. "3 files changed, +10 -2".
+func diffSummary(diff *git.Diff) string {
+	summary := fmt.Sprintf("%d %s changed, +%d -%d", len(diff.Files), plural(len(diff.Files), "file"), diff.Added, diff.Removed)
+	if diff.Truncated {
+		summary += ", patch truncated"
+	}
+	return summary
+}
+
+func (t *GitTool) log(ctx context.Context, input map[string]interface{}) (string, error) {
+	commits, err := t.repo.Log(ctx, git.LogOptions{
+		Range:    stringParam(input, "range"),
+		Paths:    stringsParam(input, "paths"),
+		MaxCount: intParam(input, "max_count"),
+		Author:   stringParam(input, "author"),
+		Grep:     stringParam(input, "grep"),
+		Since:    stringParam(input, "since"),
+	})
+	if err != nil {
+		return Error("Failed to get log", err), nil
+	}
+	if commits == nil {
+		commits = []git.Commit{}
+	}
+	data := map[string]interface{}{"commits": commits}
+	return Success(fmt.Sprintf("%d %s", len(commits), plural(len(commits), "commit")), data), nil
+}
+
+func (t *GitTool) show(ctx context.Context, input map[string]interface{}) (string, error) {
+	opts := t.diffOptions(input, false)
+	opts.Range = ""
+	commit, err := t.repo.Show(ctx, stringParam(input, "rev"), opts)
+	if err != nil {
+		return Error("Failed to show commit", err), nil
+	}
+	return Success(fmt.Sprintf("%s %s: %s", commit.ShortHash, commit.Subject, diffSummary(commit.Diff)), commit), nil
+}
+
+func (t *GitTool) blame(ctx context.Context, input map[string]interface{}) (string, error) {
+	path := stringParam(input, "path")
+	lines, err := t.repo.Blame(ctx, path, intParam(input, "start_line"), intParam(input, "end_line"), stringParam(input, "rev"))
+	if err != nil {
+		return Error("Failed to blame", err), nil
+	}
+	commits := make(map[string]bool)
+	for _, line := range lines {
+		commits[line.Commit] = true
+	}
+	if lines == nil {
+		lines = []git.BlameLine{}
+	}
+	data := map[string]interface{}{"path": path, "lines": lines}
+	summary := fmt.Sprintf("Blame of %s: %d %s from %d %s", path, len(lines), plural(len(lines), "line"), len(commits), plural(len(commits), "commit"))
+	return Success(summary, data), nil
+}
+
+func (t *GitTool) branch(ctx context.Context, input map[string]interface{}) (string, error) {
+	name := stringParam(input, "name")
+	force, _ := input["force"].(bool)
+
+	var summary string
+	switch stringParam(input, "operation") {
+	case "create":
+		if err := t.repo.CreateBranch(ctx, name, stringParam(input, "start_point"), force); err != nil {
+			return Error("Failed to create branch", err), nil
+		}
+		summary = "Created branch " + name
+	case "switch":
+		if err := t.repo.SwitchBranch(ctx, name, force); err != nil {
+			return Error("Failed to switch branch", err), nil
+		}
+		summary = "Switched to branch " + name
+	case "delete":
+		if err := t.repo.DeleteBranch(ctx, name, force); err != nil {
+			return Error("Failed to delete branch", err), nil
+		}
+		summary = "Deleted branch " + name
+	}
+
+	branches, err := t.repo.Branches(ctx)
+	if err != nil {
+		return Error("Failed to list branches", err), nil
+	}
+	head, err := t.repo.Head(ctx)
+	if err != nil {
+		return Error("Failed to get HEAD", err), nil
+	}
+	if branches == nil {
+		branches = []git.Branch{}
+	}
+	if summary == "" {
+		summary = fmt.Sprintf("%d %s, on %s", len(branches), plural(len(branches), "branch"), head)
+	}
+	data := map[string]interface{}{"head": head, "branches": branches}
+	return Success(summary, data), nil
+}
+
+func (t *GitTool) add(ctx context.Context, input map[string]interface{}) (string, error) {
+	paths := stringsParam(input, "paths")
+	if err := t.repo.Add(ctx, paths...); err != nil {
+		return Error("Failed to stage files", err), nil
+	}
+	status, err := t.repo.Status(ctx)
+	if err != nil {
+		return Error("Failed to get status", err), nil
+	}
+	summary := fmt.Sprintf("Staged %d %s; %s", len(paths), plural(len(paths), "path"), statusSummary(status))
+	return Success(summary, status), nil
+}
+
+func (t *GitTool) commit(ctx context.Context, input map[string]interface{}) (string, error) {
+	all, _ := input["all"].(bool)
+	amend, _ := input["amend"].(bool)
+	commit, err := t.repo.Commit(ctx, stringParam(input, "message"), git.CommitOptions{All: all, Amend: amend})
+	if err != nil {
+		return Error("Failed to commit", err), nil
+	}
+	verb := "Committed"
+	if amend {
+		verb = "Amended"
+	}
+	return Success(fmt.Sprintf("%s %s: %s", verb, commit.ShortHash, commit.Subject), commit), nil
+}
+
+func (t *GitTool) stash(ctx context.Context, input map[string]interface{}) (string, error) {
+	index := intParam(input, "index")
+	ref := git.StashRef(index)
+
+	var summary string
+	switch stringParam(input, "operation") {
+	case "push":
+		includeUntracked, _ := input["include_untracked"].(bool)
+		saved, err := t.repo.StashPush(ctx, stringParam(input, "message"), includeUntracked, stringsParam(input, "paths")...)
+		if err != nil {
+			return Error("Failed to stash changes", err), nil
+		}
+		summary = "Stashed local changes as " + git.StashRef(0)
+		if !saved {
+			summary = "No local changes to stash"
+		}
+	case "pop":
+		if err := t.repo.StashApply(ctx, index, true); err != nil {
+			return Error("Failed to pop stash", err), nil
+		}
+		summary = "Applied and dropped " + ref
+	case "apply":
+		if err := t.repo.StashApply(ctx, index, false); err != nil {
+			return Error("Failed to apply stash", err), nil
+		}
+		summary = "Applied " + ref
+	case "drop":
+		if err := t.repo.StashDrop(ctx, index); err != nil {
+			return Error("Failed to drop stash", err), nil
+		}
+		summary = "Dropped " + ref
+	}
+
+	stashes, err := t.repo.Stashes(ctx)
+	if err != nil {
+		return Error("Failed to list stashes", err), nil
+	}
+	if stashes == nil {
+		stashes = []git.Stash{}
+	}
+	if summary == "" {
+		summary = fmt.Sprintf("%d %s", len(stashes), plural(len(stashes), "stash"))
+	}
+	return Success(summary, map[string]interface{}{"stashes": stashes}), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// plural returns noun, or its plural when n is not 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return noun
+	}
+	if strings.HasSuffix(noun, "sh") || strings.HasSuffix(noun, "ch") {
+		return noun + "es"
+	}
+	return noun + "s"
+}
+
+func stringParam(input map[string]interface{}, key string) string {
+	val, _ := input[key].(string)
+	return val
+}
+
+func intParam(input map[string]interface{}, key string) int {
+	val, _ := input[key].(float64)
+	return int(val)
+}
+
+func stringsParam(input map[string]interface{}, key string) []string {
+	list, _ := input[key].([]interface{})
+	var values []string
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
@@ -0,0 +1,102 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Zerofisher/goai/pkg/lsp"
+	"github.com/Zerofisher/goai/pkg/tools"
+)
+
+// newTestTools creates the tools for a manager whose only server cannot
+// be started.
+func newTestTools(t *testing.T, workDir string) map[string]tools.Tool {
+	t.Helper()
+	manager := lsp.NewManager(workDir, []lsp.ServerConfig{{
+		Language:   "go",
+		Command:    filepath.Join(workDir, "no-such-server"),
+		Extensions: []string{".go"},
+	}})
+	t.Cleanup(func() { _ = manager.Close() })
+
+	byName := make(map[string]tools.Tool)
+	for _, tool := range NewTools(manager, workDir) {
+		byName[tool.Name()] = tool
+	}
+	return byName
+}
+
+// TestToolsValidate tests input validation of the lsp tools
+func TestToolsValidate(t *testing.T) {
+	byName := newTestTools(t, t.TempDir())
+
+	tests := []struct {
+		tool    string
+		input   map[string]interface{}
+		wantErr bool
+	}{
+		{"lsp_hover", map[string]interface{}{"path": "a.go", "line": float64(1), "column": float64(2)}, false},
+		{"lsp_hover", map[string]interface{}{"path": "a.go", "line": float64(1)}, true},
+		{"lsp_definition", map[string]interface{}{"path": "a.go", "line": float64(0), "column": float64(1)}, true},
+		{"lsp_references", map[string]interface{}{"line": float64(1), "column": float64(1)}, true},
+		{"lsp_rename_preview", map[string]interface{}{"path": "a.go", "line": float64(1), "column": float64(1), "new_name": "B"}, false},
+		{"lsp_rename_preview", map[string]interface{}{"path": "a.go", "line": float64(1), "column": float64(1)}, true},
+		{"lsp_workspace_symbols", map[string]interface{}{"query": "Foo", "language": "go"}, false},
+		{"lsp_workspace_symbols", map[string]interface{}{"query": "Foo", "language": "rust"}, true},
+		{"lsp_workspace_symbols", map[string]interface{}{"language": "go"}, true},
+		{"lsp_diagnostics", map[string]interface{}{"path": "a.go"}, false},
+		{"lsp_diagnostics", map[string]interface{}{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			err := byName[tt.tool].Validate(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate(%v) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestToolsErrors tests that failures are reported in the response
+func TestToolsErrors(t *testing.T) {
+	dir := t.TempDir()
+	byName := newTestTools(t, dir)
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app.rb"), []byte("puts 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		input   map[string]interface{}
+		wantErr string
+	}{
+		{"outside work directory", map[string]interface{}{"path": "../outside.go"}, "outside"},
+		{"missing file", map[string]interface{}{"path": "missing.go"}, "cannot access"},
+		{"unconfigured language", map[string]interface{}{"path": "app.rb"}, "no language server configured"},
+		{"server fails to start", map[string]interface{}{"path": "main.go"}, "failed to start go language server"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := byName["lsp_diagnostics"].Execute(context.Background(), tt.input)
+			if err != nil {
+				t.Fatalf("Execute returned error: %v", err)
+			}
+			var resp ToolResponse
+			if err := json.Unmarshal([]byte(out), &resp); err != nil {
+				t.Fatalf("invalid JSON response: %v", err)
+			}
+			if resp.Ok || !strings.Contains(resp.Error, tt.wantErr) {
+				t.Errorf("expected error containing %q, got %s", tt.wantErr, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ToolResponse represents the standardized JSON response format for the
+// language server tools: {"ok":true,"summary":"...","data":{...}}
+type ToolResponse struct {
+	Ok      bool        `json:"ok"`
+	Summary string      `json:"summary"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// Location is a source range reported by a language server. Paths are
+// relative to the work directory when inside it.
+type Location struct {
+	Position  string `json:"position"` // file:line:col
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"end_line"`
+	EndColumn int    `json:"end_column"`
+}
+
+// HoverData contains the result of lsp_hover.
+type HoverData struct {
+	Position string `json:"position"`
+	Language string `json:"language"`
+	Contents string `json:"contents"`
+}
+
+// LocationsData contains the result of lsp_definition and lsp_references.
+type LocationsData struct {
+	Position  string     `json:"position"`
+	Language  string     `json:"language"`
+	Locations []Location `json:"locations"`
+	Count     int        `json:"count"`
+	Truncated bool       `json:"truncated,omitempty"`
+}
+
+// RenameData contains the edits a rename would make. Nothing is written.
+type RenameData struct {
+	Position string     `json:"position"`
+	NewName  string     `json:"new_name"`
+	Language string     `json:"language"`
+	Files    []FileEdit `json:"files"`
+	Edits    int        `json:"edits"`
+}
+
+// FileEdit lists the edits to one file.
+type FileEdit struct {
+	File  string     `json:"file"`
+	Edits []TextEdit `json:"edits"`
+}
+
+// TextEdit replaces a range with new text.
+type TextEdit struct {
+	Location
+	NewText string `json:"new_text"`
+}
+
+// SymbolsData contains the result of lsp_workspace_symbols.
+type SymbolsData struct {
+	Query     string   `json:"query"`
+	Language  string   `json:"language"`
+	Symbols   []Symbol `json:"symbols"`
+	Count     int      `json:"count"`
+	Truncated bool     `json:"truncated,omitempty"`
+}
+
+// Symbol is a workspace symbol.
+type Symbol struct {
+	Location
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Container string `json:"container,omitempty"`
+}
+
+// DiagnosticsData contains the result of lsp_diagnostics.
+type DiagnosticsData struct {
+	File        string       `json:"file"`
+	Language    string       `json:"language"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	Count       int          `json:"count"`
+}
+
+// Diagnostic is a problem reported by a language server.
+type Diagnostic struct {
+	Location
+	Severity string `json:"severity"`
+	Source   string `json:"source,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Success creates a successful response with data.
+func Success(summary string, data interface{}) string {
+	resp := ToolResponse{
+		Ok:      true,
+		Summary: summary,
+		Data:    data,
+	}
+	return marshalResponse(resp)
+}
+
+// Error creates an error response.
+func Error(summary string, err error) string {
+	resp := ToolResponse{
+		Ok:      false,
+		Summary: summary,
+		Error:   err.Error(),
+	}
+	return marshalResponse(resp)
+}
+
+// marshalResponse converts the response to JSON string.
+func marshalResponse(resp ToolResponse) string {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		// Fallback to plain text error if JSON marshaling fails
+		return fmt.Sprintf(`{"ok":false,"summary":"JSON marshaling error","error":"%s"}`, err.Error())
+	}
+	return string(data)
+}
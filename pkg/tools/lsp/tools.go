@@ -0,0 +1,580 @@
+// Package lsp exposes language server features as agent tools.
+//
+// All tools share one lsp.Manager, so each language server is started on
+// first use and reused for the rest of the session. Closing any of the tools
+// shuts the servers down.
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Zerofisher/goai/pkg/lsp"
+	"github.com/Zerofisher/goai/pkg/tools"
+)
+
+// defaultMaxResults bounds location and symbol lists.
+const defaultMaxResults = 200
+
+// NewTools creates all language server tools backed by manager.
+func NewTools(manager *lsp.Manager, workDir string) []tools.Tool {
+	base := newBaseTool(manager, workDir)
+	return []tools.Tool{
+		&HoverTool{base},
+		&DefinitionTool{base},
+		&ReferencesTool{base},
+		&RenamePreviewTool{base},
+		&WorkspaceSymbolsTool{base},
+		&DiagnosticsTool{base},
+	}
+}
+
+// baseTool holds the state shared by the language server tools.
+type baseTool struct {
+	manager  *lsp.Manager
+	workDir  string
+	security *tools.DefaultSecurityValidator
+}
+
+func newBaseTool(manager *lsp.Manager, workDir string) *baseTool {
+	return &baseTool{
+		manager:  manager,
+		workDir:  workDir,
+		security: tools.NewSecurityValidator(workDir),
+	}
+}
+
+// Close shuts down the language servers. It is safe to call from every
+// tool sharing the manager.
+func (b *baseTool) Close() error {
+	return b.manager.Close()
+}
+
+// positionSchema returns the schema properties for a file position.
+func positionSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"path": map[string]interface{}{
+			"type":        "string",
+			"description": "File path (relative to work directory)",
+		},
+		"line": map[string]interface{}{
+			"type":        "integer",
+			"description": "Line number (1-based)",
+			"minimum":     1,
+		},
+		"column": map[string]interface{}{
+			"type":        "integer",
+			"description": "Column of the identifier (1-based, in bytes)",
+			"minimum":     1,
+		},
+	}
+}
+
+// validatePosition checks path, line and column.
+func (b *baseTool) validatePosition(input map[string]interface{}) error {
+	if err := b.validatePath(input); err != nil {
+		return err
+	}
+	for _, key := range []string{"line", "column"} {
+		n, ok := getInt(input, key)
+		if !ok {
+			return fmt.Errorf("missing required field: %s", key)
+		}
+		if n < 1 {
+			return fmt.Errorf("%s must be >= 1", key)
+		}
+	}
+	return nil
+}
+
+// validatePath checks that path is present.
+func (b *baseTool) validatePath(input map[string]interface{}) error {
+	path, ok := input["path"].(string)
+	if !ok || path == "" {
+		return fmt.Errorf("missing required field: path")
+	}
+	return nil
+}
+
+// resolve validates path against the work directory and returns its
+// absolute form.
+func (b *baseTool) resolve(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(b.workDir, path)
+	}
+	path = filepath.Clean(path)
+	if err := b.security.ValidatePath(path); err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot access %s: %w", b.rel(path), err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", b.rel(path))
+	}
+	return path, nil
+}
+
+// client resolves the input path and returns it with the client for its
+// language.
+func (b *baseTool) client(ctx context.Context, input map[string]interface{}) (*lsp.Client, string, error) {
+	path, err := b.resolve(input["path"].(string))
+	if err != nil {
+		return nil, "", err
+	}
+	c, err := b.manager.ClientFor(ctx, path)
+	if err != nil {
+		return nil, "", err
+	}
+	return c, path, nil
+}
+
+// rel returns path relative to the work directory when inside it.
+func (b *baseTool) rel(path string) string {
+	if rel, err := filepath.Rel(b.workDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// location converts a client location for output.
+func (b *baseTool) location(loc lsp.Location) Location {
+	file := b.rel(loc.Path)
+	return Location{
+		Position:  fmt.Sprintf("%s:%d:%d", file, loc.Line, loc.Column),
+		File:      file,
+		Line:      loc.Line,
+		Column:    loc.Column,
+		EndLine:   loc.EndLine,
+		EndColumn: loc.EndColumn,
+	}
+}
+
+// position formats the queried position.
+func (b *baseTool) position(path string, input map[string]interface{}) string {
+	line, _ := getInt(input, "line")
+	column, _ := getInt(input, "column")
+	return fmt.Sprintf("%s:%d:%d", b.rel(path), line, column)
+}
+
+// locations converts a location list, truncating it to maxResults.
+func (b *baseTool) locations(locs []lsp.Location, maxResults int) ([]Location, bool) {
+	out := make([]Location, 0, len(locs))
+	for _, loc := range locs {
+		out = append(out, b.location(loc))
+	}
+	if len(out) > maxResults {
+		return out[:maxResults], true
+	}
+	return out, false
+}
+
+// getInt reads an integer input, accepting JSON numbers.
+func getInt(input map[string]interface{}, key string) (int, bool) {
+	switch v := input[key].(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	}
+	return 0, false
+}
+
+// maxResults returns the max_results input or the default.
+func maxResults(input map[string]interface{}) int {
+	if n, ok := getInt(input, "max_results"); ok && n > 0 {
+		return n
+	}
+	return defaultMaxResults
+}
+
+// HoverTool shows type information and documentation for a position.
+type HoverTool struct{ *baseTool }
+
+// Name returns the name of the tool.
+func (t *HoverTool) Name() string {
+	return "lsp_hover"
+}
+
+// Description returns the description of the tool.
+func (t *HoverTool) Description() string {
+	return "Show the language server's hover information (type, signature, documentation) for the identifier at a file position. Works for any language with a configured server"
+}
+
+// InputSchema returns the JSON schema for the input.
+func (t *HoverTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": positionSchema(),
+		"required":   []string{"path", "line", "column"},
+	}
+}
+
+// Validate checks if the input is valid.
+func (t *HoverTool) Validate(input map[string]interface{}) error {
+	return t.validatePosition(input)
+}
+
+// Execute runs the hover request.
+func (t *HoverTool) Execute(ctx context.Context, input map[string]interface{}) (string, error) {
+	if err := t.Validate(input); err != nil {
+		return Error("Invalid input", err), nil
+	}
+	c, path, err := t.client(ctx, input)
+	if err != nil {
+		return Error("Language server unavailable", err), nil
+	}
+	line, _ := getInt(input, "line")
+	column, _ := getInt(input, "column")
+
+	text, err := c.Hover(ctx, path, line, column)
+	if err != nil {
+		return Error("Hover failed", err), nil
+	}
+
+	pos := t.position(path, input)
+	summary := fmt.Sprintf("Hover information for %s", pos)
+	if text == "" {
+		summary = fmt.Sprintf("No hover information for %s", pos)
+	}
+	return Success(summary, HoverData{Position: pos, Language: c.Language(), Contents: text}), nil
+}
+
+// DefinitionTool finds where the identifier at a position is defined.
+type DefinitionTool struct{ *baseTool }
+
+// Name returns the name of the tool.
+func (t *DefinitionTool) Name() string {
+	return "lsp_definition"
+}
+
+// Description returns the description of the tool.
+func (t *DefinitionTool) Description() string {
+	return "Find the definition of the identifier at a file position using the language server"
+}
+
+// InputSchema returns the JSON schema for the input.
+func (t *DefinitionTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": positionSchema(),
+		"required":   []string{"path", "line", "column"},
+	}
+}
+
+// Validate checks if the input is valid.
+func (t *DefinitionTool) Validate(input map[string]interface{}) error {
+	return t.validatePosition(input)
+}
+
+// Execute runs the definition request.
+func (t *DefinitionTool) Execute(ctx context.Context, input map[string]interface{}) (string, error) {
+	if err := t.Validate(input); err != nil {
+		return Error("Invalid input", err), nil
+	}
+	c, path, err := t.client(ctx, input)
+	if err != nil {
+		return Error("Language server unavailable", err), nil
+	}
+	line, _ := getInt(input, "line")
+	column, _ := getInt(input, "column")
+
+	locs, err := c.Definition(ctx, path, line, column)
+	if err != nil {
+		return Error("Definition lookup failed", err), nil
+	}
+
+	pos := t.position(path, input)
+	out, truncated := t.locations(locs, defaultMaxResults)
+	data := LocationsData{Position: pos, Language: c.Language(), Locations: out, Count: len(locs), Truncated: truncated}
+	return Success(fmt.Sprintf("Found %d definition(s) for %s", len(locs), pos), data), nil
+}
+
+// ReferencesTool finds all references to the identifier at a position.
+type ReferencesTool struct{ *baseTool }
+
+// Name returns the name of the tool.
+func (t *ReferencesTool) Name() string {
+	return "lsp_references"
+}
+
+// Description returns the description of the tool.
+func (t *ReferencesTool) Description() string {
+	return "Find all references to the identifier at a file position using the language server"
+}
+
+// InputSchema returns the JSON schema for the input.
+func (t *ReferencesTool) InputSchema() map[string]interface{} {
+	props := positionSchema()
+	props["include_declaration"] = map[string]interface{}{
+		"type":        "boolean",
+		"description": "Include the declaration itself in the results",
+		"default":     true,
+	}
+	props["max_results"] = map[string]interface{}{
+		"type":        "integer",
+		"description": "Maximum number of locations to return",
+		"default":     defaultMaxResults,
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+		"required":   []string{"path", "line", "column"},
+	}
+}
+
+// Validate checks if the input is valid.
+func (t *ReferencesTool) Validate(input map[string]interface{}) error {
+	return t.validatePosition(input)
+}
+
+// Execute runs the references request.
+func (t *ReferencesTool) Execute(ctx context.Context, input map[string]interface{}) (string, error) {
+	if err := t.Validate(input); err != nil {
+		return Error("Invalid input", err), nil
+	}
+	c, path, err := t.client(ctx, input)
+	if err != nil {
+		return Error("Language server unavailable", err), nil
+	}
+	line, _ := getInt(input, "line")
+	column, _ := getInt(input, "column")
+	includeDecl := true
+	if v, ok := input["include_declaration"].(bool); ok {
+		includeDecl = v
+	}
+
+	locs, err := c.References(ctx, path, line, column, includeDecl)
+	if err != nil {
+		return Error("References lookup failed", err), nil
+	}
+
+	pos := t.position(path, input)
+	out, truncated := t.locations(locs, maxResults(input))
+	data := LocationsData{Position: pos, Language: c.Language(), Locations: out, Count: len(locs), Truncated: truncated}
+	return Success(fmt.Sprintf("Found %d reference(s) for %s", len(locs), pos), data), nil
+}
+
+// RenamePreviewTool shows the edits a rename would make without applying
+// them.
+type RenamePreviewTool struct{ *baseTool }
+
+// Name returns the name of the tool.
+func (t *RenamePreviewTool) Name() string {
+	return "lsp_rename_preview"
+}
+
+// Description returns the description of the tool.
+func (t *RenamePreviewTool) Description() string {
+	return "Preview the edits the language server would make to rename the identifier at a file position. Nothing is written; apply the edits with edit_file"
+}
+
+// InputSchema returns the JSON schema for the input.
+func (t *RenamePreviewTool) InputSchema() map[string]interface{} {
+	props := positionSchema()
+	props["new_name"] = map[string]interface{}{
+		"type":        "string",
+		"description": "New name for the identifier",
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+		"required":   []string{"path", "line", "column", "new_name"},
+	}
+}
+
+// Validate checks if the input is valid.
+func (t *RenamePreviewTool) Validate(input map[string]interface{}) error {
+	if err := t.validatePosition(input); err != nil {
+		return err
+	}
+	if name, _ := input["new_name"].(string); strings.TrimSpace(name) == "" {
+		return fmt.Errorf("missing required field: new_name")
+	}
+	return nil
+}
+
+// Execute runs the rename request and returns the resulting edits.
+func (t *RenamePreviewTool) Execute(ctx context.Context, input map[string]interface{}) (string, error) {
+	if err := t.Validate(input); err != nil {
+		return Error("Invalid input", err), nil
+	}
+	c, path, err := t.client(ctx, input)
+	if err != nil {
+		return Error("Language server unavailable", err), nil
+	}
+	line, _ := getInt(input, "line")
+	column, _ := getInt(input, "column")
+	newName := input["new_name"].(string)
+
+	fileEdits, err := c.Rename(ctx, path, line, column, newName)
+	if err != nil {
+		return Error("Rename preview failed", err), nil
+	}
+
+	data := RenameData{Position: t.position(path, input), NewName: newName, Language: c.Language(), Files: []FileEdit{}}
+	for _, fe := range fileEdits {
+		out := FileEdit{File: t.rel(fe.Path)}
+		for _, e := range fe.Edits {
+			out.Edits = append(out.Edits, TextEdit{Location: t.location(e.Location), NewText: e.NewText})
+		}
+		data.Edits += len(out.Edits)
+		data.Files = append(data.Files, out)
+	}
+	summary := fmt.Sprintf("Rename to %s would make %d edit(s) in %d file(s) (not applied)", newName, data.Edits, len(data.Files))
+	return Success(summary, data), nil
+}
+
+// WorkspaceSymbolsTool searches symbols across the workspace.
+type WorkspaceSymbolsTool struct{ *baseTool }
+
+// Name returns the name of the tool.
+func (t *WorkspaceSymbolsTool) Name() string {
+	return "lsp_workspace_symbols"
+}
+
+// Description returns the description of the tool.
+func (t *WorkspaceSymbolsTool) Description() string {
+	return "Search the workspace for symbols (types, functions, classes, ...) by name using a language server"
+}
+
+// InputSchema returns the JSON schema for the input.
+func (t *WorkspaceSymbolsTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"query": map[string]interface{}{
+				"type":        "string",
+				"description": "Symbol name or fragment to search for",
+			},
+			"language": map[string]interface{}{
+				"type":        "string",
+				"enum":        t.manager.Languages(),
+				"description": "Language server to ask",
+			},
+			"max_results": map[string]interface{}{
+				"type":        "integer",
+				"description": "Maximum number of symbols to return",
+				"default":     defaultMaxResults,
+			},
+		},
+		"required": []string{"query", "language"},
+	}
+}
+
+// Validate checks if the input is valid.
+func (t *WorkspaceSymbolsTool) Validate(input map[string]interface{}) error {
+	if query, _ := input["query"].(string); query == "" {
+		return fmt.Errorf("missing required field: query")
+	}
+	language, _ := input["language"].(string)
+	if language == "" {
+		return fmt.Errorf("missing required field: language")
+	}
+	for _, lang := range t.manager.Languages() {
+		if lang == language {
+			return nil
+		}
+	}
+	return fmt.Errorf("no language server configured for %q (available: %s)", language, strings.Join(t.manager.Languages(), ", "))
+}
+
+// Execute runs the workspace symbol search.
+func (t *WorkspaceSymbolsTool) Execute(ctx context.Context, input map[string]interface{}) (string, error) {
+	if err := t.Validate(input); err != nil {
+		return Error("Invalid input", err), nil
+	}
+	query := input["query"].(string)
+	language := input["language"].(string)
+
+	c, err := t.manager.Client(ctx, language)
+	if err != nil {
+		return Error("Language server unavailable", err), nil
+	}
+	symbols, err := c.WorkspaceSymbols(ctx, query)
+	if err != nil {
+		return Error("Workspace symbol search failed", err), nil
+	}
+
+	data := SymbolsData{Query: query, Language: language, Symbols: []Symbol{}, Count: len(symbols)}
+	limit := maxResults(input)
+	for i, s := range symbols {
+		if i == limit {
+			data.Truncated = true
+			break
+		}
+		data.Symbols = append(data.Symbols, Symbol{
+			Location:  t.location(s.Location),
+			Name:      s.Name,
+			Kind:      s.Kind,
+			Container: s.Container,
+		})
+	}
+	return Success(fmt.Sprintf("Found %d symbol(s) matching %q", len(symbols), query), data), nil
+}
+
+// DiagnosticsTool reports a language server's diagnostics for a file.
+type DiagnosticsTool struct{ *baseTool }
+
+// Name returns the name of the tool.
+func (t *DiagnosticsTool) Name() string {
+	return "lsp_diagnostics"
+}
+
+// Description returns the description of the tool.
+func (t *DiagnosticsTool) Description() string {
+	return "Report the language server's diagnostics (errors, warnings) for a file as it currently is on disk"
+}
+
+// InputSchema returns the JSON schema for the input.
+func (t *DiagnosticsTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": positionSchema()["path"],
+		},
+		"required": []string{"path"},
+	}
+}
+
+// Validate checks if the input is valid.
+func (t *DiagnosticsTool) Validate(input map[string]interface{}) error {
+	return t.validatePath(input)
+}
+
+// Execute collects the diagnostics for the file.
+func (t *DiagnosticsTool) Execute(ctx context.Context, input map[string]interface{}) (string, error) {
+	if err := t.Validate(input); err != nil {
+		return Error("Invalid input", err), nil
+	}
+	c, path, err := t.client(ctx, input)
+	if err != nil {
+		return Error("Language server unavailable", err), nil
+	}
+
+	diags, err := c.Diagnostics(ctx, path)
+	if err != nil {
+		return Error("Diagnostics failed", err), nil
+	}
+
+	data := DiagnosticsData{File: t.rel(path), Language: c.Language(), Diagnostics: []Diagnostic{}, Count: len(diags)}
+	errorCount := 0
+	for _, d := range diags {
+		if d.Severity == "error" {
+			errorCount++
+		}
+		data.Diagnostics = append(data.Diagnostics, Diagnostic{
+			Location: t.location(d.Location),
+			Severity: d.Severity,
+			Source:   d.Source,
+			Code:     d.Code,
+			Message:  d.Message,
+		})
+	}
+	summary := fmt.Sprintf("%d diagnostic(s) in %s (%d error(s))", len(diags), data.File, errorCount)
+	return Success(summary, data), nil
+}
@@ -0,0 +1,445 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Zerofisher/goai/pkg/ignore"
+)
+
+// Mode selects how a search pattern is interpreted.
+type Mode string
+
+const (
+	// ModeRegex matches a regular expression (RE2 syntax) against each line.
+	ModeRegex Mode = "regex"
+	// ModeLiteral matches the pattern as plain text against each line.
+	ModeLiteral Mode = "literal"
+	// ModeMultiline matches a regular expression against whole files, so
+	// matches may span lines. ^ and $ match at line boundaries.
+	ModeMultiline Mode = "multiline"
+	// ModeRanked treats the pattern as a natural-language query and ranks
+	// whole files with the TextIndex instead of matching lines.
+	ModeRanked Mode = "ranked"
+)
+
+const (
+	// maxFileSize is the largest file the engine reads.
+	maxFileSize = 10 * 1024 * 1024
+	// binarySniffSize is how many leading bytes are checked for NUL bytes.
+	binarySniffSize = 8000
+)
+
+// fileTypes maps type filter names to the file globs they cover.
+var fileTypes = map[string][]string{
+	"go":       {"*.go", "go.mod", "go.sum"},
+	"py":       {"*.py", "*.pyi"},
+	"js":       {"*.js", "*.jsx", "*.mjs", "*.cjs"},
+	"ts":       {"*.ts", "*.tsx", "*.mts", "*.cts"},
+	"java":     {"*.java"},
+	"c":        {"*.c", "*.h"},
+	"cpp":      {"*.cpp", "*.cc", "*.cxx", "*.hpp", "*.hh", "*.hxx", "*.h"},
+	"cs":       {"*.cs"},
+	"rust":     {"*.rs"},
+	"ruby":     {"*.rb"},
+	"php":      {"*.php"},
+	"swift":    {"*.swift"},
+	"kotlin":   {"*.kt", "*.kts"},
+	"sh":       {"*.sh", "*.bash", "*.zsh"},
+	"sql":      {"*.sql"},
+	"html":     {"*.html", "*.htm"},
+	"css":      {"*.css", "*.scss", "*.sass", "*.less"},
+	"json":     {"*.json"},
+	"yaml":     {"*.yaml", "*.yml"},
+	"toml":     {"*.toml"},
+	"markdown": {"*.md", "*.markdown"},
+	"proto":    {"*.proto"},
+}
+
+// FileTypes returns the names accepted by type filters, sorted.
+func FileTypes() []string {
+	names := make([]string, 0, len(fileTypes))
+	for name := range fileTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Query describes a single engine search.
+type Query struct {
+	Pattern       string
+	Mode          Mode
+	CaseSensitive bool
+	WholeWord     bool
+	Globs         []string // File name globs; a glob containing "/" matches the relative path
+	Types         []string // Type filter names, see FileTypes
+	Context       int      // Lines of context around each match (line modes only)
+	MaxResults    int      // 0 means unlimited
+}
+
+// Engine is an in-process, parallel code search engine. It walks the tree
+// with the ignore package, so .gitignore and .ignore files are honored,
+// skips binary and oversized files and searches files concurrently.
+type Engine struct {
+	root    string
+	opts    ignore.WalkOptions
+	workers int
+}
+
+// NewEngine creates a search engine rooted at root.
+func NewEngine(root string) *Engine {
+	return &Engine{
+		root:    root,
+		workers: runtime.GOMAXPROCS(0),
+	}
+}
+
+// SetExcludePatterns sets extra gitignore-style patterns to exclude.
+func (e *Engine) SetExcludePatterns(patterns []string) {
+	e.opts.Excludes = patterns
+}
+
+// SetIncludeHidden controls whether dot files and directories are searched.
+func (e *Engine) SetIncludeHidden(include bool) {
+	e.opts.IncludeHidden = include
+}
+
+// Files returns the relative paths of the searchable files matching the
+// globs and type filters, in lexical order.
+func (e *Engine) Files(ctx context.Context, globs, types []string) ([]string, error) {
+	filter, err := newFileFilter(globs, types)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	err = ignore.Walk(ctx, e.root, e.opts, func(rel string, d fs.DirEntry) error {
+		if d.Type().IsRegular() && filter.match(rel) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// Search runs the query and returns matches ordered by file, in walk order,
+// and line. Files are searched concurrently but their results are taken in
+// walk order, so with MaxResults the first matches are always the same;
+// the walk stops once they are known.
+func (e *Engine) Search(ctx context.Context, q Query) ([]Result, error) {
+	re, err := q.compile()
+	if err != nil {
+		return nil, err
+	}
+	filter, err := newFileFilter(q.Globs, q.Types)
+	if err != nil {
+		return nil, err
+	}
+
+	searchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type job struct {
+		seq int
+		rel string
+	}
+	paths := make(chan job, 256)
+	var walkErr error
+	go func() {
+		defer close(paths)
+		seq := 0
+		walkErr = ignore.Walk(searchCtx, e.root, e.opts, func(rel string, d fs.DirEntry) error {
+			if !d.Type().IsRegular() || !filter.match(rel) {
+				return nil
+			}
+			select {
+			case paths <- job{seq, rel}:
+				seq++
+				return nil
+			case <-searchCtx.Done():
+				return searchCtx.Err()
+			}
+		})
+	}()
+
+	// Results of files finished out of order wait in pending until all
+	// files before them are done.
+	var (
+		mu      sync.Mutex
+		results []Result
+		pending = make(map[int][]Result)
+		next    int
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < e.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range paths {
+				if searchCtx.Err() != nil {
+					continue // Drain the channel so the walker can exit
+				}
+				matches := e.searchFile(j.rel, re, q)
+				mu.Lock()
+				pending[j.seq] = matches
+				for {
+					m, ok := pending[next]
+					if !ok {
+						break
+					}
+					delete(pending, next)
+					results = append(results, m...)
+					next++
+				}
+				full := q.MaxResults > 0 && len(results) >= q.MaxResults
+				mu.Unlock()
+				if full {
+					cancel()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("search canceled: %w", ctx.Err())
+	}
+	if walkErr != nil && searchCtx.Err() == nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", e.root, walkErr)
+	}
+
+	if q.MaxResults > 0 && len(results) > q.MaxResults {
+		results = results[:q.MaxResults]
+	}
+	return results, nil
+}
+
+// compile builds the regular expression for the query.
+func (q Query) compile() (*regexp.Regexp, error) {
+	if q.Pattern == "" {
+		return nil, fmt.Errorf("pattern must not be empty")
+	}
+
+	expr := q.Pattern
+	switch q.Mode {
+	case "", ModeRegex, ModeMultiline:
+	case ModeLiteral:
+		expr = regexp.QuoteMeta(expr)
+	default:
+		return nil, fmt.Errorf("invalid search mode: %s", q.Mode)
+	}
+
+	if q.WholeWord {
+		expr = `\b(?:` + expr + `)\b`
+	}
+	// Multi-line flag is used in every mode: it lets the whole-file
+	// pre-check below run with line semantics for ^ and $.
+	flags := "(?m)"
+	if !q.CaseSensitive {
+		flags = "(?mi)"
+	}
+
+	re, err := regexp.Compile(flags + expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return re, nil
+}
+
+// searchFile searches a single file. Unreadable, oversized and binary files
+// yield no results.
+func (e *Engine) searchFile(rel string, re *regexp.Regexp, q Query) []Result {
+	abs := filepath.Join(e.root, filepath.FromSlash(rel))
+	info, err := os.Stat(abs)
+	if err != nil || info.Size() > maxFileSize {
+		return nil
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil || isBinary(data) {
+		return nil
+	}
+
+	// Most files do not match at all; one pass over the whole file is far
+	// cheaper than splitting it into lines first.
+	if !re.Match(data) {
+		return nil
+	}
+
+	if q.Mode == ModeMultiline {
+		return searchMultiline(rel, data, re, q.MaxResults)
+	}
+	return searchLines(rel, data, re, q.Context, q.MaxResults)
+}
+
+// searchLines matches re against each line of data.
+func searchLines(rel string, data []byte, re *regexp.Regexp, contextLines, limit int) []Result {
+	lines := bytes.Split(data, []byte("\n"))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+
+	var results []Result
+	for i, line := range lines {
+		loc := re.FindIndex(line)
+		if loc == nil {
+			continue
+		}
+		result := Result{
+			File:       rel,
+			Line:       i + 1,
+			Column:     loc[0] + 1,
+			Content:    trimLine(line),
+			MatchStart: loc[0],
+			MatchEnd:   loc[1],
+		}
+		if contextLines > 0 {
+			result.Before = lineRange(lines, i-contextLines, i)
+			result.After = lineRange(lines, i+1, i+1+contextLines)
+		}
+		results = append(results, result)
+		if limit > 0 && len(results) >= limit {
+			break
+		}
+	}
+	return results
+}
+
+// searchMultiline matches re against the whole file. Each result covers the
+// lines spanned by one match.
+func searchMultiline(rel string, data []byte, re *regexp.Regexp, limit int) []Result {
+	n := -1
+	if limit > 0 {
+		n = limit
+	}
+
+	var results []Result
+	line, offset := 1, 0
+	for _, loc := range re.FindAllIndex(data, n) {
+		if loc[0] == loc[1] {
+			continue // Skip empty matches
+		}
+		line += bytes.Count(data[offset:loc[0]], []byte("\n"))
+		offset = loc[0]
+
+		lineStart := bytes.LastIndexByte(data[:loc[0]], '\n') + 1
+		lineEnd := loc[1]
+		if data[lineEnd-1] == '\n' {
+			lineEnd-- // A match ending on a newline does not extend onto the next line
+		} else if i := bytes.IndexByte(data[lineEnd:], '\n'); i >= 0 {
+			lineEnd += i
+		} else {
+			lineEnd = len(data)
+		}
+
+		text := data[lineStart:lineEnd]
+		results = append(results, Result{
+			File:       rel,
+			Line:       line,
+			EndLine:    line + bytes.Count(text, []byte("\n")),
+			Column:     loc[0] - lineStart + 1,
+			Content:    strings.TrimRight(strings.ReplaceAll(string(text), "\r\n", "\n"), "\r"),
+			MatchStart: loc[0] - lineStart,
+			MatchEnd:   loc[1] - lineStart,
+		})
+	}
+	return results
+}
+
+// lineRange returns lines[from:to] clamped to the slice bounds.
+func lineRange(lines [][]byte, from, to int) []string {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(lines) {
+		to = len(lines)
+	}
+	var out []string
+	for _, line := range lines[from:to] {
+		out = append(out, trimLine(line))
+	}
+	return out
+}
+
+// trimLine converts a line to a string without its carriage return.
+func trimLine(line []byte) string {
+	return string(bytes.TrimSuffix(line, []byte("\r")))
+}
+
+// isBinary reports whether data looks binary, using the same heuristic as
+// git and grep: a NUL byte near the start of the file.
+func isBinary(data []byte) bool {
+	if len(data) > binarySniffSize {
+		data = data[:binarySniffSize]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// fileFilter selects files by glob and type.
+type fileFilter struct {
+	globs []string
+	types []string
+}
+
+// newFileFilter validates the globs and resolves the type names.
+func newFileFilter(globs, types []string) (*fileFilter, error) {
+	f := &fileFilter{}
+	for _, g := range globs {
+		if g == "" {
+			continue
+		}
+		if _, err := path.Match(g, ""); err != nil {
+			return nil, fmt.Errorf("invalid file pattern %q: %w", g, err)
+		}
+		f.globs = append(f.globs, g)
+	}
+	for _, t := range types {
+		patterns, ok := fileTypes[strings.ToLower(t)]
+		if !ok {
+			return nil, fmt.Errorf("unknown file type: %s", t)
+		}
+		f.types = append(f.types, patterns...)
+	}
+	return f, nil
+}
+
+// match reports whether rel passes the filter. Globs and types must both
+// match when both are given.
+func (f *fileFilter) match(rel string) bool {
+	base := path.Base(rel)
+	if len(f.globs) > 0 && !matchAny(f.globs, rel, base) {
+		return false
+	}
+	if len(f.types) > 0 && !matchAny(f.types, rel, base) {
+		return false
+	}
+	return true
+}
+
+// matchAny reports whether any glob matches. Globs containing a slash are
+// matched against the relative path, others against the base name.
+func matchAny(globs []string, rel, base string) bool {
+	for _, g := range globs {
+		name := base
+		if strings.Contains(g, "/") {
+			name = rel
+		}
+		if ok, _ := path.Match(g, name); ok {
+			return true
+		}
+	}
+	return false
+}
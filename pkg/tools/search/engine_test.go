@@ -0,0 +1,269 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestEngineSearch tests the native search engine modes and filters
+func TestEngineSearch(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".gitignore":        "ignored/\n",
+		"main.go":           "package main\n\nfunc main() {\n\tfmt.Println(\"a.b\")\n}\n",
+		"util.py":           "def helper():\n    return 'a.b'\n",
+		"web/app.ts":        "export function main() {}\n",
+		"ignored/skip.go":   "func main() {}\n",
+		"docs/notes.md":     "first line\nsecond line\nthird line\n",
+		"crlf.txt":          "alpha\r\nbeta\r\n",
+		"node_modules/m.js": "function main() {}\n",
+		".hidden/secret.go": "func main() {}\n",
+	}
+	for name, content := range files {
+		writeTestFile(t, filepath.Join(dir, name), content)
+	}
+	writeTestFile(t, filepath.Join(dir, "blob.bin"), "func main\x00\x01\x02")
+
+	engine := NewEngine(dir)
+	engine.SetExcludePatterns([]string{"node_modules"})
+	ctx := context.Background()
+
+	fileNames := func(results []Result) []string {
+		var out []string
+		for _, r := range results {
+			out = append(out, r.File)
+		}
+		return out
+	}
+
+	t.Run("RegexHonorsIgnores", func(t *testing.T) {
+		results, err := engine.Search(ctx, Query{Pattern: `func\s+main`, CaseSensitive: true})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if got, want := fileNames(results), []string{"main.go"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("files = %v, want %v", got, want)
+		}
+		if results[0].Line != 3 || results[0].Column != 1 || results[0].MatchEnd != 9 {
+			t.Errorf("unexpected position: %+v", results[0])
+		}
+	})
+
+	t.Run("Literal", func(t *testing.T) {
+		results, err := engine.Search(ctx, Query{Pattern: "a.b", Mode: ModeLiteral, CaseSensitive: true})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if got, want := fileNames(results), []string{"main.go", "util.py"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("files = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("TypeFilter", func(t *testing.T) {
+		results, err := engine.Search(ctx, Query{Pattern: "main", Types: []string{"ts"}})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if got, want := fileNames(results), []string{"web/app.ts"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("files = %v, want %v", got, want)
+		}
+
+		if _, err := engine.Search(ctx, Query{Pattern: "main", Types: []string{"cobol"}}); err == nil {
+			t.Error("Expected error for unknown file type")
+		}
+	})
+
+	t.Run("Context", func(t *testing.T) {
+		results, err := engine.Search(ctx, Query{Pattern: "second", Context: 1})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("Expected 1 result, got %d", len(results))
+		}
+		r := results[0]
+		if !reflect.DeepEqual(r.Before, []string{"first line"}) || !reflect.DeepEqual(r.After, []string{"third line"}) {
+			t.Errorf("unexpected context: before=%v after=%v", r.Before, r.After)
+		}
+	})
+
+	t.Run("Multiline", func(t *testing.T) {
+		results, err := engine.Search(ctx, Query{Pattern: `first line\nsecond`, Mode: ModeMultiline})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("Expected 1 result, got %d", len(results))
+		}
+		r := results[0]
+		if r.File != "docs/notes.md" || r.Line != 1 || r.EndLine != 2 || r.Content != "first line\nsecond line" {
+			t.Errorf("unexpected multiline result: %+v", r)
+		}
+	})
+
+	t.Run("LineEndings", func(t *testing.T) {
+		results, err := engine.Search(ctx, Query{Pattern: "^beta", CaseSensitive: true})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Content != "beta" || results[0].Line != 2 {
+			t.Errorf("unexpected result: %+v", results)
+		}
+	})
+
+	t.Run("MaxResults", func(t *testing.T) {
+		results, err := engine.Search(ctx, Query{Pattern: "line", MaxResults: 2})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(results) != 2 {
+			t.Errorf("Expected 2 results, got %d", len(results))
+		}
+	})
+
+	t.Run("HiddenFiles", func(t *testing.T) {
+		hidden := NewEngine(dir)
+		hidden.SetIncludeHidden(true)
+		results, err := hidden.Search(ctx, Query{Pattern: "func main", Mode: ModeLiteral, Globs: []string{"*.go"}})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if got, want := fileNames(results), []string{".hidden/secret.go", "main.go"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("files = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("InvalidPattern", func(t *testing.T) {
+		_, err := engine.Search(ctx, Query{Pattern: "func("})
+		if err == nil || !strings.Contains(err.Error(), "invalid pattern") {
+			t.Errorf("Expected invalid pattern error, got %v", err)
+		}
+	})
+}
+
+// TestEngineSearchMaxResultsOrder tests that limited searches return the
+// first matches in walk order however the workers are scheduled
+func TestEngineSearchMaxResultsOrder(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 200; i++ {
+		writeTestFile(t, filepath.Join(dir, fmt.Sprintf("pkg%d/file%03d.go", i%7, i)), "package x\n\nfunc target() {}\n")
+	}
+	engine := NewEngine(dir)
+	engine.workers = 16
+	ctx := context.Background()
+
+	all, err := engine.Search(ctx, Query{Pattern: "func target"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(all) != 200 {
+		t.Fatalf("Expected 200 results, got %d", len(all))
+	}
+	for i := 0; i < 20; i++ {
+		results, err := engine.Search(ctx, Query{Pattern: "func target", MaxResults: 10})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if !reflect.DeepEqual(results, all[:10]) {
+			t.Fatalf("run %d: got %d results differing from the first 10 of the full search", i, len(results))
+		}
+	}
+}
+
+// TestFormatCodeResultsContext tests rendering of context and multiline results
+func TestFormatCodeResultsContext(t *testing.T) {
+	tool := NewSearchTool(t.TempDir(), nil)
+	out := tool.formatCodeResults([]Result{
+		{File: "a.go", Line: 5, Content: "match", Before: []string{"before"}, After: []string{"after"}},
+		{File: "b.go", Line: 1, EndLine: 2, Content: "one\ntwo"},
+	})
+
+	for _, want := range []string{"a.go:5\n  4- before\n  5: match\n  6- after\n", "b.go:1-2\n  one\n  two\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+// benchmarkTree writes a tree of Go files for the search benchmarks.
+func benchmarkTree(b *testing.B) string {
+	dir := b.TempDir()
+	var body strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&body, "// line %d of filler text for the search benchmark\n", i)
+	}
+	for i := 0; i < 500; i++ {
+		content := body.String()
+		if i%10 == 0 {
+			content += "func HandleRequest() {}\n"
+		}
+		writeTestFile(b, filepath.Join(dir, fmt.Sprintf("pkg%02d/file%03d.go", i%25, i)), content)
+	}
+	return dir
+}
+
+// benchmarkQueries are the searches run by both benchmarks; each matches
+// 50 lines of benchmarkTree.
+var benchmarkQueries = []struct {
+	name     string
+	query    Query
+	grepArgs []string
+}{
+	{"Literal", Query{Pattern: "func HandleRequest", Mode: ModeLiteral, CaseSensitive: true}, []string{"-F"}},
+	{"Regex", Query{Pattern: `func Handle\w+`, CaseSensitive: true}, []string{"-E"}},
+	{"RegexIgnoreCase", Query{Pattern: `func Handle\w+`}, []string{"-E", "-i"}},
+}
+
+// BenchmarkEngineSearch measures the native engine on a 500-file tree
+func BenchmarkEngineSearch(b *testing.B) {
+	dir := benchmarkTree(b)
+	engine := NewEngine(dir)
+	ctx := context.Background()
+	for _, bq := range benchmarkQueries {
+		b.Run(bq.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				results, err := engine.Search(ctx, bq.query)
+				if err != nil || len(results) != 50 {
+					b.Fatalf("Search returned %d results: %v", len(results), err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkGrepSearch measures the grep command the engine replaced on the
+// same tree and queries
+func BenchmarkGrepSearch(b *testing.B) {
+	if _, err := exec.LookPath("grep"); err != nil {
+		b.Skip("grep not available")
+	}
+	dir := benchmarkTree(b)
+	for _, bq := range benchmarkQueries {
+		b.Run(bq.name, func(b *testing.B) {
+			args := append([]string{"-rn"}, bq.grepArgs...)
+			args = append(args, bq.query.Pattern, dir)
+			for i := 0; i < b.N; i++ {
+				out, err := exec.Command("grep", args...).Output()
+				if err != nil || strings.Count(string(out), "\n") != 50 {
+					b.Fatalf("grep returned %q: %v", out, err)
+				}
+			}
+		})
+	}
+}
+
+func writeTestFile(t testing.TB, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,473 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zerofisher/goai/pkg/ignore"
+)
+
+const (
+	// indexVersion changes whenever the stored index format or the
+	// tokenizer does.
+	indexVersion = 1
+	// indexFileName is the index file inside the index directory.
+	indexFileName = "bm25.gob"
+	// maxIndexFileSize is the largest file the text index reads. Larger
+	// files are usually generated or data.
+	maxIndexFileSize = 1024 * 1024
+	// pathTermWeight is how often each term of a file's path is counted,
+	// so files named after a topic rank above files that mention it.
+	pathTermWeight = 3
+	// maxSnippets is the number of lines shown per ranked file.
+	maxSnippets = 3
+)
+
+// BM25 parameters: term frequency saturation and length normalization.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// indexedDoc is the stored state of one indexed file.
+type indexedDoc struct {
+	ModTime time.Time
+	Size    int64
+	Hash    string
+	Length  int            // Number of terms, path terms included
+	Terms   map[string]int // Term frequencies
+}
+
+// indexFile is the on-disk index.
+type indexFile struct {
+	Version int
+	Docs    map[string]*indexedDoc
+}
+
+// RankedFile is a file ranked by relevance to a query.
+type RankedFile struct {
+	File     string
+	Score    float64
+	Snippets []Result // The lines that match the query best, in line order
+}
+
+// IndexStats describes what an index update did.
+type IndexStats struct {
+	Files   int // Files in the index
+	Updated int // Files read and tokenized
+	Removed int // Files dropped because they are gone or now ignored
+}
+
+// TextIndex is a persistent full-text index of the work directory, ranked
+// with BM25. The inverted index lives in memory; the term frequencies of
+// every file are stored in the index directory (normally .goai/index) and
+// brought up to date incrementally: files whose size and modification time
+// are unchanged are not read, and files whose content hash is unchanged are
+// not tokenized again. It is safe for concurrent use.
+type TextIndex struct {
+	root string
+	dir  string
+	opts ignore.WalkOptions
+
+	mu       sync.Mutex
+	loaded   bool
+	docs     map[string]*indexedDoc
+	postings map[string]map[string]int // Term -> file -> frequency
+	totalLen int
+}
+
+// NewTextIndex creates an index of root stored in dir. Nothing is read
+// until the first update.
+func NewTextIndex(root, dir string) *TextIndex {
+	return &TextIndex{
+		root:     root,
+		dir:      dir,
+		docs:     make(map[string]*indexedDoc),
+		postings: make(map[string]map[string]int),
+	}
+}
+
+// SetExcludePatterns sets extra gitignore-style patterns to exclude.
+func (x *TextIndex) SetExcludePatterns(patterns []string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.opts.Excludes = patterns
+}
+
+// SetIncludeHidden controls whether dot files and directories are indexed.
+func (x *TextIndex) SetIncludeHidden(include bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.opts.IncludeHidden = include
+}
+
+// Update brings the index up to date with the work directory and saves it
+// when anything changed.
+func (x *TextIndex) Update(ctx context.Context) (IndexStats, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.update(ctx)
+}
+
+// Search updates the index and returns up to limit files ranked by BM25
+// relevance to query, each with its best matching lines. Globs and types
+// restrict the files as in Query; contextLines adds context to snippets.
+func (x *TextIndex) Search(ctx context.Context, query string, globs, types []string, limit, contextLines int) ([]RankedFile, error) {
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("query has no searchable terms: %q", query)
+	}
+	filter, err := newFileFilter(globs, types)
+	if err != nil {
+		return nil, err
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if _, err := x.update(ctx); err != nil {
+		return nil, err
+	}
+
+	terms = x.withCompounds(terms)
+	idf := x.idf(terms)
+	scores := make(map[string]float64)
+	avgLen := float64(x.totalLen) / math.Max(float64(len(x.docs)), 1)
+	for _, term := range terms {
+		for rel, tf := range x.postings[term] {
+			if !filter.match(rel) {
+				continue
+			}
+			norm := 1 - bm25B + bm25B*float64(x.docs[rel].Length)/avgLen
+			f := float64(tf)
+			scores[rel] += idf[term] * f * (bm25K1 + 1) / (f + bm25K1*norm)
+		}
+	}
+
+	ranked := make([]RankedFile, 0, len(scores))
+	for rel, score := range scores {
+		ranked = append(ranked, RankedFile{File: rel, Score: score})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].File < ranked[j].File
+	})
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	for i := range ranked {
+		ranked[i].Snippets = x.snippets(ranked[i].File, idf, contextLines)
+	}
+	return ranked, nil
+}
+
+// withCompounds adds the joined form of adjacent query terms that occur in
+// the index, so "read guard" also finds readguard.go and ReadGuard.
+func (x *TextIndex) withCompounds(terms []string) []string {
+	out := terms
+	for i := 0; i+1 < len(terms); i++ {
+		joined := terms[i] + terms[i+1]
+		if _, ok := x.postings[joined]; ok {
+			out = append(out, joined)
+		}
+	}
+	return out
+}
+
+// idf returns the inverse document frequency of each term.
+func (x *TextIndex) idf(terms []string) map[string]float64 {
+	n := float64(len(x.docs))
+	idf := make(map[string]float64, len(terms))
+	for _, term := range terms {
+		df := float64(len(x.postings[term]))
+		idf[term] = math.Log(1 + (n-df+0.5)/(df+0.5))
+	}
+	return idf
+}
+
+// snippets returns the lines of rel that contain the most valuable query
+// terms, at most maxSnippets and not adjacent to each other.
+func (x *TextIndex) snippets(rel string, idf map[string]float64, contextLines int) []Result {
+	data, err := os.ReadFile(filepath.Join(x.root, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil
+	}
+	lines := bytes.Split(data, []byte("\n"))
+
+	type scoredLine struct {
+		index int
+		score float64
+	}
+	var candidates []scoredLine
+	for i, line := range lines {
+		seen := make(map[string]bool)
+		score := 0.0
+		tokenize(string(line), func(term string) {
+			if w, ok := idf[term]; ok && !seen[term] {
+				seen[term] = true
+				score += w
+			}
+		})
+		if score > 0 {
+			candidates = append(candidates, scoredLine{index: i, score: score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+
+	var picked []int
+	for _, c := range candidates {
+		if len(picked) == maxSnippets {
+			break
+		}
+		near := false
+		for _, p := range picked {
+			if c.index-p <= 1 && p-c.index <= 1 {
+				near = true
+				break
+			}
+		}
+		if !near {
+			picked = append(picked, c.index)
+		}
+	}
+	sort.Ints(picked)
+
+	results := make([]Result, 0, len(picked))
+	for _, i := range picked {
+		result := Result{File: rel, Line: i + 1, Column: 1, Content: trimLine(lines[i])}
+		if contextLines > 0 {
+			result.Before = lineRange(lines, i-contextLines, i)
+			result.After = lineRange(lines, i+1, i+1+contextLines)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// indexCandidate is a file found by the walk.
+type indexCandidate struct {
+	rel  string
+	info fs.FileInfo
+}
+
+// update walks the work directory, re-reads new and changed files and
+// drops files that are gone. The index is saved when anything changed.
+func (x *TextIndex) update(ctx context.Context) (IndexStats, error) {
+	if !x.loaded {
+		x.load()
+		x.loaded = true
+	}
+
+	indexRel := ""
+	if rel, err := filepath.Rel(x.root, x.dir); err == nil && !strings.HasPrefix(rel, "..") {
+		indexRel = filepath.ToSlash(rel)
+	}
+
+	var changed []indexCandidate
+	seen := make(map[string]bool)
+	err := ignore.Walk(ctx, x.root, x.opts, func(rel string, d fs.DirEntry) error {
+		if d.IsDir() {
+			if rel == indexRel {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > maxIndexFileSize {
+			return nil
+		}
+		seen[rel] = true
+		if doc, ok := x.docs[rel]; ok && doc.Size == info.Size() && doc.ModTime.Equal(info.ModTime()) {
+			return nil
+		}
+		changed = append(changed, indexCandidate{rel: rel, info: info})
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return IndexStats{}, fmt.Errorf("indexing canceled: %w", ctx.Err())
+		}
+		return IndexStats{}, fmt.Errorf("failed to walk %s: %w", x.root, err)
+	}
+
+	var stats IndexStats
+	for rel := range x.docs {
+		if !seen[rel] {
+			x.remove(rel)
+			stats.Removed++
+		}
+	}
+
+	docs := x.read(ctx, changed)
+	if err := ctx.Err(); err != nil {
+		return IndexStats{}, fmt.Errorf("indexing canceled: %w", err)
+	}
+	for i, c := range changed {
+		doc := docs[i]
+		old, exists := x.docs[c.rel]
+		switch {
+		case doc == nil:
+			// Unreadable or binary
+			if exists {
+				x.remove(c.rel)
+				stats.Removed++
+			}
+			continue
+		case exists && old.Hash == doc.Hash:
+			// Touched but not changed
+			old.ModTime, old.Size = doc.ModTime, doc.Size
+			continue
+		case exists:
+			x.remove(c.rel)
+		}
+		x.add(c.rel, doc)
+		stats.Updated++
+	}
+
+	stats.Files = len(x.docs)
+	if len(changed) > 0 || stats.Removed > 0 {
+		if err := x.save(); err != nil {
+			return stats, fmt.Errorf("failed to save index: %w", err)
+		}
+	}
+	return stats, nil
+}
+
+// read reads and hashes the changed files concurrently. Files whose hash
+// differs from the indexed version are tokenized; unreadable and binary
+// files yield nil.
+func (x *TextIndex) read(ctx context.Context, changed []indexCandidate) []*indexedDoc {
+	docs := make([]*indexedDoc, len(changed))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				c := changed[i]
+				data, err := os.ReadFile(filepath.Join(x.root, filepath.FromSlash(c.rel)))
+				if err != nil || isBinary(data) {
+					continue
+				}
+				sum := sha256.Sum256(data)
+				doc := &indexedDoc{ModTime: c.info.ModTime(), Size: c.info.Size(), Hash: hex.EncodeToString(sum[:])}
+				if old, ok := x.docs[c.rel]; !ok || old.Hash != doc.Hash {
+					doc.Terms = tokenizeDoc(c.rel, data)
+					doc.Length = docLength(doc.Terms)
+				}
+				docs[i] = doc
+			}
+		}()
+	}
+	for i := range changed {
+		if ctx.Err() != nil {
+			break
+		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return docs
+}
+
+// tokenizeDoc counts the terms of a file's content and path.
+func tokenizeDoc(rel string, content []byte) map[string]int {
+	terms := make(map[string]int)
+	tokenize(string(content), func(term string) { terms[term]++ })
+	tokenize(rel, func(term string) { terms[term] += pathTermWeight })
+	return terms
+}
+
+// docLength sums the term frequencies.
+func docLength(terms map[string]int) int {
+	n := 0
+	for _, tf := range terms {
+		n += tf
+	}
+	return n
+}
+
+// add puts a document into the inverted index.
+func (x *TextIndex) add(rel string, doc *indexedDoc) {
+	x.docs[rel] = doc
+	x.totalLen += doc.Length
+	for term, tf := range doc.Terms {
+		files := x.postings[term]
+		if files == nil {
+			files = make(map[string]int)
+			x.postings[term] = files
+		}
+		files[rel] = tf
+	}
+}
+
+// remove takes a document out of the inverted index.
+func (x *TextIndex) remove(rel string) {
+	doc, ok := x.docs[rel]
+	if !ok {
+		return
+	}
+	for term := range doc.Terms {
+		delete(x.postings[term], rel)
+		if len(x.postings[term]) == 0 {
+			delete(x.postings, term)
+		}
+	}
+	x.totalLen -= doc.Length
+	delete(x.docs, rel)
+}
+
+// load reads the stored index; a missing or outdated index is ignored and
+// rebuilt by the next update.
+func (x *TextIndex) load() {
+	f, err := os.Open(filepath.Join(x.dir, indexFileName))
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = f.Close() // Read-only, close errors are irrelevant
+	}()
+
+	var stored indexFile
+	if gob.NewDecoder(f).Decode(&stored) != nil || stored.Version != indexVersion {
+		return
+	}
+	for rel, doc := range stored.Docs {
+		x.add(rel, doc)
+	}
+}
+
+// save writes the index atomically.
+func (x *TextIndex) save() error {
+	if err := os.MkdirAll(x.dir, 0755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(indexFile{Version: indexVersion, Docs: x.docs}); err != nil {
+		return err
+	}
+	path := filepath.Join(x.dir, indexFileName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
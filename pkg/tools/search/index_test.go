@@ -0,0 +1,192 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTokenize tests identifier splitting and term normalization
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"parseHTTPRequest", []string{"parsehttprequest", "parse", "http", "request"}},
+		{"max_file_size", []string{"max_file_size", "max", "file", "size"}},
+		{"How does the agent handle errors?", []string{"agent", "handle", "error"}},
+		{"utf8 x 42 __init__", []string{"utf8", "init"}},
+		{"ReadFileData", []string{"readfiledata", "read", "file", "data"}},
+		{"retries policies class", []string{"retry", "policy", "class"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			var got []string
+			tokenize(tt.text, func(term string) { got = append(got, term) })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tokenize(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTextIndex tests BM25 ranking, snippets and incremental updates
+func TestTextIndex(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"retry/policy.go": "package retry\n\n// Policy configures retries with backoff.\ntype Policy struct {\n\tMaxRetries int\n\tBackoff    time.Duration\n}\n",
+		"client.go":       "package client\n\nfunc (c *Client) Do() error {\n\treturn c.policy.run()\n}\n",
+		"docs/usage.md":   "# Usage\n\nThe client retries failed requests.\n",
+		"readguard.go":    "package guard\n",
+		"ignored/x.go":    "package ignored // retry backoff retry backoff\n",
+		".gitignore":      "ignored/\n",
+	}
+	for name, content := range files {
+		writeTestFile(t, filepath.Join(dir, name), content)
+	}
+	writeTestFile(t, filepath.Join(dir, "blob.bin"), "retry\x00backoff")
+
+	indexDir := filepath.Join(dir, ".goai", "index")
+	index := NewTextIndex(dir, indexDir)
+	ctx := context.Background()
+
+	stats, err := index.Update(ctx)
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if stats.Files != 4 || stats.Updated != 4 {
+		t.Errorf("stats = %+v, want 4 files updated", stats)
+	}
+
+	t.Run("Ranking", func(t *testing.T) {
+		ranked, err := index.Search(ctx, "retry backoff policy", nil, nil, 10, 0)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(ranked) < 2 || ranked[0].File != "retry/policy.go" {
+			t.Fatalf("expected retry/policy.go first, got %+v", ranked)
+		}
+		for _, r := range ranked {
+			if r.File == "ignored/x.go" || r.File == "blob.bin" {
+				t.Errorf("ignored or binary file ranked: %s", r.File)
+			}
+		}
+		var lines []int
+		for _, s := range ranked[0].Snippets {
+			lines = append(lines, s.Line)
+		}
+		if want := []int{1, 3, 6}; !reflect.DeepEqual(lines, want) {
+			t.Errorf("snippet lines = %v, want %v", lines, want)
+		}
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		ranked, err := index.Search(ctx, "retries", nil, []string{"markdown"}, 10, 0)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(ranked) != 1 || ranked[0].File != "docs/usage.md" {
+			t.Errorf("expected only docs/usage.md, got %+v", ranked)
+		}
+	})
+
+	t.Run("Compounds", func(t *testing.T) {
+		ranked, err := index.Search(ctx, "read guard", nil, nil, 10, 0)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(ranked) == 0 || ranked[0].File != "readguard.go" {
+			t.Errorf("expected readguard.go first, got %+v", ranked)
+		}
+	})
+
+	t.Run("NoTerms", func(t *testing.T) {
+		if _, err := index.Search(ctx, "how is the", nil, nil, 10, 0); err == nil {
+			t.Error("expected an error for a query of stop words")
+		}
+	})
+
+	t.Run("Incremental", func(t *testing.T) {
+		// Touching a file without changing it does not re-tokenize it
+		later := time.Now().Add(time.Minute)
+		if err := os.Chtimes(filepath.Join(dir, "client.go"), later, later); err != nil {
+			t.Fatal(err)
+		}
+		writeTestFile(t, filepath.Join(dir, "docs/usage.md"), "# Usage\n\nNothing about that here.\n")
+		writeTestFile(t, filepath.Join(dir, "circuit.go"), "package circuit\n\n// Breaker stops retries after repeated failures.\n")
+		if err := os.Remove(filepath.Join(dir, "retry", "policy.go")); err != nil {
+			t.Fatal(err)
+		}
+
+		stats, err := index.Update(ctx)
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		want := IndexStats{Files: 4, Updated: 2, Removed: 1}
+		if stats != want {
+			t.Errorf("stats = %+v, want %+v", stats, want)
+		}
+
+		ranked, err := index.Search(ctx, "retries", nil, nil, 10, 0)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(ranked) != 1 || ranked[0].File != "circuit.go" {
+			t.Errorf("expected only circuit.go, got %+v", ranked)
+		}
+	})
+
+	t.Run("Persistence", func(t *testing.T) {
+		if _, err := os.Stat(filepath.Join(indexDir, indexFileName)); err != nil {
+			t.Fatalf("index not saved: %v", err)
+		}
+
+		reloaded := NewTextIndex(dir, indexDir)
+		stats, err := reloaded.Update(ctx)
+		if err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if stats.Files != 4 || stats.Updated != 0 || stats.Removed != 0 {
+			t.Errorf("expected the stored index to be current, got %+v", stats)
+		}
+		ranked, err := reloaded.Search(ctx, "breaker", nil, nil, 10, 0)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(ranked) != 1 || ranked[0].File != "circuit.go" {
+			t.Errorf("expected circuit.go from the stored index, got %+v", ranked)
+		}
+	})
+}
+
+// TestSearchToolRanked tests the ranked search mode of the search tool
+func TestSearchToolRanked(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "auth/token.go"), "package auth\n\n// RefreshToken renews an expired access token.\nfunc RefreshToken() {}\n")
+	writeTestFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {}\n")
+
+	tool := NewSearchTool(dir, nil)
+	input := map[string]interface{}{
+		"pattern": "where are expired tokens refreshed",
+		"type":    "code",
+		"mode":    "ranked",
+	}
+	if err := tool.Validate(input); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	output, err := tool.Execute(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(output, "Found 1 relevant files") || !strings.Contains(output, "auth/token.go (score ") {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+	if !strings.Contains(output, "3: // RefreshToken renews an expired access token.") {
+		t.Errorf("expected the matching line as snippet:\n%s", output)
+	}
+}
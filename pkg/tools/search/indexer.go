@@ -2,6 +2,7 @@ package search
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -74,18 +75,22 @@ func (c *IndexCache) Set(path string, info *FileInfo) {
 }
 
 // Indexer provides indexing and caching functionality for the search tool.
+// File metadata is cached in memory; content is ranked through a persistent
+// TextIndex stored in .goai/index.
 type Indexer struct {
 	workDir     string
 	cache       *IndexCache
+	text        *TextIndex
 	ignorePatterns []string
 	mu          sync.RWMutex
 }
 
 // NewIndexer creates a new indexer.
 func NewIndexer(workDir string) *Indexer {
-	return &Indexer{
+	idx := &Indexer{
 		workDir: workDir,
 		cache:   NewIndexCache(15 * time.Minute),
+		text:    NewTextIndex(workDir, filepath.Join(workDir, ".goai", "index")),
 		ignorePatterns: []string{
 			".git",
 			".svn",
@@ -105,6 +110,8 @@ func NewIndexer(workDir string) *Indexer {
 			"*.jar",
 		},
 	}
+	idx.text.SetExcludePatterns(idx.ignorePatterns)
+	return idx
 }
 
 // SetIgnorePatterns sets patterns for files/directories to ignore.
@@ -112,6 +119,7 @@ func (idx *Indexer) SetIgnorePatterns(patterns []string) {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 	idx.ignorePatterns = patterns
+	idx.text.SetExcludePatterns(patterns)
 }
 
 // ShouldIgnore checks if a path should be ignored.
@@ -144,13 +152,18 @@ func (idx *Indexer) ShouldIgnore(path string) bool {
 	return false
 }
 
-// RefreshIndex updates the file cache by scanning the work directory.
+// RefreshIndex updates the file cache by scanning the work directory and
+// brings the persistent text index up to date.
 func (idx *Indexer) RefreshIndex() error {
 	// Clear expired cache
 	if idx.cache.IsExpired() {
 		idx.cache.Clear()
 	}
 
+	if _, err := idx.text.Update(context.Background()); err != nil {
+		return err
+	}
+
 	return filepath.Walk(idx.workDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil // Skip files with errors
@@ -190,8 +203,26 @@ func (idx *Indexer) RefreshIndex() error {
 	})
 }
 
-// GetRelevantFiles returns files relevant to a query.
+// GetRelevantFiles returns files relevant to a query. With a query, files
+// are ranked by BM25 relevance of their content and path, most relevant
+// first; without one, all files matching filePattern are returned.
 func (idx *Indexer) GetRelevantFiles(query string, filePattern string) ([]string, error) {
+	if strings.TrimSpace(query) != "" {
+		var globs []string
+		if filePattern != "" {
+			globs = []string{filePattern}
+		}
+		ranked, err := idx.text.Search(context.Background(), query, globs, nil, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		files := make([]string, 0, len(ranked))
+		for _, r := range ranked {
+			files = append(files, filepath.Join(idx.workDir, filepath.FromSlash(r.File)))
+		}
+		return files, nil
+	}
+
 	// Refresh index if expired
 	if idx.cache.IsExpired() {
 		if err := idx.RefreshIndex(); err != nil {
@@ -204,7 +235,7 @@ func (idx *Indexer) GetRelevantFiles(query string, filePattern string) ([]string
 	idx.cache.mu.RLock()
 	defer idx.cache.mu.RUnlock()
 
-	for relPath, fileInfo := range idx.cache.files {
+	for _, fileInfo := range idx.cache.files {
 		// Check file pattern if specified
 		if filePattern != "" {
 			matched, _ := filepath.Match(filePattern, filepath.Base(fileInfo.Path))
@@ -213,25 +244,6 @@ func (idx *Indexer) GetRelevantFiles(query string, filePattern string) ([]string
 			}
 		}
 
-		// Simple relevance check - file name contains query terms
-		if query != "" {
-			queryLower := strings.ToLower(query)
-			pathLower := strings.ToLower(relPath)
-
-			// Check if file path contains any query terms
-			relevant := false
-			for _, term := range strings.Fields(queryLower) {
-				if strings.Contains(pathLower, term) {
-					relevant = true
-					break
-				}
-			}
-
-			if !relevant {
-				continue
-			}
-		}
-
 		relevantFiles = append(relevantFiles, fileInfo.Path)
 	}
 
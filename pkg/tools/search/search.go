@@ -1,11 +1,8 @@
 package search
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"fmt"
-	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -21,6 +18,9 @@ type Result struct {
 	Content    string
 	MatchStart int
 	MatchEnd   int
+	EndLine    int      // Last line of a multiline match, 0 otherwise
+	Before     []string // Context lines before the match
+	After      []string // Context lines after the match
 }
 
 // Location represents the location of a symbol.
@@ -36,14 +36,23 @@ type SearchOptions struct {
 	CaseSensitive bool
 	WholeWord     bool
 	FilePattern   string // Glob pattern for files to search
+	FileTypes     []string
+	Mode          Mode
 	MaxResults    int
 	Context       int // Number of context lines
 }
 
-// SearchTool implements code search functionality using grep.
+// defaultRankedResults is the number of files a ranked search returns when
+// max_results is not given.
+const defaultRankedResults = 10
+
+// SearchTool implements code search functionality on top of the native
+// search Engine.
 type SearchTool struct {
 	workDir   string
 	validator tools.SecurityValidator
+	engine    *Engine
+	index     *TextIndex
 }
 
 // NewSearchTool creates a new search tool.
@@ -54,9 +63,23 @@ func NewSearchTool(workDir string, validator tools.SecurityValidator) *SearchToo
 	return &SearchTool{
 		workDir:   workDir,
 		validator: validator,
+		engine:    NewEngine(workDir),
+		index:     NewTextIndex(workDir, filepath.Join(workDir, ".goai", "index")),
 	}
 }
 
+// SetExcludePatterns sets gitignore-style patterns excluded from every search.
+func (t *SearchTool) SetExcludePatterns(patterns []string) {
+	t.engine.SetExcludePatterns(patterns)
+	t.index.SetExcludePatterns(patterns)
+}
+
+// SetIncludeHidden controls whether dot files and directories are searched.
+func (t *SearchTool) SetIncludeHidden(include bool) {
+	t.engine.SetIncludeHidden(include)
+	t.index.SetIncludeHidden(include)
+}
+
 // Name returns the name of the tool.
 func (t *SearchTool) Name() string {
 	return "search"
@@ -64,7 +87,7 @@ func (t *SearchTool) Name() string {
 
 // Description returns the description of the tool.
 func (t *SearchTool) Description() string {
-	return "Search for code patterns, symbols, and text within the project"
+	return "Search for code patterns, symbols, and text within the project. Honors .gitignore and .ignore files and skips binary files"
 }
 
 // InputSchema returns the JSON schema for the input.
@@ -74,7 +97,13 @@ func (t *SearchTool) InputSchema() map[string]interface{} {
 		"properties": map[string]interface{}{
 			"pattern": map[string]interface{}{
 				"type":        "string",
-				"description": "Search pattern (regex or plain text)",
+				"description": "Search pattern (RE2 regex, plain text in literal mode, or a natural-language query in ranked mode)",
+			},
+			"mode": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{string(ModeRegex), string(ModeLiteral), string(ModeMultiline), string(ModeRanked)},
+				"description": "How 'code' searches interpret the pattern: 'regex' per line, 'literal' plain text per line, 'multiline' regex across lines, 'ranked' returns the files most relevant to a query such as 'where are retries configured' with their best matching lines",
+				"default":     string(ModeRegex),
 			},
 			"type": map[string]interface{}{
 				"type":        "string",
@@ -85,6 +114,11 @@ func (t *SearchTool) InputSchema() map[string]interface{} {
 				"type":        "string",
 				"description": "File pattern to search in (e.g., '*.go', '*.py')",
 			},
+			"file_types": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string", "enum": FileTypes()},
+				"description": "Restrict the search to these file types (e.g., ['go', 'ts'])",
+			},
 			"case_sensitive": map[string]interface{}{
 				"type":        "boolean",
 				"description": "Whether the search is case-sensitive",
@@ -97,7 +131,7 @@ func (t *SearchTool) InputSchema() map[string]interface{} {
 			},
 			"max_results": map[string]interface{}{
 				"type":        "integer",
-				"description": "Maximum number of results to return",
+				"description": "Maximum number of results to return (files in ranked mode, default 10 there)",
 				"default":     50,
 			},
 			"context": map[string]interface{}{
@@ -129,6 +163,16 @@ func (t *SearchTool) Execute(ctx context.Context, input map[string]interface{})
 	// Perform search based on type
 	switch searchType {
 	case "code":
+		if options.Mode == ModeRanked {
+			if _, ok := input["max_results"]; !ok {
+				options.MaxResults = defaultRankedResults
+			}
+			files, err := t.SearchRanked(ctx, pattern, options)
+			if err != nil {
+				return "", err
+			}
+			return t.formatRankedResults(files), nil
+		}
 		results, err := t.SearchCode(ctx, pattern, options)
 		if err != nil {
 			return "", err
@@ -164,6 +208,18 @@ func (t *SearchTool) Validate(input map[string]interface{}) error {
 		return fmt.Errorf("invalid search type: %s (must be 'code' or 'symbol')", searchType)
 	}
 
+	if mode, ok := input["mode"]; ok {
+		switch Mode(fmt.Sprint(mode)) {
+		case ModeRegex, ModeLiteral, ModeMultiline, ModeRanked:
+		default:
+			return fmt.Errorf("invalid mode: %v (must be 'regex', 'literal', 'multiline' or 'ranked')", mode)
+		}
+	}
+
+	if _, err := newFileFilter(nil, getStringSlice(input, "file_types")); err != nil {
+		return err
+	}
+
 	// Validate numeric fields
 	if maxResults, ok := input["max_results"]; ok {
 		if val, ok := maxResults.(float64); ok {
@@ -184,83 +240,37 @@ func (t *SearchTool) Validate(input map[string]interface{}) error {
 	return nil
 }
 
-// SearchCode searches for code patterns using grep.
+// SearchCode searches for code patterns with the native search engine.
 func (t *SearchTool) SearchCode(ctx context.Context, pattern string, options SearchOptions) ([]Result, error) {
-	// Build grep command
-	args := []string{"-n", "-H"} // Line numbers and filenames
-
-	if !options.CaseSensitive {
-		args = append(args, "-i")
-	}
-
-	if options.WholeWord {
-		args = append(args, "-w")
-	}
-
-	if options.Context > 0 {
-		args = append(args, fmt.Sprintf("-C%d", options.Context))
+	query := Query{
+		Pattern:       pattern,
+		Mode:          options.Mode,
+		CaseSensitive: options.CaseSensitive,
+		WholeWord:     options.WholeWord,
+		Types:         options.FileTypes,
+		Context:       options.Context,
+		MaxResults:    options.MaxResults,
 	}
-
-	// Limit matches at grep level for efficiency
-	if options.MaxResults > 0 {
-		args = append(args, "-m", fmt.Sprintf("%d", options.MaxResults))
-	}
-
-	// Add pattern
-	args = append(args, pattern)
-
-	// Add file pattern if specified
 	if options.FilePattern != "" {
-		// Use find to get matching files first
-		files, err := t.findMatchingFiles(ctx, options.FilePattern)
-		if err != nil {
-			return nil, err
-		}
-		if len(files) == 0 {
-			return []Result{}, nil // No matching files
-		}
-		args = append(args, files...)
-	} else {
-		// Search recursively in workdir
-		args = append(args, "-r", t.workDir)
+		query.Globs = []string{options.FilePattern}
 	}
 
-	// Execute grep with context binding
-	cmd := exec.CommandContext(ctx, "grep", args...)
-	cmd.Dir = t.workDir
-
-	// Use a buffer with size limit to prevent excessive memory usage
-	var outBuf bytes.Buffer
-	const maxOutputSize = 10 * 1024 * 1024 // 10MB limit
-	cmd.Stdout = &limitedWriter{w: &outBuf, limit: maxOutputSize}
-
-	err := cmd.Run()
-	if err != nil {
-		// Check if context was canceled
-		if ctx.Err() != nil {
-			return nil, fmt.Errorf("search canceled: %w", ctx.Err())
-		}
-		// grep returns exit code 1 when no matches found
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-			return []Result{}, nil
-		}
-		return nil, fmt.Errorf("grep failed: %w", err)
-	}
-
-	// Parse results
-	results := t.parseGrepOutput(outBuf.String())
+	return t.engine.Search(ctx, query)
+}
 
-	// Additional limit check (belt and suspenders)
-	if options.MaxResults > 0 && len(results) > options.MaxResults {
-		results = results[:options.MaxResults]
+// SearchRanked returns the files most relevant to a natural-language query,
+// ranked with BM25 over the persistent text index.
+func (t *SearchTool) SearchRanked(ctx context.Context, query string, options SearchOptions) ([]RankedFile, error) {
+	var globs []string
+	if options.FilePattern != "" {
+		globs = []string{options.FilePattern}
 	}
-
-	return results, nil
+	return t.index.Search(ctx, query, globs, options.FileTypes, options.MaxResults, options.Context)
 }
 
 // SearchSymbol searches for symbol definitions.
 func (t *SearchTool) SearchSymbol(ctx context.Context, symbol string) ([]Location, error) {
-	// For Go files, use simple grep patterns for common declarations
+	// For Go files, use simple patterns for common declarations
 	patterns := []string{
 		fmt.Sprintf(`func\s+%s\s*\(`, symbol),           // Function
 		fmt.Sprintf(`func\s+\([^)]+\)\s+%s\s*\(`, symbol), // Method
@@ -270,35 +280,25 @@ func (t *SearchTool) SearchSymbol(ctx context.Context, symbol string) ([]Locatio
 		fmt.Sprintf(`^%s\s*:=`, symbol),                  // Short variable declaration
 	}
 
-	var locations []Location
-	const maxOutputSize = 5 * 1024 * 1024 // 5MB limit per pattern
-
-	for _, pattern := range patterns {
-		// Check context before each pattern
-		if ctx.Err() != nil {
-			return nil, fmt.Errorf("search canceled: %w", ctx.Err())
-		}
-
-		cmd := exec.CommandContext(ctx, "grep", "-rn", "-E", "-m", "100", pattern, t.workDir, "--include=*.go")
-
-		var outBuf bytes.Buffer
-		cmd.Stdout = &limitedWriter{w: &outBuf, limit: maxOutputSize}
-
-		err := cmd.Run()
-		if err != nil {
-			// Check if context was canceled
-			if ctx.Err() != nil {
-				return nil, fmt.Errorf("search canceled: %w", ctx.Err())
-			}
-			// Continue if no matches for this pattern
-			if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-				continue
-			}
-			return nil, fmt.Errorf("grep failed: %w", err)
-		}
+	results, err := t.engine.Search(ctx, Query{
+		Pattern:       "(?:" + strings.Join(patterns, ")|(?:") + ")",
+		Mode:          ModeRegex,
+		CaseSensitive: true,
+		Types:         []string{"go"},
+		MaxResults:    1000,
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		locs := t.parseSymbolOutput(outBuf.String(), symbol)
-		locations = append(locations, locs...)
+	var locations []Location
+	for _, result := range results {
+		locations = append(locations, Location{
+			File:   result.File,
+			Line:   result.Line,
+			Column: result.Column,
+			Type:   t.detectSymbolType(result.Content, symbol),
+		})
 	}
 
 	// Remove duplicates
@@ -337,6 +337,12 @@ func (t *SearchTool) buildOptions(input map[string]interface{}) SearchOptions {
 		options.FilePattern = val
 	}
 
+	if val, ok := input["mode"].(string); ok {
+		options.Mode = Mode(val)
+	}
+
+	options.FileTypes = getStringSlice(input, "file_types")
+
 	if val, ok := input["max_results"].(float64); ok {
 		options.MaxResults = int(val)
 	}
@@ -348,97 +354,24 @@ func (t *SearchTool) buildOptions(input map[string]interface{}) SearchOptions {
 	return options
 }
 
+// findMatchingFiles returns the absolute paths of searchable files whose
+// name matches pattern.
 func (t *SearchTool) findMatchingFiles(ctx context.Context, pattern string) ([]string, error) {
-	// Use find to locate files recursively. This provides a single source of truth
-	// and consistent behavior across platforms (within POSIX constraints).
-	cmd := exec.CommandContext(ctx, "find", t.workDir, "-name", pattern, "-type", "f")
-
-	var outBuf bytes.Buffer
-	const maxOutputSize = 2 * 1024 * 1024 // 2MB limit for file list
-	cmd.Stdout = &limitedWriter{w: &outBuf, limit: maxOutputSize}
-
-	err := cmd.Run()
+	files, err := t.engine.Files(ctx, []string{pattern}, nil)
 	if err != nil {
-		// Check if context was canceled
 		if ctx.Err() != nil {
 			return nil, fmt.Errorf("find canceled: %w", ctx.Err())
 		}
-		// No matches or find error: return empty slice to indicate no files
-		return []string{}, nil
+		return nil, err
 	}
 
-	var matches []string
-	scanner := bufio.NewScanner(&outBuf)
-	for scanner.Scan() {
-		file := scanner.Text()
-		if file != "" {
-			matches = append(matches, file)
-		}
+	matches := make([]string, 0, len(files))
+	for _, file := range files {
+		matches = append(matches, filepath.Join(t.workDir, filepath.FromSlash(file)))
 	}
-
 	return matches, nil
 }
 
-func (t *SearchTool) parseGrepOutput(output string) []Result {
-	var results []Result
-	scanner := bufio.NewScanner(strings.NewReader(output))
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		// Parse grep output format: file:line:content
-		parts := strings.SplitN(line, ":", 3)
-		if len(parts) >= 3 {
-			lineNum := 0
-			if _, err := fmt.Sscanf(parts[1], "%d", &lineNum); err != nil {
-				continue // Skip lines with invalid line numbers
-			}
-
-			// Make path relative to workdir
-			relPath, _ := filepath.Rel(t.workDir, parts[0])
-
-			results = append(results, Result{
-				File:    relPath,
-				Line:    lineNum,
-				Content: parts[2],
-			})
-		}
-	}
-
-	return results
-}
-
-func (t *SearchTool) parseSymbolOutput(output string, symbol string) []Location {
-	var locations []Location
-	scanner := bufio.NewScanner(strings.NewReader(output))
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		// Parse grep output format: file:line:content
-		parts := strings.SplitN(line, ":", 3)
-		if len(parts) >= 3 {
-			lineNum := 0
-			if _, err := fmt.Sscanf(parts[1], "%d", &lineNum); err != nil {
-				continue // Skip lines with invalid line numbers
-			}
-
-			// Make path relative to workdir
-			relPath, _ := filepath.Rel(t.workDir, parts[0])
-
-			// Determine symbol type from content
-			content := parts[2]
-			symbolType := t.detectSymbolType(content, symbol)
-
-			locations = append(locations, Location{
-				File: relPath,
-				Line: lineNum,
-				Type: symbolType,
-			})
-		}
-	}
-
-	return locations
-}
-
 func (t *SearchTool) detectSymbolType(content, symbol string) string {
 	content = strings.TrimSpace(content)
 
@@ -494,8 +427,56 @@ func (t *SearchTool) formatCodeResults(results []Result) string {
 	output.WriteString(fmt.Sprintf("Found %d matches:\n\n", len(results)))
 
 	for _, result := range results {
-		output.WriteString(fmt.Sprintf("%s:%d\n", result.File, result.Line))
-		output.WriteString(fmt.Sprintf("  %s\n", strings.TrimSpace(result.Content)))
+		switch {
+		case result.EndLine > result.Line:
+			output.WriteString(fmt.Sprintf("%s:%d-%d\n", result.File, result.Line, result.EndLine))
+			for _, line := range strings.Split(result.Content, "\n") {
+				output.WriteString(fmt.Sprintf("  %s\n", line))
+			}
+		case len(result.Before) > 0 || len(result.After) > 0:
+			output.WriteString(fmt.Sprintf("%s:%d\n", result.File, result.Line))
+			first := result.Line - len(result.Before)
+			for i, line := range result.Before {
+				output.WriteString(fmt.Sprintf("  %d- %s\n", first+i, line))
+			}
+			output.WriteString(fmt.Sprintf("  %d: %s\n", result.Line, result.Content))
+			for i, line := range result.After {
+				output.WriteString(fmt.Sprintf("  %d- %s\n", result.Line+1+i, line))
+			}
+		default:
+			output.WriteString(fmt.Sprintf("%s:%d\n", result.File, result.Line))
+			output.WriteString(fmt.Sprintf("  %s\n", strings.TrimSpace(result.Content)))
+		}
+		output.WriteString("\n")
+	}
+
+	return output.String()
+}
+
+func (t *SearchTool) formatRankedResults(files []RankedFile) string {
+	if len(files) == 0 {
+		return "No relevant files found."
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Found %d relevant files, most relevant first:\n\n", len(files)))
+
+	for _, file := range files {
+		output.WriteString(fmt.Sprintf("%s (score %.2f)\n", file.File, file.Score))
+		for _, snippet := range file.Snippets {
+			first := snippet.Line - len(snippet.Before)
+			for i, line := range snippet.Before {
+				output.WriteString(fmt.Sprintf("  %d- %s\n", first+i, line))
+			}
+			content := snippet.Content
+			if len(snippet.Before) == 0 && len(snippet.After) == 0 {
+				content = strings.TrimSpace(content)
+			}
+			output.WriteString(fmt.Sprintf("  %d: %s\n", snippet.Line, content))
+			for i, line := range snippet.After {
+				output.WriteString(fmt.Sprintf("  %d- %s\n", snippet.Line+1+i, line))
+			}
+		}
 		output.WriteString("\n")
 	}
 
@@ -532,30 +513,24 @@ func getStringParam(input map[string]interface{}, key string) (string, error) {
 	return str, nil
 }
 
-// limitedWriter wraps an io.Writer with a maximum size limit
-type limitedWriter struct {
-	w       *bytes.Buffer
-	limit   int
-	written int
-}
-
-func (lw *limitedWriter) Write(p []byte) (n int, err error) {
-	if lw.written >= lw.limit {
-		return 0, fmt.Errorf("output size limit exceeded (%d bytes)", lw.limit)
-	}
-
-	remaining := lw.limit - lw.written
-	toWrite := len(p)
-	if toWrite > remaining {
-		toWrite = remaining
-	}
-
-	n, writeErr := lw.w.Write(p[:toWrite])
-	lw.written += n
-
-	if toWrite < len(p) {
-		return n, fmt.Errorf("output size limit exceeded (%d bytes)", lw.limit)
+// getStringSlice extracts an optional string array parameter. A single
+// string is accepted as a one-element list.
+func getStringSlice(input map[string]interface{}, key string) []string {
+	switch val := input[key].(type) {
+	case string:
+		if val != "" {
+			return []string{val}
+		}
+	case []string:
+		return val
+	case []interface{}:
+		var out []string
+		for _, item := range val {
+			if str, ok := item.(string); ok && str != "" {
+				out = append(out, str)
+			}
+		}
+		return out
 	}
-
-	return n, writeErr
+	return nil
 }
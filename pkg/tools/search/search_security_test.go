@@ -182,78 +182,3 @@ func TestFindMatchingFilesWithContext(t *testing.T) {
 		t.Errorf("Expected cancellation error or success, got: %v", err)
 	}
 }
-
-// TestLimitedWriter tests the limitedWriter implementation
-func TestLimitedWriter(t *testing.T) {
-	tests := []struct {
-		name      string
-		limit     int
-		writes    [][]byte
-		expectErr bool
-	}{
-		{
-			name:      "within limit",
-			limit:     100,
-			writes:    [][]byte{[]byte("hello"), []byte("world")},
-			expectErr: false,
-		},
-		{
-			name:      "exact limit",
-			limit:     10,
-			writes:    [][]byte{[]byte("hello"), []byte("world")},
-			expectErr: false,
-		},
-		{
-			name:      "exceed limit",
-			limit:     5,
-			writes:    [][]byte{[]byte("hello"), []byte("world")},
-			expectErr: true,
-		},
-		{
-			name:      "single large write",
-			limit:     10,
-			writes:    [][]byte{[]byte("this is a very long string")},
-			expectErr: true,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			var buf strings.Builder
-			writer := &limitedWriter{
-				w:     nil, // We'll test with strings.Builder
-				limit: tt.limit,
-			}
-
-			for _, data := range tt.writes {
-				// Simulate writing to buffer
-				n := len(data)
-				if writer.written+n > writer.limit {
-					n = writer.limit - writer.written
-					if n > 0 {
-						buf.Write(data[:n])
-					}
-					writer.written += n
-					if writer.written >= writer.limit {
-						break
-					}
-				} else {
-					buf.Write(data)
-					writer.written += n
-				}
-			}
-
-			hasError := writer.written >= writer.limit && len(tt.writes) > 0 &&
-				(func() int { total := 0; for _, w := range tt.writes { total += len(w) }; return total }()) > writer.limit
-
-			if hasError != tt.expectErr {
-				t.Errorf("Expected error: %v, got error: %v (written: %d, limit: %d)",
-					tt.expectErr, hasError, writer.written, writer.limit)
-			}
-
-			if writer.written > writer.limit {
-				t.Errorf("Written bytes (%d) exceeds limit (%d)", writer.written, writer.limit)
-			}
-		})
-	}
-}
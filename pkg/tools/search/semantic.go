@@ -0,0 +1,135 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Zerofisher/goai/pkg/semantic"
+)
+
+const (
+	// defaultSemanticResults is the number of chunks returned when k is
+	// not given.
+	defaultSemanticResults = 8
+	// maxSemanticLines is the number of lines shown per chunk.
+	maxSemanticLines = 30
+)
+
+// SemanticSearchTool finds code by meaning rather than by text, using a
+// semantic.Index of embedded chunks.
+type SemanticSearchTool struct {
+	index      *semantic.Index
+	maxResults int
+}
+
+// NewSemanticSearchTool creates a semantic search tool over index.
+// maxResults is the default number of chunks returned.
+func NewSemanticSearchTool(index *semantic.Index, maxResults int) *SemanticSearchTool {
+	if maxResults <= 0 {
+		maxResults = defaultSemanticResults
+	}
+	return &SemanticSearchTool{
+		index:      index,
+		maxResults: maxResults,
+	}
+}
+
+// Name returns the name of the tool.
+func (t *SemanticSearchTool) Name() string {
+	return "semantic_search"
+}
+
+// Description returns the description of the tool.
+func (t *SemanticSearchTool) Description() string {
+	return "Find code by meaning: returns the code chunks (Go declarations or line windows) most similar to a natural-language query such as 'where do we refresh expired auth tokens'. Use it when you do not know the names to search for"
+}
+
+// InputSchema returns the JSON schema for the input.
+func (t *SemanticSearchTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"query": map[string]interface{}{
+				"type":        "string",
+				"description": "What the code does, in plain language",
+			},
+			"k": map[string]interface{}{
+				"type":        "integer",
+				"description": "Number of chunks to return",
+				"default":     t.maxResults,
+			},
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Only search files below this directory (relative to the work directory)",
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+// Validate checks if the input is valid.
+func (t *SemanticSearchTool) Validate(input map[string]interface{}) error {
+	query, ok := input["query"].(string)
+	if !ok || strings.TrimSpace(query) == "" {
+		return fmt.Errorf("missing required field: query")
+	}
+
+	if k, ok := input["k"]; ok {
+		if val, ok := k.(float64); !ok || val < 1 || val > 50 {
+			return fmt.Errorf("k must be between 1 and 50")
+		}
+	}
+
+	return nil
+}
+
+// Execute embeds the query and returns the most similar chunks. The index
+// is brought up to date first.
+func (t *SemanticSearchTool) Execute(ctx context.Context, input map[string]interface{}) (string, error) {
+	query, err := getStringParam(input, "query")
+	if err != nil {
+		return "", err
+	}
+
+	k := t.maxResults
+	if val, ok := input["k"].(float64); ok {
+		k = int(val)
+	}
+	dir, _ := input["path"].(string)
+
+	matches, err := t.index.Search(ctx, query, k, dir)
+	if err != nil {
+		return "", err
+	}
+	return formatSemanticResults(matches), nil
+}
+
+func formatSemanticResults(matches []semantic.Match) string {
+	if len(matches) == 0 {
+		return "No indexed code found."
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Found %d chunks, most similar first:\n\n", len(matches)))
+
+	for _, m := range matches {
+		header := fmt.Sprintf("%s:%d-%d", m.Path, m.StartLine, m.EndLine)
+		if m.Symbol != "" {
+			header += " " + m.Symbol
+		}
+		output.WriteString(fmt.Sprintf("%s (similarity %.3f)\n", header, m.Score))
+
+		lines := strings.Split(m.Text, "\n")
+		for i, line := range lines {
+			if i == maxSemanticLines {
+				output.WriteString(fmt.Sprintf("  ... %d more lines\n", len(lines)-maxSemanticLines))
+				break
+			}
+			output.WriteString(fmt.Sprintf("  %d: %s\n", m.StartLine+i, line))
+		}
+		output.WriteString("\n")
+	}
+
+	return output.String()
+}
@@ -0,0 +1,50 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Zerofisher/goai/pkg/llm"
+	"github.com/Zerofisher/goai/pkg/semantic"
+)
+
+// TestSemanticSearchTool tests validation and output of semantic_search
+func TestSemanticSearchTool(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "auth/token.go"), "package auth\n\n// RefreshToken renews an expired auth token.\nfunc RefreshToken() {}\n")
+	writeTestFile(t, filepath.Join(dir, "render/table.go"), "package render\n\n// Table renders rows as a markdown table.\nfunc Table() {}\n")
+
+	index := semantic.New(dir, filepath.Join(dir, ".goai", "index", "semantic.gob"), llm.NewHashEmbedder(0))
+	tool := NewSemanticSearchTool(index, 0)
+
+	tests := []struct {
+		name    string
+		input   map[string]interface{}
+		wantErr bool
+	}{
+		{"valid", map[string]interface{}{"query": "refresh tokens", "k": float64(3)}, false},
+		{"missing query", map[string]interface{}{}, true},
+		{"blank query", map[string]interface{}{"query": "  "}, true},
+		{"k too large", map[string]interface{}{"query": "x", "k": float64(100)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tool.Validate(tt.input); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	output, err := tool.Execute(context.Background(), map[string]interface{}{"query": "where do we refresh auth tokens", "k": float64(1)})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(output, "auth/token.go:3-4 RefreshToken (similarity ") {
+		t.Errorf("expected the RefreshToken chunk, got:\n%s", output)
+	}
+	if !strings.Contains(output, "  4: func RefreshToken() {}") || strings.Contains(output, "render/table.go") {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+}
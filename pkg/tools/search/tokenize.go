@@ -0,0 +1,123 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopWords are dropped from indexed text and queries. They are frequent
+// in natural-language questions and comments and carry no meaning for
+// ranking.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "do": true, "does": true, "for": true, "from": true,
+	"how": true, "in": true, "is": true, "it": true, "of": true, "on": true,
+	"or": true, "the": true, "this": true, "that": true, "to": true, "what": true,
+	"when": true, "where": true, "which": true, "with": true,
+}
+
+// tokenize calls fn for every term of text. Identifiers are indexed whole
+// and by their parts, so "parseHTTPRequest" yields "parsehttprequest",
+// "parse", "http" and "request", and "max_file_size" yields
+// "max_file_size", "max", "file" and "size". Terms are lower-cased and
+// plural endings are removed.
+func tokenize(text string, fn func(term string)) {
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+		switch {
+		case word && start < 0:
+			start = i
+		case !word && start >= 0:
+			tokenizeWord(text[start:i], fn)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokenizeWord(text[start:], fn)
+	}
+}
+
+// tokenizeWord emits the terms of one identifier or word.
+func tokenizeWord(word string, fn func(term string)) {
+	word = strings.Trim(word, "_")
+	if word == "" {
+		return
+	}
+	parts := splitIdentifier(word)
+	emitTerm(word, fn)
+	if len(parts) > 1 {
+		for _, part := range parts {
+			emitTerm(part, fn)
+		}
+	}
+}
+
+// emitTerm normalizes term and passes it on unless it is too short, a
+// number or a stop word.
+func emitTerm(term string, fn func(term string)) {
+	term = strings.ToLower(term)
+	if len(term) < 2 || stopWords[term] || isNumber(term) {
+		return
+	}
+	fn(stem(term))
+}
+
+// splitIdentifier splits snake_case and camelCase identifiers. Runs of
+// capitals are kept together as acronyms: "HTTPServer" gives "HTTP" and
+// "Server".
+func splitIdentifier(word string) []string {
+	var parts []string
+	for _, chunk := range strings.Split(word, "_") {
+		runes := []rune(chunk)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			prev, cur := runes[i-1], runes[i]
+			boundary := unicode.IsUpper(cur) && (unicode.IsLower(prev) || unicode.IsDigit(prev)) ||
+				unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if boundary {
+				parts = append(parts, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			parts = append(parts, string(runes[start:]))
+		}
+	}
+	return parts
+}
+
+// stem removes plural endings so "errors" and "error", or "retries" and
+// "retry", share a term.
+func stem(term string) string {
+	if len(term) > 4 && strings.HasSuffix(term, "ies") {
+		return term[:len(term)-3] + "y"
+	}
+	if len(term) > 3 && strings.HasSuffix(term, "s") && !strings.HasSuffix(term, "ss") {
+		return term[:len(term)-1]
+	}
+	return term
+}
+
+// isNumber reports whether s consists of digits only.
+func isNumber(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// queryTerms returns the distinct terms of a query in order.
+func queryTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	tokenize(query, func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	})
+	return terms
+}
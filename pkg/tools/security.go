@@ -5,6 +5,10 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/Zerofisher/goai/pkg/git"
+	"github.com/Zerofisher/goai/pkg/patch"
+	"github.com/Zerofisher/goai/pkg/shell"
 )
 
 // SecurityValidator validates tool inputs for security concerns
@@ -19,12 +23,28 @@ type SecurityValidator interface {
 	CheckPermission(tool string, input map[string]interface{}) error
 }
 
+// CommandValidator validates shell command lines, including the scripts
+// nested in them. The bash tool's validator implements it.
+type CommandValidator interface {
+	// ValidateCommand checks if a command line is safe to execute
+	ValidateCommand(command string) error
+
+	// AddCheck adds a rule applied to every simple command
+	AddCheck(check func(shell.Command) error)
+}
+
 // DefaultSecurityValidator provides default security validation
 type DefaultSecurityValidator struct {
 	workDir           string
 	forbiddenCommands []string
 	forbiddenPaths    []string
 	allowedDirs       []string
+
+	// allowDestructiveGit permits git operations that can lose work
+	allowDestructiveGit bool
+
+	// commands validates command lines when set
+	commands CommandValidator
 }
 
 // NewSecurityValidator creates a new security validator
@@ -78,6 +98,12 @@ func (v *DefaultSecurityValidator) SetAllowedDirs(dirs []string) {
 	v.allowedDirs = dirs
 }
 
+// SetAllowDestructiveGit sets whether the git tool may run operations that
+// can lose work, such as force-deleting a branch or amending a commit
+func (v *DefaultSecurityValidator) SetAllowDestructiveGit(allow bool) {
+	v.allowDestructiveGit = allow
+}
+
 // ValidatePath checks if a file path is safe to access
 func (v *DefaultSecurityValidator) ValidatePath(path string) error {
 	// Expand home directory first if needed
@@ -208,46 +234,85 @@ func (v *DefaultSecurityValidator) ValidatePath(path string) error {
 	return nil
 }
 
-// ValidateCommand checks if a command is safe to execute
+// dangerousCommands are command patterns rejected in addition to the
+// configured forbidden commands. They are matched per simple command with
+// shell.Command.Matches.
+var dangerousCommands = []string{
+	"sudo rm",
+	"sudo dd",
+	"sudo mkfs",
+	"chmod -R 777",
+	"chmod 777",
+	"chown -R",
+}
+
+// SetCommandValidator makes ValidateCommand use commands, which walks
+// nested scripts such as "bash -c" and here-documents. The forbidden and
+// dangerous commands of v are added to it as a check, so they apply to
+// nested commands too.
+func (v *DefaultSecurityValidator) SetCommandValidator(commands CommandValidator) {
+	commands.AddCheck(v.checkCommand)
+	v.commands = commands
+}
+
+// ValidateCommand checks if a command is safe to execute.
+// The command is parsed into its simple commands and each one is checked,
+// so chaining (&&, ||, ;) and substitutions are allowed as long as every
+// command they run is. With a command validator set, the check is left to
+// it, which also covers nested scripts.
 func (v *DefaultSecurityValidator) ValidateCommand(cmd string) error {
 	// Check for empty command
 	if strings.TrimSpace(cmd) == "" {
 		return fmt.Errorf("empty command")
 	}
 
-	// Convert to lowercase for case-insensitive comparison
-	cmdLower := strings.ToLower(cmd)
+	if v.commands != nil {
+		return v.commands.ValidateCommand(cmd)
+	}
 
-	// Check against forbidden commands
-	for _, forbidden := range v.forbiddenCommands {
-		if strings.Contains(cmdLower, strings.ToLower(forbidden)) {
-			return fmt.Errorf("command contains forbidden pattern: %s", forbidden)
+	script, err := shell.Parse(cmd)
+	if err != nil {
+		return fmt.Errorf("potential shell injection detected: %w", err)
+	}
+
+	// Check for shell injection attempts
+	if err := checkShellInjection(cmd, script); err != nil {
+		return fmt.Errorf("potential shell injection detected: %w", err)
+	}
+
+	for _, c := range script.Commands {
+		if err := v.checkCommand(c); err != nil {
+			return err
 		}
 	}
 
-	// Check for dangerous patterns
-	dangerousPatterns := []string{
-		"sudo rm",
-		"sudo dd",
-		"sudo mkfs",
-		"> /dev/",
-		"< /dev/zero",
-		"< /dev/random",
-		"/dev/null >",
-		"chmod -R 777",
-		"chmod 777",
-		"chown -R",
+	return nil
+}
+
+// checkCommand checks a simple command against the forbidden and dangerous
+// commands and rejects device access through redirections.
+func (v *DefaultSecurityValidator) checkCommand(c shell.Command) error {
+	for _, forbidden := range v.forbiddenCommands {
+		if c.Matches(forbidden) {
+			return shell.NewCommandError(c, "command contains forbidden pattern %q", forbidden)
+		}
 	}
 
-	for _, pattern := range dangerousPatterns {
-		if strings.Contains(cmdLower, strings.ToLower(pattern)) {
-			return fmt.Errorf("command contains dangerous pattern: %s", pattern)
+	for _, pattern := range dangerousCommands {
+		if c.Matches(pattern) {
+			return shell.NewCommandError(c, "command contains dangerous pattern %q", pattern)
 		}
 	}
 
-	// Check for shell injection attempts
-	if containsShellInjection(cmd) {
-		return fmt.Errorf("potential shell injection detected")
+	for _, r := range c.Redirects {
+		if !strings.HasPrefix(r.Target, "/dev/") || r.IsHeredoc() {
+			continue
+		}
+		switch r.Target {
+		case "/dev/null", "/dev/stdout", "/dev/stderr", "/dev/tty":
+			continue
+		}
+		return shell.NewCommandError(c, "redirection to device %s", r.Target)
 	}
 
 	return nil
@@ -271,18 +336,63 @@ func (v *DefaultSecurityValidator) CheckPermission(tool string, input map[string
 		if filePath, ok := input["file_path"].(string); ok {
 			return v.ValidatePath(filePath)
 		}
-	case "read_file", "write_file", "edit_file":
+	case "read_file", "write_file", "edit_file", "outline":
 		if path, ok := input["path"].(string); ok {
 			return v.ValidatePath(path)
 		}
 		if filePath, ok := input["file_path"].(string); ok {
 			return v.ValidatePath(filePath)
 		}
+	case "multi_edit":
+		// Every edit in the transaction must target an allowed path
+		edits, _ := input["edits"].([]interface{})
+		for _, e := range edits {
+			edit, _ := e.(map[string]interface{})
+			if path, ok := edit["path"].(string); ok {
+				if err := v.ValidatePath(path); err != nil {
+					return err
+				}
+			}
+		}
+	case "apply_patch":
+		// Every file the patch creates, changes, renames or deletes
+		text, _ := input["patch"].(string)
+		for _, path := range patch.Paths(text) {
+			if err := v.ValidatePath(path); err != nil {
+				return err
+			}
+		}
 	case "list_files":
 		// Validate directory if provided
 		if dir, ok := input["dir"].(string); ok {
 			return v.ValidatePath(dir)
 		}
+	case "glob", "tree", "semantic_search":
+		if dir, ok := input["path"].(string); ok {
+			return v.ValidatePath(dir)
+		}
+
+	case "git":
+		if path, ok := input["path"].(string); ok {
+			if err := v.ValidatePath(path); err != nil {
+				return err
+			}
+		}
+		paths, _ := input["paths"].([]interface{})
+		for _, p := range paths {
+			if path, ok := p.(string); ok {
+				if err := v.ValidatePath(path); err != nil {
+					return err
+				}
+			}
+		}
+		// Operations that can lose work are classified like the same
+		// command run through bash
+		if !v.allowDestructiveGit {
+			if reason := git.DestructiveCommandIn(v.workDir, gitToolArgs(input)); reason != "" {
+				return fmt.Errorf("git %s is not allowed (set tools.git.allow_destructive to permit it)", reason)
+			}
+		}
 
 	case "delete", "remove":
 		// Extra strict for delete operations
@@ -300,49 +410,78 @@ func (v *DefaultSecurityValidator) CheckPermission(tool string, input map[string
 	return nil
 }
 
+// gitToolArgs returns the git command line equivalent to a git tool call,
+// as far as it matters for DestructiveCommand.
+func gitToolArgs(input map[string]interface{}) []string {
+	action, _ := input["action"].(string)
+	operation, _ := input["operation"].(string)
+	force, _ := input["force"].(bool)
+	switch action {
+	case "branch":
+		switch {
+		case operation == "delete" && force:
+			return []string{"branch", "-D"}
+		case operation == "create" && force:
+			return []string{"branch", "--force"}
+		case operation == "switch" && force:
+			return []string{"switch", "--discard-changes"}
+		}
+	case "commit":
+		if amend, _ := input["amend"].(bool); amend {
+			return []string{"commit", "--amend"}
+		}
+	case "stash":
+		if operation == "drop" {
+			return []string{"stash", "drop"}
+		}
+	}
+	return []string{action}
+}
+
 // containsShellInjection checks for potential shell injection patterns
 func containsShellInjection(cmd string) bool {
-	// Check for common injection patterns
-	injectionPatterns := []string{
-		"$(",    // Command substitution
-		"`",     // Backticks for command substitution
-		"$(IFS", // IFS manipulation
-		"${IFS", // IFS manipulation
-		"\r",    // Carriage return injection
+	script, err := shell.Parse(cmd)
+	if err != nil {
+		return true
 	}
+	return checkShellInjection(cmd, script) != nil
+}
 
-	for _, pattern := range injectionPatterns {
-		if strings.Contains(cmd, pattern) {
-			return true
-		}
+// checkShellInjection inspects a parsed command for constructs that hide
+// what is actually executed.
+func checkShellInjection(cmd string, script *shell.Script) error {
+	// Carriage returns can make a command display differently than it runs
+	if strings.Contains(cmd, "\r") {
+		return fmt.Errorf("carriage return in command")
 	}
 
-	// Check for dangerous command chaining with newlines
-	// Allow \n in quoted strings or heredocs, but not for command injection
-	if strings.Contains(cmd, "\n") {
-		// Simple heuristic: if there are multiple command-like structures, it's suspicious
-		lines := strings.Split(cmd, "\n")
-		if len(lines) > 1 {
-			// Allow heredocs (cat << EOF)
-			if !strings.Contains(cmd, "<<") && !strings.Contains(cmd, "EOF") {
-				return true
-			}
+	// IFS manipulation changes how words are split
+	for _, param := range script.Params {
+		if param == "IFS" {
+			return fmt.Errorf("use of $IFS")
 		}
 	}
 
-	// Check for command chaining and separators (security risk)
-	// Block && (and), || (or), and ; (separator) to prevent command injection
-	if strings.Contains(cmd, "&&") {
-		return true
-	}
-	if strings.Contains(cmd, "||") {
-		return true
+	// Recursive functions (fork bombs)
+	for _, fn := range script.Functions {
+		if fn.Recursive {
+			return fmt.Errorf("recursive function %q at %d:%d", fn.Name, fn.Line, fn.Col)
+		}
 	}
-	if strings.Contains(cmd, ";") {
-		return true
+
+	for _, c := range script.Commands {
+		// The program to run must be known statically
+		if c.Dynamic {
+			return shell.NewCommandError(c, "command name is computed at runtime")
+		}
+		for _, name := range c.Assigns {
+			if name == "IFS" {
+				return shell.NewCommandError(c, "assignment to IFS")
+			}
+		}
 	}
 
-	return false
+	return nil
 }
 
 // PathSanitizer provides path sanitization utilities.
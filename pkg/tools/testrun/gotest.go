@@ -0,0 +1,447 @@
+package testrun
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Zerofisher/goai/pkg/affected"
+)
+
+const (
+	// maxOutputLines is the number of trailing output lines kept per test
+	// or failed package.
+	maxOutputLines = 40
+	// maxLocations is the number of file:line locations kept per test.
+	maxLocations = 5
+)
+
+var (
+	// logLocation matches the position t.Log/t.Error prefix lines with,
+	// e.g. "    parse_test.go:42: unexpected token".
+	logLocation = regexp.MustCompile(`^\s*([\w./\\-]+\.go):(\d+)(?::\d+)?: `)
+	// stackLocation matches the absolute positions in panic stack traces,
+	// e.g. "\t/home/me/mod/parse.go:42 +0x1d".
+	stackLocation = regexp.MustCompile(`^\s+(/\S+\.go):(\d+)`)
+	// framingLine matches the lines go test frames each test with.
+	framingLine = regexp.MustCompile(`^\s*(=== (RUN|PAUSE|CONT|NAME)|--- (PASS|FAIL|SKIP):)`)
+)
+
+// GoRunner runs Go tests with "go test -json".
+type GoRunner struct{}
+
+// NewGoRunner creates a runner for Go tests.
+func NewGoRunner() *GoRunner {
+	return &GoRunner{}
+}
+
+// Name returns the framework name.
+func (r *GoRunner) Name() string {
+	return "go"
+}
+
+// Detect reports whether workDir is inside a Go module or workspace.
+func (r *GoRunner) Detect(workDir string) bool {
+	for _, name := range []string{"go.mod", "go.work"} {
+		if _, err := os.Stat(filepath.Join(workDir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Run runs "go test -json" for req and parses the event stream. Packages
+// default to ./... and Run is passed as -run.
+func (r *GoRunner) Run(ctx context.Context, workDir string, req Request) (*Report, error) {
+	args := []string{"test", "-json"}
+	if req.Run != "" {
+		args = append(args, "-run="+req.Run)
+	}
+	packages := req.Packages
+	if len(packages) == 0 {
+		packages = []string{"./..."}
+	}
+	args = append(args, packages...)
+
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = workDir
+	// Test binaries inherit the pipes; don't wait for them after a kill
+	cmd.WaitDelay = 2 * time.Second
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to run go test: %w", err)
+	}
+	parser := newGoParser(workDir, readModulePath(workDir))
+	parseErr := parser.parse(stdout)
+	waitErr := cmd.Wait()
+
+	report := parser.report()
+	report.Commands = []string{"go " + strings.Join(args, " ")}
+	report.DurationMs = time.Since(start).Milliseconds()
+	report.TimedOut = ctx.Err() == context.DeadlineExceeded
+	if out := strings.TrimSpace(stderr.String()); out != "" {
+		report.Output = joinOutput(report.Output, tail(strings.Split(out, "\n"), maxOutputLines))
+	}
+
+	if waitErr != nil && !report.TimedOut {
+		if _, ok := waitErr.(*exec.ExitError); !ok {
+			return nil, fmt.Errorf("go test failed: %w", waitErr)
+		}
+		// A failing exit without any failure in the stream means go test
+		// itself failed, e.g. on a bad package pattern
+		if report.OK() && len(report.Packages) == 0 {
+			return nil, fmt.Errorf("go test failed: %s", firstLine(report.Output, waitErr.Error()))
+		}
+	}
+	if parseErr != nil && !report.TimedOut {
+		return nil, fmt.Errorf("failed to read go test output: %w", parseErr)
+	}
+	return report, nil
+}
+
+// Affected returns the packages whose tests are affected by changes to
+// files, from the reverse dependencies reported by "go list -deps -test".
+func (r *GoRunner) Affected(ctx context.Context, workDir string, files []string) ([]string, error) {
+	return affected.Packages(ctx, workDir, files)
+}
+
+// Rerun re-runs the failed top-level tests of the packages that had them,
+// and packages that failed without a failing test in full.
+func (r *GoRunner) Rerun(report *Report) []Request {
+	var requests []Request
+
+	var packages, names []string
+	seenPkg, seenName := map[string]bool{}, map[string]bool{}
+	for _, test := range report.Tests {
+		if test.Status != StatusFail {
+			continue
+		}
+		if !seenPkg[test.Package] {
+			seenPkg[test.Package] = true
+			packages = append(packages, test.Package)
+		}
+		name, _, _ := strings.Cut(test.Name, "/")
+		if !seenName[name] {
+			seenName[name] = true
+			names = append(names, regexp.QuoteMeta(name))
+		}
+	}
+	if len(packages) > 0 {
+		requests = append(requests, Request{
+			Packages: packages,
+			Run:      "^(" + strings.Join(names, "|") + ")$",
+		})
+	}
+
+	var broken []string
+	for _, pkg := range report.Packages {
+		if pkg.Status == StatusFail && !seenPkg[pkg.Name] {
+			broken = append(broken, pkg.Name)
+		}
+	}
+	if len(broken) > 0 {
+		requests = append(requests, Request{Packages: broken})
+	}
+	return requests
+}
+
+// goEvent is one line of "go test -json" output (see "go doc test2json").
+type goEvent struct {
+	Action      string
+	Package     string
+	ImportPath  string // build-output events
+	Test        string
+	Elapsed     float64
+	Output      string
+	FailedBuild string
+}
+
+// goParser accumulates go test events into tests and packages.
+type goParser struct {
+	workDir    string
+	modulePath string
+
+	tests       map[string]*goTest
+	testOrder   []string
+	packages    map[string]*goPackage
+	pkgOrder    []string
+	buildOutput map[string][]string
+	stray       []string
+}
+
+type goTest struct {
+	result TestResult
+	output []string
+	done   bool
+}
+
+type goPackage struct {
+	result PackageResult
+	output []string
+	done   bool
+}
+
+func newGoParser(workDir, modulePath string) *goParser {
+	return &goParser{
+		workDir:     workDir,
+		modulePath:  modulePath,
+		tests:       make(map[string]*goTest),
+		packages:    make(map[string]*goPackage),
+		buildOutput: make(map[string][]string),
+	}
+}
+
+// parse reads the event stream until EOF. Lines that are not JSON events
+// are kept as stray output.
+func (p *goParser) parse(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var event goEvent
+		if len(line) == 0 || line[0] != '{' || json.Unmarshal(line, &event) != nil {
+			if text := strings.TrimSpace(string(line)); text != "" {
+				p.stray = append(p.stray, text)
+			}
+			continue
+		}
+		p.handle(event)
+	}
+	return scanner.Err()
+}
+
+func (p *goParser) handle(e goEvent) {
+	if e.Action == "build-output" {
+		p.buildOutput[e.ImportPath] = append(p.buildOutput[e.ImportPath], strings.TrimRight(e.Output, "\n"))
+		return
+	}
+	if e.Package == "" {
+		return
+	}
+
+	if e.Test == "" {
+		pkg := p.pkg(e.Package)
+		switch e.Action {
+		case "output":
+			pkg.output = append(pkg.output, strings.TrimRight(e.Output, "\n"))
+		case "pass", "fail", "skip":
+			pkg.result.Status = e.Action
+			pkg.result.DurationMs = seconds(e.Elapsed)
+			pkg.done = true
+			if e.FailedBuild != "" {
+				build := p.buildOutput[e.FailedBuild]
+				pkg.output = append(append([]string{}, build...), pkg.output...)
+			}
+		}
+		return
+	}
+
+	test := p.test(e.Package, e.Test)
+	switch e.Action {
+	case "output":
+		line := strings.TrimRight(e.Output, "\n")
+		if !framingLine.MatchString(line) {
+			test.output = append(test.output, strings.TrimPrefix(line, "    "))
+		}
+	case "pass", "fail", "skip":
+		test.result.Status = e.Action
+		test.result.DurationMs = seconds(e.Elapsed)
+		test.done = true
+	}
+}
+
+func (p *goParser) pkg(name string) *goPackage {
+	pkg, ok := p.packages[name]
+	if !ok {
+		pkg = &goPackage{result: PackageResult{Name: name}}
+		p.packages[name] = pkg
+		p.pkgOrder = append(p.pkgOrder, name)
+	}
+	return pkg
+}
+
+func (p *goParser) test(pkgName, name string) *goTest {
+	p.pkg(pkgName)
+	key := pkgName + " " + name
+	test, ok := p.tests[key]
+	if !ok {
+		test = &goTest{result: TestResult{Package: pkgName, Name: name}}
+		p.tests[key] = test
+		p.testOrder = append(p.testOrder, key)
+	}
+	return test
+}
+
+// report builds the Report. Tests and packages that never finished (the
+// run was killed) count as failed. A failed parent test is dropped when it
+// only failed because of its subtests.
+func (p *goParser) report() *Report {
+	report := &Report{Framework: "go", Packages: []PackageResult{}}
+
+	failedParents := make(map[string]bool)
+	for _, key := range p.testOrder {
+		test := p.tests[key]
+		if !test.done {
+			test.result.Status = StatusFail
+			test.output = append(test.output, "(test did not finish)")
+		}
+		if test.result.Status == StatusFail {
+			name := test.result.Name
+			for i := strings.LastIndex(name, "/"); i > 0; i = strings.LastIndex(name, "/") {
+				name = name[:i]
+				failedParents[test.result.Package+" "+name] = true
+			}
+		}
+	}
+
+	for _, key := range p.testOrder {
+		test := p.tests[key]
+		if test.result.Status == StatusFail && failedParents[key] && len(test.output) == 0 {
+			continue
+		}
+		pkg := p.packages[test.result.Package]
+		switch test.result.Status {
+		case StatusPass:
+			report.Passed++
+			pkg.result.Passed++
+		case StatusFail:
+			report.Failed++
+			pkg.result.Failed++
+			test.result.Locations = p.locations(test.result.Package, test.output)
+		case StatusSkip:
+			report.Skipped++
+			pkg.result.Skipped++
+		}
+		if test.result.Status != StatusPass {
+			test.result.Output = tail(test.output, maxOutputLines)
+		}
+		report.Tests = append(report.Tests, test.result)
+	}
+
+	for _, name := range p.pkgOrder {
+		pkg := p.packages[name]
+		if !pkg.done {
+			pkg.result.Status = StatusFail
+			pkg.output = append(pkg.output, "(package did not finish)")
+		}
+		// Packages without test files report as skipped; leave them out
+		if pkg.result.Status == StatusSkip && pkg.result.Passed+pkg.result.Failed+pkg.result.Skipped == 0 {
+			continue
+		}
+		if pkg.result.Status == StatusFail && pkg.result.Failed == 0 {
+			pkg.result.Output = tail(pkg.output, maxOutputLines)
+		}
+		report.Packages = append(report.Packages, pkg.result)
+	}
+
+	report.Output = tail(p.stray, maxOutputLines)
+	return report
+}
+
+// locations extracts the file:line positions from a failed test's output.
+// Log positions are relative to the package directory; stack positions are
+// absolute and only kept inside the work directory.
+func (p *goParser) locations(pkgName string, output []string) []string {
+	dir := p.packageDir(pkgName)
+
+	var locations []string
+	seen := make(map[string]bool)
+	add := func(path, line string) {
+		loc := filepath.ToSlash(path) + ":" + line
+		if !seen[loc] && len(locations) < maxLocations {
+			seen[loc] = true
+			locations = append(locations, loc)
+		}
+	}
+
+	for _, line := range output {
+		if m := logLocation.FindStringSubmatch(line); m != nil {
+			path := m[1]
+			if dir != "" && !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			add(path, m[2])
+		} else if m := stackLocation.FindStringSubmatch(line); m != nil {
+			if rel, err := filepath.Rel(p.workDir, m[1]); err == nil && !strings.HasPrefix(rel, "..") {
+				add(rel, m[2])
+			}
+		}
+	}
+	return locations
+}
+
+// packageDir returns the directory of a package of the main module
+// relative to the work directory, or "" for packages outside it.
+func (p *goParser) packageDir(pkgName string) string {
+	switch {
+	case p.modulePath == "":
+		return ""
+	case pkgName == p.modulePath:
+		return "."
+	case strings.HasPrefix(pkgName, p.modulePath+"/"):
+		return strings.TrimPrefix(pkgName, p.modulePath+"/")
+	}
+	return ""
+}
+
+// readModulePath returns the module path declared in workDir/go.mod.
+func readModulePath(workDir string) string {
+	data, err := os.ReadFile(filepath.Join(workDir, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+	return ""
+}
+
+// tail joins the last max non-empty-trailing lines, noting how many were
+// omitted.
+func tail(lines []string, max int) string {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > max {
+		omitted := len(lines) - max
+		lines = append([]string{fmt.Sprintf("... %d lines omitted", omitted)}, lines[omitted:]...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func joinOutput(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "\n" + b
+}
+
+func firstLine(s, fallback string) string {
+	if line, _, _ := strings.Cut(strings.TrimSpace(s), "\n"); line != "" {
+		return line
+	}
+	return fallback
+}
+
+func seconds(elapsed float64) int64 {
+	return int64(elapsed * 1000)
+}
@@ -0,0 +1,54 @@
+package testrun
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ToolResponse represents the standardized JSON response format for the
+// run_tests tool: {"ok":true,"summary":"...","data":{...}}
+type ToolResponse struct {
+	Ok      bool    `json:"ok"`
+	Summary string  `json:"summary"`
+	Data    *Report `json:"data,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Success creates a response for a run whose tests all passed.
+func Success(summary string, data *Report) string {
+	resp := ToolResponse{
+		Ok:      true,
+		Summary: summary,
+		Data:    data,
+	}
+	return marshalResponse(resp)
+}
+
+// Failure creates a response for a run that completed with failing tests or
+// packages. The report is still included.
+func Failure(summary string, data *Report, err error) string {
+	resp := ToolResponse{
+		Ok:      false,
+		Summary: summary,
+		Data:    data,
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return marshalResponse(resp)
+}
+
+// Error creates an error response.
+func Error(summary string, err error) string {
+	return Failure(summary, nil, err)
+}
+
+// marshalResponse converts the response to JSON string.
+func marshalResponse(resp ToolResponse) string {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		// Fallback to plain text error if JSON marshaling fails
+		return fmt.Sprintf(`{"ok":false,"summary":"JSON marshaling error","error":"%s"}`, err.Error())
+	}
+	return string(data)
+}
@@ -0,0 +1,168 @@
+package testrun
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Test and package statuses.
+const (
+	StatusPass = "pass"
+	StatusFail = "fail"
+	StatusSkip = "skip"
+)
+
+// Scopes of a run.
+const (
+	ScopeAffected = "affected" // packages affected by the changed files
+	ScopeAll      = "all"      // every package
+	ScopePackages = "packages" // the packages given in the input
+	ScopeFailed   = "failed"   // what failed in the previous run
+)
+
+// Runner runs the tests of one framework and parses its output into a
+// Report. Frameworks other than Go plug in by implementing it.
+type Runner interface {
+	// Name is the framework name accepted by the tool's framework input.
+	Name() string
+	// Detect reports whether the framework applies to workDir.
+	Detect(workDir string) bool
+	// Run runs the tests selected by req. The returned error is only set
+	// when the tests could not be run at all; failing tests are reported.
+	Run(ctx context.Context, workDir string, req Request) (*Report, error)
+	// Rerun returns the requests that re-run only what failed in report.
+	Rerun(report *Report) []Request
+}
+
+// Selector is implemented by runners that can narrow a run to the
+// packages affected by a set of changed files.
+type Selector interface {
+	// Affected returns the packages whose tests are affected by changes to
+	// files (relative to workDir). An empty result means none are.
+	Affected(ctx context.Context, workDir string, files []string) ([]string, error)
+}
+
+// Request selects the tests to run.
+type Request struct {
+	Packages []string // package patterns; the runner's default when empty
+	Run      string   // test name filter, in the framework's syntax
+}
+
+// Report is the structured result of a test run.
+type Report struct {
+	Framework    string          `json:"framework"`
+	Scope        string          `json:"scope"`
+	ChangedFiles []string        `json:"changed_files,omitempty"` // files the affected scope was derived from
+	Commands     []string        `json:"commands"`
+	Passed       int             `json:"passed"`
+	Failed       int             `json:"failed"`
+	Skipped      int             `json:"skipped"`
+	DurationMs   int64           `json:"duration_ms"`
+	TimedOut     bool            `json:"timed_out,omitempty"`
+	Packages     []PackageResult `json:"packages"`
+	Tests        []TestResult    `json:"tests,omitempty"`
+	Truncated    bool            `json:"truncated,omitempty"` // Tests was cut short
+	Output       string          `json:"output,omitempty"`    // output not tied to a package
+}
+
+// PackageResult is the outcome of one package. Output is only set for
+// packages that failed without a failing test, such as build failures.
+type PackageResult struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Passed     int    `json:"passed"`
+	Failed     int    `json:"failed"`
+	Skipped    int    `json:"skipped"`
+	DurationMs int64  `json:"duration_ms"`
+	Output     string `json:"output,omitempty"`
+}
+
+// TestResult is the outcome of one test. Output holds what the test
+// logged (trimmed to its tail) and Locations the file:line positions
+// found in it, relative to the work directory where possible.
+type TestResult struct {
+	Package    string   `json:"package"`
+	Name       string   `json:"name"`
+	Status     string   `json:"status"`
+	DurationMs int64    `json:"duration_ms"`
+	Output     string   `json:"output,omitempty"`
+	Locations  []string `json:"locations,omitempty"`
+}
+
+// OK reports whether the run passed: no failing test or package and no
+// timeout.
+func (r *Report) OK() bool {
+	if r.Failed > 0 || r.TimedOut {
+		return false
+	}
+	for _, pkg := range r.Packages {
+		if pkg.Status == StatusFail {
+			return false
+		}
+	}
+	return true
+}
+
+// Summary returns a one-line summary such as
+// "12 passed, 1 failed, 2 skipped in 3 packages (1.4s)".
+func (r *Report) Summary() string {
+	summary := fmt.Sprintf("%d passed, %d failed, %d skipped in %d packages (%.1fs)",
+		r.Passed, r.Failed, r.Skipped, len(r.Packages), float64(r.DurationMs)/1000)
+
+	var broken []string
+	for _, pkg := range r.Packages {
+		if pkg.Status == StatusFail && pkg.Failed == 0 {
+			broken = append(broken, pkg.Name)
+		}
+	}
+	if len(broken) > 0 {
+		summary += "; packages failed without a failing test: " + strings.Join(broken, ", ")
+	}
+	if r.Scope == ScopeAffected {
+		summary += fmt.Sprintf("; only packages affected by %d changed files, use scope 'all' to widen", len(r.ChangedFiles))
+	}
+	if r.TimedOut {
+		summary += "; timed out"
+	}
+	return summary
+}
+
+// merge adds the results of other to r.
+func (r *Report) merge(other *Report) {
+	r.Commands = append(r.Commands, other.Commands...)
+	r.Passed += other.Passed
+	r.Failed += other.Failed
+	r.Skipped += other.Skipped
+	r.DurationMs += other.DurationMs
+	r.TimedOut = r.TimedOut || other.TimedOut
+	r.Packages = append(r.Packages, other.Packages...)
+	r.Tests = append(r.Tests, other.Tests...)
+	r.Truncated = r.Truncated || other.Truncated
+	if other.Output != "" {
+		if r.Output != "" {
+			r.Output += "\n"
+		}
+		r.Output += other.Output
+	}
+}
+
+// compact drops passing tests unless includePassed is set and keeps at most
+// maxTests tests, failures first.
+func (r *Report) compact(includePassed bool, maxTests int) {
+	var failed, other []TestResult
+	for _, test := range r.Tests {
+		switch {
+		case test.Status == StatusFail:
+			failed = append(failed, test)
+		case test.Status == StatusSkip || includePassed:
+			other = append(other, test)
+		}
+	}
+	tests := append(failed, other...)
+	if len(tests) > maxTests {
+		tests = tests[:maxTests]
+		r.Truncated = true
+	}
+	r.Tests = tests
+}
@@ -0,0 +1,270 @@
+package testrun
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestGoParser tests turning a go test -json stream into a report
+func TestGoParser(t *testing.T) {
+	stream := `{"Action":"start","Package":"example.com/mod/calc"}
+{"Action":"run","Package":"example.com/mod/calc","Test":"TestAdd"}
+{"Action":"output","Package":"example.com/mod/calc","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}
+{"Action":"output","Package":"example.com/mod/calc","Test":"TestAdd","Output":"--- PASS: TestAdd (0.00s)\n"}
+{"Action":"pass","Package":"example.com/mod/calc","Test":"TestAdd","Elapsed":0.01}
+{"Action":"run","Package":"example.com/mod/calc","Test":"TestDiv"}
+{"Action":"run","Package":"example.com/mod/calc","Test":"TestDiv/by_zero"}
+{"Action":"output","Package":"example.com/mod/calc","Test":"TestDiv/by_zero","Output":"        calc_test.go:21: Div(1, 0) = 0, want error\n"}
+{"Action":"output","Package":"example.com/mod/calc","Test":"TestDiv/by_zero","Output":"    --- FAIL: TestDiv/by_zero (0.00s)\n"}
+{"Action":"fail","Package":"example.com/mod/calc","Test":"TestDiv/by_zero","Elapsed":0.25}
+{"Action":"output","Package":"example.com/mod/calc","Test":"TestDiv","Output":"--- FAIL: TestDiv (0.25s)\n"}
+{"Action":"fail","Package":"example.com/mod/calc","Test":"TestDiv","Elapsed":0.25}
+{"Action":"run","Package":"example.com/mod/calc","Test":"TestSlow"}
+{"Action":"output","Package":"example.com/mod/calc","Test":"TestSlow","Output":"    calc_test.go:30: skipping in short mode\n"}
+{"Action":"skip","Package":"example.com/mod/calc","Test":"TestSlow","Elapsed":0}
+{"Action":"run","Package":"example.com/mod/calc","Test":"TestPanic"}
+{"Action":"output","Package":"example.com/mod/calc","Test":"TestPanic","Output":"panic: runtime error: index out of range [3] with length 3\n"}
+{"Action":"output","Package":"example.com/mod/calc","Test":"TestPanic","Output":"\t/work/calc/calc.go:12 +0x1d\n"}
+{"Action":"output","Package":"example.com/mod/calc","Test":"TestPanic","Output":"\t/usr/local/go/src/testing/testing.go:1690 +0xf3\n"}
+{"Action":"fail","Package":"example.com/mod/calc","Test":"TestPanic","Elapsed":0}
+{"Action":"output","Package":"example.com/mod/calc","Output":"FAIL\n"}
+{"Action":"fail","Package":"example.com/mod/calc","Elapsed":0.3}
+{"ImportPath":"example.com/mod/broken [example.com/mod/broken.test]","Action":"build-output","Output":"# example.com/mod/broken\n"}
+{"ImportPath":"example.com/mod/broken [example.com/mod/broken.test]","Action":"build-output","Output":"broken/broken.go:3:1: syntax error: unexpected }\n"}
+{"ImportPath":"example.com/mod/broken [example.com/mod/broken.test]","Action":"build-fail"}
+{"Action":"start","Package":"example.com/mod/broken"}
+{"Action":"output","Package":"example.com/mod/broken","Output":"FAIL\texample.com/mod/broken [build failed]\n"}
+{"Action":"fail","Package":"example.com/mod/broken","Elapsed":0,"FailedBuild":"example.com/mod/broken [example.com/mod/broken.test]"}
+{"Action":"start","Package":"example.com/mod/docs"}
+{"Action":"output","Package":"example.com/mod/docs","Output":"?   \texample.com/mod/docs\t[no test files]\n"}
+{"Action":"skip","Package":"example.com/mod/docs","Elapsed":0}
+`
+	parser := newGoParser("/work", "example.com/mod")
+	if err := parser.parse(strings.NewReader(stream)); err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	report := parser.report()
+
+	if report.Passed != 1 || report.Failed != 2 || report.Skipped != 1 {
+		t.Errorf("counts = %d/%d/%d, want 1 passed, 2 failed, 1 skipped", report.Passed, report.Failed, report.Skipped)
+	}
+
+	var names []string
+	for _, test := range report.Tests {
+		names = append(names, test.Name+":"+test.Status)
+	}
+	// TestDiv only failed because of its subtest and is dropped
+	want := []string{"TestAdd:pass", "TestDiv/by_zero:fail", "TestSlow:skip", "TestPanic:fail"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("tests = %v, want %v", names, want)
+	}
+
+	byZero := report.Tests[1]
+	if byZero.Output != "    calc_test.go:21: Div(1, 0) = 0, want error" || byZero.DurationMs != 250 {
+		t.Errorf("unexpected failure: %+v", byZero)
+	}
+	if !reflect.DeepEqual(byZero.Locations, []string{"calc/calc_test.go:21"}) {
+		t.Errorf("locations = %v", byZero.Locations)
+	}
+	if locations := report.Tests[3].Locations; !reflect.DeepEqual(locations, []string{"calc/calc.go:12"}) {
+		t.Errorf("panic locations = %v", locations)
+	}
+	if report.Tests[0].Output != "" {
+		t.Errorf("expected no output for a passing test, got %q", report.Tests[0].Output)
+	}
+
+	if len(report.Packages) != 2 {
+		t.Fatalf("expected the package without tests to be left out, got %+v", report.Packages)
+	}
+	broken := report.Packages[1]
+	if broken.Status != StatusFail || !strings.Contains(broken.Output, "syntax error: unexpected }") {
+		t.Errorf("expected the build failure output, got %+v", broken)
+	}
+	if report.Packages[0].Output != "" {
+		t.Errorf("expected no package output when a test failed, got %q", report.Packages[0].Output)
+	}
+
+	summary := report.Summary()
+	if !strings.HasPrefix(summary, "1 passed, 2 failed, 1 skipped in 2 packages") || !strings.Contains(summary, "example.com/mod/broken") {
+		t.Errorf("unexpected summary %q", summary)
+	}
+
+	requests := NewGoRunner().Rerun(report)
+	wantRequests := []Request{
+		{Packages: []string{"example.com/mod/calc"}, Run: "^(TestDiv|TestPanic)$"},
+		{Packages: []string{"example.com/mod/broken"}},
+	}
+	if !reflect.DeepEqual(requests, wantRequests) {
+		t.Errorf("rerun = %+v, want %+v", requests, wantRequests)
+	}
+}
+
+// TestRunTestsTool tests running a real module and re-running its failures
+func TestRunTestsTool(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/mod\n\ngo 1.21\n")
+	write("calc/calc_test.go", `package calc
+
+import "testing"
+
+func TestAdd(t *testing.T) {}
+
+func TestSub(t *testing.T) {
+	t.Errorf("Sub(2, 1) = 3, want 1")
+}
+`)
+	tool := NewRunTestsTool(dir)
+
+	tests := []struct {
+		name    string
+		input   map[string]interface{}
+		wantErr bool
+	}{
+		{"empty", map[string]interface{}{}, false},
+		{"packages", map[string]interface{}{"packages": []interface{}{"./calc"}}, false},
+		{"flag as package", map[string]interface{}{"packages": []interface{}{"-exec=sh"}}, true},
+		{"unknown framework", map[string]interface{}{"framework": "pytest"}, true},
+		{"unknown scope", map[string]interface{}{"scope": "changed"}, true},
+		{"timeout too large", map[string]interface{}{"timeout": float64(100000)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tool.Validate(tt.input); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	run := func(input map[string]interface{}) ToolResponse {
+		t.Helper()
+		output, err := tool.Execute(context.Background(), input)
+		if err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		var resp ToolResponse
+		if err := json.Unmarshal([]byte(output), &resp); err != nil {
+			t.Fatalf("invalid response %s: %v", output, err)
+		}
+		return resp
+	}
+
+	resp := run(map[string]interface{}{"failed_only": true})
+	if resp.Ok || resp.Data != nil {
+		t.Errorf("expected nothing to re-run before a run, got %+v", resp)
+	}
+
+	resp = run(map[string]interface{}{})
+	if resp.Ok || resp.Data == nil {
+		t.Fatalf("expected a failing run, got %+v", resp)
+	}
+	if resp.Data.Passed != 1 || resp.Data.Failed != 1 || len(resp.Data.Tests) != 1 {
+		t.Fatalf("unexpected report: %+v", resp.Data)
+	}
+	if failure := resp.Data.Tests[0]; failure.Name != "TestSub" || !reflect.DeepEqual(failure.Locations, []string{"calc/calc_test.go:8"}) {
+		t.Errorf("unexpected failure: %+v", failure)
+	}
+
+	// Fix the test; re-running the failures runs only TestSub
+	write("calc/calc_test.go", `package calc
+
+import "testing"
+
+func TestAdd(t *testing.T) {}
+
+func TestSub(t *testing.T) {}
+`)
+	resp = run(map[string]interface{}{"failed_only": true, "include_passed": true})
+	if !resp.Ok || resp.Data.Passed != 1 || resp.Data.Tests[0].Name != "TestSub" {
+		t.Errorf("expected only TestSub to run and pass, got %+v", resp)
+	}
+	if !strings.Contains(resp.Data.Commands[0], "-run=^(TestSub)$") {
+		t.Errorf("unexpected command %v", resp.Data.Commands)
+	}
+}
+
+// TestRunTestsToolScope tests running only the packages affected by the
+// changed files
+func TestRunTestsToolScope(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":        "module example.com/mod\n\ngo 1.21\n",
+		"a/a.go":        "package a\n\nfunc A() int { return 1 }\n",
+		"a/a_test.go":   "package a\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) {}\n",
+		"b/b.go":        "package b\n\nimport \"example.com/mod/a\"\n\nvar B = a.A()\n",
+		"b/b_test.go":   "package b\n\nimport \"testing\"\n\nfunc TestB(t *testing.T) {}\n",
+		"c/c_test.go":   "package c\n\nimport \"testing\"\n\nfunc TestC(t *testing.T) {}\n",
+		"docs/guide.md": "# guide\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var changed []string
+	tool := NewRunTestsTool(dir)
+	tool.SetChangedFiles(func(ctx context.Context) ([]string, error) {
+		return changed, nil
+	})
+	run := func(input map[string]interface{}) *Report {
+		t.Helper()
+		output, err := tool.Execute(context.Background(), input)
+		if err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		var resp ToolResponse
+		if err := json.Unmarshal([]byte(output), &resp); err != nil || !resp.Ok || resp.Data == nil {
+			t.Fatalf("unexpected response %s", output)
+		}
+		return resp.Data
+	}
+
+	changed = []string{"a/a.go"}
+	report := run(map[string]interface{}{})
+	if report.Scope != ScopeAffected || report.Passed != 2 || len(report.Packages) != 2 {
+		t.Errorf("expected a and b to be tested, got %+v", report)
+	}
+
+	report = run(map[string]interface{}{"scope": "all"})
+	if report.Scope != ScopeAll || report.Passed != 3 {
+		t.Errorf("expected every package to be tested, got %+v", report)
+	}
+
+	changed = []string{"docs/guide.md"}
+	report = run(map[string]interface{}{})
+	if report.Scope != ScopeAffected || len(report.Commands) != 0 {
+		t.Errorf("expected nothing to run, got %+v", report)
+	}
+
+	// Without changes there is nothing to narrow to
+	changed = nil
+	report = run(map[string]interface{}{})
+	if report.Scope != ScopeAll || report.Passed != 3 {
+		t.Errorf("expected every package to be tested, got %+v", report)
+	}
+}
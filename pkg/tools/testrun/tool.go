@@ -0,0 +1,302 @@
+// Package testrun provides the run_tests tool, which runs a project's tests
+// and returns structured per-test results instead of raw output.
+//
+// Frameworks plug in through the Runner interface; Go's "go test -json" is
+// built in.
+package testrun
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultTimeout bounds a run when no timeout is given.
+	defaultTimeout = 5 * time.Minute
+	// maxTimeoutSeconds is the largest accepted timeout.
+	maxTimeoutSeconds = 1800
+	// maxReportedTests is the number of tests listed in a response.
+	maxReportedTests = 50
+)
+
+// ChangedFilesFunc returns the files changed in the session, relative to
+// the work directory.
+type ChangedFilesFunc func(ctx context.Context) ([]string, error)
+
+// RunTestsTool runs tests and reports pass/fail/skip per test. It remembers
+// what failed in the last run so failed_only can re-run just that. When it
+// knows the changed files, it runs only the packages they affect by default.
+type RunTestsTool struct {
+	workDir      string
+	runners      map[string]Runner
+	order        []string
+	timeout      time.Duration
+	changedFiles ChangedFilesFunc
+
+	mu         sync.Mutex
+	lastRunner string
+	rerun      []Request
+}
+
+// NewRunTestsTool creates a run_tests tool. Runners are tried in order when
+// the framework is detected automatically; the Go runner is used when none
+// are given.
+func NewRunTestsTool(workDir string, runners ...Runner) *RunTestsTool {
+	if len(runners) == 0 {
+		runners = []Runner{NewGoRunner()}
+	}
+	t := &RunTestsTool{
+		workDir: workDir,
+		runners: make(map[string]Runner),
+		timeout: defaultTimeout,
+	}
+	for _, runner := range runners {
+		t.runners[runner.Name()] = runner
+		t.order = append(t.order, runner.Name())
+	}
+	return t
+}
+
+// SetTimeout sets the default timeout of a run.
+func (t *RunTestsTool) SetTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		t.timeout = timeout
+	}
+}
+
+// SetChangedFiles sets the source of changed files used to select the
+// affected packages. Without one, every package is tested.
+func (t *RunTestsTool) SetChangedFiles(fn ChangedFilesFunc) {
+	t.changedFiles = fn
+}
+
+// Reset forgets the failures of the last run.
+func (t *RunTestsTool) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lastRunner = ""
+	t.rerun = nil
+}
+
+// Name returns the name of the tool.
+func (t *RunTestsTool) Name() string {
+	return "run_tests"
+}
+
+// Description returns the description of the tool.
+func (t *RunTestsTool) Description() string {
+	return "Run the project's tests and get structured results: pass/fail/skip counts per package, and for each failing or skipped test its output, file:line locations and duration. By default only the packages affected by the files changed in this session are tested; use scope 'all' for everything. Use failed_only to re-run just the tests that failed last time. Prefer this over running go test through bash"
+}
+
+// InputSchema returns the JSON schema for the input.
+func (t *RunTestsTool) InputSchema() map[string]interface{} {
+	frameworks := append([]string{"auto"}, t.order...)
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"packages": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Packages to test, e.g. ['./pkg/parser/...'] (default: selected by scope)",
+			},
+			"scope": map[string]interface{}{
+				"type":        "string",
+				"enum":        []string{ScopeAffected, ScopeAll},
+				"description": "Without packages: 'affected' tests the packages affected by the files changed in this session (all packages when nothing changed), 'all' tests every package",
+				"default":     ScopeAffected,
+			},
+			"run": map[string]interface{}{
+				"type":        "string",
+				"description": "Only run tests matching this pattern (go test -run regexp)",
+			},
+			"failed_only": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Re-run only the tests and packages that failed in the previous run",
+			},
+			"include_passed": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Also list passing tests with their durations",
+			},
+			"framework": map[string]interface{}{
+				"type":        "string",
+				"enum":        frameworks,
+				"description": "Test framework (default: detected from the work directory)",
+			},
+			"timeout": map[string]interface{}{
+				"type":        "integer",
+				"description": "Timeout in seconds",
+				"default":     int(t.timeout.Seconds()),
+			},
+		},
+	}
+}
+
+// Validate checks if the input is valid.
+func (t *RunTestsTool) Validate(input map[string]interface{}) error {
+	if raw, ok := input["packages"]; ok {
+		list, ok := raw.([]interface{})
+		if !ok {
+			return fmt.Errorf("packages must be an array of strings")
+		}
+		for _, item := range list {
+			pkg, ok := item.(string)
+			if !ok || strings.TrimSpace(pkg) == "" {
+				return fmt.Errorf("packages must be non-empty strings")
+			}
+			if strings.HasPrefix(pkg, "-") {
+				return fmt.Errorf("invalid package %q: flags are not accepted", pkg)
+			}
+		}
+	}
+
+	if raw, ok := input["run"]; ok {
+		if _, ok := raw.(string); !ok {
+			return fmt.Errorf("run must be a string")
+		}
+	}
+
+	for _, key := range []string{"failed_only", "include_passed"} {
+		if raw, ok := input[key]; ok {
+			if _, ok := raw.(bool); !ok {
+				return fmt.Errorf("%s must be a boolean", key)
+			}
+		}
+	}
+
+	if raw, ok := input["scope"]; ok {
+		if scope, ok := raw.(string); !ok || (scope != ScopeAffected && scope != ScopeAll) {
+			return fmt.Errorf("scope must be '%s' or '%s'", ScopeAffected, ScopeAll)
+		}
+	}
+
+	if framework, ok := input["framework"].(string); ok && framework != "" && framework != "auto" {
+		if _, ok := t.runners[framework]; !ok {
+			return fmt.Errorf("unknown framework %q (available: %s)", framework, strings.Join(t.order, ", "))
+		}
+	}
+
+	if raw, ok := input["timeout"]; ok {
+		timeout, ok := raw.(float64)
+		if !ok || timeout <= 0 || timeout > maxTimeoutSeconds {
+			return fmt.Errorf("timeout must be between 1 and %d seconds", maxTimeoutSeconds)
+		}
+	}
+
+	return nil
+}
+
+// Execute runs the selected tests and returns the report.
+func (t *RunTestsTool) Execute(ctx context.Context, input map[string]interface{}) (string, error) {
+	if err := t.Validate(input); err != nil {
+		return Error("Invalid input", err), nil
+	}
+
+	runner, err := t.selectRunner(input)
+	if err != nil {
+		return Error("No test framework", err), nil
+	}
+
+	timeout := t.timeout
+	if val, ok := input["timeout"].(float64); ok {
+		timeout = time.Duration(val) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	report := &Report{Framework: runner.Name(), Scope: ScopeAll, Packages: []PackageResult{}}
+	requests := []Request{{Run: stringParam(input, "run")}}
+	packages, _ := input["packages"].([]interface{})
+	failedOnly, _ := input["failed_only"].(bool)
+	switch {
+	case failedOnly:
+		t.mu.Lock()
+		lastRunner, rerun := t.lastRunner, t.rerun
+		t.mu.Unlock()
+		if lastRunner != runner.Name() || len(rerun) == 0 {
+			return Error("Nothing to re-run", fmt.Errorf("no failed %s tests recorded from a previous run", runner.Name())), nil
+		}
+		requests = rerun
+		report.Scope = ScopeFailed
+	case len(packages) > 0:
+		for _, item := range packages {
+			requests[0].Packages = append(requests[0].Packages, item.(string))
+		}
+		report.Scope = ScopePackages
+	case stringParam(input, "scope") != ScopeAll:
+		if affected, changed, ok := t.affected(ctx, runner); ok {
+			report.Scope, report.ChangedFiles = ScopeAffected, changed
+			if len(affected) == 0 {
+				return Success(fmt.Sprintf("No tested packages are affected by the %d changed files; use scope 'all' to run every package", len(changed)), report), nil
+			}
+			requests[0].Packages = affected
+		}
+	}
+
+	for _, req := range requests {
+		result, err := runner.Run(ctx, t.workDir, req)
+		if err != nil {
+			return Error("Failed to run tests", err), nil
+		}
+		report.merge(result)
+		if report.TimedOut {
+			break
+		}
+	}
+
+	t.mu.Lock()
+	t.lastRunner = runner.Name()
+	t.rerun = runner.Rerun(report)
+	t.mu.Unlock()
+
+	includePassed, _ := input["include_passed"].(bool)
+	report.compact(includePassed, maxReportedTests)
+
+	if report.TimedOut {
+		return Failure(report.Summary(), report, fmt.Errorf("tests timed out after %v", timeout)), nil
+	}
+	if !report.OK() {
+		return Failure(report.Summary(), report, fmt.Errorf("tests failed")), nil
+	}
+	return Success(report.Summary(), report), nil
+}
+
+// affected returns the packages affected by the files changed in the
+// session. It reports false when the selection is unavailable (no changed
+// files known, or the runner cannot select), so every package is tested.
+func (t *RunTestsTool) affected(ctx context.Context, runner Runner) ([]string, []string, bool) {
+	selector, ok := runner.(Selector)
+	if !ok || t.changedFiles == nil {
+		return nil, nil, false
+	}
+	changed, err := t.changedFiles(ctx)
+	if err != nil || len(changed) == 0 {
+		return nil, nil, false
+	}
+	packages, err := selector.Affected(ctx, t.workDir, changed)
+	if err != nil {
+		return nil, nil, false
+	}
+	return packages, changed, true
+}
+
+// selectRunner returns the requested runner, or the first one that
+// detects its framework in the work directory.
+func (t *RunTestsTool) selectRunner(input map[string]interface{}) (Runner, error) {
+	if framework := stringParam(input, "framework"); framework != "" && framework != "auto" {
+		return t.runners[framework], nil
+	}
+	for _, name := range t.order {
+		if t.runners[name].Detect(t.workDir) {
+			return t.runners[name], nil
+		}
+	}
+	return nil, fmt.Errorf("no supported test framework detected in %s (available: %s)", t.workDir, strings.Join(t.order, ", "))
+}
+
+func stringParam(input map[string]interface{}, key string) string {
+	val, _ := input[key].(string)
+	return val
+}
@@ -0,0 +1,278 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// maxSearchResponseBytes bounds the response read from a search API.
+	maxSearchResponseBytes = 2 * 1024 * 1024
+	// maxSnippetChars bounds the snippet kept for a result.
+	maxSnippetChars = 300
+)
+
+// JSONBackend searches through an HTTP API that answers a GET request with
+// JSON results, such as SearXNG with format=json. The results are read from
+// a "results", "items", "hits" or "data" array, or from a top-level array,
+// and each needs a URL in "url", "link" or "href".
+type JSONBackend struct {
+	name       string
+	endpoint   *url.URL
+	queryParam string
+	params     map[string]string
+	headers    map[string]string
+	client     *http.Client
+}
+
+// NewJSONBackend creates a backend that sends the query to endpoint in the
+// queryParam parameter, together with params and headers.
+func NewJSONBackend(endpoint, queryParam string, params, headers map[string]string, timeout time.Duration) (*JSONBackend, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search endpoint: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("search endpoint %q must be an http or https URL", endpoint)
+	}
+	if queryParam == "" {
+		queryParam = "q"
+	}
+	return &JSONBackend{
+		name:       "json",
+		endpoint:   u,
+		queryParam: queryParam,
+		params:     params,
+		headers:    headers,
+		client:     &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// NewSearXNGBackend creates a backend for the SearXNG instance at endpoint
+// (its /search URL), asking for JSON results.
+func NewSearXNGBackend(endpoint string, params, headers map[string]string, timeout time.Duration) (*JSONBackend, error) {
+	merged := map[string]string{"format": "json"}
+	for key, value := range params {
+		merged[key] = value
+	}
+	b, err := NewJSONBackend(endpoint, "q", merged, headers, timeout)
+	if err != nil {
+		return nil, err
+	}
+	b.name = "searxng"
+	return b, nil
+}
+
+// Name returns the name of the backend.
+func (b *JSONBackend) Name() string {
+	return b.name
+}
+
+// Search sends the query and returns at most limit results.
+func (b *JSONBackend) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	u := *b.endpoint
+	values := u.Query()
+	for key, value := range b.params {
+		values.Set(key, value)
+	}
+	values.Set(b.queryParam, query)
+	u.RawQuery = values.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+	for name, value := range b.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("search timed out: %w", err)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSearchResponseBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the search response: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("search API returned HTTP %s", resp.Status)
+	}
+	if len(body) > maxSearchResponseBytes {
+		return nil, fmt.Errorf("search response is larger than %d bytes", maxSearchResponseBytes)
+	}
+	results, err := parseResults(body)
+	if err != nil {
+		return nil, err
+	}
+	return limitResults(results, limit), nil
+}
+
+// FixtureBackend returns results from a fixed list, for tests and offline
+// use. A result matches when every word of the query appears in its title,
+// URL or snippet.
+type FixtureBackend struct {
+	results []SearchResult
+}
+
+// NewFixtureBackend creates a backend that searches results.
+func NewFixtureBackend(results []SearchResult) *FixtureBackend {
+	return &FixtureBackend{results: results}
+}
+
+// LoadFixtureBackend creates a fixture backend from a JSON file in any of
+// the formats JSONBackend reads.
+func LoadFixtureBackend(path string) (*FixtureBackend, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search fixture: %w", err)
+	}
+	results, err := parseResults(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search fixture %s: %w", path, err)
+	}
+	return NewFixtureBackend(results), nil
+}
+
+// Name returns the name of the backend.
+func (b *FixtureBackend) Name() string {
+	return "fixture"
+}
+
+// Search returns the matching results in their original order.
+func (b *FixtureBackend) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	words := strings.Fields(strings.ToLower(query))
+	var results []SearchResult
+	for _, r := range b.results {
+		text := strings.ToLower(r.Title + " " + r.URL + " " + r.Snippet)
+		matched := true
+		for _, word := range words {
+			if !strings.Contains(text, word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			results = append(results, r)
+		}
+	}
+	return limitResults(results, limit), nil
+}
+
+// parseResults reads search results from a JSON document and normalises
+// them: titles and snippets become single-line plain text, results without
+// an http or https URL are dropped, and duplicate URLs are kept once.
+func parseResults(data []byte) ([]SearchResult, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("search response is not JSON: %w", err)
+	}
+
+	var items []interface{}
+	switch v := doc.(type) {
+	case []interface{}:
+		items = v
+	case map[string]interface{}:
+		found := false
+		for _, key := range []string{"results", "items", "hits", "data"} {
+			if list, ok := v[key].([]interface{}); ok {
+				items, found = list, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("search response has no results array")
+		}
+	default:
+		return nil, fmt.Errorf("search response has no results array")
+	}
+
+	seen := make(map[string]bool)
+	results := make([]SearchResult, 0, len(items))
+	for _, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		link := firstString(fields, "url", "link", "href")
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || seen[link] {
+			continue
+		}
+		seen[link] = true
+
+		title := plainText(firstString(fields, "title", "name"))
+		if title == "" {
+			title = link
+		}
+		results = append(results, SearchResult{
+			Title:   title,
+			URL:     link,
+			Snippet: truncateRunes(plainText(firstString(fields, "content", "snippet", "description", "body")), maxSnippetChars),
+		})
+	}
+	return results, nil
+}
+
+func firstString(fields map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if s, ok := fields[key].(string); ok && strings.TrimSpace(s) != "" {
+			return strings.TrimSpace(s)
+		}
+	}
+	return ""
+}
+
+// plainText drops HTML markup, such as the <b> highlighting some search
+// APIs add, and collapses whitespace.
+func plainText(s string) string {
+	if !strings.ContainsAny(s, "<&") {
+		return strings.Join(strings.Fields(s), " ")
+	}
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(s))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return strings.Join(strings.Fields(b.String()), " ")
+		case html.TextToken:
+			b.Write(z.Text())
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			// Highlighting is inline; only line breaks separate words
+			if name, _ := z.TagName(); string(name) == "br" || string(name) == "p" || string(name) == "li" {
+				b.WriteByte(' ')
+			}
+		}
+	}
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:n])) + "…"
+}
+
+func limitResults(results []SearchResult, limit int) []SearchResult {
+	if limit > 0 && len(results) > limit {
+		return results[:limit]
+	}
+	return results
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// DomainFilter decides which hosts the web tools may contact. A domain
+// matches itself and its subdomains; "*.example.com" is the same as
+// "example.com". Blocked domains win over allowed ones, and when Allowed is
+// empty every other domain is allowed.
+type DomainFilter struct {
+	Allowed []string
+	Blocked []string
+}
+
+// Check returns an error if u may not be fetched.
+func (f DomainFilter) Check(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q: only http and https are allowed", u.Scheme)
+	}
+	host := normalizeDomain(u.Hostname())
+	if host == "" {
+		return fmt.Errorf("URL %q has no host", u.String())
+	}
+	if matchAny(host, f.Blocked) {
+		return fmt.Errorf("domain %s is blocked by tools.web.blocked_domains", host)
+	}
+	if len(f.Allowed) > 0 && !matchAny(host, f.Allowed) {
+		return fmt.Errorf("domain %s is not in tools.web.allowed_domains", host)
+	}
+	return nil
+}
+
+func matchAny(host string, domains []string) bool {
+	for _, domain := range domains {
+		domain = normalizeDomain(strings.TrimPrefix(strings.TrimSpace(domain), "*."))
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(domain), ".")
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which is not routed on
+// the internet either.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// privateAddress reports whether ip is a loopback, link-local, private or
+// other address that is not on the public internet, such as the cloud
+// metadata service at 169.254.169.254.
+func privateAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || sharedAddressSpace.Contains(ip)
+}
+
+// addressCheck returns a net.Dialer Control function that refuses to
+// connect to the addresses blocked reports. It runs after DNS resolution,
+// so neither a redirect nor a host name pointing at a private address gets
+// around it.
+func addressCheck(blocked func(netip.Addr) bool) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip, err := netip.ParseAddr(host)
+		if err != nil {
+			return fmt.Errorf("invalid address %s: %w", address, err)
+		}
+		if blocked(ip) {
+			return fmt.Errorf("%s is a private network address; set tools.web.allow_private_networks to fetch it", ip)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,434 @@
+// Package web provides tools that read from the web: web_fetch downloads a
+// page and returns it as Markdown, JSON or text, and web_search finds pages
+// through a pluggable SearchBackend.
+//
+// Requests are bounded in size, time and redirects, and only go to the
+// domains allowed by tools.web in the configuration. web_fetch does not
+// connect to loopback, link-local or private addresses unless
+// tools.web.allow_private_networks is set.
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	// defaultMaxChars is the content returned when max_chars is not given.
+	defaultMaxChars = 20000
+	// maxMaxChars is the largest accepted max_chars.
+	maxMaxChars = 100000
+	// maxCachedPages bounds the session cache; the oldest page goes first.
+	maxCachedPages = 50
+	// userAgent identifies the tool to servers.
+	userAgent = "goai-web-fetch/1.0 (+https://github.com/Zerofisher/goai)"
+)
+
+// Formats of fetched content.
+const (
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+	FormatText     = "text"
+)
+
+// Page is a fetched and converted response.
+type Page struct {
+	URL         string // after redirects
+	StatusCode  int
+	ContentType string
+	Format      string
+	Title       string
+	Content     string
+	Truncated   bool // the body was cut at the size limit
+}
+
+// FetchResult is the part of a page the tool returns.
+type FetchResult struct {
+	URL           string `json:"url"`
+	StatusCode    int    `json:"status"`
+	ContentType   string `json:"content_type"`
+	Format        string `json:"format"`
+	Title         string `json:"title,omitempty"`
+	Content       string `json:"content"`
+	Offset        int    `json:"offset,omitempty"`
+	TotalChars    int    `json:"total_chars"`
+	NextOffset    int    `json:"next_offset,omitempty"`    // offset of the rest of the content, if any
+	BodyTruncated bool   `json:"body_truncated,omitempty"` // the response was larger than the size limit
+	Cached        bool   `json:"cached,omitempty"`
+}
+
+// WebFetchTool fetches URLs and converts them for the model. Pages are
+// cached for the session, so reading a long page in parts fetches it once.
+type WebFetchTool struct {
+	maxBytes     int64
+	timeout      time.Duration
+	maxRedirects int
+	domains      DomainFilter
+	blockedIP    func(netip.Addr) bool // nil when private networks are allowed
+
+	mu    sync.Mutex
+	cache map[string]*Page
+	order []string
+}
+
+// NewWebFetchTool creates a web_fetch tool that reads at most maxBytes of
+// a response and gives up after timeout.
+func NewWebFetchTool(maxBytes int64, timeout time.Duration) *WebFetchTool {
+	return &WebFetchTool{
+		maxBytes:     maxBytes,
+		timeout:      timeout,
+		maxRedirects: 5,
+		blockedIP:    privateAddress,
+		cache:        make(map[string]*Page),
+	}
+}
+
+// SetMaxRedirects sets the number of redirects followed.
+func (t *WebFetchTool) SetMaxRedirects(n int) {
+	if n >= 0 {
+		t.maxRedirects = n
+	}
+}
+
+// SetDomains sets the domains that may and may not be fetched.
+func (t *WebFetchTool) SetDomains(filter DomainFilter) {
+	t.domains = filter
+}
+
+// SetAllowPrivateNetworks sets whether loopback, link-local and private
+// addresses may be fetched. They are refused by default.
+func (t *WebFetchTool) SetAllowPrivateNetworks(allow bool) {
+	t.blockedIP = privateAddress
+	if allow {
+		t.blockedIP = nil
+	}
+}
+
+// Reset empties the page cache.
+func (t *WebFetchTool) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cache = make(map[string]*Page)
+	t.order = nil
+}
+
+// Name returns the name of the tool.
+func (t *WebFetchTool) Name() string {
+	return "web_fetch"
+}
+
+// Description returns the description of the tool.
+func (t *WebFetchTool) Description() string {
+	return "Fetch a URL with HTTP GET and return its content for reading: HTML pages are converted to Markdown with navigation, headers and footers removed; JSON is pretty-printed; plain text is returned as is. Use it to read documentation pages, API references and raw files given as URLs. Long content is returned in parts: pass next_offset as offset to read on. Pages are cached for the session"
+}
+
+// InputSchema returns the JSON schema for the input.
+func (t *WebFetchTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"url": map[string]interface{}{
+				"type":        "string",
+				"description": "The http or https URL to fetch",
+			},
+			"offset": map[string]interface{}{
+				"type":        "integer",
+				"description": "Character offset in the converted content to start from (default: 0)",
+			},
+			"max_chars": map[string]interface{}{
+				"type":        "integer",
+				"description": fmt.Sprintf("Characters of content to return (default: %d, max: %d)", defaultMaxChars, maxMaxChars),
+			},
+			"refresh": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Fetch again instead of using the cached page",
+			},
+		},
+		"required": []string{"url"},
+	}
+}
+
+// Validate checks if the input is valid.
+func (t *WebFetchTool) Validate(input map[string]interface{}) error {
+	rawURL, ok := input["url"].(string)
+	if !ok || strings.TrimSpace(rawURL) == "" {
+		return fmt.Errorf("url is required")
+	}
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if err := t.domains.Check(u); err != nil {
+		return err
+	}
+
+	for _, key := range []string{"offset", "max_chars"} {
+		if raw, ok := input[key]; ok {
+			n, ok := raw.(float64)
+			if !ok || n < 0 || n != float64(int(n)) {
+				return fmt.Errorf("%s must be a non-negative integer", key)
+			}
+		}
+	}
+	if n := intParam(input, "max_chars"); n > maxMaxChars {
+		return fmt.Errorf("max_chars must be at most %d", maxMaxChars)
+	}
+	if raw, ok := input["refresh"]; ok {
+		if _, ok := raw.(bool); !ok {
+			return fmt.Errorf("refresh must be a boolean")
+		}
+	}
+	return nil
+}
+
+// Execute fetches the URL, or takes it from the cache, and returns the
+// requested part of its content.
+func (t *WebFetchTool) Execute(ctx context.Context, input map[string]interface{}) (string, error) {
+	if err := t.Validate(input); err != nil {
+		return Error("Invalid input", err), nil
+	}
+	rawURL := strings.TrimSpace(input["url"].(string))
+	refresh, _ := input["refresh"].(bool)
+
+	page, cached := t.cached(rawURL)
+	if page == nil || refresh {
+		var err error
+		page, err = t.Fetch(ctx, rawURL)
+		if err != nil {
+			return Error("Failed to fetch "+rawURL, err), nil
+		}
+		t.store(rawURL, page)
+		cached = false
+	}
+
+	maxChars := intParam(input, "max_chars")
+	if maxChars == 0 {
+		maxChars = defaultMaxChars
+	}
+	result := slice(page, intParam(input, "offset"), maxChars)
+	result.Cached = cached
+	return Success(fetchSummary(result), result), nil
+}
+
+// Fetch downloads rawURL and converts its content.
+func (t *WebFetchTool) Fetch(ctx context.Context, rawURL string) (*Page, error) {
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html, application/xhtml+xml, text/markdown;q=0.9, text/plain;q=0.9, application/json;q=0.9, */*;q=0.5")
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if t.blockedIP != nil {
+		dialer.Control = addressCheck(t.blockedIP)
+		// A proxy would connect to the target out of reach of the check
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+	defer transport.CloseIdleConnections()
+
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > t.maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", t.maxRedirects)
+			}
+			return t.domains.Check(req.URL)
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("timed out after %s", t.timeout)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("HTTP %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, t.maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the response: %w", err)
+	}
+	page := &Page{
+		URL:         resp.Request.URL.String(),
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	if int64(len(body)) > t.maxBytes {
+		body, page.Truncated = body[:t.maxBytes], true
+	}
+	if page.ContentType == "" {
+		page.ContentType = http.DetectContentType(body)
+	}
+	if err := convert(page, body, resp.Request.URL); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// convert sets the format and content of page from body.
+func convert(page *Page, body []byte, base *url.URL) error {
+	mediaType, _, err := mime.ParseMediaType(page.ContentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(page.ContentType, ";")[0]))
+	}
+
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		reader, err := charset.NewReader(bytes.NewReader(body), page.ContentType)
+		if err != nil {
+			reader = bytes.NewReader(body)
+		}
+		doc, err := html.Parse(reader)
+		if err != nil {
+			return fmt.Errorf("failed to parse HTML: %w", err)
+		}
+		page.Format = FormatMarkdown
+		page.Title, page.Content = ConvertHTML(doc, base)
+
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		page.Format = FormatJSON
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, body, "", "  "); err == nil {
+			page.Content = pretty.String()
+		} else {
+			// Cut at the size limit, or not JSON after all
+			page.Content = string(body)
+		}
+
+	case isText(mediaType):
+		page.Format = FormatText
+		if mediaType == "text/markdown" {
+			page.Format = FormatMarkdown
+		}
+		text, err := decodeText(body, page.ContentType)
+		if err != nil {
+			return err
+		}
+		page.Content = text
+
+	default:
+		return fmt.Errorf("unsupported content type %s; only HTML, JSON and text can be read", mediaType)
+	}
+	return nil
+}
+
+// isText reports whether mediaType is readable text.
+func isText(mediaType string) bool {
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	switch mediaType {
+	case "application/xml", "application/javascript", "application/x-yaml", "application/yaml", "application/toml":
+		return true
+	}
+	return false
+}
+
+// decodeText converts body to UTF-8 using the charset of contentType.
+func decodeText(body []byte, contentType string) (string, error) {
+	reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return string(body), nil
+	}
+	text, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode the response: %w", err)
+	}
+	return string(text), nil
+}
+
+// slice returns maxChars characters of the page's content from offset.
+func slice(page *Page, offset, maxChars int) *FetchResult {
+	content := []rune(page.Content)
+	offset = min(offset, len(content))
+	end := min(offset+maxChars, len(content))
+	result := &FetchResult{
+		URL:           page.URL,
+		StatusCode:    page.StatusCode,
+		ContentType:   page.ContentType,
+		Format:        page.Format,
+		Title:         page.Title,
+		Content:       string(content[offset:end]),
+		Offset:        offset,
+		TotalChars:    len(content),
+		BodyTruncated: page.Truncated,
+	}
+	if end < len(content) {
+		result.NextOffset = end
+	}
+	return result
+}
+
+func fetchSummary(r *FetchResult) string {
+	summary := fmt.Sprintf("Fetched %s (%s", r.URL, r.Format)
+	if r.Title != "" {
+		summary = fmt.Sprintf("Fetched %q from %s (%s", r.Title, r.URL, r.Format)
+	}
+	if r.Offset > 0 || r.NextOffset > 0 {
+		summary += fmt.Sprintf(", characters %d-%d of %d", r.Offset, r.Offset+len([]rune(r.Content)), r.TotalChars)
+	} else {
+		summary += fmt.Sprintf(", %d characters", r.TotalChars)
+	}
+	if r.Cached {
+		summary += ", cached"
+	}
+	summary += ")"
+	if r.NextOffset > 0 {
+		summary += fmt.Sprintf("; continue with offset %d", r.NextOffset)
+	}
+	return summary
+}
+
+// cached returns the cached page of rawURL.
+func (t *WebFetchTool) cached(rawURL string) (*Page, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	page, ok := t.cache[rawURL]
+	return page, ok
+}
+
+// store caches page, dropping the oldest page when the cache is full.
+func (t *WebFetchTool) store(rawURL string, page *Page) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.cache[rawURL]; !ok {
+		t.order = append(t.order, rawURL)
+	}
+	t.cache[rawURL] = page
+	for len(t.order) > maxCachedPages {
+		delete(t.cache, t.order[0])
+		t.order = t.order[1:]
+	}
+}
+
+func intParam(input map[string]interface{}, key string) int {
+	val, _ := input[key].(float64)
+	return int(val)
+}
@@ -0,0 +1,436 @@
+package web
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// indent marks indentation added by lists while converting, so cleaning up
+// the whitespace of text does not remove it. It becomes a space at the end.
+const indent = "\x00"
+
+// skippedElements are never part of the content.
+var skippedElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Template: true, atom.Iframe: true, atom.Svg: true, atom.Canvas: true,
+	atom.Form: true, atom.Button: true, atom.Select: true, atom.Input: true,
+	atom.Nav: true, atom.Aside: true,
+}
+
+// skippedRoles are ARIA roles of navigation and page chrome.
+var skippedRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true, "complementary": true,
+	"search": true, "menu": true, "menubar": true, "dialog": true,
+}
+
+// skippedClasses are class and id words of navigation and page chrome.
+var skippedClasses = map[string]bool{
+	"nav": true, "navbar": true, "navigation": true, "menu": true, "sidebar": true,
+	"breadcrumb": true, "breadcrumbs": true, "footer": true, "header": true,
+	"cookie-banner": true, "skip-link": true,
+}
+
+var (
+	spaceRun      = regexp.MustCompile(`[ \t\r\n\f]+`)
+	codeLanguage  = regexp.MustCompile(`(?:^|\s)(?:language|lang)-([\w+#-]+)`)
+	headingLevels = map[atom.Atom]int{atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6}
+)
+
+// ConvertHTML returns the title of an HTML document and its main content
+// as Markdown. The main content is the <main> element, or the first
+// <article>, or the body; navigation, headers, footers, sidebars, forms
+// and scripts are dropped. Relative links are resolved against base.
+func ConvertHTML(doc *html.Node, base *url.URL) (title, markdown string) {
+	if t := find(doc, func(n *html.Node) bool { return n.DataAtom == atom.Title }); t != nil {
+		title = strings.TrimSpace(spaceRun.ReplaceAllString(textContent(t), " "))
+	}
+
+	root := find(doc, func(n *html.Node) bool { return n.DataAtom == atom.Main || attr(n, "role") == "main" })
+	if root == nil {
+		root = find(doc, func(n *html.Node) bool { return n.DataAtom == atom.Article })
+	}
+	if root == nil {
+		root = find(doc, func(n *html.Node) bool { return n.DataAtom == atom.Body })
+	}
+	if root == nil {
+		root = doc
+	}
+
+	c := &converter{base: base}
+	return title, strings.ReplaceAll(clean(c.children(root)), indent, " ")
+}
+
+// converter renders HTML nodes as Markdown. Block elements are separated
+// by blank lines, which clean collapses.
+type converter struct {
+	base *url.URL
+}
+
+func (c *converter) children(n *html.Node) string {
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		sb.WriteString(c.node(child))
+	}
+	return sb.String()
+}
+
+func (c *converter) node(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return spaceRun.ReplaceAllString(n.Data, " ")
+	case html.ElementNode:
+	default:
+		return ""
+	}
+	if skipped(n) {
+		return ""
+	}
+
+	if level, ok := headingLevels[n.DataAtom]; ok {
+		text := strings.TrimSpace(c.inline(n))
+		if text == "" {
+			return ""
+		}
+		return "\n\n" + strings.Repeat("#", level) + " " + text + "\n\n"
+	}
+
+	switch n.DataAtom {
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Figure,
+		atom.Figcaption, atom.Details, atom.Summary, atom.Address, atom.Center:
+		return "\n\n" + c.children(n) + "\n\n"
+	case atom.Br:
+		return "\n"
+	case atom.Hr:
+		return "\n\n---\n\n"
+	case atom.Pre:
+		return c.pre(n)
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		text := textContent(n)
+		if strings.TrimSpace(text) == "" {
+			return text
+		}
+		return wrap(spaceRun.ReplaceAllString(text, " "), "`")
+	case atom.Strong, atom.B:
+		return wrap(c.children(n), "**")
+	case atom.Em, atom.I, atom.Cite:
+		return wrap(c.children(n), "*")
+	case atom.Del, atom.S, atom.Strike:
+		return wrap(c.children(n), "~~")
+	case atom.A:
+		return c.link(n)
+	case atom.Img:
+		alt := strings.TrimSpace(attr(n, "alt"))
+		src := c.resolve(attr(n, "src"))
+		if alt == "" || src == "" {
+			return ""
+		}
+		return fmt.Sprintf("![%s](%s)", alt, src)
+	case atom.Ul, atom.Ol:
+		return c.list(n)
+	case atom.Li:
+		// Outside a list
+		return "\n\n- " + strings.TrimSpace(c.children(n)) + "\n\n"
+	case atom.Blockquote:
+		lines := strings.Split(clean(c.children(n)), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return "\n\n" + strings.Join(lines, "\n") + "\n\n"
+	case atom.Table:
+		return c.table(n)
+	case atom.Dt:
+		return "\n\n" + wrap(c.inline(n), "**") + "\n"
+	case atom.Dd:
+		return c.children(n) + "\n\n"
+	}
+	return c.children(n)
+}
+
+// inline renders the content of n on one line.
+func (c *converter) inline(n *html.Node) string {
+	return strings.TrimSpace(spaceRun.ReplaceAllString(strings.ReplaceAll(c.children(n), indent, " "), " "))
+}
+
+func (c *converter) link(n *html.Node) string {
+	content := c.children(n)
+	text := strings.TrimSpace(spaceRun.ReplaceAllString(content, " "))
+	href := c.resolve(attr(n, "href"))
+	if text == "" || href == "" || strings.HasPrefix(href, "#") {
+		return content
+	}
+	return leading(content) + "[" + text + "](" + href + ")" + trailing(content)
+}
+
+func (c *converter) pre(n *html.Node) string {
+	language := ""
+	for _, node := range []*html.Node{n, n.FirstChild} {
+		if node == nil || node.Type != html.ElementNode {
+			continue
+		}
+		if m := codeLanguage.FindStringSubmatch(attr(node, "class")); m != nil {
+			language = m[1]
+			break
+		}
+	}
+	text := strings.Trim(textContent(n), "\n")
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return "\n\n" + fence + language + "\n" + text + "\n" + fence + "\n\n"
+}
+
+// list renders ul and ol with nested content indented under each item.
+func (c *converter) list(n *html.Node) string {
+	ordered := n.DataAtom == atom.Ol
+	number := 1
+	if ordered {
+		fmt.Sscanf(attr(n, "start"), "%d", &number)
+	}
+
+	var items []string
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || child.DataAtom != atom.Li {
+			if child.Type == html.ElementNode && !skipped(child) {
+				// Stray content such as a nested list directly in the list
+				if text := tight(clean(c.node(child))); text != "" {
+					items = append(items, indentLines(text, indent+indent, indent+indent))
+				}
+			}
+			continue
+		}
+		marker := "- "
+		if ordered {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+		content := tight(clean(c.children(child)))
+		items = append(items, indentLines(content, marker, strings.Repeat(indent, len(marker))))
+	}
+	if len(items) == 0 {
+		return ""
+	}
+	return "\n\n" + strings.Join(items, "\n") + "\n\n"
+}
+
+// table renders a table as a Markdown table with the first row as header.
+func (c *converter) table(n *html.Node) string {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode || skipped(child) {
+				continue
+			}
+			switch child.DataAtom {
+			case atom.Tr:
+				var row []string
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+						row = append(row, strings.ReplaceAll(c.inline(cell), "|", `\|`))
+					}
+				}
+				if len(row) > 0 {
+					rows = append(rows, row)
+				}
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				walk(child)
+			}
+		}
+	}
+	walk(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\n")
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		sb.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			sb.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+		}
+	}
+	return sb.String() + "\n"
+}
+
+// resolve returns ref as an absolute URL, or "" for script links.
+func (c *converter) resolve(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(strings.ToLower(ref), "javascript:") {
+		return ""
+	}
+	if strings.HasPrefix(ref, "#") || c.base == nil {
+		return ref
+	}
+	u, err := c.base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+// skipped reports whether n is navigation, page chrome or hidden.
+func skipped(n *html.Node) bool {
+	if skippedElements[n.DataAtom] || skippedRoles[attr(n, "role")] || attr(n, "aria-hidden") == "true" {
+		return true
+	}
+	// Page headers and footers, but not those of an article
+	if (n.DataAtom == atom.Header || n.DataAtom == atom.Footer) && !inside(n, atom.Article) {
+		return true
+	}
+	for _, a := range n.Attr {
+		if a.Key == "hidden" {
+			return true
+		}
+	}
+	for _, word := range strings.Fields(attr(n, "class") + " " + attr(n, "id")) {
+		if skippedClasses[strings.ToLower(word)] {
+			return true
+		}
+	}
+	return false
+}
+
+// clean trims the lines of converted Markdown and collapses blank lines,
+// leaving code blocks as they are.
+func clean(s string) string {
+	var lines []string
+	inFence, blank := false, false
+	for _, line := range strings.Split(s, "\n") {
+		content := strings.TrimLeft(line, indent)
+		prefix := line[:len(line)-len(content)]
+		if !inFence {
+			content = strings.TrimSpace(content)
+		}
+		if strings.HasPrefix(strings.TrimSpace(content), "```") {
+			inFence = !inFence
+			content = strings.TrimSpace(content)
+		}
+		if content == "" && !inFence {
+			blank = true
+			continue
+		}
+		if blank && len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		blank = false
+		lines = append(lines, prefix+content)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// tight removes the blank lines outside code blocks, for list items.
+func tight(s string) string {
+	var lines []string
+	inFence := false
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(strings.TrimLeft(line, indent), "```") {
+			inFence = !inFence
+		}
+		if line == "" && !inFence {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// indentLines prefixes the first line of s with first and the others with
+// rest.
+func indentLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if i == 0 {
+			lines[i] = first + line
+		} else if line != "" {
+			lines[i] = rest + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wrap surrounds the trimmed s with mark, keeping the surrounding spaces
+// outside it.
+func wrap(s, mark string) string {
+	text := strings.TrimSpace(s)
+	if text == "" {
+		return s
+	}
+	return leading(s) + mark + text + mark + trailing(s)
+}
+
+func leading(s string) string {
+	if strings.TrimLeft(s, " \n") != s {
+		return " "
+	}
+	return ""
+}
+
+func trailing(s string) string {
+	if strings.TrimRight(s, " \n") != s {
+		return " "
+	}
+	return ""
+}
+
+// textContent returns the text of n and its descendants as is.
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.DataAtom == atom.Br {
+			sb.WriteString("\n")
+			continue
+		}
+		sb.WriteString(textContent(child))
+	}
+	return sb.String()
+}
+
+// find returns the first node below n, in document order, for which match
+// is true.
+func find(n *html.Node, match func(*html.Node) bool) *html.Node {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && match(child) {
+			return child
+		}
+		if found := find(child, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// inside reports whether n has an ancestor element a.
+func inside(n *html.Node, a atom.Atom) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.DataAtom == a {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,194 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// maxSearchResults is the largest accepted max_results.
+	maxSearchResults = 20
+	// searchOverfetch is how many times the requested number of results is
+	// asked from the backend when domain filters may drop some of them.
+	searchOverfetch = 3
+)
+
+// SearchResult is a search hit normalised across backends.
+type SearchResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// SearchBackend finds web pages for a query.
+type SearchBackend interface {
+	// Name identifies the backend in results.
+	Name() string
+	// Search returns at most limit results, best first.
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
+}
+
+// SearchResults is the result of the web_search tool.
+type SearchResults struct {
+	Query    string         `json:"query"`
+	Backend  string         `json:"backend"`
+	Results  []SearchResult `json:"results"`
+	Filtered int            `json:"filtered,omitempty"` // results dropped by the domain filters
+}
+
+// WebSearchTool searches the web through a SearchBackend.
+type WebSearchTool struct {
+	backend    SearchBackend
+	maxResults int
+	domains    DomainFilter
+}
+
+// NewWebSearchTool creates a web_search tool that returns maxResults
+// results unless the model asks for another number.
+func NewWebSearchTool(backend SearchBackend, maxResults int) *WebSearchTool {
+	if maxResults <= 0 || maxResults > maxSearchResults {
+		maxResults = 5
+	}
+	return &WebSearchTool{backend: backend, maxResults: maxResults}
+}
+
+// SetDomains sets the domains whose results may and may not be returned.
+func (t *WebSearchTool) SetDomains(filter DomainFilter) {
+	t.domains = filter
+}
+
+// Name returns the name of the tool.
+func (t *WebSearchTool) Name() string {
+	return "web_search"
+}
+
+// Description returns the description of the tool.
+func (t *WebSearchTool) Description() string {
+	return "Search the web and return the title, URL and snippet of each result. Use it to find documentation, release notes or discussions of an error, then read the relevant pages with web_fetch. Results can be limited to or exclude domains and their subdomains"
+}
+
+// InputSchema returns the JSON schema for the input.
+func (t *WebSearchTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"query": map[string]interface{}{
+				"type":        "string",
+				"description": "The search query",
+			},
+			"max_results": map[string]interface{}{
+				"type":        "integer",
+				"description": fmt.Sprintf("Number of results to return (default: %d, max: %d)", t.maxResults, maxSearchResults),
+			},
+			"allowed_domains": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Only return results from these domains, e.g. [\"go.dev\"]",
+			},
+			"blocked_domains": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Never return results from these domains",
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+// Validate checks if the input is valid.
+func (t *WebSearchTool) Validate(input map[string]interface{}) error {
+	query, ok := input["query"].(string)
+	if !ok || strings.TrimSpace(query) == "" {
+		return fmt.Errorf("query is required")
+	}
+	if raw, ok := input["max_results"]; ok {
+		n, ok := raw.(float64)
+		if !ok || n < 1 || n > maxSearchResults || n != float64(int(n)) {
+			return fmt.Errorf("max_results must be an integer between 1 and %d", maxSearchResults)
+		}
+	}
+	for _, key := range []string{"allowed_domains", "blocked_domains"} {
+		if _, err := stringList(input, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Execute runs the search and drops results from domains that are not
+// allowed by the configuration or the input.
+func (t *WebSearchTool) Execute(ctx context.Context, input map[string]interface{}) (string, error) {
+	if err := t.Validate(input); err != nil {
+		return Error("Invalid input", err), nil
+	}
+	query := strings.TrimSpace(input["query"].(string))
+	limit := intParam(input, "max_results")
+	if limit == 0 {
+		limit = t.maxResults
+	}
+	allowed, _ := stringList(input, "allowed_domains")
+	blocked, _ := stringList(input, "blocked_domains")
+	requested := DomainFilter{Allowed: allowed, Blocked: blocked}
+
+	fetch := limit
+	if len(t.domains.Allowed)+len(t.domains.Blocked)+len(allowed)+len(blocked) > 0 {
+		fetch = min(limit*searchOverfetch, maxSearchResults*searchOverfetch)
+	}
+	found, err := t.backend.Search(ctx, query, fetch)
+	if err != nil {
+		return Error("Search failed", err), nil
+	}
+
+	result := &SearchResults{Query: query, Backend: t.backend.Name(), Results: []SearchResult{}}
+	for _, r := range found {
+		u, err := url.Parse(r.URL)
+		if err != nil || t.domains.Check(u) != nil || requested.Check(u) != nil {
+			result.Filtered++
+			continue
+		}
+		if len(result.Results) < limit {
+			result.Results = append(result.Results, r)
+		}
+	}
+	return Success(searchSummary(result), result), nil
+}
+
+// searchSummary describes the results in one line.
+func searchSummary(r *SearchResults) string {
+	var summary string
+	switch len(r.Results) {
+	case 0:
+		summary = fmt.Sprintf("No results for %q", r.Query)
+	case 1:
+		summary = fmt.Sprintf("Found 1 result for %q", r.Query)
+	default:
+		summary = fmt.Sprintf("Found %d results for %q", len(r.Results), r.Query)
+	}
+	if r.Filtered > 0 {
+		summary += fmt.Sprintf(" (%d filtered by domain)", r.Filtered)
+	}
+	return summary
+}
+
+// stringList returns the string array input[key], which may be absent.
+func stringList(input map[string]interface{}, key string) ([]string, error) {
+	raw, ok := input[key]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array of strings", key)
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be an array of strings", key)
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
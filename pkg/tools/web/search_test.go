@@ -0,0 +1,225 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const searxngResponse = `{"query":"go generics","results":[
+{"title":"Tutorial: Getting started with <b>generics</b>","url":"https://go.dev/doc/tutorial/generics","content":"This tutorial introduces the basics of\n  <b>generics</b> in Go."},
+{"title":"","url":"https://pkg.go.dev/golang.org/x/exp/constraints","content":"Package constraints"},
+{"title":"Duplicate","url":"https://go.dev/doc/tutorial/generics"},
+{"title":"No URL","content":"dropped"},
+{"title":"FTP","url":"ftp://example.com/file"},
+{"title":"Generics in Go - Stack Overflow","url":"https://stackoverflow.com/questions/1","content":"Q&amp;A about generics"}
+]}`
+
+// executeSearch runs the tool and decodes its response.
+func executeSearch(t *testing.T, tool *WebSearchTool, input map[string]interface{}) (ToolResponse, *SearchResults) {
+	t.Helper()
+	out, err := tool.Execute(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	var resp struct {
+		ToolResponse
+		Data *SearchResults `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatalf("invalid JSON response %q: %v", out, err)
+	}
+	return resp.ToolResponse, resp.Data
+}
+
+// TestParseResults tests normalising the result formats of search APIs
+func TestParseResults(t *testing.T) {
+	results, err := parseResults([]byte(searxngResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SearchResult{
+		{Title: "Tutorial: Getting started with generics", URL: "https://go.dev/doc/tutorial/generics", Snippet: "This tutorial introduces the basics of generics in Go."},
+		{Title: "https://pkg.go.dev/golang.org/x/exp/constraints", URL: "https://pkg.go.dev/golang.org/x/exp/constraints", Snippet: "Package constraints"},
+		{Title: "Generics in Go - Stack Overflow", URL: "https://stackoverflow.com/questions/1", Snippet: "Q&A about generics"},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(results), len(want), results)
+	}
+	for i := range want {
+		if results[i] != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, results[i], want[i])
+		}
+	}
+
+	// Other shapes of the same data
+	for _, doc := range []string{
+		`[{"name":"A","link":"https://a.example/","snippet":"x"}]`,
+		`{"items":[{"title":"A","link":"https://a.example/","description":"x"}]}`,
+		`{"hits":[{"title":"A","href":"https://a.example/","body":"x"}]}`,
+	} {
+		results, err := parseResults([]byte(doc))
+		if err != nil || len(results) != 1 || results[0].URL != "https://a.example/" || results[0].Snippet != "x" {
+			t.Errorf("parseResults(%s) = %+v, %v", doc, results, err)
+		}
+	}
+	for _, doc := range []string{`not json`, `{"answer":42}`, `"text"`} {
+		if _, err := parseResults([]byte(doc)); err == nil {
+			t.Errorf("parseResults(%s) succeeded", doc)
+		}
+	}
+}
+
+// TestSearXNGBackend tests searching through a SearXNG-style JSON API
+func TestSearXNGBackend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("q") != "go generics" || q.Get("format") != "json" || q.Get("language") != "en" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("missing header, got %q", r.Header.Get("Authorization"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(searxngResponse))
+	}))
+	defer server.Close()
+
+	backend, err := NewSearXNGBackend(server.URL+"/search", map[string]string{"language": "en"},
+		map[string]string{"Authorization": "Bearer secret"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := backend.Search(context.Background(), "go generics", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend.Name() != "searxng" || len(results) != 2 || results[0].URL != "https://go.dev/doc/tutorial/generics" {
+		t.Errorf("results = %+v", results)
+	}
+
+	missing, _ := NewJSONBackend(server.URL+"/missing", "query", nil, nil, time.Second)
+	if _, err := missing.Search(context.Background(), "x", 5); err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Errorf("Search on a missing endpoint = %v", err)
+	}
+	if _, err := NewJSONBackend("file:///search", "q", nil, nil, time.Second); err == nil {
+		t.Error("NewJSONBackend accepted a file URL")
+	}
+}
+
+// TestFixtureBackend tests loading and matching fixture results
+func TestFixtureBackend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+	if err := os.WriteFile(path, []byte(searxngResponse), 0644); err != nil {
+		t.Fatal(err)
+	}
+	backend, err := LoadFixtureBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, _ := backend.Search(context.Background(), "GENERICS go.dev", 10)
+	if len(results) != 1 || results[0].URL != "https://go.dev/doc/tutorial/generics" {
+		t.Errorf("results = %+v", results)
+	}
+	results, _ = backend.Search(context.Background(), "generics", 1)
+	if len(results) != 1 {
+		t.Errorf("limit ignored: %+v", results)
+	}
+	if _, err := LoadFixtureBackend(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadFixtureBackend succeeded for a missing file")
+	}
+}
+
+// TestWebSearchTool tests result counts and domain filtering
+func TestWebSearchTool(t *testing.T) {
+	var results []SearchResult
+	for _, u := range []string{
+		"https://go.dev/a", "https://stackoverflow.com/b", "https://pkg.go.dev/c",
+		"https://spam.example/d", "https://go.dev/e", "https://github.com/f",
+	} {
+		results = append(results, SearchResult{Title: "Result " + u, URL: u, Snippet: "about go"})
+	}
+	tool := NewWebSearchTool(NewFixtureBackend(results), 3)
+
+	resp, found := executeSearch(t, tool, map[string]interface{}{"query": "go"})
+	if !resp.Ok || found.Backend != "fixture" || len(found.Results) != 3 || found.Results[2].URL != "https://pkg.go.dev/c" {
+		t.Fatalf("unexpected response: %+v %+v", resp, found)
+	}
+	if resp.Summary != `Found 3 results for "go"` {
+		t.Errorf("summary = %q", resp.Summary)
+	}
+
+	_, found = executeSearch(t, tool, map[string]interface{}{"query": "go", "max_results": 10.0})
+	if len(found.Results) != 6 {
+		t.Errorf("max_results 10 returned %d results", len(found.Results))
+	}
+
+	tool.SetDomains(DomainFilter{Blocked: []string{"spam.example"}})
+	resp, found = executeSearch(t, tool, map[string]interface{}{
+		"query":           "go",
+		"max_results":     10.0,
+		"allowed_domains": []interface{}{"go.dev", "spam.example"},
+	})
+	var urls []string
+	for _, r := range found.Results {
+		urls = append(urls, r.URL)
+	}
+	if strings.Join(urls, " ") != "https://go.dev/a https://pkg.go.dev/c https://go.dev/e" || found.Filtered != 3 {
+		t.Errorf("filtered results = %v, %d filtered", urls, found.Filtered)
+	}
+	if !strings.Contains(resp.Summary, "(3 filtered by domain)") {
+		t.Errorf("summary = %q", resp.Summary)
+	}
+
+	resp, found = executeSearch(t, tool, map[string]interface{}{"query": "go", "blocked_domains": []interface{}{"go.dev", "stackoverflow.com", "github.com"}})
+	if len(found.Results) != 0 || resp.Summary != `No results for "go" (6 filtered by domain)` {
+		t.Errorf("unexpected response: %+v %+v", resp, found)
+	}
+}
+
+// TestWebSearchToolValidate tests input validation
+func TestWebSearchToolValidate(t *testing.T) {
+	tool := NewWebSearchTool(NewFixtureBackend(nil), 5)
+	tests := []struct {
+		name    string
+		input   map[string]interface{}
+		wantErr string
+	}{
+		{"valid", map[string]interface{}{"query": "go", "max_results": 3.0, "allowed_domains": []interface{}{"go.dev"}}, ""},
+		{"missing query", map[string]interface{}{}, "query is required"},
+		{"blank query", map[string]interface{}{"query": "  "}, "query is required"},
+		{"zero results", map[string]interface{}{"query": "go", "max_results": 0.0}, "between 1 and 20"},
+		{"too many results", map[string]interface{}{"query": "go", "max_results": 50.0}, "between 1 and 20"},
+		{"domains not array", map[string]interface{}{"query": "go", "blocked_domains": "go.dev"}, "array of strings"},
+		{"domain not string", map[string]interface{}{"query": "go", "allowed_domains": []interface{}{1.0}}, "array of strings"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tool.Validate(tt.input)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	resp, _ := executeSearch(t, tool, map[string]interface{}{})
+	if resp.Ok || !strings.Contains(resp.Error, "query is required") {
+		t.Errorf("Execute without query = %+v", resp)
+	}
+}
@@ -0,0 +1,342 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const testPage = `<!DOCTYPE html><html><head><title> Widget API </title><script>var x=1</script></head>
+<body>
+<header class="site"><a href="/">Home</a> <nav><a href="/docs">Docs</a></nav></header>
+<div class="sidebar"><ul><li><a href="/a">A</a></li></ul></div>
+<main>
+  <article>
+  <header><h1>Widgets   <small>v2</small></h1></header>
+  <p>Create a <strong>widget</strong> with
+     <code>New()</code>. See <a href="guide.html#setup">the guide</a>.</p>
+  <pre><code class="language-go">w := widget.New()
+if err != nil {
+	return err
+}</code></pre>
+  <ul>
+    <li>First
+      <ul><li>Nested <em>one</em></li><li>Nested two</li></ul>
+    </li>
+    <li><p>Second</p></li>
+  </ul>
+  <ol start="3"><li>Three</li><li>Four</li></ol>
+  <blockquote><p>Quoted</p><p>Text</p></blockquote>
+  <table><thead><tr><th>Name</th><th>Type</th></tr></thead>
+  <tbody><tr><td>id</td><td>int | nil</td></tr><tr><td>name</td></tr></tbody></table>
+  <img src="/img/w.png" alt="A widget"><img src="spacer.gif">
+  <dl><dt>Term</dt><dd>Definition</dd></dl>
+  </article>
+</main>
+<footer>Copyright</footer>
+</body></html>`
+
+const testMarkdown = "# Widgets v2\n\n" +
+	"Create a **widget** with `New()`. See [the guide](https://example.com/docs/widgets/guide.html#setup).\n\n" +
+	"```go\nw := widget.New()\nif err != nil {\n\treturn err\n}\n```\n\n" +
+	"- First\n  - Nested *one*\n  - Nested two\n- Second\n\n" +
+	"3. Three\n4. Four\n\n" +
+	"> Quoted\n>\n> Text\n\n" +
+	"| Name | Type |\n| --- | --- |\n| id | int \\| nil |\n| name |  |\n\n" +
+	"![A widget](https://example.com/img/w.png)\n\n" +
+	"**Term**\nDefinition"
+
+// execute runs the tool and decodes its response.
+func execute(t *testing.T, tool *WebFetchTool, input map[string]interface{}) (ToolResponse, *FetchResult) {
+	t.Helper()
+	out, err := tool.Execute(context.Background(), input)
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	var resp struct {
+		ToolResponse
+		Data *FetchResult `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatalf("invalid JSON response %q: %v", out, err)
+	}
+	return resp.ToolResponse, resp.Data
+}
+
+// TestConvertHTML tests converting the main content of a page to Markdown
+func TestConvertHTML(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(testPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse("https://example.com/docs/widgets/")
+	title, markdown := ConvertHTML(doc, base)
+	if title != "Widget API" {
+		t.Errorf("title = %q", title)
+	}
+	if markdown != testMarkdown {
+		t.Errorf("markdown =\n%s\nwant\n%s", markdown, testMarkdown)
+	}
+}
+
+// TestConvertHTMLBody tests falling back to the body without page chrome
+func TestConvertHTMLBody(t *testing.T) {
+	doc, _ := html.Parse(strings.NewReader(`<body><div id="nav">Menu</div><div role="navigation">Links</div>
+<p>Intro <a href="javascript:void(0)">click</a></p><div hidden>Secret</div><footer>Bye</footer></body>`))
+	_, markdown := ConvertHTML(doc, nil)
+	if markdown != "Intro click" {
+		t.Errorf("markdown = %q", markdown)
+	}
+}
+
+// TestDomainFilter tests allowed and blocked domains
+func TestDomainFilter(t *testing.T) {
+	filter := DomainFilter{Allowed: []string{"go.dev", "*.github.com"}, Blocked: []string{"gist.github.com"}}
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://go.dev/doc/", true},
+		{"https://pkg.go.dev/net/http", true},
+		{"https://GO.DEV./", true},
+		{"https://api.github.com/repos", true},
+		{"https://github.com/", true},
+		{"https://gist.github.com/x", false},
+		{"https://notgo.dev/", false},
+		{"https://example.com/", false},
+		{"ftp://go.dev/file", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if err := filter.Check(u); (err == nil) != tt.allowed {
+			t.Errorf("Check(%s) = %v, want allowed %v", tt.url, err, tt.allowed)
+		}
+	}
+	if err := (DomainFilter{}).Check(&url.URL{Scheme: "https", Host: "anything.example"}); err != nil {
+		t.Errorf("empty filter refused a domain: %v", err)
+	}
+}
+
+// TestWebFetchToolValidate tests input validation
+func TestWebFetchToolValidate(t *testing.T) {
+	tool := NewWebFetchTool(1024, time.Second)
+	tool.SetDomains(DomainFilter{Blocked: []string{"blocked.example"}})
+
+	tests := []struct {
+		name    string
+		input   map[string]interface{}
+		wantErr string
+	}{
+		{"valid", map[string]interface{}{"url": "https://go.dev/doc", "offset": 10.0}, ""},
+		{"missing url", map[string]interface{}{}, "url is required"},
+		{"file scheme", map[string]interface{}{"url": "file:///etc/passwd"}, "only http and https"},
+		{"blocked domain", map[string]interface{}{"url": "https://docs.blocked.example/"}, "blocked"},
+		{"negative offset", map[string]interface{}{"url": "https://go.dev", "offset": -1.0}, "non-negative integer"},
+		{"large max_chars", map[string]interface{}{"url": "https://go.dev", "max_chars": 1e6}, "at most"},
+		{"refresh not bool", map[string]interface{}{"url": "https://go.dev", "refresh": "yes"}, "boolean"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tool.Validate(tt.input)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestWebFetchTool tests fetching HTML, JSON and text with limits and the cache
+func TestWebFetchTool(t *testing.T) {
+	var docHits atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/docs/widgets/", func(w http.ResponseWriter, r *http.Request) {
+		docHits.Add(1)
+		if r.Header.Get("User-Agent") == "" {
+			t.Error("request without User-Agent")
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(testPage))
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"widget","tags":["a","b"]}`))
+	})
+	mux.HandleFunc("/latin1.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=iso-8859-1")
+		w.Write([]byte("caf\xe9"))
+	})
+	mux.HandleFunc("/big.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strings.Repeat("x", 5000)))
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG"))
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/docs/widgets/", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/away", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://blocked.example/", http.StatusFound)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tool := NewWebFetchTool(4096, 500*time.Millisecond)
+	tool.SetMaxRedirects(3)
+	tool.SetDomains(DomainFilter{Blocked: []string{"blocked.example"}})
+	tool.SetAllowPrivateNetworks(true)
+
+	resp, page := execute(t, tool, map[string]interface{}{"url": server.URL + "/docs/widgets/"})
+	if !resp.Ok || page.Format != FormatMarkdown || page.Title != "Widget API" || page.Cached {
+		t.Fatalf("unexpected response: %+v %+v", resp, page)
+	}
+	wantMarkdown := strings.ReplaceAll(testMarkdown, "https://example.com", server.URL)
+	if page.Content != wantMarkdown {
+		t.Errorf("content =\n%s\nwant\n%s", page.Content, wantMarkdown)
+	}
+
+	// Reading on in parts uses the cache
+	resp, page = execute(t, tool, map[string]interface{}{"url": server.URL + "/docs/widgets/", "max_chars": 10.0})
+	if !page.Cached || page.NextOffset != 10 || !strings.Contains(resp.Summary, "continue with offset 10") {
+		t.Errorf("unexpected first part: %+v %+v", resp, page)
+	}
+	_, page = execute(t, tool, map[string]interface{}{"url": server.URL + "/docs/widgets/", "offset": 10.0, "max_chars": 10.0})
+	if page.Content != wantMarkdown[10:20] || page.Offset != 10 {
+		t.Errorf("second part = %+v", page)
+	}
+	if docHits.Load() != 1 {
+		t.Errorf("page fetched %d times, want once", docHits.Load())
+	}
+	execute(t, tool, map[string]interface{}{"url": server.URL + "/docs/widgets/", "refresh": true})
+	tool.Reset()
+	execute(t, tool, map[string]interface{}{"url": server.URL + "/docs/widgets/"})
+	if docHits.Load() != 3 {
+		t.Errorf("page fetched %d times after refresh and reset, want 3", docHits.Load())
+	}
+
+	_, page = execute(t, tool, map[string]interface{}{"url": server.URL + "/api"})
+	if page.Format != FormatJSON || page.Content != "{\n  \"name\": \"widget\",\n  \"tags\": [\n    \"a\",\n    \"b\"\n  ]\n}" {
+		t.Errorf("JSON page = %+v", page)
+	}
+
+	_, page = execute(t, tool, map[string]interface{}{"url": server.URL + "/latin1.txt"})
+	if page.Format != FormatText || page.Content != "café" {
+		t.Errorf("text page = %+v", page)
+	}
+
+	_, page = execute(t, tool, map[string]interface{}{"url": server.URL + "/big.txt"})
+	if !page.BodyTruncated || page.TotalChars != 4096 {
+		t.Errorf("big page = truncated %v, %d chars", page.BodyTruncated, page.TotalChars)
+	}
+
+	_, page = execute(t, tool, map[string]interface{}{"url": server.URL + "/moved"})
+	if page.URL != server.URL+"/docs/widgets/" {
+		t.Errorf("redirected URL = %q", page.URL)
+	}
+
+	failures := map[string]string{
+		"/image.png": "unsupported content type image/png",
+		"/missing":   "HTTP 404",
+		"/loop":      "stopped after 3 redirects",
+		"/away":      "domain blocked.example is blocked",
+		"/slow":      "timed out",
+	}
+	for path, want := range failures {
+		resp, _ := execute(t, tool, map[string]interface{}{"url": server.URL + path})
+		if resp.Ok || !strings.Contains(resp.Error, want) {
+			t.Errorf("%s: response %+v, want error containing %q", path, resp, want)
+		}
+	}
+}
+
+// TestPrivateAddress tests which addresses web_fetch refuses by default
+func TestPrivateAddress(t *testing.T) {
+	tests := map[string]bool{
+		"127.0.0.1":        true,
+		"10.1.2.3":         true,
+		"172.16.0.1":       true,
+		"192.168.1.1":      true,
+		"169.254.169.254":  true,
+		"100.64.0.1":       true,
+		"0.0.0.0":          true,
+		"::1":              true,
+		"fd00::1":          true,
+		"fe80::1":          true,
+		"::ffff:127.0.0.1": true,
+		"93.184.216.34":    false,
+		"2606:4700::1111":  false,
+	}
+	for addr, want := range tests {
+		if got := privateAddress(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("privateAddress(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+// TestWebFetchToolPrivateNetworks tests that private addresses are refused
+// after DNS resolution, also when a redirect leads to them
+func TestWebFetchToolPrivateNetworks(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("internal"))
+	}))
+	defer target.Close()
+
+	tool := NewWebFetchTool(4096, time.Second)
+	resp, _ := execute(t, tool, map[string]interface{}{"url": target.URL})
+	if resp.Ok || !strings.Contains(resp.Error, "127.0.0.1 is a private network address") {
+		t.Errorf("direct fetch: response %+v, want a private network error", resp)
+	}
+
+	// A server on another loopback address stands in for a public one that
+	// redirects to 127.0.0.1
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("cannot listen on 127.0.0.2: %v", err)
+	}
+	redirector := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+"/metadata", http.StatusFound)
+	}))
+	redirector.Listener.Close()
+	redirector.Listener = listener
+	redirector.Start()
+	defer redirector.Close()
+
+	tool.blockedIP = func(ip netip.Addr) bool { return ip == netip.MustParseAddr("127.0.0.1") }
+	resp, _ = execute(t, tool, map[string]interface{}{"url": redirector.URL})
+	if resp.Ok || !strings.Contains(resp.Error, "127.0.0.1 is a private network address") {
+		t.Errorf("redirect: response %+v, want a private network error", resp)
+	}
+
+	tool.SetAllowPrivateNetworks(true)
+	if resp, page := execute(t, tool, map[string]interface{}{"url": redirector.URL}); !resp.Ok || page.Content != "internal" {
+		t.Errorf("allowed: response %+v, page %+v", resp, page)
+	}
+}